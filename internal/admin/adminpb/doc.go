@@ -0,0 +1,5 @@
+// Package adminpb contains the generated protobuf and gRPC bindings for the
+// watcher admin API defined in admin.proto.
+package adminpb
+
+//go:generate sh -c "cd ../../.. && protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative internal/admin/adminpb/admin.proto"
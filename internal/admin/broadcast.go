@@ -0,0 +1,47 @@
+package admin
+
+import "sync"
+
+// Broadcaster fans routing state out to WatchTransitions subscribers. Slow
+// subscribers drop updates rather than blocking transitions.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan State]struct{}
+}
+
+// NewBroadcaster constructs an empty Broadcaster.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan State]struct{})}
+}
+
+// Publish delivers state to every subscriber with buffer space.
+func (b *Broadcaster) Publish(state State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a new subscriber.
+func (b *Broadcaster) Subscribe() (<-chan State, func()) {
+	ch := make(chan State, 16)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
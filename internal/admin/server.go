@@ -0,0 +1,169 @@
+// Package admin exposes the watcher's routing controls over gRPC so deployment
+// orchestrators can switch routing directly instead of editing pod labels.
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/denniswebb/ghostwire/internal/admin/adminpb"
+)
+
+// State is the routing state reported to admin clients.
+type State struct {
+	Role           string
+	JumpActive     bool
+	RuleCount      int
+	NATChain       string
+	JumpHook       string
+	LastTransition time.Time
+	LastError      string
+}
+
+// Controller is the watcher functionality the admin API drives.
+type Controller interface {
+	Activate(ctx context.Context) error
+	Deactivate(ctx context.Context) error
+	Refresh(ctx context.Context) error
+	State() State
+	// Subscribe returns a channel receiving the state after every transition
+	// and a function that releases the subscription.
+	Subscribe() (<-chan State, func())
+}
+
+// Server implements adminpb.AdminServer on top of a Controller.
+type Server struct {
+	adminpb.UnimplementedAdminServer
+
+	controller Controller
+	logger     *slog.Logger
+}
+
+// NewServer constructs a Server.
+func NewServer(controller Controller, logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{controller: controller, logger: logger}
+}
+
+// Activate installs the DNAT jump.
+func (s *Server) Activate(ctx context.Context, _ *adminpb.ActivateRequest) (*adminpb.Status, error) {
+	s.logger.Info("admin activate requested")
+	if err := s.controller.Activate(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "activate: %v", err)
+	}
+	return toProto(s.controller.State()), nil
+}
+
+// Deactivate removes the DNAT jump.
+func (s *Server) Deactivate(ctx context.Context, _ *adminpb.DeactivateRequest) (*adminpb.Status, error) {
+	s.logger.Info("admin deactivate requested")
+	if err := s.controller.Deactivate(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "deactivate: %v", err)
+	}
+	return toProto(s.controller.State()), nil
+}
+
+// GetStatus returns the current routing state.
+func (s *Server) GetStatus(context.Context, *adminpb.GetStatusRequest) (*adminpb.Status, error) {
+	return toProto(s.controller.State()), nil
+}
+
+// Refresh re-reads the DNAT map and re-applies the current role.
+func (s *Server) Refresh(ctx context.Context, _ *adminpb.RefreshRequest) (*adminpb.Status, error) {
+	s.logger.Info("admin refresh requested")
+	if err := s.controller.Refresh(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "refresh: %v", err)
+	}
+	return toProto(s.controller.State()), nil
+}
+
+// WatchTransitions streams the state after every transition.
+func (s *Server) WatchTransitions(_ *adminpb.WatchTransitionsRequest, stream grpc.ServerStreamingServer[adminpb.Status]) error {
+	updates, release := s.controller.Subscribe()
+	defer release()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case state, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(toProto(state)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProto(state State) *adminpb.Status {
+	out := &adminpb.Status{
+		Role:       state.Role,
+		JumpActive: state.JumpActive,
+		RuleCount:  int32(state.RuleCount), // #nosec G115 -- rule counts are far below int32 range.
+		NatChain:   state.NATChain,
+		JumpHook:   state.JumpHook,
+		LastError:  state.LastError,
+	}
+	if !state.LastTransition.IsZero() {
+		out.LastTransitionTime = timestamppb.New(state.LastTransition)
+	}
+	return out
+}
+
+// ServerTLSConfig builds a TLS configuration that requires clients to present a
+// certificate signed by the CA bundle at clientCAFile.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || clientCAFile == "" {
+		return nil, errors.New("admin API requires a certificate, key and client CA bundle")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load admin certificate: %w", err)
+	}
+
+	// #nosec G304 -- client CA path comes from operator configuration.
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read admin client CA %s: %w", clientCAFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in admin client CA %s", clientCAFile)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// NewGRPCServer returns a gRPC server with the admin service registered. A nil
+// tlsConfig serves plaintext, which is only intended for tests.
+func NewGRPCServer(server *Server, tlsConfig *tls.Config) *grpc.Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	adminpb.RegisterAdminServer(grpcServer, server)
+	return grpcServer
+}
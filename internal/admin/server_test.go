@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/denniswebb/ghostwire/internal/admin/adminpb"
+)
+
+type fakeController struct {
+	mu          sync.Mutex
+	state       State
+	refreshErr  error
+	broadcaster *Broadcaster
+}
+
+func (f *fakeController) set(role string, active bool) {
+	f.mu.Lock()
+	f.state.Role = role
+	f.state.JumpActive = active
+	f.state.LastTransition = time.Unix(1700000000, 0)
+	state := f.state
+	f.mu.Unlock()
+	f.broadcaster.Publish(state)
+}
+
+func (f *fakeController) Activate(context.Context) error   { f.set("preview", true); return nil }
+func (f *fakeController) Deactivate(context.Context) error { f.set("active", false); return nil }
+func (f *fakeController) Refresh(context.Context) error    { return f.refreshErr }
+func (f *fakeController) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+func (f *fakeController) Subscribe() (<-chan State, func()) { return f.broadcaster.Subscribe() }
+
+func newTestClient(t *testing.T, controller Controller) adminpb.AdminClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(NewServer(controller, nil), nil)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return adminpb.NewAdminClient(conn)
+}
+
+func TestServerActivateDeactivateAndStatus(t *testing.T) {
+	t.Parallel()
+
+	controller := &fakeController{state: State{RuleCount: 3, NATChain: "CANARY_DNAT", JumpHook: "OUTPUT"}, broadcaster: NewBroadcaster()}
+	client := newTestClient(t, controller)
+	ctx := context.Background()
+
+	got, err := client.Activate(ctx, &adminpb.ActivateRequest{})
+	if err != nil {
+		t.Fatalf("Activate returned error: %v", err)
+	}
+	if got.GetRole() != "preview" || !got.GetJumpActive() || got.GetRuleCount() != 3 || got.GetNatChain() != "CANARY_DNAT" {
+		t.Fatalf("unexpected status after activate: %v", got)
+	}
+	if got.GetLastTransitionTime().AsTime().Unix() != 1700000000 {
+		t.Fatalf("unexpected transition time: %v", got.GetLastTransitionTime())
+	}
+
+	if _, err := client.Deactivate(ctx, &adminpb.DeactivateRequest{}); err != nil {
+		t.Fatalf("Deactivate returned error: %v", err)
+	}
+	got, err = client.GetStatus(ctx, &adminpb.GetStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetStatus returned error: %v", err)
+	}
+	if got.GetRole() != "active" || got.GetJumpActive() {
+		t.Fatalf("unexpected status after deactivate: %v", got)
+	}
+}
+
+func TestServerRefreshError(t *testing.T) {
+	t.Parallel()
+
+	controller := &fakeController{refreshErr: errors.New("boom"), broadcaster: NewBroadcaster()}
+	client := newTestClient(t, controller)
+
+	_, err := client.Refresh(context.Background(), &adminpb.RefreshRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal error, got %v", err)
+	}
+}
+
+func TestServerWatchTransitions(t *testing.T) {
+	t.Parallel()
+
+	controller := &fakeController{broadcaster: NewBroadcaster()}
+	client := newTestClient(t, controller)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchTransitions(ctx, &adminpb.WatchTransitionsRequest{})
+	if err != nil {
+		t.Fatalf("WatchTransitions returned error: %v", err)
+	}
+
+	// The subscription is registered asynchronously; keep publishing until the
+	// first event arrives.
+	received := make(chan *adminpb.Status, 1)
+	go func() {
+		msg, err := stream.Recv()
+		if err == nil {
+			received <- msg
+		}
+	}()
+
+	for {
+		controller.set("preview", true)
+		select {
+		case msg := <-received:
+			if msg.GetRole() != "preview" || !msg.GetJumpActive() {
+				t.Fatalf("unexpected streamed status: %v", msg)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for transition event")
+		}
+	}
+}
+
+func TestServerTLSConfigRequiresAllFiles(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ServerTLSConfig("cert.pem", "key.pem", ""); err == nil {
+		t.Fatalf("expected error without client CA")
+	}
+}
@@ -0,0 +1,10 @@
+// Package v1alpha1 contains the ghostwire.dev/v1alpha1 API types. The typed
+// clientset in internal/client/clientset/versioned is generated from the
+// +genclient types here.
+//
+// +k8s:deepcopy-gen=package
+// +groupName=ghostwire.dev
+package v1alpha1
+
+//go:generate go run k8s.io/code-generator/cmd/deepcopy-gen@v0.34.1 --output-file zz_generated.deepcopy.go --go-header-file /dev/null .
+//go:generate go run k8s.io/code-generator/cmd/client-gen@v0.34.1 --clientset-name versioned --input-base github.com/denniswebb/ghostwire/internal --input apis/v1alpha1 --output-dir ../../client/clientset --output-pkg github.com/denniswebb/ghostwire/internal/client/clientset --go-header-file /dev/null
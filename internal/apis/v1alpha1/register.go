@@ -0,0 +1,42 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group for ghostwire custom resources.
+const GroupName = "ghostwire.dev"
+
+// SchemeGroupVersion identifies the group/version used to register these objects.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// GhostwireConfigResource is the GroupVersionResource served for GhostwireConfig objects.
+var GhostwireConfigResource = SchemeGroupVersion.WithResource("ghostwireconfigs")
+
+// GhostwireMappingResource is the GroupVersionResource served for GhostwireMapping objects.
+var GhostwireMappingResource = SchemeGroupVersion.WithResource("ghostwiremappings")
+
+// GhostwireStatusResource is the GroupVersionResource served for GhostwireStatus objects.
+var GhostwireStatusResource = SchemeGroupVersion.WithResource("ghostwirestatuses")
+
+var (
+	// SchemeBuilder collects the functions that register these types with a scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the ghostwire.dev/v1alpha1 types with a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&GhostwireConfig{},
+		&GhostwireConfigList{},
+		&GhostwireMapping{},
+		&GhostwireMappingList{},
+		&GhostwireStatus{},
+		&GhostwireStatusList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
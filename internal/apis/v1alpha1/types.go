@@ -0,0 +1,165 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GhostwireConfig captures the namespace-wide ghostwire settings that would
+// otherwise be supplied through GW_* environment variables. Fields left empty
+// fall back to the environment/flag values.
+//
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,shortName=gwc
+type GhostwireConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GhostwireConfigSpec `json:"spec,omitempty"`
+}
+
+// GhostwireConfigSpec lists the tunable discovery, iptables, and role settings.
+type GhostwireConfigSpec struct {
+	// SvcPreviewPattern is the Go-template used to derive preview service names.
+	// +optional
+	SvcPreviewPattern string `json:"svcPreviewPattern,omitempty"`
+	// ActiveSuffix identifies active services when pairing by suffix.
+	// +optional
+	ActiveSuffix string `json:"activeSuffix,omitempty"`
+	// PreviewSuffix replaces ActiveSuffix when deriving preview names.
+	// +optional
+	PreviewSuffix string `json:"previewSuffix,omitempty"`
+	// ExcludeCIDRs bypass DNAT handling entirely.
+	// +optional
+	ExcludeCIDRs []string `json:"excludeCidrs,omitempty"`
+	// NATChain is the iptables chain holding the DNAT rules.
+	// +optional
+	NATChain string `json:"natChain,omitempty"`
+	// JumpHook is the built-in chain that receives the jump (OUTPUT or
+	// PREROUTING), or a comma-separated list of both.
+	// +optional
+	JumpHook string `json:"jumpHook,omitempty"`
+	// RoleLabelKey is the pod label the watcher reads.
+	// +optional
+	RoleLabelKey string `json:"roleLabelKey,omitempty"`
+	// RoleActive is the label value that disables preview routing.
+	// +optional
+	RoleActive string `json:"roleActive,omitempty"`
+	// RolePreview is the label value that enables preview routing.
+	// +optional
+	RolePreview string `json:"rolePreview,omitempty"`
+}
+
+// GhostwireConfigList is a list of GhostwireConfig objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GhostwireConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GhostwireConfig `json:"items"`
+}
+
+// GhostwireMapping overrides convention-based discovery for a single active
+// service: it can pin an explicit preview service, remap individual ports, or
+// exclude the service from preview routing altogether.
+//
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,shortName=gwm
+// +kubebuilder:subresource:status
+type GhostwireMapping struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostwireMappingSpec   `json:"spec,omitempty"`
+	Status GhostwireMappingStatus `json:"status,omitempty"`
+}
+
+// GhostwireMappingSpec describes the override for one active service.
+type GhostwireMappingSpec struct {
+	// Service is the active service the override applies to.
+	Service string `json:"service"`
+	// PreviewService replaces the pattern-derived preview service name.
+	// +optional
+	PreviewService string `json:"previewService,omitempty"`
+	// Exclude removes the service from preview routing entirely.
+	// +optional
+	Exclude bool `json:"exclude,omitempty"`
+	// Ports pins preview ports for individual active ports.
+	// +optional
+	Ports []PortOverride `json:"ports,omitempty"`
+}
+
+// PortOverride routes one active service port to a different preview port.
+type PortOverride struct {
+	Port        int32  `json:"port"`
+	PreviewPort int32  `json:"previewPort"`
+	Protocol    string `json:"protocol,omitempty"`
+}
+
+// GhostwireMappingStatus reports how the override was applied during discovery.
+type GhostwireMappingStatus struct {
+	// ObservedGeneration is the spec generation the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conflicts lists reasons the override could not be applied as written.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// GhostwireMappingList is a list of GhostwireMapping objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GhostwireMappingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GhostwireMapping `json:"items"`
+}
+
+// GhostwireStatus reports the routing state of a single ghostwire-managed pod.
+// The watcher owns the object, names it after its pod and sets the pod as the
+// owner so it is garbage collected with it.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GhostwireStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status RoutingState `json:"status,omitempty"`
+}
+
+// RoutingState is the watcher's view of routing after its latest transition.
+type RoutingState struct {
+	// PodName is the pod the state belongs to.
+	PodName string `json:"podName"`
+	// Role is the role label value observed at the latest transition.
+	// +optional
+	Role string `json:"role,omitempty"`
+	// JumpActive reports whether the DNAT jump is installed.
+	JumpActive bool `json:"jumpActive"`
+	// RuleCount is the number of DNAT mappings init installed.
+	RuleCount int32 `json:"ruleCount"`
+	// NATChain and JumpHook identify where the jump lives.
+	// +optional
+	NATChain string `json:"natChain,omitempty"`
+	// +optional
+	JumpHook string `json:"jumpHook,omitempty"`
+	// LastTransitionTime is when the watcher last handled a role change.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// LastError is the error from the latest transition, empty on success.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// GhostwireStatusList is a list of GhostwireStatus objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GhostwireStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GhostwireStatus `json:"items"`
+}
@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var chainNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,28}$`)
+
+// Validate mirrors the CRD's OpenAPI schema so objects created before the schema
+// was installed, or read from older API servers, are still rejected consistently.
+func (s GhostwireConfigSpec) Validate() error {
+	var errs []error
+
+	if s.SvcPreviewPattern != "" && !strings.Contains(s.SvcPreviewPattern, "name") {
+		errs = append(errs, fmt.Errorf("svcPreviewPattern %q must reference {{name}}", s.SvcPreviewPattern))
+	}
+	if s.NATChain != "" && !chainNamePattern.MatchString(s.NATChain) {
+		errs = append(errs, fmt.Errorf("natChain %q must match %s", s.NATChain, chainNamePattern.String()))
+	}
+	if s.JumpHook != "" && !validJumpHooks(s.JumpHook) {
+		errs = append(errs, fmt.Errorf("jumpHook %q must list OUTPUT and/or PREROUTING", s.JumpHook))
+	}
+	for _, cidr := range s.ExcludeCIDRs {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+			errs = append(errs, fmt.Errorf("excludeCidrs entry %q: %w", cidr, err))
+		}
+	}
+	if s.RoleActive != "" && s.RoleActive == s.RolePreview {
+		errs = append(errs, fmt.Errorf("roleActive and rolePreview must differ"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the override for internally inconsistent settings. Whether the
+// referenced services and ports exist is only known during discovery and is
+// reported through the status conflicts instead.
+func (s GhostwireMappingSpec) Validate() error {
+	var errs []error
+
+	if s.Service == "" {
+		errs = append(errs, fmt.Errorf("service is required"))
+	}
+	if s.Exclude && (s.PreviewService != "" || len(s.Ports) > 0) {
+		errs = append(errs, fmt.Errorf("exclude cannot be combined with previewService or ports"))
+	}
+	if s.PreviewService != "" && s.PreviewService == s.Service {
+		errs = append(errs, fmt.Errorf("previewService must differ from service"))
+	}
+	for _, port := range s.Ports {
+		if port.Port < 1 || port.Port > 65535 || port.PreviewPort < 1 || port.PreviewPort > 65535 {
+			errs = append(errs, fmt.Errorf("port override %d -> %d out of range", port.Port, port.PreviewPort))
+		}
+		switch port.Protocol {
+		case "", "TCP", "UDP", "SCTP":
+		default:
+			errs = append(errs, fmt.Errorf("port override protocol %q must be TCP, UDP, or SCTP", port.Protocol))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validJumpHooks reports whether every entry of the comma-separated hooks is
+// OUTPUT or PREROUTING.
+func validJumpHooks(hooks string) bool {
+	for _, hook := range strings.Split(hooks, ",") {
+		switch strings.TrimSpace(hook) {
+		case "OUTPUT", "PREROUTING":
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGhostwireConfigSpecValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		spec        GhostwireConfigSpec
+		expectError string
+	}{
+		{name: "empty spec", spec: GhostwireConfigSpec{}},
+		{
+			name: "fully populated",
+			spec: GhostwireConfigSpec{
+				SvcPreviewPattern: "{{name}}-canary",
+				NATChain:          "CANARY_DNAT",
+				JumpHook:          "PREROUTING",
+				ExcludeCIDRs:      []string{"169.254.169.254/32", "fd00::/8"},
+				RoleActive:        "blue",
+				RolePreview:       "green",
+			},
+		},
+		{name: "pattern without name", spec: GhostwireConfigSpec{SvcPreviewPattern: "static"}, expectError: "svcPreviewPattern"},
+		{name: "chain with spaces", spec: GhostwireConfigSpec{NATChain: "BAD CHAIN"}, expectError: "natChain"},
+		{name: "both hooks", spec: GhostwireConfigSpec{JumpHook: "OUTPUT,PREROUTING"}},
+		{name: "unsupported hook", spec: GhostwireConfigSpec{JumpHook: "INPUT"}, expectError: "jumpHook"},
+		{name: "unsupported hook in list", spec: GhostwireConfigSpec{JumpHook: "OUTPUT,INPUT"}, expectError: "jumpHook"},
+		{name: "invalid cidr", spec: GhostwireConfigSpec{ExcludeCIDRs: []string{"10.0.0.0"}}, expectError: "excludeCidrs"},
+		{name: "identical roles", spec: GhostwireConfigSpec{RoleActive: "x", RolePreview: "x"}, expectError: "must differ"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.spec.Validate()
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestGhostwireConfigDeepCopy(t *testing.T) {
+	t.Parallel()
+
+	original := &GhostwireConfig{Spec: GhostwireConfigSpec{ExcludeCIDRs: []string{"10.0.0.0/8"}}}
+	clone := original.DeepCopy()
+	clone.Spec.ExcludeCIDRs[0] = "192.168.0.0/16"
+
+	if original.Spec.ExcludeCIDRs[0] != "10.0.0.0/8" {
+		t.Fatalf("deep copy shares slice storage: %v", original.Spec.ExcludeCIDRs)
+	}
+}
+
+func TestGhostwireMappingSpecValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		spec        GhostwireMappingSpec
+		expectError string
+	}{
+		{name: "explicit preview", spec: GhostwireMappingSpec{Service: "orders", PreviewService: "orders-canary", Ports: []PortOverride{{Port: 80, PreviewPort: 8080, Protocol: "TCP"}}}},
+		{name: "exclude only", spec: GhostwireMappingSpec{Service: "orders", Exclude: true}},
+		{name: "missing service", spec: GhostwireMappingSpec{PreviewService: "x"}, expectError: "service is required"},
+		{name: "exclude with preview", spec: GhostwireMappingSpec{Service: "orders", Exclude: true, PreviewService: "x"}, expectError: "exclude cannot be combined"},
+		{name: "self preview", spec: GhostwireMappingSpec{Service: "orders", PreviewService: "orders"}, expectError: "must differ"},
+		{name: "port out of range", spec: GhostwireMappingSpec{Service: "orders", Ports: []PortOverride{{Port: 0, PreviewPort: 80}}}, expectError: "out of range"},
+		{name: "bad protocol", spec: GhostwireMappingSpec{Service: "orders", Ports: []PortOverride{{Port: 80, PreviewPort: 80, Protocol: "ICMP"}}}, expectError: "protocol"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.spec.Validate()
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package backend puts the way ghostwire programs the kernel behind one
+// interface. The commands drive a Backend picked by name from the
+// rule-backend setting; the iptables backend, which runs iptables and
+// ip6tables through an Executor, is the default. nftables or eBPF backends,
+// and fakes in tests, register themselves under their own name.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// DefaultName is the backend used when none is configured.
+const DefaultName = "iptables"
+
+// Backend manages one DNAT chain and the jumps to it from the hooks.
+type Backend interface {
+	// EnsureChain creates the chain, or empties it when it exists.
+	EnsureChain(ctx context.Context) error
+	// ApplyMappings rebuilds the chain from mappings. The jumps are left
+	// alone, so traffic is unaffected until AddJump.
+	ApplyMappings(ctx context.Context, mappings []discovery.ServiceMapping) error
+	// AddJump sends the hooks' traffic through the chain, turning preview
+	// routing on. It does nothing for jumps already in place.
+	AddJump(ctx context.Context) error
+	// RemoveJump turns preview routing off.
+	RemoveJump(ctx context.Context) error
+	// Verify compares the live chain with mappings.
+	Verify(ctx context.Context, mappings []discovery.ServiceMapping) ([]iptables.Discrepancy, error)
+	// Teardown removes the jumps and the chain.
+	Teardown(ctx context.Context) error
+}
+
+// Options describe the chain a backend manages.
+type Options struct {
+	// Executor runs the commands of backends that shell out. Backends that
+	// talk to the kernel directly ignore it.
+	Executor iptables.Executor
+	// Rules shape the chain; Rules.ChainName names it.
+	Rules iptables.Config
+	Hooks []string
+	// Position places the jump within each hook.
+	Position iptables.JumpPosition
+	Logger   *slog.Logger
+}
+
+// Factory builds a backend for opts.
+type Factory func(opts Options) (Backend, error)
+
+var (
+	backendsMu sync.RWMutex
+	factories  = map[string]Factory{}
+)
+
+// Register makes a backend available to New under name. Like
+// discovery.RegisterSource it panics on an empty name or one registered
+// twice.
+func Register(name string, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("backend: Register needs a name and a factory")
+	}
+	if _, exists := factories[name]; exists {
+		panic("backend: " + name + " registered twice")
+	}
+	factories[name] = factory
+}
+
+// Names returns the registered backend names, sorted.
+func Names() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New builds the backend registered as name, DefaultName when name is empty.
+func New(name string, opts Options) (Backend, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	backendsMu.RLock()
+	factory, ok := factories[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown rule backend %q (registered: %s)", name, strings.Join(Names(), ", "))
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return factory(opts)
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/pkg/iptablestest"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// recordingBackend is the kind of fake a test registers in place of iptables.
+type recordingBackend struct {
+	chain string
+	calls []string
+}
+
+func (r *recordingBackend) record(call string) error {
+	r.calls = append(r.calls, call+" "+r.chain)
+	return nil
+}
+
+func (r *recordingBackend) EnsureChain(context.Context) error { return r.record("ensure") }
+func (r *recordingBackend) ApplyMappings(context.Context, []discovery.ServiceMapping) error {
+	return r.record("apply")
+}
+func (r *recordingBackend) AddJump(context.Context) error    { return r.record("add") }
+func (r *recordingBackend) RemoveJump(context.Context) error { return r.record("remove") }
+func (r *recordingBackend) Verify(context.Context, []discovery.ServiceMapping) ([]iptables.Discrepancy, error) {
+	return nil, r.record("verify")
+}
+func (r *recordingBackend) Teardown(context.Context) error { return r.record("teardown") }
+
+func TestRegistry(t *testing.T) {
+	recorder := &recordingBackend{}
+	Register("recording", func(opts Options) (Backend, error) {
+		recorder.chain = opts.Rules.ChainName
+		return recorder, nil
+	})
+
+	if names := Names(); !slices.Equal(names, []string{"iptables", "recording"}) {
+		t.Fatalf("unexpected backends %v", names)
+	}
+
+	rules, err := New("recording", Options{Rules: iptables.Config{ChainName: "CANARY_DNAT"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := rules.AddJump(context.Background()); err != nil {
+		t.Fatalf("AddJump returned error: %v", err)
+	}
+	if !slices.Equal(recorder.calls, []string{"add CANARY_DNAT"}) {
+		t.Fatalf("unexpected calls %v", recorder.calls)
+	}
+
+	if _, err := New("nftables", Options{}); err == nil || !strings.Contains(err.Error(), "registered: iptables, recording") {
+		t.Fatalf("expected an unknown backend error listing the registered ones, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a name twice to panic")
+		}
+	}()
+	Register("recording", func(Options) (Backend, error) { return recorder, nil })
+}
+
+func TestIPTablesTeardown(t *testing.T) {
+	t.Parallel()
+
+	executor := iptablestest.New()
+	executor.SetChain("nat", "CANARY_DNAT", true)
+
+	rules, err := New("", Options{
+		Executor: executor,
+		Rules:    iptables.Config{ChainName: "CANARY_DNAT"},
+		Hooks:    []string{"OUTPUT", "PREROUTING"},
+		Logger:   testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := rules.Teardown(context.Background()); err != nil {
+		t.Fatalf("Teardown returned error: %v", err)
+	}
+
+	executor.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-D", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+	executor.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-D", "PREROUTING", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+	executor.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-F", "CANARY_DNAT")
+	executor.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-X", "CANARY_DNAT")
+
+	if _, err := New("", Options{Rules: iptables.Config{ChainName: "CANARY_DNAT"}}); err == nil {
+		t.Fatal("expected the iptables backend to require an executor")
+	}
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+const natTable = "nat"
+
+func init() {
+	Register(DefaultName, newIPTables)
+}
+
+// ipTables drives the nat table through iptables and ip6tables.
+type ipTables struct {
+	opts Options
+}
+
+func newIPTables(opts Options) (Backend, error) {
+	if opts.Executor == nil {
+		return nil, errors.New("iptables backend needs an executor")
+	}
+	if strings.TrimSpace(opts.Rules.ChainName) == "" {
+		opts.Rules.ChainName = config.DefaultNATChain
+	}
+	if err := iptables.ValidateChainName(opts.Rules.ChainName); err != nil {
+		return nil, err
+	}
+	if len(opts.Hooks) == 0 {
+		opts.Hooks = []string{config.DefaultJumpHook}
+	}
+	return &ipTables{opts: opts}, nil
+}
+
+func (b *ipTables) EnsureChain(ctx context.Context) error {
+	return iptables.EnsureChain(ctx, b.opts.Executor, natTable, b.opts.Rules.ChainName, b.opts.Rules.IPv6, b.opts.Logger)
+}
+
+func (b *ipTables) ApplyMappings(ctx context.Context, mappings []discovery.ServiceMapping) error {
+	return iptables.SetupWithExecutor(ctx, b.opts.Executor, b.opts.Rules, mappings, b.opts.Logger)
+}
+
+func (b *ipTables) AddJump(ctx context.Context) error {
+	return iptables.AddJumpsAt(ctx, b.opts.Executor, natTable, b.opts.Hooks, b.opts.Rules.ChainName, b.opts.Position, b.opts.Rules.IPv6, b.opts.Logger)
+}
+
+func (b *ipTables) RemoveJump(ctx context.Context) error {
+	return iptables.RemoveJumps(ctx, b.opts.Executor, natTable, b.opts.Hooks, b.opts.Rules.ChainName, b.opts.Rules.IPv6, b.opts.Logger)
+}
+
+func (b *ipTables) Verify(ctx context.Context, mappings []discovery.ServiceMapping) ([]iptables.Discrepancy, error) {
+	return iptables.VerifyDNATRules(ctx, b.opts.Executor, natTable, b.opts.Rules.ChainName, mappings, b.opts.Rules.IPv6)
+}
+
+func (b *ipTables) Teardown(ctx context.Context) error {
+	if err := b.RemoveJump(ctx); err != nil {
+		return fmt.Errorf("remove jumps: %w", err)
+	}
+	return iptables.DeleteChain(ctx, b.opts.Executor, natTable, b.opts.Rules.ChainName, b.opts.Rules.IPv6, b.opts.Logger)
+}
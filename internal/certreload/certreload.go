@@ -0,0 +1,136 @@
+// Package certreload serves a TLS certificate from disk and picks up its
+// replacement, as written by cert-manager or a rotated Secret, without
+// restarting the listener. Handshakes after a reload present the new
+// certificate; connections already open, and requests in flight on them,
+// are untouched.
+package certreload
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the current certificate of one server.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	observe  func(err error)
+	logger   *slog.Logger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	stamp string
+}
+
+// New loads the pair at certFile and keyFile. observe, when set, is called
+// after every later reload attempt with its error, nil on success.
+func New(certFile, keyFile string, observe func(err error), logger *slog.Logger) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("a TLS certificate and key are required")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, observe: observe, logger: logger}
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(stamp); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns config (a new one when nil) serving the current
+// certificate in place of any static ones.
+func (r *Reloader) TLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	config.Certificates = nil
+	config.GetCertificate = r.GetCertificate
+	return config
+}
+
+// Reload loads the pair again if either file changed since the last load and
+// reports whether it did. A pair that fails to load, such as a certificate
+// whose key is not written yet, leaves the previous certificate in place and
+// is retried on the next call.
+func (r *Reloader) Reload() (bool, error) {
+	stamp, err := r.fileStamp()
+	if err == nil {
+		r.mu.RLock()
+		unchanged := stamp == r.stamp
+		r.mu.RUnlock()
+		if unchanged {
+			return false, nil
+		}
+		err = r.load(stamp)
+	}
+	if r.observe != nil {
+		r.observe(err)
+	}
+	if err != nil {
+		return false, err
+	}
+	r.logger.Info("tls certificate reloaded", slog.String("cert_file", r.certFile))
+	return true, nil
+}
+
+// Run calls Reload every interval until ctx is cancelled. Failures are
+// logged.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.Reload(); err != nil {
+			r.logger.Warn("tls certificate reload failed; keeping the previous certificate", slog.String("cert_file", r.certFile), slog.Any("error", err))
+		}
+	}
+}
+
+func (r *Reloader) load(stamp string) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate %s: %w", r.certFile, err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.stamp = stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// fileStamp identifies the current version of both files. Stat follows the
+// symlinks of Secret volumes, which are swapped atomically on update.
+func (r *Reloader) fileStamp() (string, error) {
+	var stamp string
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp, nil
+}
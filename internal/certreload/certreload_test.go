@@ -0,0 +1,116 @@
+package certreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a fresh self-signed certificate for name and its key,
+// stamping both files with modTime so changes are seen regardless of the
+// filesystem's timestamp resolution.
+func writePair(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	write := func(path, kind string, der []byte) {
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("touch %s: %v", path, err)
+		}
+	}
+	if certFile != "" {
+		write(certFile, "CERTIFICATE", der)
+	}
+	if keyFile != "" {
+		write(keyFile, "EC PRIVATE KEY", keyDER)
+	}
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate returned error: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloaderPicksUpReplacedCertificate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writePair(t, certFile, keyFile, "first", start)
+
+	var observed []error
+	reloader, err := New(certFile, keyFile, func(err error) { observed = append(observed, err) }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if name := commonName(t, reloader); name != "first" {
+		t.Fatalf("expected the first certificate, got %s", name)
+	}
+
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Fatalf("expected unchanged files to be left alone, got %v, %v", reloaded, err)
+	}
+
+	// A certificate written before its key does not match the old key; the
+	// previous pair keeps serving until both are in place.
+	writePair(t, certFile, "", "second", start.Add(time.Minute))
+	if _, err := reloader.Reload(); err == nil {
+		t.Fatal("expected a mismatched pair to fail")
+	}
+	if name := commonName(t, reloader); name != "first" {
+		t.Fatalf("expected the first certificate to be kept, got %s", name)
+	}
+
+	writePair(t, certFile, keyFile, "third", start.Add(2*time.Minute))
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.Fatalf("expected the replaced pair to load, got %v, %v", reloaded, err)
+	}
+	if name := commonName(t, reloader); name != "third" {
+		t.Fatalf("expected the third certificate, got %s", name)
+	}
+
+	if len(observed) != 2 || observed[0] == nil || observed[1] != nil {
+		t.Fatalf("expected a failed and a successful reload to be observed, got %v", observed)
+	}
+}
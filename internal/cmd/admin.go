@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+
+	"github.com/denniswebb/ghostwire/internal/admin"
+	"github.com/denniswebb/ghostwire/internal/certreload"
+)
+
+// adminController adapts the jumpManager to the admin API.
+type adminController struct {
+	jm          *jumpManager
+	broadcaster *admin.Broadcaster
+}
+
+func (a *adminController) Activate(ctx context.Context) error {
+	return a.jm.ForceRole(ctx, a.jm.previewRole())
+}
+
+func (a *adminController) Deactivate(ctx context.Context) error {
+	return a.jm.ForceRole(ctx, a.jm.activeRole())
+}
+
+func (a *adminController) Refresh(ctx context.Context) error {
+	return a.jm.Refresh(ctx)
+}
+
+func (a *adminController) State() admin.State {
+	return toAdminState(a.jm.Status())
+}
+
+func (a *adminController) Subscribe() (<-chan admin.State, func()) {
+	return a.broadcaster.Subscribe()
+}
+
+// broadcastReporter publishes every transition to admin stream subscribers.
+type broadcastReporter struct {
+	broadcaster *admin.Broadcaster
+}
+
+func (r *broadcastReporter) Report(_ context.Context, status routingStatus) {
+	r.broadcaster.Publish(toAdminState(status))
+}
+
+func toAdminState(status routingStatus) admin.State {
+	return admin.State{
+		Role:           status.Role,
+		JumpActive:     status.JumpActive,
+		RuleCount:      status.RuleCount,
+		NATChain:       status.NATChain,
+		JumpHook:       status.JumpHook,
+		LastTransition: status.LastTransition,
+		LastError:      status.LastError,
+	}
+}
+
+// startAdminServer serves the gRPC admin API when admin-grpc-addr is set,
+// presenting the certificate held by certs. The returned server is nil when
+// the API is disabled.
+func startAdminServer(jm *jumpManager, certs *certreload.Reloader, logger *slog.Logger) (*grpc.Server, <-chan error, error) {
+	addr := strings.TrimSpace(viper.GetString("admin-grpc-addr"))
+	if addr == "" {
+		return nil, nil, nil
+	}
+
+	tlsConfig, err := admin.ServerTLSConfig(
+		strings.TrimSpace(viper.GetString("admin-tls-cert")),
+		strings.TrimSpace(viper.GetString("admin-tls-key")),
+		strings.TrimSpace(viper.GetString("admin-tls-client-ca")),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig = certs.TLSConfig(tlsConfig)
+
+	broadcaster := admin.NewBroadcaster()
+	jm.reporters = append(jm.reporters, &broadcastReporter{broadcaster: broadcaster})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listen for admin api on %s: %w", addr, err)
+	}
+
+	adminLogger := logger.With(slog.String("admin_addr", addr))
+	server := admin.NewGRPCServer(admin.NewServer(&adminController{jm: jm, broadcaster: broadcaster}, adminLogger), tlsConfig)
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		if err := server.Serve(listener); err != nil {
+			errCh <- err
+		}
+	}()
+
+	adminLogger.Info("admin api listening")
+	return server, errCh, nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/admin"
+	"github.com/denniswebb/ghostwire/internal/certreload"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+// Reasons recorded in ghostwire_admin_auth_failures_total.
+const (
+	authFailureToken       = "bad_token"
+	authFailureMissingCert = "missing_client_cert"
+	authFailureInvalidCert = "invalid_client_cert"
+)
+
+// adminAuth guards POST /role and everything under /admin. A non-empty token
+// must be presented as a bearer token; a non-nil clientCAs pool additionally
+// requires a client certificate issued by one of those CAs. Rejections are
+// logged and counted.
+type adminAuth struct {
+	token     string
+	clientCAs *x509.CertPool
+	metrics   *metrics.Metrics
+	logger    *slog.Logger
+}
+
+func (a *adminAuth) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if a.clientCAs != nil {
+		if reason, err := a.verifyClientCert(r); err != nil {
+			a.reject(r, reason, err)
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return false
+		}
+	}
+	if a.token != "" {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(a.token)) != 1 {
+			a.reject(r, authFailureToken, errors.New("missing or wrong bearer token"))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ghostwire"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return false
+		}
+	}
+	return true
+}
+
+// verifyClientCert checks the peer certificate against clientCAs. The TLS
+// listener only requests a certificate so that failures reach this handler
+// and can be counted, rather than ending as anonymous handshake errors.
+func (a *adminAuth) verifyClientCert(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return authFailureMissingCert, errors.New("no client certificate presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         a.clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return authFailureInvalidCert, err
+	}
+	return "", nil
+}
+
+func (a *adminAuth) reject(r *http.Request, reason string, err error) {
+	if a.metrics != nil {
+		a.metrics.IncrementAdminAuthFailure(reason)
+	}
+	if a.logger != nil {
+		a.logger.Warn("admin request rejected",
+			slog.String("path", r.URL.Path),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("reason", reason),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// adminHTTPTLSConfig loads the admin certificate, key and client CA bundle
+// for the dedicated admin HTTP listener, serving the certificate held by
+// certs. Client certificates are requested but verified by adminAuth.
+func adminHTTPTLSConfig(certs *certreload.Reloader) (*tls.Config, error) {
+	tlsConfig, err := admin.ServerTLSConfig(
+		strings.TrimSpace(viper.GetString("admin-tls-cert")),
+		strings.TrimSpace(viper.GetString("admin-tls-key")),
+		strings.TrimSpace(viper.GetString("admin-tls-client-ca")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientAuth = tls.RequestClientCert
+	return certs.TLSConfig(tlsConfig), nil
+}
+
+func buildAdminMux(role http.Handler, resync http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/role", role)
+	mux.Handle("/admin/resync", resync)
+	return mux
+}
+
+// startAdminHTTPServer serves handler over TLS on addr.
+func startAdminHTTPServer(addr string, tlsConfig *tls.Config, handler http.Handler, logger *slog.Logger) (*http.Server, <-chan error, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listen for admin http on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		if err := srv.Serve(tls.NewListener(listener, tlsConfig)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	logger.Info("admin http listening", slog.String("admin_http_addr", addr))
+	return srv, errCh, nil
+}
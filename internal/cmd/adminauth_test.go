@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestAdminAuthClientCertificates(t *testing.T) {
+	ca, caKey := newTestCert(t, "ghostwire-admin-ca", nil, nil, true)
+	client, _ := newTestCert(t, "operator", ca, caKey, false)
+	stranger, _ := newTestCert(t, "stranger", nil, nil, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	metricsCollector := metrics.NewMetrics()
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	auth := &adminAuth{token: "s3cret", clientCAs: pool, metrics: metricsCollector, logger: logger}
+
+	tests := []struct {
+		name  string
+		tls   *tls.ConnectionState
+		token string
+		want  bool
+	}{
+		{name: "no tls", token: "s3cret"},
+		{name: "no certificate", tls: &tls.ConnectionState{}, token: "s3cret"},
+		{name: "untrusted certificate", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{stranger}}, token: "s3cret"},
+		{name: "trusted certificate without token", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}},
+		{name: "trusted certificate and token", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}, token: "s3cret", want: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/resync", nil)
+			req.TLS = tc.tls
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			if got := auth.authorize(rec, req); got != tc.want {
+				t.Fatalf("authorize = %v, want %v", got, tc.want)
+			}
+			if !tc.want && rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status 401, got %d", rec.Code)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	metricsCollector.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`ghostwire_admin_auth_failures_total{reason="missing_client_cert"} 2`,
+		`ghostwire_admin_auth_failures_total{reason="invalid_client_cert"} 1`,
+		`ghostwire_admin_auth_failures_total{reason="bad_token"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/backend"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/rulehelper"
+)
+
+// ruleBackendName returns the configured rule backend, rejecting names no
+// backend registered.
+func ruleBackendName() (string, error) {
+	name := strings.TrimSpace(viper.GetString("rule-backend"))
+	if name == "" {
+		return backend.DefaultName, nil
+	}
+	if !slices.Contains(backend.Names(), name) {
+		return "", configError(fmt.Errorf("GW_RULE_BACKEND: unknown rule backend %q (registered: %s)", name, strings.Join(backend.Names(), ", ")))
+	}
+	return name, nil
+}
+
+// ruleBackend builds the configured backend for the chain in cfg, jumped to
+// from the configured hooks. A rule helper client always gets the rule-helper
+// backend, since it runs no commands of its own.
+func ruleBackend(executor iptables.Executor, cfg iptables.Config, logger *slog.Logger) (backend.Backend, error) {
+	name, err := ruleBackendName()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := executor.(*rulehelper.Client); ok {
+		name = rulehelper.BackendName
+	}
+	_, hooks, err := ruleNames()
+	if err != nil {
+		return nil, err
+	}
+	position, err := iptables.ParseJumpPosition(viper.GetString("jump-position"))
+	if err != nil {
+		return nil, configError(err)
+	}
+	rules, err := backend.New(name, backend.Options{
+		Executor: executor,
+		Rules:    cfg,
+		Hooks:    hooks,
+		Position: position,
+		Logger:   logger,
+	})
+	if err != nil {
+		return nil, configError(err)
+	}
+	return rules, nil
+}
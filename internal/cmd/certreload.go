@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/certreload"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+// newCertReloader loads the serving certificate of server and, unless
+// tls-reload-interval is 0, checks the files for a replacement until ctx
+// ends. Reloads are counted per server.
+func newCertReloader(ctx context.Context, server, certFile, keyFile string, m *metrics.Metrics, logger *slog.Logger) (*certreload.Reloader, error) {
+	observe := func(err error) { m.ObserveCertificateReload(server, err) }
+	reloader, err := certreload.New(certFile, keyFile, observe, logger.With(slog.String("server", server)))
+	if err != nil {
+		return nil, err
+	}
+	if interval := viper.GetDuration("tls-reload-interval"); interval > 0 {
+		go reloader.Run(ctx, interval)
+	}
+	return reloader, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// minChaosInterval bounds how often chaos mode may flip the jump.
+const minChaosInterval = 10 * time.Second
+
+// parseChaosInterval parses the chaos flap interval. Zero disables chaos mode;
+// anything else must be at least minChaosInterval.
+func parseChaosInterval(raw string) (time.Duration, error) {
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("parse chaos flap interval %q: %w", raw, err)
+	}
+	if interval != 0 && interval < minChaosInterval {
+		return 0, fmt.Errorf("chaos flap interval %s is below the minimum of %s", interval, minChaosInterval)
+	}
+	return interval, nil
+}
+
+// chaosAllowed refuses chaos mode in namespaces marked as production. A
+// namespace that cannot be read counts as production.
+func chaosAllowed(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	production, err := k8s.IsProductionNamespace(ctx, client, namespace)
+	if err != nil {
+		return fmt.Errorf("check namespace %s for production marker: %w", namespace, err)
+	}
+	if production {
+		return fmt.Errorf("namespace %s is labeled %s", namespace, k8s.NamespaceLabelProduction)
+	}
+	return nil
+}
+
+// chaosDelay picks the wait before the next flip, uniformly between interval
+// and twice interval.
+func chaosDelay(interval time.Duration, rng *rand.Rand) time.Duration {
+	return interval + time.Duration(rng.Int64N(int64(interval)+1))
+}
+
+// runChaos flips the jump at random moments until ctx is cancelled. The
+// poller only re-applies the label's role when the label changes, so a flip
+// lasts until the next flip or label change.
+func (j *jumpManager) runChaos(ctx context.Context, interval time.Duration, rng *rand.Rand) {
+	for {
+		timer := time.NewTimer(chaosDelay(interval, rng))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := j.Flap(ctx); err != nil {
+			j.logger.Error("chaos flap failed", slog.Any("error", err))
+		}
+	}
+}
+
+// Flap toggles the jump to the opposite of its current state, reporting the
+// resulting role like any other transition.
+func (j *jumpManager) Flap(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	previous := j.lastStatus.Role
+	role := j.previewValue
+	if j.jumpActive {
+		role = j.activeValue
+	}
+	j.logger.Warn("chaos mode flapping dnat jump", slog.Bool("jump_active", j.jumpActive), slog.String("role", role))
+
+	err := j.applyTransition(ctx, previous, role)
+	j.report(ctx, role, err)
+	return err
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestJumpManagerFlap(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	logger, logs := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.Flap(ctx); err != nil {
+		t.Fatalf("Flap returned error: %v", err)
+	}
+	if status := jm.Status(); status.Role != "preview" || !status.JumpActive {
+		t.Fatalf("expected first flap to activate the jump, got %+v", status)
+	}
+	exec.assertCallsContain(t, []string{"-C", "-I"})
+
+	if err := jm.Flap(ctx); err != nil {
+		t.Fatalf("Flap returned error: %v", err)
+	}
+	if status := jm.Status(); status.Role != "active" {
+		t.Fatalf("expected second flap to return to active, got %+v", status)
+	}
+	if !strings.Contains(logs.String(), "chaos mode flapping dnat jump") {
+		t.Fatalf("expected chaos log, got %s", logs.String())
+	}
+}
+
+func TestChaosGuards(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseChaosInterval("1s"); err == nil {
+		t.Fatalf("expected interval below the minimum to be rejected")
+	}
+	if interval, err := parseChaosInterval("0s"); err != nil || interval != 0 {
+		t.Fatalf("expected 0s to disable chaos, got %v, %v", interval, err)
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		if delay := chaosDelay(minChaosInterval, rng); delay < minChaosInterval || delay > 2*minChaosInterval {
+			t.Fatalf("chaos delay %s outside [%s, %s]", delay, minChaosInterval, 2*minChaosInterval)
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{k8s.NamespaceLabelProduction: "true"}}},
+	)
+	ctx := context.Background()
+	if err := chaosAllowed(ctx, client, "staging"); err != nil {
+		t.Fatalf("expected chaos to be allowed in staging: %v", err)
+	}
+	if err := chaosAllowed(ctx, client, "prod"); err == nil {
+		t.Fatalf("expected chaos to be refused in a production namespace")
+	}
+	if err := chaosAllowed(ctx, client, "missing"); err == nil {
+		t.Fatalf("expected chaos to be refused when the namespace cannot be read")
+	}
+}
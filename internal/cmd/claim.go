@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/handshake"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+const (
+	conflictRefuse = "refuse"
+	conflictWarn   = "warn"
+)
+
+// claimRules makes sure this is the only ghostwire instance managing the
+// pod's network namespace before owner touches its rules. It takes the claim
+// file on the shared volume, which a second sidecar injected into the pod
+// cannot take while this one runs, and scans the jump hooks for DNAT chains
+// of another instance, which catches sidecars that do not share the volume.
+// Either finding fails with on-conflict=refuse and is logged with warn. The
+// returned claim is nil when claim-file is empty.
+func claimRules(ctx context.Context, executor iptables.Executor, owner string, logger *slog.Logger) (*handshake.Claim, error) {
+	policy := strings.TrimSpace(viper.GetString("on-conflict"))
+	if policy != conflictRefuse && policy != conflictWarn {
+		return nil, configError(fmt.Errorf("on-conflict %q must be %s or %s", policy, conflictRefuse, conflictWarn))
+	}
+	chain, hooks, err := ruleNames()
+	if err != nil {
+		return nil, err
+	}
+
+	conflict := func(err error) error {
+		if policy == conflictWarn {
+			logger.Warn("another ghostwire instance manages this network namespace; continuing", slog.Any("error", err))
+			return nil
+		}
+		logger.Error("another ghostwire instance manages this network namespace; refusing to continue", slog.Any("error", err))
+		return iptablesError(err)
+	}
+
+	var claim *handshake.Claim
+	if path := strings.TrimSpace(viper.GetString("claim-file")); path != "" {
+		claim, err = handshake.Acquire(path, handshake.ClaimInfo{
+			Chain:   chain,
+			Hooks:   hooks,
+			Owner:   owner,
+			PID:     os.Getpid(),
+			Claimed: time.Now().UTC(),
+		})
+		if err != nil {
+			if err := conflict(err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	foreign, err := iptables.ForeignChains(ctx, executor, "nat", hooks, chain)
+	if err != nil {
+		logger.Warn("scan for other ghostwire chains failed", slog.Any("error", err))
+		return claim, nil
+	}
+	if len(foreign) > 0 {
+		err := fmt.Errorf("%s jump to other DNAT chains %s", strings.Join(hooks, ","), strings.Join(foreign, ","))
+		if err := conflict(err); err != nil {
+			_ = claim.Release()
+			return nil, err
+		}
+	}
+	return claim, nil
+}
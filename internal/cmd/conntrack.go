@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+// conntrackChecker exports the conntrack table's fill level and warns as it
+// nears its limit, since DNAT-heavy preview traffic can exhaust the table on
+// small nodes and then drops new connections of every pod sharing it.
+type conntrackChecker struct {
+	procRoot    string
+	mappings    func() []discovery.ServiceMapping
+	warnPercent int
+	metrics     *metrics.Metrics
+	events      *eventLog
+	logger      *slog.Logger
+	warned      bool
+	unreadable  bool
+}
+
+func (c *conntrackChecker) check() {
+	usage, err := iptables.ReadConntrackUsage(c.procRoot, c.mappings())
+	if err != nil {
+		// Without the counters (no nf_conntrack loaded) there is nothing to
+		// watch; say so once.
+		if !c.unreadable {
+			c.logger.Warn("conntrack usage unavailable", slog.Any("error", err))
+			c.unreadable = true
+		}
+		return
+	}
+	c.unreadable = false
+
+	c.metrics.SetConntrackUsage(usage.Count, usage.Max)
+	if usage.DNATEntriesKnown {
+		c.metrics.SetConntrackDNATEntries(usage.DNATEntries)
+	}
+
+	percent := int(usage.Utilization() * 100)
+	attrs := []any{slog.Int("entries", usage.Count), slog.Int("max", usage.Max), slog.Int("percent", percent)}
+	if usage.DNATEntriesKnown {
+		attrs = append(attrs, slog.Int("dnat_entries", usage.DNATEntries))
+	}
+	switch full := percent >= c.warnPercent; {
+	case full && !c.warned:
+		c.logger.Warn("conntrack table nearly full; new connections will be dropped once it is", attrs...)
+		c.events.Add(eventConntrack, fmt.Sprintf("conntrack table %d%% full (%d of %d entries)", percent, usage.Count, usage.Max), "", nil)
+		c.warned = true
+	case !full && c.warned:
+		c.logger.Info("conntrack table usage back below the warning level", attrs...)
+		c.warned = false
+	}
+}
+
+func (c *conntrackChecker) run(ctx context.Context, interval time.Duration) {
+	c.check()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.check()
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestConntrackCheckerWarnsNearLimit(t *testing.T) {
+	procRoot := t.TempDir()
+	netfilter := filepath.Join(procRoot, "sys", "net", "netfilter")
+	if err := os.MkdirAll(netfilter, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	setCount := func(count string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(netfilter, "nf_conntrack_count"), []byte(count+"\n"), 0o600); err != nil {
+			t.Fatalf("write count: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(netfilter, "nf_conntrack_max"), []byte("1000\n"), 0o600); err != nil {
+		t.Fatalf("write max: %v", err)
+	}
+
+	var logs bytes.Buffer
+	metricsCollector := metrics.NewMetrics()
+	checker := &conntrackChecker{
+		procRoot:    procRoot,
+		mappings:    func() []discovery.ServiceMapping { return nil },
+		warnPercent: 80,
+		metrics:     metricsCollector,
+		events:      newEventLog(10),
+		logger:      slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+
+	setCount("500")
+	checker.check()
+	if strings.Contains(logs.String(), "nearly full") {
+		t.Fatalf("did not expect a warning at 50%%, got %s", logs.String())
+	}
+
+	setCount("850")
+	checker.check()
+	checker.check()
+	if got := strings.Count(logs.String(), "conntrack table nearly full"); got != 1 {
+		t.Fatalf("expected one warning, got %d:\n%s", got, logs.String())
+	}
+	if events := checker.events.Events(); len(events) != 1 || events[0].Kind != eventConntrack {
+		t.Fatalf("expected one conntrack event, got %+v", events)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsCollector.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{"ghostwire_conntrack_entries 850", "ghostwire_conntrack_max 1000"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+
+	setCount("100")
+	checker.check()
+	if !strings.Contains(logs.String(), "back below the warning level") {
+		t.Fatalf("expected recovery to be logged, got %s", logs.String())
+	}
+}
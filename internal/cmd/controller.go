@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/denniswebb/ghostwire/internal/controller"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+const defaultMappingsConfigMap = "ghostwire-mappings"
+
+// ControllerCmd represents the ghostwire controller subcommand.
+var ControllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Discover services across namespaces and publish per-namespace mapping ConfigMaps",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		interval := viper.GetDuration("controller-interval")
+
+		configMapName := strings.TrimSpace(viper.GetString("mappings-configmap"))
+		if configMapName == "" {
+			configMapName = defaultMappingsConfigMap
+		}
+
+		namespaces := splitList(viper.GetString("controller-namespaces"))
+		selector := strings.TrimSpace(viper.GetString("controller-namespace-selector"))
+
+		overrides, err := namespaceOverrides()
+		if err != nil {
+			return err
+		}
+
+		ctrlLogger := logger.With(
+			slog.String("component", "controller"),
+			slog.String("configmap", configMapName),
+		)
+
+		clientset, err := k8s.NewInClusterClient()
+		if err != nil {
+			return kubernetesError(fmt.Errorf("create kubernetes client: %w", err))
+		}
+
+		metricsCollector := metrics.NewMetrics()
+		ctrl, err := controller.New(controller.Config{
+			Client: clientset,
+			Discover: func(ctx context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+				return discoverNamespace(ctx, clientset, namespace, overrides[namespace], ctrlLogger.With(slog.String("namespace", namespace)))
+			},
+			Namespaces:        namespaces,
+			NamespaceSelector: selector,
+			ConfigMapName:     configMapName,
+			Interval:          interval,
+			Workers:           viper.GetInt("controller-workers"),
+			NamespaceTimeout:  viper.GetDuration("controller-namespace-timeout"),
+			Observer:          metricsCollector,
+			Logger:            ctrlLogger,
+		})
+		if err != nil {
+			return configError(fmt.Errorf("create controller: %w", err))
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		if addr := strings.TrimSpace(viper.GetString("controller-metrics-addr")); addr != "" {
+			srv, err := startControllerMetrics(addr, metricsCollector, ctrlLogger)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer shutdownCancel()
+				_ = srv.Shutdown(shutdownCtx)
+			}()
+		}
+
+		ctrlLogger.Info("controller started",
+			slog.Int("namespaces", len(namespaces)),
+			slog.String("namespace_selector", selector),
+			slog.Duration("interval", interval),
+			slog.Int("workers", viper.GetInt("controller-workers")),
+		)
+
+		if err := ctrl.Run(ctx); err != nil {
+			ctrlLogger.Error("controller stopped with error", slog.Any("error", err))
+			return err
+		}
+
+		ctrlLogger.Info("controller shutdown complete")
+		return nil
+	},
+}
+
+// startControllerMetrics serves /metrics and /healthz on addr. Serve errors
+// after startup are logged; a controller keeps publishing without metrics.
+func startControllerMetrics(addr string, m *metrics.Metrics, logger *slog.Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server encountered error", slog.Any("error", err))
+		}
+	}()
+	logger.Info("controller metrics listening", slog.String("metrics_addr", addr))
+	return srv, nil
+}
+
+// loadControllerMappings reads the mappings the controller published for the
+// namespace instead of performing discovery locally.
+func loadControllerMappings(ctx context.Context, namespace, name string) ([]discovery.ServiceMapping, error) {
+	clientset, err := k8s.NewInClusterClient()
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get configmap %s/%s: %w", namespace, name, err)
+	}
+
+	return controller.ParseMappings(cm)
+}
+
+func splitList(csv string) []string {
+	var result []string
+	for _, part := range strings.Split(csv, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+// credentialChecker periodically confirms the API server still accepts the
+// watcher's service account token. The clientset re-reads the projected token
+// on its own; this check exists so a token the kubelet stopped refreshing
+// shows up as a named error and metric instead of unexplained 401s from the
+// label poll.
+type credentialChecker struct {
+	client    kubernetes.Interface
+	namespace string
+	tokenFile string
+	metrics   *metrics.Metrics
+	events    *eventLog
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+func (c *credentialChecker) check(ctx context.Context) {
+	expiry, err := k8s.TokenExpiry(c.tokenFile)
+	if err != nil {
+		c.logger.Warn("could not read service account token expiry", slog.Any("error", err))
+	} else {
+		c.metrics.SetTokenExpiry(expiry)
+		if !expiry.IsZero() && c.now().After(expiry) {
+			c.logger.Error("service account token has expired; the kubelet is not refreshing it",
+				slog.String("token_file", c.tokenFile), slog.Time("expired", expiry))
+		}
+	}
+
+	err = k8s.CheckCredentials(ctx, c.client, c.namespace)
+	if errors.Is(err, k8s.ErrCredentialsRejected) {
+		c.metrics.SetCredentialsValid(false)
+		c.metrics.IncrementError(metricErrorCredentials)
+		c.logger.Error("api server rejected the service account token", slog.Any("error", err))
+		c.events.Add(eventError, "service account token rejected", "", err)
+		return
+	}
+	if err != nil {
+		// The API server could not be asked; that says nothing about the token.
+		c.logger.Warn("credential check failed", slog.Any("error", err))
+		return
+	}
+	c.metrics.SetCredentialsValid(true)
+}
+
+func (c *credentialChecker) run(ctx context.Context, interval time.Duration) {
+	c.check(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.check(ctx)
+	}
+}
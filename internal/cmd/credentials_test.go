@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestCredentialCheckerReportsRejectedToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000000}`))
+	if err := os.WriteFile(tokenFile, []byte("e30."+claims+".sig"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	client := fake.NewSimpleClientset()
+	var logs bytes.Buffer
+	metricsCollector := metrics.NewMetrics()
+	checker := &credentialChecker{
+		client:    client,
+		namespace: "shop",
+		tokenFile: tokenFile,
+		metrics:   metricsCollector,
+		events:    newEventLog(10),
+		logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+		now:       func() time.Time { return time.Unix(1700000100, 0) },
+	}
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		metricsCollector.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	checker.check(context.Background())
+	body := scrape()
+	for _, want := range []string{
+		"ghostwire_credentials_valid 1",
+		"ghostwire_serviceaccount_token_expiry_timestamp_seconds 1.7e+09",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if !strings.Contains(logs.String(), "service account token has expired") {
+		t.Fatalf("expected expired token to be logged, got %s", logs.String())
+	}
+
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewUnauthorized("token expired")
+	})
+	checker.check(context.Background())
+	body = scrape()
+	for _, want := range []string{
+		"ghostwire_credentials_valid 0",
+		`ghostwire_errors_total{type="credentials"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if events := checker.events.Events(); len(events) != 1 || events[0].Kind != eventError {
+		t.Fatalf("expected one error event, got %+v", events)
+	}
+}
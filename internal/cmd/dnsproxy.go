@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/dnsproxy"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/logging"
+)
+
+// DNSProxyCmd represents the ghostwire dnsproxy subcommand.
+var DNSProxyCmd = &cobra.Command{
+	Use:   "dnsproxy",
+	Short: "Run an in-pod DNS forwarder that resolves active services to preview IPs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		podName := os.Getenv("POD_NAME")
+		if podName == "" {
+			return fmt.Errorf("environment variable POD_NAME is required")
+		}
+		podNamespace := os.Getenv("POD_NAMESPACE")
+		if podNamespace == "" {
+			return fmt.Errorf("environment variable POD_NAMESPACE is required")
+		}
+
+		labelKey := viper.GetString("role-label-key")
+		activeValue := viper.GetString("role-active")
+		previewValue := viper.GetString("role-preview")
+
+		pollInterval := viper.GetDuration("poll-interval")
+
+		listenAddr := strings.TrimSpace(viper.GetString("dns-listen-addr"))
+		upstream := strings.TrimSpace(viper.GetString("dns-upstream"))
+		if upstream == "" {
+			var err error
+			upstream, err = dnsproxy.UpstreamFromResolvConf("/etc/resolv.conf")
+			if err != nil {
+				return fmt.Errorf("determine upstream resolver: %w", err)
+			}
+		}
+
+		dnatMapPath := viper.GetString("iptables-dnat-map")
+		entries, err := dnsproxy.LoadEntries(dnatMapPath, podNamespace, viper.GetString("dns-suffix"))
+		if err != nil {
+			return fmt.Errorf("load dns overrides: %w", err)
+		}
+
+		proxyLogger := logger.With(
+			slog.String("component", "dnsproxy"),
+			slog.String("pod_name", podName),
+			slog.String("namespace", podNamespace),
+			slog.String("listen_addr", listenAddr),
+			slog.String("upstream", upstream),
+		)
+
+		proxy, err := dnsproxy.New(dnsproxy.Config{
+			ListenAddr:   listenAddr,
+			Upstream:     upstream,
+			ActiveValue:  activeValue,
+			PreviewValue: previewValue,
+			Entries:      entries,
+			Logger:       proxyLogger,
+		})
+		if err != nil {
+			return fmt.Errorf("create dns proxy: %w", err)
+		}
+
+		clientset, err := k8s.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("create kubernetes client: %w", err)
+		}
+
+		poller, err := k8s.NewPoller(k8s.PollerConfig{
+			LabelReader:       k8s.NewPodLabelReader(clientset, podNamespace, podName),
+			LabelKey:          labelKey,
+			ActiveValue:       activeValue,
+			PreviewValue:      previewValue,
+			PollInterval:      pollInterval,
+			Logger:            proxyLogger,
+			TransitionHandler: proxy,
+		})
+		if err != nil {
+			return fmt.Errorf("create poller: %w", err)
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		pollDone := make(chan struct{})
+		go func() {
+			defer close(pollDone)
+			poller.Run(ctx)
+		}()
+
+		proxyLogger.Info("dns proxy started", slog.Int("override_names", len(entries)))
+
+		serveErr := proxy.ListenAndServe(ctx)
+		cancel()
+		<-pollDone
+
+		if serveErr != nil {
+			proxyLogger.Error("dns proxy stopped with error", slog.Any("error", serveErr))
+			return serveErr
+		}
+
+		proxyLogger.Info("dns proxy shutdown complete")
+		return nil
+	},
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event kinds kept in the watcher's event log.
+const (
+	eventTransition  = "transition"
+	eventRepair      = "repair"
+	eventResync      = "resync"
+	eventReconfigure = "reconfigure"
+	eventExpire      = "expire"
+	eventCircuit     = "circuit"
+	eventConntrack   = "conntrack"
+	eventError       = "error"
+)
+
+// watcherEvent is one significant thing the watcher did or failed to do.
+type watcherEvent struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Role    string    `json:"role,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// eventLog keeps the latest events in a fixed-size ring buffer so recent
+// history survives log rotation. A nil *eventLog records nothing.
+type eventLog struct {
+	mu     sync.Mutex
+	events []watcherEvent
+	next   int
+	full   bool
+}
+
+func newEventLog(size int) *eventLog {
+	if size <= 0 {
+		return nil
+	}
+	return &eventLog{events: make([]watcherEvent, size)}
+}
+
+// Add records an event of kind, stamping it with the current time. A non-nil
+// err is kept as the event's error.
+func (l *eventLog) Add(kind, message, role string, err error) {
+	if l == nil {
+		return
+	}
+
+	event := watcherEvent{Time: time.Now(), Kind: kind, Message: message, Role: role}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Events returns the buffered events, oldest first.
+func (l *eventLog) Events() []watcherEvent {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]watcherEvent{}, l.events[:l.next]...)
+	}
+	return append(append([]watcherEvent{}, l.events[l.next:]...), l.events[:l.next]...)
+}
+
+type eventsResponse struct {
+	Events []watcherEvent `json:"events"`
+}
+
+// ServeHTTP serves GET /events with the buffered events as JSON.
+func (l *eventLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(eventsResponse{Events: l.Events()})
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestEventLog(t *testing.T) {
+	t.Parallel()
+
+	if newEventLog(0) != nil {
+		t.Fatal("expected a zero-size event log to be disabled")
+	}
+
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     &mockExecutor{},
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		events:       newEventLog(2),
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+	ctx := context.Background()
+	for _, step := range [][2]string{{"", "active"}, {"active", "preview"}, {"preview", "preview"}, {"preview", "active"}} {
+		if err := jm.OnTransition(ctx, step[0], step[1]); err != nil {
+			t.Fatalf("OnTransition(%q, %q) returned error: %v", step[0], step[1], err)
+		}
+	}
+
+	handler := buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), nil, nil, nil, jm.events)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body eventsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+	if len(body.Events) != 2 {
+		t.Fatalf("expected the buffer to keep the last 2 events, got %+v", body.Events)
+	}
+	if body.Events[0].Role != "preview" || body.Events[1].Role != "active" || body.Events[1].Kind != eventTransition {
+		t.Fatalf("expected the latest transitions oldest first, got %+v", body.Events)
+	}
+}
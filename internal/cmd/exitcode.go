@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+)
+
+// Exit codes returned by the ghostwire binary, so init restart policies and
+// scripts can branch on the class of failure.
+const (
+	ExitOK         = 0
+	ExitFailure    = 1
+	ExitConfig     = 2
+	ExitKubernetes = 3
+	ExitIptables   = 4
+	ExitTimeout    = 5
+	ExitMismatch   = 6
+)
+
+// ExitError attaches an exit code to an error returned from a command.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes the underlying error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode maps an error returned from Execute to the process exit code. A
+// deadline anywhere in the chain is reported as ExitTimeout, since the class
+// of the operation that ran out of time matters less than the timeout itself.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ExitTimeout
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
+
+// configError marks invalid configuration: flags, environment or files.
+func configError(err error) error {
+	return withExitCode(ExitConfig, err)
+}
+
+// kubernetesError marks failures talking to the API server, including
+// discovery and RBAC denials.
+func kubernetesError(err error) error {
+	return withExitCode(ExitKubernetes, err)
+}
+
+// iptablesError marks failures applying rules.
+func iptablesError(err error) error {
+	return withExitCode(ExitIptables, err)
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *ExitError
+	if errors.As(err, &existing) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitOK},
+		{name: "plain error", err: errors.New("boom"), want: ExitFailure},
+		{name: "config", err: configError(errors.New("bad flag")), want: ExitConfig},
+		{name: "kubernetes wrapped", err: fmt.Errorf("init: %w", kubernetesError(errors.New("forbidden"))), want: ExitKubernetes},
+		{name: "iptables", err: iptablesError(errors.New("exit status 4")), want: ExitIptables},
+		{name: "deadline", err: fmt.Errorf("wait: %w", context.DeadlineExceeded), want: ExitTimeout},
+		{name: "deadline inside classified error", err: kubernetesError(fmt.Errorf("list services: %w", context.DeadlineExceeded)), want: ExitTimeout},
+		{name: "inner classification wins", err: configError(iptablesError(errors.New("boom"))), want: ExitIptables},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := ExitCode(tc.err); got != tc.want {
+				t.Fatalf("ExitCode() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
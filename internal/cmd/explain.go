@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/output"
+)
+
+// explainResult is the result printed by `ghostwire explain`.
+type explainResult struct {
+	discovery.Explanation
+	// Rules are the DNAT rules Setup would install for the service's mappings.
+	Rules []string `json:"rules"`
+}
+
+// ExplainCmd represents the ghostwire explain subcommand.
+var ExplainCmd = &cobra.Command{
+	Use:   "explain <service>",
+	Short: "Show how discovery and rule building treat one service",
+	Long: "Explain runs discovery for the namespace with the current settings and reports, for one " +
+		"active service, the derived preview service and whether it exists, the ports that map, " +
+		"what was skipped and why, and the exact DNAT rules init would install. Nothing is executed.",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		clientset, err := discovery.NewInClusterClient()
+		if err != nil {
+			return kubernetesError(fmt.Errorf("create kubernetes client: %w", err))
+		}
+
+		namespace := initNamespace()
+		if err := applyNamespaceOverrides(namespace, logger); err != nil {
+			return err
+		}
+		discoveryCfg := discoveryConfig(clientset, namespace, nil)
+		if viper.GetBool("mapping-overrides") {
+			overrides, err := loadMappingOverrides(ctx, namespace)
+			if err != nil {
+				return kubernetesError(fmt.Errorf("load ghostwiremapping overrides: %w", err))
+			}
+			discoveryCfg.Overrides = overrides.overrides
+		}
+
+		explanation, err := discovery.Explain(ctx, discoveryCfg, args[0])
+		if err != nil {
+			return kubernetesError(err)
+		}
+
+		cfg, err := iptablesConfig(logger)
+		if err != nil {
+			return err
+		}
+		rules, err := explainRules(ctx, cfg, explanation.Mappings, logger)
+		if err != nil {
+			return iptablesError(err)
+		}
+
+		return output.Write(cmd.OutOrStdout(), format, explainResult{Explanation: explanation, Rules: rules})
+	},
+}
+
+// explainRules records Setup for mappings and returns the nat-table DNAT
+// rules, IPv6 ones included.
+func explainRules(ctx context.Context, cfg iptables.Config, mappings []discovery.ServiceMapping, logger *slog.Logger) ([]string, error) {
+	rules := make([]string, 0)
+	if len(mappings) == 0 {
+		return rules, nil
+	}
+
+	recorder := iptables.NewRestoreRecorder()
+	cfg.DnatMapPath = ""
+	if err := iptables.SetupWithExecutor(ctx, recorder, cfg, mappings, slog.New(slog.DiscardHandler)); err != nil {
+		logger.Error("recording iptables rules failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	for _, binary := range []string{"iptables", "ip6tables"} {
+		for _, rule := range recorder.Rules(binary, "nat") {
+			if strings.Contains(rule, "-j DNAT") {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// WriteTable prints the pairing summary followed by the mappings, notes and
+// rules.
+func (r explainResult) WriteTable(w io.Writer) error {
+	if !r.Found {
+		_, err := fmt.Fprintf(w, "service %s/%s was not found by discovery\n", r.Namespace, r.Service)
+		return err
+	}
+
+	preview := r.PreviewService
+	if preview == "" {
+		preview = "-"
+	}
+	fmt.Fprintf(w, "service:         %s/%s\n", r.Namespace, r.Service)
+	fmt.Fprintf(w, "preview service: %s (exists: %t)\n", preview, r.PreviewExists)
+
+	if len(r.Mappings) > 0 {
+		fmt.Fprintln(w, "\nmappings:")
+		table := output.NewTable(w, "port", "protocol", "active", "preview", "preview port")
+		for _, m := range r.Mappings {
+			table.Row(strconv.Itoa(int(m.Port)), string(m.Protocol), m.ActiveClusterIP, m.PreviewClusterIP, strconv.Itoa(int(m.TargetPort())))
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(w, "\nno mappings: traffic to this service is never redirected")
+	}
+
+	if len(r.Notes) > 0 {
+		fmt.Fprintln(w, "\nnotes:")
+		for _, note := range r.Notes {
+			fmt.Fprintf(w, "  - %s\n", note)
+		}
+	}
+
+	if len(r.Rules) > 0 {
+		fmt.Fprintln(w, "\nrules:")
+		for _, rule := range r.Rules {
+			fmt.Fprintf(w, "  %s\n", rule)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/logging"
+)
+
+// Rule syntaxes export-format selects.
+const (
+	exportFormatIptables = "iptables"
+	exportFormatNFT      = "nft"
+)
+
+// ExportRulesCmd represents the ghostwire export-rules subcommand.
+var ExportRulesCmd = &cobra.Command{
+	Use:   "export-rules",
+	Short: "Print the desired ruleset in iptables-save or nft format",
+	Long: "Export-rules builds the chain, exclusions, DNAT rules and jump exactly as init and the " +
+		"watcher would, without executing iptables, and prints them in iptables-save format (or " +
+		"nft -f syntax with --format nft) for review, GitOps archival or application by other " +
+		"tooling. Mappings are discovered like init does, or read from the DNAT map with " +
+		"--from-dnat-map.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		if err := applyNamespaceOverrides(initNamespace(), logger); err != nil {
+			return err
+		}
+
+		mappings, err := exportMappings(ctx, logger)
+		if err != nil {
+			return err
+		}
+
+		format := strings.TrimSpace(viper.GetString("export-format"))
+		if format != exportFormatIptables && format != exportFormatNFT {
+			return configError(fmt.Errorf("export-format %q is not %s or %s", format, exportFormatIptables, exportFormatNFT))
+		}
+
+		cfg, err := iptablesConfig(logger)
+		if err != nil {
+			return err
+		}
+		_, hooks, err := ruleNames()
+		if err != nil {
+			return err
+		}
+
+		recorder, err := iptables.ExportRules(ctx, cfg, mappings, hooks, logger)
+		if err != nil {
+			return iptablesError(err)
+		}
+
+		path := strings.TrimSpace(viper.GetString("export-file"))
+		out := cmd.OutOrStdout()
+		if format == exportFormatNFT {
+			if path != "" {
+				return recorder.WriteNFTFile(path)
+			}
+			data, err := recorder.RenderNFT()
+			if err != nil {
+				return err
+			}
+			_, err = out.Write(data)
+			return err
+		}
+
+		if path != "" {
+			return recorder.WriteFiles(path)
+		}
+		for _, binary := range []string{"iptables", "ip6tables"} {
+			if data := recorder.Render(binary); data != nil {
+				if _, err := out.Write(data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// exportMappings returns the mappings to export: the current DNAT map with
+// --from-dnat-map, otherwise a fresh discovery.
+func exportMappings(ctx context.Context, logger *slog.Logger) ([]discovery.ServiceMapping, error) {
+	if viper.GetBool("from-dnat-map") {
+		path := viper.GetString("iptables-dnat-map")
+		mappings, err := loadDNATMap(path)
+		if err != nil {
+			return nil, configError(fmt.Errorf("read dnat map %s: %w", path, err))
+		}
+		return mappings, nil
+	}
+
+	return resolveMappings(ctx, initNamespace(), logger)
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+)
+
+// settingAnnotation marks a flag with the viper key it feeds. Flags are bound
+// when a command runs rather than at startup, because several commands define
+// the same key and viper only keeps one binding per key.
+const settingAnnotation = "ghostwire.dev/setting"
+
+// setting is one configuration key and the help text of its --<key> flag.
+// The flag is named after the key, so GW_<KEY> and --<key> set the same
+// value; its default comes from config.Defaults.
+type setting struct {
+	key   string
+	usage string
+}
+
+// discoverySettings control which Services are paired and how.
+var discoverySettings = []setting{
+	{"namespace", "Namespace for service discovery (falls back to POD_NAMESPACE)"},
+	{"config-name", "Name of a GhostwireConfig in the pod's namespace to read settings from"},
+	{"svc-preview-pattern", "Pattern that derives a preview Service name from an active one"},
+	{"active-suffix", "Suffix used to detect active Services when pairing"},
+	{"preview-suffix", "Suffix of preview Service names"},
+	{"service-selector", "Label selector limiting the Services considered for pairing"},
+	{"service-events", "Record a PreviewPairing Event on each paired active Service"},
+	{"publish-mappings", "Also publish the mappings to a per-pod ConfigMap"},
+	{"exclude-service-selector", "Label selector of Services never paired"},
+	{"pair-by", "How active and preview Services are paired: name or release"},
+	{"release-label", "Label holding the Helm release name when pairing by release"},
+	{"release-pattern", "Pattern that derives the preview release from an active one"},
+	{"stateful-ordinals", "Also pair per-pod and headless StatefulSet Services by ordinal"},
+	{"record-target-ports", "Record each pair's targetPorts in the published mappings"},
+	{"mapping-overrides", "Merge GhostwireMapping overrides over convention-based discovery"},
+	{"mappings-configmap", "ConfigMap of controller-published mappings to read instead of discovering"},
+	{"mapping-sources", "Comma-separated mapping sources (services, configmap, file), merged in order with later ones winning on collisions (default: from mappings-configmap and mappings-file)"},
+	{"mappings-file-precedence", "Who wins when a static mapping and a discovered one collide: file or discovery"},
+	{"api-retry-attempts", "Attempts for Kubernetes API calls before giving up"},
+	{"api-retry-initial-backoff", "First backoff between Kubernetes API retries"},
+	{"api-retry-max-backoff", "Largest backoff between Kubernetes API retries"},
+	{"init-timeout", "Deadline for init and each resync (default: 30s plus the API retry budget)"},
+	{"strict-parsing", "Fail on malformed configuration and DNAT map entries instead of skipping them"},
+	{"rbac-check", "Check the API access the current settings need at startup"},
+}
+
+// ruleSettings shape the iptables rules.
+var ruleSettings = []setting{
+	{"nat-chain", "NAT chain holding the DNAT rules"},
+	{"jump-hook", "Comma-separated built-in chains that jump to the NAT chain"},
+	{"jump-position", "Rule number for the jump, or after:<chain> (default: top of the hook)"},
+	{"exclude-cidrs", "Comma-separated CIDRs never redirected"},
+	{"exclude-ipset", "ipset of destinations never redirected"},
+	{"notrack-cidrs", "Comma-separated CIDRs exempted from connection tracking"},
+	{"exclude-cgroups", "Comma-separated cgroup v2 paths whose traffic is never redirected"},
+	{"ipv6", "Also add ip6tables rules"},
+	{"dnat-protocols", "Comma-separated protocols (TCP, UDP, SCTP) that get DNAT rules (default: all)"},
+	{"whole-service-dnat", "One address-only DNAT rule per Service when active and preview ports match"},
+	{"multiport", "Collapse ports sharing an IP pair and protocol into multiport rules"},
+	{"endpoint-dnat", "DNAT straight to ready preview pod IPs from EndpointSlices instead of the preview ClusterIP"},
+	{"endpoint-balance", "How endpoint-dnat spreads connections across preview pods: random or nth"},
+	{"preview-percent", "Percentage of new connections routed to preview while routing is on (0-100)"},
+	{"preview-percent-from", "Follow the ghostwire.dev/preview-percent annotation of the pod or configmap/<name> live (empty disables it)"},
+	{"sticky-window", "Keep each source on the side its last connection took for this long (0: decide per connection)"},
+	{"hairpin-masquerade", "Masquerade redirected connections so hairpin flows get replies"},
+	{"hairpin-mark", "Connmark bit used by --hairpin-masquerade"},
+	{"debug-log", "LOG or NFLOG: add a rate-limited logging rule to the DNAT chain"},
+	{"debug-log-scope", "Packets that are logged: chain, unmatched or both"},
+	{"debug-log-prefix", "Log prefix for debug logging"},
+	{"debug-log-rate", "Rate limit for debug logging"},
+	{"debug-log-nflog-group", "Netlink group used with --debug-log=NFLOG"},
+	{"ct-timeout-policy", "Conntrack timeout policy attached to UDP flows towards active services"},
+	{"ct-udp-timeout", "Create the conntrack timeout policy with this UDP timeout in seconds"},
+	{"dnat-map-hmac-key-file", "File holding the HMAC key that signs the DNAT map"},
+	{"ready-marker", "Handshake file written once the rules are installed (empty disables it)"},
+	{"claim-file", "File on the shared volume locked by the ghostwire instance managing the pod's rules (empty disables it)"},
+	{"on-conflict", "What to do when another ghostwire instance manages the pod's rules: refuse or warn"},
+	{"rule-backend", "Backend that programs the DNAT chain and jumps (default: iptables)"},
+}
+
+// dnsSettings drive the optional hosts-file routing mode.
+var dnsSettings = []setting{
+	{"dns-mode", "Also pin active Service names to preview ClusterIPs in the hosts file"},
+	{"dns-suffix", "Cluster DNS suffix used to build fully qualified names"},
+	{"dns-hosts-fragment", "Hosts fragment written by init"},
+	{"dns-hosts-path", "Hosts file the watcher rewrites"},
+}
+
+// watcherSettings configure the polling loop and its HTTP and admin APIs.
+var watcherSettings = []setting{
+	{"role-label-key", "Pod label holding the role"},
+	{"role-active", "Role label value that disables preview routing"},
+	{"role-preview", "Role label value that enables preview routing"},
+	{"role-actions", "Comma-separated role=action pairs for further role values (actions: route, bypass, mirror)"},
+	{"mirror-gateway", "Gateway that receives copies of traffic to active Services while a mirror role is held"},
+	{"route-schedule", "Semicolon-separated cron expressions of the minutes preview routing is allowed (empty: always)"},
+	{"route-schedule-timezone", "IANA time zone the route-schedule is evaluated in"},
+	{"max-preview-duration", "Remove the jump once it has been active this long (0s disables it)"},
+	{"reverse-mode", "While bypassing, DNAT preview ClusterIPs back to the active Services"},
+	{"transition-handlers", "Comma-separated handlers run in order on each role change: routing, webhook, hooks"},
+	{"transition-webhook-url", "URL the webhook handler posts role changes to"},
+	{"transition-webhook-timeout", "How long the webhook handler waits for a response"},
+	{"on-activate-cmd", "Shell command run after preview routing is switched on"},
+	{"on-deactivate-cmd", "Shell command run after preview routing is switched off"},
+	{"hook-timeout", "How long a hook command may run before it is killed"},
+	{"poll-interval", "How often the pod's labels are polled"},
+	{"ready-timeout", "How long the watcher waits for the ready marker"},
+	{"readiness-signals", "Comma-separated conditions /healthz waits for: chain, labels, jump"},
+	{"drift-check-interval", "How often DNAT map IPs are compared with Service ClusterIPs (0s disables)"},
+	{"preview-health-check", "Hold the jump off while a preview Service has no ready endpoints"},
+	{"drift-repair", "Rewrite the rules of stale mappings"},
+	{"rule-drift-interval", "How often the DNAT chain is compared with the DNAT map and differences reported (0s disables it)"},
+	{"reconcile-interval", "How often the chain, its rules and the jump are re-checked and repaired (0s disables it)"},
+	{"credential-check-interval", "How often the ServiceAccount token is checked (0s disables)"},
+	{"conntrack-check-interval", "How often conntrack table usage is read (0s disables it)"},
+	{"conntrack-warn-percent", "Conntrack table fill level, in percent, that triggers a warning"},
+	{"mapping-info-limit", "Maximum ghostwire_mapping_info series (0 disables them)"},
+	{"http-addr", "TCP address of the health, metrics and status server (empty disables it)"},
+	{"http-socket", "Unix socket, e.g. on the shared volume, also serving the health, metrics and status endpoints"},
+	{"events-buffer", "Number of recent events kept for GET /events (0 disables it)"},
+	{"metrics-openmetrics", "Serve /metrics in the OpenMetrics format when asked"},
+	{"metrics-compression", "Gzip /metrics responses when asked"},
+	{"status-annotations", "Patch routing status onto the pod"},
+	{"status-resource", "Maintain a GhostwireStatus object named after the pod"},
+	{"privsep", "Drop capabilities and run rule changes through a re-executed helper"},
+	{"helper-socket", "Unix socket of a rule-helper daemon that applies rule changes"},
+	{"role-token-file", "Bearer token file guarding POST /role"},
+	{"http-auth", "Bearer authentication for the read endpoints: token or tokenreview"},
+	{"http-token-file", "Token file for --http-auth=token"},
+	{"http-token-audiences", "Comma-separated audiences for --http-auth=tokenreview"},
+	{"admin-grpc-addr", "Address of the gRPC admin API"},
+	{"admin-http-addr", "Mutual-TLS address serving POST /role and /admin/*"},
+	{"admin-tls-cert", "Admin API server certificate"},
+	{"admin-tls-key", "Admin API server key"},
+	{"admin-tls-client-ca", "CA that must sign admin API client certificates"},
+}
+
+// dnsProxySettings configure the dns-proxy command.
+var dnsProxySettings = []setting{
+	{"dns-listen-addr", "Address the DNS proxy listens on"},
+	{"dns-upstream", "Upstream resolver (default: first non-loopback /etc/resolv.conf nameserver)"},
+}
+
+// controllerSettings configure the controller command.
+var controllerSettings = []setting{
+	{"controller-namespaces", "Comma-separated namespaces to manage"},
+	{"controller-namespace-selector", "Label selector of namespaces to manage when none are listed"},
+	{"controller-interval", "How often discovery is re-run"},
+	{"controller-workers", "How many namespaces are synced at once"},
+	{"controller-namespace-timeout", "Time limit for syncing one namespace (default: controller-interval)"},
+	{"controller-metrics-addr", "Address serving the controller's /metrics and /healthz (empty disables it)"},
+}
+
+// nodeSettings configure the node command.
+var nodeSettings = []setting{
+	{"node-name", "Node whose annotated pods are managed (default: NODE_NAME)"},
+	{"node-interval", "How often pods on the node are reconciled"},
+	{"node-proc-root", "The host's /proc, used to find pod network namespaces"},
+}
+
+// injectorSettings configure the injector command.
+var injectorSettings = []setting{
+	{"injector-listen-addr", "HTTPS address of the admission webhooks"},
+	{"injector-tls-cert", "Serving certificate of the admission webhooks"},
+	{"injector-tls-key", "Serving key of the admission webhooks"},
+}
+
+// tlsSettings configure the TLS listeners of the watcher and the injector.
+var tlsSettings = []setting{
+	{"tls-reload-interval", "How often serving certificates are checked for replacement (0 disables reloading)"},
+}
+
+// registeredSettings indexes every setting passed to registerSettings.
+var registeredSettings = map[string]setting{}
+
+// settingDefaults types and fills in the default of every flag.
+var settingDefaults = config.Defaults()
+
+// registerSettings defines the flag of each setting on cmds.
+func registerSettings(settings []setting, cmds ...*cobra.Command) {
+	for _, s := range settings {
+		registeredSettings[s.key] = s
+		for _, cmd := range cmds {
+			addFlag(cmd.Flags(), s.key, s.key, s.usage)
+		}
+	}
+}
+
+// addSettingFlags defines flags on cmd for already registered keys.
+func addSettingFlags(cmd *cobra.Command, keys ...string) {
+	for _, key := range keys {
+		s, ok := registeredSettings[key]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown setting %q for %s flags\n", key, cmd.Name())
+			os.Exit(1)
+		}
+		addFlag(cmd.Flags(), s.key, s.key, s.usage)
+	}
+}
+
+// addFlag defines flag name for setting key, typed and defaulted from the
+// key's entry in config.Defaults.
+func addFlag(flags *pflag.FlagSet, name, key, usage string) {
+	switch value := settingDefaults[key].(type) {
+	case string:
+		flags.String(name, value, usage)
+	case bool:
+		flags.Bool(name, value, usage)
+	case int:
+		flags.Int(name, value, usage)
+	case time.Duration:
+		flags.Duration(name, value, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported default %T for setting %s\n", value, key)
+		os.Exit(1)
+	}
+	markSetting(flags, name, key)
+}
+
+// markSetting records that flag name feeds viper key.
+func markSetting(flags *pflag.FlagSet, name, key string) {
+	if err := flags.SetAnnotation(name, settingAnnotation, []string{key}); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to mark %s flag: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// bindSettingFlags binds the marked flags of the running command, including
+// inherited persistent flags, to their viper keys.
+func bindSettingFlags(cmd *cobra.Command) error {
+	var bindErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		keys := flag.Annotations[settingAnnotation]
+		if bindErr != nil || len(keys) == 0 {
+			return
+		}
+		if err := viper.BindPFlag(keys[0], flag); err != nil {
+			bindErr = configError(fmt.Errorf("bind %s flag: %w", flag.Name, err))
+		}
+	})
+	return bindErr
+}
+
+// durationLimits bounds duration settings whose extremes make no sense, such
+// as polling the API server in a tight loop.
+var durationLimits = map[string]struct{ min, max time.Duration }{
+	"poll-interval": {time.Second, time.Hour},
+}
+
+// validateDurationSettings checks the duration settings the running command
+// reads, wherever they were set, so a bad GW_POLL_INTERVAL fails at startup
+// instead of deep inside the command.
+func validateDurationSettings(cmd *cobra.Command) error {
+	var validateErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		keys := flag.Annotations[settingAnnotation]
+		if validateErr != nil || len(keys) == 0 || flag.Value.Type() != "duration" {
+			return
+		}
+		key := keys[0]
+		raw := viper.Get(key)
+		value, err := cast.ToDurationE(raw)
+		if err != nil {
+			validateErr = configError(fmt.Errorf("%s: %q is not a duration; use a value such as 30s, 5m or 1h30m", key, fmt.Sprint(raw)))
+			return
+		}
+		if value < 0 {
+			validateErr = configError(fmt.Errorf("%s: %s is negative", key, value))
+			return
+		}
+		if limits, ok := durationLimits[key]; ok {
+			if value < limits.min {
+				validateErr = configError(fmt.Errorf("%s: %s is below the minimum of %s", key, value, limits.min))
+			} else if value > limits.max {
+				validateErr = configError(fmt.Errorf("%s: %s is above the maximum of %s", key, value, limits.max))
+			}
+		}
+	})
+	return validateErr
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestSettingFlagsOverrideDefaults(t *testing.T) {
+	for key := range registeredSettings {
+		found := false
+		for _, cmd := range rootCmd.Commands() {
+			if flag := cmd.Flags().Lookup(key); flag != nil && len(flag.Annotations[settingAnnotation]) > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("setting %s has no flag", key)
+		}
+	}
+
+	t.Cleanup(func() {
+		// Point the keys back at the unchanged watcher flags.
+		if err := bindSettingFlags(WatcherCmd); err != nil {
+			t.Fatalf("rebind watcher flags: %v", err)
+		}
+	})
+
+	cmd := &cobra.Command{Use: "test"}
+	addSettingFlags(cmd, "poll-interval", "ipv6", "mapping-info-limit")
+	if err := cmd.ParseFlags([]string{"--poll-interval=7s", "--ipv6"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	if err := bindSettingFlags(cmd); err != nil {
+		t.Fatalf("bind flags: %v", err)
+	}
+
+	if got := viper.GetString("poll-interval"); got != "7s" {
+		t.Errorf("poll-interval = %q, want 7s", got)
+	}
+	if !viper.GetBool("ipv6") {
+		t.Error("ipv6 = false, want true")
+	}
+	if got := viper.GetInt("mapping-info-limit"); got != 100 {
+		t.Errorf("mapping-info-limit = %d, want the default 100", got)
+	}
+}
+
+func TestValidateDurationSettings(t *testing.T) {
+	t.Cleanup(func() { viper.Set("poll-interval", nil) })
+
+	cmd := &cobra.Command{Use: "test"}
+	addSettingFlags(cmd, "poll-interval")
+
+	tests := []struct {
+		value   any
+		wantErr string
+	}{
+		{value: "30s"},
+		{value: 5 * time.Minute},
+		{value: "soon", wantErr: "not a duration"},
+		{value: "-5s", wantErr: "negative"},
+		{value: "500ms", wantErr: "below the minimum of 1s"},
+		{value: "2h", wantErr: "above the maximum of 1h0m0s"},
+	}
+	for _, tt := range tests {
+		viper.Set("poll-interval", tt.value)
+		err := validateDurationSettings(cmd)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tt.value, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%v: error = %v, want it to contain %q", tt.value, err, tt.wantErr)
+		}
+		if ExitCode(err) != ExitConfig {
+			t.Errorf("%v: exit code = %d, want %d", tt.value, ExitCode(err), ExitConfig)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// loadGhostwireConfig fetches the GhostwireConfig named by GW_CONFIG_NAME and
+// applies its set fields over the other configuration sources; readers go
+// through configValue and configString to see them. The returned source lets
+// long-running commands keep watching the object; nil means the feature is off.
+func loadGhostwireConfig(ctx context.Context, namespace string, logger *slog.Logger) (*k8s.ConfigSource, error) {
+	name := strings.TrimSpace(viper.GetString("config-name"))
+	if name == "" {
+		return nil, nil
+	}
+
+	client, err := k8s.NewInClusterGhostwireClient()
+	if err != nil {
+		return nil, err
+	}
+
+	source := k8s.NewConfigSource(client, namespace, name, logger)
+	cfg, err := source.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applyGhostwireConfigSpec(cfg.Spec)
+	logger.Info("applied ghostwireconfig",
+		slog.String("namespace", namespace),
+		slog.String("name", name),
+		slog.String("resource_version", cfg.ResourceVersion),
+	)
+
+	return source, nil
+}
+
+// ghostwireSettings holds the GhostwireConfig fields in force. The watch
+// goroutine replaces them while resync and the HTTP handlers read them, so
+// they sit behind a mutex instead of being written into viper, which is not
+// safe for concurrent use.
+var ghostwireSettings struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// applyGhostwireConfigSpec replaces the GhostwireConfig settings with spec's
+// set fields and returns the keys whose value changed. Set fields win over
+// flags and environment variables; a field cleared in the object drops out,
+// so its key falls back to the flag, environment or default value again.
+func applyGhostwireConfigSpec(spec v1alpha1.GhostwireConfigSpec) map[string]bool {
+	values := make(map[string]any)
+	for key, value := range map[string]string{
+		"svc-preview-pattern": spec.SvcPreviewPattern,
+		"active-suffix":       spec.ActiveSuffix,
+		"preview-suffix":      spec.PreviewSuffix,
+		"nat-chain":           spec.NATChain,
+		"jump-hook":           spec.JumpHook,
+		"role-label-key":      spec.RoleLabelKey,
+		"role-active":         spec.RoleActive,
+		"role-preview":        spec.RolePreview,
+	} {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	if len(spec.ExcludeCIDRs) > 0 {
+		values["exclude-cidrs"] = slices.Clone(spec.ExcludeCIDRs)
+	}
+
+	ghostwireSettings.mu.Lock()
+	defer ghostwireSettings.mu.Unlock()
+
+	changed := make(map[string]bool)
+	for key, value := range values {
+		if !reflect.DeepEqual(ghostwireSettings.values[key], value) {
+			changed[key] = true
+		}
+	}
+	for key := range ghostwireSettings.values {
+		if _, ok := values[key]; !ok {
+			changed[key] = true
+		}
+	}
+	ghostwireSettings.values = values
+	return changed
+}
+
+// ruleSettingKeys are the GhostwireConfig keys that change the rules inside the
+// chain rather than where it is jumped to.
+var ruleSettingKeys = []string{"svc-preview-pattern", "active-suffix", "preview-suffix", "exclude-cidrs"}
+
+// rulesChanged reports whether changed, as returned by
+// applyGhostwireConfigSpec, holds a key that needs the chain rebuilt.
+func rulesChanged(changed map[string]bool) bool {
+	for _, key := range ruleSettingKeys {
+		if changed[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// configValue returns key's GhostwireConfig value when the object sets it and
+// viper's otherwise.
+func configValue(key string) any {
+	ghostwireSettings.mu.RLock()
+	value, ok := ghostwireSettings.values[key]
+	ghostwireSettings.mu.RUnlock()
+	if ok {
+		return value
+	}
+	return viper.Get(key)
+}
+
+// configString is configValue for string keys.
+func configString(key string) string {
+	return cast.ToString(configValue(key))
+}
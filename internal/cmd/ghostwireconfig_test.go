@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+func TestApplyGhostwireConfigSpec(t *testing.T) {
+	t.Cleanup(func() { applyGhostwireConfigSpec(v1alpha1.GhostwireConfigSpec{}) })
+
+	changed := applyGhostwireConfigSpec(v1alpha1.GhostwireConfigSpec{
+		NATChain:     "GW_DNAT",
+		RolePreview:  "green",
+		ExcludeCIDRs: []string{"10.0.0.0/8"},
+	})
+	if len(changed) != 3 || !changed["nat-chain"] || !changed["role-preview"] || !changed["exclude-cidrs"] {
+		t.Fatalf("unexpected changed keys %v", changed)
+	}
+	if !rulesChanged(changed) {
+		t.Fatal("expected changed exclusions to need a rebuild")
+	}
+	if got := configString("nat-chain"); got != "GW_DNAT" {
+		t.Fatalf("expected nat-chain from the object, got %q", got)
+	}
+	if got, ok := configValue("exclude-cidrs").([]string); !ok || !slices.Equal(got, []string{"10.0.0.0/8"}) {
+		t.Fatalf("expected exclude-cidrs from the object, got %v", configValue("exclude-cidrs"))
+	}
+
+	// Clearing fields hands the keys back to the other configuration sources.
+	changed = applyGhostwireConfigSpec(v1alpha1.GhostwireConfigSpec{RolePreview: "green"})
+	if len(changed) != 2 || !changed["nat-chain"] || !changed["exclude-cidrs"] {
+		t.Fatalf("expected cleared keys reported as changed, got %v", changed)
+	}
+	if got, want := configString("nat-chain"), viper.GetString("nat-chain"); got != want {
+		t.Fatalf("expected cleared nat-chain to fall back to %q, got %q", want, got)
+	}
+	if got := configString("role-preview"); got != "green" {
+		t.Fatalf("expected role-preview kept, got %q", got)
+	}
+
+	if changed := applyGhostwireConfigSpec(v1alpha1.GhostwireConfigSpec{RolePreview: "green"}); len(changed) != 0 || rulesChanged(changed) {
+		t.Fatalf("expected no changes for an identical spec, got %v", changed)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// routingHandler is the handler that applies role actions. It must be in
+// every chain: without it the watcher would never route.
+const routingHandler = "routing"
+
+// handlerEnv carries what transition handler factories may need.
+type handlerEnv struct {
+	jm        *jumpManager
+	podName   string
+	namespace string
+	logger    *slog.Logger
+}
+
+// transitionHandlers registers the handlers transition-handlers may list, by
+// name.
+var transitionHandlers = map[string]func(env handlerEnv) (k8s.TransitionHandler, error){
+	routingHandler: func(env handlerEnv) (k8s.TransitionHandler, error) {
+		return env.jm, nil
+	},
+	"webhook":    newWebhookHandler,
+	hooksHandler: newHookHandler,
+}
+
+// buildTransitionHandler chains the handlers named by transition-handlers, in
+// order, into the handler the poller calls. The hooks handler is appended when
+// a hook command is set and the list does not place it.
+func buildTransitionHandler(env handlerEnv, observer k8s.HandlerObserver) (k8s.TransitionHandler, error) {
+	names, err := parseHandlerNames(viper.Get("transition-handlers"))
+	if err != nil {
+		return nil, err
+	}
+	if hooksConfigured() && !slices.Contains(names, hooksHandler) {
+		names = append(names, hooksHandler)
+	}
+
+	handlers := make([]k8s.NamedHandler, 0, len(names))
+	for _, name := range names {
+		handler, err := transitionHandlers[name](env)
+		if err != nil {
+			return nil, fmt.Errorf("transition handler %s: %w", name, err)
+		}
+		handlers = append(handlers, k8s.NamedHandler{Name: name, Handler: handler})
+	}
+	return k8s.NewCompositeHandler(env.logger, observer, handlers...), nil
+}
+
+// parseHandlerNames checks that every name is registered, listed once, and
+// that the routing handler is among them.
+func parseHandlerNames(raw any) ([]string, error) {
+	entries, err := config.StringList("transition-handlers", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for i, name := range entries {
+		if name == "" {
+			continue
+		}
+		var problem error
+		if _, ok := transitionHandlers[name]; !ok {
+			problem = fmt.Errorf("unknown handler (expected %s)", strings.Join(handlerNames(), ", "))
+		} else if seen[name] {
+			problem = errors.New("listed twice")
+		}
+		if problem != nil {
+			return nil, &config.EntryError{Source: "transition-handlers", Index: i, Entry: name, Err: problem}
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if !seen[routingHandler] {
+		return nil, fmt.Errorf("transition-handlers must include %q", routingHandler)
+	}
+	return names, nil
+}
+
+func handlerNames() []string {
+	names := make([]string, 0, len(transitionHandlers))
+	for name := range transitionHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// webhookHandler posts every role change as JSON to a URL, e.g. a chat
+// notifier or a deployment tracker.
+type webhookHandler struct {
+	url       string
+	client    *http.Client
+	podName   string
+	namespace string
+}
+
+type webhookPayload struct {
+	Pod          string    `json:"pod"`
+	Namespace    string    `json:"namespace"`
+	PreviousRole string    `json:"previousRole,omitempty"`
+	Role         string    `json:"role"`
+	Time         time.Time `json:"time"`
+}
+
+func newWebhookHandler(env handlerEnv) (k8s.TransitionHandler, error) {
+	raw := strings.TrimSpace(viper.GetString("transition-webhook-url"))
+	if raw == "" {
+		return nil, errors.New("transition-webhook-url is not set")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("transition-webhook-url %q is not an http or https URL", raw)
+	}
+	return &webhookHandler{
+		url:       raw,
+		client:    &http.Client{Timeout: viper.GetDuration("transition-webhook-timeout")},
+		podName:   env.podName,
+		namespace: env.namespace,
+	}, nil
+}
+
+// OnTransition implements k8s.TransitionHandler.
+func (h *webhookHandler) OnTransition(ctx context.Context, previous string, current string) error {
+	body, err := json.Marshal(webhookPayload{
+		Pod:          h.podName,
+		Namespace:    h.namespace,
+		PreviousRole: previous,
+		Role:         current,
+		Time:         time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseHandlerNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		raw     any
+		want    string
+		wantErr string
+	}{
+		{name: "default", raw: "routing", want: "routing"},
+		{name: "ordered list", raw: []any{"webhook", "routing"}, want: "webhook,routing"},
+		{name: "unknown", raw: "routing,pager", wantErr: `transition-handlers[1] "pager": unknown handler`},
+		{name: "duplicate", raw: "routing, routing", wantErr: "listed twice"},
+		{name: "routing missing", raw: "webhook", wantErr: `must include "routing"`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseHandlerNames(tc.raw)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHandlerNames returned error: %v", err)
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Fatalf("expected %s, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	t.Parallel()
+
+	var got webhookPayload
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	handler := &webhookHandler{url: server.URL, client: server.Client(), podName: "web-0", namespace: "shop"}
+	if err := handler.OnTransition(context.Background(), "active", "preview"); err != nil {
+		t.Fatalf("OnTransition returned error: %v", err)
+	}
+	if got.Pod != "web-0" || got.Namespace != "shop" || got.PreviousRole != "active" || got.Role != "preview" || got.Time.IsZero() {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+
+	status = http.StatusBadGateway
+	if err := handler.OnTransition(context.Background(), "preview", "active"); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected non-2xx response to fail, got %v", err)
+	}
+}
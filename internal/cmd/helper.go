@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// helperCommand is the hidden subcommand the watcher re-executes itself with
+// when privilege separation is enabled.
+const helperCommand = "iptables-helper"
+
+// helperBinaries are the only commands the helper will run.
+var helperBinaries = map[string]bool{"iptables": true, "ip6tables": true, "ipset": true}
+
+// HelperCmd runs a single rule command on behalf of a watcher that has
+// dropped its capabilities. It is not meant to be invoked by hand.
+var HelperCmd = &cobra.Command{
+	Use:                helperCommand + " -- <binary> [args...]",
+	Short:              "Run one iptables command for a privilege-separated watcher",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	SilenceUsage:       true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if len(args) == 0 || !helperBinaries[args[0]] {
+			return configError(fmt.Errorf("%s only runs iptables, ip6tables or ipset", helperCommand))
+		}
+		if err := iptables.ValidateCommand(args[0], args[1:]); err != nil {
+			return configError(err)
+		}
+
+		// #nosec G204 -- the binary is restricted to helperBinaries and the
+		// arguments are checked like any RealExecutor command above.
+		child := exec.Command(args[0], args[1:]...)
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := child.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// Pass the status through untouched: callers such as
+				// ChainExists branch on it, and the child already wrote its
+				// own diagnostics.
+				os.Exit(exitErr.ExitCode())
+			}
+			return err
+		}
+		return nil
+	},
+}
+
+// newPrivsepExecutor returns an executor that runs every command through a
+// re-executed copy of this binary.
+func newPrivsepExecutor() (iptables.Executor, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate ghostwire binary: %w", err)
+	}
+	return &iptables.HelperExecutor{Path: self, Args: []string{helperCommand, "--"}}, nil
+}
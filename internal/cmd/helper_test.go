@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+func TestHelperCmdRejectsBadArgv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "shell metacharacter", args: []string{"--", "iptables", "-t", "nat", "-N", "X;reboot"}},
+		{name: "control character", args: []string{"--", "ipset", "add", "gw-exclude", "10.0.0.0/8\n"}},
+		{name: "oversized argument", args: []string{"--", "iptables", "-N", strings.Repeat("X", 513)}},
+		{name: "too many arguments", args: append([]string{"--", "ip6tables"}, slices.Repeat([]string{"-v"}, 65)...)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := HelperCmd.RunE(HelperCmd, tc.args)
+			if !errors.Is(err, iptables.ErrCommandRejected) {
+				t.Fatalf("RunE(%q) = %v, want ErrCommandRejected", tc.args, err)
+			}
+			if ExitCode(err) != ExitConfig {
+				t.Fatalf("exit code = %d, want %d", ExitCode(err), ExitConfig)
+			}
+		})
+	}
+
+	if err := HelperCmd.RunE(HelperCmd, []string{"--", "sh", "-c", "id"}); err == nil || ExitCode(err) != ExitConfig {
+		t.Fatalf("expected a binary outside the allowlist to be refused, got %v", err)
+	}
+}
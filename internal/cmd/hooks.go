@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// hooksHandler is the transition handler that runs the user's hook commands.
+// It is appended to the chain automatically when a hook command is set.
+const hooksHandler = "hooks"
+
+// Hook events passed to the commands as GW_HOOK_EVENT.
+const (
+	hookEventActivate   = "activate"
+	hookEventDeactivate = "deactivate"
+)
+
+// maxHookOutput caps how much of a hook's output is logged.
+const maxHookOutput = 4 << 10
+
+// hookHandler runs on-activate-cmd once preview routing has been switched on
+// and on-deactivate-cmd once it has been switched off, e.g. to warm caches or
+// drain connections. Commands run through /bin/sh -c with the transition in
+// their environment. Transitions the routing handler failed to apply run no
+// hook.
+type hookHandler struct {
+	jm            *jumpManager
+	activateCmd   string
+	deactivateCmd string
+	timeout       time.Duration
+	env           []string
+	logger        *slog.Logger
+}
+
+// hooksConfigured reports whether either hook command is set.
+func hooksConfigured() bool {
+	return strings.TrimSpace(viper.GetString("on-activate-cmd")) != "" || strings.TrimSpace(viper.GetString("on-deactivate-cmd")) != ""
+}
+
+func newHookHandler(env handlerEnv) (k8s.TransitionHandler, error) {
+	if !hooksConfigured() {
+		return nil, errors.New("neither on-activate-cmd nor on-deactivate-cmd is set")
+	}
+	return &hookHandler{
+		jm:            env.jm,
+		activateCmd:   strings.TrimSpace(viper.GetString("on-activate-cmd")),
+		deactivateCmd: strings.TrimSpace(viper.GetString("on-deactivate-cmd")),
+		timeout:       viper.GetDuration("hook-timeout"),
+		env: []string{
+			"GW_POD_NAME=" + env.podName,
+			"GW_POD_NAMESPACE=" + env.namespace,
+		},
+		logger: env.logger,
+	}, nil
+}
+
+// OnTransition implements k8s.TransitionHandler.
+func (h *hookHandler) OnTransition(ctx context.Context, previous string, current string) error {
+	status := h.jm.Status()
+	if status.Role != current || status.LastError != "" {
+		h.logger.Debug("skipping hooks for a transition that was not applied", slog.String("current_role", current))
+		return nil
+	}
+
+	wasRouting, routing := h.jm.routes(previous), h.jm.routes(current)
+	switch {
+	case routing && !wasRouting:
+		return h.run(ctx, hookEventActivate, h.activateCmd, previous, current, status)
+	case wasRouting && !routing:
+		return h.run(ctx, hookEventDeactivate, h.deactivateCmd, previous, current, status)
+	}
+	return nil
+}
+
+func (h *hookHandler) run(ctx context.Context, event, command, previous, current string, status routingStatus) error {
+	if command == "" {
+		return nil
+	}
+
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	// Children left behind by a killed shell may hold the output pipe open;
+	// stop waiting for them shortly after the timeout.
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(), h.env...)
+	cmd.Env = append(cmd.Env,
+		"GW_HOOK_EVENT="+event,
+		"GW_PREVIOUS_ROLE="+previous,
+		"GW_ROLE="+current,
+		"GW_NAT_CHAIN="+status.NATChain,
+		"GW_JUMP_HOOK="+status.JumpHook,
+	)
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logged := string(output)
+	if len(logged) > maxHookOutput {
+		logged = logged[:maxHookOutput] + "..."
+	}
+	attrs := []any{
+		slog.String("event", event),
+		slog.String("current_role", current),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("output", strings.TrimSpace(logged)),
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", h.timeout)
+		}
+		h.logger.Warn("hook command failed", append(attrs, slog.Any("error", err))...)
+		return fmt.Errorf("%s hook: %w", event, err)
+	}
+	h.logger.Info("hook command finished", attrs...)
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestHookHandler(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "hook.log")
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     &mockExecutor{},
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+	handler := &hookHandler{
+		jm:            jm,
+		activateCmd:   `echo "$GW_HOOK_EVENT $GW_PREVIOUS_ROLE>$GW_ROLE $GW_POD_NAME $GW_NAT_CHAIN" >> ` + out,
+		deactivateCmd: `echo "$GW_HOOK_EVENT $GW_ROLE" >> ` + out + `; exit 3`,
+		timeout:       5 * time.Second,
+		env:           []string{"GW_POD_NAME=web-0"},
+		logger:        logger,
+	}
+
+	ctx := context.Background()
+	transition := func(previous, current string) error {
+		if err := jm.OnTransition(ctx, previous, current); err != nil {
+			t.Fatalf("routing %s -> %s failed: %v", previous, current, err)
+		}
+		return handler.OnTransition(ctx, previous, current)
+	}
+
+	if err := transition("active", "preview"); err != nil {
+		t.Fatalf("activate hook failed: %v", err)
+	}
+	if err := transition("preview", "preview"); err != nil {
+		t.Fatalf("expected no hook for an unchanged role, got %v", err)
+	}
+	if err := transition("preview", "active"); err == nil || !strings.Contains(err.Error(), "deactivate hook") {
+		t.Fatalf("expected failing deactivate hook to be reported, got %v", err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	want := "activate active>preview web-0 CANARY_DNAT\ndeactivate active\n"
+	if string(content) != want {
+		t.Fatalf("unexpected hook output %q, want %q", content, want)
+	}
+
+	handler.activateCmd = "exec sleep 5"
+	handler.timeout = 50 * time.Millisecond
+	if err := transition("active", "preview"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected hook timeout, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// Modes accepted by http-auth.
+const (
+	httpAuthNone        = ""
+	httpAuthToken       = "token"
+	httpAuthTokenReview = "tokenreview"
+)
+
+// tokenReviewCacheTTL bounds how long an accepted token skips the TokenReview
+// call, so scrapes and probes do not hit the API server every time.
+const tokenReviewCacheTTL = time.Minute
+
+// tokenCheck accepts or rejects a bearer token.
+type tokenCheck func(ctx context.Context, token string) error
+
+// httpTokenCheck builds the bearer check for the watcher's read endpoints
+// from http-auth. It returns nil when authentication is off.
+func httpTokenCheck(client kubernetes.Interface) (tokenCheck, error) {
+	switch mode := strings.TrimSpace(viper.GetString("http-auth")); mode {
+	case httpAuthNone:
+		return nil, nil
+	case httpAuthToken:
+		token, err := loadTokenFile(strings.TrimSpace(viper.GetString("http-token-file")))
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, errors.New("http-auth=token requires http-token-file")
+		}
+		return staticTokenCheck(token), nil
+	case httpAuthTokenReview:
+		return newTokenReviewCheck(client, splitList(viper.GetString("http-token-audiences")), time.Now), nil
+	default:
+		return nil, fmt.Errorf("http-auth %q must be empty, %q or %q", mode, httpAuthToken, httpAuthTokenReview)
+	}
+}
+
+func staticTokenCheck(token string) tokenCheck {
+	return func(_ context.Context, presented string) error {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return errors.New("wrong bearer token")
+		}
+		return nil
+	}
+}
+
+// newTokenReviewCheck accepts any token the API server authenticates for
+// audiences, like kube-rbac-proxy without the authorization step.
+func newTokenReviewCheck(client kubernetes.Interface, audiences []string, now func() time.Time) tokenCheck {
+	var mu sync.Mutex
+	accepted := map[[sha256.Size]byte]time.Time{}
+
+	return func(ctx context.Context, token string) error {
+		key := sha256.Sum256([]byte(token))
+		mu.Lock()
+		expiry, ok := accepted[key]
+		mu.Unlock()
+		if ok && now().Before(expiry) {
+			return nil
+		}
+
+		if _, err := k8s.ReviewToken(ctx, client, token, audiences); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for k, exp := range accepted {
+			if !now().Before(exp) {
+				delete(accepted, k)
+			}
+		}
+		accepted[key] = now().Add(tokenReviewCacheTTL)
+		return nil
+	}
+}
+
+// requireBearer guards every path on next except POST /role and /admin/*,
+// which carry their own authentication.
+func requireBearer(next http.Handler, check tokenCheck, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/role" || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || presented == "" {
+			logger.Warn("http request rejected", slog.String("path", r.URL.Path), slog.String("remote_addr", r.RemoteAddr), slog.String("reason", "missing bearer token"))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ghostwire"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := check(r.Context(), presented); err != nil {
+			logger.Warn("http request rejected", slog.String("path", r.URL.Path), slog.String("remote_addr", r.RemoteAddr), slog.Any("error", err))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ghostwire"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+func TestRequireBearerProtectsReadEndpoints(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	handler := requireBearer(buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), nil, nil, nil, nil), staticTokenCheck("scrape"), logger)
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{name: "metrics without token", path: "/metrics", want: http.StatusUnauthorized},
+		{name: "healthz with wrong token", path: "/healthz", token: "nope", want: http.StatusUnauthorized},
+		{name: "metrics with token", path: "/metrics", token: "scrape", want: http.StatusOK},
+		{name: "admin paths keep their own auth", path: "/admin/resync", want: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("expected status %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTokenReviewCheckCachesAcceptedTokens(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviews := 0
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status.Authenticated = review.Spec.Token == "good"
+		return true, review, nil
+	})
+
+	now := time.Unix(1700000000, 0)
+	check := newTokenReviewCheck(client, nil, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background(), "good"); err != nil {
+			t.Fatalf("check returned error: %v", err)
+		}
+	}
+	if reviews != 1 {
+		t.Fatalf("expected one token review while cached, got %d", reviews)
+	}
+
+	now = now.Add(tokenReviewCacheTTL)
+	if err := check(context.Background(), "good"); err != nil || reviews != 2 {
+		t.Fatalf("expected a fresh review after the cache expired, got err %v after %d reviews", err, reviews)
+	}
+
+	if err := check(context.Background(), "bad"); !errors.Is(err, k8s.ErrTokenNotAuthenticated) {
+		t.Fatalf("expected ErrTokenNotAuthenticated, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// watcherListeners opens the listeners of the watcher's HTTP server: the TCP
+// address in http-addr and the unix socket in http-socket, either of which
+// may be empty. Pods whose policy forbids extra ports serve on the socket
+// alone, for a sidecar or node agent sharing the volume to scrape.
+func watcherListeners() ([]net.Listener, error) {
+	addr := strings.TrimSpace(viper.GetString("http-addr"))
+	socket := strings.TrimSpace(viper.GetString("http-socket"))
+	if addr == "" && socket == "" {
+		return nil, configError(errors.New("http-addr and http-socket are both empty; set at least one"))
+	}
+
+	var listeners []net.Listener
+	if addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if socket != "" {
+		listener, err := listenUnixSocket(socket)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenUnixSocket listens on a unix socket at path, replacing a stale one
+// left by a previous run. Like the rule helper's socket it is mode 0660, so
+// only the owner and group can connect. Closing the listener removes it.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("restrict socket %s: %w", path, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestWatcherListenersServeSocket(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("http-addr", nil)
+		viper.Set("http-socket", nil)
+	})
+
+	viper.Set("http-addr", "")
+	viper.Set("http-socket", "")
+	if _, err := watcherListeners(); err == nil {
+		t.Fatal("expected an error with neither a TCP address nor a socket")
+	}
+
+	socket := filepath.Join(t.TempDir(), "ghostwire.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatalf("write stale socket: %v", err)
+	}
+	viper.Set("http-socket", socket)
+	listeners, err := watcherListeners()
+	if err != nil {
+		t.Fatalf("watcherListeners returned error: %v", err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("expected only the socket listener, got %d", len(listeners))
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o660 {
+		t.Fatalf("expected a 0660 socket, got %v", info.Mode())
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), ReadHeaderTimeout: time.Second}
+	go func() { _ = srv.Serve(listeners[0]) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://ghostwire/healthz")
+	if err != nil {
+		t.Fatalf("request over the socket: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+}
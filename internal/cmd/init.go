@@ -2,18 +2,22 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/controller"
 	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/dns"
+	"github.com/denniswebb/ghostwire/internal/handshake"
 	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/k8s"
 	"github.com/denniswebb/ghostwire/internal/logging"
 )
 
@@ -22,117 +26,378 @@ var InitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Discover services and build DNAT rules",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+		return runInit()
+	},
+}
 
-		logger := logging.GetLogger()
-		if logger == nil {
-			logger = slog.Default()
-		}
+// runInit discovers mappings, builds the DNAT chain and writes the map and
+// ready marker. Setup flushes an existing chain, so it is safe to repeat.
+func runInit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), initTimeout())
+	defer cancel()
 
-		namespace := viper.GetString("namespace")
-		if namespace == "" {
-			namespace = os.Getenv("POD_NAMESPACE")
-		}
-		if namespace == "" {
-			namespace = "default"
-		}
+	logger := logging.GetLogger()
+	if logger == nil {
+		logger = slog.Default()
+	}
 
-		previewPattern := viper.GetString("svc-preview-pattern")
-		if previewPattern == "" {
-			previewPattern = "{{name}}-preview"
-		}
+	namespace := initNamespace()
+	if err := applyNamespaceOverrides(namespace, logger); err != nil {
+		return err
+	}
 
-		activeSuffix := viper.GetString("active-suffix")
-		if activeSuffix == "" {
-			activeSuffix = "-active"
+	if viper.GetBool("rbac-check") {
+		if clientset, err := k8s.NewInClusterClient(); err != nil {
+			logger.Warn("rbac self-check skipped", slog.String("error", err.Error()))
+		} else if err := checkRBAC(ctx, clientset, initPermissions(namespace), logger); err != nil {
+			return err
 		}
+	}
 
-		previewSuffix := viper.GetString("preview-suffix")
-		if previewSuffix == "" {
-			previewSuffix = "-preview"
-		}
+	_, err := loadGhostwireConfig(ctx, namespace, logger)
+	if err != nil {
+		logger.Error("failed to load ghostwireconfig", slog.String("error", err.Error()))
+		return configError(err)
+	}
 
-		clientset, err := discovery.NewInClusterClient()
-		if err != nil {
-			logger.Error("failed to create kubernetes client", slog.String("error", err.Error()))
+	readyMarker := strings.TrimSpace(viper.GetString("ready-marker"))
+	if readyMarker != "" {
+		if err := handshake.Clear(readyMarker); err != nil {
+			logger.Error("failed to clear ready marker", slog.String("error", err.Error()))
 			return err
 		}
+	}
 
-		discoveryCfg := discovery.Config{
-			Clientset:      clientset,
-			Namespace:      namespace,
-			PreviewPattern: previewPattern,
-			ActiveSuffix:   activeSuffix,
-			PreviewSuffix:  previewSuffix,
-		}
+	mappings, err := resolveMappings(ctx, namespace, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info(
+		"service discovery complete",
+		slog.Int("mappings", len(mappings)),
+		slog.String("namespace", namespace),
+	)
 
-		mappings, err := discovery.Discover(ctx, discoveryCfg, logger)
+	iptablesCfg, err := iptablesConfig(logger)
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool("skip-apply") {
+		if err := recordRules(ctx, iptablesCfg, mappings, logger); err != nil {
+			return err
+		}
+	} else {
+		executor := iptables.NewExecutor()
+		rules, err := ruleBackend(executor, iptablesCfg, logger)
 		if err != nil {
-			logger.Error("service discovery failed", slog.String("error", err.Error()))
 			return err
 		}
-
-		logger.Info(
-			"service discovery complete",
-			slog.Int("mappings", len(mappings)),
-			slog.String("namespace", namespace),
-		)
-
-		chainName := strings.TrimSpace(viper.GetString("nat-chain"))
-		excludeList := viper.GetString("exclude-cidrs")
-		ipv6Enabled := viper.GetBool("ipv6")
-
-		excludeCIDRs, err := parseExcludeCIDRs(excludeList)
+		claim, err := claimRules(ctx, executor, "init", logger)
 		if err != nil {
-			logger.Error("invalid exclude CIDRs", slog.String("value", excludeList), slog.String("error", err.Error()))
 			return err
 		}
+		defer func() { _ = claim.Release() }()
 
-		dnatMapPath := strings.TrimSpace(viper.GetString("iptables-dnat-map"))
-		if dnatMapPath == "" {
-			dnatMapPath = "/shared/dnat.map"
+		if err := rules.ApplyMappings(ctx, mappings); err != nil {
+			logger.Error("iptables setup failed", slog.String("error", err.Error()))
+			return iptablesError(err)
 		}
+	}
 
-		iptablesCfg := iptables.Config{
-			ChainName:    chainName,
-			ExcludeCIDRs: excludeCIDRs,
-			IPv6:         ipv6Enabled,
-			DnatMapPath:  dnatMapPath,
+	if viper.GetBool("dns-mode") {
+		fragmentPath := strings.TrimSpace(viper.GetString("dns-hosts-fragment"))
+		entries := dns.BuildHostsEntries(mappings, namespace, viper.GetString("dns-suffix"))
+		if err := dns.WriteHostsFragment(fragmentPath, entries, logger); err != nil {
+			logger.Error("dns hosts fragment write failed", slog.String("error", err.Error()))
+			return err
 		}
+	}
 
-		if err := iptables.Setup(ctx, iptablesCfg, mappings, logger); err != nil {
-			logger.Error("iptables setup failed", slog.String("error", err.Error()))
+	if readyMarker != "" {
+		generation, err := handshake.NewGeneration()
+		if err != nil {
 			return err
 		}
+		if err := handshake.Write(readyMarker, handshake.Marker{Generation: generation, Written: time.Now().UTC()}); err != nil {
+			logger.Error("failed to write ready marker", slog.String("error", err.Error()))
+			return err
+		}
+		logger.Info("ready marker written", slog.String("path", readyMarker), slog.String("generation", generation))
+	}
+
+	if viper.GetBool("publish-mappings") {
+		publishPodMappings(ctx, namespace, mappings, logger)
+	}
+
+	if viper.GetBool("service-events") {
+		recordPairingEvents(ctx, namespace, mappings, logger)
+	}
+
+	logger.Info(
+		"iptables chain prepared",
+		slog.String("chain", iptablesCfg.ChainName),
+		slog.Int("dnat_rules", len(mappings)),
+	)
+
+	return nil
+}
 
-		logger.Info(
-			"iptables chain prepared",
-			slog.String("chain", chainName),
-			slog.Int("dnat_rules", len(mappings)),
-		)
+// initNamespace returns the namespace init discovers Services in.
+func initNamespace() string {
+	namespace := viper.GetString("namespace")
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		namespace = config.DefaultNamespace
+	}
+	return namespace
+}
+
+// resolveMappings lists the configured mapping sources (see mappingSource).
+// Errors carry their exit code.
+func resolveMappings(ctx context.Context, namespace string, logger *slog.Logger) ([]discovery.ServiceMapping, error) {
+	source, err := mappingSource(namespace, logger)
+	if err != nil {
+		return nil, err
+	}
+	return source.List(ctx)
+}
+
+// iptablesConfig builds the rule configuration shared by init and resync.
+func iptablesConfig(logger *slog.Logger) (iptables.Config, error) {
+	chainName, _, err := ruleNames()
+	if err != nil {
+		return iptables.Config{}, err
+	}
+	ipv6Enabled := viper.GetBool("ipv6")
+
+	excludeCIDRs, err := config.ParseCIDRList("exclude-cidrs", configValue("exclude-cidrs"), viper.GetBool("strict-parsing"))
+	if err != nil {
+		logger.Error("invalid exclude CIDRs", slog.String("error", err.Error()))
+		return iptables.Config{}, configError(err)
+	}
+
+	protocolList := viper.GetString("dnat-protocols")
+	protocols, err := iptables.ParseProtocols(splitList(protocolList))
+	if err != nil {
+		logger.Error("invalid dnat protocols", slog.String("value", protocolList), slog.String("error", err.Error()))
+		return iptables.Config{}, configError(err)
+	}
 
+	mapKey, err := dnatMapKey()
+	if err != nil {
+		return iptables.Config{}, err
+	}
+
+	endpointBalance, err := iptables.ParseEndpointBalance(viper.GetString("endpoint-balance"))
+	if err != nil {
+		return iptables.Config{}, configError(err)
+	}
+
+	split, err := splitConfig()
+	if err != nil {
+		return iptables.Config{}, err
+	}
+
+	dnatMapPath := strings.TrimSpace(viper.GetString("iptables-dnat-map"))
+	if dnatMapPath == "" {
+		dnatMapPath = config.DefaultDNATMapPath
+	}
+
+	return iptables.Config{
+		ChainName:          chainName,
+		ExcludeCIDRs:       excludeCIDRs,
+		NotrackCIDRs:       splitList(viper.GetString("notrack-cidrs")),
+		ExclusionIPSet:     viper.GetString("exclude-ipset"),
+		ExcludeCgroupPaths: splitList(viper.GetString("exclude-cgroups")),
+		IPv6:               ipv6Enabled,
+		Protocols:          protocols,
+		DnatMapPath:        dnatMapPath,
+		WholeServiceDNAT:   viper.GetBool("whole-service-dnat"),
+		Multiport:          viper.GetBool("multiport"),
+		EndpointDNAT:       viper.GetBool("endpoint-dnat"),
+		EndpointBalance:    endpointBalance,
+		Split:              split,
+		HairpinMasquerade:  viper.GetBool("hairpin-masquerade"),
+		HairpinMark:        viper.GetString("hairpin-mark"),
+		DebugLog: iptables.DebugLogConfig{
+			Target:     viper.GetString("debug-log"),
+			Scope:      viper.GetString("debug-log-scope"),
+			Prefix:     viper.GetString("debug-log-prefix"),
+			Rate:       viper.GetString("debug-log-rate"),
+			NFLOGGroup: viper.GetInt("debug-log-nflog-group"),
+		},
+		CTTimeoutPolicy:     strings.TrimSpace(viper.GetString("ct-timeout-policy")),
+		CTUDPTimeoutSeconds: viper.GetInt("ct-udp-timeout"),
+		DnatMapKey:          mapKey,
+	}, nil
+}
+
+// recordRules runs the rule setup against a recorder instead of iptables, for
+// split-privilege deployments where another component applies the rules. The
+// DNAT map is written as usual, and the rules go to restore-file when set.
+func recordRules(ctx context.Context, cfg iptables.Config, mappings []discovery.ServiceMapping, logger *slog.Logger) error {
+	recorder := iptables.NewRestoreRecorder()
+	if err := iptables.SetupWithExecutor(ctx, recorder, cfg, mappings, logger); err != nil {
+		logger.Error("recording iptables rules failed", slog.String("error", err.Error()))
+		return iptablesError(err)
+	}
+
+	restoreFile := strings.TrimSpace(viper.GetString("restore-file"))
+	if restoreFile == "" {
+		logger.Info("skip-apply: iptables not executed; only the dnat map was written")
 		return nil
-	},
+	}
+	if err := recorder.WriteFiles(restoreFile); err != nil {
+		logger.Error("failed to write restore file", slog.String("path", restoreFile), slog.String("error", err.Error()))
+		return err
+	}
+	logger.Info("skip-apply: iptables not executed; rules written for iptables-restore", slog.String("path", restoreFile))
+	return nil
 }
 
-func parseExcludeCIDRs(csv string) ([]string, error) {
-	if strings.TrimSpace(csv) == "" {
-		return nil, nil
+// apiBackoff reads the retry policy init applies to Kubernetes API calls.
+func apiBackoff() k8s.Backoff {
+	return k8s.Backoff{
+		Attempts: viper.GetInt("api-retry-attempts"),
+		Initial:  viper.GetDuration("api-retry-initial-backoff"),
+		Max:      viper.GetDuration("api-retry-max-backoff"),
 	}
+}
+
+// initWorkTimeout is the time init allows for everything besides API retry
+// backoff: the calls themselves and the iptables work.
+const initWorkTimeout = 30 * time.Second
 
-	var result []string
-	for _, part := range strings.Split(csv, ",") {
-		trimmed := strings.TrimSpace(part)
-		if trimmed == "" {
-			continue
+// initRetriedCalls counts the API calls init wraps in RetryAPI one after
+// another: client creation, discovery and the controller mappings read.
+const initRetriedCalls = 3
+
+// initTimeout returns init-timeout when set. Otherwise it leaves room for
+// every retried call to exhaust its backoff on top of initWorkTimeout, so
+// the deadline never cancels init mid-retry.
+func initTimeout() time.Duration {
+	if timeout := viper.GetDuration("init-timeout"); timeout > 0 {
+		return timeout
+	}
+	return initWorkTimeout + initRetriedCalls*apiBackoff().Budget()
+}
+
+// publishPodMappings writes the pod's mappings to its own ConfigMap. Like the
+// pairing events this is for visibility only, so failures are logged.
+func publishPodMappings(ctx context.Context, namespace string, mappings []discovery.ServiceMapping, logger *slog.Logger) {
+	podName := os.Getenv("POD_NAME")
+	if podName == "" {
+		logger.Warn("skipping mappings configmap: environment variable POD_NAME is not set")
+		return
+	}
+
+	clientset, err := k8s.NewInClusterClient()
+	if err != nil {
+		logger.Warn("skipping mappings configmap", slog.String("error", err.Error()))
+		return
+	}
+
+	target := controller.PodTarget(namespace, podName, os.Getenv("POD_UID"))
+	hash, _, err := controller.Publish(ctx, clientset, target, mappings)
+	if err != nil {
+		logger.Warn("failed to publish mappings configmap", slog.String("configmap", target.Name), slog.String("error", err.Error()))
+		return
+	}
+	logger.Info("published mappings configmap", slog.String("configmap", target.Name), slog.String("hash", hash))
+}
+
+// recordPairingEvents creates an Event on every paired active Service. Events
+// are informational, so failures are logged and never fail init.
+func recordPairingEvents(ctx context.Context, namespace string, mappings []discovery.ServiceMapping, logger *slog.Logger) {
+	clientset, err := k8s.NewInClusterClient()
+	if err != nil {
+		logger.Warn("skipping service pairing events", slog.String("error", err.Error()))
+		return
+	}
+
+	recorder := k8s.NewServiceEventRecorder(clientset, namespace, os.Getenv("POD_NAME"))
+	for _, pairing := range k8s.GroupPairings(mappings) {
+		if err := recorder.RecordPairing(ctx, pairing); err != nil {
+			logger.Warn("failed to record service pairing event", slog.String("service", pairing.Service), slog.String("error", err.Error()))
 		}
+	}
+}
+
+// discoverNamespace runs convention-based discovery for a namespace using the
+// configured naming settings, merging GhostwireMapping overrides when enabled.
+// settings holds per-namespace values that win over the configured ones.
+func discoverNamespace(ctx context.Context, clientset *kubernetes.Clientset, namespace string, settings map[string]string, logger *slog.Logger) ([]discovery.ServiceMapping, error) {
+	discoveryCfg := discoveryConfig(clientset, namespace, settings)
 
-		if _, _, err := net.ParseCIDR(trimmed); err != nil {
-			return nil, fmt.Errorf("parse exclude cidr %q: %w", trimmed, err)
+	var overrides *mappingOverrides
+	if viper.GetBool("mapping-overrides") {
+		var err error
+		overrides, err = loadMappingOverrides(ctx, namespace)
+		if err != nil {
+			logger.Error("failed to load ghostwiremapping overrides", slog.String("namespace", namespace), slog.String("error", err.Error()))
+			return nil, err
 		}
-		result = append(result, trimmed)
+		discoveryCfg.Overrides = overrides.overrides
+	}
+
+	mappings, conflicts, err := discovery.DiscoverWithOverrides(ctx, discoveryCfg, logger)
+	if err != nil {
+		logger.Error("service discovery failed", slog.String("namespace", namespace), slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	return result, nil
+	if overrides != nil {
+		overrides.reportConflicts(ctx, conflicts, logger)
+	}
+
+	logger.Info("discovery summary", slog.String("namespace", namespace), slog.Any("stats", discoveryCfg.Stats))
+
+	return mappings, nil
+}
+
+// discoveryConfig builds the discovery settings for namespace from the
+// configured naming and selection options, preferring values in settings.
+func discoveryConfig(clientset *kubernetes.Clientset, namespace string, settings map[string]string) discovery.Config {
+	get := func(key string) string {
+		if value, ok := settings[key]; ok {
+			return value
+		}
+		return configString(key)
+	}
+
+	previewPattern := get("svc-preview-pattern")
+	if previewPattern == "" {
+		previewPattern = config.DefaultPreviewPattern
+	}
+
+	activeSuffix := get("active-suffix")
+	if activeSuffix == "" {
+		activeSuffix = config.DefaultActiveSuffix
+	}
+
+	previewSuffix := get("preview-suffix")
+	if previewSuffix == "" {
+		previewSuffix = config.DefaultPreviewSuffix
+	}
+
+	return discovery.Config{
+		Clientset:         clientset,
+		Namespace:         namespace,
+		PreviewPattern:    previewPattern,
+		ActiveSuffix:      activeSuffix,
+		PreviewSuffix:     previewSuffix,
+		PairBy:            strings.TrimSpace(viper.GetString("pair-by")),
+		StatefulOrdinals:  viper.GetBool("stateful-ordinals"),
+		RecordTargetPorts: viper.GetBool("record-target-ports"),
+		ResolveEndpoints:  viper.GetBool("endpoint-dnat"),
+		ServiceSelector:   strings.TrimSpace(viper.GetString("service-selector")),
+		ExcludeSelector:   strings.TrimSpace(get("exclude-service-selector")),
+		Stats:             &discovery.Stats{},
+		ReleaseLabel:      strings.TrimSpace(viper.GetString("release-label")),
+		ReleasePattern:    strings.TrimSpace(viper.GetString("release-pattern")),
+	}
 }
@@ -1,19 +1,103 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+	"github.com/denniswebb/ghostwire/internal/webhook"
 )
 
 // InjectorCmd represents the ghostwire injector subcommand.
 var InjectorCmd = &cobra.Command{
 	Use:   "injector",
-	Short: "Run mutating admission webhook server",
+	Short: "Run the admission webhook server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if logger := logging.GetLogger(); logger != nil {
-			logger.Info("injector command not yet implemented")
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		listenAddr := strings.TrimSpace(viper.GetString("injector-listen-addr"))
+		certFile := strings.TrimSpace(viper.GetString("injector-tls-cert"))
+		keyFile := strings.TrimSpace(viper.GetString("injector-tls-key"))
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("injector requires both a TLS certificate and key")
+		}
+
+		injectorLogger := logger.With(
+			slog.String("component", "injector"),
+			slog.String("listen_addr", listenAddr),
+		)
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		// Rotated certificates are picked up per handshake, so admission
+		// requests in flight never see the listener go away.
+		metricsCollector := metrics.NewMetrics()
+		certs, err := newCertReloader(ctx, "injector", certFile, keyFile, metricsCollector, injectorLogger)
+		if err != nil {
+			return configError(err)
+		}
+
+		srv := &http.Server{
+			Addr:              listenAddr,
+			Handler:           buildInjectorMux(metricsCollector, injectorLogger),
+			TLSConfig:         certs.TLSConfig(nil),
+			ReadHeaderTimeout: 5 * time.Second,
 		}
-		return nil
+
+		serverErrCh := make(chan error, 1)
+		go func() {
+			defer close(serverErrCh)
+			if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrCh <- err
+			}
+		}()
+
+		injectorLogger.Info("injector started; mutation not yet implemented, serving validation only")
+
+		var serverErr error
+		select {
+		case <-ctx.Done():
+			injectorLogger.Info("shutdown signal received")
+		case err, ok := <-serverErrCh:
+			if ok && err != nil {
+				serverErr = err
+				injectorLogger.Error("webhook server encountered error", slog.Any("error", err))
+			}
+		}
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			injectorLogger.Error("webhook server shutdown failed", slog.Any("error", err))
+		}
+
+		injectorLogger.Info("injector shutdown complete")
+		return serverErr
 	},
 }
+
+func buildInjectorMux(m *metrics.Metrics, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/validate", webhook.NewValidator(logger))
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
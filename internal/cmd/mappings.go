@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// mappingOverrides couples the GhostwireMapping objects read for a discovery run
+// with the conflicts detected before discovery even starts (invalid specs).
+type mappingOverrides struct {
+	source    *k8s.MappingSource
+	objects   []v1alpha1.GhostwireMapping
+	overrides []discovery.Override
+	invalid   discovery.Conflicts
+}
+
+// loadMappingOverrides lists GhostwireMapping objects and converts the valid
+// ones into discovery overrides.
+func loadMappingOverrides(ctx context.Context, namespace string) (*mappingOverrides, error) {
+	client, err := k8s.NewInClusterDynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	source := k8s.NewMappingSource(client, namespace)
+	objects, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mappingOverrides{
+		source:  source,
+		objects: objects,
+		invalid: discovery.Conflicts{},
+	}
+	for _, obj := range objects {
+		if err := obj.Spec.Validate(); err != nil {
+			result.invalid[obj.Name] = []string{err.Error()}
+			continue
+		}
+		result.overrides = append(result.overrides, toDiscoveryOverride(obj))
+	}
+
+	return result, nil
+}
+
+// reportConflicts records discovery conflicts on each GhostwireMapping status.
+// Failures are logged rather than returned so status bookkeeping never blocks
+// routing setup.
+func (m *mappingOverrides) reportConflicts(ctx context.Context, conflicts discovery.Conflicts, logger *slog.Logger) {
+	for i := range m.objects {
+		obj := &m.objects[i]
+		reasons, invalid := m.invalid[obj.Name]
+		if !invalid {
+			reasons = conflicts[obj.Name]
+		}
+
+		for _, reason := range reasons {
+			logger.Warn("ghostwiremapping conflict", slog.String("mapping", obj.Name), slog.String("service", obj.Spec.Service), slog.String("reason", reason))
+		}
+
+		if err := m.source.UpdateStatus(ctx, obj, reasons); err != nil {
+			logger.Warn("failed to update ghostwiremapping status", slog.String("mapping", obj.Name), slog.Any("error", err))
+		}
+	}
+}
+
+func toDiscoveryOverride(obj v1alpha1.GhostwireMapping) discovery.Override {
+	override := discovery.Override{
+		Name:           obj.Name,
+		Service:        obj.Spec.Service,
+		PreviewService: obj.Spec.PreviewService,
+		Exclude:        obj.Spec.Exclude,
+	}
+	for _, port := range obj.Spec.Ports {
+		override.Ports = append(override.Ports, discovery.PortOverride{
+			Port:        port.Port,
+			PreviewPort: port.PreviewPort,
+			Protocol:    corev1.Protocol(port.Protocol),
+		})
+	}
+	return override
+}
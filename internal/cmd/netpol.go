@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/netpol"
+)
+
+// NetworkPolicyCmd represents the ghostwire network-policy subcommand.
+var NetworkPolicyCmd = &cobra.Command{
+	Use:   "network-policy",
+	Short: "Print a NetworkPolicy confining preview pods to their preview services",
+	Long: "Network-policy discovers mappings like init does (or reads the DNAT map with " +
+		"--from-dnat-map) and prints an egress NetworkPolicy for pods carrying the preview " +
+		"role label. It allows DNS and the pods behind each mapped preview service, and " +
+		"nothing else, so preview environments cannot reach active dependencies directly.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		dnsSelector, err := labels.ConvertSelectorToLabelsMap(viper.GetString("netpol-dns-selector"))
+		if err != nil {
+			return configError(fmt.Errorf("parse dns selector: %w", err))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		mappings, err := exportMappings(ctx, logger)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := discovery.NewInClusterClient()
+		if err != nil {
+			return kubernetesError(fmt.Errorf("create kubernetes client: %w", err))
+		}
+		targets, skipped, err := netpol.ResolveTargets(ctx, clientset, mappings)
+		if err != nil {
+			return kubernetesError(err)
+		}
+		for _, entry := range skipped {
+			logger.Warn("mapping left out of network policy", slog.String("mapping", entry))
+		}
+
+		policy := netpol.Build(netpol.Options{
+			Name:         strings.TrimSpace(viper.GetString("netpol-name")),
+			Namespace:    initNamespace(),
+			LabelKey:     viper.GetString("role-label-key"),
+			PreviewValue: viper.GetString("role-preview"),
+			DNSNamespace: strings.TrimSpace(viper.GetString("netpol-dns-namespace")),
+			DNSSelector:  dnsSelector,
+		}, targets)
+
+		data, err := yaml.Marshal(policy)
+		if err != nil {
+			return fmt.Errorf("render network policy: %w", err)
+		}
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	},
+}
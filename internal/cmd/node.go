@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/node"
+)
+
+// NodeCmd represents the ghostwire node subcommand.
+var NodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Manage the rules of annotated pods on this node from a privileged DaemonSet",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		nodeName := strings.TrimSpace(viper.GetString("node-name"))
+		if nodeName == "" {
+			nodeName = strings.TrimSpace(os.Getenv("NODE_NAME"))
+		}
+		if nodeName == "" {
+			return configError(errors.New("node-name is required (or NODE_NAME from the downward API)"))
+		}
+
+		_, hooks, err := ruleNames()
+		if err != nil {
+			return err
+		}
+		rules, err := iptablesConfig(logger)
+		if err != nil {
+			return err
+		}
+		backendName, err := ruleBackendName()
+		if err != nil {
+			return err
+		}
+		labelKey := strings.TrimSpace(viper.GetString("role-label-key"))
+		if labelKey == "" {
+			labelKey = config.DefaultRoleLabelKey
+		}
+		previewValue := strings.TrimSpace(viper.GetString("role-preview"))
+		if previewValue == "" {
+			previewValue = config.DefaultRolePreview
+		}
+		interval := viper.GetDuration("node-interval")
+
+		overrides, err := namespaceOverrides()
+		if err != nil {
+			return err
+		}
+
+		nodeLogger := logger.With(
+			slog.String("component", "node"),
+			slog.String("node", nodeName),
+		)
+
+		clientset, err := k8s.NewInClusterClient()
+		if err != nil {
+			return kubernetesError(fmt.Errorf("create kubernetes client: %w", err))
+		}
+
+		manager, err := node.New(node.Config{
+			Client:   clientset,
+			NodeName: nodeName,
+			Discover: func(ctx context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+				return discoverNamespace(ctx, clientset, namespace, overrides[namespace], nodeLogger.With(slog.String("namespace", namespace)))
+			},
+			Netns:        node.ProcResolver{Root: viper.GetString("node-proc-root")},
+			Executor:     node.NewNetnsExecutor(iptables.NewExecutor()),
+			Rules:        rules,
+			Hooks:        hooks,
+			Backend:      backendName,
+			RoleLabelKey: labelKey,
+			PreviewValue: previewValue,
+			Interval:     interval,
+			Logger:       nodeLogger,
+		})
+		if err != nil {
+			return configError(fmt.Errorf("create node manager: %w", err))
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		nodeLogger.Info("node mode started",
+			slog.String("chain", rules.ChainName),
+			slog.Any("jump_hooks", hooks),
+			slog.Duration("interval", interval),
+		)
+
+		if err := manager.Run(ctx); err != nil {
+			nodeLogger.Error("node mode stopped with error", slog.Any("error", err))
+			return err
+		}
+
+		nodeLogger.Info("node mode shutdown complete")
+		return nil
+	},
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// namespaceOverridable lists the settings a config file's namespaces section
+// may override per namespace.
+var namespaceOverridable = map[string]bool{
+	"svc-preview-pattern":      true,
+	"active-suffix":            true,
+	"preview-suffix":           true,
+	"exclude-cidrs":            true,
+	"exclude-service-selector": true,
+	"role-label-key":           true,
+	"role-active":              true,
+	"role-preview":             true,
+}
+
+// namespaceOverrides reads the config file's namespaces section:
+//
+//	namespaces:
+//	  team-a:
+//	    svc-preview-pattern: "{{name}}-canary"
+//	    exclude-cidrs: [10.0.0.0/8]
+//
+// Every section is validated merged over the global settings, so a value that
+// only conflicts once combined with them (say role-preview equal to the global
+// role-active) fails at startup. The result maps namespace to its overrides
+// and is empty when the section is absent.
+func namespaceOverrides() (map[string]map[string]string, error) {
+	raw := viper.Get("namespaces")
+	if raw == nil {
+		return nil, nil
+	}
+	sections, ok := raw.(map[string]any)
+	if !ok {
+		return nil, configError(fmt.Errorf("namespaces: expected a map of namespace to settings, got %T", raw))
+	}
+
+	result := make(map[string]map[string]string, len(sections))
+	for namespace, rawSection := range sections {
+		section, ok := rawSection.(map[string]any)
+		if !ok {
+			return nil, configError(fmt.Errorf("namespaces.%s: expected a map of settings, got %T", namespace, rawSection))
+		}
+
+		overrides := make(map[string]string, len(section))
+		for key, value := range section {
+			if !namespaceOverridable[key] {
+				return nil, configError(fmt.Errorf("namespaces.%s.%s: setting cannot be overridden per namespace (allowed: %s)", namespace, key, strings.Join(overridableKeys(), ", ")))
+			}
+			str, err := overrideValue(value)
+			if err != nil {
+				return nil, configError(fmt.Errorf("namespaces.%s.%s: %w", namespace, key, err))
+			}
+			overrides[key] = str
+		}
+
+		if err := validateNamespaceSettings(overrides); err != nil {
+			return nil, configError(fmt.Errorf("namespaces.%s: %w", namespace, err))
+		}
+		result[namespace] = overrides
+	}
+
+	return result, nil
+}
+
+// applyNamespaceOverrides copies the overrides for namespace into viper, so
+// single-namespace commands read them like any other setting. They win over
+// the global flags, environment and config file; a GhostwireConfig applied
+// afterwards still wins over them.
+func applyNamespaceOverrides(namespace string, logger *slog.Logger) error {
+	all, err := namespaceOverrides()
+	if err != nil {
+		return err
+	}
+	overrides := all[namespace]
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key, value := range overrides {
+		viper.Set(key, value)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	logger.Info("applied namespace overrides", slog.String("namespace", namespace), slog.Any("settings", keys))
+	return nil
+}
+
+// validateNamespaceSettings rejects overrides that are invalid or that
+// conflict with each other or with the global settings they are merged over.
+func validateNamespaceSettings(overrides map[string]string) error {
+	get := func(key string) string {
+		if value, ok := overrides[key]; ok {
+			return value
+		}
+		return viper.GetString(key)
+	}
+
+	if pattern, ok := overrides["svc-preview-pattern"]; ok {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("svc-preview-pattern is empty")
+		}
+		if _, err := discovery.ApplyPattern(pattern, "service"); err != nil {
+			return fmt.Errorf("svc-preview-pattern: %w", err)
+		}
+	}
+	if cidrs, ok := overrides["exclude-cidrs"]; ok {
+		if _, err := config.ParseCIDRList("exclude-cidrs", cidrs, viper.GetBool("strict-parsing")); err != nil {
+			return err
+		}
+	}
+	if key, ok := overrides["role-label-key"]; ok && strings.TrimSpace(key) == "" {
+		return fmt.Errorf("role-label-key is empty")
+	}
+
+	if active, preview := get("role-active"), get("role-preview"); active == preview {
+		return fmt.Errorf("role-active and role-preview are both %q", active)
+	}
+	if active, preview := get("active-suffix"), get("preview-suffix"); active != "" && active == preview {
+		return fmt.Errorf("active-suffix and preview-suffix are both %q", active)
+	}
+	return nil
+}
+
+// overrideValue flattens a config file value to the string form viper holds
+// for the setting; lists become comma-separated.
+func overrideValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("list element %v is not a string", item)
+			}
+			parts = append(parts, str)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("expected a string or list of strings, got %T", value)
+	}
+}
+
+func overridableKeys() []string {
+	keys := make([]string, 0, len(namespaceOverridable))
+	for key := range namespaceOverridable {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
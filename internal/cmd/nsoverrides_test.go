@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestNamespaceOverrides(t *testing.T) {
+	t.Cleanup(func() { viper.Set("namespaces", nil) })
+
+	viper.Set("namespaces", map[string]any{
+		"team-a": map[string]any{
+			"svc-preview-pattern": "{{name}}-canary",
+			"exclude-cidrs":       []any{"10.0.0.0/8", "192.168.0.0/16"},
+		},
+	})
+	overrides, err := namespaceOverrides()
+	if err != nil {
+		t.Fatalf("namespaceOverrides: %v", err)
+	}
+	want := map[string]string{"svc-preview-pattern": "{{name}}-canary", "exclude-cidrs": "10.0.0.0/8,192.168.0.0/16"}
+	if got := overrides["team-a"]; len(got) != len(want) || got["svc-preview-pattern"] != want["svc-preview-pattern"] || got["exclude-cidrs"] != want["exclude-cidrs"] {
+		t.Fatalf("team-a overrides = %v, want %v", got, want)
+	}
+	if cfg := discoveryConfig(nil, "team-a", overrides["team-a"]); cfg.PreviewPattern != "{{name}}-canary" {
+		t.Errorf("PreviewPattern = %q, want the namespace override", cfg.PreviewPattern)
+	}
+
+	invalid := map[string]map[string]any{
+		"unknown key":          {"nat-chain": "OTHER"},
+		"conflicting roles":    {"role-preview": viper.GetString("role-active")},
+		"conflicting suffixes": {"active-suffix": "-x", "preview-suffix": "-x"},
+		"bad cidr":             {"exclude-cidrs": "10.0.0.0/33"},
+		"bad pattern":          {"svc-preview-pattern": "{{name"},
+		"non-string value":     {"role-active": 3},
+	}
+	for name, section := range invalid {
+		viper.Set("namespaces", map[string]any{"team-b": section})
+		if _, err := namespaceOverrides(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// previewHealth is the state of the preview health circuit breaker shown at
+// GET /status.
+type previewHealth struct {
+	CircuitOpen bool      `json:"circuitOpen"`
+	Unhealthy   []string  `json:"unhealthy,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt,omitempty"`
+}
+
+// watchPreviewHealth runs CheckPreviewHealth every interval.
+func (j *jumpManager) watchPreviewHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := j.CheckPreviewHealth(ctx); err != nil {
+			j.logger.Error("preview health check failed", slog.Any("error", err))
+			j.events.Add(eventError, "preview health check failed", "", err)
+		}
+	}
+}
+
+// CheckPreviewHealth opens the circuit breaker when a preview Service of the
+// current mappings has no ready endpoint, removing the jump while a routing
+// role is held, and closes it again once every preview Service has one,
+// restoring the jump. While the breaker is open, routing roles leave the jump
+// off. A failed check leaves the breaker as it was.
+func (j *jumpManager) CheckPreviewHealth(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	unhealthy, err := discovery.UnhealthyPreviews(ctx, j.services, j.mappings, j.namespace)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorPreviewHealth)
+		return fmt.Errorf("check preview endpoints: %w", err)
+	}
+	j.health = previewHealth{CircuitOpen: j.health.CircuitOpen, Unhealthy: unhealthy, CheckedAt: time.Now()}
+
+	open := len(unhealthy) > 0
+	if open == j.health.CircuitOpen {
+		return nil
+	}
+	j.health.CircuitOpen = open
+	j.metrics.SetPreviewCircuitOpen(open)
+
+	role := j.lastStatus.Role
+	action, _ := j.actionFor(role)
+	if open {
+		j.logger.Warn("preview services have no ready endpoints; holding the dnat jump off", slog.Any("unhealthy", unhealthy), slog.String("role", role))
+		j.events.Add(eventCircuit, "circuit opened: no ready endpoints in "+strings.Join(unhealthy, ", "), role, nil)
+	} else {
+		j.logger.Info("preview services ready again; releasing the dnat jump", slog.String("role", role))
+		j.events.Add(eventCircuit, "circuit closed: every preview service has ready endpoints", role, nil)
+	}
+	if action != roleActionRoute {
+		return nil
+	}
+
+	if open {
+		err = j.bypass(ctx, role, role)
+	} else {
+		err = j.route(ctx, role, role)
+	}
+	j.report(ctx, role, err)
+	return err
+}
+
+// PreviewHealth returns the circuit breaker state, or nil when the preview
+// health check is off.
+func (j *jumpManager) PreviewHealth() *previewHealth {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.healthCheck {
+		return nil
+	}
+	health := j.health
+	health.Unhealthy = append([]string(nil), health.Unhealthy...)
+	return &health
+}
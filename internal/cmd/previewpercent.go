@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// preview-percent-from values.
+const (
+	previewPercentFromPod = "pod"
+	previewPercentFromCM  = "configmap/"
+)
+
+// splitConfig builds the traffic split from the preview-percent settings.
+// With preview-percent-from set the split rules are always installed so the
+// watcher can adjust them later.
+func splitConfig() (iptables.SplitConfig, error) {
+	previewPercent := viper.GetInt("preview-percent")
+	if previewPercent < 0 || previewPercent > 100 {
+		return iptables.SplitConfig{}, configError(fmt.Errorf("preview-percent %d is not between 0 and 100", previewPercent))
+	}
+	from := strings.TrimSpace(viper.GetString("preview-percent-from"))
+	if _, err := parsePreviewPercentFrom(from); err != nil {
+		return iptables.SplitConfig{}, configError(err)
+	}
+	split := iptables.SplitConfig{
+		BypassPercent: 100 - previewPercent,
+		StickyWindow:  viper.GetDuration("sticky-window"),
+		Adjustable:    from != "",
+	}
+	if err := split.Validate(); err != nil {
+		return iptables.SplitConfig{}, configError(err)
+	}
+	return split, nil
+}
+
+// parsePreviewPercentFrom validates preview-percent-from: empty, "pod", or
+// "configmap/<name>". It returns the ConfigMap name, if any.
+func parsePreviewPercentFrom(from string) (string, error) {
+	switch {
+	case from == "" || from == previewPercentFromPod:
+		return "", nil
+	case strings.HasPrefix(from, previewPercentFromCM) && len(from) > len(previewPercentFromCM):
+		return strings.TrimPrefix(from, previewPercentFromCM), nil
+	default:
+		return "", fmt.Errorf("invalid preview-percent-from %q (expected %s or %s<name>)", from, previewPercentFromPod, previewPercentFromCM)
+	}
+}
+
+// previewPercentReader returns the reader of the preview-percent annotation,
+// or nil when preview-percent-from is unset.
+func previewPercentReader(client kubernetes.Interface, namespace, podName string) (*k8s.AnnotationReader, error) {
+	from := strings.TrimSpace(viper.GetString("preview-percent-from"))
+	name, err := parsePreviewPercentFrom(from)
+	switch {
+	case err != nil:
+		return nil, err
+	case from == "":
+		return nil, nil
+	case name != "":
+		return k8s.NewConfigMapAnnotationReader(client, namespace, name), nil
+	default:
+		return k8s.NewPodAnnotationReader(client, namespace, podName), nil
+	}
+}
+
+// parsePreviewPercent reads an annotation value such as "25" or "25%".
+func parsePreviewPercent(raw string) (int, error) {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(raw), "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("preview percent %q is not a whole number between 0 and 100", raw)
+	}
+	return percent, nil
+}
+
+// watchPreviewPercent polls the preview-percent annotation every interval and
+// applies changes to the split. Without the annotation the configured
+// preview-percent applies; an invalid value keeps the current split.
+func (j *jumpManager) watchPreviewPercent(ctx context.Context, reader *k8s.AnnotationReader, fallback int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		percent, err := readPreviewPercent(ctx, reader, fallback)
+		if err != nil {
+			j.metrics.IncrementError(metricErrorPreviewPct)
+			j.logger.Warn("keeping current preview percent", slog.String("source", reader.String()), slog.Any("error", err))
+		} else if err := j.SetPreviewPercent(ctx, percent); err != nil {
+			j.logger.Error("failed to update preview percent", slog.Int("preview_percent", percent), slog.Any("error", err))
+			j.events.Add(eventError, "preview percent update failed", "", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readPreviewPercent returns the percentage the annotation asks for, or
+// fallback when it is absent.
+func readPreviewPercent(ctx context.Context, reader *k8s.AnnotationReader, fallback int) (int, error) {
+	raw, ok, err := reader.GetAnnotation(ctx, k8s.AnnotationPreviewPercent)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return fallback, nil
+	}
+	return parsePreviewPercent(raw)
+}
+
+// SetPreviewPercent replaces the split decision rule in the live chain so the
+// given share of new connections reaches preview. Connections already
+// established keep their conntrack entry and are not moved.
+func (j *jumpManager) SetPreviewPercent(ctx context.Context, percent int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	split := j.split
+	split.BypassPercent = 100 - percent
+	if split == j.split {
+		return nil
+	}
+	if err := iptables.UpdateSplit(ctx, j.executor, j.table, j.chain, split, j.ipv6, j.logger); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return err
+	}
+	j.events.Add(eventReconfigure, fmt.Sprintf("preview percent %d -> %d", 100-j.split.BypassPercent, percent), "", nil)
+	j.split = split
+	j.metrics.SetPreviewPercent(percent)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// watchPreviewTTL checks every interval whether the jump has outlived ttl.
+func (j *jumpManager) watchPreviewTTL(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := j.ExpirePreview(ctx, ttl, time.Now()); err != nil {
+			j.logger.Error("failed to revert expired preview routing", slog.Any("error", err))
+			j.events.Add(eventError, "preview expiry failed", "", err)
+		}
+	}
+}
+
+// ExpirePreview reverts to the active role once the jump has been active
+// longer than ttl, reporting whether it did. The poller only re-applies the
+// label's role when the label changes, so routing stays off until the pod is
+// relabelled or a role is pushed through the admin API.
+func (j *jumpManager) ExpirePreview(ctx context.Context, ttl time.Duration, now time.Time) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.jumpActive || j.jumpSince.IsZero() || now.Sub(j.jumpSince) <= ttl {
+		return false, nil
+	}
+
+	previous := j.lastStatus.Role
+	active := now.Sub(j.jumpSince).Round(time.Second)
+	j.logger.Warn("preview routing outlived max-preview-duration; reverting to the active role",
+		slog.String("role", previous),
+		slog.Duration("active_for", active),
+		slog.Duration("max_preview_duration", ttl),
+	)
+	j.metrics.IncrementPreviewExpirations()
+	j.events.Add(eventExpire, fmt.Sprintf("jump active for %s, longer than %s; reverted to %q", active, ttl, j.activeValue), previous, nil)
+
+	err := j.applyTransition(ctx, previous, j.activeValue)
+	j.report(ctx, j.activeValue, err)
+	return err == nil, err
+}
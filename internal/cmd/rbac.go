@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// initPermissions lists the API access init needs with the current settings.
+// Best-effort extras (pairing events, published mappings) are left out since
+// their failures never fail init.
+func initPermissions(namespace string) []k8s.Permission {
+	var permissions []k8s.Permission
+	if name := strings.TrimSpace(viper.GetString("config-name")); name != "" {
+		permissions = append(permissions, k8s.Permission{Verb: "get", Group: v1alpha1.GroupName, Resource: v1alpha1.GhostwireConfigResource.Resource, Namespace: namespace, Name: name})
+	}
+	if name := strings.TrimSpace(viper.GetString("mappings-configmap")); name != "" {
+		return append(permissions, k8s.Permission{Verb: "get", Resource: "configmaps", Namespace: namespace, Name: name})
+	}
+
+	permissions = append(permissions, k8s.Permission{Verb: "list", Resource: "services", Namespace: namespace})
+	if viper.GetBool("mapping-overrides") {
+		permissions = append(permissions, k8s.Permission{Verb: "list", Group: v1alpha1.GroupName, Resource: v1alpha1.GhostwireMappingResource.Resource, Namespace: namespace})
+	}
+	if viper.GetBool("stateful-ordinals") || viper.GetBool("endpoint-dnat") {
+		permissions = append(permissions, k8s.Permission{Verb: "list", Group: "discovery.k8s.io", Resource: "endpointslices", Namespace: namespace})
+	}
+	return permissions
+}
+
+// watcherPermissions lists the API access the watcher needs with the current
+// settings.
+func watcherPermissions(namespace, podName string, driftInterval, chaosInterval time.Duration) []k8s.Permission {
+	permissions := []k8s.Permission{{Verb: "get", Resource: "pods", Namespace: namespace, Name: podName}}
+	if viper.GetBool("status-annotations") {
+		permissions = append(permissions, k8s.Permission{Verb: "patch", Resource: "pods", Namespace: namespace, Name: podName})
+	}
+	if name := strings.TrimSpace(viper.GetString("config-name")); name != "" {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Group: v1alpha1.GroupName, Resource: v1alpha1.GhostwireConfigResource.Resource, Namespace: namespace, Name: name},
+			k8s.Permission{Verb: "watch", Group: v1alpha1.GroupName, Resource: v1alpha1.GhostwireConfigResource.Resource, Namespace: namespace, Name: name},
+		)
+	}
+	if name, err := parsePreviewPercentFrom(strings.TrimSpace(viper.GetString("preview-percent-from"))); err == nil && name != "" {
+		permissions = append(permissions, k8s.Permission{Verb: "get", Resource: "configmaps", Namespace: namespace, Name: name})
+	}
+	if viper.GetBool("preview-health-check") {
+		permissions = append(permissions, k8s.Permission{Verb: "list", Group: "discovery.k8s.io", Resource: "endpointslices", Namespace: namespace})
+	}
+	if driftInterval > 0 {
+		permissions = append(permissions, k8s.Permission{Verb: "get", Resource: "services", Namespace: namespace})
+	}
+	if chaosInterval > 0 {
+		permissions = append(permissions, k8s.Permission{Verb: "get", Resource: "namespaces", Name: namespace})
+	}
+	if strings.TrimSpace(viper.GetString("http-auth")) == httpAuthTokenReview {
+		permissions = append(permissions, k8s.Permission{Verb: "create", Group: "authentication.k8s.io", Resource: "tokenreviews"})
+	}
+	return permissions
+}
+
+// checkRBAC verifies permissions up front so a denial surfaces as one precise
+// "missing RBAC" error instead of a 403 later on. When the reviews themselves
+// cannot be made the check is skipped with a warning; the real calls will
+// report any problem.
+func checkRBAC(ctx context.Context, client kubernetes.Interface, permissions []k8s.Permission, logger *slog.Logger) error {
+	if !viper.GetBool("rbac-check") || len(permissions) == 0 {
+		return nil
+	}
+
+	err := k8s.CheckPermissions(ctx, client, permissions)
+	var missing *k8s.MissingRBACError
+	switch {
+	case err == nil:
+		logger.Debug("rbac self-check passed", slog.Int("permissions", len(permissions)))
+		return nil
+	case errors.As(err, &missing):
+		logger.Error("rbac self-check failed", slog.String("error", err.Error()))
+		return kubernetesError(err)
+	default:
+		logger.Warn("rbac self-check skipped", slog.String("error", err.Error()))
+		return nil
+	}
+}
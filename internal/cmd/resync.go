@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/dns"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// resyncHandler serves POST /admin/resync, re-running discovery and rule
+// application on demand.
+type resyncHandler struct {
+	jm     *jumpManager
+	auth   *adminAuth
+	logger *slog.Logger
+}
+
+func (h *resyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.auth.authorize(w, r) {
+		return
+	}
+
+	h.logger.Info("resync requested via http", slog.String("remote_addr", r.RemoteAddr))
+	ctx, cancel := context.WithTimeout(r.Context(), initTimeout())
+	defer cancel()
+	if err := h.jm.Resync(ctx); err != nil {
+		h.logger.Error("resync failed", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := h.jm.Status()
+	mappings := h.jm.Mappings()
+	if mappings == nil {
+		mappings = []discovery.ServiceMapping{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statusResponse{
+		roleResponse: roleResponse{
+			Role:           status.Role,
+			JumpActive:     status.JumpActive,
+			RuleCount:      status.RuleCount,
+			LastTransition: status.LastTransition,
+			LastError:      status.LastError,
+		},
+		Generation: h.jm.Generation(),
+		Mappings:   mappings,
+	})
+}
+
+// rebuildRules runs init's discovery and rule setup against chain through
+// executor, the watcher's own, so rules are still written once privsep has
+// dropped capabilities or when a rule helper applies them. Notrack rules don't
+// depend on discovery and are left as init built them.
+func rebuildRules(ctx context.Context, executor iptables.Executor, chain string, logger *slog.Logger) ([]discovery.ServiceMapping, error) {
+	namespace := initNamespace()
+	mappings, err := resolveMappings(ctx, namespace, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := iptablesConfig(logger)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ChainName = chain
+	cfg.NotrackCIDRs = nil
+	rules, err := ruleBackend(executor, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.ApplyMappings(ctx, mappings); err != nil {
+		return nil, err
+	}
+
+	if viper.GetBool("dns-mode") {
+		fragmentPath := strings.TrimSpace(viper.GetString("dns-hosts-fragment"))
+		entries := dns.BuildHostsEntries(mappings, namespace, viper.GetString("dns-suffix"))
+		if err := dns.WriteHostsFragment(fragmentPath, entries, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+const maxRoleRequestBytes = 1 << 10
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+type roleResponse struct {
+	Role           string    `json:"role"`
+	JumpActive     bool      `json:"jumpActive"`
+	RuleCount      int       `json:"ruleCount"`
+	LastTransition time.Time `json:"lastTransition,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+}
+
+// statusResponse extends the role response with the mappings recorded in the
+// DNAT map.
+type statusResponse struct {
+	roleResponse
+	Generation    string                     `json:"generation,omitempty"`
+	PreviewHealth *previewHealth             `json:"previewHealth,omitempty"`
+	Mappings      []discovery.ServiceMapping `json:"mappings"`
+}
+
+// statusHandler serves GET /status with the watcher's routing state and the
+// mappings it routes.
+type statusHandler struct {
+	jm *jumpManager
+}
+
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := h.jm.Status()
+	mappings := h.jm.Mappings()
+	if mappings == nil {
+		mappings = []discovery.ServiceMapping{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statusResponse{
+		roleResponse: roleResponse{
+			Role:           status.Role,
+			JumpActive:     status.JumpActive,
+			RuleCount:      status.RuleCount,
+			LastTransition: status.LastTransition,
+			LastError:      status.LastError,
+		},
+		Generation:    h.jm.Generation(),
+		PreviewHealth: h.jm.PreviewHealth(),
+		Mappings:      mappings,
+	})
+}
+
+// roleHandler serves POST /role, applying a pushed role immediately. Polling
+// keeps running as reconciliation, so the label wins again once it changes.
+type roleHandler struct {
+	jm     *jumpManager
+	auth   *adminAuth
+	logger *slog.Logger
+}
+
+// loadTokenFile reads a bearer token from path, such as the one guarding
+// POST /role. It returns "" when no path is configured.
+func loadTokenFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	// #nosec G304 -- token path comes from operator configuration.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file %s: %w", path, err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+func (h *roleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.auth.authorize(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRoleRequestBytes))
+	if err != nil {
+		http.Error(w, "read request body", http.StatusBadRequest)
+		return
+	}
+	var req roleRequest
+	if err := json.Unmarshal(body, &req); err != nil || strings.TrimSpace(req.Role) == "" {
+		http.Error(w, `expected JSON body {"role": "<value>"}`, http.StatusBadRequest)
+		return
+	}
+
+	role := strings.TrimSpace(req.Role)
+	if !h.jm.hasRole(role) {
+		http.Error(w, fmt.Sprintf("unknown role %q", role), http.StatusUnprocessableEntity)
+		return
+	}
+
+	h.logger.Info("role pushed via http", slog.String("role", role), slog.String("remote_addr", r.RemoteAddr))
+	applyErr := h.jm.ForceRole(r.Context(), role)
+
+	status := h.jm.Status()
+	code := http.StatusOK
+	if applyErr != nil {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(roleResponse{
+		Role:           status.Role,
+		JumpActive:     status.JumpActive,
+		RuleCount:      status.RuleCount,
+		LastTransition: status.LastTransition,
+		LastError:      status.LastError,
+	})
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/dns"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// Role actions name what the watcher does while the pod holds a role.
+// role-preview always routes and role-active always bypasses; role-actions
+// attaches an action to further role values, e.g. shadow=mirror.
+const (
+	roleActionRoute  = "route"
+	roleActionBypass = "bypass"
+	roleActionMirror = "mirror"
+)
+
+// roleAction is the behaviour behind an action name. enter runs when the pod
+// takes a role with the action, and again when a refresh re-applies the role;
+// leave, if set, runs first when the pod moves to a role with another action.
+// Both run with the jumpManager's lock held.
+type roleAction struct {
+	enter func(j *jumpManager, ctx context.Context, previous, current string) error
+	leave func(j *jumpManager, ctx context.Context) error
+}
+
+// roleActions registers the available actions by name.
+var roleActions = map[string]roleAction{
+	roleActionRoute:  {enter: (*jumpManager).route},
+	roleActionBypass: {enter: (*jumpManager).bypass},
+	roleActionMirror: {enter: (*jumpManager).mirror, leave: (*jumpManager).stopMirror},
+}
+
+// loadRoleActions reads role-actions into a map of role value to action name
+// and returns the mirror gateway when a role mirrors.
+func loadRoleActions(activeValue, previewValue string) (map[string]string, string, error) {
+	actions, err := parseRoleActions(viper.Get("role-actions"), activeValue, previewValue)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gateway := strings.TrimSpace(viper.GetString("mirror-gateway"))
+	for role, action := range actions {
+		if action != roleActionMirror {
+			continue
+		}
+		if gateway == "" {
+			return nil, "", fmt.Errorf("role-actions: role %q mirrors but mirror-gateway is not set", role)
+		}
+		if net.ParseIP(gateway) == nil {
+			return nil, "", fmt.Errorf("mirror-gateway %q is not an IP address", gateway)
+		}
+		break
+	}
+	return actions, gateway, nil
+}
+
+// parseRoleActions parses role=action pairs, given as a list or a
+// comma-separated string. Roles must be distinct from each other and from the
+// active and preview values, whose actions are fixed.
+func parseRoleActions(raw any, activeValue, previewValue string) (map[string]string, error) {
+	entries, err := config.StringList("role-actions", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		role, action, ok := strings.Cut(entry, "=")
+		role, action = strings.TrimSpace(role), strings.TrimSpace(action)
+		var problem error
+		switch {
+		case !ok || role == "":
+			problem = errors.New("expected role=action")
+		case role == activeValue || role == previewValue:
+			problem = fmt.Errorf("role %q already has a fixed action", role)
+		case actions[role] != "":
+			problem = fmt.Errorf("role %q is listed twice", role)
+		default:
+			if _, known := roleActions[action]; !known {
+				problem = fmt.Errorf("unknown action %q (expected %s)", action, strings.Join(roleActionNames(), ", "))
+			}
+		}
+		if problem != nil {
+			return nil, &config.EntryError{Source: "role-actions", Index: i, Entry: entry, Err: problem}
+		}
+		actions[role] = action
+	}
+	return actions, nil
+}
+
+func roleActionNames() []string {
+	names := make([]string, 0, len(roleActions))
+	for name := range roleActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extraRoles returns the role values of actions in a stable order, for the
+// poller to recognize.
+func extraRoles(actions map[string]string) []string {
+	roles := make([]string, 0, len(actions))
+	for role := range actions {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// actionFor returns the action of role, if it has one.
+func (j *jumpManager) actionFor(role string) (string, bool) {
+	switch role {
+	case "":
+		return "", false
+	case j.previewValue:
+		return roleActionRoute, true
+	case j.activeValue:
+		return roleActionBypass, true
+	}
+	action, ok := j.roleActions[role]
+	return action, ok
+}
+
+// hasRole reports whether role is the active, preview or a role-actions role.
+func (j *jumpManager) hasRole(role string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.actionFor(role)
+	return ok
+}
+
+// routes reports whether role's action routes to the preview Services.
+func (j *jumpManager) routes(role string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	action, _ := j.actionFor(role)
+	return action == roleActionRoute
+}
+
+// route installs the jump so the pod's traffic reaches the preview Services.
+func (j *jumpManager) route(ctx context.Context, previous, current string) error {
+	if j.health.CircuitOpen {
+		j.logger.Warn("preview circuit open; leaving the dnat jump off", slog.String("current_role", current), slog.Any("unhealthy", j.health.Unhealthy))
+		return nil
+	}
+	j.logger.Info("activating dnat jump", slog.String("previous_role", previous), slog.String("current_role", current))
+	if err := j.stopReverse(ctx); err != nil {
+		return err
+	}
+	// The hosts overrides go in before the jump so DNAT is never active
+	// without them.
+	if j.dnsHostsPath != "" {
+		if err := dns.InstallHostsFragment(j.dnsFragment, j.dnsHostsPath); err != nil {
+			j.metrics.IncrementError(metricErrorLabelDNS)
+			return fmt.Errorf("install dns hosts overrides: %w", err)
+		}
+		j.logger.Info("dns hosts overrides installed", slog.String("hosts_path", j.dnsHostsPath))
+	}
+	if err := j.addJumps(ctx, j.table, j.hooks, j.chain); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		if j.dnsHostsPath != "" {
+			if rollbackErr := dns.RemoveHostsBlock(j.dnsHostsPath); rollbackErr != nil {
+				j.logger.Warn("failed to remove dns hosts overrides after jump failure", slog.Any("error", rollbackErr))
+			}
+		}
+		return fmt.Errorf("add jump: %w", err)
+	}
+	if !j.jumpActive {
+		j.jumpSince = time.Now()
+	}
+	j.jumpActive = true
+	j.metrics.SetJumpActive(true)
+	if j.conntrack {
+		if err := j.addJumps(ctx, conntrackTable, j.hooks, j.chain); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("add conntrack jump: %w", err)
+		}
+	}
+	return nil
+}
+
+// bypass removes the jump so the pod's traffic reaches the active Services.
+// In reverse mode it also sends traffic for the preview ClusterIPs back to the
+// active Services.
+func (j *jumpManager) bypass(ctx context.Context, previous, current string) error {
+	j.logger.Info("deactivating dnat jump", slog.String("previous_role", previous), slog.String("current_role", current))
+	if err := j.removeJumps(ctx, j.table, j.hooks, j.chain); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return fmt.Errorf("remove jump: %w", err)
+	}
+	j.jumpActive = false
+	j.metrics.SetJumpActive(false)
+	if j.conntrack {
+		if err := j.removeJumps(ctx, conntrackTable, j.hooks, j.chain); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("remove conntrack jump: %w", err)
+		}
+	}
+	if j.dnsHostsPath != "" {
+		if err := dns.RemoveHostsBlock(j.dnsHostsPath); err != nil {
+			j.metrics.IncrementError(metricErrorLabelDNS)
+			return fmt.Errorf("remove dns hosts overrides: %w", err)
+		}
+		j.logger.Info("dns hosts overrides removed", slog.String("hosts_path", j.dnsHostsPath))
+	}
+	if j.reverse {
+		if _, err := iptables.SetupReverse(ctx, j.executor, j.table, j.chain, j.hooks, j.mappings, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("install reverse dnat: %w", err)
+		}
+		j.reverseActive = true
+	}
+	return nil
+}
+
+// stopReverse removes the reverse DNAT before the pod routes to the preview
+// Services.
+func (j *jumpManager) stopReverse(ctx context.Context) error {
+	if !j.reverseActive {
+		return nil
+	}
+	if err := iptables.RemoveReverse(ctx, j.executor, j.table, j.chain, j.hooks, j.ipv6, j.logger); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return fmt.Errorf("remove reverse dnat: %w", err)
+	}
+	j.reverseActive = false
+	return nil
+}
+
+// mirror bypasses like the active role, then copies the traffic bound for the
+// active Services to the mirror gateway.
+func (j *jumpManager) mirror(ctx context.Context, previous, current string) error {
+	if err := j.bypass(ctx, previous, current); err != nil {
+		return err
+	}
+	if _, err := iptables.SetupMirror(ctx, j.executor, j.chain, j.hooks, j.mirrorGateway, j.mappings, j.ipv6, j.logger); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return fmt.Errorf("install traffic mirror: %w", err)
+	}
+	j.mirrorActive = true
+	return nil
+}
+
+// stopMirror removes the traffic mirror when the pod leaves a mirroring role.
+func (j *jumpManager) stopMirror(ctx context.Context) error {
+	if !j.mirrorActive {
+		return nil
+	}
+	if err := iptables.RemoveMirror(ctx, j.executor, j.chain, j.hooks, j.ipv6, j.logger); err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return fmt.Errorf("remove traffic mirror: %w", err)
+	}
+	j.mirrorActive = false
+	return nil
+}
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/denniswebb/ghostwire/internal/config"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
 	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/output"
 )
 
 var (
@@ -25,52 +32,197 @@ It does this at L4 with DNAT rules. No app code changes, no mesh dependency, no
 		viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 		viper.AutomaticEnv()
 
+		if err := bindSettingFlags(cmd); err != nil {
+			return err
+		}
+
 		if cfgFile != "" {
 			viper.SetConfigFile(cfgFile)
 			if err := viper.ReadInConfig(); err != nil {
-				return fmt.Errorf("failed to read config file: %w", err)
+				return configError(fmt.Errorf("failed to read config file: %w", err))
 			}
 		}
 
 		logging.InitLogger(viper.GetString("log-level"), "ghostwire")
+		if err := validateDurationSettings(cmd); err != nil {
+			return err
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return configError(err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return configError(err)
+		}
 		return nil
 	},
 }
 
+// outputFormat returns the validated --output format.
+func outputFormat() (output.Format, error) {
+	format, err := output.ParseFormat(viper.GetString("output"))
+	if err != nil {
+		return "", configError(err)
+	}
+	return format, nil
+}
+
+// ruleNames returns the configured DNAT chain and jump hooks, defaulting to
+// CANARY_DNAT and OUTPUT. jump-hook is a list (a YAML list or
+// comma-separated) for pods that need the jump in both OUTPUT and PREROUTING;
+// duplicates are dropped. Names are spliced into iptables argv, so names
+// outside the safe charset are rejected here rather than at the first rule.
+func ruleNames() (chain string, hooks []string, err error) {
+	chain = strings.TrimSpace(configString("nat-chain"))
+	if chain == "" {
+		chain = config.DefaultNATChain
+	}
+	if err := iptables.ValidateChainName(chain); err != nil {
+		return "", nil, configError(fmt.Errorf("GW_NAT_CHAIN: %w", err))
+	}
+
+	entries, err := config.StringList("jump-hook", configValue("jump-hook"))
+	if err != nil {
+		return "", nil, configError(fmt.Errorf("GW_JUMP_HOOK: %w", err))
+	}
+	for _, hook := range entries {
+		if hook == "" || slices.Contains(hooks, hook) {
+			continue
+		}
+		if err := iptables.ValidateHookName(hook); err != nil {
+			return "", nil, configError(fmt.Errorf("GW_JUMP_HOOK: %w", err))
+		}
+		hooks = append(hooks, hook)
+	}
+	if len(hooks) == 0 {
+		hooks = []string{config.DefaultJumpHook}
+	}
+	return chain, hooks, nil
+}
+
+// loadDNATMap reads the dnat.map at path, failing on malformed entries instead
+// of skipping them when strict parsing is enabled. With an HMAC key configured
+// the map is only returned once its signature checks out.
+func loadDNATMap(path string) ([]discovery.ServiceMapping, error) {
+	key, err := dnatMapKey()
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		return iptables.LoadSignedDNATMap(path, key, viper.GetBool("strict-parsing"))
+	}
+	if viper.GetBool("strict-parsing") {
+		return iptables.LoadDNATMapStrict(path)
+	}
+	return iptables.LoadDNATMap(path)
+}
+
+// writeDNATMap rewrites the dnat.map at path, signing it when an HMAC key is
+// configured.
+func writeDNATMap(path string, mappings []discovery.ServiceMapping, logger *slog.Logger) error {
+	key, err := dnatMapKey()
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		return iptables.WriteSignedDNATMap(path, mappings, key, logger)
+	}
+	return iptables.WriteDNATMap(path, mappings, logger)
+}
+
+// dnatMapKey reads the dnat.map HMAC key from dnat-map-hmac-key-file. It is
+// read on every use so a rotated Secret takes effect without a restart, and
+// is nil when no key file is configured.
+func dnatMapKey() ([]byte, error) {
+	path := strings.TrimSpace(viper.GetString("dnat-map-hmac-key-file"))
+	if path == "" {
+		return nil, nil
+	}
+
+	// #nosec G304 -- key path comes from operator configuration.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, configError(fmt.Errorf("read dnat map hmac key %s: %w", path, err))
+	}
+	key := bytes.TrimSpace(raw)
+	if len(key) == 0 {
+		return nil, configError(fmt.Errorf("dnat map hmac key file %s is empty", path))
+	}
+	return key, nil
+}
+
 // Execute runs the root command.
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return configError(err)
+	})
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Path to configuration file")
-	rootCmd.PersistentFlags().String("log-level", "info", "Log level (debug, info, warn, error)")
-	rootCmd.PersistentFlags().String("iptables-dnat-map", "/shared/dnat.map", "Path to write the DNAT map artifact")
+	addFlag(rootCmd.PersistentFlags(), "log-level", "log-level", "Log level (debug, info, warn, error)")
+	addFlag(rootCmd.PersistentFlags(), "iptables-dnat-map", "iptables-dnat-map", "Path to write the DNAT map artifact")
+	rootCmd.PersistentFlags().StringP("output", "o", config.DefaultOutput, "Result format for reporting commands (table, json, yaml)")
+	markSetting(rootCmd.PersistentFlags(), "output", "output")
 
-	if err := viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level")); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to bind log-level flag: %v\n", err)
-		os.Exit(1)
+	config.SetDefaults()
+	registerSettings(discoverySettings, InitCmd, WatcherCmd, RunCmd, ExportRulesCmd, ExplainCmd, NetworkPolicyCmd, ControllerCmd, NodeCmd)
+	registerSettings(ruleSettings, InitCmd, WatcherCmd, RunCmd, ExportRulesCmd, ExplainCmd, VerifyCmd, TraceCmd, NodeCmd)
+	registerSettings(dnsSettings, InitCmd, WatcherCmd, RunCmd)
+	registerSettings(watcherSettings, WatcherCmd, RunCmd)
+	registerSettings(dnsProxySettings, DNSProxyCmd)
+	registerSettings(controllerSettings, ControllerCmd)
+	registerSettings(nodeSettings, NodeCmd)
+	registerSettings(injectorSettings, InjectorCmd)
+	registerSettings(tlsSettings, WatcherCmd, RunCmd, InjectorCmd)
+	addSettingFlags(DNSProxyCmd, "namespace", "role-label-key", "role-active", "role-preview", "poll-interval", "dns-suffix")
+	addSettingFlags(NetworkPolicyCmd, "role-label-key", "role-preview")
+	addSettingFlags(NodeCmd, "role-label-key", "role-preview")
+	addSettingFlags(SelftestCmd, "ipv6")
+	addSettingFlags(RuleHelperCmd, "helper-socket")
+
+	for _, cmd := range []*cobra.Command{InitCmd, RunCmd} {
+		addFlag(cmd.Flags(), "mappings-file", "mappings-file", "YAML or JSON file of static service mappings merged over discovered ones")
 	}
-	if err := viper.BindPFlag("iptables-dnat-map", rootCmd.PersistentFlags().Lookup("iptables-dnat-map")); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to bind iptables-dnat-map flag: %v\n", err)
+	addFlag(InitCmd.Flags(), "skip-apply", "skip-apply", "Discover and write the DNAT map (and --restore-file) without executing iptables")
+	addFlag(InitCmd.Flags(), "restore-file", "restore-file", "With --skip-apply, write the rules in iptables-restore format to this path")
+
+	addFlag(ExportRulesCmd.Flags(), "from-dnat-map", "from-dnat-map", "Export rules for the mappings in the DNAT map instead of discovering services")
+	addFlag(ExportRulesCmd.Flags(), "file", "export-file", "Write the IPv4 rules to this path (and IPv6 rules to <path>.v6) instead of stdout")
+	addFlag(ExportRulesCmd.Flags(), "format", "export-format", "Ruleset syntax: iptables (iptables-save) or nft (nft -f)")
+
+	addFlag(NetworkPolicyCmd.Flags(), "from-dnat-map", "from-dnat-map", "Build the policy from the mappings in the DNAT map instead of discovering services")
+	addFlag(NetworkPolicyCmd.Flags(), "name", "netpol-name", "Name of the generated NetworkPolicy")
+	addFlag(NetworkPolicyCmd.Flags(), "dns-namespace", "netpol-dns-namespace", "Namespace of the cluster DNS pods")
+	addFlag(NetworkPolicyCmd.Flags(), "dns-selector", "netpol-dns-selector", "Label selector of the cluster DNS pods")
+
+	addFlag(TraceCmd.Flags(), "protocol", "trace-protocol", "Protocol of the traced connection (tcp, udp, sctp)")
+	addFlag(TraceCmd.Flags(), "source", "trace-source", "Rules to trace: live, dnat-map, or auto (live, falling back to the DNAT map)")
+
+	addFlag(WatcherCmd.Flags(), "chaos-flap-interval", "chaos-flap-interval", "Flip the jump at random every interval to 2x interval (game days only; refused in production namespaces)")
+	if err := WatcherCmd.Flags().MarkHidden("chaos-flap-interval"); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hide chaos-flap-interval flag: %v\n", err)
 		os.Exit(1)
 	}
 
-	viper.SetDefault("namespace", "default")
-	viper.SetDefault("svc-preview-pattern", "{{name}}-preview")
-	viper.SetDefault("active-suffix", "-active")
-	viper.SetDefault("preview-suffix", "-preview")
-	viper.SetDefault("nat-chain", "CANARY_DNAT")
-	viper.SetDefault("exclude-cidrs", "169.254.169.254/32,10.96.0.10/32")
-	viper.SetDefault("ipv6", false)
-	viper.SetDefault("jump-hook", "OUTPUT")
-	viper.SetDefault("iptables-dnat-map", "/shared/dnat.map")
-	viper.SetDefault("role-label-key", "role")
-	viper.SetDefault("role-active", "active")
-	viper.SetDefault("role-preview", "preview")
-	viper.SetDefault("poll-interval", "2s")
+	for _, cmd := range []*cobra.Command{WatcherCmd, RunCmd} {
+		addFlag(cmd.Flags(), "runtime-metrics", "runtime-metrics", "Also export Go runtime and process metrics on /metrics")
+	}
 
 	rootCmd.AddCommand(InitCmd)
 	rootCmd.AddCommand(WatcherCmd)
+	rootCmd.AddCommand(RunCmd)
+	rootCmd.AddCommand(SelftestCmd)
+	rootCmd.AddCommand(VerifyCmd)
+	rootCmd.AddCommand(ExportRulesCmd)
+	rootCmd.AddCommand(NetworkPolicyCmd)
+	rootCmd.AddCommand(ExplainCmd)
+	rootCmd.AddCommand(TraceCmd)
 	rootCmd.AddCommand(InjectorCmd)
+	rootCmd.AddCommand(DNSProxyCmd)
+	rootCmd.AddCommand(ControllerCmd)
+	rootCmd.AddCommand(NodeCmd)
+	rootCmd.AddCommand(HelperCmd)
+	rootCmd.AddCommand(RuleHelperCmd)
 }
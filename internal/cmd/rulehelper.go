@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/rulehelper"
+)
+
+// RuleHelperCmd runs the privileged daemon that performs rule operations for
+// a watcher holding no capabilities.
+var RuleHelperCmd = &cobra.Command{
+	Use:   "rule-helper",
+	Short: "Serve jump and chain operations to the watcher over a unix socket",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		socket := strings.TrimSpace(viper.GetString("helper-socket"))
+		if socket == "" {
+			socket = defaultHelperSocket
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		server := rulehelper.NewServer(logger.With(slog.String("component", "rule-helper")))
+		return server.ListenAndServe(ctx, socket)
+	},
+}
+
+// defaultHelperSocket is where rule-helper listens when GW_HELPER_SOCKET is
+// unset. The watcher only uses the helper when GW_HELPER_SOCKET is set.
+const defaultHelperSocket = "/shared/rule-helper.sock"
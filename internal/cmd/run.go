@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"github.com/denniswebb/ghostwire/internal/logging"
+)
+
+// RunCmd represents the ghostwire run subcommand, which performs the init
+// phase and then becomes the watcher in a single process.
+var RunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Build DNAT rules, then poll pod labels and toggle the jump",
+	Long: "Run performs the init phase (chain, exclusions, rules, dnat.map) and then " +
+		"transitions into the watcher loop, for pods that cannot use an init container. " +
+		"The health endpoints only start once setup has completed, so readiness is " +
+		"gated on it. The process needs NET_ADMIN for its whole lifetime.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		logger.Info("run: starting init phase")
+		if err := runInit(); err != nil {
+			return err
+		}
+
+		logger.Info("run: init phase complete, starting watcher")
+		return runWatcher()
+	},
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+	"github.com/denniswebb/ghostwire/internal/schedule"
+)
+
+// loadRouteSchedule parses route-schedule in route-schedule-timezone, or
+// returns nil when no schedule is set.
+func loadRouteSchedule() (*schedule.Schedule, error) {
+	raw := strings.TrimSpace(viper.GetString("route-schedule"))
+	if raw == "" {
+		return nil, nil
+	}
+	return schedule.Parse(raw, viper.GetString("route-schedule-timezone"))
+}
+
+// scheduleLabelReader holds the pod on the active role outside the routing
+// schedule: any other role label reads as the active value, so the poller
+// reverts routing when the window closes and follows the label again once it
+// opens.
+type scheduleLabelReader struct {
+	delegate   k8s.LabelReader
+	schedule   *schedule.Schedule
+	activeRole func() string
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+	now        func() time.Time
+	overriding bool
+}
+
+func (s *scheduleLabelReader) GetLabel(ctx context.Context, labelKey string) (string, error) {
+	value, err := s.delegate.GetLabel(ctx, labelKey)
+	if err != nil {
+		return "", err
+	}
+
+	active := s.activeRole()
+	override := value != "" && value != active && !s.schedule.Active(s.now())
+	if override != s.overriding {
+		if override {
+			s.logger.Warn("outside the routing schedule; holding the active role against the label",
+				slog.String("label", value),
+				slog.String("schedule", s.schedule.String()),
+				slog.String("timezone", s.schedule.Location().String()),
+			)
+		} else {
+			s.logger.Info("routing schedule no longer overrides the label", slog.String("label", value))
+		}
+		s.overriding = override
+		s.metrics.SetScheduleOverride(override)
+	}
+	if override {
+		return active, nil
+	}
+	return value, nil
+}
+
+// Terminating forwards the delegate's termination state.
+func (s *scheduleLabelReader) Terminating() bool {
+	reader, ok := s.delegate.(k8s.TerminationReader)
+	return ok && reader.Terminating()
+}
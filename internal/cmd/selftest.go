@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/output"
+	"github.com/denniswebb/ghostwire/internal/selftest"
+)
+
+// SelftestCmd represents the ghostwire selftest subcommand.
+var SelftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Verify DNAT redirection end to end in a scratch network namespace",
+	Long: "Selftest creates a throwaway network namespace, installs a chain with an exclusion, " +
+		"DNAT rules and the jump, sends test connections and checks they are redirected, then " +
+		"deletes the namespace. Run it on a node (as root) to confirm the kernel and iptables " +
+		"variant work before rollout.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		report, err := selftest.Run(ctx, iptables.NewExecutor("ip"), viper.GetBool("ipv6"), logger)
+		if err != nil {
+			return err
+		}
+
+		if err := output.Write(cmd.OutOrStdout(), format, selftestResult{Report: report, Passed: report.Passed()}); err != nil {
+			return err
+		}
+		if !report.Passed() {
+			return iptablesError(fmt.Errorf("selftest failed"))
+		}
+		return nil
+	},
+}
+
+// selftestResult is the result printed by `ghostwire selftest`.
+type selftestResult struct {
+	selftest.Report
+	Passed bool `json:"passed"`
+}
+
+// WriteTable prints one PASS/FAIL row per check and a closing verdict.
+func (r selftestResult) WriteTable(w io.Writer) error {
+	fmt.Fprintf(w, "selftest namespace %s\n", r.Namespace)
+	table := output.NewTable(w, "status", "check", "detail")
+	for _, check := range r.Checks {
+		status := "PASS"
+		if !check.OK {
+			status = "FAIL"
+		}
+		table.Row(status, check.Name, check.Detail)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if r.Passed {
+		_, err := fmt.Fprintln(w, "selftest passed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// Built-in mapping sources. Each wraps its errors with the exit code init
+// has always used for it.
+func init() {
+	discovery.RegisterSource("services", func(namespace string, logger *slog.Logger) (discovery.MappingSource, error) {
+		return discovery.SourceFunc{SourceName: "services", ListFunc: func(ctx context.Context) ([]discovery.ServiceMapping, error) {
+			backoff := apiBackoff()
+			var clientset *kubernetes.Clientset
+			err := k8s.RetryAPI(ctx, backoff, logger, "create kubernetes client", func(context.Context) error {
+				var err error
+				clientset, err = discovery.NewInClusterClient()
+				return err
+			})
+			if err != nil {
+				logger.Error("failed to create kubernetes client", slog.String("error", err.Error()))
+				return nil, kubernetesError(err)
+			}
+
+			var mappings []discovery.ServiceMapping
+			err = k8s.RetryAPI(ctx, backoff, logger, "discover services", func(ctx context.Context) error {
+				var err error
+				mappings, err = discoverNamespace(ctx, clientset, namespace, nil, logger)
+				return err
+			})
+			if err != nil {
+				return nil, kubernetesError(err)
+			}
+			return mappings, nil
+		}}, nil
+	})
+
+	discovery.RegisterSource("configmap", func(namespace string, logger *slog.Logger) (discovery.MappingSource, error) {
+		name := strings.TrimSpace(viper.GetString("mappings-configmap"))
+		if name == "" {
+			name = defaultMappingsConfigMap
+		}
+		return discovery.SourceFunc{SourceName: "configmap", ListFunc: func(ctx context.Context) ([]discovery.ServiceMapping, error) {
+			var mappings []discovery.ServiceMapping
+			err := k8s.RetryAPI(ctx, apiBackoff(), logger, "load controller mappings", func(ctx context.Context) error {
+				var err error
+				mappings, err = loadControllerMappings(ctx, namespace, name)
+				return err
+			})
+			if err != nil {
+				logger.Error("failed to load controller mappings", slog.String("configmap", name), slog.String("error", err.Error()))
+				return nil, kubernetesError(err)
+			}
+			return mappings, nil
+		}}, nil
+	})
+
+	discovery.RegisterSource("file", func(_ string, logger *slog.Logger) (discovery.MappingSource, error) {
+		path := strings.TrimSpace(viper.GetString("mappings-file"))
+		if path == "" {
+			return nil, configError(errors.New("mapping source file needs mappings-file"))
+		}
+		file := discovery.FileSource{Path: path}
+		return discovery.SourceFunc{SourceName: "file", ListFunc: func(ctx context.Context) ([]discovery.ServiceMapping, error) {
+			mappings, err := file.List(ctx)
+			if err != nil {
+				logger.Error("failed to load mappings file", slog.String("path", path), slog.String("error", err.Error()))
+				return nil, configError(err)
+			}
+			return mappings, nil
+		}}, nil
+	})
+}
+
+// mappingSourceNames returns the configured sources, lowest precedence first.
+// Without mapping-sources the older settings decide: the controller ConfigMap
+// when mappings-configmap is set and discovery otherwise, merged with the
+// mappings file on the side mappings-file-precedence names.
+func mappingSourceNames() ([]string, error) {
+	if names := splitList(viper.GetString("mapping-sources")); len(names) > 0 {
+		return names, nil
+	}
+
+	base := "services"
+	if strings.TrimSpace(viper.GetString("mappings-configmap")) != "" {
+		base = "configmap"
+	}
+	if strings.TrimSpace(viper.GetString("mappings-file")) == "" {
+		return []string{base}, nil
+	}
+	switch precedence := strings.TrimSpace(viper.GetString("mappings-file-precedence")); precedence {
+	case "", discovery.StaticPrecedenceFile:
+		return []string{base, "file"}, nil
+	case discovery.StaticPrecedenceDiscovery:
+		return []string{"file", base}, nil
+	default:
+		return nil, configError(fmt.Errorf("unsupported mappings file precedence %q (expected %s or %s)", precedence, discovery.StaticPrecedenceFile, discovery.StaticPrecedenceDiscovery))
+	}
+}
+
+// mappingSource builds the union of the configured sources for namespace.
+func mappingSource(namespace string, logger *slog.Logger) (discovery.MappingSource, error) {
+	names, err := mappingSourceNames()
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]discovery.MappingSource, 0, len(names))
+	for _, name := range names {
+		source, err := discovery.NewSource(name, namespace, logger)
+		if err != nil {
+			return nil, configError(err)
+		}
+		sources = append(sources, source)
+	}
+	return discovery.Union(logger, sources...), nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMappingSourceNames(t *testing.T) {
+	keys := []string{"mapping-sources", "mappings-configmap", "mappings-file", "mappings-file-precedence"}
+	t.Cleanup(func() {
+		for _, key := range keys {
+			viper.Set(key, nil)
+		}
+	})
+
+	tests := []struct {
+		settings map[string]string
+		want     string
+		wantErr  string
+	}{
+		{want: "services"},
+		{settings: map[string]string{"mappings-configmap": "ghostwire-mappings"}, want: "configmap"},
+		{settings: map[string]string{"mappings-file": "/etc/ghostwire/mappings.yaml"}, want: "services,file"},
+		{settings: map[string]string{"mappings-file": "/etc/ghostwire/mappings.yaml", "mappings-file-precedence": "discovery"}, want: "file,services"},
+		{settings: map[string]string{"mappings-file": "/etc/ghostwire/mappings.yaml", "mappings-file-precedence": "newest"}, wantErr: "unsupported mappings file precedence"},
+		{settings: map[string]string{"mapping-sources": "file, configmap", "mappings-file": "/etc/ghostwire/mappings.yaml"}, want: "file,configmap"},
+	}
+	for _, tt := range tests {
+		for _, key := range keys {
+			viper.Set(key, tt.settings[key])
+		}
+		names, err := mappingSourceNames()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || ExitCode(err) != ExitConfig {
+				t.Errorf("%v: error = %v, want a config error containing %q", tt.settings, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(names, ",") != tt.want {
+			t.Errorf("%v: got %v (err %v), want %s", tt.settings, names, err, tt.want)
+		}
+	}
+
+	viper.Set("mapping-sources", "services,argo")
+	if _, err := mappingSource("shop", slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))); err == nil || !strings.Contains(err.Error(), `unknown mapping source "argo"`) {
+		t.Fatalf("expected an unknown source error, got %v", err)
+	}
+}
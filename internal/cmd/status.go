@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+	"github.com/denniswebb/ghostwire/internal/metrics"
+)
+
+// routingStatus is the watcher's view of routing after a role transition.
+type routingStatus struct {
+	Role           string
+	NATChain       string
+	JumpHook       string
+	JumpActive     bool
+	LastTransition time.Time
+	RuleCount      int
+	LastError      string
+}
+
+// statusReporter publishes routing status somewhere visible to operators.
+// Reporting is best effort: implementations log their own failures so a
+// status write never fails a transition.
+type statusReporter interface {
+	Report(ctx context.Context, status routingStatus)
+}
+
+// healthReporter feeds the jump readiness signal. A transition that completed
+// without error leaves the jump in the state its role asks for.
+type healthReporter struct {
+	health *metrics.HealthChecker
+}
+
+func (r *healthReporter) Report(_ context.Context, status routingStatus) {
+	r.health.SetJumpMatchesRole(status.LastError == "")
+}
+
+// podAnnotationReporter mirrors routing status onto the watcher's own Pod.
+type podAnnotationReporter struct {
+	annotator *k8s.PodAnnotator
+	logger    *slog.Logger
+}
+
+func (r *podAnnotationReporter) Report(ctx context.Context, status routingStatus) {
+	role := status.Role
+	jumpActive := strconv.FormatBool(status.JumpActive)
+	lastTransition := status.LastTransition.UTC().Format(time.RFC3339)
+	ruleCount := strconv.Itoa(status.RuleCount)
+
+	annotations := map[string]*string{
+		k8s.AnnotationRole:           &role,
+		k8s.AnnotationJumpActive:     &jumpActive,
+		k8s.AnnotationLastTransition: &lastTransition,
+		k8s.AnnotationRuleCount:      &ruleCount,
+		k8s.AnnotationLastError:      nil,
+	}
+	if status.LastError != "" {
+		lastError := status.LastError
+		annotations[k8s.AnnotationLastError] = &lastError
+	}
+
+	if err := r.annotator.Annotate(ctx, annotations); err != nil {
+		r.logger.Warn("failed to publish status annotations", slog.Any("error", err))
+	}
+}
+
+// statusResourceReporter writes routing status to the pod's GhostwireStatus.
+type statusResourceReporter struct {
+	writer *k8s.StatusWriter
+	logger *slog.Logger
+}
+
+func (r *statusResourceReporter) Report(ctx context.Context, status routingStatus) {
+	state := v1alpha1.RoutingState{
+		Role:               status.Role,
+		JumpActive:         status.JumpActive,
+		RuleCount:          int32(status.RuleCount), // #nosec G115 -- rule counts are far below int32 range.
+		NATChain:           status.NATChain,
+		JumpHook:           status.JumpHook,
+		LastTransitionTime: metav1.NewTime(status.LastTransition),
+		LastError:          status.LastError,
+	}
+	if err := r.writer.Write(ctx, state); err != nil {
+		r.logger.Warn("failed to publish ghostwirestatus", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/logging"
+	"github.com/denniswebb/ghostwire/internal/output"
+)
+
+// Trace sources selectable with --source.
+const (
+	traceSourceAuto    = "auto"
+	traceSourceLive    = "live"
+	traceSourceDNATMap = "dnat-map"
+)
+
+// TraceCmd represents the ghostwire trace subcommand.
+var TraceCmd = &cobra.Command{
+	Use:   "trace <ip:port>",
+	Short: "Simulate the DNAT chain for a connection to ip:port",
+	Long: "Trace walks the DNAT chain in order for a connection from this pod to ip:port and reports " +
+		"which exclusion or DNAT rule matches, the resulting destination, and whether the jump is " +
+		"currently installed. It reads the live chain, falling back to the rules init would build " +
+		"from the DNAT map when the chain cannot be listed (--source selects one explicitly).",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		ip, port, err := parseTraceDestination(args[0])
+		if err != nil {
+			return configError(err)
+		}
+		protocol := strings.ToLower(strings.TrimSpace(viper.GetString("trace-protocol")))
+		if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+			return configError(fmt.Errorf("unsupported protocol %q (want tcp, udp or sctp)", protocol))
+		}
+		source := strings.TrimSpace(viper.GetString("trace-source"))
+		if source != traceSourceAuto && source != traceSourceLive && source != traceSourceDNATMap {
+			return configError(fmt.Errorf("unsupported trace source %q (want auto, live or dnat-map)", source))
+		}
+
+		logger := logging.GetLogger()
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		chain, hooks, err := ruleNames()
+		if err != nil {
+			return err
+		}
+		// Traced connections are opened locally, so they take OUTPUT when the
+		// jump is installed there.
+		hook := hooks[0]
+		if slices.Contains(hooks, "OUTPUT") {
+			hook = "OUTPUT"
+		}
+
+		if source != traceSourceDNATMap {
+			result, err := iptables.Trace(ctx, iptables.NewExecutor(), "nat", hook, chain, ip, port, protocol)
+			if err == nil {
+				return output.Write(cmd.OutOrStdout(), format, traceResult(result))
+			}
+			if source == traceSourceLive {
+				return iptablesError(err)
+			}
+			logger.Warn("cannot read live rules; tracing the rules built from the dnat map", slog.String("error", err.Error()))
+		}
+
+		result, err := traceDNATMap(ctx, chain, ip, port, protocol, logger)
+		if err != nil {
+			return err
+		}
+		return output.Write(cmd.OutOrStdout(), format, traceResult(result))
+	},
+}
+
+// parseTraceDestination splits "ip:port" (or "[ipv6]:port").
+func parseTraceDestination(raw string) (net.IP, int, error) {
+	host, portRaw, err := net.SplitHostPort(raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse destination %q: %w", raw, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("parse destination %q: %q is not an IP address", raw, host)
+	}
+	port, err := strconv.Atoi(portRaw)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, 0, fmt.Errorf("parse destination %q: invalid port %q", raw, portRaw)
+	}
+	return ip, port, nil
+}
+
+// traceDNATMap rebuilds the chain init would install from the DNAT map and
+// traces it. Whether the jump is installed is unknown in this mode.
+func traceDNATMap(ctx context.Context, chain string, ip net.IP, port int, protocol string, logger *slog.Logger) (iptables.TraceResult, error) {
+	path := viper.GetString("iptables-dnat-map")
+	mappings, err := loadDNATMap(path)
+	if err != nil {
+		return iptables.TraceResult{}, configError(fmt.Errorf("read dnat map %s: %w", path, err))
+	}
+
+	cfg, err := iptablesConfig(logger)
+	if err != nil {
+		return iptables.TraceResult{}, err
+	}
+	cfg.DnatMapPath = ""
+	recorder := iptables.NewRestoreRecorder()
+	if err := iptables.SetupWithExecutor(ctx, recorder, cfg, mappings, slog.New(slog.DiscardHandler)); err != nil {
+		return iptables.TraceResult{}, iptablesError(err)
+	}
+
+	binary := "iptables"
+	if ip.To4() == nil {
+		binary = "ip6tables"
+	}
+	var rules []string
+	for _, rule := range recorder.Rules(binary, "nat") {
+		if strings.HasPrefix(rule, "-A "+chain+" ") {
+			rules = append(rules, rule)
+		}
+	}
+
+	result := iptables.EvaluateTrace(chain, rules, ip, port, protocol)
+	result.Source = traceSourceDNATMap
+	return result, nil
+}
+
+// traceResult renders an iptables.TraceResult as a table.
+type traceResult iptables.TraceResult
+
+// WriteTable prints the verdict followed by each evaluated rule.
+func (r traceResult) WriteTable(w io.Writer) error {
+	fmt.Fprintf(w, "destination: %s/%s\n", r.Destination, r.Protocol)
+	fmt.Fprintf(w, "chain:       %s (rules from %s)\n", r.Chain, r.Source)
+	fmt.Fprintf(w, "jump:        %s\n", r.Jump)
+	switch r.Verdict {
+	case iptables.TraceDNAT:
+		fmt.Fprintf(w, "verdict:     redirected to %s\n", r.Target)
+	case iptables.TraceExcluded:
+		fmt.Fprintln(w, "verdict:     excluded; the connection keeps its destination")
+	default:
+		fmt.Fprintln(w, "verdict:     no rule matched; the connection keeps its destination")
+	}
+	if r.Verdict != iptables.TraceNoMatch && r.Jump == iptables.TraceJumpInactive {
+		fmt.Fprintln(w, "             (the jump is not installed, so this chain is not evaluated right now)")
+	}
+
+	fmt.Fprintln(w)
+	table := output.NewTable(w, "match", "rule", "note")
+	for _, step := range r.Steps {
+		match := "no"
+		if step.Matched {
+			match = "yes"
+		}
+		table.Row(match, step.Rule, step.Note)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/output"
+)
+
+// verifyReport is the result printed by `ghostwire verify`.
+type verifyReport struct {
+	Chain         string                 `json:"chain"`
+	Mappings      int                    `json:"mappings"`
+	OK            bool                   `json:"ok"`
+	Discrepancies []iptables.Discrepancy `json:"discrepancies"`
+}
+
+// VerifyCmd represents the ghostwire verify subcommand.
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compare dnat.map against the live DNAT chain",
+	Long: "Verify parses the DNAT map written by init, lists the live DNAT chain and reports rules " +
+		"that are missing, extra, or point at the wrong preview address. It exits 6 when the two " +
+		"disagree, so it can back a readiness probe exec or a cronjob.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		dnatMapPath := viper.GetString("iptables-dnat-map")
+		mappings, err := loadDNATMap(dnatMapPath)
+		if err != nil {
+			return configError(fmt.Errorf("read dnat map %s: %w", dnatMapPath, err))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		chain, _, err := ruleNames()
+		if err != nil {
+			return err
+		}
+		rules, err := ruleBackend(iptables.NewExecutor(), iptables.Config{ChainName: chain, IPv6: viper.GetBool("ipv6")}, nil)
+		if err != nil {
+			return err
+		}
+		discrepancies, err := rules.Verify(ctx, mappings)
+		if err != nil {
+			return iptablesError(err)
+		}
+
+		report := verifyReport{
+			Chain:         chain,
+			Mappings:      len(mappings),
+			OK:            len(discrepancies) == 0,
+			Discrepancies: discrepancies,
+		}
+		if report.Discrepancies == nil {
+			report.Discrepancies = []iptables.Discrepancy{}
+		}
+
+		if err := output.Write(cmd.OutOrStdout(), format, report); err != nil {
+			return err
+		}
+		if !report.OK {
+			return &ExitError{Code: ExitMismatch, Err: fmt.Errorf("%d discrepancies between dnat map and chain %s", len(discrepancies), chain)}
+		}
+		return nil
+	},
+}
+
+// WriteTable prints a summary line when the chain matches, otherwise one row
+// per discrepancy.
+func (r verifyReport) WriteTable(w io.Writer) error {
+	if r.OK {
+		_, err := fmt.Fprintf(w, "chain %s matches dnat map (%d mappings)\n", r.Chain, r.Mappings)
+		return err
+	}
+
+	table := output.NewTable(w, "kind", "service", "protocol", "port", "active", "expected", "actual", "rule")
+	for _, d := range r.Discrepancies {
+		port := ""
+		if d.Port != 0 {
+			port = strconv.Itoa(int(d.Port))
+		}
+		table.Row(d.Kind, d.Service, d.Protocol, port, d.ActiveIP, d.Expected, d.Actual, d.Rule)
+	}
+	return table.Flush()
+}
@@ -2,30 +2,56 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/backend"
+	"github.com/denniswebb/ghostwire/internal/certreload"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/dns"
+	"github.com/denniswebb/ghostwire/internal/handshake"
 	"github.com/denniswebb/ghostwire/internal/iptables"
 	"github.com/denniswebb/ghostwire/internal/k8s"
 	"github.com/denniswebb/ghostwire/internal/logging"
 	"github.com/denniswebb/ghostwire/internal/metrics"
+	"github.com/denniswebb/ghostwire/internal/privsep"
+	"github.com/denniswebb/ghostwire/internal/rulehelper"
 )
 
 const (
-	httpListenAddr           = ":8081"
 	metricErrorLabelRead     = "label_read"
 	metricErrorLabelIptables = "iptables"
 	metricErrorChainVerify   = "chain_verify"
+	metricErrorLabelDNS      = "dns"
+	metricErrorJumpPosition  = "jump_position"
+	metricErrorDrift         = "drift"
+	metricErrorCredentials   = "credentials"
+	metricErrorPreviewPct    = "preview_percent"
+	metricErrorPreviewHealth = "preview_health"
+	metricErrorReconcile     = "reconcile"
+	metricErrorRuleDrift     = "rule_drift"
+	reconcileRepairChain     = "chain"
+	reconcileRepairRules     = "rules"
+	reconcileRepairJump      = "jump"
+	conntrackTable           = "raw"
+	readyMarkerPollInterval  = 250 * time.Millisecond
 )
 
 // WatcherCmd represents the ghostwire watcher subcommand.
@@ -33,222 +59,1344 @@ var WatcherCmd = &cobra.Command{
 	Use:   "watcher",
 	Short: "Poll pod labels and toggle iptables jump",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		logger := logging.GetLogger()
-		if logger == nil {
-			logger = slog.Default()
-		}
+		return runWatcher()
+	},
+}
 
-		podName := os.Getenv("POD_NAME")
-		if podName == "" {
-			return fmt.Errorf("environment variable POD_NAME is required")
-		}
-		podNamespace := os.Getenv("POD_NAMESPACE")
-		if podNamespace == "" {
-			return fmt.Errorf("environment variable POD_NAMESPACE is required")
+// runWatcher serves health and metrics and toggles the jump until it receives
+// SIGINT or SIGTERM.
+func runWatcher() error {
+	logger := logging.GetLogger()
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	podName := os.Getenv("POD_NAME")
+	if podName == "" {
+		return fmt.Errorf("environment variable POD_NAME is required")
+	}
+	podNamespace := os.Getenv("POD_NAMESPACE")
+	if podNamespace == "" {
+		return fmt.Errorf("environment variable POD_NAMESPACE is required")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := applyNamespaceOverrides(podNamespace, logger); err != nil {
+		return err
+	}
+
+	configSource, err := loadGhostwireConfig(ctx, podNamespace, logger)
+	if err != nil {
+		return configError(fmt.Errorf("load ghostwireconfig: %w", err))
+	}
+
+	labelKey := configString("role-label-key")
+	activeValue := configString("role-active")
+	previewValue := configString("role-preview")
+	actions, mirrorGateway, err := loadRoleActions(activeValue, previewValue)
+	if err != nil {
+		return configError(err)
+	}
+
+	pollInterval := viper.GetDuration("poll-interval")
+
+	natChain, jumpHooks, err := ruleNames()
+	if err != nil {
+		return err
+	}
+	ipv6Enabled := viper.GetBool("ipv6")
+	jumpPosition, err := iptables.ParseJumpPosition(viper.GetString("jump-position"))
+	if err != nil {
+		return configError(err)
+	}
+	backendName, err := ruleBackendName()
+	if err != nil {
+		return err
+	}
+	split, err := splitConfig()
+	if err != nil {
+		return err
+	}
+	routeSchedule, err := loadRouteSchedule()
+	if err != nil {
+		return configError(err)
+	}
+	maxPreviewDuration := viper.GetDuration("max-preview-duration")
+	driftInterval := viper.GetDuration("drift-check-interval")
+	reconcileInterval := viper.GetDuration("reconcile-interval")
+	ruleDriftInterval := viper.GetDuration("rule-drift-interval")
+	credentialInterval := viper.GetDuration("credential-check-interval")
+	conntrackInterval := viper.GetDuration("conntrack-check-interval")
+	conntrackWarnPercent := viper.GetInt("conntrack-warn-percent")
+	if conntrackWarnPercent < 1 || conntrackWarnPercent > 100 {
+		return configError(fmt.Errorf("conntrack-warn-percent %d is not between 1 and 100", conntrackWarnPercent))
+	}
+	chaosInterval, err := parseChaosInterval(viper.GetString("chaos-flap-interval"))
+	if err != nil {
+		return configError(err)
+	}
+	readinessSignals, err := metrics.ParseSignals(viper.GetString("readiness-signals"))
+	if err != nil {
+		return configError(err)
+	}
+	dnatMapPath := viper.GetString("iptables-dnat-map")
+	mappingInfoLimit := viper.GetInt("mapping-info-limit")
+
+	var dnsFragment, dnsHostsPath string
+	if viper.GetBool("dns-mode") {
+		dnsFragment = strings.TrimSpace(viper.GetString("dns-hosts-fragment"))
+		dnsHostsPath = strings.TrimSpace(viper.GetString("dns-hosts-path"))
+	}
+
+	pollLogger := logger.With(
+		slog.String("component", "watcher"),
+		slog.String("pod_name", podName),
+		slog.String("namespace", podNamespace),
+		slog.String("label_key", labelKey),
+		slog.String("nat_chain", natChain),
+		slog.Any("jump_hooks", jumpHooks),
+		slog.Bool("ipv6_enabled", ipv6Enabled),
+		slog.String("http_addr", strings.TrimSpace(viper.GetString("http-addr"))),
+		slog.String("http_socket", strings.TrimSpace(viper.GetString("http-socket"))),
+	)
+
+	metricsCollector := metrics.NewMetrics()
+	if viper.GetBool("runtime-metrics") {
+		metricsCollector.RegisterRuntimeCollectors()
+	}
+	metricsCollector.SetHandlerOptions(viper.GetBool("metrics-openmetrics"), viper.GetBool("metrics-compression"))
+	clientset, err := k8s.NewInClusterClient(metricsCollector.ObserveAPIRequest)
+	if err != nil {
+		return kubernetesError(fmt.Errorf("create kubernetes client: %w", err))
+	}
+	if err := checkRBAC(ctx, clientset, watcherPermissions(podNamespace, podName, driftInterval, chaosInterval), pollLogger); err != nil {
+		return err
+	}
+
+	metricsCollector.SetJumpActive(false)
+	metricsCollector.SetPreviewPercent(100 - split.BypassPercent)
+	healthChecker := metrics.NewHealthChecker()
+	healthChecker.SetRequired(readinessSignals)
+
+	dnatMappings, err := loadDNATMap(dnatMapPath)
+	if err != nil {
+		pollLogger.Warn("failed to read dnat map",
+			slog.String("dnat_map_path", dnatMapPath),
+			slog.Any("error", err),
+		)
+	} else {
+		metricsCollector.SetDNATRuleCount(len(dnatMappings))
+		if omitted := metricsCollector.SetMappingInfo(dnatMappings, mappingInfoLimit); omitted > 0 {
+			pollLogger.Warn("mapping info metrics truncated", slog.Int("limit", mappingInfoLimit), slog.Int("omitted", omitted))
 		}
+	}
+	metricsCollector.ObserveSkippedDNATRules(iptables.SkippedDNATRules())
 
-		labelKey := viper.GetString("role-label-key")
-		activeValue := viper.GetString("role-active")
-		previewValue := viper.GetString("role-preview")
+	var generation string
+	if readyMarker := strings.TrimSpace(viper.GetString("ready-marker")); readyMarker != "" {
+		readyTimeout := viper.GetDuration("ready-timeout")
+		pollLogger.Info("waiting for init ready marker", slog.String("path", readyMarker), slog.Duration("timeout", readyTimeout))
+		marker, err := handshake.Wait(ctx, readyMarker, readyTimeout, readyMarkerPollInterval)
+		if err != nil {
+			pollLogger.Error("init did not signal readiness; watcher stays unready", slog.Any("error", err))
+			return err
+		}
+		generation = marker.Generation
+		pollLogger.Info("init ready marker found", slog.String("generation", generation), slog.Time("written", marker.Written))
+	}
 
-		pollIntervalRaw := viper.GetString("poll-interval")
-		pollInterval, err := time.ParseDuration(pollIntervalRaw)
+	executor := iptables.NewExecutor()
+	if socket := strings.TrimSpace(viper.GetString("helper-socket")); socket != "" {
+		executor = rulehelper.NewClient(socket)
+		backendName = rulehelper.BackendName
+		pollLogger.Info("rule changes run through the rule helper", slog.String("socket", socket))
+	} else if viper.GetBool("privsep") {
+		executor, err = newPrivsepExecutor()
 		if err != nil {
-			return fmt.Errorf("parse poll interval %q: %w", pollIntervalRaw, err)
+			return err
+		}
+		if err := privsep.DropEffective(privsep.KeepForExec); err != nil {
+			return configError(fmt.Errorf("drop capabilities: %w", err))
 		}
+		pollLogger.Info("capabilities dropped; rule changes run in a re-executed helper")
+	}
+
+	claim, err := claimRules(ctx, executor, "watcher", pollLogger)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = claim.Release() }()
+
+	chainExists, err := executor.ChainExists(ctx, "nat", natChain)
+	if err != nil {
+		metricsCollector.IncrementError(metricErrorChainVerify)
+		pollLogger.Error("failed to verify dnat chain", slog.Any("error", err))
+	} else if !chainExists {
+		metricsCollector.IncrementError(metricErrorChainVerify)
+		pollLogger.Warn("dnat chain missing")
+	} else {
+		healthChecker.SetChainVerified()
+		pollLogger.Info("dnat chain verified")
+	}
 
-		natChain := strings.TrimSpace(viper.GetString("nat-chain"))
-		if natChain == "" {
-			natChain = "CANARY_DNAT"
+	labelReader := k8s.NewPodLabelReader(clientset, podNamespace, podName)
+	wrappedReader := &metricsLabelReader{
+		delegate: labelReader,
+		metrics:  metricsCollector,
+		health:   healthChecker,
+	}
+
+	jm := &jumpManager{
+		executor:         executor,
+		backendName:      backendName,
+		ruleHelper:       backendName == rulehelper.BackendName,
+		table:            "nat",
+		hooks:            jumpHooks,
+		chain:            natChain,
+		position:         jumpPosition,
+		ipv6:             ipv6Enabled,
+		activeValue:      activeValue,
+		previewValue:     previewValue,
+		roleActions:      actions,
+		mirrorGateway:    mirrorGateway,
+		reverse:          viper.GetBool("reverse-mode"),
+		split:            split,
+		dnsFragment:      dnsFragment,
+		dnsHostsPath:     dnsHostsPath,
+		conntrack:        strings.TrimSpace(viper.GetString("ct-timeout-policy")) != "",
+		dnatMapPath:      dnatMapPath,
+		mappingInfoLimit: mappingInfoLimit,
+		events:           newEventLog(viper.GetInt("events-buffer")),
+		ruleCount:        len(dnatMappings),
+		mappings:         dnatMappings,
+		generation:       generation,
+		services:         clientset,
+		namespace:        podNamespace,
+		driftRepair:      viper.GetBool("drift-repair"),
+		healthCheck:      viper.GetBool("preview-health-check"),
+		rebuild: func(ctx context.Context, executor iptables.Executor, chain string) ([]discovery.ServiceMapping, error) {
+			return rebuildRules(ctx, executor, chain, pollLogger)
+		},
+		metrics: metricsCollector,
+		logger:  pollLogger,
+	}
+	healthChecker.SetVerifier("chain", func(ctx context.Context) error {
+		if err := jm.verifyChain(ctx); err != nil {
+			return err
 		}
-		jumpHook := strings.TrimSpace(viper.GetString("jump-hook"))
-		if jumpHook == "" {
-			jumpHook = "OUTPUT"
+		healthChecker.SetChainVerified()
+		return nil
+	})
+	healthChecker.SetVerifier("rules", jm.verifyRules)
+	if routeSchedule != nil {
+		wrappedReader.delegate = &scheduleLabelReader{
+			delegate:   labelReader,
+			schedule:   routeSchedule,
+			activeRole: jm.activeRole,
+			metrics:    metricsCollector,
+			logger:     pollLogger,
+			now:        time.Now,
 		}
-		ipv6Enabled := viper.GetBool("ipv6")
-		dnatMapPath := viper.GetString("iptables-dnat-map")
+		pollLogger.Info("preview routing limited to the routing schedule", slog.String("schedule", routeSchedule.String()), slog.String("timezone", routeSchedule.Location().String()))
+	}
+	jm.reporters = append(jm.reporters, &healthReporter{health: healthChecker})
 
-		pollLogger := logger.With(
-			slog.String("component", "watcher"),
-			slog.String("pod_name", podName),
-			slog.String("namespace", podNamespace),
-			slog.String("label_key", labelKey),
-			slog.String("nat_chain", natChain),
-			slog.String("jump_hook", jumpHook),
-			slog.Bool("ipv6_enabled", ipv6Enabled),
-			slog.String("http_addr", httpListenAddr),
-		)
+	if viper.GetBool("status-annotations") {
+		jm.reporters = append(jm.reporters, &podAnnotationReporter{
+			annotator: k8s.NewPodAnnotator(clientset, podNamespace, podName),
+			logger:    pollLogger,
+		})
+	}
 
-		clientset, err := k8s.NewInClusterClient()
+	if viper.GetBool("status-resource") {
+		dynamicClient, err := k8s.NewInClusterDynamicClient()
 		if err != nil {
-			return fmt.Errorf("create kubernetes client: %w", err)
+			return kubernetesError(fmt.Errorf("create dynamic client: %w", err))
 		}
+		jm.reporters = append(jm.reporters, &statusResourceReporter{
+			writer: k8s.NewStatusWriter(dynamicClient, podNamespace, podName, os.Getenv("POD_UID")),
+			logger: pollLogger,
+		})
+	}
 
-		metricsCollector := metrics.NewMetrics()
-		metricsCollector.SetJumpActive(false)
-		healthChecker := metrics.NewHealthChecker()
+	transitionHandler, err := buildTransitionHandler(handlerEnv{
+		jm:        jm,
+		podName:   podName,
+		namespace: podNamespace,
+		logger:    pollLogger,
+	}, metricsCollector.ObserveTransitionHandler)
+	if err != nil {
+		return configError(err)
+	}
 
-		dnatCount, err := metrics.CountDNATMappings(dnatMapPath)
+	poller, err := k8s.NewPoller(k8s.PollerConfig{
+		LabelReader:       wrappedReader,
+		LabelKey:          labelKey,
+		ActiveValue:       activeValue,
+		PreviewValue:      previewValue,
+		ExtraRoles:        extraRoles(actions),
+		PollInterval:      pollInterval,
+		Logger:            pollLogger,
+		TransitionHandler: transitionHandler,
+	})
+	if err != nil {
+		return configError(fmt.Errorf("create poller: %w", err))
+	}
+
+	// The gRPC and HTTP admin listeners share one certificate, reloaded in
+	// place when it is rotated.
+	var adminCerts *certreload.Reloader
+	adminHTTPAddr := strings.TrimSpace(viper.GetString("admin-http-addr"))
+	if adminHTTPAddr != "" || strings.TrimSpace(viper.GetString("admin-grpc-addr")) != "" {
+		adminCerts, err = newCertReloader(ctx, "admin",
+			strings.TrimSpace(viper.GetString("admin-tls-cert")),
+			strings.TrimSpace(viper.GetString("admin-tls-key")),
+			metricsCollector, pollLogger)
 		if err != nil {
-			pollLogger.Warn("failed to count dnat mappings",
-				slog.String("dnat_map_path", dnatMapPath),
-				slog.Any("error", err),
-			)
-		} else {
-			metricsCollector.SetDNATRuleCount(dnatCount)
+			return configError(fmt.Errorf("admin tls: %w", err))
 		}
+	}
 
-		executor := iptables.NewExecutor()
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	adminServer, adminErrCh, err := startAdminServer(jm, adminCerts, pollLogger)
+	if err != nil {
+		return fmt.Errorf("start admin api: %w", err)
+	}
 
-		chainExists, err := executor.ChainExists(ctx, "nat", natChain)
-		if err != nil {
-			metricsCollector.IncrementError(metricErrorChainVerify)
-			pollLogger.Error("failed to verify dnat chain", slog.Any("error", err))
-		} else if !chainExists {
-			metricsCollector.IncrementError(metricErrorChainVerify)
-			pollLogger.Warn("dnat chain missing")
-		} else {
-			healthChecker.SetChainVerified()
-			pollLogger.Info("dnat chain verified")
-		}
-
-		labelReader := k8s.NewPodLabelReader(clientset, podNamespace, podName)
-		wrappedReader := &metricsLabelReader{
-			delegate: labelReader,
-			metrics:  metricsCollector,
-			health:   healthChecker,
-		}
-
-		jm := &jumpManager{
-			executor:     executor,
-			table:        "nat",
-			hook:         jumpHook,
-			chain:        natChain,
-			ipv6:         ipv6Enabled,
-			activeValue:  activeValue,
-			previewValue: previewValue,
-			metrics:      metricsCollector,
-			logger:       pollLogger,
-		}
-
-		poller, err := k8s.NewPoller(k8s.PollerConfig{
-			LabelReader:       wrappedReader,
-			LabelKey:          labelKey,
-			ActiveValue:       activeValue,
-			PreviewValue:      previewValue,
-			PollInterval:      pollInterval,
-			Logger:            pollLogger,
-			TransitionHandler: jm,
-		})
+	var role, resync, events http.Handler
+	if jm.events != nil {
+		events = jm.events
+	}
+	roleToken, err := loadTokenFile(strings.TrimSpace(viper.GetString("role-token-file")))
+	if err != nil {
+		return configError(err)
+	}
+	auth := &adminAuth{token: roleToken, metrics: metricsCollector, logger: pollLogger}
+	var adminHTTPTLS *tls.Config
+	if adminHTTPAddr != "" {
+		adminHTTPTLS, err = adminHTTPTLSConfig(adminCerts)
 		if err != nil {
-			return fmt.Errorf("create poller: %w", err)
+			return configError(fmt.Errorf("admin http: %w", err))
 		}
+		auth.clientCAs = adminHTTPTLS.ClientCAs
+	}
+	if roleToken != "" || auth.clientCAs != nil {
+		role = &roleHandler{jm: jm, auth: auth, logger: pollLogger}
+		resync = &resyncHandler{jm: jm, auth: auth, logger: pollLogger}
+	}
 
-		srv := &http.Server{
-			Addr:              httpListenAddr,
-			Handler:           buildWatcherMux(metricsCollector, healthChecker),
-			ReadHeaderTimeout: 5 * time.Second,
+	// With a dedicated admin listener the mutating endpoints leave the main
+	// port, which keeps serving probes and metrics without client certificates.
+	var adminHTTP *http.Server
+	var adminHTTPErrCh <-chan error
+	if adminHTTPTLS != nil {
+		adminHTTP, adminHTTPErrCh, err = startAdminHTTPServer(adminHTTPAddr, adminHTTPTLS, buildAdminMux(role, resync), pollLogger)
+		if err != nil {
+			return err
 		}
+		role, resync = nil, nil
+	}
 
-		serverErrCh := make(chan error, 1)
+	handler := buildWatcherMux(metricsCollector, healthChecker, &statusHandler{jm: jm}, role, resync, events)
+	tokenCheck, err := httpTokenCheck(clientset)
+	if err != nil {
+		return configError(err)
+	}
+	if tokenCheck != nil {
+		handler = requireBearer(handler, tokenCheck, pollLogger)
+	}
+
+	listeners, err := watcherListeners()
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// One server on every listener: Shutdown closes them all, and the channel
+	// closes once each has stopped serving.
+	serverErrCh := make(chan error, len(listeners))
+	var serving sync.WaitGroup
+	for _, listener := range listeners {
+		serving.Add(1)
 		go func() {
-			defer close(serverErrCh)
-			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			defer serving.Done()
+			if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				serverErrCh <- err
 			}
 		}()
+	}
+	go func() {
+		serving.Wait()
+		close(serverErrCh)
+	}()
 
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-		defer signal.Stop(sigCh)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
 
-		pollDone := make(chan struct{})
-		go func() {
-			defer close(pollDone)
-			poller.Run(ctx)
-		}()
+	// A first health check before polling starts keeps an unhealthy preview
+	// from ever receiving traffic.
+	if jm.healthCheck {
+		if err := jm.CheckPreviewHealth(ctx); err != nil {
+			pollLogger.Error("preview health check failed", slog.Any("error", err))
+		}
+		go jm.watchPreviewHealth(ctx, pollInterval)
+	}
 
-		pollLogger.Info("watcher started",
-			slog.String("poll_interval", pollInterval.String()),
-			slog.String("active_value", activeValue),
-			slog.String("preview_value", previewValue),
-		)
+	pollDone := make(chan struct{})
+	go func() {
+		defer close(pollDone)
+		poller.Run(ctx)
+	}()
 
-		var serverErr error
-		select {
-		case sig := <-sigCh:
-			pollLogger.Info("shutdown signal received", slog.String("signal", sig.String()))
-		case err, ok := <-serverErrCh:
-			if ok && err != nil {
-				serverErr = err
-				pollLogger.Error("http server encountered error", slog.Any("error", err))
+	if !jumpPosition.IsDefault() {
+		go jm.watchJumpPosition(ctx, pollInterval)
+	}
+	if driftInterval > 0 {
+		go jm.watchDrift(ctx, driftInterval)
+	}
+	if reconcileInterval > 0 {
+		go jm.watchReconcile(ctx, reconcileInterval)
+	}
+	if ruleDriftInterval > 0 {
+		go jm.watchRuleDrift(ctx, ruleDriftInterval)
+	}
+	if maxPreviewDuration > 0 {
+		go jm.watchPreviewTTL(ctx, maxPreviewDuration, min(pollInterval, maxPreviewDuration))
+	}
+	percentReader, err := previewPercentReader(clientset, podNamespace, podName)
+	if err != nil {
+		return configError(err)
+	}
+	if percentReader != nil {
+		go jm.watchPreviewPercent(ctx, percentReader, 100-split.BypassPercent, pollInterval)
+	}
+	if credentialInterval > 0 {
+		checker := &credentialChecker{
+			client:    clientset,
+			namespace: podNamespace,
+			tokenFile: k8s.ServiceAccountTokenFile,
+			metrics:   metricsCollector,
+			events:    jm.events,
+			logger:    pollLogger,
+			now:       time.Now,
+		}
+		go checker.run(ctx, credentialInterval)
+	}
+	if conntrackInterval > 0 {
+		checker := &conntrackChecker{
+			procRoot:    "/proc",
+			mappings:    jm.Mappings,
+			warnPercent: conntrackWarnPercent,
+			metrics:     metricsCollector,
+			events:      jm.events,
+			logger:      pollLogger,
+		}
+		go checker.run(ctx, conntrackInterval)
+	}
+	if chaosInterval > 0 {
+		if err := chaosAllowed(ctx, clientset, podNamespace); err != nil {
+			pollLogger.Warn("chaos mode disabled", slog.Any("error", err))
+		} else {
+			pollLogger.Warn("chaos mode enabled; the dnat jump will flap at random", slog.Duration("interval", chaosInterval))
+			go jm.runChaos(ctx, chaosInterval, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+		}
+	}
+
+	if configSource != nil {
+		go configSource.Watch(ctx, func(cfg *v1alpha1.GhostwireConfig) {
+			changed := applyGhostwireConfigSpec(cfg.Spec)
+			chain, hooks, err := ruleNames()
+			if err != nil {
+				pollLogger.Warn("rejected ghostwireconfig chain update", slog.Any("error", err))
+				return
 			}
-		case <-ctx.Done():
+			active := configString("role-active")
+			preview := configString("role-preview")
+			if err := poller.SetRoles(configString("role-label-key"), active, preview, extraRoles(actions)...); err != nil {
+				pollLogger.Warn("rejected ghostwireconfig role update", slog.Any("error", err))
+				return
+			}
+			if err := jm.Reconfigure(ctx, hooks, chain, active, preview, rulesChanged(changed)); err != nil {
+				pollLogger.Error("failed to apply ghostwireconfig update", slog.Any("error", err))
+				return
+			}
+			pollLogger.Info("ghostwireconfig update applied", slog.String("resource_version", cfg.ResourceVersion))
+		})
+	}
+
+	pollLogger.Info("watcher started",
+		slog.String("poll_interval", pollInterval.String()),
+		slog.String("active_value", activeValue),
+		slog.String("preview_value", previewValue),
+		slog.Any("role_actions", actions),
+	)
+
+	var serverErr error
+	select {
+	case sig := <-sigCh:
+		pollLogger.Info("shutdown signal received", slog.String("signal", sig.String()))
+	case err, ok := <-serverErrCh:
+		if ok && err != nil {
+			serverErr = err
+			pollLogger.Error("http server encountered error", slog.Any("error", err))
 		}
+	case err, ok := <-adminErrCh:
+		if ok && err != nil {
+			serverErr = err
+			pollLogger.Error("admin api encountered error", slog.Any("error", err))
+		}
+	case err, ok := <-adminHTTPErrCh:
+		if ok && err != nil {
+			serverErr = err
+			pollLogger.Error("admin http server encountered error", slog.Any("error", err))
+		}
+	case <-ctx.Done():
+	}
 
-		cancel()
-		<-pollDone
+	cancel()
+	if adminServer != nil {
+		adminServer.Stop()
+	}
+	<-pollDone
 
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			pollLogger.Error("http server shutdown failed", slog.Any("error", err))
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		pollLogger.Error("http server shutdown failed", slog.Any("error", err))
+	}
+	if adminHTTP != nil {
+		if err := adminHTTP.Shutdown(shutdownCtx); err != nil {
+			pollLogger.Error("admin http server shutdown failed", slog.Any("error", err))
 		}
-		shutdownCancel()
+	}
+	shutdownCancel()
 
-		if serverErr == nil {
-			if err, ok := <-serverErrCh; ok && err != nil {
-				pollLogger.Error("http server encountered error", slog.Any("error", err))
-			}
+	if serverErr == nil {
+		if err, ok := <-serverErrCh; ok && err != nil {
+			pollLogger.Error("http server encountered error", slog.Any("error", err))
 		}
+	}
 
-		pollLogger.Info("watcher shutdown complete")
-		return nil
-	},
+	pollLogger.Info("watcher shutdown complete")
+	return nil
 }
 
-func buildWatcherMux(metricsCollector *metrics.Metrics, healthChecker *metrics.HealthChecker) http.Handler {
+func buildWatcherMux(metricsCollector *metrics.Metrics, healthChecker *metrics.HealthChecker, status http.Handler, role http.Handler, resync http.Handler, events http.Handler) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", metricsCollector.Handler())
 	mux.Handle("/healthz", healthChecker.Handler())
+	if status != nil {
+		mux.Handle("/status", status)
+	}
+	if role != nil {
+		mux.Handle("/role", role)
+	}
+	if resync != nil {
+		mux.Handle("/admin/resync", resync)
+	}
+	if events != nil {
+		mux.Handle("/events", events)
+	}
 	return mux
 }
 
+// verifyChain re-checks that the DNAT chain exists, for
+// `/healthz?verify=chain`.
+func (j *jumpManager) verifyChain(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	exists, err := j.executor.ChainExists(ctx, j.table, j.chain)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorChainVerify)
+		return fmt.Errorf("check chain %s: %w", j.chain, err)
+	}
+	if !exists {
+		j.metrics.IncrementError(metricErrorChainVerify)
+		return fmt.Errorf("chain %s missing", j.chain)
+	}
+	return nil
+}
+
+// verifyRules compares the live chain with the current mappings, for
+// `/healthz?verify=rules`.
+func (j *jumpManager) verifyRules(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	rules, err := j.rules(j.hooks, j.chain)
+	if err != nil {
+		return err
+	}
+	discrepancies, err := rules.Verify(ctx, j.mappings)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorChainVerify)
+		return fmt.Errorf("list chain %s: %w", j.chain, err)
+	}
+	if len(discrepancies) > 0 {
+		j.metrics.IncrementError(metricErrorChainVerify)
+		return fmt.Errorf("%d DNAT rule discrepancies in chain %s (first: %s %s)", len(discrepancies), j.chain, discrepancies[0].Kind, discrepancies[0].ActiveIP)
+	}
+	return nil
+}
+
 type jumpManager struct {
-	executor     iptables.Executor
-	table        string
-	hook         string
-	chain        string
-	ipv6         bool
-	activeValue  string
-	previewValue string
-	metrics      *metrics.Metrics
-	logger       *slog.Logger
+	mu               sync.Mutex
+	jumpActive       bool
+	jumpSince        time.Time
+	executor         iptables.Executor
+	backendName      string
+	ruleHelper       bool
+	table            string
+	hooks            []string
+	chain            string
+	position         iptables.JumpPosition
+	ipv6             bool
+	activeValue      string
+	previewValue     string
+	roleActions      map[string]string
+	mirrorGateway    string
+	mirrorActive     bool
+	reverse          bool
+	reverseActive    bool
+	split            iptables.SplitConfig
+	dnsFragment      string
+	dnsHostsPath     string
+	conntrack        bool
+	dnatMapPath      string
+	mappingInfoLimit int
+	ruleCount        int
+	mappings         []discovery.ServiceMapping
+	generation       string
+	services         kubernetes.Interface
+	namespace        string
+	driftRepair      bool
+	healthCheck      bool
+	health           previewHealth
+	rebuild          func(ctx context.Context, executor iptables.Executor, chain string) ([]discovery.ServiceMapping, error)
+	lastStatus       routingStatus
+	reporters        []statusReporter
+	events           *eventLog
+	metrics          *metrics.Metrics
+	logger           *slog.Logger
 }
 
 func (j *jumpManager) OnTransition(ctx context.Context, previous string, current string) error {
-	switch current {
-	case j.previewValue:
-		j.logger.Info("activating dnat jump", slog.String("previous_role", previous), slog.String("current_role", current))
-		if err := iptables.AddJump(ctx, j.executor, j.table, j.hook, j.chain, j.ipv6, j.logger); err != nil {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.applyTransition(ctx, previous, current)
+	j.report(ctx, current, err)
+	return err
+}
+
+func (j *jumpManager) report(ctx context.Context, role string, transitionErr error) {
+	status := routingStatus{
+		Role:           role,
+		NATChain:       j.chain,
+		JumpHook:       strings.Join(j.hooks, ","),
+		JumpActive:     j.jumpActive,
+		LastTransition: time.Now(),
+		RuleCount:      j.ruleCount,
+	}
+	if transitionErr != nil {
+		status.LastError = transitionErr.Error()
+	}
+	if role != j.lastStatus.Role || transitionErr != nil {
+		j.events.Add(eventTransition, fmt.Sprintf("role %q -> %q, jump active %t", j.lastStatus.Role, role, j.jumpActive), role, transitionErr)
+	}
+	j.lastStatus = status
+	for _, reporter := range j.reporters {
+		reporter.Report(ctx, status)
+	}
+}
+
+func (j *jumpManager) activeRole() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.activeValue
+}
+
+func (j *jumpManager) previewRole() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.previewValue
+}
+
+// Status returns the routing state recorded at the latest transition.
+func (j *jumpManager) Status() routingStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := j.lastStatus
+	status.NATChain = j.chain
+	status.JumpHook = strings.Join(j.hooks, ",")
+	status.JumpActive = j.jumpActive
+	status.RuleCount = j.ruleCount
+	return status
+}
+
+// ForceRole applies a role pushed by an external controller. The poller keeps
+// running and re-applies the label's role the next time the label changes.
+func (j *jumpManager) ForceRole(ctx context.Context, role string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.actionFor(role); !ok {
+		return fmt.Errorf("role %q is not %q, %q or a role-actions role", role, j.activeValue, j.previewValue)
+	}
+
+	err := j.applyTransition(ctx, j.lastStatus.Role, role)
+	j.report(ctx, role, err)
+	return err
+}
+
+// setMappings records the mappings read from the DNAT map and the rule count
+// derived from them.
+func (j *jumpManager) setMappings(mappings []discovery.ServiceMapping) {
+	j.mappings = mappings
+	j.ruleCount = len(mappings)
+	j.metrics.SetDNATRuleCount(len(mappings))
+	j.metrics.ObserveSkippedDNATRules(iptables.SkippedDNATRules())
+	if omitted := j.metrics.SetMappingInfo(mappings, j.mappingInfoLimit); omitted > 0 {
+		j.logger.Warn("mapping info metrics truncated", slog.Int("limit", j.mappingInfoLimit), slog.Int("omitted", omitted))
+	}
+}
+
+// Mappings returns the mappings read from the DNAT map at startup or the
+// latest refresh.
+func (j *jumpManager) Mappings() []discovery.ServiceMapping {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]discovery.ServiceMapping(nil), j.mappings...)
+}
+
+// Generation returns the init run recorded in the ready marker, if any.
+func (j *jumpManager) Generation() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.generation
+}
+
+// Refresh re-reads the DNAT map rule count and re-applies the current role so
+// a jump removed out of band is restored.
+func (j *jumpManager) Refresh(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.dnatMapPath != "" {
+		mappings, err := loadDNATMap(j.dnatMapPath)
+		if err != nil {
+			return fmt.Errorf("reload dnat map: %w", err)
+		}
+		j.setMappings(mappings)
+	}
+
+	role := j.lastStatus.Role
+	if role == "" {
+		return nil
+	}
+	err := j.applyTransition(ctx, role, role)
+	j.report(ctx, role, err)
+	return err
+}
+
+// Resync rebuilds the rules from fresh discovery into a staging chain, moves
+// an active jump over to it and then promotes it in place of the live chain,
+// so routing never passes through a flushed or half-built chain.
+func (j *jumpManager) Resync(ctx context.Context) (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer func() {
+		if err != nil {
+			j.events.Add(eventError, "resync failed", "", err)
+		}
+	}()
+
+	return j.resync(ctx)
+}
+
+func (j *jumpManager) resync(ctx context.Context) error {
+	return j.rebuildChain(ctx, j.hooks, j.chain)
+}
+
+// rebuildChain rebuilds chain from fresh discovery with the jump, when
+// active, moved over to it under hooks, then reinstalls what depends on the
+// chain's rules.
+func (j *jumpManager) rebuildChain(ctx context.Context, hooks []string, chain string) error {
+	if j.rebuild == nil {
+		return fmt.Errorf("resync is not configured")
+	}
+
+	var mappings []discovery.ServiceMapping
+	var err error
+	if j.ruleHelper {
+		mappings, err = j.rebuildThroughHelper(ctx, hooks, chain)
+	} else {
+		mappings, err = j.rebuildStaged(ctx, hooks, chain)
+	}
+	if err != nil {
+		return err
+	}
+	j.hooks = append([]string(nil), hooks...)
+	j.chain = chain
+
+	if j.jumpActive && j.dnsHostsPath != "" {
+		if err := dns.InstallHostsFragment(j.dnsFragment, j.dnsHostsPath); err != nil {
+			j.metrics.IncrementError(metricErrorLabelDNS)
+			return fmt.Errorf("reinstall dns hosts overrides: %w", err)
+		}
+	}
+
+	j.setMappings(mappings)
+	if j.split.Adjustable {
+		// The rebuilt chain starts from the configured percentage.
+		if err := iptables.UpdateSplit(ctx, j.executor, j.table, j.chain, j.split, j.ipv6, j.logger); err != nil {
 			j.metrics.IncrementError(metricErrorLabelIptables)
-			return fmt.Errorf("add jump: %w", err)
+			return fmt.Errorf("restore preview percent: %w", err)
 		}
-		j.metrics.SetJumpActive(true)
-	case j.activeValue:
-		j.logger.Info("deactivating dnat jump", slog.String("previous_role", previous), slog.String("current_role", current))
-		if err := iptables.RemoveJump(ctx, j.executor, j.table, j.hook, j.chain, j.ipv6, j.logger); err != nil {
+	}
+	if j.mirrorActive {
+		if _, err := iptables.SetupMirror(ctx, j.executor, j.chain, j.hooks, j.mirrorGateway, j.mappings, j.ipv6, j.logger); err != nil {
 			j.metrics.IncrementError(metricErrorLabelIptables)
-			return fmt.Errorf("remove jump: %w", err)
+			return fmt.Errorf("rebuild traffic mirror: %w", err)
+		}
+	}
+	if j.reverseActive {
+		if _, err := iptables.SetupReverse(ctx, j.executor, j.table, j.chain, j.hooks, j.mappings, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("rebuild reverse dnat: %w", err)
+		}
+	}
+	j.logger.Info("resync complete", slog.Int("mappings", len(mappings)), slog.Bool("jump_active", j.jumpActive))
+	j.events.Add(eventResync, fmt.Sprintf("resync complete with %d mappings", len(mappings)), "", nil)
+	return nil
+}
+
+// rebuildThroughHelper rebuilds chain through the rule helper, which stages
+// and promotes it itself and keeps the jumps to it in place. The helper cannot
+// rename chains, so Reconfigure keeps chain the current one.
+func (j *jumpManager) rebuildThroughHelper(ctx context.Context, hooks []string, chain string) ([]discovery.ServiceMapping, error) {
+	if !slices.Equal(hooks, j.hooks) {
+		if err := j.moveJumps(ctx, hooks); err != nil {
+			return nil, err
 		}
-		j.metrics.SetJumpActive(false)
-	default:
+	}
+	mappings, err := j.rebuild(ctx, j.executor, chain)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return nil, fmt.Errorf("rebuild rules: %w", err)
+	}
+	return mappings, nil
+}
+
+// rebuildStaged rebuilds the rules into chain's staging chain, moves an active
+// jump from the current hooks and chain over to it under hooks, and promotes
+// it to chain. A previous chain of another name is deleted once nothing
+// jumps to it.
+func (j *jumpManager) rebuildStaged(ctx context.Context, hooks []string, chain string) ([]discovery.ServiceMapping, error) {
+	staging := iptables.StagingChainName(chain)
+	mappings, err := j.rebuild(ctx, j.executor, staging)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorLabelIptables)
+		return nil, fmt.Errorf("rebuild rules: %w", err)
+	}
+
+	tables := []string{j.table}
+	if j.conntrack {
+		tables = append(tables, conntrackTable)
+	}
+	for _, table := range tables {
+		if j.jumpActive {
+			if err := j.addJumps(ctx, table, hooks, staging); err != nil {
+				j.metrics.IncrementError(metricErrorLabelIptables)
+				return nil, fmt.Errorf("add staging jump in %s: %w", table, err)
+			}
+			if err := j.removeJumps(ctx, table, j.hooks, j.chain); err != nil {
+				j.metrics.IncrementError(metricErrorLabelIptables)
+				return nil, fmt.Errorf("remove previous jump in %s: %w", table, err)
+			}
+		}
+		if chain != j.chain {
+			if err := iptables.DeleteChain(ctx, j.executor, table, j.chain, j.ipv6, j.logger); err != nil {
+				j.metrics.IncrementError(metricErrorLabelIptables)
+				return nil, fmt.Errorf("delete previous chain in %s: %w", table, err)
+			}
+		}
+		if err := iptables.PromoteChain(ctx, j.executor, table, chain, staging, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return nil, fmt.Errorf("promote staging chain in %s: %w", table, err)
+		}
+	}
+	return mappings, nil
+}
+
+// applyTransition runs the action of the current role, after the leave step
+// of the previous role's action when the two differ. Roles without an action
+// are ignored.
+func (j *jumpManager) applyTransition(ctx context.Context, previous string, current string) error {
+	action, ok := j.actionFor(current)
+	if !ok {
 		j.logger.Debug("ignoring transition", slog.String("previous_role", previous), slog.String("current_role", current))
+		return nil
+	}
+	if previousAction, ok := j.actionFor(previous); ok && previousAction != action {
+		if leave := roleActions[previousAction].leave; leave != nil {
+			if err := leave(j, ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return roleActions[action].enter(j, ctx, previous, current)
+}
+
+// Reconfigure applies live changes to the jump hooks, chain, and role values.
+// A new chain, or rebuild after a discovery or exclusion setting changed, is
+// built from fresh discovery and promoted before the jump is moved onto it;
+// a change of hooks alone moves the installed jump. Routing stays active
+// across either.
+func (j *jumpManager) Reconfigure(ctx context.Context, hooks []string, chain, activeValue, previewValue string, rebuild bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.activeValue = activeValue
+	j.previewValue = previewValue
+
+	moved := !slices.Equal(hooks, j.hooks) || chain != j.chain
+	if !moved && !rebuild {
+		return nil
+	}
+	if len(hooks) == 0 {
+		return fmt.Errorf("no jump hooks configured")
+	}
+	for _, hook := range hooks {
+		if err := iptables.ValidateHookName(hook); err != nil {
+			return err
+		}
+	}
+	if err := iptables.ValidateChainName(chain); err != nil {
+		return err
+	}
+	rebuild = rebuild || chain != j.chain
+	if rebuild && j.rebuild == nil {
+		return fmt.Errorf("rebuilding %s needs resync, which is not configured", chain)
+	}
+	if j.ruleHelper && chain != j.chain {
+		return fmt.Errorf("the rule helper cannot move the jump to chain %s; restart the watcher to change chains", chain)
+	}
+
+	j.logger.Info("reconfiguring dnat jump",
+		slog.Any("previous_hooks", j.hooks),
+		slog.String("previous_chain", j.chain),
+		slog.Any("hooks", hooks),
+		slog.String("chain", chain),
+		slog.Bool("rebuild", rebuild),
+		slog.Bool("jump_active", j.jumpActive),
+	)
+
+	previousHooks, previousChain := j.hooks, j.chain
+	if moved && j.mirrorActive {
+		if err := iptables.RemoveMirror(ctx, j.executor, j.chain, j.hooks, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("remove previous traffic mirror: %w", err)
+		}
+	}
+	if moved && j.reverseActive {
+		if err := iptables.RemoveReverse(ctx, j.executor, j.table, j.chain, j.hooks, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("remove previous reverse dnat: %w", err)
+		}
+	}
+
+	if rebuild {
+		// rebuildChain reinstalls the mirror and reverse rules itself.
+		if err := j.rebuildChain(ctx, hooks, chain); err != nil {
+			return err
+		}
+	} else {
+		if err := j.moveJumps(ctx, hooks); err != nil {
+			return err
+		}
+	}
+
+	if moved {
+		j.events.Add(eventReconfigure, fmt.Sprintf("jump moved from %s/%s to %s/%s", strings.Join(previousHooks, ","), previousChain, strings.Join(hooks, ","), chain), "", nil)
+	}
+	return nil
+}
+
+// moveJumps moves an installed jump to j.chain from j.hooks to hooks and
+// reinstalls the mirror and reverse rules under them.
+func (j *jumpManager) moveJumps(ctx context.Context, hooks []string) error {
+	if j.jumpActive {
+		if err := j.removeJumps(ctx, j.table, j.hooks, j.chain); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("remove previous jump: %w", err)
+		}
+		if err := j.addJumps(ctx, j.table, hooks, j.chain); err != nil {
+			j.jumpActive = false
+			j.metrics.SetJumpActive(false)
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("add reconfigured jump: %w", err)
+		}
+		if j.conntrack {
+			if err := j.removeJumps(ctx, conntrackTable, j.hooks, j.chain); err != nil {
+				j.metrics.IncrementError(metricErrorLabelIptables)
+				return fmt.Errorf("remove previous conntrack jump: %w", err)
+			}
+			if err := j.addJumps(ctx, conntrackTable, hooks, j.chain); err != nil {
+				j.metrics.IncrementError(metricErrorLabelIptables)
+				return fmt.Errorf("add reconfigured conntrack jump: %w", err)
+			}
+		}
+	}
+	j.hooks = append([]string(nil), hooks...)
+
+	if j.mirrorActive {
+		if _, err := iptables.SetupMirror(ctx, j.executor, j.chain, j.hooks, j.mirrorGateway, j.mappings, j.ipv6, j.logger); err != nil {
+			j.mirrorActive = false
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("install reconfigured traffic mirror: %w", err)
+		}
+	}
+	if j.reverseActive {
+		if _, err := iptables.SetupReverse(ctx, j.executor, j.table, j.chain, j.hooks, j.mappings, j.ipv6, j.logger); err != nil {
+			j.reverseActive = false
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("install reconfigured reverse dnat: %w", err)
+		}
+	}
+	return nil
+}
+
+// addJumps installs the jump to chain in every hook of table. The nat-table
+// jump goes through the rule backend, which honours the configured position;
+// the raw-table conntrack jump always goes to the top.
+func (j *jumpManager) addJumps(ctx context.Context, table string, hooks []string, chain string) error {
+	if table == j.table {
+		rules, err := j.rules(hooks, chain)
+		if err != nil {
+			return err
+		}
+		return rules.AddJump(ctx)
+	}
+	return iptables.AddJumps(ctx, j.executor, table, hooks, chain, j.ipv6, j.logger)
+}
+
+// removeJumps removes the jump to chain from every hook of table.
+func (j *jumpManager) removeJumps(ctx context.Context, table string, hooks []string, chain string) error {
+	if table == j.table {
+		rules, err := j.rules(hooks, chain)
+		if err != nil {
+			return err
+		}
+		return rules.RemoveJump(ctx)
+	}
+	return iptables.RemoveJumps(ctx, j.executor, table, hooks, chain, j.ipv6, j.logger)
+}
+
+// rules returns the rule backend managing chain, jumped to from hooks. Chain
+// and hooks change on reconfiguration and around staging swaps, so it is
+// built per call.
+func (j *jumpManager) rules(hooks []string, chain string) (backend.Backend, error) {
+	return backend.New(j.backendName, backend.Options{
+		Executor: j.executor,
+		Rules:    iptables.Config{ChainName: chain, IPv6: j.ipv6},
+		Hooks:    hooks,
+		Position: j.position,
+		Logger:   j.logger,
+	})
+}
+
+// watchJumpPosition re-checks the jump's place in each hook every interval and
+// restores it when other tooling has reordered a hook.
+func (j *jumpManager) watchJumpPosition(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := j.CheckJumpPosition(ctx); err != nil {
+			j.logger.Error("failed to restore jump position", slog.Any("error", err))
+			j.events.Add(eventError, "jump position check failed", "", err)
+		}
+	}
+}
+
+// CheckJumpPosition detects drift of an active jump away from its configured
+// position in any of its hooks and re-inserts it there.
+func (j *jumpManager) CheckJumpPosition(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.jumpActive {
+		return nil
+	}
+
+	for _, hook := range j.hooks {
+		holds, err := iptables.VerifyJumpPosition(ctx, j.executor, j.table, hook, j.chain, j.position)
+		if err != nil {
+			j.metrics.IncrementError(metricErrorJumpPosition)
+			return fmt.Errorf("verify jump position in %s: %w", hook, err)
+		}
+		if holds {
+			continue
+		}
+
+		j.metrics.IncrementError(metricErrorJumpPosition)
+		j.logger.Warn("jump position drift detected; restoring",
+			slog.String("hook", hook),
+			slog.String("chain", j.chain),
+			slog.String("position", j.position.String()),
+		)
+		if err := iptables.AddJumpAt(ctx, j.executor, j.table, hook, j.chain, j.position, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("restore jump position in %s: %w", hook, err)
+		}
+		j.events.Add(eventRepair, fmt.Sprintf("jump restored at position %s in %s", j.position, hook), "", nil)
 	}
 	return nil
 }
 
+// watchDrift runs CheckDrift every interval.
+func (j *jumpManager) watchDrift(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := j.CheckDrift(ctx); err != nil {
+			j.logger.Error("preview ip drift check failed", slog.Any("error", err))
+			j.events.Add(eventError, "drift check failed", "", err)
+		}
+	}
+}
+
+// CheckDrift compares the DNAT map against current Service IPs, reporting
+// stale mappings through the stale_mappings gauge. With drift repair enabled
+// the affected rules are rewritten and the map is updated to match.
+func (j *jumpManager) CheckDrift(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	mappings, err := loadDNATMap(j.dnatMapPath)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorDrift)
+		return err
+	}
+	j.setMappings(mappings)
+
+	drifts, err := discovery.DetectDrift(ctx, j.services, mappings, j.namespace)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorDrift)
+		return fmt.Errorf("detect drift: %w", err)
+	}
+	j.metrics.SetStaleMappings(len(drifts))
+	for _, drift := range drifts {
+		j.logger.Warn("stale dnat mapping detected",
+			slog.String("service", drift.Recorded.ServiceName),
+			slog.Int("port", int(drift.Recorded.Port)),
+			slog.String("reason", drift.Reason),
+			slog.String("recorded_active_ip", drift.Recorded.ActiveClusterIP),
+			slog.String("recorded_preview_ip", drift.Recorded.PreviewClusterIP),
+			slog.String("active_ip", drift.Current.ActiveClusterIP),
+			slog.String("preview_ip", drift.Current.PreviewClusterIP),
+		)
+	}
+	if !j.driftRepair || len(drifts) == 0 {
+		return nil
+	}
+
+	var errs []error
+	repaired := 0
+	for _, drift := range drifts {
+		if drift.Reason == discovery.DriftServiceMissing {
+			continue
+		}
+		if err := iptables.RepairDNATRule(ctx, j.executor, j.table, j.chain, drift.Recorded, drift.Current, j.ipv6, j.logger); err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			errs = append(errs, err)
+			continue
+		}
+		for i := range mappings {
+			if reflect.DeepEqual(mappings[i], drift.Recorded) {
+				mappings[i] = drift.Current
+			}
+		}
+		j.events.Add(eventRepair, fmt.Sprintf("repaired %s:%d/%s to %s -> %s", drift.Current.ServiceName, drift.Current.Port, drift.Current.Protocol, drift.Current.ActiveClusterIP, drift.Current.PreviewClusterIP), "", nil)
+		repaired++
+	}
+
+	if repaired > 0 {
+		if err := writeDNATMap(j.dnatMapPath, mappings, j.logger); err != nil {
+			errs = append(errs, err)
+		}
+		j.setMappings(mappings)
+		j.metrics.SetStaleMappings(len(drifts) - repaired)
+	}
+	return errors.Join(errs...)
+}
+
+// watchReconcile runs Reconcile every interval.
+func (j *jumpManager) watchReconcile(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := j.Reconcile(ctx); err != nil {
+			j.logger.Error("rule reconciliation failed", slog.Any("error", err))
+			j.events.Add(eventError, "reconcile failed", "", err)
+		}
+	}
+}
+
+// Reconcile compares the chain, its DNAT rules and the jump in each hook with
+// the state the watcher last applied, and repairs whatever changed under it,
+// such as a kube-proxy or CNI restart flushing the nat table. A missing chain
+// or wrong rules are rebuilt through a resync; jumps are restored or removed
+// to match the current role.
+func (j *jumpManager) Reconcile(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	repair := ""
+	exists, err := j.executor.ChainExists(ctx, j.table, j.chain)
+	if err != nil {
+		j.metrics.IncrementError(metricErrorReconcile)
+		return fmt.Errorf("check chain %s: %w", j.chain, err)
+	}
+	if !exists {
+		repair = reconcileRepairChain
+		j.logger.Warn("dnat chain missing; rebuilding", slog.String("chain", j.chain))
+	} else {
+		discrepancies, err := j.checkRuleDrift(ctx)
+		if err != nil {
+			j.metrics.IncrementError(metricErrorReconcile)
+			return err
+		}
+		if len(discrepancies) > 0 {
+			repair = reconcileRepairRules
+			j.logger.Info("rebuilding drifted dnat chain", slog.String("chain", j.chain))
+		}
+	}
+	if repair != "" {
+		if err := j.resync(ctx); err != nil {
+			j.metrics.IncrementError(metricErrorReconcile)
+			return fmt.Errorf("rebuild chain %s: %w", j.chain, err)
+		}
+		j.metrics.IncrementReconcileRepair(repair)
+		j.events.Add(eventRepair, fmt.Sprintf("rebuilt chain %s after %s drift", j.chain, repair), "", nil)
+		// Bring the rule_drift gauge up to date with the rebuilt chain.
+		if _, err := j.checkRuleDrift(ctx); err != nil {
+			j.metrics.IncrementError(metricErrorReconcile)
+			return err
+		}
+	}
+
+	for _, hook := range j.hooks {
+		present, err := iptables.JumpExists(ctx, j.executor, j.table, hook, j.chain)
+		if err != nil {
+			j.metrics.IncrementError(metricErrorReconcile)
+			return fmt.Errorf("check jump in %s: %w", hook, err)
+		}
+		if present == j.jumpActive {
+			continue
+		}
+
+		j.logger.Warn("jump drift detected; repairing",
+			slog.String("hook", hook),
+			slog.String("chain", j.chain),
+			slog.Bool("jump_active", j.jumpActive),
+		)
+		if j.jumpActive {
+			err = j.addJumps(ctx, j.table, []string{hook}, j.chain)
+		} else {
+			err = j.removeJumps(ctx, j.table, []string{hook}, j.chain)
+		}
+		if err != nil {
+			j.metrics.IncrementError(metricErrorLabelIptables)
+			return fmt.Errorf("repair jump in %s: %w", hook, err)
+		}
+		j.metrics.IncrementReconcileRepair(reconcileRepairJump)
+		j.events.Add(eventRepair, fmt.Sprintf("jump in %s set back to active=%t", hook, j.jumpActive), "", nil)
+	}
+	return nil
+}
+
+// watchRuleDrift runs CheckRuleDrift every interval.
+func (j *jumpManager) watchRuleDrift(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := j.CheckRuleDrift(ctx); err != nil {
+			j.metrics.IncrementError(metricErrorRuleDrift)
+			j.logger.Error("rule drift check failed", slog.Any("error", err))
+			j.events.Add(eventError, "rule drift check failed", "", err)
+		}
+	}
+}
+
+// CheckRuleDrift compares the rules live in the DNAT chain, as listed by
+// `iptables -S`, with the DNAT map. Differences are counted in the rule_drift
+// gauge and listed in a warning; repairing them is left to Reconcile.
+func (j *jumpManager) CheckRuleDrift(ctx context.Context) ([]iptables.Discrepancy, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.checkRuleDrift(ctx)
+}
+
+func (j *jumpManager) checkRuleDrift(ctx context.Context) ([]iptables.Discrepancy, error) {
+	rules, err := j.rules(j.hooks, j.chain)
+	if err != nil {
+		return nil, err
+	}
+	discrepancies, err := rules.Verify(ctx, j.mappings)
+	if err != nil {
+		return nil, fmt.Errorf("list chain %s: %w", j.chain, err)
+	}
+
+	listed := make(map[string][]string, 3)
+	for _, d := range discrepancies {
+		listed[d.Kind] = append(listed[d.Kind], d.String())
+	}
+	missing := listed[iptables.DiscrepancyMissing]
+	extra := listed[iptables.DiscrepancyExtra]
+	wrongTarget := listed[iptables.DiscrepancyWrongTarget]
+	j.metrics.SetRuleDrift(len(missing), len(extra), len(wrongTarget))
+	if len(discrepancies) > 0 {
+		j.logger.Warn("dnat chain differs from the dnat map",
+			slog.String("chain", j.chain),
+			slog.Any("missing", missing),
+			slog.Any("extra", extra),
+			slog.Any("wrong_target", wrongTarget),
+		)
+	}
+	return discrepancies, nil
+}
+
 type metricsLabelReader struct {
 	delegate k8s.LabelReader
 	metrics  *metrics.Metrics
@@ -261,8 +1409,16 @@ func (m *metricsLabelReader) GetLabel(ctx context.Context, labelKey string) (str
 		m.metrics.IncrementError(metricErrorLabelRead)
 		return "", err
 	}
+	m.metrics.RecordPollSuccess(time.Now())
 	if m.health != nil {
 		m.health.SetLabelsRead()
 	}
 	return value, nil
 }
+
+// Terminating forwards the delegate's termination state so the poller can
+// suppress transitions.
+func (m *metricsLabelReader) Terminating() bool {
+	reader, ok := m.delegate.(k8s.TerminationReader)
+	return ok && reader.Terminating()
+}
@@ -3,16 +3,32 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/dns"
 	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/internal/k8s"
 	"github.com/denniswebb/ghostwire/internal/metrics"
+	"github.com/denniswebb/ghostwire/internal/schedule"
 )
 
 func TestJumpManagerOnTransition(t *testing.T) {
@@ -170,7 +186,7 @@ func TestJumpManagerOnTransition(t *testing.T) {
 			jm := &jumpManager{
 				executor:     exec,
 				table:        "nat",
-				hook:         "OUTPUT",
+				hooks:        []string{"OUTPUT"},
 				chain:        "CANARY_DNAT",
 				ipv6:         false,
 				activeValue:  "active",
@@ -238,6 +254,520 @@ func TestJumpManagerOnTransition(t *testing.T) {
 	}
 }
 
+func TestJumpManagerDNSHostsOverrides(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fragmentPath := filepath.Join(dir, "hosts.preview")
+	hostsPath := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0o600); err != nil {
+		t.Fatalf("write hosts: %v", err)
+	}
+	entries := []dns.HostsEntry{{IP: "10.0.0.2", Names: []string{"orders"}}}
+	logger, _ := newTestLogger()
+	if err := dns.WriteHostsFragment(fragmentPath, entries, logger); err != nil {
+		t.Fatalf("write fragment: %v", err)
+	}
+
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		dnsFragment:  fragmentPath,
+		dnsHostsPath: hostsPath,
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	if err := jm.OnTransition(context.Background(), "active", "preview"); err != nil {
+		t.Fatalf("preview transition failed: %v", err)
+	}
+	content, err := os.ReadFile(hostsPath)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	if !strings.Contains(string(content), "10.0.0.2\torders") {
+		t.Fatalf("expected hosts overrides to be installed, got %q", content)
+	}
+
+	if err := jm.OnTransition(context.Background(), "preview", "active"); err != nil {
+		t.Fatalf("active transition failed: %v", err)
+	}
+	content, err = os.ReadFile(hostsPath)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	if string(content) != "127.0.0.1\tlocalhost\n" {
+		t.Fatalf("expected hosts overrides to be removed, got %q", content)
+	}
+}
+
+func TestJumpManagerDNSModeSkipsJumpWithoutOverrides(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hostsPath := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0o644); err != nil {
+		t.Fatalf("write hosts: %v", err)
+	}
+
+	logger, _ := newTestLogger()
+	exec := &mockExecutor{}
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		dnsFragment:  filepath.Join(dir, "missing.preview"),
+		dnsHostsPath: hostsPath,
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	if err := jm.OnTransition(context.Background(), "active", "preview"); err == nil {
+		t.Fatal("expected preview transition to fail without a hosts fragment")
+	}
+	for _, call := range exec.calls {
+		if containsArg(call.Args, "-I") || containsArg(call.Args, "-A") {
+			t.Fatalf("expected no jump without hosts overrides, got %s %v", call.Command, call.Args)
+		}
+	}
+	if jm.jumpActive {
+		t.Fatal("expected jump to stay inactive")
+	}
+}
+
+func TestJumpManagerMultipleHooks(t *testing.T) {
+	t.Parallel()
+
+	installed := map[string]bool{}
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			for i, arg := range args[:len(args)-1] {
+				hook := args[i+1]
+				switch arg {
+				case "-C":
+					if !installed[hook] {
+						return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+					}
+				case "-I":
+					installed[hook] = true
+				case "-D":
+					delete(installed, hook)
+				}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT", "PREROUTING"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil {
+		t.Fatalf("preview transition failed: %v", err)
+	}
+	if !installed["OUTPUT"] || !installed["PREROUTING"] {
+		t.Fatalf("expected the jump in every hook, got %v", installed)
+	}
+
+	if err := jm.OnTransition(ctx, "preview", "active"); err != nil {
+		t.Fatalf("active transition failed: %v", err)
+	}
+	if len(installed) != 0 {
+		t.Fatalf("expected the jump to be removed from every hook, got %v", installed)
+	}
+}
+
+func TestJumpManagerMirrorRole(t *testing.T) {
+	t.Parallel()
+
+	installed := map[string]bool{}
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			for i, arg := range args[:len(args)-1] {
+				key := args[3] + "/" + args[i+1]
+				switch arg {
+				case "-C":
+					if !installed[key] {
+						return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+					}
+				case "-I":
+					installed[key] = true
+				case "-D":
+					delete(installed, key)
+				}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:      exec,
+		table:         "nat",
+		hooks:         []string{"OUTPUT"},
+		chain:         "CANARY_DNAT",
+		activeValue:   "active",
+		previewValue:  "preview",
+		roleActions:   map[string]string{"shadow": roleActionMirror},
+		mirrorGateway: "10.1.0.9",
+		mappings: []discovery.ServiceMapping{
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		},
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "preview", "shadow"); err != nil {
+		t.Fatalf("shadow transition failed: %v", err)
+	}
+	if jm.jumpActive || !jm.mirrorActive {
+		t.Fatalf("expected jump off and mirror on, got jump %t mirror %t", jm.jumpActive, jm.mirrorActive)
+	}
+	if !installed["mangle/OUTPUT"] {
+		t.Fatalf("expected the mirror jump in mangle OUTPUT, got %v", installed)
+	}
+	tee := false
+	for _, call := range exec.calls {
+		if containsArg(call.Args, "TEE") && containsArg(call.Args, "10.1.0.9") {
+			tee = true
+		}
+	}
+	if !tee {
+		t.Fatalf("expected a TEE rule towards the gateway, calls: %v", exec.calls)
+	}
+
+	if err := jm.OnTransition(ctx, "shadow", "preview"); err != nil {
+		t.Fatalf("preview transition failed: %v", err)
+	}
+	if !jm.jumpActive || jm.mirrorActive {
+		t.Fatalf("expected jump on and mirror off, got jump %t mirror %t", jm.jumpActive, jm.mirrorActive)
+	}
+	if installed["mangle/OUTPUT"] || !installed["nat/OUTPUT"] {
+		t.Fatalf("expected only the nat jump to remain, got %v", installed)
+	}
+
+	if err := jm.ForceRole(ctx, "unknown"); err == nil {
+		t.Fatalf("expected a role without an action to be refused")
+	}
+}
+
+func TestJumpManagerReverseMode(t *testing.T) {
+	t.Parallel()
+
+	installed := map[string]bool{}
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			for i, arg := range args[:len(args)-1] {
+				key := args[3] + "/" + args[i+1] + "/" + args[len(args)-1]
+				switch arg {
+				case "-C":
+					if !installed[key] {
+						return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+					}
+				case "-I":
+					installed[key] = true
+				case "-D":
+					delete(installed, key)
+				}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		reverse:      true,
+		mappings: []discovery.ServiceMapping{
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		},
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "", "active"); err != nil {
+		t.Fatalf("active transition failed: %v", err)
+	}
+	if !jm.reverseActive || !installed["nat/OUTPUT/CANARY_DNAT_REVERSE"] {
+		t.Fatalf("expected the reverse jump while active, got %v", installed)
+	}
+	reversed := false
+	for _, call := range exec.calls {
+		if containsArg(call.Args, "10.0.0.2") && containsArg(call.Args, "10.0.0.1:80") {
+			reversed = true
+		}
+	}
+	if !reversed {
+		t.Fatalf("expected a DNAT rule from the preview to the active ClusterIP, calls: %v", exec.calls)
+	}
+
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil {
+		t.Fatalf("preview transition failed: %v", err)
+	}
+	if jm.reverseActive || installed["nat/OUTPUT/CANARY_DNAT_REVERSE"] || !installed["nat/OUTPUT/CANARY_DNAT"] {
+		t.Fatalf("expected only the forward jump while preview, got %v", installed)
+	}
+}
+
+type listingMockExecutor struct {
+	mockExecutor
+	listing string
+}
+
+func (l *listingMockExecutor) Output(context.Context, string, ...string) (string, error) {
+	return l.listing, nil
+}
+
+func TestJumpManagerSetPreviewPercent(t *testing.T) {
+	t.Parallel()
+
+	exec := &listingMockExecutor{listing: "-N CANARY_DNAT\n" +
+		"-A CANARY_DNAT -m statistic --mode random --probability 0.95000000007 -j RETURN\n" +
+		"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.0.2:80\n"}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor: exec,
+		table:    "nat",
+		chain:    "CANARY_DNAT",
+		split:    iptables.SplitConfig{BypassPercent: 95, Adjustable: true},
+		events:   newEventLog(10),
+		metrics:  metrics.NewMetrics(),
+		logger:   logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.SetPreviewPercent(ctx, 5); err != nil || len(exec.calls) != 0 {
+		t.Fatalf("expected an unchanged percent to be a no-op, got %v %v", err, exec.calls)
+	}
+	if err := jm.SetPreviewPercent(ctx, 25); err != nil {
+		t.Fatalf("SetPreviewPercent returned error: %v", err)
+	}
+	want := "-w 5 -t nat -R CANARY_DNAT 1 -m statistic --mode random --probability 0.75000 -m comment --comment ghostwire -j RETURN"
+	if len(exec.calls) != 1 || strings.Join(exec.calls[0].Args, " ") != want {
+		t.Fatalf("unexpected calls: %v\nwant: %s", exec.calls, want)
+	}
+	if jm.split.BypassPercent != 75 {
+		t.Fatalf("expected the split to record 75%% bypass, got %d", jm.split.BypassPercent)
+	}
+
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "ns",
+		Name:        "pod",
+		Annotations: map[string]string{k8s.AnnotationPreviewPercent: "100%"},
+	}})
+	if percent, err := readPreviewPercent(ctx, k8s.NewPodAnnotationReader(client, "ns", "pod"), 5); err != nil || percent != 100 {
+		t.Fatalf("readPreviewPercent = %d, %v; want 100", percent, err)
+	}
+	if percent, err := readPreviewPercent(ctx, k8s.NewConfigMapAnnotationReader(fake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "rollout"}}), "ns", "rollout"), 5); err != nil || percent != 5 {
+		t.Fatalf("readPreviewPercent without the annotation = %d, %v; want the fallback", percent, err)
+	}
+	for _, raw := range []string{"", "101", "-1", "12.5", "half"} {
+		if _, err := parsePreviewPercent(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+	for from, name := range map[string]string{"": "", "pod": "", "configmap/rollout": "rollout"} {
+		if got, err := parsePreviewPercentFrom(from); err != nil || got != name {
+			t.Fatalf("parsePreviewPercentFrom(%q) = %q, %v", from, got, err)
+		}
+	}
+	if _, err := parsePreviewPercentFrom("configmap/"); err == nil {
+		t.Fatal("expected a configmap source without a name to be rejected")
+	}
+}
+
+func TestParseRoleActions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		raw     any
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "unset", raw: nil, want: map[string]string{}},
+		{name: "csv", raw: "shadow=mirror, canary = route", want: map[string]string{"shadow": "mirror", "canary": "route"}},
+		{name: "yaml list", raw: []any{"shadow=mirror"}, want: map[string]string{"shadow": "mirror"}},
+		{name: "missing action", raw: "shadow", wantErr: `role-actions[0] "shadow": expected role=action`},
+		{name: "unknown action", raw: "shadow=tap", wantErr: `unknown action "tap"`},
+		{name: "fixed role", raw: "preview=mirror", wantErr: "already has a fixed action"},
+		{name: "duplicate role", raw: "shadow=mirror,shadow=bypass", wantErr: `role-actions[1] "shadow=bypass": role "shadow" is listed twice`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseRoleActions(tc.raw, "active", "preview")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoleActions returned error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for role, action := range tc.want {
+				if got[role] != action {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestJumpManagerReconfigure(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") && containsArg(args, "PREROUTING") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.Reconfigure(ctx, []string{"OUTPUT"}, "CANARY_DNAT", "blue", "green", false); err != nil {
+		t.Fatalf("Reconfigure returned error: %v", err)
+	}
+	if len(exec.calls) != 0 {
+		t.Fatalf("expected no iptables calls when hook and chain are unchanged, got %v", exec.calls)
+	}
+	if jm.activeValue != "blue" || jm.previewValue != "green" {
+		t.Fatalf("expected role values to update, got %q/%q", jm.activeValue, jm.previewValue)
+	}
+
+	jm.jumpActive = true
+	if err := jm.Reconfigure(ctx, []string{"PREROUTING"}, "CANARY_DNAT", "blue", "green", false); err != nil {
+		t.Fatalf("Reconfigure returned error: %v", err)
+	}
+	exec.assertCallsContain(t, []string{"-C", "-D", "-C", "-I"})
+	if !containsArg(exec.calls[3].Args, "PREROUTING") {
+		t.Fatalf("expected jump to be inserted into PREROUTING, got %v", exec.calls[3].Args)
+	}
+	if !slices.Equal(jm.hooks, []string{"PREROUTING"}) {
+		t.Fatalf("expected hooks to be updated, got %v", jm.hooks)
+	}
+}
+
+func TestJumpManagerReconfigureRebuildsChain(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		chainExistsResp: true,
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") && containsArg(args, "GW_DNAT_NEXT") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	var rebuilt []string
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		jumpActive:   true,
+		rebuild: func(_ context.Context, _ iptables.Executor, chain string) ([]discovery.ServiceMapping, error) {
+			rebuilt = append(rebuilt, chain)
+			return []discovery.ServiceMapping{{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP}}, nil
+		},
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.Reconfigure(ctx, []string{"OUTPUT"}, "GW_DNAT", "active", "preview", false); err != nil {
+		t.Fatalf("Reconfigure returned error: %v", err)
+	}
+	if !slices.Equal(rebuilt, []string{"GW_DNAT_NEXT"}) {
+		t.Fatalf("expected the new chain built before the jump moved, got %v", rebuilt)
+	}
+
+	// Jump to the built staging chain, drop the old jump, delete the old
+	// chain and promote staging to the new name.
+	exec.assertCallsContain(t, []string{"-C", "-I", "-C", "-D", "-F", "-X", "-F", "-X", "-E"})
+	if insert := exec.calls[1].Args; !containsArg(insert, "GW_DNAT_NEXT") {
+		t.Fatalf("expected the jump to target the built chain, got %v", insert)
+	}
+	if deleted := exec.calls[5].Args; deleted[len(deleted)-1] != "CANARY_DNAT" {
+		t.Fatalf("expected the previous chain deleted, got %v", deleted)
+	}
+	if rename := exec.calls[8].Args; rename[len(rename)-2] != "GW_DNAT_NEXT" || rename[len(rename)-1] != "GW_DNAT" {
+		t.Fatalf("expected staging renamed to the new chain, got %v", rename)
+	}
+	if jm.chain != "GW_DNAT" {
+		t.Fatalf("expected chain updated, got %q", jm.chain)
+	}
+
+	// A changed discovery or exclusion setting rebuilds the same chain.
+	if err := jm.Reconfigure(ctx, []string{"OUTPUT"}, "GW_DNAT", "active", "preview", true); err != nil {
+		t.Fatalf("Reconfigure returned error: %v", err)
+	}
+	if !slices.Equal(rebuilt, []string{"GW_DNAT_NEXT", "GW_DNAT_NEXT"}) {
+		t.Fatalf("expected a rebuild for changed rule settings, got %v", rebuilt)
+	}
+
+	jm.rebuild = nil
+	if err := jm.Reconfigure(ctx, []string{"OUTPUT"}, "OTHER_DNAT", "active", "preview", false); err == nil {
+		t.Fatal("expected a chain change without resync to be rejected")
+	}
+	if jm.chain != "GW_DNAT" {
+		t.Fatalf("expected chain unchanged after rejection, got %q", jm.chain)
+	}
+}
+
 func TestMetricsLabelReader(t *testing.T) {
 	t.Parallel()
 
@@ -452,3 +982,811 @@ type stubLabelReader struct {
 func (s *stubLabelReader) GetLabel(context.Context, string) (string, error) {
 	return s.value, s.err
 }
+
+func TestJumpManagerExpirePreview(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		events:       newEventLog(10),
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil {
+		t.Fatalf("preview transition failed: %v", err)
+	}
+	if !jm.jumpActive || jm.jumpSince.IsZero() {
+		t.Fatalf("expected the jump to be active with a start time, got %t %v", jm.jumpActive, jm.jumpSince)
+	}
+	since := jm.jumpSince
+
+	if expired, err := jm.ExpirePreview(ctx, time.Hour, since.Add(59*time.Minute)); err != nil || expired {
+		t.Fatalf("expected no expiry within the ttl, got %t %v", expired, err)
+	}
+	if err := jm.Refresh(ctx); err != nil || !jm.jumpSince.Equal(since) {
+		t.Fatalf("a refresh must not restart the ttl: %v, %v -> %v", err, since, jm.jumpSince)
+	}
+
+	expired, err := jm.ExpirePreview(ctx, time.Hour, since.Add(61*time.Minute))
+	if err != nil || !expired {
+		t.Fatalf("expected the preview to expire, got %t %v", expired, err)
+	}
+	if jm.jumpActive || jm.Status().Role != "active" {
+		t.Fatalf("expected the jump removed and the active role reported, got %t %q", jm.jumpActive, jm.Status().Role)
+	}
+	if last := exec.calls[len(exec.calls)-1]; !containsArg(last.Args, "-D") {
+		t.Fatalf("expected the jump to be deleted, last call %v", last.Args)
+	}
+	var kinds []string
+	for _, event := range jm.events.Events() {
+		kinds = append(kinds, event.Kind)
+	}
+	if !slices.Contains(kinds, eventExpire) {
+		t.Fatalf("expected an expire event, got %v", kinds)
+	}
+
+	if expired, _ := jm.ExpirePreview(ctx, time.Hour, since.Add(2*time.Hour)); expired {
+		t.Fatal("an inactive jump cannot expire again")
+	}
+}
+
+func TestJumpManagerPreviewCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	notReady := false
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "orders-preview-abc", Labels: map[string]string{discoveryv1.LabelServiceName: "orders-preview"}},
+		Endpoints:  []discoveryv1.Endpoint{{Addresses: []string{"10.2.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}}},
+	}
+	client := fake.NewSimpleClientset(slice)
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     &mockExecutor{},
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		services:     client,
+		namespace:    "shop",
+		healthCheck:  true,
+		mappings: []discovery.ServiceMapping{
+			{Namespace: "shop", ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewServiceName: "orders-preview"},
+		},
+		events:  newEventLog(10),
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil || !jm.jumpActive {
+		t.Fatalf("preview transition failed: %v (jump active %t)", err, jm.jumpActive)
+	}
+
+	if err := jm.CheckPreviewHealth(ctx); err != nil {
+		t.Fatalf("CheckPreviewHealth returned error: %v", err)
+	}
+	health := jm.PreviewHealth()
+	if jm.jumpActive || health == nil || !health.CircuitOpen || !slices.Equal(health.Unhealthy, []string{"shop/orders-preview"}) {
+		t.Fatalf("expected the circuit to open and the jump to go, got jump %t health %+v", jm.jumpActive, health)
+	}
+
+	if err := jm.OnTransition(ctx, "preview", "active"); err != nil {
+		t.Fatalf("active transition failed: %v", err)
+	}
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil || jm.jumpActive {
+		t.Fatalf("an open circuit must keep the jump off, got %v (jump active %t)", err, jm.jumpActive)
+	}
+
+	ready := true
+	slice.Endpoints[0].Conditions.Ready = &ready
+	if _, err := client.DiscoveryV1().EndpointSlices("shop").Update(ctx, slice, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jm.CheckPreviewHealth(ctx); err != nil {
+		t.Fatalf("CheckPreviewHealth returned error: %v", err)
+	}
+	if !jm.jumpActive || jm.PreviewHealth().CircuitOpen {
+		t.Fatalf("expected the circuit to close and the jump to return, got jump %t health %+v", jm.jumpActive, jm.PreviewHealth())
+	}
+}
+
+func TestScheduleLabelReaderHoldsActiveOutsideWindow(t *testing.T) {
+	t.Parallel()
+
+	businessHours, err := schedule.Parse("* 9-16 * * mon-fri", "UTC")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	now := time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC)
+	logger, logs := newTestLogger()
+	delegate := &stubLabelReader{value: "preview"}
+	reader := &scheduleLabelReader{
+		delegate:   delegate,
+		schedule:   businessHours,
+		activeRole: func() string { return "active" },
+		metrics:    metrics.NewMetrics(),
+		logger:     logger,
+		now:        func() time.Time { return now },
+	}
+
+	if value, err := reader.GetLabel(context.Background(), "role"); err != nil || value != "active" {
+		t.Fatalf("outside the window GetLabel = %q, %v; want active", value, err)
+	}
+	if !strings.Contains(logs.String(), "outside the routing schedule") {
+		t.Fatalf("expected the override to be logged, got %q", logs.String())
+	}
+
+	now = time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	if value, err := reader.GetLabel(context.Background(), "role"); err != nil || value != "preview" {
+		t.Fatalf("inside the window GetLabel = %q, %v; want preview", value, err)
+	}
+	if reader.overriding {
+		t.Fatal("expected the override to end once the window opens")
+	}
+
+	now = time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	delegate.value = "active"
+	if value, _ := reader.GetLabel(context.Background(), "role"); value != "active" || reader.overriding {
+		t.Fatalf("an active label is never overridden, got %q (overriding %t)", value, reader.overriding)
+	}
+}
+
+type recordingReporter struct {
+	statuses []routingStatus
+}
+
+func (r *recordingReporter) Report(_ context.Context, status routingStatus) {
+	r.statuses = append(r.statuses, status)
+}
+
+func TestJumpManagerReportsStatus(t *testing.T) {
+	t.Parallel()
+
+	failRemove := false
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") {
+				if failRemove {
+					return nil
+				}
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			if containsArg(args, "-D") {
+				return errors.New("delete failed")
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	reporter := &recordingReporter{}
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		ruleCount:    3,
+		reporters:    []statusReporter{reporter},
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.OnTransition(ctx, "active", "preview"); err != nil {
+		t.Fatalf("OnTransition returned error: %v", err)
+	}
+	failRemove = true
+	if err := jm.OnTransition(ctx, "preview", "active"); err == nil {
+		t.Fatalf("expected remove failure")
+	}
+
+	if len(reporter.statuses) != 2 {
+		t.Fatalf("expected two status reports, got %d", len(reporter.statuses))
+	}
+	first := reporter.statuses[0]
+	if first.Role != "preview" || !first.JumpActive || first.RuleCount != 3 || first.LastError != "" || first.LastTransition.IsZero() || first.NATChain != "CANARY_DNAT" || first.JumpHook != "OUTPUT" {
+		t.Fatalf("unexpected first status: %+v", first)
+	}
+	second := reporter.statuses[1]
+	if second.Role != "active" || !second.JumpActive || !strings.Contains(second.LastError, "remove jump") {
+		t.Fatalf("unexpected second status: %+v", second)
+	}
+}
+
+func TestJumpManagerForceRoleAndRefresh(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	dnatMap := filepath.Join(t.TempDir(), "dnat.map")
+	if err := os.WriteFile(dnatMap, []byte("# header\na:80/TCP 10.0.0.1 -> 10.0.0.2\nb:80/TCP 10.0.0.3 -> 10.0.0.4\n"), 0o600); err != nil {
+		t.Fatalf("write dnat map: %v", err)
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		dnatMapPath:  dnatMap,
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	ctx := context.Background()
+	if err := jm.ForceRole(ctx, "shadow"); err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+	if err := jm.ForceRole(ctx, "preview"); err != nil {
+		t.Fatalf("ForceRole returned error: %v", err)
+	}
+	if status := jm.Status(); status.Role != "preview" || !status.JumpActive {
+		t.Fatalf("unexpected status after ForceRole: %+v", status)
+	}
+
+	exec.calls = nil
+	if err := jm.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	exec.assertCallsContain(t, []string{"-C", "-I"})
+	if status := jm.Status(); status.RuleCount != 2 {
+		t.Fatalf("expected refreshed rule count 2, got %d", status.RuleCount)
+	}
+}
+
+func TestRoleHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		auth       string
+		body       string
+		wantStatus int
+		wantJump   bool
+	}{
+		{name: "missing token", method: http.MethodPost, body: `{"role":"preview"}`, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, auth: "Bearer nope", body: `{"role":"preview"}`, wantStatus: http.StatusUnauthorized},
+		{name: "wrong method", method: http.MethodGet, auth: "Bearer s3cret", wantStatus: http.StatusMethodNotAllowed},
+		{name: "malformed body", method: http.MethodPost, auth: "Bearer s3cret", body: `preview`, wantStatus: http.StatusBadRequest},
+		{name: "unknown role", method: http.MethodPost, auth: "Bearer s3cret", body: `{"role":"shadow"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "preview applied", method: http.MethodPost, auth: "Bearer s3cret", body: `{"role":"preview"}`, wantStatus: http.StatusOK, wantJump: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			exec := &mockExecutor{
+				runHook: func(command string, args []string) error {
+					if containsArg(args, "-C") {
+						return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+					}
+					return nil
+				},
+			}
+			logger, _ := newTestLogger()
+			jm := &jumpManager{
+				executor:     exec,
+				table:        "nat",
+				hooks:        []string{"OUTPUT"},
+				chain:        "CANARY_DNAT",
+				activeValue:  "active",
+				previewValue: "preview",
+				metrics:      metrics.NewMetrics(),
+				logger:       logger,
+			}
+			handler := buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), nil, &roleHandler{jm: jm, auth: &adminAuth{token: "s3cret"}, logger: logger}, nil, nil)
+
+			req := httptest.NewRequest(tc.method, "/role", strings.NewReader(tc.body))
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if jm.Status().JumpActive != tc.wantJump {
+				t.Fatalf("expected jump active=%v", tc.wantJump)
+			}
+			if tc.wantStatus == http.StatusOK && !strings.Contains(rec.Body.String(), `"role":"preview"`) {
+				t.Fatalf("expected status body, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBuildWatcherMuxWithoutRoleHandler(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), nil, nil, nil, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/role", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected /role to be absent without a token, got %d", rec.Code)
+	}
+}
+
+func TestJumpManagerTogglesConntrackJump(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:     exec,
+		table:        "nat",
+		hooks:        []string{"OUTPUT"},
+		chain:        "CANARY_DNAT",
+		activeValue:  "active",
+		previewValue: "preview",
+		conntrack:    true,
+		metrics:      metrics.NewMetrics(),
+		logger:       logger,
+	}
+
+	if err := jm.OnTransition(context.Background(), "active", "preview"); err != nil {
+		t.Fatalf("OnTransition returned error: %v", err)
+	}
+
+	var rawInserts int
+	for _, call := range exec.calls {
+		if containsArg(call.Args, "raw") && containsArg(call.Args, "-I") {
+			rawInserts++
+		}
+	}
+	if rawInserts != 1 {
+		t.Fatalf("expected one raw-table jump insert, got calls %v", exec.calls)
+	}
+}
+
+func TestJumpManagerCheckDrift(t *testing.T) {
+	t.Parallel()
+
+	dnatMap := filepath.Join(t.TempDir(), "dnat.map")
+	contents := "# header\norders:80/TCP 10.0.0.1 -> 10.0.1.1 namespace=shop preview=orders-preview\ncart:80/TCP 10.0.0.2 -> 10.0.1.2 namespace=shop preview=cart-preview\n"
+	if err := os.WriteFile(dnatMap, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write dnat map: %v", err)
+	}
+	service := func(name, ip string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"}, Spec: corev1.ServiceSpec{ClusterIP: ip}}
+	}
+	client := fake.NewSimpleClientset(
+		service("orders", "10.0.0.1"),
+		service("orders-preview", "10.0.1.9"),
+		service("cart", "10.0.0.2"),
+		service("cart-preview", "10.0.1.2"),
+	)
+
+	exec := &mockExecutor{}
+	metricsCollector := metrics.NewMetrics()
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:    exec,
+		table:       "nat",
+		chain:       "CANARY_DNAT",
+		dnatMapPath: dnatMap,
+		services:    client,
+		namespace:   "shop",
+		metrics:     metricsCollector,
+		logger:      logger,
+	}
+
+	if err := jm.CheckDrift(context.Background()); err != nil {
+		t.Fatalf("CheckDrift returned error: %v", err)
+	}
+	if value, _ := findMetricValue(t, scrapeMetrics(t, metricsCollector), "ghostwire_stale_mappings", ""); value != 1 {
+		t.Fatalf("expected one stale mapping, got %v", value)
+	}
+	if len(exec.calls) != 0 {
+		t.Fatalf("expected no iptables calls without repair, got %+v", exec.calls)
+	}
+
+	jm.driftRepair = true
+	if err := jm.CheckDrift(context.Background()); err != nil {
+		t.Fatalf("CheckDrift with repair returned error: %v", err)
+	}
+	exec.assertCallsContain(t, []string{"-C", "-A", "-D"})
+	if value, _ := findMetricValue(t, scrapeMetrics(t, metricsCollector), "ghostwire_stale_mappings", ""); value != 0 {
+		t.Fatalf("expected stale mappings cleared after repair, got %v", value)
+	}
+
+	rewritten, err := os.ReadFile(dnatMap)
+	if err != nil {
+		t.Fatalf("read dnat map: %v", err)
+	}
+	if !strings.Contains(string(rewritten), "orders:80/TCP 10.0.0.1 -> 10.0.1.9") {
+		t.Fatalf("expected dnat map to record repaired preview ip, got:\n%s", rewritten)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	t.Parallel()
+
+	dnatMap := filepath.Join(t.TempDir(), "dnat.map")
+	if err := os.WriteFile(dnatMap, []byte("# header\norders:80/TCP 10.0.0.1 -> 10.0.1.1 namespace=shop preview=orders-preview\n"), 0o600); err != nil {
+		t.Fatalf("write dnat map: %v", err)
+	}
+	logger, _ := newTestLogger()
+	jm := &jumpManager{
+		executor:    &mockExecutor{},
+		table:       "nat",
+		chain:       "CANARY_DNAT",
+		dnatMapPath: dnatMap,
+		metrics:     metrics.NewMetrics(),
+		logger:      logger,
+	}
+	if err := jm.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+
+	handler := buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), &statusHandler{jm: jm}, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if body.RuleCount != 1 || len(body.Mappings) != 1 || body.Mappings[0].PreviewServiceName != "orders-preview" {
+		t.Fatalf("unexpected status body: %+v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestJumpManagerResync(t *testing.T) {
+	t.Parallel()
+
+	exec := &mockExecutor{
+		chainExistsResp: true,
+		runHook: func(command string, args []string) error {
+			if containsArg(args, "-C") && containsArg(args, "CANARY_DNAT_NEXT") {
+				return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+			}
+			return nil
+		},
+	}
+	logger, _ := newTestLogger()
+	var rebuiltChain string
+	jm := &jumpManager{
+		executor:   exec,
+		table:      "nat",
+		hooks:      []string{"OUTPUT"},
+		chain:      "CANARY_DNAT",
+		jumpActive: true,
+		rebuild: func(_ context.Context, _ iptables.Executor, chain string) ([]discovery.ServiceMapping, error) {
+			rebuiltChain = chain
+			return []discovery.ServiceMapping{{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP}}, nil
+		},
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+
+	if err := jm.Resync(context.Background()); err != nil {
+		t.Fatalf("Resync returned error: %v", err)
+	}
+	if rebuiltChain != "CANARY_DNAT_NEXT" {
+		t.Fatalf("expected rules rebuilt into staging chain, got %q", rebuiltChain)
+	}
+
+	// Check staging jump, insert it, check and remove the live jump, then
+	// delete the live chain and rename staging over it.
+	exec.assertCallsContain(t, []string{"-C", "-I", "-C", "-D", "-F", "-X", "-E"})
+	rename := exec.calls[len(exec.calls)-1].Args
+	if !containsArg(rename, "CANARY_DNAT_NEXT") || rename[len(rename)-1] != "CANARY_DNAT" {
+		t.Fatalf("expected staging chain renamed to live chain, got %v", rename)
+	}
+	if len(jm.Mappings()) != 1 || jm.Status().RuleCount != 1 {
+		t.Fatalf("expected mappings replaced after resync, got %+v", jm.Mappings())
+	}
+}
+
+var registerResyncSource sync.Once
+
+func TestRebuildRulesUsesInjectedExecutor(t *testing.T) {
+	registerResyncSource.Do(func() {
+		discovery.RegisterSource("resync-test", func(string, *slog.Logger) (discovery.MappingSource, error) {
+			return discovery.SourceFunc{SourceName: "resync-test", ListFunc: func(context.Context) ([]discovery.ServiceMapping, error) {
+				return []discovery.ServiceMapping{{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"}}, nil
+			}}, nil
+		})
+	})
+	t.Cleanup(func() {
+		viper.Set("mapping-sources", nil)
+		viper.Set("iptables-dnat-map", nil)
+	})
+	viper.Set("mapping-sources", "resync-test")
+	viper.Set("iptables-dnat-map", filepath.Join(t.TempDir(), "dnat.map"))
+
+	// The executor stands in for the privsep executor the watcher was built
+	// with.
+	exec := &mockExecutor{}
+	logger, _ := newTestLogger()
+	mappings, err := rebuildRules(context.Background(), exec, "CANARY_DNAT_NEXT", logger)
+	if err != nil {
+		t.Fatalf("rebuildRules returned error: %v", err)
+	}
+	if len(mappings) != 1 {
+		t.Fatalf("expected the discovered mapping, got %+v", mappings)
+	}
+	dnat := false
+	for _, call := range exec.calls {
+		if containsArg(call.Args, "CANARY_DNAT_NEXT") && containsArg(call.Args, "DNAT") {
+			dnat = true
+		}
+	}
+	if !dnat {
+		t.Fatalf("expected the dnat rule written through the injected executor, got %v", exec.calls)
+	}
+}
+
+func TestInitTimeout(t *testing.T) {
+	t.Cleanup(func() { viper.Set("init-timeout", nil) })
+
+	if got, want := initTimeout(), 30*time.Second+3*7500*time.Millisecond; got != want {
+		t.Fatalf("derived timeout = %s, want %s", got, want)
+	}
+
+	viper.Set("init-timeout", 2*time.Minute)
+	if got := initTimeout(); got != 2*time.Minute {
+		t.Fatalf("configured timeout = %s, want 2m", got)
+	}
+}
+
+func TestJumpManagerReconcile(t *testing.T) {
+	t.Parallel()
+
+	missingRule := func(command string, args []string) error {
+		return &iptables.CommandError{Command: command, Args: append([]string(nil), args...), Err: &exitErr{code: 1}}
+	}
+	exec := &listingMockExecutor{
+		mockExecutor: mockExecutor{
+			chainExistsResp: true,
+			runHook: func(command string, args []string) error {
+				if containsArg(args, "-C") {
+					return missingRule(command, args)
+				}
+				return nil
+			},
+		},
+		listing: "-N CANARY_DNAT\n-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80\n",
+	}
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		// Mixed address families get no rule, so their absence is no drift.
+		{ServiceName: "legacy", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5", PreviewClusterIP: "fd00::5"},
+	}
+	metricsCollector := metrics.NewMetrics()
+	logger, _ := newTestLogger()
+	var rebuiltChain string
+	jm := &jumpManager{
+		executor:   exec,
+		table:      "nat",
+		hooks:      []string{"OUTPUT"},
+		chain:      "CANARY_DNAT",
+		jumpActive: true,
+		mappings:   mappings,
+		rebuild: func(_ context.Context, _ iptables.Executor, chain string) ([]discovery.ServiceMapping, error) {
+			rebuiltChain = chain
+			return mappings, nil
+		},
+		events:  newEventLog(10),
+		metrics: metricsCollector,
+		logger:  logger,
+	}
+
+	// Rules intact but the jump flushed out of OUTPUT: only the jump is put
+	// back.
+	if err := jm.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile returned error: %v", err)
+	}
+	if rebuiltChain != "" {
+		t.Fatalf("expected intact rules to be left alone, rebuilt %q", rebuiltChain)
+	}
+	exec.assertCallsContain(t, []string{"-C", "-C", "-I"})
+	if value, _ := findMetricValue(t, scrapeMetrics(t, metricsCollector), "ghostwire_reconcile_repairs_total", `kind="jump"`); value != 1 {
+		t.Fatalf("expected one jump repair, got %v", value)
+	}
+
+	// The chain emptied by a flush of nat is rebuilt through a resync.
+	exec.mu.Lock()
+	exec.calls = nil
+	exec.runHook = func(command string, args []string) error {
+		if containsArg(args, "-C") && containsArg(args, "CANARY_DNAT_NEXT") {
+			return missingRule(command, args)
+		}
+		return nil
+	}
+	exec.mu.Unlock()
+	exec.listing = "-N CANARY_DNAT\n"
+	if err := jm.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile returned error: %v", err)
+	}
+	if rebuiltChain != "CANARY_DNAT_NEXT" {
+		t.Fatalf("expected drifted rules rebuilt into the staging chain, got %q", rebuiltChain)
+	}
+	body := scrapeMetrics(t, metricsCollector)
+	if value, _ := findMetricValue(t, body, "ghostwire_reconcile_repairs_total", `kind="rules"`); value != 1 {
+		t.Fatalf("expected one rules repair, got %v", value)
+	}
+	if value, _ := findMetricValue(t, body, "ghostwire_reconcile_repairs_total", `kind="jump"`); value != 1 {
+		t.Fatalf("expected the restored jump to be left alone, got %v jump repairs", value)
+	}
+
+	repairs := 0
+	for _, event := range jm.events.Events() {
+		if event.Kind == eventRepair {
+			repairs++
+		}
+	}
+	if repairs != 2 {
+		t.Fatalf("expected two repair events, got %+v", jm.events.Events())
+	}
+}
+
+func TestJumpManagerCheckRuleDrift(t *testing.T) {
+	t.Parallel()
+
+	exec := &listingMockExecutor{
+		listing: "-N CANARY_DNAT\n" +
+			"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.9:80\n" +
+			"-A CANARY_DNAT -d 10.0.0.7/32 -p tcp -m tcp --dport 443 -j DNAT --to-destination 10.0.1.7:443\n",
+	}
+	metricsCollector := metrics.NewMetrics()
+	logger, logs := newTestLogger()
+	jm := &jumpManager{
+		executor: exec,
+		table:    "nat",
+		hooks:    []string{"OUTPUT"},
+		chain:    "CANARY_DNAT",
+		mappings: []discovery.ServiceMapping{
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+			{ServiceName: "cart", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+			// Skipped by the rule writers; never reported as drift.
+			{ServiceName: "legacy", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5", PreviewClusterIP: "fd00::5"},
+		},
+		metrics: metricsCollector,
+		logger:  logger,
+	}
+
+	discrepancies, err := jm.CheckRuleDrift(context.Background())
+	if err != nil {
+		t.Fatalf("CheckRuleDrift returned error: %v", err)
+	}
+	if len(discrepancies) != 3 {
+		t.Fatalf("expected a missing, an extra and a wrong-target rule, got %+v", discrepancies)
+	}
+	body := scrapeMetrics(t, metricsCollector)
+	for _, kind := range []string{"missing", "extra", "wrong_target"} {
+		if value, _ := findMetricValue(t, body, "ghostwire_rule_drift", `kind="`+kind+`"`); value != 1 {
+			t.Fatalf("expected one %s rule, got %v", kind, value)
+		}
+	}
+	for _, want := range []string{
+		"dnat chain differs from the dnat map",
+		"cart:80/TCP 10.0.0.2 -> 10.0.1.2:80",
+		"orders:80/TCP 10.0.0.1 -> 10.0.1.1:80, live 10.0.1.9:80",
+		"-A CANARY_DNAT -d 10.0.0.7/32",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("expected warning to list %q, got %s", want, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "legacy") {
+		t.Fatalf("expected the skipped mapping left out of the warning, got %s", logs.String())
+	}
+	if len(exec.calls) != 0 {
+		t.Fatalf("expected the check to change nothing, got %+v", exec.calls)
+	}
+
+	logs.Reset()
+	exec.listing = "-N CANARY_DNAT\n" +
+		"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80\n" +
+		"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.2:80\n"
+	if discrepancies, err := jm.CheckRuleDrift(context.Background()); err != nil || len(discrepancies) != 0 {
+		t.Fatalf("expected a matching chain, got %+v, %v", discrepancies, err)
+	}
+	if value, _ := findMetricValue(t, scrapeMetrics(t, metricsCollector), "ghostwire_rule_drift", `kind="missing"`); value != 0 {
+		t.Fatalf("expected the gauge cleared, got %v", value)
+	}
+	if strings.Contains(logs.String(), "dnat chain differs") {
+		t.Fatalf("expected no warning for a matching chain, got %s", logs.String())
+	}
+}
+
+func TestResyncHandler(t *testing.T) {
+	t.Parallel()
+
+	logger, _ := newTestLogger()
+	rebuildErr := errors.New("discovery failed")
+	jm := &jumpManager{
+		executor: &mockExecutor{},
+		table:    "nat",
+		hooks:    []string{"OUTPUT"},
+		chain:    "CANARY_DNAT",
+		rebuild: func(context.Context, iptables.Executor, string) ([]discovery.ServiceMapping, error) {
+			return nil, rebuildErr
+		},
+		metrics: metrics.NewMetrics(),
+		logger:  logger,
+	}
+	handler := buildWatcherMux(metrics.NewMetrics(), metrics.NewHealthChecker(), nil, nil, &resyncHandler{jm: jm, auth: &adminAuth{token: "s3cret"}, logger: logger}, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+		want   int
+	}{
+		{name: "wrong method", method: http.MethodGet, auth: "Bearer s3cret", want: http.StatusMethodNotAllowed},
+		{name: "missing token", method: http.MethodPost, want: http.StatusUnauthorized},
+		{name: "rebuild failure", method: http.MethodPost, auth: "Bearer s3cret", want: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/admin/resync", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("expected status %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	jm.rebuild = func(context.Context, iptables.Executor, string) ([]discovery.ServiceMapping, error) {
+		return []discovery.ServiceMapping{{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP}}, nil
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/resync", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(body.Mappings) != 1 || body.RuleCount != 1 {
+		t.Fatalf("unexpected resync response: %+v", body)
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,19 +11,76 @@ import (
 // Config captures the runtime settings for ghostwire components. Service
 // discovery is fully automatic; no explicit service lists are required.
 type Config struct {
-	Namespace         string `mapstructure:"namespace"`
-	RoleLabelKey      string `mapstructure:"role_label_key"`
-	RoleActive        string `mapstructure:"role_active"`
-	RolePreview       string `mapstructure:"role_preview"`
-	SvcPreviewPattern string `mapstructure:"svc_preview_pattern"`
-	DNSSuffix         string `mapstructure:"dns_suffix"`
-	NATChain          string `mapstructure:"nat_chain"`
-	JumpHook          string `mapstructure:"jump_hook"`
-	ExcludeCIDRs      string `mapstructure:"exclude_cidrs"`
-	PollInterval      string `mapstructure:"poll_interval"`
-	RefreshInterval   string `mapstructure:"refresh_interval"`
-	IPv6              bool   `mapstructure:"ipv6"`
-	LogLevel          string `mapstructure:"log_level"`
+	Namespace                   string        `mapstructure:"namespace"`
+	ConfigName                  string        `mapstructure:"config-name"`
+	RoleLabelKey                string        `mapstructure:"role-label-key"`
+	RoleActive                  string        `mapstructure:"role-active"`
+	RolePreview                 string        `mapstructure:"role-preview"`
+	SvcPreviewPattern           string        `mapstructure:"svc-preview-pattern"`
+	ActiveSuffix                string        `mapstructure:"active-suffix"`
+	PreviewSuffix               string        `mapstructure:"preview-suffix"`
+	ServiceSelector             string        `mapstructure:"service-selector"`
+	ServiceEvents               bool          `mapstructure:"service-events"`
+	PublishMappings             bool          `mapstructure:"publish-mappings"`
+	ExcludeServiceSelector      string        `mapstructure:"exclude-service-selector"`
+	PairBy                      string        `mapstructure:"pair-by"`
+	ReleaseLabel                string        `mapstructure:"release-label"`
+	StatefulOrdinals            bool          `mapstructure:"stateful-ordinals"`
+	RecordTargetPorts           bool          `mapstructure:"record-target-ports"`
+	ReleasePattern              string        `mapstructure:"release-pattern"`
+	MappingOverrides            bool          `mapstructure:"mapping-overrides"`
+	MappingsConfigMap           string        `mapstructure:"mappings-configmap"`
+	MappingsFile                string        `mapstructure:"mappings-file"`
+	MappingsFilePrecedence      string        `mapstructure:"mappings-file-precedence"`
+	MappingSources              string        `mapstructure:"mapping-sources"`
+	SkipApply                   bool          `mapstructure:"skip-apply"`
+	RestoreFile                 string        `mapstructure:"restore-file"`
+	RuleBackend                 string        `mapstructure:"rule-backend"`
+	ControllerNamespaces        string        `mapstructure:"controller-namespaces"`
+	ControllerNamespaceSelector string        `mapstructure:"controller-namespace-selector"`
+	ControllerInterval          time.Duration `mapstructure:"controller-interval"`
+	ControllerWorkers           int           `mapstructure:"controller-workers"`
+	ControllerNamespaceTimeout  time.Duration `mapstructure:"controller-namespace-timeout"`
+	ControllerMetricsAddr       string        `mapstructure:"controller-metrics-addr"`
+	DNSMode                     bool          `mapstructure:"dns-mode"`
+	DNSSuffix                   string        `mapstructure:"dns-suffix"`
+	DNSHostsFragment            string        `mapstructure:"dns-hosts-fragment"`
+	DNSHostsPath                string        `mapstructure:"dns-hosts-path"`
+	DNSListenAddr               string        `mapstructure:"dns-listen-addr"`
+	DNSUpstream                 string        `mapstructure:"dns-upstream"`
+	InjectorListenAddr          string        `mapstructure:"injector-listen-addr"`
+	InjectorTLSCert             string        `mapstructure:"injector-tls-cert"`
+	InjectorTLSKey              string        `mapstructure:"injector-tls-key"`
+	TLSReloadInterval           time.Duration `mapstructure:"tls-reload-interval"`
+	NATChain                    string        `mapstructure:"nat-chain"`
+	JumpHook                    string        `mapstructure:"jump-hook"`
+	JumpPosition                string        `mapstructure:"jump-position"`
+	ExcludeCIDRs                []string      `mapstructure:"exclude-cidrs"`
+	ExcludeIPSet                string        `mapstructure:"exclude-ipset"`
+	NotrackCIDRs                string        `mapstructure:"notrack-cidrs"`
+	ExcludeCgroups              string        `mapstructure:"exclude-cgroups"`
+	DNATProtocols               string        `mapstructure:"dnat-protocols"`
+	PollInterval                time.Duration `mapstructure:"poll-interval"`
+	APIRetryAttempts            int           `mapstructure:"api-retry-attempts"`
+	APIRetryInitialBackoff      time.Duration `mapstructure:"api-retry-initial-backoff"`
+	APIRetryMaxBackoff          time.Duration `mapstructure:"api-retry-max-backoff"`
+	InitTimeout                 time.Duration `mapstructure:"init-timeout"`
+	ReadyMarker                 string        `mapstructure:"ready-marker"`
+	ReadyTimeout                time.Duration `mapstructure:"ready-timeout"`
+	RefreshInterval             time.Duration `mapstructure:"refresh-interval"`
+	DriftCheckInterval          time.Duration `mapstructure:"drift-check-interval"`
+	DriftRepair                 bool          `mapstructure:"drift-repair"`
+	ReconcileInterval           time.Duration `mapstructure:"reconcile-interval"`
+	RuleDriftInterval           time.Duration `mapstructure:"rule-drift-interval"`
+	IPv6                        bool          `mapstructure:"ipv6"`
+	Multiport                   bool          `mapstructure:"multiport"`
+	DebugLog                    string        `mapstructure:"debug-log"`
+	DebugLogScope               string        `mapstructure:"debug-log-scope"`
+	DebugLogPrefix              string        `mapstructure:"debug-log-prefix"`
+	DebugLogRate                string        `mapstructure:"debug-log-rate"`
+	DebugLogNFLOGGroup          int           `mapstructure:"debug-log-nflog-group"`
+	StrictParsing               bool          `mapstructure:"strict-parsing"`
+	LogLevel                    string        `mapstructure:"log-level"`
 }
 
 // Load reads configuration values from viper into a Config instance.
@@ -32,3 +91,32 @@ func Load() (Config, error) {
 	}
 	return cfg, nil
 }
+
+// Validate rejects settings that are malformed or contradict each other. It
+// covers the checks that do not need a cluster or the iptables binaries, so
+// every command can run it before doing any work.
+func (c Config) Validate() error {
+	if strings.TrimSpace(c.RoleLabelKey) == "" {
+		return fmt.Errorf("role-label-key is empty")
+	}
+	if c.RoleActive == c.RolePreview {
+		return fmt.Errorf("role-active and role-preview are both %q", c.RoleActive)
+	}
+	if c.ActiveSuffix != "" && c.ActiveSuffix == c.PreviewSuffix {
+		return fmt.Errorf("active-suffix and preview-suffix are both %q", c.ActiveSuffix)
+	}
+	if _, err := ParseCIDRList("exclude-cidrs", c.ExcludeCIDRs, c.StrictParsing); err != nil {
+		return err
+	}
+	switch c.PairBy {
+	case "", "name", "release":
+	default:
+		return fmt.Errorf("pair-by %q is not supported (expected name or release)", c.PairBy)
+	}
+	switch c.MappingsFilePrecedence {
+	case "", "file", "discovery":
+	default:
+		return fmt.Errorf("mappings-file-precedence %q is not supported (expected file or discovery)", c.MappingsFilePrecedence)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestDefaultsLoadAndValidate(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	SetDefaults()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults do not validate: %v", err)
+	}
+	if cfg.NATChain != DefaultNATChain || cfg.JumpHook != DefaultJumpHook {
+		t.Errorf("chain/hook = %s/%s, want %s/%s", cfg.NATChain, cfg.JumpHook, DefaultNATChain, DefaultJumpHook)
+	}
+	if cfg.PollInterval != DefaultPollInterval {
+		t.Errorf("PollInterval = %s, want %s", cfg.PollInterval, DefaultPollInterval)
+	}
+}
+
+func TestLoadDecodesEnvironmentStrings(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	SetDefaults()
+	viper.Set("poll-interval", "45s")
+	viper.Set("ipv6", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PollInterval != 45*time.Second {
+		t.Errorf("PollInterval = %s, want 45s", cfg.PollInterval)
+	}
+	if !cfg.IPv6 {
+		t.Error("IPv6 = false, want true")
+	}
+}
+
+func TestValidateRejectsConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{name: "empty role label", modify: func(c *Config) { c.RoleLabelKey = " " }, wantErr: "role-label-key is empty"},
+		{name: "same role values", modify: func(c *Config) { c.RolePreview = c.RoleActive }, wantErr: "role-active and role-preview"},
+		{name: "same suffixes", modify: func(c *Config) { c.PreviewSuffix = c.ActiveSuffix }, wantErr: "active-suffix and preview-suffix"},
+		{name: "unknown pairing", modify: func(c *Config) { c.PairBy = "label" }, wantErr: "pair-by"},
+		{name: "unknown precedence", modify: func(c *Config) { c.MappingsFilePrecedence = "newest" }, wantErr: "mappings-file-precedence"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				RoleLabelKey:  DefaultRoleLabelKey,
+				RoleActive:    DefaultRoleActive,
+				RolePreview:   DefaultRolePreview,
+				ActiveSuffix:  DefaultActiveSuffix,
+				PreviewSuffix: DefaultPreviewSuffix,
+			}
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     any
+		strict  bool
+		want    []string
+		wantErr string
+	}{
+		{name: "csv with whitespace", raw: " 10.0.0.0/8 , 192.168.0.0/16", want: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		{name: "yaml list", raw: []any{"10.0.0.0/8", " fd00::/8 "}, want: []string{"10.0.0.0/8", "fd00::/8"}},
+		{name: "string slice", raw: []string{"10.0.0.0/8"}, want: []string{"10.0.0.0/8"}},
+		{name: "empty", raw: "", want: nil},
+		{name: "empty element skipped", raw: "10.0.0.0/8,,192.168.0.0/16", want: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		{name: "empty element strict", raw: "10.0.0.0/8,,192.168.0.0/16", strict: true, wantErr: `exclude-cidrs[1] "": empty list element`},
+		{name: "bad csv entry", raw: "10.0.0.0/8,10.0.0.0/33", wantErr: `exclude-cidrs[1] "10.0.0.0/33"`},
+		{name: "bad list entry", raw: []any{"10.0.0.0/8", "192.168.0.0/16", "nope"}, wantErr: `exclude-cidrs[2] "nope"`},
+		{name: "non-string entry", raw: []any{"10.0.0.0/8", 7}, wantErr: `exclude-cidrs[1] "7": expected a string`},
+		{name: "wrong type", raw: 7, wantErr: "expected a list or comma-separated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCIDRList("exclude-cidrs", tt.raw, tt.strict)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseCIDRList() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCIDRList() unexpected error: %v", err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("ParseCIDRList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAcceptsListAndCSVExcludeCIDRs(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	SetDefaults()
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader("exclude-cidrs:\n  - 10.0.0.0/8\n  - 192.168.0.0/16\n")); err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(cfg.ExcludeCIDRs) != 2 || cfg.ExcludeCIDRs[1] != "192.168.0.0/16" {
+		t.Errorf("ExcludeCIDRs = %v from a YAML list", cfg.ExcludeCIDRs)
+	}
+
+	viper.Set("exclude-cidrs", "10.0.0.0/8,10.0.0.0/33")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `exclude-cidrs[1] "10.0.0.0/33"`) {
+		t.Errorf("Validate() = %v, want the bad CSV entry named", err)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Defaults that commands also fall back to when a setting is explicitly set
+// to an empty string.
+const (
+	DefaultNamespace      = "default"
+	DefaultNATChain       = "CANARY_DNAT"
+	DefaultJumpHook       = "OUTPUT"
+	DefaultDNATMapPath    = "/shared/dnat.map"
+	DefaultReadyMarker    = "/shared/ready"
+	DefaultClaimFile      = "/shared/ghostwire.claim"
+	DefaultPreviewPattern = "{{name}}-preview"
+	DefaultActiveSuffix   = "-active"
+	DefaultPreviewSuffix  = "-preview"
+	DefaultRoleLabelKey   = "role"
+	DefaultRoleActive     = "active"
+	DefaultRolePreview    = "preview"
+	DefaultPollInterval   = 2 * time.Second
+	DefaultOutput         = "table"
+	DefaultLogLevel       = "info"
+)
+
+// Defaults returns the default of every setting, keyed by its viper key
+// (GW_<KEY> in the environment, --<key> on the command line). Values are
+// strings, bools, ints or time.Durations, which also decides the type of the
+// setting's flag.
+func Defaults() map[string]any {
+	return map[string]any{
+		"log-level":         DefaultLogLevel,
+		"output":            DefaultOutput,
+		"iptables-dnat-map": DefaultDNATMapPath,
+
+		"namespace":                 DefaultNamespace,
+		"config-name":               "",
+		"svc-preview-pattern":       DefaultPreviewPattern,
+		"active-suffix":             DefaultActiveSuffix,
+		"preview-suffix":            DefaultPreviewSuffix,
+		"service-selector":          "",
+		"service-events":            false,
+		"publish-mappings":          false,
+		"exclude-service-selector":  "",
+		"pair-by":                   "name",
+		"release-label":             "app.kubernetes.io/instance",
+		"release-pattern":           DefaultPreviewPattern,
+		"stateful-ordinals":         false,
+		"record-target-ports":       false,
+		"mapping-overrides":         false,
+		"mappings-configmap":        "",
+		"mappings-file":             "",
+		"mapping-sources":           "",
+		"mappings-file-precedence":  "file",
+		"api-retry-attempts":        5,
+		"api-retry-initial-backoff": 500 * time.Millisecond,
+		"api-retry-max-backoff":     8 * time.Second,
+		"init-timeout":              time.Duration(0),
+		"strict-parsing":            false,
+		"rbac-check":                true,
+
+		"nat-chain":              DefaultNATChain,
+		"jump-hook":              DefaultJumpHook,
+		"jump-position":          "",
+		"exclude-cidrs":          "169.254.169.254/32,10.96.0.10/32",
+		"exclude-ipset":          "",
+		"notrack-cidrs":          "",
+		"exclude-cgroups":        "",
+		"ipv6":                   false,
+		"dnat-protocols":         "",
+		"whole-service-dnat":     false,
+		"multiport":              false,
+		"endpoint-dnat":          false,
+		"endpoint-balance":       "random",
+		"preview-percent":        100,
+		"preview-percent-from":   "",
+		"sticky-window":          time.Duration(0),
+		"hairpin-masquerade":     false,
+		"hairpin-mark":           "0x1000000",
+		"debug-log":              "",
+		"debug-log-scope":        "chain",
+		"debug-log-prefix":       "ghostwire",
+		"debug-log-rate":         "10/second",
+		"debug-log-nflog-group":  0,
+		"ct-timeout-policy":      "",
+		"ct-udp-timeout":         0,
+		"dnat-map-hmac-key-file": "",
+		"ready-marker":           DefaultReadyMarker,
+		"claim-file":             DefaultClaimFile,
+		"on-conflict":            "refuse",
+		"rule-backend":           "iptables",
+		"skip-apply":             false,
+		"restore-file":           "",
+
+		"dns-mode":           false,
+		"dns-suffix":         ".svc.cluster.local",
+		"dns-hosts-fragment": "/shared/hosts.preview",
+		"dns-hosts-path":     "/etc/hosts",
+		"dns-listen-addr":    "127.0.0.1:53",
+		"dns-upstream":       "",
+
+		"role-label-key":             DefaultRoleLabelKey,
+		"role-active":                DefaultRoleActive,
+		"role-preview":               DefaultRolePreview,
+		"role-actions":               "",
+		"mirror-gateway":             "",
+		"route-schedule":             "",
+		"route-schedule-timezone":    "UTC",
+		"max-preview-duration":       time.Duration(0),
+		"reverse-mode":               false,
+		"transition-handlers":        "routing",
+		"transition-webhook-url":     "",
+		"transition-webhook-timeout": 5 * time.Second,
+		"on-activate-cmd":            "",
+		"on-deactivate-cmd":          "",
+		"hook-timeout":               30 * time.Second,
+		"poll-interval":              DefaultPollInterval,
+		"ready-timeout":              60 * time.Second,
+		"readiness-signals":          "chain,labels",
+		"drift-check-interval":       time.Duration(0),
+		"drift-repair":               false,
+		"reconcile-interval":         time.Duration(0),
+		"rule-drift-interval":        time.Duration(0),
+		"preview-health-check":       false,
+		"credential-check-interval":  5 * time.Minute,
+		"conntrack-check-interval":   30 * time.Second,
+		"conntrack-warn-percent":     80,
+		"chaos-flap-interval":        time.Duration(0),
+		"mapping-info-limit":         100,
+		"http-addr":                  ":8081",
+		"http-socket":                "",
+		"events-buffer":              100,
+		"metrics-openmetrics":        true,
+		"metrics-compression":        true,
+		"runtime-metrics":            false,
+		"status-annotations":         false,
+		"status-resource":            false,
+		"privsep":                    false,
+		"helper-socket":              "",
+		"role-token-file":            "",
+		"http-auth":                  "",
+		"http-token-file":            "",
+		"http-token-audiences":       "",
+		"admin-grpc-addr":            "",
+		"admin-http-addr":            "",
+		"admin-tls-cert":             "",
+		"admin-tls-key":              "",
+		"admin-tls-client-ca":        "",
+
+		"controller-namespaces":         "",
+		"controller-namespace-selector": "",
+		"controller-interval":           30 * time.Second,
+		"controller-workers":            4,
+		"controller-namespace-timeout":  time.Duration(0),
+		"controller-metrics-addr":       ":8081",
+
+		"node-name":      "",
+		"node-interval":  5 * time.Second,
+		"node-proc-root": "/proc",
+
+		"injector-listen-addr": ":8443",
+		"injector-tls-cert":    "/etc/ghostwire/tls/tls.crt",
+		"injector-tls-key":     "/etc/ghostwire/tls/tls.key",
+
+		"tls-reload-interval": 30 * time.Second,
+
+		"export-file":          "",
+		"export-format":        "iptables",
+		"from-dnat-map":        false,
+		"netpol-name":          "ghostwire-preview-egress",
+		"netpol-dns-namespace": "kube-system",
+		"netpol-dns-selector":  "k8s-app=kube-dns",
+		"trace-protocol":       "tcp",
+		"trace-source":         "auto",
+	}
+}
+
+// SetDefaults registers Defaults with viper, the lowest-precedence source
+// behind flags, environment variables and the config file.
+func SetDefaults() {
+	for key, value := range Defaults() {
+		viper.SetDefault(key, value)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// EntryError locates a bad element of a list-valued setting such as
+// exclude-cidrs. Index is 0-based and counts every element, including empty
+// ones, so it matches the position in the YAML list or the CSV string.
+type EntryError struct {
+	Source string
+	Index  int
+	Entry  string
+	Err    error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s[%d] %q: %v", e.Source, e.Index, e.Entry, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// StringList normalizes a list-valued setting. Config files may give a native
+// list; environment variables and flags give a comma-separated string. Entries
+// are trimmed but empty ones are kept so callers can report their index.
+func StringList(source string, raw any) ([]string, error) {
+	var entries []string
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		entries = strings.Split(v, ",")
+	case []string:
+		entries = append(entries, v...)
+	case []any:
+		for i, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, &EntryError{Source: source, Index: i, Entry: fmt.Sprint(item), Err: fmt.Errorf("expected a string, got %T", item)}
+			}
+			entries = append(entries, str)
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected a list or comma-separated string, got %T", source, raw)
+	}
+
+	for i := range entries {
+		entries[i] = strings.TrimSpace(entries[i])
+	}
+	return entries, nil
+}
+
+// ParseCIDRList normalizes raw with StringList and checks that every entry is
+// a CIDR. Empty entries are skipped, or rejected when strict is set.
+func ParseCIDRList(source string, raw any, strict bool) ([]string, error) {
+	entries, err := StringList(source, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for i, entry := range entries {
+		if entry == "" {
+			if strict {
+				return nil, &EntryError{Source: source, Index: i, Entry: entry, Err: errors.New("empty list element")}
+			}
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return nil, &EntryError{Source: source, Index: i, Entry: entry, Err: errors.New("not a CIDR such as 10.0.0.0/8")}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// ParseError locates a malformed token in an operator-supplied input such as
+// the dnat.map, a mappings file or a comma-separated setting. Line and
+// Column are 1-based; Source names the file or setting.
+type ParseError struct {
+	Source string
+	Line   int
+	Column int
+	Token  string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v (at %q)", e.Source, e.Line, e.Column, e.Err, e.Token)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,360 @@
+// Package controller implements ghostwire's central deployment mode. A single
+// controller performs service discovery for many namespaces and publishes the
+// results as ConfigMaps, so injected pods consume precomputed mappings instead
+// of each needing discovery configuration and RBAC of their own.
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+const (
+	// DNATMapKey holds the human-readable dnat.map rendering.
+	DNATMapKey = "dnat.map"
+	// MappingsKey holds the JSON-encoded mappings consumed by init.
+	MappingsKey = "mappings.json"
+	// HashAnnotation records a digest of the published mappings so watchers and
+	// operators can tell whether a pod's rules match the controller's view.
+	HashAnnotation = "ghostwire.dev/mappings-hash"
+
+	// PodLabel names the pod whose init published a per-pod mappings
+	// ConfigMap.
+	PodLabel = "ghostwire.dev/pod"
+	// PodConfigMapPrefix prefixes the name of per-pod mappings ConfigMaps.
+	PodConfigMapPrefix = "ghostwire-mappings-"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "ghostwire"
+
+	defaultInterval = 30 * time.Second
+	defaultWorkers  = 4
+)
+
+// DiscoverFunc returns the mappings for a single namespace.
+type DiscoverFunc func(ctx context.Context, namespace string) ([]discovery.ServiceMapping, error)
+
+// Observer receives the timing of controller syncs, e.g. to export them as
+// metrics.
+type Observer interface {
+	// ObserveNamespaceSync records one namespace's discovery and publishing.
+	ObserveNamespaceSync(duration time.Duration, err error)
+	// ObserveSync records a full pass over namespaces, failed of which failed.
+	ObserveSync(duration time.Duration, namespaces, failed int)
+}
+
+// Config describes the namespaces the controller manages and where it publishes
+// results.
+type Config struct {
+	Client kubernetes.Interface
+	// Discover performs discovery for one namespace.
+	Discover DiscoverFunc
+	// Namespaces lists namespaces explicitly. When empty, NamespaceSelector is
+	// used to list them from the API server on every sync.
+	Namespaces        []string
+	NamespaceSelector string
+	// ConfigMapName is the ConfigMap written in every managed namespace.
+	ConfigMapName string
+	Interval      time.Duration
+	// Workers bounds how many namespaces are synced at once.
+	Workers int
+	// NamespaceTimeout bounds the sync of a single namespace, so one stuck on
+	// a slow API call gives up instead of holding its worker. It defaults to
+	// Interval.
+	NamespaceTimeout time.Duration
+	Observer         Observer
+	Logger           *slog.Logger
+}
+
+// Controller periodically reconciles per-namespace mapping ConfigMaps.
+type Controller struct {
+	cfg    Config
+	logger *slog.Logger
+}
+
+// New validates cfg and constructs a Controller.
+func New(cfg Config) (*Controller, error) {
+	if cfg.Client == nil {
+		return nil, errors.New("kubernetes client must be provided")
+	}
+	if cfg.Discover == nil {
+		return nil, errors.New("discover function must be provided")
+	}
+	if cfg.ConfigMapName == "" {
+		return nil, errors.New("configmap name must be provided")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.NamespaceTimeout <= 0 {
+		cfg.NamespaceTimeout = cfg.Interval
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Controller{cfg: cfg, logger: logger}, nil
+}
+
+// Run syncs immediately and then on every interval until ctx is cancelled.
+// Sync errors are logged; they never stop the loop.
+func (c *Controller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.SyncOnce(ctx); err != nil {
+			c.logger.Warn("controller sync finished with errors", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce reconciles every managed namespace once, up to Workers at a time.
+// A failure, timeout or panic in one namespace does not prevent the others
+// from being processed; all failures are joined in namespace order.
+func (c *Controller) SyncOnce(ctx context.Context) error {
+	start := time.Now()
+	namespaces, err := c.namespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(namespaces))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(c.cfg.Workers, len(namespaces)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = c.isolatedSync(ctx, namespaces[i])
+			}
+		}()
+	}
+	for i := range namespaces {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			errs[i] = fmt.Errorf("namespace %q: %w", namespaces[i], err)
+		}
+	}
+	if c.cfg.Observer != nil {
+		c.cfg.Observer.ObserveSync(time.Since(start), len(namespaces), failed)
+	}
+	return errors.Join(errs...)
+}
+
+// isolatedSync syncs namespace under NamespaceTimeout, turning a panic into
+// an error so it only fails that namespace.
+func (c *Controller) isolatedSync(ctx context.Context, namespace string) (err error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.NamespaceTimeout)
+	defer func() {
+		cancel()
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			c.logger.Warn("namespace sync failed", slog.String("namespace", namespace), slog.Any("error", err))
+		}
+		if c.cfg.Observer != nil {
+			c.cfg.Observer.ObserveNamespaceSync(time.Since(start), err)
+		}
+	}()
+	return c.syncNamespace(ctx, namespace)
+}
+
+func (c *Controller) namespaces(ctx context.Context) ([]string, error) {
+	if len(c.cfg.Namespaces) > 0 {
+		return c.cfg.Namespaces, nil
+	}
+
+	list, err := c.cfg.Client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: c.cfg.NamespaceSelector})
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c *Controller) syncNamespace(ctx context.Context, namespace string) error {
+	mappings, err := c.cfg.Discover(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("discover: %w", err)
+	}
+
+	hash, changed, err := Publish(ctx, c.cfg.Client, Target{Namespace: namespace, Name: c.cfg.ConfigMapName}, mappings)
+	if err != nil {
+		return err
+	}
+	if changed {
+		c.logger.Info("published mappings", slog.String("namespace", namespace), slog.Int("mappings", len(mappings)), slog.String("hash", hash))
+	}
+	return nil
+}
+
+// Target names the ConfigMap Publish writes, with optional extra labels and
+// owner references applied when it is created.
+type Target struct {
+	Namespace string
+	Name      string
+	Labels    map[string]string
+	Owners    []metav1.OwnerReference
+}
+
+// Publish creates or updates the target ConfigMap with the rendered mappings.
+// The write is skipped when the hash annotation already matches; changed
+// reports whether the API server was written to.
+func Publish(ctx context.Context, client kubernetes.Interface, target Target, mappings []discovery.ServiceMapping) (string, bool, error) {
+	data, hash, err := Render(mappings)
+	if err != nil {
+		return "", false, err
+	}
+
+	configMaps := client.CoreV1().ConfigMaps(target.Namespace)
+	existing, err := configMaps.Get(ctx, target.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		labels := map[string]string{managedByLabel: managedByValue}
+		for key, value := range target.Labels {
+			labels[key] = value
+		}
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            target.Name,
+				Namespace:       target.Namespace,
+				Labels:          labels,
+				Annotations:     map[string]string{HashAnnotation: hash},
+				OwnerReferences: target.Owners,
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return "", false, fmt.Errorf("create configmap %s: %w", target.Name, err)
+		}
+		return hash, true, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get configmap %s: %w", target.Name, err)
+	}
+
+	if existing.Annotations[HashAnnotation] == hash {
+		return hash, false, nil
+	}
+
+	updated := existing.DeepCopy()
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Labels[managedByLabel] = managedByValue
+	for key, value := range target.Labels {
+		updated.Labels[key] = value
+	}
+	updated.Annotations[HashAnnotation] = hash
+	updated.Data = data
+
+	if _, err := configMaps.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return "", false, fmt.Errorf("update configmap %s: %w", target.Name, err)
+	}
+	return hash, true, nil
+}
+
+// PodTarget returns the per-pod ConfigMap init publishes its mappings to. When
+// podUID is set the ConfigMap is owned by the pod and garbage collected with
+// it.
+func PodTarget(namespace, podName, podUID string) Target {
+	target := Target{
+		Namespace: namespace,
+		Name:      PodConfigMapPrefix + podName,
+		Labels:    map[string]string{PodLabel: podName},
+	}
+	if podUID != "" {
+		target.Owners = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       podName,
+			UID:        types.UID(podUID),
+		}}
+	}
+	return target
+}
+
+// Render produces the ConfigMap data for mappings together with a stable digest
+// of the JSON encoding.
+func Render(mappings []discovery.ServiceMapping) (map[string]string, string, error) {
+	if mappings == nil {
+		mappings = []discovery.ServiceMapping{}
+	}
+
+	encoded, err := json.Marshal(mappings)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode mappings: %w", err)
+	}
+
+	var dnatMap bytes.Buffer
+	if err := iptables.RenderDNATMap(&dnatMap, mappings, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	data := map[string]string{
+		DNATMapKey:  dnatMap.String(),
+		MappingsKey: string(encoded),
+	}
+	return data, hex.EncodeToString(sum[:8]), nil
+}
+
+// ParseMappings decodes the MappingsKey payload of a controller ConfigMap.
+func ParseMappings(cm *corev1.ConfigMap) ([]discovery.ServiceMapping, error) {
+	raw, ok := cm.Data[MappingsKey]
+	if !ok {
+		return nil, fmt.Errorf("configmap %s/%s has no %s key", cm.Namespace, cm.Name, MappingsKey)
+	}
+
+	var mappings []discovery.ServiceMapping
+	if err := json.Unmarshal([]byte(raw), &mappings); err != nil {
+		return nil, fmt.Errorf("decode %s from configmap %s/%s: %w", MappingsKey, cm.Namespace, cm.Name, err)
+	}
+	return mappings, nil
+}
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestSyncOncePublishesPerNamespace(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"ghostwire": "on"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "billing", Labels: map[string]string{"ghostwire": "on"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+
+	byNamespace := map[string][]discovery.ServiceMapping{
+		"shop": {
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		},
+		"billing": nil,
+	}
+	var calls []string
+	discover := func(_ context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+		calls = append(calls, namespace)
+		return byNamespace[namespace], nil
+	}
+
+	ctrl, err := New(Config{
+		Client:            client,
+		Discover:          discover,
+		NamespaceSelector: "ghostwire=on",
+		ConfigMapName:     "ghostwire-mappings",
+		Logger:            testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if err := ctrl.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	if strings.Join(calls, ",") != "billing,shop" {
+		t.Fatalf("expected discovery for billing,shop; got %v", calls)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("shop").Get(context.Background(), "ghostwire-mappings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	if !strings.Contains(cm.Data[DNATMapKey], "orders:80/TCP 10.0.0.1 -> 10.0.0.2") {
+		t.Fatalf("unexpected dnat.map: %q", cm.Data[DNATMapKey])
+	}
+	if cm.Annotations[HashAnnotation] == "" {
+		t.Fatalf("expected hash annotation")
+	}
+	if cm.Labels[managedByLabel] != managedByValue {
+		t.Fatalf("expected managed-by label, got %v", cm.Labels)
+	}
+
+	parsed, err := ParseMappings(cm)
+	if err != nil {
+		t.Fatalf("ParseMappings returned error: %v", err)
+	}
+	if len(parsed) != 1 || !reflect.DeepEqual(parsed[0], byNamespace["shop"][0]) {
+		t.Fatalf("round-trip mismatch: %+v", parsed)
+	}
+
+	empty, err := client.CoreV1().ConfigMaps("billing").Get(context.Background(), "ghostwire-mappings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get billing configmap: %v", err)
+	}
+	if empty.Data[MappingsKey] != "[]" {
+		t.Fatalf("expected empty mappings list, got %q", empty.Data[MappingsKey])
+	}
+}
+
+func TestSyncOnceUpdatesOnlyOnChange(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+	}
+	ctrl, err := New(Config{
+		Client:        client,
+		Discover:      func(context.Context, string) ([]discovery.ServiceMapping, error) { return mappings, nil },
+		Namespaces:    []string{"apps"},
+		ConfigMapName: "gw",
+		Logger:        testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ctrl.SyncOnce(context.Background()); err != nil {
+			t.Fatalf("SyncOnce returned error: %v", err)
+		}
+	}
+	if got := countActions(client, "update"); got != 0 {
+		t.Fatalf("expected no updates for unchanged mappings, got %d", got)
+	}
+
+	mappings = append(mappings, discovery.ServiceMapping{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.0.4"})
+	if err := ctrl.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	if got := countActions(client, "update"); got != 1 {
+		t.Fatalf("expected one update after mappings changed, got %d", got)
+	}
+}
+
+func TestSyncOnceContinuesPastFailures(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	ctrl, err := New(Config{
+		Client: client,
+		Discover: func(_ context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+			if namespace == "broken" {
+				return nil, errors.New("boom")
+			}
+			return nil, nil
+		},
+		Namespaces:    []string{"broken", "ok"},
+		ConfigMapName: "gw",
+		Logger:        testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	err = ctrl.SyncOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `namespace "broken"`) {
+		t.Fatalf("expected joined error naming broken namespace, got %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ok").Get(context.Background(), "gw", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected ok namespace to be published: %v", err)
+	}
+}
+
+type recordingObserver struct {
+	mu         sync.Mutex
+	namespaces int
+	failures   int
+	passes     int
+	failed     int
+}
+
+func (r *recordingObserver) ObserveNamespaceSync(_ time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.namespaces++
+	if err != nil {
+		r.failures++
+	}
+}
+
+func (r *recordingObserver) ObserveSync(_ time.Duration, _, failed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.passes++
+	r.failed = failed
+}
+
+func TestSyncOnceIsolatesNamespacesAcrossWorkers(t *testing.T) {
+	t.Parallel()
+
+	// "first" and "second" only return once both are in flight, which needs
+	// two workers; "slow" outlives the namespace timeout and "panics" panics.
+	both := make(chan struct{})
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	go func() {
+		arrived.Wait()
+		close(both)
+	}()
+
+	client := fake.NewSimpleClientset()
+	observer := &recordingObserver{}
+	ctrl, err := New(Config{
+		Client: client,
+		Discover: func(ctx context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+			switch namespace {
+			case "first", "second":
+				arrived.Done()
+				select {
+				case <-both:
+				case <-time.After(5 * time.Second):
+					return nil, errors.New("namespaces were not synced concurrently")
+				}
+			case "slow":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			case "panics":
+				panic("boom")
+			}
+			return nil, nil
+		},
+		Namespaces:       []string{"first", "panics", "second", "slow", "ok"},
+		ConfigMapName:    "gw",
+		Workers:          2,
+		NamespaceTimeout: 50 * time.Millisecond,
+		Observer:         observer,
+		Logger:           testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	err = ctrl.SyncOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `namespace "panics": panic: boom`) || !strings.Contains(err.Error(), `namespace "slow"`) {
+		t.Fatalf("expected the panicking and slow namespaces to fail, got %v", err)
+	}
+	for _, namespace := range []string{"first", "second", "ok"} {
+		if _, err := client.CoreV1().ConfigMaps(namespace).Get(context.Background(), "gw", metav1.GetOptions{}); err != nil {
+			t.Fatalf("expected %s to be published: %v", namespace, err)
+		}
+	}
+	if observer.namespaces != 5 || observer.failures != 2 || observer.passes != 1 || observer.failed != 2 {
+		t.Fatalf("unexpected observations %+v", observer)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	t.Parallel()
+
+	discover := func(context.Context, string) ([]discovery.ServiceMapping, error) { return nil, nil }
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "missing client", cfg: Config{Discover: discover, ConfigMapName: "gw"}},
+		{name: "missing discover", cfg: Config{Client: fake.NewSimpleClientset(), ConfigMapName: "gw"}},
+		{name: "missing configmap name", cfg: Config{Client: fake.NewSimpleClientset(), Discover: discover}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := New(tc.cfg); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func countActions(client *fake.Clientset, verb string) int {
+	count := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == verb && action.GetResource().Resource == "configmaps" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPublishPodTarget(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+	}
+	target := PodTarget("shop", "orders-7d9", "pod-uid")
+
+	hash, changed, err := Publish(context.Background(), client, target, mappings)
+	if err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	if !changed || hash == "" {
+		t.Fatalf("expected first publish to write, got changed=%v hash=%q", changed, hash)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("shop").Get(context.Background(), "ghostwire-mappings-orders-7d9", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	if cm.Labels[PodLabel] != "orders-7d9" || cm.Labels[managedByLabel] != managedByValue {
+		t.Fatalf("unexpected labels: %v", cm.Labels)
+	}
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].UID != "pod-uid" || cm.OwnerReferences[0].Kind != "Pod" {
+		t.Fatalf("unexpected owner references: %+v", cm.OwnerReferences)
+	}
+
+	if _, changed, err := Publish(context.Background(), client, target, mappings); err != nil || changed {
+		t.Fatalf("expected unchanged republish to be skipped, got changed=%v err=%v", changed, err)
+	}
+}
@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// remoteServices lazily lists services in namespaces other than the one being
+// discovered, so preview patterns that render "namespace/name" can resolve
+// their targets. Each namespace is listed at most once per discovery run.
+type remoteServices struct {
+	cfg      Config
+	exclude  labels.Selector
+	byNSName map[string]map[string]*corev1.Service
+}
+
+func newRemoteServices(cfg Config, exclude labels.Selector) *remoteServices {
+	return &remoteServices{cfg: cfg, exclude: exclude, byNSName: make(map[string]map[string]*corev1.Service)}
+}
+
+// lookup returns the named service in namespace. A missing service is not an
+// error; failing to list the namespace is, with RBAC denials called out
+// explicitly because they are the usual cause.
+func (r *remoteServices) lookup(ctx context.Context, namespace, name string) (*corev1.Service, bool, error) {
+	services, ok := r.byNSName[namespace]
+	if !ok {
+		list, err := r.cfg.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		if apierrors.IsForbidden(err) {
+			return nil, false, fmt.Errorf("list services in preview namespace %q: the service account for namespace %q needs list on services in %q: %w", namespace, r.cfg.Namespace, namespace, err)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("list services in preview namespace %q: %w", namespace, err)
+		}
+
+		services = make(map[string]*corev1.Service, len(list.Items))
+		for i := range list.Items {
+			svc := &list.Items[i]
+			if r.exclude != nil && r.exclude.Matches(labels.Set(svc.Labels)) {
+				continue
+			}
+			services[svc.Name] = svc
+		}
+		r.byNSName[namespace] = services
+	}
+
+	svc, found := services[name]
+	return svc, found, nil
+}
@@ -4,41 +4,136 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Config captures the inputs required for service discovery.
 type Config struct {
-	Clientset      *kubernetes.Clientset
+	Clientset      kubernetes.Interface
 	Namespace      string
 	PreviewPattern string
 	ActiveSuffix   string
 	PreviewSuffix  string
+	// Overrides take precedence over pattern-based pairing for the services
+	// they name.
+	Overrides []Override
+	// PairBy selects PairByName (the default) or PairByRelease.
+	PairBy string
+	// ReleaseLabel identifies a service's Helm release when pairing by
+	// release; empty uses DefaultReleaseLabel.
+	ReleaseLabel string
+	// StatefulOrdinals enables StatefulSet-aware pairing: per-pod services
+	// ("db-0") also pair with "db-preview-0", and headless governing services
+	// pair their pods by ordinal using EndpointSlices.
+	StatefulOrdinals bool
+	// RecordTargetPorts populates ServiceMapping.ActiveTargetPort and
+	// PreviewTargetPort.
+	RecordTargetPorts bool
+	// ResolveEndpoints populates ServiceMapping.PreviewEndpoints from the
+	// preview services' EndpointSlices.
+	ResolveEndpoints bool
+	// ExcludeSelector fences out services whose labels match it, both as
+	// active services and as preview targets.
+	ExcludeSelector string
+	// ServiceSelector, when set, restricts active services to those whose
+	// labels match it. Preview targets are not filtered, so preview services
+	// need not carry the label.
+	ServiceSelector string
+	// Stats, when non-nil, is filled with counts describing the run.
+	Stats *Stats
+	// ReleasePattern maps an active release name to its preview release
+	// name, using the same {{name}} syntax as PreviewPattern.
+	ReleasePattern string
 }
 
 // Discover lists services in the configured namespace, pairing base services
 // with their preview counterparts using the provided name pattern.
 func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMapping, error) {
-	if cfg.Clientset == nil {
-		return nil, fmt.Errorf("kubernetes clientset must be provided")
+	mappings, _, err := DiscoverWithOverrides(ctx, cfg, logger)
+	return mappings, err
+}
+
+// DiscoverWithOverrides behaves like Discover and additionally reports, per
+// override, the reasons it could not be applied as written.
+func DiscoverWithOverrides(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMapping, Conflicts, error) {
+	// A nil *kubernetes.Clientset still makes a non-nil interface.
+	if clientset, ok := cfg.Clientset.(*kubernetes.Clientset); cfg.Clientset == nil || ok && clientset == nil {
+		return nil, nil, fmt.Errorf("kubernetes clientset must be provided")
 	}
 	if cfg.Namespace == "" {
-		return nil, fmt.Errorf("namespace must be provided")
+		return nil, nil, fmt.Errorf("namespace must be provided")
 	}
 	if cfg.PreviewPattern == "" {
-		return nil, fmt.Errorf("preview pattern must be provided")
+		return nil, nil, fmt.Errorf("preview pattern must be provided")
 	}
 	if logger == nil {
 		logger = slog.Default()
 	}
 
+	var excludeSelector labels.Selector
+	if cfg.ExcludeSelector != "" {
+		selector, err := labels.Parse(cfg.ExcludeSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse exclude service selector %q: %w", cfg.ExcludeSelector, err)
+		}
+		excludeSelector = selector
+	}
+
+	var includeSelector labels.Selector
+	if cfg.ServiceSelector != "" {
+		selector, err := labels.Parse(cfg.ServiceSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse service selector %q: %w", cfg.ServiceSelector, err)
+		}
+		includeSelector = selector
+	}
+
 	serviceList, err := cfg.Clientset.CoreV1().Services(cfg.Namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("list services in namespace %q: %w", cfg.Namespace, err)
+		return nil, nil, fmt.Errorf("list services in namespace %q: %w", cfg.Namespace, err)
+	}
+	if cfg.Stats != nil {
+		cfg.Stats.Services = len(serviceList.Items)
+	}
+
+	if excludeSelector != nil {
+		kept := serviceList.Items[:0]
+		for _, svc := range serviceList.Items {
+			if excludeSelector.Matches(labels.Set(svc.Labels)) {
+				logger.Info("skipping service matched by exclude selector", slog.String("service", svc.Name), slog.String("selector", cfg.ExcludeSelector))
+				cfg.Stats.skip(SkipReasonExcludeSelector)
+				continue
+			}
+			kept = append(kept, svc)
+		}
+		serviceList.Items = kept
+	}
+
+	var overrides *overrideIndex
+	if len(cfg.Overrides) > 0 {
+		overrides = indexOverrides(cfg.Overrides)
+	}
+
+	var releasePreviews map[string]string
+	switch cfg.PairBy {
+	case "", PairByName:
+	case PairByRelease:
+		pattern := cfg.ReleasePattern
+		if pattern == "" {
+			pattern = DefaultPreviewPattern
+		}
+		releasePreviews, err = releasePairs(serviceList.Items, cfg.ReleaseLabel, pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported pairing mode %q", cfg.PairBy)
 	}
 
 	serviceMap := make(map[string]*corev1.Service, len(serviceList.Items))
@@ -47,30 +142,85 @@ func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMa
 		serviceMap[svc.Name] = svc
 	}
 
+	remote := newRemoteServices(cfg, excludeSelector)
+	endpoints := newEndpointIndex(cfg)
 	mappings := make([]ServiceMapping, 0)
 
 	for i := range serviceList.Items {
 		svc := &serviceList.Items[i]
 
-		if cfg.PreviewPattern == DefaultPreviewPattern && cfg.PreviewSuffix == "-preview" && strings.HasSuffix(svc.Name, cfg.PreviewSuffix) {
-			logger.Debug("skipping preview service as base", slog.String("service", svc.Name))
+		if includeSelector != nil && !includeSelector.Matches(labels.Set(svc.Labels)) {
+			logger.Debug("skipping service not matched by service selector", slog.String("service", svc.Name), slog.String("selector", cfg.ServiceSelector))
+			cfg.Stats.skip(SkipReasonNotSelected)
 			continue
 		}
 
-		previewName, err := DerivePreviewName(svc.Name, cfg.ActiveSuffix, cfg.PreviewSuffix, cfg.PreviewPattern)
-		if err != nil {
-			return nil, err
+		override, overridden := overrides.lookup(svc.Name)
+		if overridden && override.Exclude {
+			logger.Info("skipping service excluded by override", slog.String("service", svc.Name), slog.String("override", override.Name))
+			cfg.Stats.skip(SkipReasonOverrideExclude)
+			continue
+		}
+
+		var previewName string
+		if overridden && override.PreviewService != "" {
+			previewName = override.PreviewService
+		} else if releasePreviews != nil {
+			var paired bool
+			previewName, paired = releasePreviews[svc.Name]
+			if !paired {
+				logger.Debug("no preview release counterpart", slog.String("service", svc.Name))
+				cfg.Stats.skip(SkipReasonNoPreview)
+				continue
+			}
+		} else {
+			if cfg.PreviewPattern == DefaultPreviewPattern && cfg.PreviewSuffix == "-preview" && strings.HasSuffix(svc.Name, cfg.PreviewSuffix) {
+				logger.Debug("skipping preview service as base", slog.String("service", svc.Name))
+				continue
+			}
+
+			previewName, err = DerivePreviewName(svc.Name, cfg.Namespace, cfg.ActiveSuffix, cfg.PreviewSuffix, cfg.PreviewPattern)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, found := serviceMap[previewName]; !found && cfg.StatefulOrdinals && !strings.ContainsAny(previewName, "/.") {
+				exists := func(name string) bool { _, ok := serviceMap[name]; return ok }
+				if ordinalName, ok, err := ordinalPreviewName(svc.Name, cfg.PreviewPattern, exists); err != nil {
+					return nil, nil, err
+				} else if ok {
+					previewName = ordinalName
+				}
+			}
 		}
 
-		previewSvc, ok := serviceMap[previewName]
+		previewNamespace, previewRef := SplitPreviewRef(previewName, cfg.Namespace)
+		previewName = previewRef
+		previewSvc, ok := serviceMap[previewRef]
+		if previewNamespace != cfg.Namespace {
+			previewName = previewNamespace + "/" + previewRef
+			previewSvc, ok, err = remote.lookup(ctx, previewNamespace, previewRef)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 		if !ok {
 			logger.Debug("no preview service found", slog.String("service", svc.Name), slog.String("expected_preview", previewName))
+			cfg.Stats.skip(SkipReasonNoPreview)
 			continue
 		}
 
 		activeIP := clusterIP(svc)
 		previewIP := clusterIP(previewSvc)
 
+		if cfg.StatefulOrdinals && previewNamespace == cfg.Namespace && activeIP == corev1.ClusterIPNone && previewIP == corev1.ClusterIPNone {
+			ordinalMappings, err := discoverHeadlessOrdinals(ctx, cfg, svc, previewSvc, logger)
+			if err != nil {
+				return nil, nil, err
+			}
+			mappings = append(mappings, ordinalMappings...)
+			continue
+		}
+
 		if !isValidClusterIP(activeIP) {
 			logger.Warn("skipping service with invalid cluster IP", slog.String("service", svc.Name), slog.String("cluster_ip", activeIP))
 			continue
@@ -90,12 +240,26 @@ func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMa
 		}
 
 		previewPorts := buildNumericPortMap(previewSvc.Spec.Ports)
+		skipped := skipPorts(svc)
+		identicalPorts := samePortSet(svc.Spec.Ports, previewPorts) && (!overridden || len(override.Ports) == 0) && len(skipped) == 0
 
 		for _, port := range svc.Spec.Ports {
-			lookupKey := numericPortKey(port)
+			if skipped[numericPortKey(port)] {
+				logger.Info("skipping port opted out by annotation", slog.String("service", svc.Name), slog.Int("port", int(port.Port)), slog.String("protocol", string(port.Protocol)), slog.String("annotation", AnnotationSkipPorts))
+				cfg.Stats.skip(SkipReasonSkipPorts)
+				continue
+			}
+			targetPort := port.Port
+			if overridden {
+				targetPort = overrides.previewPort(override, port)
+			}
+			lookupKey := fmt.Sprintf("%d/%s", targetPort, port.Protocol)
 			previewPort, ok := previewPorts[lookupKey]
 			if !ok {
 				logger.Warn("preview service missing matching port", slog.String("service", svc.Name), slog.String("preview_service", previewName), slog.String("port_key", lookupKey))
+				if overridden {
+					overrides.conflicts.add(override.Name, "preview service %q has no port %s", previewName, lookupKey)
+				}
 				continue
 			}
 
@@ -116,12 +280,45 @@ func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMa
 				)
 			}
 
+			activeTarget := serviceTargetPort(port)
+			previewTarget := serviceTargetPort(previewPort)
+			if activeTarget != previewTarget {
+				logger.Warn(
+					"target port differs between active and preview service",
+					slog.String("service", svc.Name),
+					slog.String("preview_service", previewName),
+					slog.Int("port", int(port.Port)),
+					slog.String("active_target_port", activeTarget),
+					slog.String("preview_target_port", previewTarget),
+				)
+			}
+
 			mapping := ServiceMapping{
-				ServiceName:      svc.Name,
-				Port:             port.Port,
-				Protocol:         port.Protocol,
-				ActiveClusterIP:  activeIP,
-				PreviewClusterIP: previewIP,
+				Namespace:          cfg.Namespace,
+				ServiceName:        svc.Name,
+				Port:               port.Port,
+				Protocol:           port.Protocol,
+				ActiveClusterIP:    activeIP,
+				PreviewClusterIP:   previewIP,
+				PreviewServiceName: previewName,
+				PortName:           port.Name,
+				IdenticalPorts:     identicalPorts,
+			}
+			if targetPort != port.Port {
+				mapping.PreviewPort = targetPort
+			}
+			if cfg.RecordTargetPorts {
+				mapping.ActiveTargetPort = activeTarget
+				mapping.PreviewTargetPort = previewTarget
+			}
+			if cfg.ResolveEndpoints {
+				mapping.PreviewEndpoints, err = endpoints.lookup(ctx, previewNamespace, previewRef, previewPort, previewIP)
+				if err != nil {
+					return nil, nil, err
+				}
+				if len(mapping.PreviewEndpoints) == 0 {
+					logger.Warn("no ready preview endpoints; dnat falls back to the preview cluster IP", slog.String("service", svc.Name), slog.String("preview_service", previewName), slog.Int("port", int(port.Port)))
+				}
 			}
 
 			logger.Info(
@@ -129,6 +326,7 @@ func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMa
 				slog.String("service", svc.Name),
 				slog.String("preview_service", previewName),
 				slog.Int("port", int(port.Port)),
+				slog.Int("preview_port", int(targetPort)),
 				slog.String("protocol", string(port.Protocol)),
 				slog.String("active_ip", activeIP),
 				slog.String("preview_ip", previewIP),
@@ -138,7 +336,10 @@ func Discover(ctx context.Context, cfg Config, logger *slog.Logger) ([]ServiceMa
 		}
 	}
 
-	return mappings, nil
+	if cfg.Stats != nil {
+		cfg.Stats.Mappings = len(mappings)
+	}
+	return mappings, overrides.finalize(serviceMap), nil
 }
 
 func isValidClusterIP(ip string) bool {
@@ -156,6 +357,30 @@ func buildNumericPortMap(ports []corev1.ServicePort) map[string]corev1.ServicePo
 	return result
 }
 
+// samePortSet reports whether ports and the preview port map contain exactly
+// the same port/protocol keys.
+func samePortSet(ports []corev1.ServicePort, previewPorts map[string]corev1.ServicePort) bool {
+	active := buildNumericPortMap(ports)
+	if len(active) != len(previewPorts) {
+		return false
+	}
+	for key := range active {
+		if _, ok := previewPorts[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceTargetPort renders a port's targetPort, applying the Kubernetes
+// default of the service port when it is unset.
+func serviceTargetPort(port corev1.ServicePort) string {
+	if port.TargetPort.IntValue() == 0 && port.TargetPort.StrVal == "" {
+		return strconv.Itoa(int(port.Port))
+	}
+	return port.TargetPort.String()
+}
+
 func numericPortKey(port corev1.ServicePort) string {
 	return fmt.Sprintf("%d/%s", port.Port, port.Protocol)
 }
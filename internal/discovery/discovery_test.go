@@ -7,14 +7,17 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -26,6 +29,11 @@ type mockRoundTripper struct {
 	status    int
 	err       error
 	list      *corev1.ServiceList
+	// slices serves EndpointSlice lists keyed by owning service name.
+	slices map[string]*discoveryv1.EndpointSliceList
+	// remote serves service lists for other namespaces; a nil list answers
+	// 403 Forbidden.
+	remote map[string]*corev1.ServiceList
 }
 
 func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -37,6 +45,45 @@ func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 		m.t.Fatalf("unexpected method %q", req.Method)
 	}
 
+	slicePath := fmt.Sprintf("/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices", m.namespace)
+	if m.slices != nil && req.URL.Path == slicePath {
+		service := strings.TrimPrefix(req.URL.Query().Get("labelSelector"), discoveryv1.LabelServiceName+"=")
+		list := m.slices[service]
+		if list == nil {
+			list = &discoveryv1.EndpointSliceList{}
+		}
+		data, err := runtime.Encode(scheme.Codecs.LegacyCodec(discoveryv1.SchemeGroupVersion), list)
+		if err != nil {
+			m.t.Fatalf("encode endpointslice list: %v", err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(data)),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Request:    req,
+		}, nil
+	}
+
+	for ns, list := range m.remote {
+		if req.URL.Path != fmt.Sprintf("/api/v1/namespaces/%s/services", ns) {
+			continue
+		}
+		if list == nil {
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       io.NopCloser(strings.NewReader(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`)),
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Request:    req,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(encodeServiceList(m.t, list))),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Request:    req,
+		}, nil
+	}
+
 	wantPath := fmt.Sprintf("/api/v1/namespaces/%s/services", m.namespace)
 	if req.URL.Path != wantPath {
 		m.t.Fatalf("unexpected path %q, want %q", req.URL.Path, wantPath)
@@ -90,6 +137,12 @@ func newTestClientset(t *testing.T, namespace string, list *corev1.ServiceList,
 		list:      list,
 	}
 
+	return newClientsetWithTransport(t, rt)
+}
+
+func newClientsetWithTransport(t *testing.T, rt http.RoundTripper) *kubernetes.Clientset {
+	t.Helper()
+
 	httpClient := &http.Client{Transport: rt}
 	cfg := &rest.Config{
 		Host:    "https://example.com",
@@ -503,3 +556,386 @@ func TestDiscover(t *testing.T) {
 		})
 	}
 }
+
+func TestDiscoverIdenticalPorts(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	services := makeServiceList(
+		newService("api", "10.0.0.1", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP), port("grpc", 9090, corev1.ProtocolTCP)}),
+		newService("api-preview", "10.0.1.1", []corev1.ServicePort{port("grpc", 9090, corev1.ProtocolTCP), port("http", 80, corev1.ProtocolTCP)}),
+		newService("web", "10.0.0.2", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("web-preview", "10.0.1.2", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP), port("debug", 6060, corev1.ProtocolTCP)}),
+	)
+
+	logger, _ := newTestLogger()
+	mappings, err := Discover(context.Background(), Config{
+		Clientset:      newTestClientset(t, namespace, services, 0, nil),
+		Namespace:      namespace,
+		PreviewPattern: DefaultPreviewPattern,
+		ActiveSuffix:   "-active",
+		PreviewSuffix:  "-preview",
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	want := map[string]bool{"api": true, "web": false}
+	for _, m := range mappings {
+		if m.IdenticalPorts != want[m.ServiceName] {
+			t.Fatalf("mapping %s: IdenticalPorts = %v, want %v", mappingKey(m), m.IdenticalPorts, want[m.ServiceName])
+		}
+	}
+	if len(mappings) != 3 {
+		t.Fatalf("expected 3 mappings, got %d", len(mappings))
+	}
+}
+
+func TestDiscoverPairsByRelease(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	release := func(name string) func(*corev1.Service) {
+		return func(svc *corev1.Service) {
+			svc.Labels = map[string]string{DefaultReleaseLabel: name}
+		}
+	}
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("shop-api", "10.0.0.1", ports, release("shop")),
+		newService("shop", "10.0.0.2", ports, release("shop")),
+		newService("shop-preview-api", "10.0.1.1", ports, release("shop-preview")),
+		newService("shop-preview", "10.0.1.2", ports, release("shop-preview")),
+		newService("billing-api", "10.0.0.3", ports, release("billing")),
+		newService("billing-api-preview", "10.0.1.3", ports),
+	)
+
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:      newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:      namespace,
+		PreviewPattern: DefaultPreviewPattern,
+		PairBy:         PairByRelease,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	assertMappings(t, got, []ServiceMapping{
+		{ServiceName: "shop-api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "shop", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	})
+}
+
+func TestDiscoverStatefulOrdinals(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	ports := []corev1.ServicePort{port("pg", 5432, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("db", corev1.ClusterIPNone, ports),
+		newService("db-preview", corev1.ClusterIPNone, ports),
+		newService("db-0", "10.0.0.10", ports),
+		newService("db-preview-0", "10.0.1.10", ports),
+	)
+
+	endpoint := func(hostname, ip string) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: []string{ip}, Hostname: &hostname}
+	}
+	slices := map[string]*discoveryv1.EndpointSliceList{
+		"db": {Items: []discoveryv1.EndpointSlice{{
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{endpoint("db-0", "10.1.0.10"), endpoint("db-1", "10.1.0.11")},
+		}}},
+		"db-preview": {Items: []discoveryv1.EndpointSlice{{
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{endpoint("db-preview-0", "10.1.1.10")},
+		}}},
+	}
+
+	clientset := newClientsetWithTransport(t, &mockRoundTripper{t: t, namespace: namespace, list: list, slices: slices})
+
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:        clientset,
+		Namespace:        namespace,
+		PreviewPattern:   DefaultPreviewPattern,
+		StatefulOrdinals: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	assertMappings(t, got, []ServiceMapping{
+		{ServiceName: "db-0.db", Port: 5432, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.1.0.10", PreviewClusterIP: "10.1.1.10"},
+		{ServiceName: "db-0", Port: 5432, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10"},
+	})
+}
+
+func TestDiscoverRecordsTargetPorts(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	withTarget := func(target intstr.IntOrString) func(*corev1.Service) {
+		return func(svc *corev1.Service) {
+			svc.Spec.Ports[0].TargetPort = target
+		}
+	}
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("web", "10.0.0.1", ports, withTarget(intstr.FromString("http"))),
+		newService("web-preview", "10.0.1.1", ports, withTarget(intstr.FromInt32(8080))),
+		newService("api", "10.0.0.2", ports),
+		newService("api-preview", "10.0.1.2", ports),
+	)
+
+	logger, buf := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:         newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:         namespace,
+		PreviewPattern:    DefaultPreviewPattern,
+		RecordTargetPorts: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	byService := make(map[string]ServiceMapping, len(got))
+	for _, mapping := range got {
+		byService[mapping.ServiceName] = mapping
+	}
+	if web := byService["web"]; web.ActiveTargetPort != "http" || web.PreviewTargetPort != "8080" {
+		t.Fatalf("unexpected web target ports: %+v", web)
+	}
+	if api := byService["api"]; api.ActiveTargetPort != "80" || api.PreviewTargetPort != "80" {
+		t.Fatalf("expected defaulted target ports for api: %+v", api)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "target port differs") || strings.Count(logs, "target port differs") != 1 {
+		t.Fatalf("expected exactly one target port warning, logs: %s", logs)
+	}
+}
+
+func TestDiscoverResolvesEndpoints(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("web", "10.0.0.1", ports),
+		newService("web-preview", "10.0.1.1", ports),
+	)
+
+	name, number, notReady := "http", int32(8080), false
+	ready := func(ip string) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: []string{ip}}
+	}
+	slices := map[string]*discoveryv1.EndpointSliceList{
+		"web-preview": {Items: []discoveryv1.EndpointSlice{
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: &name, Port: &number}},
+				Endpoints: []discoveryv1.Endpoint{
+					ready("10.1.1.12"),
+					ready("10.1.1.11"),
+					{Addresses: []string{"10.1.1.13"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+				},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv6,
+				Ports:       []discoveryv1.EndpointPort{{Name: &name, Port: &number}},
+				Endpoints:   []discoveryv1.Endpoint{ready("fd00::11")},
+			},
+		}},
+	}
+
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:        newClientsetWithTransport(t, &mockRoundTripper{t: t, namespace: namespace, list: list, slices: slices}),
+		Namespace:        namespace,
+		PreviewPattern:   DefaultPreviewPattern,
+		ResolveEndpoints: true,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one mapping, got %+v", got)
+	}
+
+	want := []Endpoint{{IP: "10.1.1.11", Port: 8080}, {IP: "10.1.1.12", Port: 8080}}
+	if !reflect.DeepEqual(got[0].PreviewEndpoints, want) {
+		t.Fatalf("unexpected preview endpoints %+v, want %+v", got[0].PreviewEndpoints, want)
+	}
+}
+
+func TestDiscoverExcludeSelector(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	ignored := func(svc *corev1.Service) {
+		svc.Labels = map[string]string{"ghostwire.io/ignore": "true"}
+	}
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("operator", "10.0.0.1", ports, ignored),
+		newService("operator-preview", "10.0.1.1", ports),
+		newService("orders", "10.0.0.2", ports),
+		newService("orders-preview", "10.0.1.2", ports, ignored),
+		newService("web", "10.0.0.3", ports),
+		newService("web-preview", "10.0.1.3", ports),
+	)
+
+	stats := &Stats{}
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:       newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:       namespace,
+		PreviewPattern:  DefaultPreviewPattern,
+		PreviewSuffix:   "-preview",
+		ExcludeSelector: "ghostwire.io/ignore=true",
+		Stats:           stats,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	assertMappings(t, got, []ServiceMapping{
+		{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.1.3"},
+	})
+	if stats.Services != 6 || stats.Mappings != 1 || stats.Skipped[SkipReasonExcludeSelector] != 2 || stats.Skipped[SkipReasonNoPreview] != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if _, err := Discover(context.Background(), Config{
+		Clientset:       newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:       namespace,
+		PreviewPattern:  DefaultPreviewPattern,
+		ExcludeSelector: "a in (",
+	}, logger); err == nil {
+		t.Fatalf("expected invalid selector to be rejected")
+	}
+}
+
+func TestDiscoverSkipPortsAnnotation(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP), port("metrics", 9090, corev1.ProtocolTCP), port("debug", 6060, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("api", "10.0.0.1", ports, func(svc *corev1.Service) {
+			svc.Annotations = map[string]string{AnnotationSkipPorts: " 9090, debug ,7070"}
+		}),
+		newService("api-preview", "10.0.1.1", ports),
+	)
+
+	stats := &Stats{}
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:      newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:      namespace,
+		PreviewPattern: DefaultPreviewPattern,
+		PreviewSuffix:  "-preview",
+		Stats:          stats,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Port != 80 {
+		t.Fatalf("expected only port 80 to be mapped, got %+v", got)
+	}
+	if got[0].IdenticalPorts {
+		t.Fatalf("a service with skipped ports must not get a whole-service rule")
+	}
+	if stats.Skipped[SkipReasonSkipPorts] != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDiscoverServiceSelector(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	checkout := func(svc *corev1.Service) {
+		svc.Labels = map[string]string{"app.kubernetes.io/part-of": "checkout"}
+	}
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	list := makeServiceList(
+		newService("cart", "10.0.0.1", ports, checkout),
+		newService("cart-preview", "10.0.1.1", ports),
+		newService("search", "10.0.0.2", ports),
+		newService("search-preview", "10.0.1.2", ports),
+	)
+
+	stats := &Stats{}
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:       newTestClientset(t, namespace, list, http.StatusOK, nil),
+		Namespace:       namespace,
+		PreviewPattern:  DefaultPreviewPattern,
+		ServiceSelector: "app.kubernetes.io/part-of=checkout",
+		Stats:           stats,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	assertMappings(t, got, []ServiceMapping{
+		{ServiceName: "cart", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+	})
+	if stats.Skipped[SkipReasonNotSelected] != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDiscoverCrossNamespacePreview(t *testing.T) {
+	t.Parallel()
+
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	rt := &mockRoundTripper{
+		t:         t,
+		namespace: "shop",
+		list:      makeServiceList(newService("orders", "10.0.0.1", ports), newService("cart", "10.0.0.2", ports)),
+		remote: map[string]*corev1.ServiceList{
+			"shop-preview": makeServiceList(newService("orders", "10.0.1.1", ports)),
+		},
+	}
+
+	logger, _ := newTestLogger()
+	got, err := Discover(context.Background(), Config{
+		Clientset:      newClientsetWithTransport(t, rt),
+		Namespace:      "shop",
+		PreviewPattern: "{{name}}.{{namespace}}-preview",
+	}, logger)
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	assertMappings(t, got, []ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewServiceName: "shop-preview/orders", PortName: "http", IdenticalPorts: true},
+	})
+}
+
+func TestDiscoverCrossNamespaceForbidden(t *testing.T) {
+	t.Parallel()
+
+	ports := []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}
+	rt := &mockRoundTripper{
+		t:         t,
+		namespace: "shop",
+		list:      makeServiceList(newService("orders", "10.0.0.1", ports)),
+		remote:    map[string]*corev1.ServiceList{"previews": nil},
+	}
+
+	logger, _ := newTestLogger()
+	_, err := Discover(context.Background(), Config{
+		Clientset:      newClientsetWithTransport(t, rt),
+		Namespace:      "shop",
+		PreviewPattern: "previews/{{name}}",
+	}, logger)
+	if err == nil || !strings.Contains(err.Error(), `needs list on services in "previews"`) {
+		t.Fatalf("expected RBAC error naming the preview namespace, got %v", err)
+	}
+}
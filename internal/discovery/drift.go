@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Drift reasons reported by DetectDrift.
+const (
+	DriftPreviewIPChanged = "preview_ip_changed"
+	DriftActiveIPChanged  = "active_ip_changed"
+	DriftServiceMissing   = "service_missing"
+)
+
+// Drift describes a recorded mapping whose Service IPs no longer match the
+// cluster. Current holds the mapping as it should be now; it is only
+// meaningful when Reason is not DriftServiceMissing.
+type Drift struct {
+	Recorded ServiceMapping
+	Current  ServiceMapping
+	Reason   string
+}
+
+// DetectDrift compares recorded mappings, typically read back from dnat.map,
+// against the ClusterIPs of the Services they name. Mappings without a
+// recorded preview service (version 1 maps) only have their active IP
+// checked, and headless ordinal mappings ("db-0.db") are skipped because
+// they target pod IPs. The ServiceAccount needs get on services.
+func DetectDrift(ctx context.Context, client kubernetes.Interface, mappings []ServiceMapping, namespace string) ([]Drift, error) {
+	services := make(map[string]*corev1.Service)
+	get := func(ns, name string) (*corev1.Service, error) {
+		key := ns + "/" + name
+		if svc, ok := services[key]; ok {
+			return svc, nil
+		}
+		svc, err := client.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			services[key] = nil
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get service %s: %w", key, err)
+		}
+		services[key] = svc
+		return svc, nil
+	}
+
+	var drifts []Drift
+	for _, mapping := range mappings {
+		if strings.Contains(mapping.ServiceName, ".") {
+			continue
+		}
+
+		activeNamespace := mapping.Namespace
+		if activeNamespace == "" {
+			activeNamespace = namespace
+		}
+
+		current := mapping
+		active, err := get(activeNamespace, mapping.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		if active == nil {
+			drifts = append(drifts, Drift{Recorded: mapping, Reason: DriftServiceMissing})
+			continue
+		}
+		current.ActiveClusterIP = clusterIP(active)
+
+		if mapping.PreviewServiceName != "" {
+			previewNamespace, previewName := SplitPreviewRef(mapping.PreviewServiceName, activeNamespace)
+			preview, err := get(previewNamespace, previewName)
+			if err != nil {
+				return nil, err
+			}
+			if preview == nil {
+				drifts = append(drifts, Drift{Recorded: mapping, Reason: DriftServiceMissing})
+				continue
+			}
+			current.PreviewClusterIP = clusterIP(preview)
+		}
+
+		switch {
+		case current.PreviewClusterIP != mapping.PreviewClusterIP:
+			drifts = append(drifts, Drift{Recorded: mapping, Current: current, Reason: DriftPreviewIPChanged})
+		case current.ActiveClusterIP != mapping.ActiveClusterIP:
+			drifts = append(drifts, Drift{Recorded: mapping, Current: current, Reason: DriftActiveIPChanged})
+		}
+	}
+
+	return drifts, nil
+}
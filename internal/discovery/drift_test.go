@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectDrift(t *testing.T) {
+	t.Parallel()
+
+	svc := func(namespace, name, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{ClusterIP: ip},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		svc("shop", "orders", "10.0.0.1"),
+		svc("shop", "orders-preview", "10.0.1.9"),
+		svc("shop", "cart", "10.0.0.5"),
+		svc("shop", "cart-preview", "10.0.1.2"),
+		svc("shop", "search", "10.0.0.3"),
+		svc("previews", "api", "10.0.2.1"),
+		svc("shop", "api", "10.0.0.4"),
+	)
+
+	mappings := []ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewServiceName: "orders-preview"},
+		{Namespace: "shop", ServiceName: "cart", Port: 80, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2", PreviewServiceName: "cart-preview"},
+		{Namespace: "shop", ServiceName: "search", Port: 80, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.1.3", PreviewServiceName: "search-preview"},
+		{ServiceName: "api", Port: 443, ActiveClusterIP: "10.0.0.4", PreviewClusterIP: "10.0.2.1", PreviewServiceName: "previews/api"},
+		{ServiceName: "db-0.db", Port: 5432, ActiveClusterIP: "10.1.0.1", PreviewClusterIP: "10.1.0.2"},
+	}
+
+	drifts, err := DetectDrift(context.Background(), client, mappings, "shop")
+	if err != nil {
+		t.Fatalf("DetectDrift returned error: %v", err)
+	}
+	if len(drifts) != 3 {
+		t.Fatalf("expected 3 drifts, got %+v", drifts)
+	}
+
+	want := []struct {
+		service, reason, activeIP, previewIP string
+	}{
+		{"orders", DriftPreviewIPChanged, "10.0.0.1", "10.0.1.9"},
+		{"cart", DriftActiveIPChanged, "10.0.0.5", "10.0.1.2"},
+		{"search", DriftServiceMissing, "", ""},
+	}
+	for i, w := range want {
+		got := drifts[i]
+		if got.Recorded.ServiceName != w.service || got.Reason != w.reason || got.Current.ActiveClusterIP != w.activeIP || got.Current.PreviewClusterIP != w.previewIP {
+			t.Fatalf("drift %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Endpoint is one ready pod address behind a service port.
+type Endpoint struct {
+	IP   string `json:"ip"`
+	Port int32  `json:"port"`
+}
+
+// endpointIndex lists the EndpointSlices of preview services, once per
+// service, for endpoint-level DNAT.
+type endpointIndex struct {
+	cfg    Config
+	slices map[string][]discoveryv1.EndpointSlice
+}
+
+func newEndpointIndex(cfg Config) *endpointIndex {
+	return &endpointIndex{cfg: cfg, slices: make(map[string][]discoveryv1.EndpointSlice)}
+}
+
+// lookup returns the ready endpoints serving port of the service, in the
+// address family of clusterIP, sorted for stable rules. Endpoints are matched
+// to the service port by name, as EndpointSlices publish them.
+func (e *endpointIndex) lookup(ctx context.Context, namespace, service string, port corev1.ServicePort, clusterIP string) ([]Endpoint, error) {
+	key := namespace + "/" + service
+	slices, ok := e.slices[key]
+	if !ok {
+		list, err := e.cfg.Clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + service,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list endpointslices for service %q: %w", key, err)
+		}
+		slices = list.Items
+		e.slices[key] = slices
+	}
+
+	family := discoveryv1.AddressTypeIPv4
+	if ip := net.ParseIP(clusterIP); ip != nil && ip.To4() == nil {
+		family = discoveryv1.AddressTypeIPv6
+	}
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+
+	seen := make(map[string]bool)
+	var endpoints []Endpoint
+	for _, slice := range slices {
+		if slice.AddressType != family {
+			continue
+		}
+		var number int32
+		for _, slicePort := range slice.Ports {
+			name, sliceProtocol := "", corev1.ProtocolTCP
+			if slicePort.Name != nil {
+				name = *slicePort.Name
+			}
+			if slicePort.Protocol != nil {
+				sliceProtocol = *slicePort.Protocol
+			}
+			if name == port.Name && sliceProtocol == protocol && slicePort.Port != nil {
+				number = *slicePort.Port
+				break
+			}
+		}
+		if number == 0 {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if len(endpoint.Addresses) == 0 || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+			address := endpoint.Addresses[0]
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			endpoints = append(endpoints, Endpoint{IP: address, Port: number})
+		}
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].IP < endpoints[j].IP })
+	return endpoints, nil
+}
@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Explanation describes how discovery treats a single active service.
+type Explanation struct {
+	Service   string `json:"service"`
+	Namespace string `json:"namespace"`
+	// Found reports whether the service was listed in Namespace at all.
+	Found bool `json:"found"`
+	// PreviewService is the preview counterpart discovery looked for, as
+	// "namespace/name" when it lives outside Namespace.
+	PreviewService string `json:"previewService,omitempty"`
+	// PreviewExists reports whether PreviewService was found.
+	PreviewExists bool `json:"previewExists"`
+	// Mappings are the port mappings discovery produced for the service.
+	Mappings []ServiceMapping `json:"mappings"`
+	// Notes are discovery's remarks about the service in the order they were
+	// made: skipped ports, mismatches and the reason the service was skipped.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Explain runs discovery for cfg and reports what it decided for service.
+// Discovery's per-service log records are captured as notes instead of being
+// logged.
+func Explain(ctx context.Context, cfg Config, service string) (Explanation, error) {
+	recorder := &explainHandler{service: service}
+	mappings, _, err := DiscoverWithOverrides(ctx, cfg, slog.New(recorder))
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	explanation := Explanation{
+		Service:   service,
+		Namespace: cfg.Namespace,
+		Mappings:  make([]ServiceMapping, 0),
+	}
+	for _, m := range mappings {
+		if m.ServiceName == service {
+			explanation.Mappings = append(explanation.Mappings, m)
+		}
+	}
+
+	for _, record := range recorder.records {
+		explanation.Notes = append(explanation.Notes, record.note)
+		if record.preview != "" {
+			explanation.PreviewService = record.preview
+			explanation.PreviewExists = true
+		}
+		if record.expected != "" {
+			explanation.PreviewService = record.expected
+		}
+	}
+	if len(explanation.Mappings) > 0 {
+		explanation.PreviewExists = true
+		if explanation.PreviewService == "" {
+			explanation.PreviewService = explanation.Mappings[0].PreviewServiceName
+		}
+	}
+	explanation.Found = len(recorder.records) > 0 || len(explanation.Mappings) > 0
+
+	if explanation.PreviewService == "" && explanation.Found && (cfg.PairBy == "" || cfg.PairBy == PairByName) {
+		preview, err := DerivePreviewName(service, cfg.Namespace, cfg.ActiveSuffix, cfg.PreviewSuffix, cfg.PreviewPattern)
+		if err != nil {
+			return Explanation{}, err
+		}
+		explanation.PreviewService = preview
+	}
+	return explanation, nil
+}
+
+// explainRecord is one discovery log record about the explained service.
+type explainRecord struct {
+	note     string
+	preview  string
+	expected string
+}
+
+// explainHandler is a slog.Handler that keeps the records whose "service"
+// attribute names the explained service.
+type explainHandler struct {
+	service string
+	mu      sync.Mutex
+	records []explainRecord
+}
+
+func (h *explainHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *explainHandler) Handle(_ context.Context, r slog.Record) error {
+	var matched bool
+	record := explainRecord{}
+	var details []string
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "service":
+			matched = a.Value.String() == h.service
+		case "preview_service":
+			record.preview = a.Value.String()
+		case "expected_preview":
+			record.expected = a.Value.String()
+		default:
+			details = append(details, fmt.Sprintf("%s=%s", a.Key, a.Value))
+		}
+		return true
+	})
+	if !matched {
+		return nil
+	}
+
+	record.note = r.Message
+	if len(details) > 0 {
+		record.note += " (" + strings.Join(details, ", ") + ")"
+	}
+	h.mu.Lock()
+	h.records = append(h.records, record)
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *explainHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *explainHandler) WithGroup(string) slog.Handler { return h }
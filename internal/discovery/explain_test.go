@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	list := makeServiceList(
+		newService("orders", "10.0.0.10", []corev1.ServicePort{
+			port("http", 80, corev1.ProtocolTCP),
+			port("metrics", 9090, corev1.ProtocolTCP),
+		}),
+		newService("orders-preview", "10.0.1.10", []corev1.ServicePort{
+			port("http", 80, corev1.ProtocolTCP),
+		}),
+		newService("users", "10.0.0.30", []corev1.ServicePort{
+			port("http", 80, corev1.ProtocolTCP),
+		}),
+	)
+
+	tests := []struct {
+		name          string
+		service       string
+		wantFound     bool
+		wantPreview   string
+		wantExists    bool
+		wantMappings  int
+		wantNoteParts []string
+	}{
+		{
+			name:          "paired with a skipped port",
+			service:       "orders",
+			wantFound:     true,
+			wantPreview:   "orders-preview",
+			wantExists:    true,
+			wantMappings:  1,
+			wantNoteParts: []string{"preview service missing matching port", "port_key=9090/TCP"},
+		},
+		{
+			name:          "no preview",
+			service:       "users",
+			wantFound:     true,
+			wantPreview:   "users-preview",
+			wantNoteParts: []string{"no preview service found"},
+		},
+		{
+			name:    "unknown service",
+			service: "missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := Config{
+				Clientset:      newTestClientset(t, namespace, list, 0, nil),
+				Namespace:      namespace,
+				PreviewPattern: DefaultPreviewPattern,
+				ActiveSuffix:   "-active",
+				PreviewSuffix:  "-preview",
+			}
+			got, err := Explain(context.Background(), cfg, tc.service)
+			if err != nil {
+				t.Fatalf("Explain returned error: %v", err)
+			}
+			if got.Found != tc.wantFound || got.PreviewService != tc.wantPreview || got.PreviewExists != tc.wantExists {
+				t.Fatalf("unexpected explanation: %+v", got)
+			}
+			if len(got.Mappings) != tc.wantMappings {
+				t.Fatalf("expected %d mappings, got %+v", tc.wantMappings, got.Mappings)
+			}
+			notes := strings.Join(got.Notes, "\n")
+			for _, part := range tc.wantNoteParts {
+				if !strings.Contains(notes, part) {
+					t.Fatalf("expected notes to contain %q, got:\n%s", part, notes)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UnhealthyPreviews returns the preview services of mappings, as
+// "namespace/name" and sorted, whose EndpointSlices list no ready endpoint.
+// Mappings without a recorded preview service and headless ordinal mappings
+// ("db-0.db"), which target pod IPs, are skipped. The ServiceAccount needs
+// list on endpointslices.
+func UnhealthyPreviews(ctx context.Context, client kubernetes.Interface, mappings []ServiceMapping, namespace string) ([]string, error) {
+	checked := make(map[string]bool)
+	var unhealthy []string
+	for _, mapping := range mappings {
+		if mapping.PreviewServiceName == "" || strings.Contains(mapping.ServiceName, ".") {
+			continue
+		}
+		activeNamespace := mapping.Namespace
+		if activeNamespace == "" {
+			activeNamespace = namespace
+		}
+		previewNamespace, previewName := SplitPreviewRef(mapping.PreviewServiceName, activeNamespace)
+		key := previewNamespace + "/" + previewName
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		list, err := client.DiscoveryV1().EndpointSlices(previewNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + previewName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list endpointslices for service %q: %w", key, err)
+		}
+		if !hasReadyEndpoint(list.Items) {
+			unhealthy = append(unhealthy, key)
+		}
+	}
+	sort.Strings(unhealthy)
+	return unhealthy, nil
+}
+
+// hasReadyEndpoint reports whether any slice lists an endpoint that is ready,
+// or whose readiness is unknown, as kube-proxy treats it.
+func hasReadyEndpoint(slices []discoveryv1.EndpointSlice) bool {
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if len(endpoint.Addresses) > 0 && (endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready) {
+				return true
+			}
+		}
+	}
+	return false
+}
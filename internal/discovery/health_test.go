@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUnhealthyPreviews(t *testing.T) {
+	t.Parallel()
+
+	ready, notReady := true, false
+	slice := func(namespace, service string, conditions ...*bool) *discoveryv1.EndpointSlice {
+		s := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      service + "-abc",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		}}
+		for _, ready := range conditions {
+			s.Endpoints = append(s.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.2.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: ready}})
+		}
+		return s
+	}
+	client := fake.NewSimpleClientset(
+		slice("shop", "orders-preview", &notReady, &ready),
+		slice("shop", "cart-preview", &notReady),
+		slice("previews", "api", nil),
+	)
+
+	mappings := []ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, PreviewServiceName: "orders-preview"},
+		{Namespace: "shop", ServiceName: "orders", Port: 443, PreviewServiceName: "orders-preview"},
+		{Namespace: "shop", ServiceName: "cart", Port: 80, PreviewServiceName: "cart-preview"},
+		{ServiceName: "search", Port: 80, PreviewServiceName: "search-preview"},
+		{ServiceName: "api", Port: 443, PreviewServiceName: "previews/api"},
+		{ServiceName: "db-0.db", Port: 5432, PreviewServiceName: "db-0.db-preview"},
+		{ServiceName: "legacy", Port: 80},
+	}
+
+	got, err := UnhealthyPreviews(context.Background(), client, mappings, "shop")
+	if err != nil {
+		t.Fatalf("UnhealthyPreviews returned error: %v", err)
+	}
+	want := []string{"shop/cart-preview", "shop/search-preview"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("UnhealthyPreviews = %v, want %v", got, want)
+	}
+}
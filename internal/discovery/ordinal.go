@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var ordinalName = regexp.MustCompile(`^(.+)-(\d+)$`)
+
+// splitOrdinal separates a StatefulSet pod-style name such as "db-0" into its
+// base and ordinal.
+func splitOrdinal(name string) (string, string, bool) {
+	match := ordinalName.FindStringSubmatch(name)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// ordinalPreviewName resolves the preview counterpart of a per-pod service.
+// Both "db-0-preview" (pattern applied to the pod service) and
+// "db-preview-0" (pattern applied to the StatefulSet name) are accepted, the
+// former taking precedence.
+func ordinalPreviewName(name string, pattern string, exists func(string) bool) (string, bool, error) {
+	direct, err := ApplyPattern(pattern, name)
+	if err != nil {
+		return "", false, err
+	}
+	if exists(direct) {
+		return direct, true, nil
+	}
+
+	base, ordinal, ok := splitOrdinal(name)
+	if !ok {
+		return "", false, nil
+	}
+	previewBase, err := ApplyPattern(pattern, base)
+	if err != nil {
+		return "", false, err
+	}
+	candidate := previewBase + "-" + ordinal
+	return candidate, exists(candidate), nil
+}
+
+// discoverHeadlessOrdinals pairs the pods behind a headless governing service
+// with the pods behind its preview counterpart by StatefulSet ordinal. Pod
+// hostnames come from EndpointSlices, and each mapping is named after the
+// pod's DNS label under the governing service ("db-0.db"), so DNS mode pins
+// the per-pod hostname stateful clients actually use.
+func discoverHeadlessOrdinals(ctx context.Context, cfg Config, active *corev1.Service, preview *corev1.Service, logger *slog.Logger) ([]ServiceMapping, error) {
+	activePods, err := ordinalEndpoints(ctx, cfg, active.Name)
+	if err != nil {
+		return nil, err
+	}
+	previewPods, err := ordinalEndpoints(ctx, cfg, preview.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ordinals := make([]string, 0, len(activePods))
+	for ordinal := range activePods {
+		ordinals = append(ordinals, ordinal)
+	}
+	sort.Strings(ordinals)
+
+	previewPorts := buildNumericPortMap(preview.Spec.Ports)
+	skipped := skipPorts(active)
+	var mappings []ServiceMapping
+	for _, ordinal := range ordinals {
+		activePod := activePods[ordinal]
+		previewPod, ok := previewPods[ordinal]
+		if !ok {
+			logger.Debug("no preview pod for ordinal", slog.String("service", active.Name), slog.String("hostname", activePod.hostname))
+			continue
+		}
+
+		for _, port := range active.Spec.Ports {
+			if skipped[numericPortKey(port)] {
+				logger.Info("skipping port opted out by annotation", slog.String("service", active.Name), slog.Int("port", int(port.Port)), slog.String("protocol", string(port.Protocol)), slog.String("annotation", AnnotationSkipPorts))
+				cfg.Stats.skip(SkipReasonSkipPorts)
+				continue
+			}
+			if _, ok := previewPorts[numericPortKey(port)]; !ok {
+				logger.Warn("preview service missing matching port", slog.String("service", active.Name), slog.String("preview_service", preview.Name), slog.String("port_key", numericPortKey(port)))
+				continue
+			}
+			name := activePod.hostname + "." + active.Name
+			logger.Info("discovered ordinal preview mapping",
+				slog.String("service", name),
+				slog.String("preview_pod", previewPod.hostname),
+				slog.Int("port", int(port.Port)),
+				slog.String("protocol", string(port.Protocol)),
+				slog.String("active_ip", activePod.ip),
+				slog.String("preview_ip", previewPod.ip),
+			)
+			mappings = append(mappings, ServiceMapping{
+				Namespace:          cfg.Namespace,
+				ServiceName:        name,
+				Port:               port.Port,
+				Protocol:           port.Protocol,
+				ActiveClusterIP:    activePod.ip,
+				PreviewClusterIP:   previewPod.ip,
+				PreviewServiceName: previewPod.hostname + "." + preview.Name,
+				PortName:           port.Name,
+			})
+		}
+	}
+
+	return mappings, nil
+}
+
+type ordinalPod struct {
+	hostname string
+	ip       string
+}
+
+func ordinalEndpoints(ctx context.Context, cfg Config, service string) (map[string]ordinalPod, error) {
+	slices, err := cfg.Clientset.DiscoveryV1().EndpointSlices(cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list endpointslices for service %q: %w", service, err)
+	}
+
+	pods := make(map[string]ordinalPod)
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Hostname == nil || len(endpoint.Addresses) == 0 {
+				continue
+			}
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			_, ordinal, ok := splitOrdinal(*endpoint.Hostname)
+			if !ok {
+				continue
+			}
+			// Dual-stack services publish one slice per family; prefer IPv4.
+			if _, seen := pods[ordinal]; seen && slice.AddressType != discoveryv1.AddressTypeIPv4 {
+				continue
+			}
+			pods[ordinal] = ordinalPod{hostname: *endpoint.Hostname, ip: endpoint.Addresses[0]}
+		}
+	}
+	return pods, nil
+}
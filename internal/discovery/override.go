@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Override replaces convention-based pairing for a single active service. It is
+// the discovery-side view of a GhostwireMapping custom resource.
+type Override struct {
+	// Name identifies the source of the override (the custom resource name) so
+	// conflicts can be reported back to it.
+	Name           string
+	Service        string
+	PreviewService string
+	Exclude        bool
+	Ports          []PortOverride
+}
+
+// PortOverride routes one active port to a different preview port. An empty
+// Protocol matches TCP, mirroring the Kubernetes Service default.
+type PortOverride struct {
+	Port        int32
+	PreviewPort int32
+	Protocol    corev1.Protocol
+}
+
+// Conflicts maps override names to the reasons they could not be applied as
+// written. Overrides that applied cleanly are present with an empty slice so
+// callers can clear stale status.
+type Conflicts map[string][]string
+
+func (c Conflicts) add(name string, format string, args ...any) {
+	c[name] = append(c[name], fmt.Sprintf(format, args...))
+}
+
+type overrideIndex struct {
+	byService map[string]*Override
+	conflicts Conflicts
+	seenPorts map[string]map[string]bool
+}
+
+func indexOverrides(overrides []Override) *overrideIndex {
+	idx := &overrideIndex{
+		byService: make(map[string]*Override, len(overrides)),
+		conflicts: make(Conflicts, len(overrides)),
+		seenPorts: make(map[string]map[string]bool, len(overrides)),
+	}
+
+	sorted := append([]Override(nil), overrides...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for i := range sorted {
+		override := &sorted[i]
+		idx.conflicts[override.Name] = nil
+
+		if existing, ok := idx.byService[override.Service]; ok {
+			idx.conflicts.add(override.Name, "service %q is already overridden by %q; ignoring", override.Service, existing.Name)
+			continue
+		}
+		idx.byService[override.Service] = override
+		idx.seenPorts[override.Name] = make(map[string]bool, len(override.Ports))
+	}
+
+	return idx
+}
+
+func (idx *overrideIndex) lookup(service string) (*Override, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	override, ok := idx.byService[service]
+	return override, ok
+}
+
+// previewPort returns the preview port for an active port, honoring overrides.
+func (idx *overrideIndex) previewPort(override *Override, port corev1.ServicePort) int32 {
+	if override == nil {
+		return port.Port
+	}
+	for _, candidate := range override.Ports {
+		protocol := candidate.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		if candidate.Port == port.Port && protocol == port.Protocol {
+			idx.seenPorts[override.Name][numericPortKey(port)] = true
+			return candidate.PreviewPort
+		}
+	}
+	return port.Port
+}
+
+// finalize records conflicts for overrides whose services or ports never matched.
+func (idx *overrideIndex) finalize(services map[string]*corev1.Service) Conflicts {
+	if idx == nil {
+		return nil
+	}
+
+	for service, override := range idx.byService {
+		if _, ok := services[service]; !ok {
+			idx.conflicts.add(override.Name, "service %q not found", service)
+			continue
+		}
+		if override.PreviewService != "" && !strings.ContainsAny(override.PreviewService, "/.") {
+			if _, ok := services[override.PreviewService]; !ok {
+				idx.conflicts.add(override.Name, "preview service %q not found", override.PreviewService)
+			}
+		}
+		for _, port := range override.Ports {
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			key := fmt.Sprintf("%d/%s", port.Port, protocol)
+			if !idx.seenPorts[override.Name][key] {
+				idx.conflicts.add(override.Name, "port %s not mapped on service %q", key, service)
+			}
+		}
+	}
+
+	return idx.conflicts
+}
@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDiscoverWithOverrides(t *testing.T) {
+	t.Parallel()
+
+	namespace := "ghostwire"
+	services := []corev1.Service{
+		newService("orders", "10.0.0.10", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("orders-preview", "10.0.1.10", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("orders-canary", "10.0.2.10", []corev1.ServicePort{port("http", 8080, corev1.ProtocolTCP)}),
+		newService("payment", "10.0.0.20", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("payment-preview", "10.0.1.20", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("legacy", "10.0.0.30", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+		newService("legacy-v2", "10.0.2.30", []corev1.ServicePort{port("http", 80, corev1.ProtocolTCP)}),
+	}
+
+	tests := []struct {
+		name          string
+		overrides     []Override
+		want          []ServiceMapping
+		wantConflicts Conflicts
+	}{
+		{
+			name: "explicit preview service with port remap",
+			overrides: []Override{{
+				Name:           "orders-canary",
+				Service:        "orders",
+				PreviewService: "orders-canary",
+				Ports:          []PortOverride{{Port: 80, PreviewPort: 8080}},
+			}},
+			want: []ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.2.10", PreviewPort: 8080},
+				{ServiceName: "payment", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.20", PreviewClusterIP: "10.0.1.20"},
+			},
+			wantConflicts: Conflicts{"orders-canary": nil},
+		},
+		{
+			name:      "exclude removes convention mapping",
+			overrides: []Override{{Name: "no-payment", Service: "payment", Exclude: true}},
+			want: []ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10"},
+			},
+			wantConflicts: Conflicts{"no-payment": nil},
+		},
+		{
+			name:      "pairing a service without conventional preview",
+			overrides: []Override{{Name: "legacy", Service: "legacy", PreviewService: "legacy-v2"}},
+			want: []ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10"},
+				{ServiceName: "payment", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.20", PreviewClusterIP: "10.0.1.20"},
+				{ServiceName: "legacy", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.30", PreviewClusterIP: "10.0.2.30"},
+			},
+			wantConflicts: Conflicts{"legacy": nil},
+		},
+		{
+			name: "conflicts reported",
+			overrides: []Override{
+				{Name: "a-missing", Service: "ghost"},
+				{Name: "b-dup", Service: "ghost"},
+				{Name: "c-bad-preview", Service: "legacy", PreviewService: "legacy-v9"},
+				{Name: "d-bad-port", Service: "payment", Ports: []PortOverride{{Port: 443, PreviewPort: 8443}, {Port: 80, PreviewPort: 81}}},
+			},
+			want: []ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10"},
+			},
+			wantConflicts: Conflicts{
+				"a-missing":     {`service "ghost" not found`},
+				"b-dup":         {`service "ghost" is already overridden by "a-missing"; ignoring`},
+				"c-bad-preview": {`preview service "legacy-v9" not found`},
+				"d-bad-port":    {`preview service "payment-preview" has no port 81/TCP`, `port 443/TCP not mapped on service "payment"`},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, _ := newTestLogger()
+			cfg := Config{
+				Clientset:      newTestClientset(t, namespace, makeServiceList(services...), 0, nil),
+				Namespace:      namespace,
+				PreviewPattern: DefaultPreviewPattern,
+				PreviewSuffix:  "-preview",
+				Overrides:      tc.overrides,
+			}
+
+			got, conflicts, err := DiscoverWithOverrides(context.Background(), cfg, logger)
+			if err != nil {
+				t.Fatalf("DiscoverWithOverrides returned error: %v", err)
+			}
+
+			assertMappings(t, got, tc.want)
+			for _, want := range tc.want {
+				for _, m := range got {
+					if mappingKey(m) == mappingKey(want) && m.PreviewPort != want.PreviewPort {
+						t.Fatalf("mapping %s preview port = %d, want %d", mappingKey(m), m.PreviewPort, want.PreviewPort)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(conflicts, tc.wantConflicts) {
+				t.Fatalf("unexpected conflicts:\n got %#v\nwant %#v", conflicts, tc.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PairByName pairs services through the preview name pattern or suffixes.
+	PairByName = "name"
+	// PairByRelease pairs services belonging to an active Helm release with
+	// their counterparts in the corresponding preview release.
+	PairByRelease = "release"
+
+	// DefaultReleaseLabel is the label Helm charts conventionally set to the
+	// release name.
+	DefaultReleaseLabel = "app.kubernetes.io/instance"
+)
+
+// releasePairs maps active service names to preview service names for shops
+// that deploy previews as separate Helm releases. The preview release name is
+// produced by applying pattern to the active release name. Within a release,
+// services are matched by their name relative to the release (the service
+// name with a leading "<release>-" removed), so "shop-api" in release "shop"
+// pairs with "shop-preview-api" in release "shop-preview".
+func releasePairs(services []corev1.Service, releaseLabel string, pattern string) (map[string]string, error) {
+	if releaseLabel == "" {
+		releaseLabel = DefaultReleaseLabel
+	}
+
+	byRelease := make(map[string]map[string]string)
+	for i := range services {
+		svc := &services[i]
+		release := svc.Labels[releaseLabel]
+		if release == "" {
+			continue
+		}
+		if byRelease[release] == nil {
+			byRelease[release] = make(map[string]string)
+		}
+		byRelease[release][releaseRelativeName(svc.Name, release)] = svc.Name
+	}
+
+	pairs := make(map[string]string)
+	for release, members := range byRelease {
+		previewRelease, err := ApplyPattern(pattern, release)
+		if err != nil {
+			return nil, fmt.Errorf("derive preview release for %q: %w", release, err)
+		}
+		if previewRelease == release {
+			continue
+		}
+		previewMembers, ok := byRelease[previewRelease]
+		if !ok {
+			continue
+		}
+		for relative, name := range members {
+			if previewName, ok := previewMembers[relative]; ok {
+				pairs[name] = previewName
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+func releaseRelativeName(name string, release string) string {
+	if name == release {
+		return ""
+	}
+	return strings.TrimPrefix(name, release+"-")
+}
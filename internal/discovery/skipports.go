@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationSkipPorts on an active Service lists ports, by number or name,
+// that are never DNAT'd even when the rest of the Service is previewed, e.g.
+// "9090,metrics".
+const AnnotationSkipPorts = "ghostwire.dev/skip-ports"
+
+// skipPorts returns the ports of svc its skip-ports annotation opts out.
+func skipPorts(svc *corev1.Service) map[string]bool {
+	raw := strings.TrimSpace(svc.Annotations[AnnotationSkipPorts])
+	if raw == "" {
+		return nil
+	}
+	skipped := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if entry == port.Name || entry == strconv.Itoa(int(port.Port)) {
+				skipped[numericPortKey(port)] = true
+			}
+		}
+	}
+	return skipped
+}
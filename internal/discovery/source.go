@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MappingSource produces service mappings: name-pattern discovery, a static
+// file, a controller ConfigMap, or whatever a rollout tool knows about.
+type MappingSource interface {
+	// Name identifies the source in logs and errors.
+	Name() string
+	List(ctx context.Context) ([]ServiceMapping, error)
+}
+
+// WatchingSource is implemented by sources that learn about changes. Watch
+// returns a channel that receives whenever List may return something new and
+// is closed once ctx ends.
+type WatchingSource interface {
+	MappingSource
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// SourceFactory builds a source for the namespace being managed.
+type SourceFactory func(namespace string, logger *slog.Logger) (MappingSource, error)
+
+var (
+	sourcesMu       sync.RWMutex
+	sourceFactories = map[string]SourceFactory{}
+)
+
+// RegisterSource makes a source available to NewSource under name. Like
+// http.Handle it panics on an empty name or one registered twice, since both
+// are programming errors.
+func RegisterSource(name string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("discovery: RegisterSource needs a name and a factory")
+	}
+	if _, exists := sourceFactories[name]; exists {
+		panic("discovery: mapping source " + name + " registered twice")
+	}
+	sourceFactories[name] = factory
+}
+
+// SourceNames returns the registered source names, sorted.
+func SourceNames() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+
+	names := make([]string, 0, len(sourceFactories))
+	for name := range sourceFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewSource builds the source registered as name for namespace.
+func NewSource(name, namespace string, logger *slog.Logger) (MappingSource, error) {
+	sourcesMu.RLock()
+	factory, ok := sourceFactories[name]
+	sourcesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown mapping source %q (registered: %s)", name, strings.Join(SourceNames(), ", "))
+	}
+	return factory(namespace, logger)
+}
+
+// SourceFunc adapts a function to a MappingSource.
+type SourceFunc struct {
+	SourceName string
+	ListFunc   func(ctx context.Context) ([]ServiceMapping, error)
+}
+
+// Name returns SourceName.
+func (s SourceFunc) Name() string { return s.SourceName }
+
+// List calls ListFunc.
+func (s SourceFunc) List(ctx context.Context) ([]ServiceMapping, error) { return s.ListFunc(ctx) }
+
+// PatternSource is name-pattern discovery as a MappingSource.
+type PatternSource struct {
+	Config Config
+	Logger *slog.Logger
+}
+
+// Name returns "services".
+func (PatternSource) Name() string { return "services" }
+
+// List runs Discover.
+func (p PatternSource) List(ctx context.Context) ([]ServiceMapping, error) {
+	return Discover(ctx, p.Config, p.Logger)
+}
+
+// FileSource reads a static mappings file (see LoadMappingsFile).
+type FileSource struct {
+	Path string
+}
+
+// Name returns "file".
+func (FileSource) Name() string { return "file" }
+
+// List loads the file.
+func (f FileSource) List(context.Context) ([]ServiceMapping, error) {
+	return LoadMappingsFile(f.Path)
+}
+
+// unionSource merges several sources, see Union.
+type unionSource struct {
+	sources []MappingSource
+	logger  *slog.Logger
+}
+
+// Union returns a source listing the mappings of every source, in order. A
+// mapping for the same service, port and protocol from a later source
+// replaces the earlier one in place, so order the sources by increasing
+// precedence. Any source failing fails the union.
+func Union(logger *slog.Logger, sources ...MappingSource) MappingSource {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &unionSource{sources: sources, logger: logger}
+}
+
+// Name joins the names of the merged sources with "+".
+func (u *unionSource) Name() string {
+	names := make([]string, 0, len(u.sources))
+	for _, source := range u.sources {
+		names = append(names, source.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+// List lists every source and merges the results.
+func (u *unionSource) List(ctx context.Context) ([]ServiceMapping, error) {
+	key := func(m ServiceMapping) string {
+		return fmt.Sprintf("%s:%d/%s", m.ServiceName, m.Port, m.Protocol)
+	}
+
+	var merged []ServiceMapping
+	index := make(map[string]int)
+	from := make(map[string]string)
+	for _, source := range u.sources {
+		mappings, err := source.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("mapping source %s: %w", source.Name(), err)
+		}
+		for _, mapping := range mappings {
+			k := key(mapping)
+			i, exists := index[k]
+			if !exists {
+				index[k] = len(merged)
+				from[k] = source.Name()
+				merged = append(merged, mapping)
+				continue
+			}
+			if from[k] != source.Name() {
+				u.logger.Info("mapping replaced by a later source",
+					slog.String("mapping", k),
+					slog.String("source", source.Name()),
+					slog.String("replaced_source", from[k]),
+				)
+				// Whole-service rules assume the earlier source's port view;
+				// a replaced port may point elsewhere.
+				mapping.IdenticalPorts = false
+			}
+			from[k] = source.Name()
+			merged[i] = mapping
+		}
+	}
+	return merged, nil
+}
+
+// Watch fans in the change notifications of the sources that can watch. The
+// returned channel is nil, and never fires, when none can.
+func (u *unionSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	var channels []<-chan struct{}
+	for _, source := range u.sources {
+		watching, ok := source.(WatchingSource)
+		if !ok {
+			continue
+		}
+		ch, err := watching.Watch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("watch mapping source %s: %w", source.Name(), err)
+		}
+		channels = append(channels, ch)
+	}
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	out := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(ch <-chan struct{}) {
+			defer wg.Done()
+			for range ch {
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func listOf(name string, mappings ...ServiceMapping) MappingSource {
+	return SourceFunc{SourceName: name, ListFunc: func(context.Context) ([]ServiceMapping, error) {
+		return mappings, nil
+	}}
+}
+
+func TestUnionLaterSourcesWin(t *testing.T) {
+	t.Parallel()
+
+	logger, buf := newTestLogger()
+	discovered := listOf("services",
+		ServiceMapping{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", IdenticalPorts: true},
+		ServiceMapping{ServiceName: "billing", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	)
+	rollout := listOf("rollout",
+		ServiceMapping{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.2.1", IdenticalPorts: true},
+		ServiceMapping{ServiceName: "search", Port: 9200, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.1.3"},
+	)
+
+	union := Union(logger, discovered, rollout)
+	if union.Name() != "services+rollout" {
+		t.Fatalf("unexpected union name %q", union.Name())
+	}
+	got, err := union.List(context.Background())
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(got) != 3 || got[0].PreviewClusterIP != "10.0.2.1" || got[0].IdenticalPorts || got[1].ServiceName != "billing" || got[2].ServiceName != "search" {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+	if !strings.Contains(buf.String(), "mapping replaced by a later source") {
+		t.Fatalf("expected the replacement to be logged, got %s", buf.String())
+	}
+
+	failing := SourceFunc{SourceName: "broken", ListFunc: func(context.Context) ([]ServiceMapping, error) {
+		return nil, errors.New("boom")
+	}}
+	if _, err := Union(logger, discovered, failing).List(context.Background()); err == nil || !strings.Contains(err.Error(), "mapping source broken: boom") {
+		t.Fatalf("expected the failing source to be named, got %v", err)
+	}
+}
+
+func TestSourceRegistry(t *testing.T) {
+	RegisterSource("test-static", func(namespace string, _ *slog.Logger) (MappingSource, error) {
+		return listOf("test-static", ServiceMapping{Namespace: namespace, ServiceName: "orders"}), nil
+	})
+
+	source, err := NewSource("test-static", "shop", nil)
+	if err != nil {
+		t.Fatalf("NewSource returned error: %v", err)
+	}
+	got, err := source.List(context.Background())
+	if err != nil || len(got) != 1 || got[0].Namespace != "shop" {
+		t.Fatalf("unexpected mappings %+v (err %v)", got, err)
+	}
+
+	if _, err := NewSource("argo", "shop", nil); err == nil || !strings.Contains(err.Error(), "test-static") {
+		t.Fatalf("expected an unknown source error listing registered sources, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a duplicate registration to panic")
+		}
+	}()
+	RegisterSource("test-static", func(string, *slog.Logger) (MappingSource, error) { return nil, nil })
+}
@@ -0,0 +1,192 @@
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	yamlv3 "go.yaml.in/yaml/v3"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+)
+
+// Static mapping precedence controls which side wins when a static mapping and
+// a discovered mapping cover the same service port.
+const (
+	// StaticPrecedenceFile lets entries from the mappings file replace
+	// discovered ones.
+	StaticPrecedenceFile = "file"
+	// StaticPrecedenceDiscovery keeps discovered mappings and only adds file
+	// entries for ports discovery did not produce.
+	StaticPrecedenceDiscovery = "discovery"
+)
+
+type staticMappingsFile struct {
+	Mappings []ServiceMapping `json:"mappings"`
+}
+
+// LoadMappingsFile reads hand-written ServiceMappings from a YAML or JSON file.
+// The document may be a bare list or an object with a "mappings" list.
+func LoadMappingsFile(path string) ([]ServiceMapping, error) {
+	// #nosec G304 -- mappings file path comes from operator configuration.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mappings file %s: %w", path, err)
+	}
+
+	var mappings []ServiceMapping
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '-') {
+		err = yaml.UnmarshalStrict(data, &mappings)
+	} else {
+		var wrapped staticMappingsFile
+		err = yaml.UnmarshalStrict(data, &wrapped)
+		mappings = wrapped.Mappings
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode mappings file %s: %w", path, err)
+	}
+
+	for i := range mappings {
+		if field, err := validateStaticMapping(&mappings[i]); err != nil {
+			if parseErr := mappingsFileError(data, path, i, field, err); parseErr != nil {
+				return nil, parseErr
+			}
+			return nil, fmt.Errorf("mappings file %s entry %d: %w", path, i, err)
+		}
+	}
+	return mappings, nil
+}
+
+// validateStaticMapping checks a hand-written mapping and defaults its
+// protocol. On failure it also returns the JSON name of the offending field.
+func validateStaticMapping(mapping *ServiceMapping) (string, error) {
+	if mapping.ServiceName == "" {
+		return "serviceName", fmt.Errorf("serviceName is required")
+	}
+	if mapping.Port <= 0 || mapping.Port > 65535 {
+		return "port", fmt.Errorf("service %q: port %d out of range", mapping.ServiceName, mapping.Port)
+	}
+	if mapping.PreviewPort < 0 || mapping.PreviewPort > 65535 {
+		return "previewPort", fmt.Errorf("service %q: previewPort %d out of range", mapping.ServiceName, mapping.PreviewPort)
+	}
+	switch mapping.Protocol {
+	case "":
+		mapping.Protocol = corev1.ProtocolTCP
+	case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+	default:
+		return "protocol", fmt.Errorf("service %q: unsupported protocol %q", mapping.ServiceName, mapping.Protocol)
+	}
+	if net.ParseIP(mapping.ActiveClusterIP) == nil {
+		return "activeClusterIP", fmt.Errorf("service %q: invalid activeClusterIP %q", mapping.ServiceName, mapping.ActiveClusterIP)
+	}
+	if net.ParseIP(mapping.PreviewClusterIP) == nil {
+		return "previewClusterIP", fmt.Errorf("service %q: invalid previewClusterIP %q", mapping.ServiceName, mapping.PreviewClusterIP)
+	}
+	return "", nil
+}
+
+// mappingsFileError locates field of entry index in the raw document so the
+// validation error can point at its line and column. It falls back to the
+// entry itself when the field is absent, and returns nil when the document
+// cannot be walked.
+func mappingsFileError(data []byte, path string, index int, field string, err error) error {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	list := doc.Content[0]
+	if list.Kind == yamlv3.MappingNode {
+		list = mappingValue(list, "mappings")
+	}
+	if list == nil || list.Kind != yamlv3.SequenceNode || index >= len(list.Content) {
+		return nil
+	}
+
+	entry := list.Content[index]
+	node := entry
+	if value := mappingValue(entry, field); value != nil {
+		node = value
+	}
+	token := node.Value
+	if node == entry {
+		token = "-"
+	}
+	return &config.ParseError{Source: path, Line: node.Line, Column: node.Column, Token: token, Err: err}
+}
+
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// MergeMappings combines discovered and static mappings keyed by service,
+// port, and protocol. precedence decides which side wins on collisions;
+// non-colliding static entries are always appended.
+func MergeMappings(discovered []ServiceMapping, static []ServiceMapping, precedence string, logger *slog.Logger) ([]ServiceMapping, error) {
+	switch precedence {
+	case "":
+		precedence = StaticPrecedenceFile
+	case StaticPrecedenceFile, StaticPrecedenceDiscovery:
+	default:
+		return nil, fmt.Errorf("unsupported mappings file precedence %q (expected %s or %s)", precedence, StaticPrecedenceFile, StaticPrecedenceDiscovery)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	key := func(m ServiceMapping) string {
+		return fmt.Sprintf("%s:%d/%s", m.ServiceName, m.Port, m.Protocol)
+	}
+
+	staticByKey := make(map[string]ServiceMapping, len(static))
+	for _, mapping := range static {
+		staticByKey[key(mapping)] = mapping
+	}
+
+	merged := make([]ServiceMapping, 0, len(discovered)+len(static))
+	used := make(map[string]bool, len(static))
+	for _, mapping := range discovered {
+		override, ok := staticByKey[key(mapping)]
+		if !ok {
+			merged = append(merged, mapping)
+			continue
+		}
+		used[key(mapping)] = true
+		if precedence == StaticPrecedenceDiscovery {
+			logger.Info("static mapping shadowed by discovery", slog.String("mapping", key(mapping)))
+			merged = append(merged, mapping)
+			continue
+		}
+		logger.Info("static mapping overrides discovery", slog.String("mapping", key(mapping)), slog.String("preview_ip", override.PreviewClusterIP))
+		// Whole-service rules assume discovery's port view; a replaced port
+		// may point elsewhere.
+		override.IdenticalPorts = false
+		merged = append(merged, override)
+	}
+
+	for _, mapping := range static {
+		if used[key(mapping)] {
+			continue
+		}
+		used[key(mapping)] = true
+		// Later duplicates in the file win, matching the collision handling above.
+		mapping = staticByKey[key(mapping)]
+		logger.Info("adding static mapping", slog.String("mapping", key(mapping)), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP))
+		merged = append(merged, mapping)
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/config"
+)
+
+func TestLoadMappingsFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "yaml object",
+			content: "mappings:\n- serviceName: legacy-db\n  port: 5432\n  activeClusterIP: 192.0.2.10\n  previewClusterIP: 192.0.2.20\n",
+			want:    1,
+		},
+		{
+			name:    "json list",
+			content: `[{"serviceName":"dns","port":53,"protocol":"UDP","activeClusterIP":"10.0.0.53","previewClusterIP":"10.0.1.53"}]`,
+			want:    1,
+		},
+		{
+			name:    "unknown field",
+			content: "mappings:\n- serviceName: x\n  prot: 80\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid ip",
+			content: "- serviceName: x\n  port: 80\n  activeClusterIP: nope\n  previewClusterIP: 192.0.2.20\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "mappings.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatalf("write mappings file: %v", err)
+			}
+
+			got, err := LoadMappingsFile(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMappingsFile returned error: %v", err)
+			}
+			if len(got) != tc.want {
+				t.Fatalf("expected %d mappings, got %+v", tc.want, got)
+			}
+			if got[0].Protocol == "" {
+				t.Fatalf("expected protocol to be defaulted")
+			}
+		})
+	}
+}
+
+func TestLoadMappingsFilePosition(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "mappings.yaml")
+	content := "mappings:\n- serviceName: a\n  port: 80\n  activeClusterIP: 192.0.2.10\n  previewClusterIP: 192.0.2.20\n- serviceName: b\n  port: 80\n  protocol: ICMP\n  activeClusterIP: 192.0.2.11\n  previewClusterIP: 192.0.2.21\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write mappings file: %v", err)
+	}
+
+	_, err := LoadMappingsFile(path)
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if parseErr.Line != 8 || parseErr.Column != 13 || parseErr.Token != "ICMP" {
+		t.Fatalf("expected 8:13 \"ICMP\", got %v", err)
+	}
+}
+
+func TestMergeMappings(t *testing.T) {
+	t.Parallel()
+
+	discovered := []ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", IdenticalPorts: true},
+		{ServiceName: "payment", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	}
+	static := []ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.9.9"},
+		{ServiceName: "legacy", Port: 5432, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "192.0.2.10", PreviewClusterIP: "192.0.2.20"},
+	}
+	logger, _ := newTestLogger()
+
+	fileWins, err := MergeMappings(discovered, static, StaticPrecedenceFile, logger)
+	if err != nil {
+		t.Fatalf("MergeMappings returned error: %v", err)
+	}
+	if len(fileWins) != 3 || fileWins[0].PreviewClusterIP != "10.0.9.9" || fileWins[0].IdenticalPorts || fileWins[2].ServiceName != "legacy" {
+		t.Fatalf("unexpected file-precedence merge: %+v", fileWins)
+	}
+
+	discoveryWins, err := MergeMappings(discovered, static, StaticPrecedenceDiscovery, logger)
+	if err != nil {
+		t.Fatalf("MergeMappings returned error: %v", err)
+	}
+	if len(discoveryWins) != 3 || discoveryWins[0].PreviewClusterIP != "10.0.1.1" {
+		t.Fatalf("unexpected discovery-precedence merge: %+v", discoveryWins)
+	}
+
+	if _, err := MergeMappings(discovered, static, "newest", logger); err == nil {
+		t.Fatalf("expected unknown precedence to be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package discovery
+
+import (
+	"log/slog"
+	"sort"
+)
+
+// Skip reasons recorded in Stats.Skipped.
+const (
+	SkipReasonExcludeSelector = "exclude_selector"
+	SkipReasonNotSelected     = "not_selected"
+	SkipReasonOverrideExclude = "override_exclude"
+	SkipReasonNoPreview       = "no_preview"
+	SkipReasonSkipPorts       = "skip_ports"
+)
+
+// Stats counts discovery outcomes. Set Config.Stats to collect them.
+type Stats struct {
+	// Services is the number of services listed in the namespace.
+	Services int
+	// Mappings is the number of mappings produced.
+	Mappings int
+	// Skipped counts services left out of pairing, by reason. Ports opted
+	// out through AnnotationSkipPorts count one each under skip_ports.
+	Skipped map[string]int
+}
+
+func (s *Stats) skip(reason string) {
+	if s == nil {
+		return
+	}
+	if s.Skipped == nil {
+		s.Skipped = make(map[string]int)
+	}
+	s.Skipped[reason]++
+}
+
+// LogValue renders the stats as a structured log group.
+func (s *Stats) LogValue() slog.Value {
+	if s == nil {
+		return slog.GroupValue()
+	}
+	attrs := []slog.Attr{
+		slog.Int("services", s.Services),
+		slog.Int("mappings", s.Mappings),
+	}
+	reasons := make([]string, 0, len(s.Skipped))
+	for reason := range s.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		attrs = append(attrs, slog.Int("skipped_"+reason, s.Skipped[reason]))
+	}
+	return slog.GroupValue(attrs...)
+}
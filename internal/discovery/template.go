@@ -7,28 +7,37 @@ import (
 	"strings"
 	"sync"
 	"text/template"
+
+	"github.com/denniswebb/ghostwire/internal/config"
 )
 
 var (
 	templateCache sync.Map
 )
 
-const DefaultPreviewPattern = "{{name}}-preview"
+const DefaultPreviewPattern = config.DefaultPreviewPattern
 
 type patternData struct {
-	Name string
+	Name      string
+	Namespace string
 }
 
 // ApplyPattern renders the preview service name using the configured template
 // string. Templates are cached after the first parse to avoid repeated work.
 func ApplyPattern(pattern string, serviceName string) (string, error) {
+	return ApplyNamespacedPattern(pattern, serviceName, "")
+}
+
+// ApplyNamespacedPattern renders pattern like ApplyPattern and additionally
+// substitutes {{namespace}} with the active service's namespace.
+func ApplyNamespacedPattern(pattern, serviceName, namespace string) (string, error) {
 	tpl, err := loadTemplate(pattern)
 	if err != nil {
 		return "", err
 	}
 
 	var buf bytes.Buffer
-	if err := tpl.Execute(&buf, patternData{Name: serviceName}); err != nil {
+	if err := tpl.Execute(&buf, patternData{Name: serviceName, Namespace: namespace}); err != nil {
 		return "", fmt.Errorf("render preview pattern %q for service %q: %w", pattern, serviceName, err)
 	}
 
@@ -36,15 +45,33 @@ func ApplyPattern(pattern string, serviceName string) (string, error) {
 }
 
 // DerivePreviewName resolves the preview service name using configured suffixes
-// or, if they do not apply, the provided pattern-based fallback.
-func DerivePreviewName(name, activeSuffix, previewSuffix, pattern string) (string, error) {
+// or, if they do not apply, the provided pattern-based fallback. namespace is
+// the active service's namespace, available to the pattern as {{namespace}}.
+func DerivePreviewName(name, namespace, activeSuffix, previewSuffix, pattern string) (string, error) {
 	if activeSuffix != "" && previewSuffix != "" && strings.HasSuffix(name, activeSuffix) {
 		return strings.TrimSuffix(name, activeSuffix) + previewSuffix, nil
 	}
-	return ApplyPattern(pattern, name)
+	return ApplyNamespacedPattern(pattern, name, namespace)
 }
 
-var namePlaceholder = regexp.MustCompile(`{{\s*name\s*}}`)
+// SplitPreviewRef splits a rendered preview reference into namespace and
+// service name. "ns/name" and "name.ns" name a service in another namespace;
+// a plain name resolves in namespace. Service names cannot contain dots, so
+// the second form is unambiguous.
+func SplitPreviewRef(ref, namespace string) (string, string) {
+	if ns, name, ok := strings.Cut(ref, "/"); ok {
+		return ns, name
+	}
+	if name, ns, ok := strings.Cut(ref, "."); ok {
+		return ns, name
+	}
+	return namespace, ref
+}
+
+var (
+	namePlaceholder      = regexp.MustCompile(`{{\s*name\s*}}`)
+	namespacePlaceholder = regexp.MustCompile(`{{\s*namespace\s*}}`)
+)
 
 func loadTemplate(pattern string) (*template.Template, error) {
 	if tpl, ok := templateCache.Load(pattern); ok {
@@ -52,6 +79,7 @@ func loadTemplate(pattern string) (*template.Template, error) {
 	}
 
 	normalized := namePlaceholder.ReplaceAllString(pattern, "{{.Name}}")
+	normalized = namespacePlaceholder.ReplaceAllString(normalized, "{{.Namespace}}")
 
 	tpl, err := template.New("svc_preview_pattern").Parse(normalized)
 	if err != nil {
@@ -100,6 +100,25 @@ func TestApplyPattern(t *testing.T) {
 	}
 }
 
+func TestSplitPreviewRef(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ref, wantNamespace, wantName string
+	}{
+		{ref: "orders-preview", wantNamespace: "shop", wantName: "orders-preview"},
+		{ref: "previews/orders", wantNamespace: "previews", wantName: "orders"},
+		{ref: "orders.shop-preview", wantNamespace: "shop-preview", wantName: "orders"},
+	}
+
+	for _, tc := range tests {
+		ns, name := SplitPreviewRef(tc.ref, "shop")
+		if ns != tc.wantNamespace || name != tc.wantName {
+			t.Fatalf("SplitPreviewRef(%q) = %q, %q; want %q, %q", tc.ref, ns, name, tc.wantNamespace, tc.wantName)
+		}
+	}
+}
+
 func TestDerivePreviewName(t *testing.T) {
 	t.Parallel()
 
@@ -159,7 +178,7 @@ func TestDerivePreviewName(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := DerivePreviewName(tc.service, tc.activeSuffix, tc.previewSuffix, tc.pattern)
+			got, err := DerivePreviewName(tc.service, "", tc.activeSuffix, tc.previewSuffix, tc.pattern)
 			if tc.wantErr {
 				if err == nil {
 					t.Fatalf("DerivePreviewName expected error for %q", tc.service)
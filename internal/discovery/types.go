@@ -9,20 +9,55 @@ import (
 // ServiceMapping represents a single port mapping between an active/base service
 // and its preview variant. These mappings later drive DNAT rule creation.
 type ServiceMapping struct {
-	ServiceName      string
-	Port             int32
-	Protocol         corev1.Protocol
-	ActiveClusterIP  string
-	PreviewClusterIP string
+	// Namespace is the namespace the services were discovered in.
+	Namespace        string          `json:"namespace,omitempty"`
+	ServiceName      string          `json:"serviceName"`
+	Port             int32           `json:"port"`
+	Protocol         corev1.Protocol `json:"protocol"`
+	ActiveClusterIP  string          `json:"activeClusterIP"`
+	PreviewClusterIP string          `json:"previewClusterIP"`
+	// PreviewServiceName is the service the mapping redirects to, as
+	// "namespace/name" when it lives outside Namespace.
+	PreviewServiceName string `json:"previewServiceName,omitempty"`
+	// PortName is the active service's port name, if any.
+	PortName string `json:"portName,omitempty"`
+	// PreviewPort is the destination port on the preview service. Zero means the
+	// preview service listens on the same port as the active one.
+	PreviewPort int32 `json:"previewPort,omitempty"`
+	// IdenticalPorts reports that the active and preview services expose
+	// exactly the same port/protocol set without remapping, so one DNAT rule
+	// per destination can stand in for the per-port rules.
+	IdenticalPorts bool `json:"identicalPorts,omitempty"`
+	// ActiveTargetPort and PreviewTargetPort record each service's targetPort
+	// (a number or a named container port) for endpoint-level modes. They are
+	// only populated when discovery is asked to record them.
+	ActiveTargetPort  string `json:"activeTargetPort,omitempty"`
+	PreviewTargetPort string `json:"previewTargetPort,omitempty"`
+	// PreviewEndpoints lists the ready preview pods behind the port, for
+	// endpoint-level DNAT. Only populated when discovery is asked to resolve
+	// endpoints.
+	PreviewEndpoints []Endpoint `json:"previewEndpoints,omitempty"`
+}
+
+// TargetPort returns the preview port DNAT should rewrite to.
+func (m ServiceMapping) TargetPort() int32 {
+	if m.PreviewPort != 0 {
+		return m.PreviewPort
+	}
+	return m.Port
 }
 
 func (m ServiceMapping) String() string {
+	preview := m.PreviewClusterIP
+	if m.PreviewPort != 0 && m.PreviewPort != m.Port {
+		preview = fmt.Sprintf("%s:%d", m.PreviewClusterIP, m.PreviewPort)
+	}
 	return fmt.Sprintf(
 		"%s:%d/%s -> active=%s preview=%s",
 		m.ServiceName,
 		m.Port,
 		string(m.Protocol),
 		m.ActiveClusterIP,
-		m.PreviewClusterIP,
+		preview,
 	)
 }
@@ -0,0 +1,200 @@
+// Package dns implements ghostwire's DNS-assisted routing mode. Instead of
+// relying solely on DNAT, init renders hosts-file overrides that pin active
+// service names to preview ClusterIPs, and the watcher splices them into the
+// pod's hosts file while the role is preview. This covers clients that pin the
+// resolved address and never traverse the DNAT chain with the original IP.
+// A resolv.conf search path cannot do this: search domains only append
+// suffixes, so they can never turn "orders" into "orders-preview".
+package dns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+const (
+	// DefaultSuffix is the cluster DNS suffix appended to namespaced service names.
+	DefaultSuffix = ".svc.cluster.local"
+
+	hostsBlockBegin = "# BEGIN ghostwire preview overrides"
+	hostsBlockEnd   = "# END ghostwire preview overrides"
+)
+
+// HostsEntry pins a set of service hostnames to the preview ClusterIP.
+type HostsEntry struct {
+	IP    string
+	Names []string
+}
+
+// BuildHostsEntries renders one hosts entry per discovered service so the short
+// name, the namespaced name, and the fully qualified name of the active service
+// all resolve to the preview ClusterIP. Services contributing several port
+// mappings collapse into a single entry.
+func BuildHostsEntries(mappings []discovery.ServiceMapping, namespace string, suffix string) []HostsEntry {
+	suffix = strings.TrimSpace(suffix)
+	if suffix == "" {
+		suffix = DefaultSuffix
+	}
+	if !strings.HasPrefix(suffix, ".") {
+		suffix = "." + suffix
+	}
+	suffix = strings.TrimSuffix(suffix, ".")
+
+	seen := make(map[string]struct{}, len(mappings))
+	entries := make([]HostsEntry, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.ServiceName == "" || mapping.PreviewClusterIP == "" {
+			continue
+		}
+		if _, ok := seen[mapping.ServiceName]; ok {
+			continue
+		}
+		seen[mapping.ServiceName] = struct{}{}
+
+		names := []string{mapping.ServiceName}
+		if namespace != "" {
+			qualified := mapping.ServiceName + "." + namespace
+			names = append(names, qualified, qualified+suffix)
+		}
+
+		entries = append(entries, HostsEntry{IP: mapping.PreviewClusterIP, Names: names})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Names[0] < entries[j].Names[0]
+	})
+
+	return entries
+}
+
+// WriteHostsFragment records the rendered hosts block to the shared volume so the
+// watcher can splice it into the pod's hosts file when preview routing activates.
+func WriteHostsFragment(path string, entries []HostsEntry, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	// #nosec G306 -- the fragment is read by the watcher sidecar and contains no secrets.
+	if err := os.WriteFile(path, renderBlock(entries), 0o644); err != nil {
+		return fmt.Errorf("write hosts fragment %s: %w", path, err)
+	}
+
+	logger.Info("wrote dns hosts fragment", slog.String("path", path), slog.Int("entries", len(entries)))
+	return nil
+}
+
+// InstallHostsFragment replaces any ghostwire-managed block in hostsPath with the
+// fragment written by init. The hosts file is rewritten in place because the
+// kubelet bind-mounts it into every container and a rename would detach it.
+func InstallHostsFragment(fragmentPath string, hostsPath string) error {
+	if err := validatePath(fragmentPath); err != nil {
+		return err
+	}
+
+	// #nosec G304 -- fragment path comes from operator configuration and is validated above.
+	fragment, err := os.ReadFile(fragmentPath)
+	if err != nil {
+		return fmt.Errorf("read hosts fragment %s: %w", fragmentPath, err)
+	}
+
+	return rewriteHosts(hostsPath, fragment)
+}
+
+// RemoveHostsBlock strips the ghostwire-managed block from hostsPath, leaving
+// every other line untouched. A missing hosts file is treated as already clean.
+func RemoveHostsBlock(hostsPath string) error {
+	err := rewriteHosts(hostsPath, nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func rewriteHosts(hostsPath string, block []byte) error {
+	if err := validatePath(hostsPath); err != nil {
+		return err
+	}
+
+	// #nosec G304 -- hosts path comes from operator configuration and is validated above.
+	current, err := os.ReadFile(hostsPath)
+	if err != nil {
+		return fmt.Errorf("read hosts file %s: %w", hostsPath, err)
+	}
+
+	updated := stripBlock(current)
+	if len(block) > 0 {
+		if len(updated) > 0 && !bytes.HasSuffix(updated, []byte("\n")) {
+			updated = append(updated, '\n')
+		}
+		updated = append(updated, block...)
+	}
+
+	if bytes.Equal(current, updated) {
+		return nil
+	}
+
+	// #nosec G302,G304 -- hosts path is validated and must stay world-readable for resolvers.
+	file, err := os.OpenFile(hostsPath, os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("open hosts file %s: %w", hostsPath, err)
+	}
+	if _, err := file.Write(updated); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("write hosts file %s: %w", hostsPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close hosts file %s: %w", hostsPath, err)
+	}
+
+	return nil
+}
+
+func renderBlock(entries []HostsEntry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(hostsBlockBegin + "\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "%s\t%s\n", entry.IP, strings.Join(entry.Names, " "))
+	}
+	buf.WriteString(hostsBlockEnd + "\n")
+	return buf.Bytes()
+}
+
+func stripBlock(content []byte) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	var out strings.Builder
+	inBlock := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == hostsBlockBegin:
+			inBlock = true
+		case trimmed == hostsBlockEnd:
+			inBlock = false
+		case !inBlock:
+			out.WriteString(line)
+		}
+	}
+	return []byte(out.String())
+}
+
+func validatePath(path string) error {
+	clean := filepath.Clean(path)
+	for _, part := range strings.Split(clean, string(filepath.Separator)) {
+		if part == ".." {
+			return fmt.Errorf("path %q contains unsupported traversal component", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package dns
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestBuildHostsEntries(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		{ServiceName: "orders", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		{ServiceName: "billing", Port: 8080, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.0.4"},
+		{ServiceName: "broken", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5"},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		suffix    string
+		want      []HostsEntry
+	}{
+		{
+			name:      "default suffix",
+			namespace: "shop",
+			want: []HostsEntry{
+				{IP: "10.0.0.4", Names: []string{"billing", "billing.shop", "billing.shop.svc.cluster.local"}},
+				{IP: "10.0.0.2", Names: []string{"orders", "orders.shop", "orders.shop.svc.cluster.local"}},
+			},
+		},
+		{
+			name:      "custom suffix normalized",
+			namespace: "shop",
+			suffix:    "svc.example.internal.",
+			want: []HostsEntry{
+				{IP: "10.0.0.4", Names: []string{"billing", "billing.shop", "billing.shop.svc.example.internal"}},
+				{IP: "10.0.0.2", Names: []string{"orders", "orders.shop", "orders.shop.svc.example.internal"}},
+			},
+		},
+		{
+			name: "no namespace",
+			want: []HostsEntry{
+				{IP: "10.0.0.4", Names: []string{"billing"}},
+				{IP: "10.0.0.2", Names: []string{"orders"}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := BuildHostsEntries(mappings, tc.namespace, tc.suffix)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("unexpected entries:\n got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallAndRemoveHostsFragment(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fragmentPath := filepath.Join(dir, "hosts.preview")
+	hostsPath := filepath.Join(dir, "hosts")
+
+	original := "127.0.0.1\tlocalhost\n10.1.2.3\tmy-pod\n"
+	if err := os.WriteFile(hostsPath, []byte(original), 0o600); err != nil {
+		t.Fatalf("write hosts: %v", err)
+	}
+
+	entries := []HostsEntry{{IP: "10.0.0.2", Names: []string{"orders", "orders.shop"}}}
+	if err := WriteHostsFragment(fragmentPath, entries, discardLogger()); err != nil {
+		t.Fatalf("WriteHostsFragment returned error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := InstallHostsFragment(fragmentPath, hostsPath); err != nil {
+			t.Fatalf("InstallHostsFragment returned error: %v", err)
+		}
+	}
+
+	installed, err := os.ReadFile(hostsPath)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	want := original + hostsBlockBegin + "\n10.0.0.2\torders orders.shop\n" + hostsBlockEnd + "\n"
+	if string(installed) != want {
+		t.Fatalf("unexpected hosts content after install:\n%q\nwant\n%q", installed, want)
+	}
+
+	if err := RemoveHostsBlock(hostsPath); err != nil {
+		t.Fatalf("RemoveHostsBlock returned error: %v", err)
+	}
+
+	restored, err := os.ReadFile(hostsPath)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	if string(restored) != original {
+		t.Fatalf("expected hosts to be restored, got %q", restored)
+	}
+}
+
+func TestHostsHelpersRejectTraversal(t *testing.T) {
+	t.Parallel()
+
+	if err := WriteHostsFragment("../hosts.preview", nil, discardLogger()); err == nil || !strings.Contains(err.Error(), "traversal") {
+		t.Fatalf("expected traversal error, got %v", err)
+	}
+	if err := InstallHostsFragment("/shared/hosts.preview", "../etc/hosts"); err == nil {
+		t.Fatal("expected error for traversal hosts path")
+	}
+}
+
+func TestRemoveHostsBlockMissingFile(t *testing.T) {
+	t.Parallel()
+
+	if err := RemoveHostsBlock(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("expected missing hosts file to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,414 @@
+// Package dnsproxy implements the in-pod DNS forwarder used by the
+// `ghostwire dnsproxy` sidecar. While the pod's role is preview it answers A
+// and AAAA queries for active service names with the preview ClusterIP; every
+// other query, and every query while the role is active, is relayed verbatim to
+// the upstream resolver.
+package dnsproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/denniswebb/ghostwire/internal/dns"
+)
+
+const (
+	defaultTimeout     = 2 * time.Second
+	defaultMaxInFlight = 128
+	tcpIdleTimeout     = 10 * time.Second
+	answerTTL          = 5
+	maxPacketSize      = 65535
+)
+
+// Config captures the settings required to run the proxy.
+type Config struct {
+	ListenAddr   string
+	Upstream     string
+	ActiveValue  string
+	PreviewValue string
+	Entries      []dns.HostsEntry
+	Timeout      time.Duration
+	// MaxInFlight caps the UDP queries and TCP connections served at once.
+	MaxInFlight int
+	Logger      *slog.Logger
+}
+
+// Proxy answers overridden names locally and forwards everything else upstream.
+// It implements k8s.TransitionHandler so the label poller can flip it between
+// active and preview behavior.
+type Proxy struct {
+	cfg       Config
+	logger    *slog.Logger
+	overrides map[string]netip.Addr
+	preview   atomic.Bool
+	slots     chan struct{}
+}
+
+// New validates the configuration and returns a Proxy ready to serve.
+func New(cfg Config) (*Proxy, error) {
+	if cfg.Upstream == "" {
+		return nil, fmt.Errorf("upstream resolver is required")
+	}
+	if cfg.ActiveValue == "" || cfg.PreviewValue == "" {
+		return nil, fmt.Errorf("active and preview values are required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultMaxInFlight
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	overrides := make(map[string]netip.Addr)
+	for _, entry := range cfg.Entries {
+		addr, err := netip.ParseAddr(entry.IP)
+		if err != nil {
+			return nil, fmt.Errorf("parse preview ip %q: %w", entry.IP, err)
+		}
+		for _, name := range entry.Names {
+			overrides[normalizeName(name)] = addr.Unmap()
+		}
+	}
+
+	return &Proxy{
+		cfg:       cfg,
+		logger:    logger,
+		overrides: overrides,
+		slots:     make(chan struct{}, cfg.MaxInFlight),
+	}, nil
+}
+
+// OnTransition switches the proxy between pass-through and preview answering.
+func (p *Proxy) OnTransition(_ context.Context, previous string, current string) error {
+	switch current {
+	case p.cfg.PreviewValue:
+		p.preview.Store(true)
+		p.logger.Info("dns overrides enabled", slog.String("previous_role", previous), slog.String("current_role", current), slog.Int("names", len(p.overrides)))
+	case p.cfg.ActiveValue:
+		p.preview.Store(false)
+		p.logger.Info("dns overrides disabled", slog.String("previous_role", previous), slog.String("current_role", current))
+	default:
+		p.logger.Debug("ignoring transition", slog.String("previous_role", previous), slog.String("current_role", current))
+	}
+	return nil
+}
+
+// PreviewActive reports whether overrides are currently being served.
+func (p *Proxy) PreviewActive() bool {
+	return p.preview.Load()
+}
+
+// ListenAndServe binds the configured address over UDP and TCP and serves
+// both until ctx is done. TCP carries the retries clients make after a
+// truncated UDP answer.
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, "udp", p.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", p.cfg.ListenAddr, err)
+	}
+	listener, err := lc.Listen(ctx, "tcp", p.cfg.ListenAddr)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("listen on %s/tcp: %w", p.cfg.ListenAddr, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	go func() { errs <- p.Serve(ctx, conn) }()
+	go func() { errs <- p.ServeTCP(ctx, listener) }()
+
+	// Either listener failing stops the other.
+	err = <-errs
+	cancel()
+	if otherErr := <-errs; err == nil {
+		err = otherErr
+	}
+	return err
+}
+
+// Serve handles queries arriving on conn until ctx is cancelled. The connection
+// is closed on return.
+func (p *Proxy) Serve(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	p.logger.Info("dns proxy listening", slog.String("network", "udp"), slog.String("addr", conn.LocalAddr().String()), slog.String("upstream", p.cfg.Upstream))
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("read dns query: %w", err)
+		}
+
+		query := append([]byte(nil), buf[:n]...)
+		if !p.acquire(ctx) {
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.release()
+			reply, err := p.handle(ctx, "udp", query)
+			if err != nil {
+				p.logger.Warn("dns query failed", slog.String("client", addr.String()), slog.Any("error", err))
+				return
+			}
+			if _, err := conn.WriteTo(reply, addr); err != nil && ctx.Err() == nil {
+				p.logger.Warn("failed to write dns reply", slog.String("client", addr.String()), slog.Any("error", err))
+			}
+		}()
+	}
+}
+
+// ServeTCP handles connections accepted from listener until ctx is cancelled.
+// Each connection holds one in-flight slot while it is open and is closed
+// after tcpIdleTimeout without a query. The listener is closed on return.
+func (p *Proxy) ServeTCP(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = listener.Close()
+	})
+	defer stop()
+
+	p.logger.Info("dns proxy listening", slog.String("network", "tcp"), slog.String("addr", listener.Addr().String()), slog.String("upstream", p.cfg.Upstream))
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept dns connection: %w", err)
+		}
+
+		if !p.acquire(ctx) {
+			_ = conn.Close()
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.release()
+			p.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers queries on one TCP connection until the client closes
+// it, it idles out or ctx is cancelled.
+func (p *Proxy) serveConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+	defer conn.Close()
+
+	for {
+		_ = conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+
+		reply, err := p.handle(ctx, "tcp", query)
+		if err != nil {
+			p.logger.Warn("dns query failed", slog.String("client", conn.RemoteAddr().String()), slog.Any("error", err))
+			return
+		}
+		if err := writeTCPMessage(conn, reply); err != nil {
+			if ctx.Err() == nil {
+				p.logger.Warn("failed to write dns reply", slog.String("client", conn.RemoteAddr().String()), slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
+
+// acquire takes an in-flight slot, waiting while the proxy is at its limit.
+// It returns false once ctx is done.
+func (p *Proxy) acquire(ctx context.Context) bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *Proxy) release() {
+	<-p.slots
+}
+
+// handle answers query locally or forwards it upstream over network, the
+// transport the client used.
+func (p *Proxy) handle(ctx context.Context, network string, query []byte) ([]byte, error) {
+	if p.preview.Load() {
+		if reply, ok := p.answer(query); ok {
+			return reply, nil
+		}
+	}
+	return p.forward(ctx, network, query)
+}
+
+// answer builds a local reply when the query targets an overridden name. The
+// second return value is false when the query should be forwarded instead.
+func (p *Proxy) answer(query []byte) ([]byte, bool) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response || header.OpCode != 0 {
+		return nil, false
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return nil, false
+	}
+	if question.Class != dnsmessage.ClassINET || (question.Type != dnsmessage.TypeA && question.Type != dnsmessage.TypeAAAA) {
+		return nil, false
+	}
+
+	addr, ok := p.overrides[normalizeName(question.Name.String())]
+	if !ok {
+		return nil, false
+	}
+
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		Authoritative:      true,
+		RecursionDesired:   header.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              dnsmessage.RCodeSuccess,
+	})
+	builder.EnableCompression()
+
+	if err := builder.StartQuestions(); err != nil {
+		return nil, false
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, false
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, false
+	}
+
+	resource := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: answerTTL}
+	switch {
+	case question.Type == dnsmessage.TypeA && addr.Is4():
+		if err := builder.AResource(resource, dnsmessage.AResource{A: addr.As4()}); err != nil {
+			return nil, false
+		}
+	case question.Type == dnsmessage.TypeAAAA && addr.Is6():
+		if err := builder.AAAAResource(resource, dnsmessage.AAAAResource{AAAA: addr.As16()}); err != nil {
+			return nil, false
+		}
+	}
+
+	reply, err := builder.Finish()
+	if err != nil {
+		return nil, false
+	}
+
+	p.logger.Debug("answered dns query with preview address", slog.String("name", question.Name.String()), slog.String("type", question.Type.String()), slog.String("preview_ip", addr.String()))
+	return reply, true
+}
+
+func (p *Proxy) forward(ctx context.Context, network string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, p.cfg.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream %s: %w", p.cfg.Upstream, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, fmt.Errorf("forward query to %s: %w", p.cfg.Upstream, err)
+		}
+		reply, err := readTCPMessage(conn)
+		if err != nil {
+			return nil, fmt.Errorf("read upstream reply from %s: %w", p.cfg.Upstream, err)
+		}
+		return reply, nil
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("forward query to %s: %w", p.cfg.Upstream, err)
+	}
+
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("read upstream reply from %s: %w", p.cfg.Upstream, err)
+	}
+
+	return buf[:n], nil
+}
+
+// readTCPMessage reads one DNS message framed with the two-byte length
+// prefix that DNS over TCP uses (RFC 1035 section 4.2.2).
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var prefix [2]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeTCPMessage writes msg with its two-byte length prefix.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	if len(msg) > maxPacketSize {
+		return fmt.Errorf("dns message of %d bytes exceeds %d", len(msg), maxPacketSize)
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg))) // #nosec G115 -- bounded by maxPacketSize above.
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
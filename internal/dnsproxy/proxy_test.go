@@ -0,0 +1,437 @@
+package dnsproxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/denniswebb/ghostwire/internal/dns"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func buildQuery(t *testing.T, name string, qtype dnsmessage.Type) []byte {
+	t.Helper()
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("pack query: %v", err)
+	}
+	return packed
+}
+
+// upstreamReply answers query with 192.0.2.1.
+func upstreamReply(query []byte) ([]byte, bool) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil, false
+	}
+	msg.Header.Response = true
+	msg.Answers = []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 30},
+		Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+	}}
+	reply, err := msg.Pack()
+	return reply, err == nil
+}
+
+// startUpstream runs a fake resolver that answers every query with 192.0.2.1.
+func startUpstream(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply, ok := upstreamReply(buf[:n]); ok {
+				_, _ = conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// startTCPUpstream is startUpstream over TCP.
+func startTCPUpstream(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				query, err := readTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				if reply, ok := upstreamReply(query); ok {
+					_ = writeTCPMessage(conn, reply)
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func newTestProxy(t *testing.T, upstream string) *Proxy {
+	t.Helper()
+	proxy, err := New(Config{
+		Upstream:     upstream,
+		ActiveValue:  "active",
+		PreviewValue: "preview",
+		Entries: []dns.HostsEntry{
+			{IP: "10.0.0.2", Names: []string{"orders", "orders.shop", "orders.shop.svc.cluster.local"}},
+			{IP: "fd00::2", Names: []string{"billing"}},
+		},
+		Timeout: time.Second,
+		Logger:  discardLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return proxy
+}
+
+func firstAnswer(t *testing.T, reply []byte) (dnsmessage.Header, []dnsmessage.Resource) {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(reply); err != nil {
+		t.Fatalf("unpack reply: %v", err)
+	}
+	return msg.Header, msg.Answers
+}
+
+func TestProxyHandle(t *testing.T) {
+	t.Parallel()
+
+	upstream := startUpstream(t)
+
+	tests := []struct {
+		name    string
+		preview bool
+		qname   string
+		qtype   dnsmessage.Type
+		wantA   [4]byte
+		wantAAA bool
+		wantLen int
+	}{
+		{name: "active role forwards", qname: "orders.shop.svc.cluster.local.", qtype: dnsmessage.TypeA, wantA: [4]byte{192, 0, 2, 1}, wantLen: 1},
+		{name: "preview answers fqdn", preview: true, qname: "orders.shop.svc.cluster.local.", qtype: dnsmessage.TypeA, wantA: [4]byte{10, 0, 0, 2}, wantLen: 1},
+		{name: "preview answers case insensitive", preview: true, qname: "ORDERS.shop.", qtype: dnsmessage.TypeA, wantA: [4]byte{10, 0, 0, 2}, wantLen: 1},
+		{name: "preview forwards unknown names", preview: true, qname: "example.com.", qtype: dnsmessage.TypeA, wantA: [4]byte{192, 0, 2, 1}, wantLen: 1},
+		{name: "preview returns empty AAAA for ipv4 service", preview: true, qname: "orders.", qtype: dnsmessage.TypeAAAA, wantLen: 0},
+		{name: "preview answers AAAA for ipv6 service", preview: true, qname: "billing.", qtype: dnsmessage.TypeAAAA, wantAAA: true, wantLen: 1},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			proxy := newTestProxy(t, upstream)
+			role := "active"
+			if tc.preview {
+				role = "preview"
+			}
+			if err := proxy.OnTransition(context.Background(), "", role); err != nil {
+				t.Fatalf("OnTransition returned error: %v", err)
+			}
+
+			reply, err := proxy.handle(context.Background(), "udp", buildQuery(t, tc.qname, tc.qtype))
+			if err != nil {
+				t.Fatalf("handle returned error: %v", err)
+			}
+
+			header, answers := firstAnswer(t, reply)
+			if header.ID != 42 || !header.Response {
+				t.Fatalf("unexpected reply header: %+v", header)
+			}
+			if len(answers) != tc.wantLen {
+				t.Fatalf("expected %d answers, got %d", tc.wantLen, len(answers))
+			}
+			if tc.wantLen == 0 {
+				return
+			}
+			if tc.wantAAA {
+				if _, ok := answers[0].Body.(*dnsmessage.AAAAResource); !ok {
+					t.Fatalf("expected AAAA answer, got %T", answers[0].Body)
+				}
+				return
+			}
+			a, ok := answers[0].Body.(*dnsmessage.AResource)
+			if !ok {
+				t.Fatalf("expected A answer, got %T", answers[0].Body)
+			}
+			if a.A != tc.wantA {
+				t.Fatalf("unexpected A record: got %v want %v", a.A, tc.wantA)
+			}
+		})
+	}
+}
+
+func TestProxyServe(t *testing.T) {
+	t.Parallel()
+
+	proxy := newTestProxy(t, startUpstream(t))
+	_ = proxy.OnTransition(context.Background(), "active", "preview")
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- proxy.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := client.Write(buildQuery(t, "orders.", dnsmessage.TypeA)); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	buf := make([]byte, 512)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	if _, answers := firstAnswer(t, buf[:n]); len(answers) != 1 {
+		t.Fatalf("expected one answer, got %d", len(answers))
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not stop after cancel")
+	}
+}
+
+func TestProxyServeTCP(t *testing.T) {
+	t.Parallel()
+
+	proxy := newTestProxy(t, startTCPUpstream(t))
+	_ = proxy.OnTransition(context.Background(), "active", "preview")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- proxy.ServeTCP(ctx, listener) }()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// One connection carries several queries: a local answer, then one
+	// forwarded to the upstream over TCP.
+	for _, tc := range []struct {
+		name string
+		want [4]byte
+	}{
+		{"orders.", [4]byte{10, 0, 0, 2}},
+		{"example.com.", [4]byte{192, 0, 2, 1}},
+	} {
+		if err := writeTCPMessage(client, buildQuery(t, tc.name, dnsmessage.TypeA)); err != nil {
+			t.Fatalf("write query: %v", err)
+		}
+		reply, err := readTCPMessage(client)
+		if err != nil {
+			t.Fatalf("read reply for %s: %v", tc.name, err)
+		}
+		_, answers := firstAnswer(t, reply)
+		if len(answers) != 1 {
+			t.Fatalf("expected one answer for %s, got %d", tc.name, len(answers))
+		}
+		if a, ok := answers[0].Body.(*dnsmessage.AResource); !ok || a.A != tc.want {
+			t.Fatalf("unexpected answer for %s: %+v", tc.name, answers[0].Body)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ServeTCP returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ServeTCP did not stop after cancel")
+	}
+}
+
+func TestProxyLimitsInFlight(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := New(Config{
+		Upstream:     startUpstream(t),
+		ActiveValue:  "active",
+		PreviewValue: "preview",
+		Entries:      []dns.HostsEntry{{IP: "10.0.0.2", Names: []string{"orders"}}},
+		MaxInFlight:  1,
+		Logger:       discardLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	_ = proxy.OnTransition(context.Background(), "active", "preview")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	packets, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = proxy.ServeTCP(ctx, listener) }()
+	go func() { _ = proxy.Serve(ctx, packets) }()
+
+	// An open TCP connection holds the only slot once it has been served.
+	tcpClient, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial tcp: %v", err)
+	}
+	_ = tcpClient.SetDeadline(time.Now().Add(2 * time.Second))
+	if err := writeTCPMessage(tcpClient, buildQuery(t, "orders.", dnsmessage.TypeA)); err != nil {
+		t.Fatalf("write tcp query: %v", err)
+	}
+	if _, err := readTCPMessage(tcpClient); err != nil {
+		t.Fatalf("read tcp reply: %v", err)
+	}
+
+	udpClient, err := net.Dial("udp", packets.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial udp: %v", err)
+	}
+	defer udpClient.Close()
+	if _, err := udpClient.Write(buildQuery(t, "orders.", dnsmessage.TypeA)); err != nil {
+		t.Fatalf("write udp query: %v", err)
+	}
+	buf := make([]byte, 512)
+	_ = udpClient.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := udpClient.Read(buf); err == nil {
+		t.Fatal("expected udp query to wait while the tcp connection holds the slot")
+	}
+
+	_ = tcpClient.Close()
+	_ = udpClient.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := udpClient.Read(buf)
+	if err != nil {
+		t.Fatalf("expected udp reply once the slot was released: %v", err)
+	}
+	if _, answers := firstAnswer(t, buf[:n]); len(answers) != 1 {
+		t.Fatalf("expected one answer, got %d", len(answers))
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(Config{ActiveValue: "active", PreviewValue: "preview"}); err == nil {
+		t.Fatal("expected error without upstream")
+	}
+	if _, err := New(Config{Upstream: "127.0.0.1:53", ActiveValue: "active", PreviewValue: "preview", Entries: []dns.HostsEntry{{IP: "bogus", Names: []string{"x"}}}}); err == nil {
+		t.Fatal("expected error for invalid preview ip")
+	}
+}
+
+func TestUpstreamFromResolvConf(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	content := "search shop.svc.cluster.local\nnameserver 127.0.0.1\nnameserver 10.96.0.10\noptions ndots:5\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write resolv.conf: %v", err)
+	}
+
+	got, err := UpstreamFromResolvConf(path)
+	if err != nil {
+		t.Fatalf("UpstreamFromResolvConf returned error: %v", err)
+	}
+	if got != "10.96.0.10:53" {
+		t.Fatalf("unexpected upstream: %q", got)
+	}
+
+	loopbackOnly := filepath.Join(dir, "loopback.conf")
+	if err := os.WriteFile(loopbackOnly, []byte("nameserver 127.0.0.1\n"), 0o600); err != nil {
+		t.Fatalf("write resolv.conf: %v", err)
+	}
+	if _, err := UpstreamFromResolvConf(loopbackOnly); err == nil {
+		t.Fatal("expected error when only loopback nameservers are present")
+	}
+}
+
+func TestLoadEntries(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnat.map")
+	content := "# DNAT mappings generated by ghostwire-init\n# Format: service:port/protocol active_ip -> preview_ip\norders:80/TCP 10.0.0.1 -> 10.0.0.2\norders:443/TCP 10.0.0.1 -> 10.0.0.2 namespace=shop preview=orders-preview port_name=https\ngarbage line\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write dnat map: %v", err)
+	}
+
+	entries, err := LoadEntries(path, "shop", "")
+	if err != nil {
+		t.Fatalf("LoadEntries returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].IP != "10.0.0.2" || len(entries[0].Names) != 3 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	missing, err := LoadEntries(filepath.Join(dir, "missing.map"), "shop", "")
+	if err != nil || missing != nil {
+		t.Fatalf("expected nil entries for missing map, got %+v, %v", missing, err)
+	}
+}
@@ -0,0 +1,57 @@
+package dnsproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/dns"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// UpstreamFromResolvConf returns the first non-loopback nameserver listed in the
+// resolv.conf at path, formatted as host:53. Loopback entries are skipped so a
+// pod pointed at the proxy itself does not forward queries back into it.
+func UpstreamFromResolvConf(path string) (string, error) {
+	// #nosec G304 -- resolv.conf path comes from operator configuration.
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open resolv.conf %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		ip := net.ParseIP(fields[1])
+		if ip == nil || ip.IsLoopback() {
+			continue
+		}
+		return net.JoinHostPort(ip.String(), "53"), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("scan resolv.conf %s: %w", path, err)
+	}
+
+	return "", fmt.Errorf("no non-loopback nameserver found in %s", path)
+}
+
+// LoadEntries reads the DNAT map written by init and renders the hostnames the
+// proxy should answer for while preview routing is active. A missing map yields
+// no entries so the proxy degrades to a plain forwarder.
+func LoadEntries(dnatMapPath string, namespace string, suffix string) ([]dns.HostsEntry, error) {
+	mappings, err := iptables.LoadDNATMap(dnatMapPath)
+	if err != nil {
+		return nil, err
+	}
+	if mappings == nil {
+		return nil, nil
+	}
+
+	return dns.BuildHostsEntries(mappings, namespace, suffix), nil
+}
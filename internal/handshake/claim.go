@@ -0,0 +1,83 @@
+package handshake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrClaimed reports a claim file held by another ghostwire instance.
+var ErrClaimed = errors.New("claimed by another ghostwire instance")
+
+var errLocked = errors.New("claim file locked")
+
+// ClaimInfo is the content of a claim file: who manages the rules of the
+// pod's network namespace.
+type ClaimInfo struct {
+	Chain   string    `json:"chain"`
+	Hooks   []string  `json:"hooks,omitempty"`
+	Owner   string    `json:"owner"`
+	PID     int       `json:"pid"`
+	Claimed time.Time `json:"claimed"`
+}
+
+// Claim is a held claim file. The lock is an flock on the open file, so the
+// kernel drops it when the holder exits, however it exits, and a restarted
+// container never finds a stale claim.
+type Claim struct {
+	file *os.File
+}
+
+// Acquire takes the claim file at path and records info in it. When another
+// process holds it the error wraps ErrClaimed and names the holder. Outside
+// Linux the file is written but not locked.
+func Acquire(path string, info ClaimInfo) (*Claim, error) {
+	// #nosec G302 G304 -- the claim path comes from operator configuration and
+	// is read by the other containers sharing the volume.
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open claim file %s: %w", path, err)
+	}
+	if err := lockFile(file); err != nil {
+		holder := readClaim(file)
+		_ = file.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("%s %w (%s)", path, ErrClaimed, holder)
+		}
+		return nil, fmt.Errorf("lock claim file %s: %w", path, err)
+	}
+
+	encoded, err := json.Marshal(info)
+	if err == nil {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		_, err = file.WriteAt(append(encoded, '\n'), 0)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("write claim file %s: %w", path, err)
+	}
+	return &Claim{file: file}, nil
+}
+
+// Release gives the claim up. The file stays behind for the next holder.
+func (c *Claim) Release() error {
+	if c == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// readClaim describes the holder recorded in file, tolerating a holder that
+// is still writing it.
+func readClaim(file *os.File) string {
+	var info ClaimInfo
+	raw, err := os.ReadFile(file.Name())
+	if err != nil || json.Unmarshal(raw, &info) != nil || info.Owner == "" {
+		return "holder unknown"
+	}
+	return fmt.Sprintf("%s pid %d, chain %s, since %s", info.Owner, info.PID, info.Chain, info.Claimed.Format(time.RFC3339))
+}
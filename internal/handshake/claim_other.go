@@ -0,0 +1,10 @@
+//go:build !linux
+
+package handshake
+
+import "os"
+
+// lockFile is a no-op outside Linux, where ghostwire never manages rules.
+func lockFile(*os.File) error {
+	return nil
+}
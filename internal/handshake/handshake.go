@@ -0,0 +1,109 @@
+// Package handshake coordinates init and the watcher through a marker file on
+// the shared volume. Init writes the marker once its rules are in place, and
+// the watcher waits for it before verifying the chain, closing the window in
+// which a watcher started alongside a slow init would verify a half-built
+// chain.
+package handshake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Marker is the content of the ready marker.
+type Marker struct {
+	// Generation identifies one init run, so the watcher can tell which run
+	// produced the rules it is about to manage.
+	Generation string    `json:"generation"`
+	Written    time.Time `json:"written"`
+}
+
+// NewGeneration returns a random generation ID.
+func NewGeneration() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate handshake generation: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Clear removes a marker left by a previous init run. A missing marker is not
+// an error.
+func Clear(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove ready marker %s: %w", path, err)
+	}
+	return nil
+}
+
+// Write atomically records marker at path, so a watcher never reads a
+// partially written file.
+func Write(path string, marker Marker) error {
+	encoded, err := json.Marshal(marker)
+	if err != nil {
+		return fmt.Errorf("encode ready marker: %w", err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	// #nosec G306 -- the marker is read by the watcher container sharing the volume.
+	if err := os.WriteFile(tmp, append(encoded, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write ready marker %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("install ready marker %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read returns the marker at path. The error wraps fs.ErrNotExist when init
+// has not written it yet.
+func Read(path string) (Marker, error) {
+	var marker Marker
+
+	// #nosec G304 -- marker path comes from operator configuration.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return marker, fmt.Errorf("read ready marker %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, &marker); err != nil {
+		return marker, fmt.Errorf("decode ready marker %s: %w", path, err)
+	}
+	if marker.Generation == "" {
+		return marker, fmt.Errorf("ready marker %s has no generation", path)
+	}
+	return marker, nil
+}
+
+// Wait polls for the marker every interval until it appears, timeout elapses
+// or ctx is cancelled.
+func Wait(ctx context.Context, path string, timeout, interval time.Duration) (Marker, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		marker, err := Read(path)
+		if err == nil {
+			return marker, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return Marker{}, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Marker{}, fmt.Errorf("init ready marker %s not written within %s: %w", path, timeout, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handshake
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReadClear(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ready")
+	if _, err := Read(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not-exist error before write, got %v", err)
+	}
+
+	want := Marker{Generation: "abc123", Written: time.Unix(1700000000, 0).UTC()}
+	if err := Write(path, want); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if got.Generation != want.Generation || !got.Written.Equal(want.Written) {
+		t.Fatalf("unexpected marker: %+v", got)
+	}
+
+	if err := Clear(path); err != nil {
+		t.Fatalf("Clear returned error: %v", err)
+	}
+	if err := Clear(path); err != nil {
+		t.Fatalf("Clear of missing marker returned error: %v", err)
+	}
+}
+
+func TestReadRejectsEmptyGeneration(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ready")
+	if err := os.WriteFile(path, []byte(`{"generation":""}`), 0o600); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Fatalf("expected error for marker without generation")
+	}
+}
+
+func TestWait(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = Write(path, Marker{Generation: "late"})
+	}()
+
+	marker, err := Wait(context.Background(), path, time.Second, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if marker.Generation != "late" {
+		t.Fatalf("unexpected generation %q", marker.Generation)
+	}
+
+	if _, err := Wait(context.Background(), filepath.Join(t.TempDir(), "never"), 20*time.Millisecond, 5*time.Millisecond); err == nil {
+		t.Fatalf("expected timeout error")
+	}
+}
+
+func TestNewGeneration(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewGeneration()
+	if err != nil {
+		t.Fatalf("NewGeneration returned error: %v", err)
+	}
+	b, _ := NewGeneration()
+	if len(a) != 16 || a == b {
+		t.Fatalf("unexpected generations %q, %q", a, b)
+	}
+}
+
+func TestAcquireRefusesSecondHolder(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ghostwire.claim")
+	claim, err := Acquire(path, ClaimInfo{Chain: "CANARY_DNAT", Owner: "watcher", PID: 42, Claimed: time.Unix(1700000000, 0).UTC()})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+
+	_, err = Acquire(path, ClaimInfo{Chain: "OTHER_DNAT", Owner: "watcher", PID: 43})
+	if !errors.Is(err, ErrClaimed) {
+		t.Fatalf("expected ErrClaimed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "watcher pid 42, chain CANARY_DNAT") {
+		t.Fatalf("expected the holder in the error, got %v", err)
+	}
+
+	if err := claim.Release(); err != nil {
+		t.Fatalf("Release returned error: %v", err)
+	}
+	next, err := Acquire(path, ClaimInfo{Chain: "OTHER_DNAT", Owner: "init", PID: 43})
+	if err != nil {
+		t.Fatalf("Acquire after release returned error: %v", err)
+	}
+	defer func() { _ = next.Release() }()
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"sync/atomic"
 )
 
@@ -26,7 +28,9 @@ func ResetIPv6ChainFailuresForTest() {
 	ipv6ChainFailureCount.Store(0)
 }
 
-// EnsureChain verifies the DNAT chain exists and is empty for both IPv4 and IPv6.
+// EnsureChain verifies the DNAT chain exists for both IPv4 and IPv6 and holds
+// none of ghostwire's rules. Rules other tooling tagged with its own comment
+// stay in place.
 func EnsureChain(ctx context.Context, executor Executor, table string, chain string, ipv6 bool, logger *slog.Logger) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -38,9 +42,9 @@ func EnsureChain(ctx context.Context, executor Executor, table string, chain str
 	}
 
 	if exists {
-		logger.Info("flushing existing chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
-		if err := executor.Run(ctx, ipv4Binary, "-w", iptablesWaitSeconds, "-t", table, "-F", chain); err != nil {
-			return fmt.Errorf("flush chain %s: %w", chain, err)
+		logger.Info("clearing existing chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
+		if err := clearOwnedRules(ctx, executor, ipv4Binary, table, chain, logger); err != nil {
+			return fmt.Errorf("clear chain %s: %w", chain, err)
 		}
 	} else {
 		logger.Info("creating chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
@@ -72,10 +76,92 @@ func ensureIPv6Chain(ctx context.Context, executor Executor, table string, chain
 	}
 
 	if exists {
-		logger.Info("flushing existing chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", true))
-		return executor.Run(ctx, ipv6Binary, "-w", iptablesWaitSeconds, "-t", table, "-F", chain)
+		logger.Info("clearing existing chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", true))
+		return clearOwnedRules(ctx, executor, ipv6Binary, table, chain, logger)
 	}
 
 	logger.Info("creating chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", true))
 	return executor.Run(ctx, ipv6Binary, "-w", iptablesWaitSeconds, "-t", table, "-N", chain)
 }
+
+// clearOwnedRules deletes the rules of chain that ghostwire owns, by rule
+// number and from the bottom up so the numbers left to delete stay valid.
+// Executors that cannot list rules leave the chain as it is.
+func clearOwnedRules(ctx context.Context, executor Executor, binary string, table string, chain string, logger *slog.Logger) error {
+	outputExecutor, ok := executor.(OutputExecutor)
+	if !ok {
+		logger.Warn("executor cannot list rules; existing chain left as is", slog.String("table", table), slog.String("chain", chain))
+		return nil
+	}
+	output, err := outputExecutor.Output(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-S", chain)
+	if err != nil {
+		return fmt.Errorf("list %s rules: %w", chain, err)
+	}
+
+	prefix := "-A " + chain + " "
+	var owned []int
+	number := 0
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		number++
+		if ownedRule(line) {
+			owned = append(owned, number)
+		}
+	}
+	for i := len(owned) - 1; i >= 0; i-- {
+		if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-D", chain, strconv.Itoa(owned[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChain flushes and deletes chain, doing nothing when it does not
+// exist. Jumps to it must be gone first: iptables refuses to delete a
+// referenced chain. IPv6 failures are logged, matching EnsureChain.
+func DeleteChain(ctx context.Context, executor Executor, table string, chain string, ipv6 bool, logger *slog.Logger) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	exists, err := executor.ChainExists(ctx, table, chain)
+	if err != nil {
+		return fmt.Errorf("determine chain existence: %w", err)
+	}
+	if exists {
+		if err := deleteChain(ctx, executor, ipv4Binary, table, chain); err != nil {
+			return err
+		}
+		logger.Info("deleted chain", slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
+	}
+
+	if !ipv6 {
+		return nil
+	}
+
+	exists, err = executor.ChainExists6(ctx, table, chain)
+	if err == nil && exists {
+		err = deleteChain(ctx, executor, ipv6Binary, table, chain)
+	}
+	if err != nil {
+		ipv6ChainFailureCount.Add(1)
+		logger.Warn("ip6tables chain deletion failed", slog.String("table", table), slog.String("chain", chain), slog.Any("error", err))
+	}
+	return nil
+}
+
+func deleteChain(ctx context.Context, executor Executor, binary string, table string, chain string) error {
+	if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-F", chain); err != nil {
+		return fmt.Errorf("flush chain %s: %w", chain, err)
+	}
+	if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-X", chain); err != nil {
+		return fmt.Errorf("delete chain %s: %w", chain, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// builtinTargets are jump targets that are not user chains.
+var builtinTargets = map[string]bool{
+	"":           true,
+	"ACCEPT":     true,
+	"DROP":       true,
+	"RETURN":     true,
+	"REJECT":     true,
+	"DNAT":       true,
+	"SNAT":       true,
+	"MASQUERADE": true,
+	"REDIRECT":   true,
+	"MARK":       true,
+	"CONNMARK":   true,
+	"CT":         true,
+	"LOG":        true,
+	"NFLOG":      true,
+	"TEE":        true,
+}
+
+// ForeignChains returns the user chains other than chain, and the reverse and
+// staging chains derived from it, that hooks jump to and that hold DNAT rules. Nothing else in a pod's network namespace DNATs
+// to ClusterIPs, so such a chain is almost always another ghostwire instance
+// (a second injected sidecar) managing the same namespace, which would fight
+// over the jump position and flush rules out from under this one.
+func ForeignChains(ctx context.Context, executor Executor, table string, hooks []string, chain string) ([]string, error) {
+	outputExecutor, ok := executor.(OutputExecutor)
+	if !ok {
+		return nil, errors.New("executor cannot read rule listings")
+	}
+
+	var foreign []string
+	seen := map[string]bool{
+		chain:                   true,
+		ReverseChainName(chain): true,
+		StagingChainName(chain): true,
+	}
+	for _, hook := range hooks {
+		targets, err := ListHookRules(ctx, executor, ipv4Binary, table, hook)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			if seen[target] || builtinTargets[target] {
+				continue
+			}
+			seen[target] = true
+
+			output, err := outputExecutor.Output(ctx, ipv4Binary, "-w", iptablesWaitSeconds, "-t", table, "-S", target)
+			if err != nil {
+				return nil, fmt.Errorf("list %s rules: %w", target, err)
+			}
+			if strings.Contains(output, " -j DNAT ") {
+				foreign = append(foreign, target)
+			}
+		}
+	}
+	slices.Sort(foreign)
+	return foreign, nil
+}
@@ -0,0 +1,75 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+const (
+	conntrackTable = "raw"
+	nfctBinary     = "nfct"
+)
+
+// CreateCTTimeoutPolicy registers a UDP conntrack timeout policy with nfct so
+// CT rules can reference it. An existing policy with the same name is reused.
+func CreateCTTimeoutPolicy(ctx context.Context, executor Executor, policy string, seconds int, logger *slog.Logger) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	timeout := strconv.Itoa(seconds)
+	logger.Info("creating conntrack timeout policy", slog.String("policy", policy), slog.Int("udp_timeout_seconds", seconds))
+	err := executor.Run(ctx, nfctBinary, "add", "timeout", policy, "inet", "udp", "unreplied", timeout, "replied", timeout)
+	if err != nil {
+		if errors.Is(err, ErrRuleExists) {
+			logger.Info("conntrack timeout policy already present", slog.String("policy", policy))
+			return nil
+		}
+		return fmt.Errorf("create conntrack timeout policy %s: %w", policy, err)
+	}
+	return nil
+}
+
+// AddCTTimeoutRules attaches the named timeout policy to UDP flows towards the
+// active ClusterIPs. The rules live in a raw-table chain named like the DNAT
+// chain; the watcher jumps to it together with the DNAT jump so preview flows
+// start with short timeouts and expire promptly after switching back.
+func AddCTTimeoutRules(ctx context.Context, executor Executor, chain string, policy string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
+	if err := EnsureChain(ctx, executor, conntrackTable, chain, ipv6, logger); err != nil {
+		return 0, fmt.Errorf("prepare conntrack chain %s: %w", chain, err)
+	}
+
+	added := 0
+	for _, mapping := range mappings {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+		if mapping.Protocol != corev1.ProtocolUDP || mapping.ActiveClusterIP == "" || mapping.Port == 0 {
+			continue
+		}
+
+		bin := ipv4Binary
+		if isIPv6(mapping.ActiveClusterIP) {
+			if !ipv6 {
+				continue
+			}
+			bin = ipv6Binary
+		}
+
+		args := withOwner("-w", iptablesWaitSeconds, "-t", conntrackTable, "-A", chain, "-d", mapping.ActiveClusterIP, "-p", "udp", "--dport", strconv.Itoa(int(mapping.Port)), "-j", "CT", "--timeout", policy)
+		logger.Info("adding conntrack timeout rule", slog.String("service", mapping.ServiceName), slog.Int("port", int(mapping.Port)), slog.String("policy", policy))
+		if err := executor.Run(ctx, bin, args...); err != nil {
+			return added, fmt.Errorf("add conntrack timeout rule for %s: %w", mapping.ServiceName, WithService(err, mapping.ServiceName))
+		}
+		added++
+	}
+
+	return added, nil
+}
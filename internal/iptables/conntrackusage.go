@@ -0,0 +1,139 @@
+package iptables
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// ConntrackUsage is the fill level of the conntrack table seen from the pod's
+// network namespace.
+type ConntrackUsage struct {
+	// Count and Max come from /proc/sys/net/netfilter/nf_conntrack_count and
+	// nf_conntrack_max.
+	Count int
+	Max   int
+	// DNATEntries counts entries whose original destination is an active
+	// ClusterIP of the mappings and whose reply comes from its preview side,
+	// i.e. connections ghostwire redirected. It is only read when
+	// DNATEntriesKnown is set, since /proc/net/nf_conntrack needs
+	// CONFIG_NF_CONNTRACK_PROCFS.
+	DNATEntries      int
+	DNATEntriesKnown bool
+}
+
+// Utilization returns Count as a fraction of Max, or zero without a limit.
+func (u ConntrackUsage) Utilization() float64 {
+	if u.Max <= 0 {
+		return 0
+	}
+	return float64(u.Count) / float64(u.Max)
+}
+
+// ReadConntrackUsage reads the conntrack table counters under procRoot,
+// normally /proc, and counts the entries redirected for mappings.
+func ReadConntrackUsage(procRoot string, mappings []discovery.ServiceMapping) (ConntrackUsage, error) {
+	var usage ConntrackUsage
+	var err error
+	if usage.Count, err = readProcInt(filepath.Join(procRoot, "sys/net/netfilter/nf_conntrack_count")); err != nil {
+		return ConntrackUsage{}, err
+	}
+	if usage.Max, err = readProcInt(filepath.Join(procRoot, "sys/net/netfilter/nf_conntrack_max")); err != nil {
+		return ConntrackUsage{}, err
+	}
+
+	preview := make(map[string]map[string]bool)
+	for _, mapping := range mappings {
+		active := canonicalIP(mapping.ActiveClusterIP)
+		if active == "" {
+			continue
+		}
+		targets := preview[active]
+		if targets == nil {
+			targets = make(map[string]bool)
+			preview[active] = targets
+		}
+		if ip := canonicalIP(mapping.PreviewClusterIP); ip != "" {
+			targets[ip] = true
+		}
+		for _, endpoint := range mapping.PreviewEndpoints {
+			if ip := canonicalIP(endpoint.IP); ip != "" {
+				targets[ip] = true
+			}
+		}
+	}
+
+	// #nosec G304 -- procRoot is /proc outside tests.
+	file, err := os.Open(filepath.Join(procRoot, "net/nf_conntrack"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return ConntrackUsage{}, fmt.Errorf("open conntrack table: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		dst, replySrc := conntrackTuple(scanner.Text())
+		if targets, ok := preview[canonicalIP(dst)]; ok && targets[canonicalIP(replySrc)] {
+			usage.DNATEntries++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ConntrackUsage{}, fmt.Errorf("read conntrack table: %w", err)
+	}
+	usage.DNATEntriesKnown = true
+	return usage, nil
+}
+
+// conntrackTuple returns the original destination and the reply source of a
+// /proc/net/nf_conntrack line: the first dst= and the second src= field.
+func conntrackTuple(line string) (string, string) {
+	var dst, replySrc string
+	sources := 0
+	for _, field := range strings.Fields(line) {
+		switch {
+		case strings.HasPrefix(field, "src="):
+			sources++
+			if sources == 2 {
+				replySrc = strings.TrimPrefix(field, "src=")
+				return dst, replySrc
+			}
+		case dst == "" && strings.HasPrefix(field, "dst="):
+			dst = strings.TrimPrefix(field, "dst=")
+		}
+	}
+	return dst, replySrc
+}
+
+// canonicalIP renders ip in its shortest form, since the conntrack table
+// prints IPv6 addresses in full, or returns "" for anything else.
+func canonicalIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func readProcInt(path string) (int, error) {
+	// #nosec G304 -- path is under /proc outside tests.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("read %s: %w", path, err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return value, nil
+}
@@ -1,6 +1,8 @@
 package iptables
 
+import "github.com/denniswebb/ghostwire/internal/config"
+
 const (
-	defaultChainName    = "CANARY_DNAT"
+	defaultChainName    = config.DefaultNATChain
 	iptablesWaitSeconds = "5"
 )
@@ -0,0 +1,130 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Debug log scopes select which packets the debug rule records.
+const (
+	// DebugLogScopeChain logs every packet entering the DNAT chain.
+	DebugLogScopeChain = "chain"
+	// DebugLogScopeUnmatched logs packets that fall through the chain without
+	// being excluded or redirected.
+	DebugLogScopeUnmatched = "unmatched"
+	// DebugLogScopeBoth installs both rules.
+	DebugLogScopeBoth = "both"
+
+	defaultDebugLogRate   = "10/second"
+	defaultDebugLogPrefix = "ghostwire"
+	// maxLogPrefixLength is the kernel limit for LOG prefixes, minus the
+	// terminating NUL.
+	maxLogPrefixLength = 29
+)
+
+// DebugLogConfig describes the optional packet logging rules used to confirm
+// which packets traverse the DNAT chain.
+type DebugLogConfig struct {
+	// Target is "LOG" or "NFLOG". Empty disables debug logging.
+	Target string
+	// Scope is one of DebugLogScopeChain, DebugLogScopeUnmatched, or
+	// DebugLogScopeBoth. Empty defaults to DebugLogScopeChain.
+	Scope string
+	// Prefix identifies ghostwire's entries in the kernel log.
+	Prefix string
+	// Rate is passed to `-m limit --limit`; empty defaults to 10/second.
+	Rate string
+	// NFLOGGroup selects the netlink group for the NFLOG target.
+	NFLOGGroup int
+}
+
+// Enabled reports whether any debug log rule should be installed.
+func (c DebugLogConfig) Enabled() bool {
+	return strings.TrimSpace(c.Target) != ""
+}
+
+// Validate normalises the config and rejects unsupported values.
+func (c DebugLogConfig) Validate() (DebugLogConfig, error) {
+	c.Target = strings.ToUpper(strings.TrimSpace(c.Target))
+	switch c.Target {
+	case "", "LOG", "NFLOG":
+	default:
+		return c, fmt.Errorf("debug log target %q must be LOG or NFLOG", c.Target)
+	}
+
+	c.Scope = strings.ToLower(strings.TrimSpace(c.Scope))
+	switch c.Scope {
+	case "":
+		c.Scope = DebugLogScopeChain
+	case DebugLogScopeChain, DebugLogScopeUnmatched, DebugLogScopeBoth:
+	default:
+		return c, fmt.Errorf("debug log scope %q must be %s, %s, or %s", c.Scope, DebugLogScopeChain, DebugLogScopeUnmatched, DebugLogScopeBoth)
+	}
+
+	c.Prefix = strings.TrimSpace(c.Prefix)
+	if c.Prefix == "" {
+		c.Prefix = defaultDebugLogPrefix
+	}
+	// Room for the longest suffix appended by debugLogPrefix.
+	if len(c.Prefix)+len(" miss: ") > maxLogPrefixLength {
+		return c, fmt.Errorf("debug log prefix %q is too long (max %d characters)", c.Prefix, maxLogPrefixLength-len(" miss: "))
+	}
+
+	c.Rate = strings.TrimSpace(c.Rate)
+	if c.Rate == "" {
+		c.Rate = defaultDebugLogRate
+	}
+	if c.NFLOGGroup < 0 || c.NFLOGGroup > 65535 {
+		return c, fmt.Errorf("nflog group %d out of range", c.NFLOGGroup)
+	}
+	return c, nil
+}
+
+func (c DebugLogConfig) includes(scope string) bool {
+	return c.Scope == scope || c.Scope == DebugLogScopeBoth
+}
+
+func debugLogPrefix(prefix string, scope string) string {
+	if scope == DebugLogScopeUnmatched {
+		return prefix + " miss: "
+	}
+	return prefix + " hit: "
+}
+
+// AddDebugLogRule appends a rate-limited LOG or NFLOG rule to chain when cfg
+// includes scope. Call it with DebugLogScopeChain before any other rule and
+// with DebugLogScopeUnmatched after the last one. It reports whether a rule
+// was added.
+func AddDebugLogRule(ctx context.Context, executor Executor, table string, chain string, cfg DebugLogConfig, scope string, ipv6 bool, logger *slog.Logger) (bool, error) {
+	if !cfg.Enabled() || !cfg.includes(scope) {
+		return false, nil
+	}
+
+	prefix := debugLogPrefix(cfg.Prefix, scope)
+	rule := []string{"-m", "limit", "--limit", cfg.Rate, "-j", cfg.Target}
+	if cfg.Target == "NFLOG" {
+		rule = append(rule, "--nflog-prefix", prefix, "--nflog-group", fmt.Sprintf("%d", cfg.NFLOGGroup))
+	} else {
+		rule = append(rule, "--log-prefix", prefix)
+	}
+
+	binaries := []string{ipv4Binary}
+	if ipv6 {
+		binaries = append(binaries, ipv6Binary)
+	}
+
+	for _, bin := range binaries {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		logger.Info("adding debug log rule", slog.String("binary", bin), slog.String("chain", chain), slog.String("target", cfg.Target), slog.String("scope", scope), slog.String("rate", cfg.Rate))
+		args := withOwner(append([]string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain}, rule...)...)
+		if err := executor.Run(ctx, bin, args...); err != nil {
+			return false, fmt.Errorf("add %s debug log rule via %s: %w", scope, bin, err)
+		}
+	}
+
+	return true, nil
+}
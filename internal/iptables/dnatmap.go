@@ -1,21 +1,50 @@
 package iptables
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/config"
 	"github.com/denniswebb/ghostwire/internal/discovery"
 )
 
 // WriteDNATMap records the resolved DNAT mappings to an audit file.
-func WriteDNATMap(path string, mappings []discovery.ServiceMapping, logger *slog.Logger) (err error) {
+func WriteDNATMap(path string, mappings []discovery.ServiceMapping, logger *slog.Logger) error {
+	return writeDNATMap(path, mappings, nil, logger)
+}
+
+// WriteSignedDNATMap records the mappings like WriteDNATMap and writes an
+// HMAC-SHA256 of the file, keyed with key, next to it (see SignaturePath).
+func WriteSignedDNATMap(path string, mappings []discovery.ServiceMapping, key []byte, logger *slog.Logger) error {
+	if len(key) == 0 {
+		return errors.New("dnat map signing key is empty")
+	}
+	return writeDNATMap(path, mappings, key, logger)
+}
+
+func writeDNATMap(path string, mappings []discovery.ServiceMapping, key []byte, logger *slog.Logger) (err error) {
 	if err := validateDNATMapPath(path); err != nil {
 		return err
 	}
 
+	var content bytes.Buffer
+	if err := RenderDNATMap(&content, mappings, time.Now()); err != nil {
+		return err
+	}
+
 	// #nosec G302,G304 -- DNAT map lives on an operator-configured shared volume; validateDNATMapPath ensures safe path traversal.
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 	if err != nil {
@@ -27,23 +56,266 @@ func WriteDNATMap(path string, mappings []discovery.ServiceMapping, logger *slog
 		}
 	}()
 
-	if _, err := fmt.Fprintln(file, "# DNAT mappings generated by ghostwire-init"); err != nil {
-		return fmt.Errorf("write dnat map header: %w", err)
+	if _, err := file.Write(content.Bytes()); err != nil {
+		return fmt.Errorf("write dnat map %s: %w", path, err)
+	}
+
+	if len(key) > 0 {
+		if err := writeDNATMapSignature(path, content.Bytes(), key); err != nil {
+			return err
+		}
 	}
-	if _, err := fmt.Fprintln(file, "# Format: service:port/protocol active_ip -> preview_ip"); err != nil {
-		return fmt.Errorf("write dnat map header: %w", err)
+
+	logger.Info("wrote dnat map", slog.String("path", path), slog.Int("mappings", len(mappings)), slog.Bool("signed", len(key) > 0))
+	return nil
+}
+
+// DNATMapVersion identifies the current dnat.map layout. Version 2 appends
+// key=value provenance fields (namespace, preview service, port name) to each
+// entry and records the generation time in the header; entries still start
+// with the version 1 fields, so older line parsers keep working.
+const DNATMapVersion = 2
+
+// RenderDNATMap writes the DNAT map format to w. It backs WriteDNATMap and lets
+// the controller publish the same content through ConfigMaps.
+func RenderDNATMap(w io.Writer, mappings []discovery.ServiceMapping, generated time.Time) error {
+	header := []string{
+		"# DNAT mappings generated by ghostwire-init",
+		fmt.Sprintf("# Version: %d", DNATMapVersion),
+		"# Generated: " + generated.UTC().Format(time.RFC3339),
+		"# Format: service:port/protocol active_ip -> preview_ip[:preview_port] [namespace=ns] [preview=service] [port_name=name]",
+	}
+	for _, line := range header {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("write dnat map header: %w", err)
+		}
 	}
 
 	for _, mapping := range mappings {
-		if _, err := fmt.Fprintf(file, "%s:%d/%s %s -> %s\n", mapping.ServiceName, mapping.Port, mapping.Protocol, mapping.ActiveClusterIP, mapping.PreviewClusterIP); err != nil {
+		preview := mapping.PreviewClusterIP
+		if mapping.TargetPort() != mapping.Port {
+			preview = net.JoinHostPort(mapping.PreviewClusterIP, strconv.Itoa(int(mapping.TargetPort())))
+		}
+		entry := fmt.Sprintf("%s:%d/%s %s -> %s", mapping.ServiceName, mapping.Port, mapping.Protocol, mapping.ActiveClusterIP, preview)
+		if mapping.Namespace != "" {
+			entry += " namespace=" + mapping.Namespace
+		}
+		if mapping.PreviewServiceName != "" {
+			entry += " preview=" + mapping.PreviewServiceName
+		}
+		if mapping.PortName != "" {
+			entry += " port_name=" + mapping.PortName
+		}
+		if _, err := fmt.Fprintln(w, entry); err != nil {
 			return fmt.Errorf("write dnat map entry for %s: %w", mapping.ServiceName, err)
 		}
 	}
 
-	logger.Info("wrote dnat map", slog.String("path", path), slog.Int("mappings", len(mappings)))
 	return nil
 }
 
+// LoadDNATMap reads the mappings recorded at path. A missing file yields no
+// mappings, since the map only exists once init has run.
+func LoadDNATMap(path string) ([]discovery.ServiceMapping, error) {
+	return loadDNATMap(path, false, nil)
+}
+
+// LoadDNATMapStrict behaves like LoadDNATMap but fails on the first malformed
+// entry with a *config.ParseError instead of skipping it.
+func LoadDNATMapStrict(path string) ([]discovery.ServiceMapping, error) {
+	return loadDNATMap(path, true, nil)
+}
+
+// LoadSignedDNATMap reads the mappings at path only after checking the file
+// against the signature written by WriteSignedDNATMap. A missing or
+// mismatched signature fails with ErrDNATMapSignature; a missing map still
+// yields no mappings.
+func LoadSignedDNATMap(path string, key []byte, strict bool) ([]discovery.ServiceMapping, error) {
+	if len(key) == 0 {
+		return nil, errors.New("dnat map signing key is empty")
+	}
+	return loadDNATMap(path, strict, key)
+}
+
+func loadDNATMap(path string, strict bool, key []byte) ([]discovery.ServiceMapping, error) {
+	if err := validateDNATMapPath(path); err != nil {
+		return nil, err
+	}
+
+	// The whole file is read up front so the signature covers exactly the
+	// bytes that are parsed.
+	// #nosec G304 -- DNAT map lives on an operator-configured shared volume; validateDNATMapPath ensures safe path traversal.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open dnat map %s: %w", path, err)
+	}
+
+	if len(key) > 0 {
+		if err := verifyDNATMapSignature(path, content, key); err != nil {
+			return nil, err
+		}
+	}
+
+	mappings, err := parseDNATMap(bytes.NewReader(content), path, strict)
+	if err != nil {
+		return nil, fmt.Errorf("read dnat map %s: %w", path, err)
+	}
+	return mappings, nil
+}
+
+// ParseDNATMap reads mappings written by RenderDNATMap. Version 1 entries
+// lack the key=value fields, and unknown keys are ignored so newer writers
+// stay readable. Malformed entries are skipped.
+func ParseDNATMap(r io.Reader) ([]discovery.ServiceMapping, error) {
+	return parseDNATMap(r, "dnat.map", false)
+}
+
+// ParseDNATMapStrict behaves like ParseDNATMap but rejects malformed entries,
+// invalid IPs, ports and protocols, and trailing fields that are not
+// key=value pairs. The error is a *config.ParseError naming source and the
+// offending token's line and column.
+func ParseDNATMapStrict(r io.Reader, source string) ([]discovery.ServiceMapping, error) {
+	return parseDNATMap(r, source, true)
+}
+
+func parseDNATMap(r io.Reader, source string, strict bool) ([]discovery.ServiceMapping, error) {
+	var mappings []discovery.ServiceMapping
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		mapping, err := parseDNATMapEntry(line, strict)
+		if err != nil {
+			if strict {
+				return nil, &config.ParseError{Source: source, Line: lineNo, Column: err.column, Token: err.token, Err: err.err}
+			}
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan dnat map: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// entryError points at the token of a dnat.map line that failed to parse.
+type entryError struct {
+	column int
+	token  string
+	err    error
+}
+
+// entryField is a whitespace-separated token and its 1-based column.
+type entryField struct {
+	text   string
+	column int
+}
+
+func splitEntryFields(line string) []entryField {
+	var fields []entryField
+	start := -1
+	for i, r := range line {
+		if r == ' ' || r == '\t' {
+			if start >= 0 {
+				fields = append(fields, entryField{text: line[start:i], column: start + 1})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, entryField{text: line[start:], column: start + 1})
+	}
+	return fields
+}
+
+func parseDNATMapEntry(line string, strict bool) (discovery.ServiceMapping, *entryError) {
+	var mapping discovery.ServiceMapping
+
+	fields := splitEntryFields(line)
+	if len(fields) < 4 || fields[2].text != "->" {
+		column, token := 1, strings.TrimSpace(line)
+		if len(fields) >= 3 {
+			column, token = fields[2].column, fields[2].text
+		}
+		return mapping, &entryError{column: column, token: token, err: errors.New(`expected "service:port/protocol active_ip -> preview_ip"`)}
+	}
+
+	name, portProto, ok := strings.Cut(fields[0].text, ":")
+	if !ok {
+		return mapping, &entryError{column: fields[0].column, token: fields[0].text, err: errors.New("missing service port")}
+	}
+	portRaw, proto, _ := strings.Cut(portProto, "/")
+	portColumn := fields[0].column + len(name) + 1
+	port, err := strconv.ParseInt(portRaw, 10, 32)
+	if err != nil || (strict && (port < 1 || port > 65535)) {
+		return mapping, &entryError{column: portColumn, token: portRaw, err: errors.New("invalid port")}
+	}
+	if strict {
+		switch corev1.Protocol(proto) {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			return mapping, &entryError{column: portColumn + len(portRaw) + 1, token: proto, err: errors.New("unsupported protocol")}
+		}
+		if net.ParseIP(fields[1].text) == nil {
+			return mapping, &entryError{column: fields[1].column, token: fields[1].text, err: errors.New("invalid active IP")}
+		}
+	}
+
+	mapping.ServiceName = name
+	mapping.Port = int32(port)
+	mapping.Protocol = corev1.Protocol(proto)
+	mapping.ActiveClusterIP = fields[1].text
+	mapping.PreviewClusterIP = fields[3].text
+	if host, previewPort, err := net.SplitHostPort(fields[3].text); err == nil {
+		parsed, err := strconv.ParseInt(previewPort, 10, 32)
+		if err != nil || (strict && (parsed < 1 || parsed > 65535)) {
+			return mapping, &entryError{column: fields[3].column + len(fields[3].text) - len(previewPort), token: previewPort, err: errors.New("invalid preview port")}
+		}
+		mapping.PreviewClusterIP = host
+		if int32(parsed) != mapping.Port {
+			mapping.PreviewPort = int32(parsed)
+		}
+	}
+	if strict && net.ParseIP(mapping.PreviewClusterIP) == nil {
+		return mapping, &entryError{column: fields[3].column, token: fields[3].text, err: errors.New("invalid preview IP")}
+	}
+
+	for _, field := range fields[4:] {
+		key, value, ok := strings.Cut(field.text, "=")
+		if !ok {
+			if strict {
+				return mapping, &entryError{column: field.column, token: field.text, err: errors.New("expected key=value")}
+			}
+			continue
+		}
+		switch key {
+		case "namespace":
+			mapping.Namespace = value
+		case "preview":
+			mapping.PreviewServiceName = value
+		case "port_name":
+			mapping.PortName = value
+		}
+	}
+
+	return mapping, nil
+}
+
 func validateDNATMapPath(path string) error {
 	clean := filepath.Clean(path)
 	for _, part := range strings.Split(clean, string(filepath.Separator)) {
@@ -0,0 +1,53 @@
+package iptables
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ErrDNATMapSignature reports a dnat.map whose signature is missing or does
+// not match its contents.
+var ErrDNATMapSignature = errors.New("dnat map signature invalid")
+
+// SignaturePath returns where the signature for the dnat.map at path lives.
+func SignaturePath(path string) string {
+	return path + ".sig"
+}
+
+func dnatMapMAC(content, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return mac.Sum(nil)
+}
+
+func writeDNATMapSignature(path string, content, key []byte) error {
+	signature := hex.EncodeToString(dnatMapMAC(content, key)) + "\n"
+	// #nosec G306 -- the signature is not secret; it sits beside the world-readable map.
+	if err := os.WriteFile(SignaturePath(path), []byte(signature), 0o644); err != nil {
+		return fmt.Errorf("write dnat map signature: %w", err)
+	}
+	return nil
+}
+
+func verifyDNATMapSignature(path string, content, key []byte) error {
+	// #nosec G304 -- the signature sits next to the operator-configured map.
+	raw, err := os.ReadFile(SignaturePath(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s has no signature file", ErrDNATMapSignature, path)
+		}
+		return fmt.Errorf("read dnat map signature: %w", err)
+	}
+
+	presented, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || !hmac.Equal(presented, dnatMapMAC(content, key)) {
+		return fmt.Errorf("%w: %s does not match %s", ErrDNATMapSignature, SignaturePath(path), path)
+	}
+	return nil
+}
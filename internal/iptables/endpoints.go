@@ -0,0 +1,103 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// Endpoint balancing modes: how connections are spread across the preview
+// pods of one port.
+const (
+	EndpointBalanceRandom = "random"
+	EndpointBalanceNth    = "nth"
+)
+
+// ParseEndpointBalance validates an endpoint balancing mode; empty selects
+// random.
+func ParseEndpointBalance(raw string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(raw)); mode {
+	case "":
+		return EndpointBalanceRandom, nil
+	case EndpointBalanceRandom, EndpointBalanceNth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid endpoint balance %q (expected %s or %s)", raw, EndpointBalanceRandom, EndpointBalanceNth)
+	}
+}
+
+// SplitEndpointMappings separates mappings with resolved preview endpoints
+// from those that still target the preview ClusterIP.
+func SplitEndpointMappings(mappings []discovery.ServiceMapping) ([]discovery.ServiceMapping, []discovery.ServiceMapping) {
+	var endpoints, rest []discovery.ServiceMapping
+	for _, mapping := range mappings {
+		if len(mapping.PreviewEndpoints) > 0 {
+			endpoints = append(endpoints, mapping)
+		} else {
+			rest = append(rest, mapping)
+		}
+	}
+	return endpoints, rest
+}
+
+// AddEndpointDNATRules DNATs each mapping straight to its preview pods,
+// bypassing the preview ClusterIP. A mapping with n endpoints gets n rules;
+// all but the last carry a statistic match so each pod receives an equal
+// share of new connections: with random, rule i matches with probability
+// 1/(n-i), with nth it matches every (n-i)th packet reaching it.
+func AddEndpointDNATRules(ctx context.Context, executor Executor, table string, chain string, mappings []discovery.ServiceMapping, balance string, ipv6 bool, logger *slog.Logger) (int, error) {
+	added := 0
+	for _, mapping := range mappings {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+
+		switch endpointSkipReason(mapping, ipv6) {
+		case SkipReasonIPv6Disabled:
+			recordSkippedDNATRule(SkipReasonIPv6Disabled)
+			logger.Warn("skipping ipv6 dnat rule without ipv6 support", slog.String("service", mapping.ServiceName), slog.String("active_ip", mapping.ActiveClusterIP))
+			continue
+		case SkipReasonMixedFamily:
+			recordSkippedDNATRule(SkipReasonMixedFamily)
+			logger.Warn("skipping endpoint dnat rules without endpoints in the active address family", slog.String("service", mapping.ServiceName), slog.String("active_ip", mapping.ActiveClusterIP))
+			continue
+		}
+
+		bin := ipv4Binary
+		if isIPv6(mapping.ActiveClusterIP) {
+			bin = ipv6Binary
+		}
+		endpoints := make([]discovery.Endpoint, 0, len(mapping.PreviewEndpoints))
+		for _, endpoint := range mapping.PreviewEndpoints {
+			if isIPv6(endpoint.IP) == (bin == ipv6Binary) {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+
+		protocol := strings.ToLower(string(mapping.Protocol))
+		for i, endpoint := range endpoints {
+			args := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", mapping.ActiveClusterIP, "-p", protocol, "--dport", strconv.Itoa(int(mapping.Port))}
+			if remaining := len(endpoints) - i; remaining > 1 {
+				if balance == EndpointBalanceNth {
+					args = append(args, "-m", "statistic", "--mode", "nth", "--every", strconv.Itoa(remaining), "--packet", "0")
+				} else {
+					args = append(args, "-m", "statistic", "--mode", "random", "--probability", strconv.FormatFloat(1/float64(remaining), 'f', 5, 64))
+				}
+			}
+			destination := net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))
+			args = withOwner(append(args, "-j", "DNAT", "--to-destination", destination)...)
+
+			logger.Info("adding endpoint dnat rule", slog.String("service", mapping.ServiceName), slog.Int("port", int(mapping.Port)), slog.String("protocol", protocol), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("endpoint", destination))
+			if err := executor.Run(ctx, bin, args...); err != nil {
+				return added, fmt.Errorf("add endpoint dnat rule for %s: %w", mapping.ServiceName, WithService(err, mapping.ServiceName))
+			}
+			added++
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,137 @@
+package iptables
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors a *CommandError matches with errors.Is, classified from the
+// operation, exit status and stderr of the failed command. They let callers
+// skip or retry without matching on output themselves.
+var (
+	// ErrChainMissing means the chain the command targeted does not exist.
+	ErrChainMissing = errors.New("chain does not exist")
+	// ErrRuleMissing means a checked or deleted rule does not exist.
+	ErrRuleMissing = errors.New("rule does not exist")
+	// ErrRuleExists means what the command creates already exists: a chain,
+	// an ipset entry or a conntrack timeout policy.
+	ErrRuleExists = errors.New("already exists")
+	// ErrSetMissing means the ipset the command targeted does not exist.
+	ErrSetMissing = errors.New("ipset does not exist")
+	// ErrLockContention means another process held the xtables lock for
+	// longer than the command waited for it. Retrying later usually works.
+	ErrLockContention = errors.New("xtables lock held by another process")
+)
+
+// operations maps the iptables flags that name an operation to the name
+// CommandError.Operation reports.
+var operations = map[string]string{
+	"-A": "append",
+	"-I": "insert",
+	"-D": "delete",
+	"-C": "check",
+	"-N": "create-chain",
+	"-F": "flush",
+	"-X": "delete-chain",
+	"-E": "rename-chain",
+	"-L": "list",
+	"-S": "list",
+	"-R": "replace",
+}
+
+// NewCommandError builds the error for a failed command, deriving the table,
+// chain and operation from iptables arguments.
+func NewCommandError(command string, args []string, stdout, stderr string, err error) *CommandError {
+	e := &CommandError{
+		Command: command,
+		Args:    append([]string(nil), args...),
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Output:  stdout + stderr,
+		Err:     err,
+	}
+	e.Table, e.Chain, e.Operation = describeArgs(args)
+	return e
+}
+
+// describeArgs picks the table, target chain and operation out of iptables
+// arguments. The table defaults to filter, as in iptables itself; non-iptables
+// commands yield empty values.
+func describeArgs(args []string) (table, chain, operation string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-t" && i+1 < len(args) {
+			table = args[i+1]
+			i++
+			continue
+		}
+		if op, ok := operations[arg]; ok && operation == "" {
+			operation = op
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				chain = args[i+1]
+			}
+		}
+	}
+	if operation != "" && table == "" {
+		table = "filter"
+	}
+	return table, chain, operation
+}
+
+// WithService records service as the Service a command was run for, when err
+// carries a *CommandError. It returns err.
+func WithService(err error, service string) error {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Service == "" {
+		cmdErr.Service = service
+	}
+	return err
+}
+
+// Is reports whether the failure matches one of the sentinel errors.
+func (e *CommandError) Is(target error) bool {
+	switch target {
+	case ErrChainMissing, ErrRuleMissing, ErrRuleExists, ErrSetMissing, ErrLockContention:
+	default:
+		return false
+	}
+
+	operation := e.Operation
+	if operation == "" {
+		_, _, operation = describeArgs(e.Args)
+	}
+	output := strings.ToLower(e.Stderr)
+	if output == "" {
+		output = strings.ToLower(e.Output)
+	}
+	// iptables reports a missing chain or rule with exit status 1; fakes and
+	// helpers that drop the output leave only that to go on. Without arguments
+	// to tell the operation apart, status 1 counts as either.
+	statusOne := false
+	var exitErr interface{ ExitCode() int }
+	if errors.As(e.Err, &exitErr) {
+		statusOne = exitErr.ExitCode() == 1
+	}
+
+	switch target {
+	case ErrLockContention:
+		return strings.Contains(output, "xtables lock")
+	case ErrRuleExists:
+		return strings.Contains(output, "already exists") ||
+			strings.Contains(output, "file exists") ||
+			strings.Contains(output, "already added")
+	case ErrSetMissing:
+		return strings.Contains(output, "set with the given name does not exist")
+	case ErrChainMissing:
+		if strings.Contains(output, "no chain/target/match by that name") {
+			return true
+		}
+		return statusOne && (operation == "" || operation == "list" || operation == "flush" || operation == "delete-chain")
+	case ErrRuleMissing:
+		if strings.Contains(output, "bad rule") || strings.Contains(output, "does a matching rule exist") {
+			return true
+		}
+		return statusOne && (operation == "" || operation == "check" || operation == "delete")
+	}
+	return false
+}
@@ -29,7 +29,7 @@ func AddExclusions(ctx context.Context, executor Executor, table string, chain s
 		isIPv6 := ip.To4() == nil
 		if !isIPv6 {
 			logger.Info("adding exclusion", slog.String("cidr", cidr), slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
-			if err := executor.Run(ctx, ipv4Binary, "-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", cidr, "-j", "RETURN"); err != nil {
+			if err := executor.Run(ctx, ipv4Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", cidr, "-j", "RETURN")...); err != nil {
 				return fmt.Errorf("add exclusion for %s: %w", cidr, err)
 			}
 			continue
@@ -41,10 +41,56 @@ func AddExclusions(ctx context.Context, executor Executor, table string, chain s
 		}
 
 		logger.Info("adding exclusion", slog.String("cidr", cidr), slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", true))
-		if err := executor.Run(ctx, ipv6Binary, "-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", cidr, "-j", "RETURN"); err != nil {
+		if err := executor.Run(ctx, ipv6Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", cidr, "-j", "RETURN")...); err != nil {
 			return fmt.Errorf("add ipv6 exclusion for %s: %w", cidr, err)
 		}
 	}
 
 	return nil
 }
+
+// AddCgroupExclusions injects RETURN rules for traffic originating from the
+// given cgroup v2 paths, letting sidecars such as telemetry agents bypass
+// preview routing. cgroup matching only applies to locally generated traffic,
+// so it is effective with the OUTPUT hook.
+func AddCgroupExclusions(ctx context.Context, executor Executor, table string, chain string, paths []string, ipv6 bool, logger *slog.Logger) error {
+	for _, raw := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := strings.TrimSpace(raw)
+		if path == "" {
+			continue
+		}
+		if err := validateCgroupPath(path); err != nil {
+			return err
+		}
+
+		logger.Info("adding cgroup exclusion", slog.String("cgroup_path", path), slog.String("table", table), slog.String("chain", chain))
+		if err := executor.Run(ctx, ipv4Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-m", "cgroup", "--path", path, "-j", "RETURN")...); err != nil {
+			return fmt.Errorf("add cgroup exclusion for %s: %w", path, err)
+		}
+
+		if !ipv6 {
+			continue
+		}
+		if err := executor.Run(ctx, ipv6Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-m", "cgroup", "--path", path, "-j", "RETURN")...); err != nil {
+			return fmt.Errorf("add ipv6 cgroup exclusion for %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCgroupPath(path string) error {
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return fmt.Errorf("cgroup path %q contains unsupported traversal component", path)
+		}
+	}
+	if strings.ContainsAny(path, " \t\n") {
+		return fmt.Errorf("cgroup path %q must not contain whitespace", path)
+	}
+	return nil
+}
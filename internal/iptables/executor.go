@@ -1,11 +1,14 @@
 package iptables
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strings"
+	"unicode"
 )
 
 // Executor abstracts command execution for iptables interactions.
@@ -16,20 +19,36 @@ type Executor interface {
 }
 
 // CommandError captures detailed failure information from command execution.
+// Build it with NewCommandError, and match failures with errors.Is against
+// ErrChainMissing, ErrRuleMissing, ErrRuleExists, ErrSetMissing and
+// ErrLockContention rather than inspecting the output.
 type CommandError struct {
 	Command string
 	Args    []string
-	Output  string
-	Err     error
+	Stdout  string
+	Stderr  string
+	// Output is Stdout followed by Stderr.
+	Output string
+	// Table, Chain and Operation ("append", "delete", "list", ...) describe
+	// an iptables command; Service is set by callers acting for one.
+	Table     string
+	Chain     string
+	Operation string
+	Service   string
+	Err       error
 }
 
 // Error implements the error interface.
 func (e *CommandError) Error() string {
-	joined := strings.Join(e.Args, " ")
-	if e.Output != "" {
-		return fmt.Sprintf("command %s %s failed: %v: %s", e.Command, joined, e.Err, strings.TrimSpace(e.Output))
+	msg := fmt.Sprintf("command %s %s failed: %v", e.Command, strings.Join(e.Args, " "), e.Err)
+	detail := strings.TrimSpace(e.Stderr)
+	if detail == "" {
+		detail = strings.TrimSpace(e.Output)
 	}
-	return fmt.Sprintf("command %s %s failed: %v", e.Command, joined, e.Err)
+	if detail != "" {
+		msg += ": " + detail
+	}
+	return msg
 }
 
 // Unwrap exposes the underlying error for errors.Is / errors.As checks.
@@ -37,58 +56,117 @@ func (e *CommandError) Unwrap() error {
 	return e.Err
 }
 
-// RealExecutor executes commands on the host system.
-type RealExecutor struct{}
+// RealExecutor executes commands on the host system. It only runs the
+// binaries in AllowedBinaries plus any extras it was built with, and rejects
+// arguments that could only come from a bug in rule construction.
+type RealExecutor struct {
+	extra map[string]bool
+}
+
+// AllowedBinaries are the commands every RealExecutor may run.
+var AllowedBinaries = []string{ipv4Binary, ipv6Binary, ipsetBinary, nfctBinary, "conntrack"}
+
+// ErrCommandRejected is returned, wrapped in a *CommandError, when a command
+// is refused before it runs.
+var ErrCommandRejected = errors.New("command rejected")
+
+const (
+	// maxArgs and maxArgLength are far above anything rule construction
+	// produces; they only stop runaway input.
+	maxArgs      = 64
+	maxArgLength = 512
+)
+
+// NewExecutor constructs a RealExecutor that may also run the extra binaries.
+func NewExecutor(extra ...string) Executor {
+	r := &RealExecutor{extra: make(map[string]bool, len(extra))}
+	for _, binary := range extra {
+		r.extra[binary] = true
+	}
+	return r
+}
+
+// validate refuses binaries outside the allowlist, and arguments iptables
+// never needs but that are dangerous wherever a shell gets involved: control
+// characters, shell metacharacters and oversized values.
+func (r *RealExecutor) validate(command string, args []string) error {
+	reject := func(format string, a ...any) error {
+		return NewCommandError(command, args, "", "", fmt.Errorf("%w: %s", ErrCommandRejected, fmt.Sprintf(format, a...)))
+	}
+
+	if !slices.Contains(AllowedBinaries, command) && !r.extra[command] {
+		return reject("%s is not an allowed binary", command)
+	}
+	if len(args) > maxArgs {
+		return reject("%d arguments exceeds %d", len(args), maxArgs)
+	}
+	for i, arg := range args {
+		if len(arg) > maxArgLength {
+			return reject("argument %d is longer than %d bytes", i+1, maxArgLength)
+		}
+		if j := strings.IndexFunc(arg, unsafeArgRune); j >= 0 {
+			return reject("argument %d contains %q", i+1, arg[j:j+1])
+		}
+	}
+	return nil
+}
+
+// ValidateCommand applies the checks a RealExecutor without extra binaries
+// runs before every command, for callers that must start the process
+// themselves, such as a helper passing its standard streams through.
+func ValidateCommand(command string, args []string) error {
+	return (&RealExecutor{}).validate(command, args)
+}
 
-// NewExecutor constructs a RealExecutor instance.
-func NewExecutor() Executor {
-	return &RealExecutor{}
+func unsafeArgRune(r rune) bool {
+	return unicode.IsControl(r) || strings.ContainsRune(";|&$`<>\\'\"", r)
 }
 
 // Run executes the provided command and returns detailed errors when it fails.
 func (r *RealExecutor) Run(ctx context.Context, command string, args ...string) error {
+	if err := r.validate(command, args); err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
 	cmd := exec.CommandContext(ctx, command, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return &CommandError{
-			Command: command,
-			Args:    append([]string(nil), args...),
-			Output:  string(output),
-			Err:     err,
-		}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return NewCommandError(command, args, stdout.String(), stderr.String(), err)
 	}
 	return nil
 }
 
-func chainExists(ctx context.Context, binary string, table string, chain string) (bool, error) {
-	cmd := exec.CommandContext(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-L", chain)
-	output, err := cmd.CombinedOutput()
+func (r *RealExecutor) chainExists(ctx context.Context, binary string, table string, chain string) (bool, error) {
+	if err := r.validate(binary, []string{"-w", iptablesWaitSeconds, "-t", table, "-L", chain}); err != nil {
+		return false, err
+	}
+	args := []string{"-w", iptablesWaitSeconds, "-t", table, "-L", chain}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
 	if err == nil {
 		return true, nil
 	}
 
 	var exitErr *exec.ExitError
-	if errors.As(err, &exitErr) {
-		if exitErr.ExitCode() == 1 {
-			return false, nil
-		}
-		return false, &CommandError{
-			Command: binary,
-			Args:    []string{"-w", iptablesWaitSeconds, "-t", table, "-L", chain},
-			Output:  string(output),
-			Err:     err,
-		}
+	if !errors.As(err, &exitErr) {
+		return false, fmt.Errorf("checking chain existence: %w", err)
 	}
-
-	return false, fmt.Errorf("checking chain existence: %w", err)
+	cmdErr := NewCommandError(binary, args, "", stderr.String(), err)
+	if errors.Is(cmdErr, ErrChainMissing) {
+		return false, nil
+	}
+	return false, cmdErr
 }
 
 // ChainExists determines whether the requested IPv4 chain is present in the specified table.
 func (r *RealExecutor) ChainExists(ctx context.Context, table string, chain string) (bool, error) {
-	return chainExists(ctx, ipv4Binary, table, chain)
+	return r.chainExists(ctx, ipv4Binary, table, chain)
 }
 
 // ChainExists6 determines whether the requested IPv6 chain is present in the specified table.
 func (r *RealExecutor) ChainExists6(ctx context.Context, table string, chain string) (bool, error) {
-	return chainExists(ctx, ipv6Binary, table, chain)
+	return r.chainExists(ctx, ipv6Binary, table, chain)
 }
@@ -0,0 +1,79 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// DefaultHairpinMark is the connmark bit used to flag ghostwire-redirected
+// connections for masquerading.
+const DefaultHairpinMark = "0x1000000"
+
+// AddHairpinMarks tags connections towards every active ClusterIP in the chain
+// with a connmark so the POSTROUTING masquerade rule can recognise them. The
+// marks are appended before the DNAT rules; connections to excluded CIDRs have
+// already returned and are never marked.
+func AddHairpinMarks(ctx context.Context, executor Executor, table string, chain string, mark string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
+	seen := make(map[string]bool)
+	added := 0
+	for _, mapping := range mappings {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+		if mapping.ActiveClusterIP == "" || seen[mapping.ActiveClusterIP] {
+			continue
+		}
+		seen[mapping.ActiveClusterIP] = true
+
+		bin := ipv4Binary
+		if isIPv6(mapping.ActiveClusterIP) {
+			if !ipv6 {
+				continue
+			}
+			bin = ipv6Binary
+		}
+
+		logger.Info("adding hairpin connmark rule", slog.String("active_ip", mapping.ActiveClusterIP), slog.String("mark", mark))
+		if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", mapping.ActiveClusterIP, "-j", "CONNMARK", "--set-xmark", mark+"/"+mark)...); err != nil {
+			return added, fmt.Errorf("add hairpin mark for %s: %w", mapping.ActiveClusterIP, err)
+		}
+		added++
+	}
+
+	return added, nil
+}
+
+// EnsureHairpinMasquerade installs a POSTROUTING MASQUERADE rule for marked
+// connections. The rule is idempotent and stays in place across role flips:
+// without the jump into the DNAT chain no connection carries the mark.
+func EnsureHairpinMasquerade(ctx context.Context, executor Executor, mark string, ipv6 bool, logger *slog.Logger) error {
+	rule := withOwner("POSTROUTING", "-m", "connmark", "--mark", mark+"/"+mark, "-j", "MASQUERADE")
+
+	binaries := []string{ipv4Binary}
+	if ipv6 {
+		binaries = append(binaries, ipv6Binary)
+	}
+
+	for _, bin := range binaries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		checkArgs := append([]string{"-w", iptablesWaitSeconds, "-t", "nat", "-C"}, rule...)
+		if err := executor.Run(ctx, bin, checkArgs...); err == nil {
+			logger.Debug("hairpin masquerade rule already present", slog.String("binary", bin))
+			continue
+		}
+
+		logger.Info("adding hairpin masquerade rule", slog.String("binary", bin), slog.String("mark", mark))
+		addArgs := append([]string{"-w", iptablesWaitSeconds, "-t", "nat", "-A"}, rule...)
+		if err := executor.Run(ctx, bin, addArgs...); err != nil {
+			return fmt.Errorf("add hairpin masquerade rule via %s: %w", bin, err)
+		}
+	}
+
+	return nil
+}
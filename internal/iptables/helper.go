@@ -0,0 +1,97 @@
+package iptables
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// HelperExecutor runs every command through a helper process, typically the
+// ghostwire binary re-executed with a hidden subcommand, so the calling
+// process can give up the capabilities iptables needs. The helper is invoked
+// as `Path Args... command args...` and must exit with the command's status.
+type HelperExecutor struct {
+	Path string
+	Args []string
+}
+
+func (h *HelperExecutor) command(ctx context.Context, command string, args ...string) *exec.Cmd {
+	argv := make([]string, 0, len(h.Args)+1+len(args))
+	argv = append(argv, h.Args...)
+	argv = append(argv, command)
+	argv = append(argv, args...)
+	// #nosec G204 -- the helper path is the ghostwire binary itself and the
+	// command comes from this package.
+	return exec.CommandContext(ctx, h.Path, argv...)
+}
+
+// Run executes command through the helper.
+func (h *HelperExecutor) Run(ctx context.Context, command string, args ...string) error {
+	var stdout, stderr bytes.Buffer
+	cmd := h.command(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return NewCommandError(command, args, stdout.String(), stderr.String(), err)
+	}
+	return nil
+}
+
+// RunInput executes command through the helper with input as its standard
+// input, which the helper passes on to the command.
+func (h *HelperExecutor) RunInput(ctx context.Context, input string, command string, args ...string) error {
+	var stdout, stderr bytes.Buffer
+	cmd := h.command(ctx, command, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return NewCommandError(command, args, stdout.String(), stderr.String(), err)
+	}
+	return nil
+}
+
+// Output executes command through the helper and returns its standard output.
+func (h *HelperExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	output, err := h.command(ctx, command, args...).Output()
+	if err != nil {
+		var stderr string
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr = string(exitErr.Stderr)
+		}
+		return "", NewCommandError(command, args, "", stderr, err)
+	}
+	return string(output), nil
+}
+
+// ChainExists determines whether the IPv4 chain is present in table.
+func (h *HelperExecutor) ChainExists(ctx context.Context, table string, chain string) (bool, error) {
+	return RunChainExists(ctx, h, table, chain, false)
+}
+
+// ChainExists6 determines whether the IPv6 chain is present in table.
+func (h *HelperExecutor) ChainExists6(ctx context.Context, table string, chain string) (bool, error) {
+	return RunChainExists(ctx, h, table, chain, true)
+}
+
+// RunChainExists checks for chain by listing it through executor.Run, treating
+// exit status 1 as a missing chain. Executors that only forward commands use
+// it to implement ChainExists and ChainExists6.
+func RunChainExists(ctx context.Context, executor Executor, table string, chain string, ipv6 bool) (bool, error) {
+	binary := ipv4Binary
+	if ipv6 {
+		binary = ipv6Binary
+	}
+	err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-L", chain)
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.Is(err, ErrChainMissing) {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,202 @@
+package iptables
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+const ipsetBinary = "ipset"
+
+// InputExecutor is implemented by executors that can feed a command its
+// standard input. Set loads need it to pass every entry to one
+// `ipset restore` instead of running `ipset add` per entry.
+type InputExecutor interface {
+	RunInput(ctx context.Context, input string, command string, args ...string) error
+}
+
+// RunInput executes the command with input as its standard input.
+func (r *RealExecutor) RunInput(ctx context.Context, input string, command string, args ...string) error {
+	if err := r.validate(command, args); err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return NewCommandError(command, args, stdout.String(), stderr.String(), err)
+	}
+	return nil
+}
+
+// IPSetNames returns the IPv4 and IPv6 set names derived from base. ipset sets
+// are single-family, so IPv6 CIDRs live in a sibling set suffixed with "6".
+func IPSetNames(base string) (string, string) {
+	return base, base + "6"
+}
+
+// AddIPSetExclusions loads cidrs into hash:net ipsets and installs a single
+// `-m set --match-set` RETURN rule per family, replacing the linear list of
+// per-CIDR RETURN rules that AddExclusions would emit.
+func AddIPSetExclusions(ctx context.Context, executor Executor, table string, chain string, setName string, cidrs []string, ipv6 bool, logger *slog.Logger) error {
+	var v4, v6 []string
+	for _, raw := range cidrs {
+		cidr := strings.TrimSpace(raw)
+		if cidr == "" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Error("invalid exclusion cidr", slog.String("cidr", cidr), slog.String("ipset", setName), slog.Any("error", err))
+			return fmt.Errorf("parse exclusion cidr %q: %w", cidr, err)
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, cidr)
+			continue
+		}
+		if !ipv6 {
+			logger.Warn("skipping ipv6 exclusion without ipv6 support", slog.String("cidr", cidr), slog.String("ipset", setName))
+			continue
+		}
+		v6 = append(v6, cidr)
+	}
+
+	v4Set, v6Set := IPSetNames(setName)
+	if err := loadIPSet(ctx, executor, v4Set, "inet", v4, logger); err != nil {
+		return err
+	}
+	logger.Info("adding ipset exclusion", slog.String("ipset", v4Set), slog.Int("entries", len(v4)), slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", false))
+	if err := executor.Run(ctx, ipv4Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-m", "set", "--match-set", v4Set, "dst", "-j", "RETURN")...); err != nil {
+		return fmt.Errorf("add ipset exclusion for %s: %w", v4Set, err)
+	}
+
+	if !ipv6 {
+		return nil
+	}
+
+	if err := loadIPSet(ctx, executor, v6Set, "inet6", v6, logger); err != nil {
+		return err
+	}
+	logger.Info("adding ipset exclusion", slog.String("ipset", v6Set), slog.Int("entries", len(v6)), slog.String("table", table), slog.String("chain", chain), slog.Bool("ipv6", true))
+	if err := executor.Run(ctx, ipv6Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-m", "set", "--match-set", v6Set, "dst", "-j", "RETURN")...); err != nil {
+		return fmt.Errorf("add ipv6 ipset exclusion for %s: %w", v6Set, err)
+	}
+
+	return nil
+}
+
+// maxIPSetNameLength is the longest set name ipset accepts.
+const maxIPSetNameLength = 31
+
+const stagingSetSuffix = "-next"
+
+// stagingSetName returns the set a reload is written to before it is swapped
+// with name. Long names are trimmed so the suffix still fits.
+func stagingSetName(name string) string {
+	if len(name)+len(stagingSetSuffix) > maxIPSetNameLength {
+		name = name[:maxIPSetNameLength-len(stagingSetSuffix)]
+	}
+	return name + stagingSetSuffix
+}
+
+// loadIPSet creates the set if needed and replaces its contents with cidrs.
+// Like a resync's staging chain, the entries are loaded into a staging set
+// that is then swapped with the live one, so rules matching the set never see
+// it empty or half-filled. Executors that implement InputExecutor load every
+// entry with a single `ipset restore`; others fall back to one `ipset add`
+// per entry.
+func loadIPSet(ctx context.Context, executor Executor, name string, family string, cidrs []string, logger *slog.Logger) error {
+	if err := executor.Run(ctx, ipsetBinary, "create", name, "hash:net", "family", family, "-exist"); err != nil {
+		return fmt.Errorf("create ipset %s: %w", name, err)
+	}
+	staging := stagingSetName(name)
+	if err := executor.Run(ctx, ipsetBinary, "create", staging, "hash:net", "family", family, "-exist"); err != nil {
+		return fmt.Errorf("create ipset %s: %w", staging, err)
+	}
+	// A staging set left by an interrupted load may still hold entries.
+	if err := executor.Run(ctx, ipsetBinary, "flush", staging); err != nil {
+		return fmt.Errorf("flush ipset %s: %w", staging, err)
+	}
+	if err := fillIPSet(ctx, executor, staging, cidrs); err != nil {
+		return err
+	}
+	if err := executor.Run(ctx, ipsetBinary, "swap", staging, name); err != nil {
+		return fmt.Errorf("swap ipset %s into %s: %w", staging, name, err)
+	}
+	if err := executor.Run(ctx, ipsetBinary, "destroy", staging); err != nil {
+		return fmt.Errorf("destroy ipset %s: %w", staging, err)
+	}
+	logger.Debug("ipset loaded", slog.String("ipset", name), slog.String("family", family), slog.Int("entries", len(cidrs)))
+	return nil
+}
+
+func fillIPSet(ctx context.Context, executor Executor, name string, cidrs []string) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+	if inputExecutor, ok := executor.(InputExecutor); ok {
+		var input strings.Builder
+		for _, cidr := range cidrs {
+			fmt.Fprintf(&input, "add %s %s -exist\n", name, cidr)
+		}
+		if err := inputExecutor.RunInput(ctx, input.String(), ipsetBinary, "restore"); err != nil {
+			return fmt.Errorf("restore %d entries into ipset %s: %w", len(cidrs), name, err)
+		}
+		return nil
+	}
+	for _, cidr := range cidrs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := executor.Run(ctx, ipsetBinary, "add", name, cidr, "-exist"); err != nil {
+			return fmt.Errorf("add %s to ipset %s: %w", cidr, name, err)
+		}
+	}
+	return nil
+}
+
+// DestroyIPSetExclusions removes the match-set RETURN rules from chain and
+// destroys the exclusion sets. Rules or sets that are already gone are
+// ignored, so teardown is safe to repeat.
+func DestroyIPSetExclusions(ctx context.Context, executor Executor, table string, chain string, setName string, ipv6 bool, logger *slog.Logger) error {
+	v4Set, v6Set := IPSetNames(setName)
+
+	type family struct {
+		binary string
+		set    string
+	}
+	families := []family{{binary: ipv4Binary, set: v4Set}}
+	if ipv6 {
+		families = append(families, family{binary: ipv6Binary, set: v6Set})
+	}
+
+	var errs []error
+	for _, f := range families {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		logger.Info("removing ipset exclusion", slog.String("ipset", f.set), slog.String("table", table), slog.String("chain", chain))
+		if err := executor.Run(ctx, f.binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-D", chain, "-m", "set", "--match-set", f.set, "dst", "-j", "RETURN")...); err != nil && !isMissingError(err) {
+			errs = append(errs, fmt.Errorf("remove ipset exclusion for %s: %w", f.set, err))
+			continue
+		}
+		if err := executor.Run(ctx, ipsetBinary, "destroy", f.set); err != nil && !isMissingError(err) {
+			errs = append(errs, fmt.Errorf("destroy ipset %s: %w", f.set, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isMissingError reports whether a command failed only because the rule, chain,
+// or set it targeted does not exist.
+func isMissingError(err error) bool {
+	return errors.Is(err, ErrSetMissing) || errors.Is(err, ErrChainMissing) || errors.Is(err, ErrRuleMissing)
+}
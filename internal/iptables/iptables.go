@@ -9,10 +9,16 @@ import (
 	"github.com/denniswebb/ghostwire/internal/discovery"
 )
 
-var executorFactory = NewExecutor
+var executorFactory = func() Executor { return NewExecutor() }
 
 // Setup orchestrates chain preparation, exclusion insertion, DNAT rules, and audit output.
 func Setup(ctx context.Context, cfg Config, mappings []discovery.ServiceMapping, logger *slog.Logger) error {
+	return SetupWithExecutor(ctx, executorFactory(), cfg, mappings, logger)
+}
+
+// SetupWithExecutor is Setup with an explicit executor, e.g. a RestoreRecorder
+// that captures the rules instead of applying them.
+func SetupWithExecutor(ctx context.Context, executor Executor, cfg Config, mappings []discovery.ServiceMapping, logger *slog.Logger) error {
 	if logger == nil {
 		logger = slog.Default()
 	}
@@ -21,29 +27,129 @@ func Setup(ctx context.Context, cfg Config, mappings []discovery.ServiceMapping,
 		return err
 	}
 
-	executor := executorFactory()
+	mappings, _ = FilterProtocols(mappings, cfg.Protocols, logger)
 
 	chainName := strings.TrimSpace(cfg.ChainName)
 	if chainName == "" {
 		chainName = defaultChainName
 	}
 	cfg.ChainName = chainName
+	if err := ValidateChainName(chainName); err != nil {
+		return err
+	}
+
+	debugLog, err := cfg.DebugLog.Validate()
+	if err != nil {
+		return err
+	}
 
 	if err := EnsureChain(ctx, executor, "nat", cfg.ChainName, cfg.IPv6, logger); err != nil {
 		return fmt.Errorf("prepare chain %s: %w", cfg.ChainName, err)
 	}
 
-	if err := AddExclusions(ctx, executor, "nat", cfg.ChainName, cfg.ExcludeCIDRs, cfg.IPv6, logger); err != nil {
+	if _, err := AddDebugLogRule(ctx, executor, "nat", cfg.ChainName, debugLog, DebugLogScopeChain, cfg.IPv6, logger); err != nil {
+		return err
+	}
+
+	if setName := strings.TrimSpace(cfg.ExclusionIPSet); setName != "" {
+		err = AddIPSetExclusions(ctx, executor, "nat", cfg.ChainName, setName, cfg.ExcludeCIDRs, cfg.IPv6, logger)
+	} else {
+		err = AddExclusions(ctx, executor, "nat", cfg.ChainName, cfg.ExcludeCIDRs, cfg.IPv6, logger)
+	}
+	if err != nil {
 		return fmt.Errorf("add exclusions: %w", err)
 	}
 
-	addedDNATRules, err := AddDNATRules(ctx, executor, "nat", cfg.ChainName, mappings, cfg.IPv6, logger)
+	if len(cfg.NotrackCIDRs) > 0 {
+		if err := ValidateNotrackCIDRs(cfg.NotrackCIDRs, cfg.ExcludeCIDRs); err != nil {
+			return err
+		}
+		if _, err := SetupNotrack(ctx, executor, cfg.ChainName, cfg.NotrackCIDRs, cfg.IPv6, logger); err != nil {
+			return fmt.Errorf("setup notrack rules: %w", err)
+		}
+	}
+
+	if err := AddCgroupExclusions(ctx, executor, "nat", cfg.ChainName, cfg.ExcludeCgroupPaths, cfg.IPv6, logger); err != nil {
+		return fmt.Errorf("add cgroup exclusions: %w", err)
+	}
+
+	if _, err := AddSplitRules(ctx, executor, "nat", cfg.ChainName, cfg.Split, cfg.IPv6, logger); err != nil {
+		return fmt.Errorf("add split rules: %w", err)
+	}
+
+	if cfg.HairpinMasquerade {
+		mark := strings.TrimSpace(cfg.HairpinMark)
+		if mark == "" {
+			mark = DefaultHairpinMark
+		}
+		if _, err := AddHairpinMarks(ctx, executor, "nat", cfg.ChainName, mark, mappings, cfg.IPv6, logger); err != nil {
+			return fmt.Errorf("add hairpin marks: %w", err)
+		}
+		if err := EnsureHairpinMasquerade(ctx, executor, mark, cfg.IPv6, logger); err != nil {
+			return fmt.Errorf("ensure hairpin masquerade: %w", err)
+		}
+	}
+
+	perPort := mappings
+	addedDNATRules := 0
+	skippedBefore := SkippedDNATRules()
+	if cfg.EndpointDNAT {
+		balance, err := ParseEndpointBalance(cfg.EndpointBalance)
+		if err != nil {
+			return err
+		}
+		var endpoints []discovery.ServiceMapping
+		endpoints, perPort = SplitEndpointMappings(perPort)
+		addedDNATRules, err = AddEndpointDNATRules(ctx, executor, "nat", cfg.ChainName, endpoints, balance, cfg.IPv6, logger)
+		if err != nil {
+			return fmt.Errorf("add endpoint dnat rules: %w", err)
+		}
+	}
+
+	if cfg.WholeServiceDNAT {
+		var whole []discovery.ServiceMapping
+		var addedWhole int
+		whole, perPort = SplitWholeService(perPort)
+		addedWhole, err = AddWholeServiceDNATRules(ctx, executor, "nat", cfg.ChainName, whole, cfg.IPv6, logger)
+		if err != nil {
+			return fmt.Errorf("add whole-service dnat rules: %w", err)
+		}
+		addedDNATRules += addedWhole
+	}
+
+	if cfg.Multiport {
+		var groups []MultiportGroup
+		groups, perPort = GroupMultiport(perPort)
+		addedMultiport, err := AddMultiportDNATRules(ctx, executor, "nat", cfg.ChainName, groups, cfg.IPv6, logger)
+		if err != nil {
+			return fmt.Errorf("add multiport dnat rules: %w", err)
+		}
+		addedDNATRules += addedMultiport
+	}
+
+	addedPerPort, err := AddDNATRules(ctx, executor, "nat", cfg.ChainName, perPort, cfg.IPv6, logger)
 	if err != nil {
 		return fmt.Errorf("add dnat rules: %w", err)
 	}
+	addedDNATRules += addedPerPort
+
+	if _, err := AddDebugLogRule(ctx, executor, "nat", cfg.ChainName, debugLog, DebugLogScopeUnmatched, cfg.IPv6, logger); err != nil {
+		return err
+	}
+
+	if cfg.CTTimeoutPolicy != "" {
+		if cfg.CTUDPTimeoutSeconds > 0 {
+			if err := CreateCTTimeoutPolicy(ctx, executor, cfg.CTTimeoutPolicy, cfg.CTUDPTimeoutSeconds, logger); err != nil {
+				return err
+			}
+		}
+		if _, err := AddCTTimeoutRules(ctx, executor, cfg.ChainName, cfg.CTTimeoutPolicy, mappings, cfg.IPv6, logger); err != nil {
+			return fmt.Errorf("add conntrack timeout rules: %w", err)
+		}
+	}
 
 	if cfg.DnatMapPath != "" {
-		if err := WriteDNATMap(cfg.DnatMapPath, mappings, logger); err != nil {
+		if err := writeDNATMap(cfg.DnatMapPath, mappings, cfg.DnatMapKey, logger); err != nil {
 			return fmt.Errorf("write dnat map: %w", err)
 		}
 	}
@@ -60,6 +166,7 @@ func Setup(ctx context.Context, cfg Config, mappings []discovery.ServiceMapping,
 		slog.String("chain_name", cfg.ChainName),
 		slog.Int("exclusions", exclusionCount),
 		slog.Int("dnat_rules", addedDNATRules),
+		slog.Any("skipped_dnat_rules", skippedSince(skippedBefore)),
 		slog.Bool("ipv6_enabled", cfg.IPv6),
 		slog.String("dnat_map_path", cfg.DnatMapPath),
 	)
@@ -3,16 +3,22 @@ package iptables
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 
+	"github.com/denniswebb/ghostwire/internal/config"
 	"github.com/denniswebb/ghostwire/internal/discovery"
 )
 
@@ -127,7 +133,7 @@ func TestAddDNATRulesIPFamilyHandling(t *testing.T) {
 		}
 
 		call := exec.calls[0]
-		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.1", "-p", "tcp", "--dport", "80", "-j", "DNAT", "--to-destination", "10.0.0.2:80"}
+		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.1", "-p", "tcp", "--dport", "80", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.0.2:80"}
 		if call.command != ipv4Binary {
 			t.Fatalf("expected command %q, got %q", ipv4Binary, call.command)
 		}
@@ -162,7 +168,7 @@ func TestAddDNATRulesIPFamilyHandling(t *testing.T) {
 		}
 
 		call := exec.calls[0]
-		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "fd00::1", "-p", "tcp", "--dport", "443", "-j", "DNAT", "--to-destination", "fd00::2:443"}
+		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "fd00::1", "-p", "tcp", "--dport", "443", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "fd00::2:443"}
 		if call.command != ipv6Binary {
 			t.Fatalf("expected command %q, got %q", ipv6Binary, call.command)
 		}
@@ -251,22 +257,32 @@ func TestEnsureChain(t *testing.T) {
 		}
 	})
 
-	t.Run("flushes chain when present", func(t *testing.T) {
+	t.Run("deletes owned rules when present", func(t *testing.T) {
 		t.Parallel()
-		exec := &recordingExecutor{chainExists: true}
+		exec := &ruleListingExecutor{
+			recordingExecutor: recordingExecutor{chainExists: true},
+			chains: map[string]string{
+				chain: "-N CANARY_DNAT\n" +
+					"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1:80\n" +
+					"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 80 -m comment --comment other-tool -j DNAT --to-destination 10.0.1.2:80\n" +
+					"-A CANARY_DNAT -d 10.0.0.3/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.3:80\n",
+			},
+		}
 		if err := EnsureChain(ctx, exec, table, chain, false, logger); err != nil {
 			t.Fatalf("EnsureChain returned error: %v", err)
 		}
 		if exec.chainExistsHits != 1 {
 			t.Fatalf("ChainExists called %d times, want 1", exec.chainExistsHits)
 		}
-		if len(exec.calls) != 1 {
-			t.Fatalf("expected 1 command, got %d", len(exec.calls))
+		// The rule tagged by other tooling stays; the rest go, bottom first.
+		if len(exec.calls) != 2 {
+			t.Fatalf("expected 2 commands, got %+v", exec.calls)
 		}
-		call := exec.calls[0]
-		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-F", chain}
-		if call.command != ipv4Binary || !equalSlices(call.args, wantArgs) {
-			t.Fatalf("unexpected command %+v", call)
+		for i, number := range []string{"3", "1"} {
+			wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-D", chain, number}
+			if call := exec.calls[i]; call.command != ipv4Binary || !equalSlices(call.args, wantArgs) {
+				t.Fatalf("unexpected command %+v", call)
+			}
 		}
 	})
 
@@ -364,7 +380,7 @@ func TestAddExclusionsScenarios(t *testing.T) {
 			t.Fatalf("expected 1 command for ipv4 exclusion, got %d", len(exec.calls))
 		}
 		call := exec.calls[0]
-		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CHAIN", "-d", "169.254.169.254/32", "-j", "RETURN"}
+		wantArgs := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CHAIN", "-d", "169.254.169.254/32", "-m", "comment", "--comment", "ghostwire", "-j", "RETURN"}
 		if call.command != ipv4Binary || !equalSlices(call.args, wantArgs) {
 			t.Fatalf("unexpected command %+v", call)
 		}
@@ -400,6 +416,14 @@ func TestWriteDNATMap(t *testing.T) {
 				ActiveClusterIP:  "10.0.0.20",
 				PreviewClusterIP: "10.0.1.20",
 			},
+			{
+				ServiceName:      "grpc",
+				Port:             9000,
+				Protocol:         corev1.ProtocolTCP,
+				ActiveClusterIP:  "fd00::30",
+				PreviewClusterIP: "fd00::31",
+				PreviewPort:      9001,
+			},
 		}
 
 		if err := WriteDNATMap(path, mappings, logger); err != nil {
@@ -412,9 +436,9 @@ func TestWriteDNATMap(t *testing.T) {
 			t.Fatalf("ReadFile: %v", err)
 		}
 
-		expected := "# DNAT mappings generated by ghostwire-init\n# Format: service:port/protocol active_ip -> preview_ip\norders:80/TCP 10.0.0.10 -> 10.0.1.10\npayment:443/TCP 10.0.0.20 -> 10.0.1.20\n"
-		if string(data) != expected {
-			t.Fatalf("unexpected map contents:\n%s\nwant:\n%s", data, expected)
+		expected := "# DNAT mappings generated by ghostwire-init\n# Version: 2\n# Format: service:port/protocol active_ip -> preview_ip[:preview_port] [namespace=ns] [preview=service] [port_name=name]\norders:80/TCP 10.0.0.10 -> 10.0.1.10\npayment:443/TCP 10.0.0.20 -> 10.0.1.20\ngrpc:9000/TCP fd00::30 -> [fd00::31]:9001\n"
+		if got := stripGeneratedLine(t, string(data)); got != expected {
+			t.Fatalf("unexpected map contents:\n%s\nwant:\n%s", got, expected)
 		}
 
 		info, err := os.Stat(path)
@@ -441,9 +465,9 @@ func TestWriteDNATMap(t *testing.T) {
 			t.Fatalf("ReadFile: %v", err)
 		}
 
-		expected := "# DNAT mappings generated by ghostwire-init\n# Format: service:port/protocol active_ip -> preview_ip\n"
-		if string(data) != expected {
-			t.Fatalf("unexpected map contents %q", data)
+		expected := "# DNAT mappings generated by ghostwire-init\n# Version: 2\n# Format: service:port/protocol active_ip -> preview_ip[:preview_port] [namespace=ns] [preview=service] [port_name=name]\n"
+		if got := stripGeneratedLine(t, string(data)); got != expected {
+			t.Fatalf("unexpected map contents %q", got)
 		}
 	})
 
@@ -464,6 +488,107 @@ func TestWriteDNATMap(t *testing.T) {
 	})
 }
 
+// stripGeneratedLine removes the generation timestamp header so map contents
+// can be compared exactly.
+func stripGeneratedLine(t *testing.T, contents string) string {
+	t.Helper()
+
+	var kept []string
+	found := false
+	for _, line := range strings.SplitAfter(contents, "\n") {
+		if strings.HasPrefix(line, "# Generated: ") {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !found {
+		t.Fatalf("dnat map is missing the generated header: %q", contents)
+	}
+	return strings.Join(kept, "")
+}
+
+func TestRenderDNATMapProvenance(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10", PreviewServiceName: "orders-preview", PortName: "http"},
+	}
+
+	var buf bytes.Buffer
+	generated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if err := RenderDNATMap(&buf, mappings, generated); err != nil {
+		t.Fatalf("RenderDNATMap returned error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "# Generated: 2024-05-01T10:00:00Z\n") {
+		t.Fatalf("expected UTC generation timestamp, got:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "orders:80/TCP 10.0.0.10 -> 10.0.1.10 namespace=shop preview=orders-preview port_name=http\n") {
+		t.Fatalf("unexpected entry, got:\n%s", out)
+	}
+}
+
+// Not parallel: the skipped rule counters are process-wide.
+func TestSkippedDNATRules(t *testing.T) {
+	ResetSkippedDNATRulesForTest()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "missing", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1"},
+		{ServiceName: "mixed", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "fd00::2"},
+		{ServiceName: "v6", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::3", PreviewClusterIP: "fd00::4"},
+		{ServiceName: "ok", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5", PreviewClusterIP: "10.0.1.5"},
+	}
+	added, err := AddDNATRules(context.Background(), &recordingExecutor{}, "nat", "CANARY_DNAT", mappings, false, discardLogger())
+	if err != nil {
+		t.Fatalf("AddDNATRules returned error: %v", err)
+	}
+	if added != 1 {
+		t.Fatalf("expected 1 rule added, got %d", added)
+	}
+
+	before := SkippedDNATRules()
+	want := map[string]uint64{SkipReasonMissingFields: 1, SkipReasonMixedFamily: 1, SkipReasonIPv6Disabled: 1}
+	for reason, count := range want {
+		if before[reason] != count {
+			t.Fatalf("expected %d skips for %s, got %+v", count, reason, before)
+		}
+	}
+
+	if _, err := AddWholeServiceDNATRules(context.Background(), &recordingExecutor{}, "nat", "CANARY_DNAT", mappings[2:3], false, discardLogger()); err != nil {
+		t.Fatalf("AddWholeServiceDNATRules returned error: %v", err)
+	}
+	if delta := skippedSince(before); len(delta) != 1 || delta[SkipReasonIPv6Disabled] != 1 {
+		t.Fatalf("expected one new ipv6_disabled skip, got %+v", delta)
+	}
+}
+
+func TestAddDNATRulesPreviewPortRemap(t *testing.T) {
+	t.Parallel()
+
+	exec := &recordingExecutor{}
+	mappings := []discovery.ServiceMapping{
+		{
+			ServiceName:      "orders",
+			Port:             80,
+			Protocol:         corev1.ProtocolTCP,
+			ActiveClusterIP:  "10.0.0.10",
+			PreviewClusterIP: "10.0.1.10",
+			PreviewPort:      8080,
+		},
+	}
+
+	if _, err := AddDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", mappings, false, discardLogger()); err != nil {
+		t.Fatalf("AddDNATRules returned error: %v", err)
+	}
+
+	wantArgs := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-d", "10.0.0.10", "-p", "tcp", "--dport", "80", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.1.10:8080"}
+	if len(exec.calls) != 1 || !equalSlices(exec.calls[0].args, wantArgs) {
+		t.Fatalf("expected args %v, got %v", wantArgs, exec.calls)
+	}
+}
+
 func TestAddDNATRulesSCTP(t *testing.T) {
 	t.Parallel()
 
@@ -496,7 +621,7 @@ func TestAddDNATRulesSCTP(t *testing.T) {
 	}
 
 	call := exec.calls[0]
-	wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.30", "-p", "sctp", "--dport", "5000", "-j", "DNAT", "--to-destination", "10.0.1.30:5000"}
+	wantArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.30", "-p", "sctp", "--dport", "5000", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.1.30:5000"}
 	if call.command != ipv4Binary || !equalSlices(call.args, wantArgs) {
 		t.Fatalf("unexpected command %+v", call)
 	}
@@ -639,7 +764,7 @@ func TestSetup(t *testing.T) {
 	t.Run("exclusion error propagates", func(t *testing.T) {
 		exec := &recordingExecutor{
 			runErrors: map[string]error{
-				fmt.Sprintf("%s -w %s -t %s -A %s -d %s -j RETURN", ipv4Binary, iptablesWaitSeconds, "nat", "CANARY_DNAT", "169.254.169.254/32"): fmt.Errorf("exclude failed"),
+				fmt.Sprintf("%s -w %s -t %s -A %s -d %s -m comment --comment ghostwire -j RETURN", ipv4Binary, iptablesWaitSeconds, "nat", "CANARY_DNAT", "169.254.169.254/32"): fmt.Errorf("exclude failed"),
 			},
 		}
 		restore := withExecutorFactory(exec)
@@ -658,7 +783,7 @@ func TestSetup(t *testing.T) {
 	t.Run("dnat rule error propagates", func(t *testing.T) {
 		exec := &recordingExecutor{
 			runErrors: map[string]error{
-				fmt.Sprintf("%s -w %s -t %s -A %s -d %s -p %s --dport %d -j DNAT --to-destination %s:%d", ipv4Binary, iptablesWaitSeconds, "nat", "CANARY_DNAT", "10.0.0.10", "tcp", 80, "10.0.1.10", 80): fmt.Errorf("dnat failed"),
+				fmt.Sprintf("%s -w %s -t %s -A %s -d %s -p %s --dport %d -m comment --comment ghostwire -j DNAT --to-destination %s:%d", ipv4Binary, iptablesWaitSeconds, "nat", "CANARY_DNAT", "10.0.0.10", "tcp", 80, "10.0.1.10", 80): fmt.Errorf("dnat failed"),
 			},
 		}
 		restore := withExecutorFactory(exec)
@@ -718,7 +843,7 @@ func TestAddExclusions(t *testing.T) {
 		if ipv4Call.command != ipv4Binary {
 			t.Fatalf("expected ipv4 command %q, got %q", ipv4Binary, ipv4Call.command)
 		}
-		wantIPv4Args := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.0/24", "-j", "RETURN"}
+		wantIPv4Args := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "10.0.0.0/24", "-m", "comment", "--comment", "ghostwire", "-j", "RETURN"}
 		if !equalSlices(ipv4Call.args, wantIPv4Args) {
 			t.Fatalf("expected ipv4 args %v, got %v", wantIPv4Args, ipv4Call.args)
 		}
@@ -726,7 +851,7 @@ func TestAddExclusions(t *testing.T) {
 		if ipv6Call.command != ipv6Binary {
 			t.Fatalf("expected ipv6 command %q, got %q", ipv6Binary, ipv6Call.command)
 		}
-		wantIPv6Args := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "fd00::/64", "-j", "RETURN"}
+		wantIPv6Args := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", "fd00::/64", "-m", "comment", "--comment", "ghostwire", "-j", "RETURN"}
 		if !equalSlices(ipv6Call.args, wantIPv6Args) {
 			t.Fatalf("expected ipv6 args %v, got %v", wantIPv6Args, ipv6Call.args)
 		}
@@ -815,3 +940,1394 @@ func equalSlices(a, b []string) bool {
 	}
 	return true
 }
+
+func TestAddCTTimeoutRules(t *testing.T) {
+	t.Parallel()
+
+	exec := &recordingExecutor{}
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.53", PreviewClusterIP: "10.0.1.53"},
+		{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.80", PreviewClusterIP: "10.0.1.80"},
+		{ServiceName: "v6", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "fd00::53", PreviewClusterIP: "fd00::153"},
+	}
+
+	added, err := AddCTTimeoutRules(context.Background(), exec, "CANARY_DNAT", "gw-udp", mappings, false, discardLogger())
+	if err != nil {
+		t.Fatalf("AddCTTimeoutRules returned error: %v", err)
+	}
+	if added != 1 {
+		t.Fatalf("expected 1 rule, got %d", added)
+	}
+
+	wantChain := []string{"-w", iptablesWaitSeconds, "-t", "raw", "-N", "CANARY_DNAT"}
+	wantRule := []string{"-w", iptablesWaitSeconds, "-t", "raw", "-A", "CANARY_DNAT", "-d", "10.0.0.53", "-p", "udp", "--dport", "53", "-m", "comment", "--comment", "ghostwire", "-j", "CT", "--timeout", "gw-udp"}
+	if len(exec.calls) != 2 || !equalSlices(exec.calls[0].args, wantChain) || !equalSlices(exec.calls[1].args, wantRule) {
+		t.Fatalf("unexpected calls: %v", exec.calls)
+	}
+}
+
+func TestReadConntrackUsage(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("sys/net/netfilter/nf_conntrack_count", "870\n")
+	write("sys/net/netfilter/nf_conntrack_max", "1000\n")
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 80, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "db", Port: 5432, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2", PreviewEndpoints: []discovery.Endpoint{{IP: "fd00::10", Port: 5432}}},
+	}
+
+	usage, err := ReadConntrackUsage(root, mappings)
+	if err != nil {
+		t.Fatalf("ReadConntrackUsage returned error: %v", err)
+	}
+	if usage.Count != 870 || usage.Max != 1000 || usage.DNATEntriesKnown || usage.Utilization() != 0.87 {
+		t.Fatalf("unexpected usage without a conntrack listing: %+v", usage)
+	}
+
+	write("net/nf_conntrack", strings.Join([]string{
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.2.0.5 dst=10.0.0.1 sport=40000 dport=80 src=10.0.1.1 dst=10.2.0.5 sport=80 dport=40000 [ASSURED] mark=0 zone=0 use=2",
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.2.0.5 dst=10.0.0.1 sport=40001 dport=80 src=10.0.0.1 dst=10.2.0.5 sport=80 dport=40001 [ASSURED] mark=0 zone=0 use=2",
+		"ipv4     2 udp      17 29 src=10.2.0.5 dst=10.96.0.10 sport=5353 dport=53 src=10.96.0.10 dst=10.2.0.5 sport=53 dport=5353 mark=0 zone=0 use=2",
+		"ipv6     10 tcp      6 60 SYN_SENT src=fd00:0000:0000:0000:0000:0000:0000:0009 dst=fd00:0000:0000:0000:0000:0000:0000:0001 sport=40002 dport=5432 [UNREPLIED] src=fd00:0000:0000:0000:0000:0000:0000:0010 dst=fd00:0000:0000:0000:0000:0000:0000:0009 sport=5432 dport=40002 mark=0 zone=0 use=2",
+	}, "\n")+"\n")
+	usage, err = ReadConntrackUsage(root, mappings)
+	if err != nil {
+		t.Fatalf("ReadConntrackUsage returned error: %v", err)
+	}
+	if !usage.DNATEntriesKnown || usage.DNATEntries != 2 {
+		t.Fatalf("expected 2 redirected entries, got %+v", usage)
+	}
+
+	if _, err := ReadConntrackUsage(t.TempDir(), nil); err == nil {
+		t.Fatal("expected an error without the conntrack counters")
+	}
+}
+
+func TestCreateCTTimeoutPolicy(t *testing.T) {
+	t.Parallel()
+
+	wantArgs := []string{"add", "timeout", "gw-udp", "inet", "udp", "unreplied", "10", "replied", "10"}
+	key := "nfct " + strings.Join(wantArgs, " ")
+
+	tests := []struct {
+		name      string
+		runErr    error
+		expectErr bool
+	}{
+		{name: "created"},
+		{name: "already exists", runErr: &CommandError{Command: "nfct", Output: "nfct v1.4.7: netlink error: File exists", Err: errors.New("exit status 1")}},
+		{name: "other failure", runErr: &CommandError{Command: "nfct", Output: "Operation not permitted", Err: errors.New("exit status 1")}, expectErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			exec := &recordingExecutor{}
+			if tc.runErr != nil {
+				exec.runErrors = map[string]error{key: tc.runErr}
+			}
+			err := CreateCTTimeoutPolicy(context.Background(), exec, "gw-udp", 10, discardLogger())
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expectErr=%v, got %v", tc.expectErr, err)
+			}
+			if len(exec.calls) != 1 || exec.calls[0].command != "nfct" || !equalSlices(exec.calls[0].args, wantArgs) {
+				t.Fatalf("unexpected calls: %v", exec.calls)
+			}
+		})
+	}
+}
+
+func TestSplitWholeServiceAndRules(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", IdenticalPorts: true},
+		{ServiceName: "api", Port: 9090, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", IdenticalPorts: true},
+		{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	}
+
+	whole, perPort := SplitWholeService(mappings)
+	if len(whole) != 1 || whole[0].ServiceName != "api" {
+		t.Fatalf("expected api as the single whole-service mapping, got %v", whole)
+	}
+	if len(perPort) != 1 || perPort[0].ServiceName != "web" {
+		t.Fatalf("expected web to keep per-port rules, got %v", perPort)
+	}
+
+	exec := &recordingExecutor{}
+	added, err := AddWholeServiceDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", whole, false, discardLogger())
+	if err != nil {
+		t.Fatalf("AddWholeServiceDNATRules returned error: %v", err)
+	}
+	wantArgs := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-d", "10.0.0.1", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.1.1"}
+	if added != 1 || len(exec.calls) != 1 || !equalSlices(exec.calls[0].args, wantArgs) {
+		t.Fatalf("expected args %v, got %v", wantArgs, exec.calls)
+	}
+}
+
+func TestHairpinRules(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "api", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "v6", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2"},
+	}
+
+	exec := &recordingExecutor{}
+	added, err := AddHairpinMarks(context.Background(), exec, "nat", "CANARY_DNAT", DefaultHairpinMark, mappings, false, discardLogger())
+	if err != nil {
+		t.Fatalf("AddHairpinMarks returned error: %v", err)
+	}
+	wantMark := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-d", "10.0.0.1", "-m", "comment", "--comment", "ghostwire", "-j", "CONNMARK", "--set-xmark", "0x1000000/0x1000000"}
+	if added != 1 || len(exec.calls) != 1 || !equalSlices(exec.calls[0].args, wantMark) {
+		t.Fatalf("expected one mark rule %v, got %v", wantMark, exec.calls)
+	}
+
+	checkKey := "iptables -w " + iptablesWaitSeconds + " -t nat -C POSTROUTING -m connmark --mark 0x1000000/0x1000000 -m comment --comment ghostwire -j MASQUERADE"
+	exec = &recordingExecutor{runErrors: map[string]error{checkKey: errors.New("missing")}}
+	if err := EnsureHairpinMasquerade(context.Background(), exec, DefaultHairpinMark, false, discardLogger()); err != nil {
+		t.Fatalf("EnsureHairpinMasquerade returned error: %v", err)
+	}
+	wantAppend := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "POSTROUTING", "-m", "connmark", "--mark", "0x1000000/0x1000000", "-m", "comment", "--comment", "ghostwire", "-j", "MASQUERADE"}
+	if len(exec.calls) != 2 || !equalSlices(exec.calls[1].args, wantAppend) {
+		t.Fatalf("expected check then append, got %v", exec.calls)
+	}
+
+	exec = &recordingExecutor{}
+	if err := EnsureHairpinMasquerade(context.Background(), exec, DefaultHairpinMark, false, discardLogger()); err != nil {
+		t.Fatalf("EnsureHairpinMasquerade returned error: %v", err)
+	}
+	if len(exec.calls) != 1 {
+		t.Fatalf("expected only the check when the rule exists, got %v", exec.calls)
+	}
+}
+
+func TestAddCgroupExclusions(t *testing.T) {
+	t.Parallel()
+
+	exec := &recordingExecutor{}
+	paths := []string{" /kubepods.slice/agent.scope ", ""}
+	if err := AddCgroupExclusions(context.Background(), exec, "nat", "CANARY_DNAT", paths, true, discardLogger()); err != nil {
+		t.Fatalf("AddCgroupExclusions returned error: %v", err)
+	}
+
+	want := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-m", "cgroup", "--path", "/kubepods.slice/agent.scope", "-m", "comment", "--comment", "ghostwire", "-j", "RETURN"}
+	if len(exec.calls) != 2 {
+		t.Fatalf("expected ipv4 and ipv6 rules, got %v", exec.calls)
+	}
+	if exec.calls[0].command != ipv4Binary || !equalSlices(exec.calls[0].args, want) {
+		t.Fatalf("unexpected ipv4 call: %v", exec.calls[0])
+	}
+	if exec.calls[1].command != ipv6Binary || !equalSlices(exec.calls[1].args, want) {
+		t.Fatalf("unexpected ipv6 call: %v", exec.calls[1])
+	}
+
+	if err := AddCgroupExclusions(context.Background(), &recordingExecutor{}, "nat", "CANARY_DNAT", []string{"/a/../b"}, false, discardLogger()); err == nil {
+		t.Fatalf("expected traversal path to be rejected")
+	}
+}
+
+func TestFilterProtocols(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.53", PreviewClusterIP: "10.0.1.53", IdenticalPorts: true},
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.53", PreviewClusterIP: "10.0.1.53", IdenticalPorts: true},
+		{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.80", PreviewClusterIP: "10.0.1.80", IdenticalPorts: true},
+		{ServiceName: "sig", Port: 3868, Protocol: corev1.ProtocolSCTP, ActiveClusterIP: "10.0.0.38", PreviewClusterIP: "10.0.1.38"},
+	}
+
+	protocols, err := ParseProtocols([]string{"tcp", " TCP "})
+	if err != nil {
+		t.Fatalf("ParseProtocols returned error: %v", err)
+	}
+
+	kept, skipped := FilterProtocols(mappings, protocols, discardLogger())
+	if len(kept) != 2 || kept[0].ServiceName != "dns" || kept[1].ServiceName != "web" {
+		t.Fatalf("unexpected kept mappings: %+v", kept)
+	}
+	if kept[0].IdenticalPorts || !kept[1].IdenticalPorts {
+		t.Fatalf("expected only partially filtered services to lose whole-service eligibility: %+v", kept)
+	}
+	if skipped[corev1.ProtocolUDP] != 1 || skipped[corev1.ProtocolSCTP] != 1 {
+		t.Fatalf("unexpected skipped counts: %v", skipped)
+	}
+	if !mappings[0].IdenticalPorts {
+		t.Fatalf("input mappings must not be modified")
+	}
+
+	if all, _ := FilterProtocols(mappings, nil, discardLogger()); len(all) != len(mappings) {
+		t.Fatalf("expected empty protocol list to keep every mapping")
+	}
+	if _, err := ParseProtocols([]string{"icmp"}); err == nil {
+		t.Fatalf("expected unsupported protocol to be rejected")
+	}
+}
+
+func TestMultiportRules(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "api", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "api", Port: 8080, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewPort: 9090},
+		{ServiceName: "api", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+	}
+	for port := int32(1000); port < 1016; port++ {
+		mappings = append(mappings, discovery.ServiceMapping{ServiceName: "wide", Port: port, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"})
+	}
+
+	groups, rest := GroupMultiport(mappings)
+	if len(groups) != 2 || !equalInt32s(groups[0].Ports, []int32{80, 443}) || len(groups[1].Ports) != 16 {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+	if len(rest) != 2 || rest[0].Port != 8080 || rest[1].Port != 53 {
+		t.Fatalf("unexpected per-port mappings: %+v", rest)
+	}
+
+	exec := &recordingExecutor{}
+	added, err := AddMultiportDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", groups, false, discardLogger())
+	if err != nil {
+		t.Fatalf("AddMultiportDNATRules returned error: %v", err)
+	}
+	if added != 3 {
+		t.Fatalf("expected 3 rules (wide group split at 15 ports), got %d", added)
+	}
+	want := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-d", "10.0.0.1", "-p", "tcp", "-m", "multiport", "--dports", "80,443", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.1.1"}
+	if !equalSlices(exec.calls[0].args, want) {
+		t.Fatalf("unexpected first rule: %v", exec.calls[0].args)
+	}
+	if got := exec.calls[2].args[13]; got != "1015" {
+		t.Fatalf("expected overflow rule to carry the 16th port, got %q", got)
+	}
+}
+
+func equalInt32s(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIPSetExclusions(t *testing.T) {
+	t.Parallel()
+
+	exec := &recordingExecutor{}
+	cidrs := []string{"10.0.0.0/8", " 192.168.0.0/16", "fd00::/8", ""}
+	if err := AddIPSetExclusions(context.Background(), exec, "nat", "CANARY_DNAT", "gw-exclude", cidrs, true, discardLogger()); err != nil {
+		t.Fatalf("AddIPSetExclusions returned error: %v", err)
+	}
+
+	var got []string
+	for _, call := range exec.calls {
+		got = append(got, call.command+" "+strings.Join(call.args, " "))
+	}
+	want := []string{
+		"ipset create gw-exclude hash:net family inet -exist",
+		"ipset create gw-exclude-next hash:net family inet -exist",
+		"ipset flush gw-exclude-next",
+		"ipset add gw-exclude-next 10.0.0.0/8 -exist",
+		"ipset add gw-exclude-next 192.168.0.0/16 -exist",
+		"ipset swap gw-exclude-next gw-exclude",
+		"ipset destroy gw-exclude-next",
+		"iptables -w 5 -t nat -A CANARY_DNAT -m set --match-set gw-exclude dst -m comment --comment ghostwire -j RETURN",
+		"ipset create gw-exclude6 hash:net family inet6 -exist",
+		"ipset create gw-exclude6-next hash:net family inet6 -exist",
+		"ipset flush gw-exclude6-next",
+		"ipset add gw-exclude6-next fd00::/8 -exist",
+		"ipset swap gw-exclude6-next gw-exclude6",
+		"ipset destroy gw-exclude6-next",
+		"ip6tables -w 5 -t nat -A CANARY_DNAT -m set --match-set gw-exclude6 dst -m comment --comment ghostwire -j RETURN",
+	}
+	if !equalSlices(got, want) {
+		t.Fatalf("unexpected calls:\n got: %v\nwant: %v", got, want)
+	}
+
+	missing := &CommandError{Command: ipsetBinary, Output: "ipset v7.17: The set with the given name does not exist", Err: errors.New("exit status 1")}
+	teardown := &recordingExecutor{runErrors: map[string]error{"ipset destroy gw-exclude": missing}}
+	if err := DestroyIPSetExclusions(context.Background(), teardown, "nat", "CANARY_DNAT", "gw-exclude", false, discardLogger()); err != nil {
+		t.Fatalf("expected missing set to be tolerated, got %v", err)
+	}
+	if len(teardown.calls) != 2 || teardown.calls[0].args[4] != "-D" {
+		t.Fatalf("unexpected teardown calls: %v", teardown.calls)
+	}
+
+	failing := &recordingExecutor{runErrors: map[string]error{"ipset destroy gw-exclude": errors.New("in use")}}
+	if err := DestroyIPSetExclusions(context.Background(), failing, "nat", "CANARY_DNAT", "gw-exclude", false, discardLogger()); err == nil {
+		t.Fatalf("expected destroy failure to surface")
+	}
+}
+
+// inputRecordingExecutor records RunInput calls as well, with the input
+// appended after a "<<" marker.
+type inputRecordingExecutor struct {
+	recordingExecutor
+}
+
+func (r *inputRecordingExecutor) RunInput(ctx context.Context, input string, command string, args ...string) error {
+	return r.Run(ctx, command, append(append([]string(nil), args...), "<<", input)...)
+}
+
+func TestIPSetExclusionsRestore(t *testing.T) {
+	t.Parallel()
+
+	exec := &inputRecordingExecutor{}
+	cidrs := []string{"10.0.0.0/8", "192.168.0.0/16"}
+	if err := AddIPSetExclusions(context.Background(), exec, "nat", "CANARY_DNAT", "gw-exclude", cidrs, false, discardLogger()); err != nil {
+		t.Fatalf("AddIPSetExclusions returned error: %v", err)
+	}
+
+	var got []string
+	for _, call := range exec.calls {
+		got = append(got, call.command+" "+strings.Join(call.args, " "))
+	}
+	want := []string{
+		"ipset create gw-exclude hash:net family inet -exist",
+		"ipset create gw-exclude-next hash:net family inet -exist",
+		"ipset flush gw-exclude-next",
+		"ipset restore << add gw-exclude-next 10.0.0.0/8 -exist\nadd gw-exclude-next 192.168.0.0/16 -exist\n",
+		"ipset swap gw-exclude-next gw-exclude",
+		"ipset destroy gw-exclude-next",
+		"iptables -w 5 -t nat -A CANARY_DNAT -m set --match-set gw-exclude dst -m comment --comment ghostwire -j RETURN",
+	}
+	if !equalSlices(got, want) {
+		t.Fatalf("unexpected calls:\n got: %q\nwant: %q", got, want)
+	}
+
+	failing := &inputRecordingExecutor{recordingExecutor{runErrors: map[string]error{
+		"ipset restore << add gw-exclude-next 10.0.0.0/8 -exist\n": errors.New("exit status 1"),
+	}}}
+	if err := AddIPSetExclusions(context.Background(), failing, "nat", "CANARY_DNAT", "gw-exclude", []string{"10.0.0.0/8"}, false, discardLogger()); err == nil {
+		t.Fatalf("expected restore failure to surface")
+	}
+	for _, call := range failing.calls {
+		if len(call.args) > 0 && call.args[0] == "swap" {
+			t.Fatalf("expected no swap after a failed restore, got %v", failing.calls)
+		}
+	}
+}
+
+func TestNotrack(t *testing.T) {
+	t.Parallel()
+
+	excluded := []string{"10.20.0.0/16", "fd00::/8"}
+	if err := ValidateNotrackCIDRs([]string{"10.20.30.0/24", "fd00:1::/32"}, excluded); err != nil {
+		t.Fatalf("expected covered cidrs to validate, got %v", err)
+	}
+	if err := ValidateNotrackCIDRs([]string{"10.0.0.0/8"}, excluded); err == nil {
+		t.Fatalf("expected wider cidr to be rejected")
+	}
+
+	exec := &recordingExecutor{}
+	added, err := SetupNotrack(context.Background(), exec, "CANARY_DNAT", []string{"10.20.30.0/24", "fd00:1::/32"}, false, discardLogger())
+	if err != nil {
+		t.Fatalf("SetupNotrack returned error: %v", err)
+	}
+	if added != 2 {
+		t.Fatalf("expected 2 ipv4 notrack rules, got %d", added)
+	}
+
+	var got []string
+	for _, call := range exec.calls {
+		got = append(got, call.command+" "+strings.Join(call.args, " "))
+	}
+	want := []string{
+		"iptables -w 5 -t raw -N CANARY_DNAT_NT_OUT",
+		"iptables -w 5 -t raw -N CANARY_DNAT_NT_IN",
+		"iptables -w 5 -t raw -A CANARY_DNAT_NT_OUT -d 10.20.30.0/24 -m comment --comment ghostwire -j NOTRACK",
+		"iptables -w 5 -t raw -A CANARY_DNAT_NT_IN -s 10.20.30.0/24 -m comment --comment ghostwire -j NOTRACK",
+	}
+	if len(got) < len(want) || !equalSlices(got[:len(want)], want) {
+		t.Fatalf("unexpected calls:\n got: %v\nwant prefix: %v", got, want)
+	}
+	if last := got[len(got)-1]; last != "iptables -w 5 -t raw -C PREROUTING -m comment --comment ghostwire -j CANARY_DNAT_NT_IN" {
+		t.Fatalf("expected inbound jump check last, got %q", last)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.0.4"},
+		{ServiceName: "v6", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2"},
+	}
+
+	exec := &recordingExecutor{}
+	added, err := SetupMirror(context.Background(), exec, "CANARY_DNAT", []string{"OUTPUT"}, "10.1.0.9", mappings, false, discardLogger())
+	if err != nil {
+		t.Fatalf("SetupMirror returned error: %v", err)
+	}
+	if added != 2 {
+		t.Fatalf("expected 2 ipv4 mirror rules, got %d", added)
+	}
+
+	var got []string
+	for _, call := range exec.calls {
+		got = append(got, call.command+" "+strings.Join(call.args, " "))
+	}
+	want := []string{
+		"iptables -w 5 -t mangle -N CANARY_DNAT_MIRROR",
+		"iptables -w 5 -t mangle -A CANARY_DNAT_MIRROR -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j TEE --gateway 10.1.0.9",
+		"iptables -w 5 -t mangle -A CANARY_DNAT_MIRROR -d 10.0.0.3 -p udp --dport 53 -m comment --comment ghostwire -j TEE --gateway 10.1.0.9",
+		"iptables -w 5 -t mangle -C OUTPUT -m comment --comment ghostwire -j CANARY_DNAT_MIRROR",
+	}
+	if !equalSlices(got, want) {
+		t.Fatalf("unexpected calls:\n got: %v\nwant: %v", got, want)
+	}
+
+	if _, err := SetupMirror(context.Background(), &recordingExecutor{}, "CANARY_DNAT", []string{"OUTPUT"}, "fd00::9", mappings, false, discardLogger()); err == nil {
+		t.Fatalf("expected ipv6 gateway without ipv6 to be rejected")
+	}
+	if _, err := SetupMirror(context.Background(), &recordingExecutor{}, "CANARY_DNAT", []string{"OUTPUT"}, "collector", mappings, false, discardLogger()); err == nil {
+		t.Fatalf("expected non-IP gateway to be rejected")
+	}
+
+	if name := MirrorChainName("A_VERY_LONG_CHAIN_NAME_12345"); len(name) > MaxChainNameLength || !strings.HasSuffix(name, "_MIRROR") {
+		t.Fatalf("unexpected mirror chain name %q", name)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2", PreviewPort: 8080},
+		{ServiceName: "orders-legacy", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5", PreviewClusterIP: "10.0.0.2", PreviewPort: 8080},
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.0.4"},
+	}
+
+	exec := &recordingExecutor{}
+	added, err := SetupReverse(context.Background(), exec, "nat", "CANARY_DNAT", []string{"OUTPUT"}, mappings, false, discardLogger())
+	if err != nil {
+		t.Fatalf("SetupReverse returned error: %v", err)
+	}
+	if added != 2 {
+		t.Fatalf("expected 2 reverse rules, got %d", added)
+	}
+
+	var got []string
+	for _, call := range exec.calls {
+		got = append(got, call.command+" "+strings.Join(call.args, " "))
+	}
+	want := []string{
+		"iptables -w 5 -t nat -N CANARY_DNAT_REVERSE",
+		"iptables -w 5 -t nat -A CANARY_DNAT_REVERSE -d 10.0.0.2 -p tcp --dport 8080 -m comment --comment ghostwire -j DNAT --to-destination 10.0.0.1:80",
+		"iptables -w 5 -t nat -A CANARY_DNAT_REVERSE -d 10.0.0.4 -p udp --dport 53 -m comment --comment ghostwire -j DNAT --to-destination 10.0.0.3:53",
+		"iptables -w 5 -t nat -C OUTPUT -m comment --comment ghostwire -j CANARY_DNAT_REVERSE",
+	}
+	if !equalSlices(got, want) {
+		t.Fatalf("unexpected calls:\n got: %v\nwant: %v", got, want)
+	}
+
+	exec = &recordingExecutor{}
+	if err := RemoveReverse(context.Background(), exec, "nat", "CANARY_DNAT", []string{"OUTPUT"}, false, discardLogger()); err != nil {
+		t.Fatalf("RemoveReverse returned error: %v", err)
+	}
+	if len(exec.calls) == 0 || !slices.Contains(exec.calls[0].args, "CANARY_DNAT_REVERSE") {
+		t.Fatalf("expected the reverse jump to be removed first, got %v", exec.calls)
+	}
+
+	if name := ReverseChainName("A_VERY_LONG_CHAIN_NAME_12345"); len(name) > MaxChainNameLength || !strings.HasSuffix(name, "_REVERSE") {
+		t.Fatalf("unexpected reverse chain name %q", name)
+	}
+}
+
+func TestAddEndpointDNATRules(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "web", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewEndpoints: []discovery.Endpoint{
+			{IP: "10.1.1.11", Port: 8080}, {IP: "10.1.1.12", Port: 8080}, {IP: "10.1.1.13", Port: 8080},
+		}},
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	}
+	endpoints, rest := SplitEndpointMappings(mappings)
+	if len(endpoints) != 1 || len(rest) != 1 || rest[0].ServiceName != "api" {
+		t.Fatalf("unexpected split: %+v / %+v", endpoints, rest)
+	}
+
+	for _, tc := range []struct {
+		balance string
+		want    []string
+	}{
+		{
+			balance: EndpointBalanceRandom,
+			want: []string{
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m statistic --mode random --probability 0.33333 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.11:8080",
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m statistic --mode random --probability 0.50000 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.12:8080",
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.13:8080",
+			},
+		},
+		{
+			balance: EndpointBalanceNth,
+			want: []string{
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m statistic --mode nth --every 3 --packet 0 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.11:8080",
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m statistic --mode nth --every 2 --packet 0 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.12:8080",
+				"iptables -w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.1.1.13:8080",
+			},
+		},
+	} {
+		exec := &recordingExecutor{}
+		added, err := AddEndpointDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", endpoints, tc.balance, false, discardLogger())
+		if err != nil {
+			t.Fatalf("%s: AddEndpointDNATRules returned error: %v", tc.balance, err)
+		}
+		var got []string
+		for _, call := range exec.calls {
+			got = append(got, call.command+" "+strings.Join(call.args, " "))
+		}
+		if added != 3 || !equalSlices(got, tc.want) {
+			t.Fatalf("%s: unexpected calls (%d added):\n got: %v\nwant: %v", tc.balance, added, got, tc.want)
+		}
+	}
+
+	if _, err := ParseEndpointBalance("round-robin"); err == nil {
+		t.Fatalf("expected unknown balance mode to be rejected")
+	}
+
+	exec := &chainListingExecutor{listings: map[string]string{
+		ipv4Binary: "-N CANARY_DNAT\n" +
+			"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -m statistic --mode random --probability 0.50000000000 -j DNAT --to-destination 10.1.1.11:8080\n" +
+			"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.1.1.99:8080\n",
+	}}
+	discrepancies, err := VerifyDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", endpoints, false)
+	if err != nil {
+		t.Fatalf("VerifyDNATRules returned error: %v", err)
+	}
+	kinds := map[string]string{}
+	for _, d := range discrepancies {
+		kinds[d.Kind] += d.Expected + d.Actual + " "
+	}
+	if len(discrepancies) != 3 || kinds[DiscrepancyWrongTarget] != "10.1.1.99:8080 " || kinds[DiscrepancyMissing] != "10.1.1.12:8080 10.1.1.13:8080 " {
+		t.Fatalf("unexpected discrepancies %+v", discrepancies)
+	}
+}
+
+func TestAddSplitRules(t *testing.T) {
+	t.Parallel()
+
+	rulesOf := func(exec *recordingExecutor) []string {
+		var got []string
+		for _, call := range exec.calls {
+			got = append(got, call.command+" "+strings.Join(call.args, " "))
+		}
+		return got
+	}
+
+	exec := &recordingExecutor{}
+	if added, err := AddSplitRules(context.Background(), exec, "nat", "CANARY_DNAT", SplitConfig{}, true, discardLogger()); err != nil || added != 0 || len(exec.calls) != 0 {
+		t.Fatalf("expected no rules without a split, got %d %v %v", added, err, exec.calls)
+	}
+
+	exec = &recordingExecutor{}
+	if _, err := AddSplitRules(context.Background(), exec, "nat", "CANARY_DNAT", SplitConfig{BypassPercent: 75}, false, discardLogger()); err != nil {
+		t.Fatalf("AddSplitRules returned error: %v", err)
+	}
+	want := []string{"iptables -w 5 -t nat -A CANARY_DNAT -m statistic --mode random --probability 0.75000 -m comment --comment ghostwire -j RETURN"}
+	if got := rulesOf(exec); !equalSlices(got, want) {
+		t.Fatalf("unexpected calls:\n got: %v\nwant: %v", got, want)
+	}
+
+	exec = &recordingExecutor{}
+	added, err := AddSplitRules(context.Background(), exec, "nat", "CANARY_DNAT", SplitConfig{BypassPercent: 90, StickyWindow: 10 * time.Minute}, true, discardLogger())
+	if err != nil {
+		t.Fatalf("AddSplitRules returned error: %v", err)
+	}
+	want = []string{
+		"iptables -w 5 -t nat -A CANARY_DNAT -m recent --name CANARY_DNAT_ACTIVE --update --seconds 600 -m comment --comment ghostwire -j RETURN",
+		"iptables -w 5 -t nat -A CANARY_DNAT -m recent ! --rcheck --name CANARY_DNAT_PREVIEW --seconds 600 -m statistic --mode random --probability 0.90000 -m recent --name CANARY_DNAT_ACTIVE --set -m comment --comment ghostwire -j RETURN",
+		"iptables -w 5 -t nat -A CANARY_DNAT -m recent --name CANARY_DNAT_PREVIEW --set -m comment --comment ghostwire",
+	}
+	got := rulesOf(exec)
+	if added != 3 || len(got) != 6 || !equalSlices(got[:3], want) || !strings.HasPrefix(got[3], "ip6tables ") {
+		t.Fatalf("unexpected sticky split calls (%d added):\n got: %v\nwant: %v", added, got, want)
+	}
+
+	for _, split := range []SplitConfig{{BypassPercent: 101}, {BypassPercent: 50, StickyWindow: 500 * time.Millisecond}} {
+		if _, err := AddSplitRules(context.Background(), &recordingExecutor{}, "nat", "CANARY_DNAT", split, false, discardLogger()); err == nil {
+			t.Fatalf("expected %+v to be rejected", split)
+		}
+	}
+}
+
+func TestUpdateSplit(t *testing.T) {
+	t.Parallel()
+
+	exec := &chainListingExecutor{listings: map[string]string{
+		ipv4Binary: "-N CANARY_DNAT\n" +
+			"-A CANARY_DNAT -m recent --update --seconds 600 --name CANARY_DNAT_ACTIVE --mask 255.255.255.255 --rsource -j RETURN\n" +
+			"-A CANARY_DNAT -m recent ! --rcheck --seconds 600 --name CANARY_DNAT_PREVIEW --mask 255.255.255.255 --rsource -m statistic --mode random --probability 0.95000000007 -m recent --set --name CANARY_DNAT_ACTIVE --mask 255.255.255.255 --rsource -j RETURN\n" +
+			"-A CANARY_DNAT -m recent --set --name CANARY_DNAT_PREVIEW --mask 255.255.255.255 --rsource\n" +
+			"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80\n",
+	}}
+	split := SplitConfig{BypassPercent: 75, StickyWindow: 10 * time.Minute, Adjustable: true}
+	if err := UpdateSplit(context.Background(), exec, "nat", "CANARY_DNAT", split, false, discardLogger()); err != nil {
+		t.Fatalf("UpdateSplit returned error: %v", err)
+	}
+	want := "-w 5 -t nat -R CANARY_DNAT 2 -m recent ! --rcheck --name CANARY_DNAT_PREVIEW --seconds 600 -m statistic --mode random --probability 0.75000 -m recent --name CANARY_DNAT_ACTIVE --set -m comment --comment ghostwire -j RETURN"
+	if len(exec.calls) != 1 || strings.Join(exec.calls[0].args, " ") != want {
+		t.Fatalf("unexpected calls: %v\nwant: %s", exec.calls, want)
+	}
+
+	missing := &chainListingExecutor{listings: map[string]string{
+		ipv4Binary: "-N CANARY_DNAT\n-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80\n",
+	}}
+	if err := UpdateSplit(context.Background(), missing, "nat", "CANARY_DNAT", split, false, discardLogger()); err == nil || !strings.Contains(err.Error(), "no split rule") {
+		t.Fatalf("expected a missing split rule error, got %v", err)
+	}
+}
+
+func TestAddDebugLogRule(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := DebugLogConfig{Target: "nflog", Scope: "both", NFLOGGroup: 5}.Validate()
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	exec := &recordingExecutor{}
+	for _, scope := range []string{DebugLogScopeChain, DebugLogScopeUnmatched} {
+		added, err := AddDebugLogRule(context.Background(), exec, "nat", "CANARY_DNAT", cfg, scope, false, discardLogger())
+		if err != nil || !added {
+			t.Fatalf("AddDebugLogRule(%s) = %v, %v", scope, added, err)
+		}
+	}
+
+	want := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-A", "CANARY_DNAT", "-m", "limit", "--limit", "10/second", "-m", "comment", "--comment", "ghostwire", "-j", "NFLOG", "--nflog-prefix", "ghostwire miss: ", "--nflog-group", "5"}
+	if len(exec.calls) != 2 || !equalSlices(exec.calls[1].args, want) {
+		t.Fatalf("unexpected calls: %v", exec.calls)
+	}
+
+	chainOnly, err := DebugLogConfig{Target: "LOG"}.Validate()
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if added, _ := AddDebugLogRule(context.Background(), &recordingExecutor{}, "nat", "CANARY_DNAT", chainOnly, DebugLogScopeUnmatched, false, discardLogger()); added {
+		t.Fatalf("expected default scope to skip the unmatched rule")
+	}
+
+	invalid := []DebugLogConfig{
+		{Target: "ULOG"},
+		{Target: "LOG", Scope: "everything"},
+		{Target: "LOG", Prefix: strings.Repeat("x", 30)},
+	}
+	for _, cfg := range invalid {
+		if _, err := cfg.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestRepairDNATRule(t *testing.T) {
+	t.Parallel()
+
+	recorded := discovery.ServiceMapping{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"}
+	current := recorded
+	current.PreviewClusterIP = "10.0.1.9"
+	missing := &CommandError{Command: ipv4Binary, Err: fakeExitError{code: 1}}
+
+	t.Run("per-port rule replaced", func(t *testing.T) {
+		t.Parallel()
+
+		exec := &recordingExecutor{}
+		if err := RepairDNATRule(context.Background(), exec, "nat", "CANARY_DNAT", recorded, current, false, discardLogger()); err != nil {
+			t.Fatalf("RepairDNATRule returned error: %v", err)
+		}
+		if len(exec.calls) != 3 {
+			t.Fatalf("expected check, add and delete, got %+v", exec.calls)
+		}
+		if got := strings.Join(exec.calls[1].args, " "); got != "-w 5 -t nat -A CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.9:80" {
+			t.Fatalf("unexpected add: %s", got)
+		}
+		if got := strings.Join(exec.calls[2].args, " "); got != "-w 5 -t nat -D CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1:80" {
+			t.Fatalf("unexpected delete: %s", got)
+		}
+	})
+
+	t.Run("whole-service rule replaced", func(t *testing.T) {
+		t.Parallel()
+
+		exec := &recordingExecutor{runErrors: map[string]error{
+			"iptables -w 5 -t nat -C CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1:80": missing,
+		}}
+		if err := RepairDNATRule(context.Background(), exec, "nat", "CANARY_DNAT", recorded, current, false, discardLogger()); err != nil {
+			t.Fatalf("RepairDNATRule returned error: %v", err)
+		}
+		last := exec.calls[len(exec.calls)-1]
+		if got := strings.Join(last.args, " "); got != "-w 5 -t nat -D CANARY_DNAT -d 10.0.0.1 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1" {
+			t.Fatalf("unexpected delete: %s", got)
+		}
+	})
+
+	t.Run("no matching rule", func(t *testing.T) {
+		t.Parallel()
+
+		exec := &recordingExecutor{runErrors: map[string]error{
+			"iptables -w 5 -t nat -C CANARY_DNAT -d 10.0.0.1 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1:80": missing,
+			"iptables -w 5 -t nat -C CANARY_DNAT -d 10.0.0.1 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.1":                      missing,
+		}}
+		if err := RepairDNATRule(context.Background(), exec, "nat", "CANARY_DNAT", recorded, current, false, discardLogger()); err == nil {
+			t.Fatalf("expected error when no rule matches")
+		}
+	})
+}
+
+func TestParseDNATMap(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1", PreviewServiceName: "orders-preview", PortName: "http"},
+		{ServiceName: "api", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2", PreviewPort: 8443},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderDNATMap(&buf, mappings, time.Unix(0, 0)); err != nil {
+		t.Fatalf("RenderDNATMap returned error: %v", err)
+	}
+	got, err := ParseDNATMap(&buf)
+	if err != nil {
+		t.Fatalf("ParseDNATMap returned error: %v", err)
+	}
+	if len(got) != len(mappings) {
+		t.Fatalf("expected %d mappings, got %+v", len(mappings), got)
+	}
+	for i := range mappings {
+		if !reflect.DeepEqual(got[i], mappings[i]) {
+			t.Fatalf("mapping %d round-trip mismatch: got %+v want %+v", i, got[i], mappings[i])
+		}
+	}
+
+	skipped, err := ParseDNATMap(strings.NewReader("orders:http/TCP 10.0.0.1 -> 10.0.1.1\ngarbage line\ncart:80/TCP 10.0.0.2 -> 10.0.1.2\n"))
+	if err != nil {
+		t.Fatalf("ParseDNATMap returned error: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0].ServiceName != "cart" {
+		t.Fatalf("expected malformed entries to be skipped, got %+v", skipped)
+	}
+}
+
+func TestParseDNATMapStrict(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+		token  string
+	}{
+		{name: "missing arrow", input: "# header\norders:80/TCP 10.0.0.1 10.0.1.1 x\n", line: 2, column: 24, token: "10.0.1.1"},
+		{name: "invalid port", input: "orders:http/TCP 10.0.0.1 -> 10.0.1.1\n", line: 1, column: 8, token: "http"},
+		{name: "invalid protocol", input: "orders:80/ICMP 10.0.0.1 -> 10.0.1.1\n", line: 1, column: 11, token: "ICMP"},
+		{name: "invalid active ip", input: "orders:80/TCP  10.0.0 -> 10.0.1.1\n", line: 1, column: 16, token: "10.0.0"},
+		{name: "invalid preview port", input: "orders:80/TCP 10.0.0.1 -> 10.0.1.1:99999\n", line: 1, column: 36, token: "99999"},
+		{name: "bare trailing field", input: "orders:80/TCP 10.0.0.1 -> 10.0.1.1 namespace=shop stray\n", line: 1, column: 51, token: "stray"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseDNATMapStrict(strings.NewReader(tc.input), "dnat.map")
+			var parseErr *config.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Line != tc.line || parseErr.Column != tc.column || parseErr.Token != tc.token {
+				t.Fatalf("expected %d:%d %q, got %d:%d %q (%v)", tc.line, tc.column, tc.token, parseErr.Line, parseErr.Column, parseErr.Token, err)
+			}
+		})
+	}
+
+	got, err := ParseDNATMapStrict(strings.NewReader("# header\norders:80/TCP 10.0.0.1 -> 10.0.1.1:8080 namespace=shop future=1\n"), "dnat.map")
+	if err != nil {
+		t.Fatalf("ParseDNATMapStrict returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].PreviewPort != 8080 || got[0].Namespace != "shop" {
+		t.Fatalf("unexpected mappings %+v", got)
+	}
+}
+
+func TestSetupWithRestoreRecorder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	recorder := NewRestoreRecorder()
+	cfg := Config{
+		ChainName:         "CANARY_DNAT",
+		ExcludeCIDRs:      []string{"169.254.169.254/32"},
+		ExclusionIPSet:    "gw-exclude",
+		HairpinMasquerade: true,
+		DnatMapPath:       filepath.Join(dir, "dnat.map"),
+	}
+	mappings := []discovery.ServiceMapping{{
+		ServiceName:      "orders",
+		Port:             80,
+		Protocol:         corev1.ProtocolTCP,
+		ActiveClusterIP:  "10.0.0.10",
+		PreviewClusterIP: "10.0.1.10",
+	}}
+
+	if err := SetupWithExecutor(context.Background(), recorder, cfg, mappings, discardLogger()); err != nil {
+		t.Fatalf("SetupWithExecutor returned error: %v", err)
+	}
+
+	restorePath := filepath.Join(dir, "rules.v4")
+	if err := recorder.WriteFiles(restorePath); err != nil {
+		t.Fatalf("WriteFiles returned error: %v", err)
+	}
+	// #nosec G304 -- temp dir path is fully controlled by test, no external input.
+	data, err := os.ReadFile(restorePath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"# run first: ipset ",
+		"*nat\n:CANARY_DNAT - [0:0]\n",
+		"-A CANARY_DNAT -m set --match-set gw-exclude dst -m comment --comment ghostwire -j RETURN\n",
+		"-A CANARY_DNAT -d 10.0.0.10 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.10:80\n",
+		"-A POSTROUTING -m connmark --mark 0x1000000/0x1000000 -m comment --comment ghostwire -j MASQUERADE\n",
+		"COMMIT\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("restore file missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, " -C ") || strings.Contains(got, "-w ") {
+		t.Fatalf("restore file should not contain checks or wait flags:\n%s", got)
+	}
+	if _, err := os.Stat(restorePath + ".v6"); !os.IsNotExist(err) {
+		t.Fatalf("expected no ipv6 restore file, got err=%v", err)
+	}
+	if _, err := os.Stat(cfg.DnatMapPath); err != nil {
+		t.Fatalf("expected dnat map to be written: %v", err)
+	}
+}
+
+func TestExportRules(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := Config{
+		ChainName:       "CANARY_DNAT",
+		ExcludeCIDRs:    []string{"169.254.169.254/32"},
+		CTTimeoutPolicy: "gw-udp",
+		DnatMapPath:     filepath.Join(dir, "dnat.map"),
+	}
+	mappings := []discovery.ServiceMapping{{
+		ServiceName:      "dns",
+		Port:             53,
+		Protocol:         corev1.ProtocolUDP,
+		ActiveClusterIP:  "10.0.0.10",
+		PreviewClusterIP: "10.0.1.10",
+	}}
+
+	recorder, err := ExportRules(context.Background(), cfg, mappings, []string{"OUTPUT"}, discardLogger())
+	if err != nil {
+		t.Fatalf("ExportRules returned error: %v", err)
+	}
+
+	got := string(recorder.Render(ipv4Binary))
+	for _, want := range []string{
+		"*raw\n:CANARY_DNAT - [0:0]\n",
+		"-I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT\nCOMMIT\n*nat\n",
+		"-A CANARY_DNAT -d 169.254.169.254/32 -m comment --comment ghostwire -j RETURN\n",
+		"-A CANARY_DNAT -d 10.0.0.10 -p udp --dport 53 -m comment --comment ghostwire -j DNAT --to-destination 10.0.1.10:53\n",
+		"-I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT\nCOMMIT\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("export missing %q:\n%s", want, got)
+		}
+	}
+	if recorder.Render(ipv6Binary) != nil {
+		t.Fatalf("expected no ipv6 rules")
+	}
+	natRules := recorder.Rules(ipv4Binary, "nat")
+	if len(natRules) == 0 || natRules[len(natRules)-1] != "-I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT" {
+		t.Fatalf("expected nat rules ending in the jump, got %v", natRules)
+	}
+	if _, err := os.Stat(cfg.DnatMapPath); !os.IsNotExist(err) {
+		t.Fatalf("expected export to leave the dnat map alone, got err=%v", err)
+	}
+}
+
+func TestExportRulesNFT(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		ChainName:           "CANARY_DNAT",
+		ExcludeCIDRs:        []string{"169.254.169.254/32", "fd00:ec2::254/128"},
+		ExclusionIPSet:      "gw-exclude",
+		IPv6:                true,
+		HairpinMasquerade:   true,
+		CTTimeoutPolicy:     "gw-udp",
+		CTUDPTimeoutSeconds: 30,
+		DnatMapPath:         filepath.Join(t.TempDir(), "dnat.map"),
+	}
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.1.10"},
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2"},
+	}
+
+	recorder, err := ExportRules(context.Background(), cfg, mappings, []string{"OUTPUT"}, discardLogger())
+	if err != nil {
+		t.Fatalf("ExportRules returned error: %v", err)
+	}
+	data, err := recorder.RenderNFT()
+	if err != nil {
+		t.Fatalf("RenderNFT returned error: %v", err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"table ip ghostwire_nat\nflush table ip ghostwire_nat\n",
+		"\tct timeout gw-udp {\n\t\tprotocol udp\n\t\tl3proto ip\n\t\tpolicy = { unreplied: 30s, replied: 30s }\n\t}\n",
+		"udp dport 53 ct timeout set \"gw-udp\" comment \"ghostwire\"\n",
+		"\tchain output {\n\t\ttype nat hook output priority dstnat; policy accept;\n\t\tjump CANARY_DNAT comment \"ghostwire\"\n",
+		"ip daddr 10.0.0.10 udp dport 53 dnat to 10.0.1.10:53 comment \"ghostwire\"\n",
+		"ip6 daddr fd00::1 tcp dport 80 dnat to [fd00::2]:80 comment \"ghostwire\"\n",
+		"type nat hook postrouting priority srcnat; policy accept;\n",
+		"masquerade comment \"ghostwire\"\n",
+		"\tset gw-exclude6 {\n\t\ttype ipv6_addr\n\t\tflags interval\n\t\telements = { fd00:ec2::254/128 }\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("nft export missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "-next") {
+		t.Fatalf("expected staging ipsets swapped away in nft export:\n%s", got)
+	}
+	if strings.Contains(got, "-j ") || strings.Contains(got, "--") {
+		t.Fatalf("expected no iptables syntax in nft export:\n%s", got)
+	}
+
+	unsupported := NewRestoreRecorder()
+	_ = unsupported.Run(context.Background(), ipv4Binary, "-w", "5", "-t", "nat", "-A", "CANARY_DNAT", "-j", "REDIRECT", "--to-ports", "80")
+	if _, err := unsupported.RenderNFT(); err == nil || !strings.Contains(err.Error(), "REDIRECT") {
+		t.Fatalf("expected untranslatable rule to fail, got %v", err)
+	}
+}
+
+// chainListingExecutor serves canned `-S` output per binary.
+type chainListingExecutor struct {
+	recordingExecutor
+	listings map[string]string
+}
+
+func (c *chainListingExecutor) Output(_ context.Context, command string, _ ...string) (string, error) {
+	return c.listings[command], nil
+}
+
+func TestVerifyDNATRules(t *testing.T) {
+	t.Parallel()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "api", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "api", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "db", Port: 5432, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2", PreviewPort: 6432},
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.1.3"},
+		{ServiceName: "cache", Port: 6379, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.4", PreviewClusterIP: "10.0.1.4"},
+		{ServiceName: "web", Port: 8080, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "fd00::1", PreviewClusterIP: "fd00::2"},
+		// The rule writers skip these, so no rule is expected for them.
+		{ServiceName: "mixed", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.5", PreviewClusterIP: "fd00::5"},
+		{ServiceName: "portless", Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.6", PreviewClusterIP: "10.0.1.6"},
+		{ServiceName: "pods", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.7", PreviewClusterIP: "10.0.1.7", PreviewEndpoints: []discovery.Endpoint{{IP: "fd00::7", Port: 8080}}},
+	}
+
+	tests := []struct {
+		name     string
+		ipv4     string
+		ipv6     string
+		withIPv6 bool
+		want     []Discrepancy
+	}{
+		{
+			name: "in sync",
+			ipv4: strings.Join([]string{
+				"-N CANARY_DNAT",
+				"-A CANARY_DNAT -d 10.9.9.9/32 -j RETURN",
+				"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m multiport --dports 80,443 -j DNAT --to-destination 10.0.1.1",
+				"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 5432 -j DNAT --to-destination 10.0.1.2:6432",
+				"-A CANARY_DNAT -d 10.0.0.3/32 -p udp -m udp --dport 53 -j DNAT --to-destination 10.0.1.3:53",
+				"-A CANARY_DNAT -d 10.0.0.4/32 -j DNAT --to-destination 10.0.1.4",
+			}, "\n"),
+			ipv6:     "-A CANARY_DNAT -d fd00::1/128 -p tcp -m tcp --dport 8080 -j DNAT --to-destination [fd00::2]:8080\n",
+			withIPv6: true,
+		},
+		{
+			name: "drifted",
+			ipv4: strings.Join([]string{
+				"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80",
+				"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 5432 -j DNAT --to-destination 10.0.1.2",
+				"-A CANARY_DNAT -d 10.0.0.3/32 -p udp -m udp --dport 53 -j DNAT --to-destination 10.0.1.9:53",
+				"-A CANARY_DNAT -d 10.0.0.4/32 -j DNAT --to-destination 10.0.1.4",
+				"-A CANARY_DNAT -d 10.0.0.8/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.8:80",
+			}, "\n"),
+			want: []Discrepancy{
+				{Kind: DiscrepancyExtra, ActiveIP: "10.0.0.8", Actual: "10.0.1.8:80", Rule: "-A CANARY_DNAT -d 10.0.0.8/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.8:80"},
+				{Kind: DiscrepancyMissing, Service: "api", Port: 443, Protocol: "TCP", ActiveIP: "10.0.0.1", Expected: "10.0.1.1:443"},
+				{Kind: DiscrepancyWrongTarget, Service: "db", Port: 5432, Protocol: "TCP", ActiveIP: "10.0.0.2", Expected: "10.0.1.2:6432", Actual: "10.0.1.2", Rule: "-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 5432 -j DNAT --to-destination 10.0.1.2"},
+				{Kind: DiscrepancyWrongTarget, Service: "dns", Port: 53, Protocol: "UDP", ActiveIP: "10.0.0.3", Expected: "10.0.1.3:53", Actual: "10.0.1.9:53", Rule: "-A CANARY_DNAT -d 10.0.0.3/32 -p udp -m udp --dport 53 -j DNAT --to-destination 10.0.1.9:53"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			executor := &chainListingExecutor{listings: map[string]string{
+				ipv4Binary: tc.ipv4,
+				ipv6Binary: tc.ipv6,
+			}}
+
+			got, err := VerifyDNATRules(context.Background(), executor, "nat", "CANARY_DNAT", mappings, tc.withIPv6)
+			if err != nil {
+				t.Fatalf("VerifyDNATRules returned error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d discrepancies, got %d: %+v", len(tc.want), len(got), got)
+			}
+			for i := range tc.want {
+				if got[i] != tc.want[i] {
+					t.Fatalf("discrepancy %d mismatch:\nwant %+v\ngot  %+v", i, tc.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	listing := strings.Join([]string{
+		"-N CANARY_DNAT",
+		"-A CANARY_DNAT -j LOG --log-prefix ghostwire",
+		"-A CANARY_DNAT -d 169.254.169.254/32 -j RETURN",
+		"-A CANARY_DNAT -m set --match-set gw-exclude dst -j RETURN",
+		"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m multiport --dports 80,443 -j DNAT --to-destination 10.0.1.1",
+		"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 5432 -j DNAT --to-destination 10.0.1.2:6432",
+	}, "\n")
+
+	tests := []struct {
+		name        string
+		ip          string
+		port        int
+		protocol    string
+		wantVerdict string
+		wantTarget  string
+	}{
+		{name: "multiport dnat keeps port", ip: "10.0.0.1", port: 443, protocol: "tcp", wantVerdict: TraceDNAT, wantTarget: "10.0.1.1:443"},
+		{name: "remapped port", ip: "10.0.0.2", port: 5432, protocol: "TCP", wantVerdict: TraceDNAT, wantTarget: "10.0.1.2:6432"},
+		{name: "excluded", ip: "169.254.169.254", port: 80, protocol: "tcp", wantVerdict: TraceExcluded},
+		{name: "protocol mismatch", ip: "10.0.0.1", port: 80, protocol: "udp", wantVerdict: TraceNoMatch},
+		{name: "unmapped port", ip: "10.0.0.2", port: 80, protocol: "tcp", wantVerdict: TraceNoMatch},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			executor := &chainListingExecutor{listings: map[string]string{ipv4Binary: listing}}
+			got, err := Trace(context.Background(), executor, "nat", "OUTPUT", "CANARY_DNAT", net.ParseIP(tc.ip), tc.port, tc.protocol)
+			if err != nil {
+				t.Fatalf("Trace returned error: %v", err)
+			}
+			if got.Verdict != tc.wantVerdict || got.Target != tc.wantTarget {
+				t.Fatalf("unexpected trace: verdict=%s target=%s steps=%+v", got.Verdict, got.Target, got.Steps)
+			}
+			if got.Jump != TraceJumpActive || got.Source != "live" {
+				t.Fatalf("expected live trace with active jump, got %+v", got)
+			}
+			if !got.Steps[0].Matched {
+				t.Fatalf("expected the LOG rule to match and continue, got %+v", got.Steps[0])
+			}
+			if tc.wantVerdict != TraceExcluded && got.Steps[2].Note == "" {
+				t.Fatalf("expected the ipset rule to carry a note, got %+v", got.Steps[2])
+			}
+		})
+	}
+}
+
+func TestHelperExecutor(t *testing.T) {
+	t.Parallel()
+
+	script := `case "$*" in
+*"-L MISSING"*) exit 1 ;;
+*"-L BROKEN"*) echo boom >&2; exit 3 ;;
+esac
+echo "$@"`
+	exec := &HelperExecutor{Path: "/bin/sh", Args: []string{"-c", script, "helper"}}
+	ctx := context.Background()
+
+	out, err := exec.Output(ctx, ipv4Binary, "-t", "nat", "-S", "OUTPUT")
+	if err != nil {
+		t.Fatalf("Output returned error: %v", err)
+	}
+	if out != "iptables -t nat -S OUTPUT\n" {
+		t.Fatalf("expected the helper to receive the command and args, got %q", out)
+	}
+
+	if exists, err := exec.ChainExists(ctx, "nat", "CANARY_DNAT"); err != nil || !exists {
+		t.Fatalf("expected chain to exist, got %v, %v", exists, err)
+	}
+	if exists, err := exec.ChainExists6(ctx, "nat", "MISSING"); err != nil || exists {
+		t.Fatalf("expected missing chain on exit status 1, got %v, %v", exists, err)
+	}
+
+	_, err = exec.ChainExists(ctx, "nat", "BROKEN")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != ipv4Binary || !strings.Contains(cmdErr.Output, "boom") {
+		t.Fatalf("expected CommandError carrying the helper output, got %v", err)
+	}
+}
+
+func TestSignedDNATMap(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dnat.map")
+	key := []byte("s3cret")
+	mappings := []discovery.ServiceMapping{{
+		ServiceName:      "orders",
+		Port:             80,
+		Protocol:         corev1.ProtocolTCP,
+		ActiveClusterIP:  "10.0.0.10",
+		PreviewClusterIP: "10.0.1.10",
+	}}
+	if err := WriteSignedDNATMap(path, mappings, key, discardLogger()); err != nil {
+		t.Fatalf("WriteSignedDNATMap returned error: %v", err)
+	}
+
+	got, err := LoadSignedDNATMap(path, key, true)
+	if err != nil {
+		t.Fatalf("LoadSignedDNATMap returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].PreviewClusterIP != "10.0.1.10" {
+		t.Fatalf("unexpected mappings %+v", got)
+	}
+
+	if _, err := LoadSignedDNATMap(path, []byte("other"), false); !errors.Is(err, ErrDNATMapSignature) {
+		t.Fatalf("wrong key: expected ErrDNATMapSignature, got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read map: %v", err)
+	}
+	tampered := strings.Replace(string(content), "10.0.1.10", "203.0.113.9", 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o644); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+	if _, err := LoadSignedDNATMap(path, key, false); !errors.Is(err, ErrDNATMapSignature) {
+		t.Fatalf("tampered map: expected ErrDNATMapSignature, got %v", err)
+	}
+
+	if err := os.Remove(SignaturePath(path)); err != nil {
+		t.Fatalf("remove signature: %v", err)
+	}
+	if _, err := LoadSignedDNATMap(path, key, false); !errors.Is(err, ErrDNATMapSignature) {
+		t.Fatalf("unsigned map: expected ErrDNATMapSignature, got %v", err)
+	}
+}
+
+func TestRealExecutorRejectsUnsafeCommands(t *testing.T) {
+	t.Parallel()
+
+	executor := NewExecutor()
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{name: "binary outside allowlist", command: "sh", args: []string{"-c", "true"}},
+		{name: "shell metacharacter", command: ipv4Binary, args: []string{"-t", "nat", "-N", "X;reboot"}},
+		{name: "newline", command: ipv4Binary, args: []string{"-t", "nat", "-N", "X\nY"}},
+		{name: "oversized argument", command: ipv4Binary, args: []string{"-N", strings.Repeat("A", maxArgLength+1)}},
+		{name: "too many arguments", command: ipv4Binary, args: make([]string, maxArgs+1)},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := executor.Run(context.Background(), tc.command, tc.args...)
+			if !errors.Is(err, ErrCommandRejected) {
+				t.Fatalf("expected ErrCommandRejected, got %v", err)
+			}
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) || cmdErr.Command != tc.command {
+				t.Fatalf("expected CommandError for %s, got %v", tc.command, err)
+			}
+		})
+	}
+
+	if _, err := executor.ChainExists(context.Background(), "nat", "X$(id)"); !errors.Is(err, ErrCommandRejected) {
+		t.Fatalf("ChainExists: expected ErrCommandRejected, got %v", err)
+	}
+	if err := NewExecutor("true").Run(context.Background(), "true"); err != nil {
+		t.Fatalf("extra binary should be allowed, got %v", err)
+	}
+}
+
+// ruleListingExecutor answers -S listings per chain.
+type ruleListingExecutor struct {
+	recordingExecutor
+	chains map[string]string
+}
+
+func (r *ruleListingExecutor) Output(_ context.Context, _ string, args ...string) (string, error) {
+	return r.chains[args[len(args)-1]], nil
+}
+
+func TestForeignChains(t *testing.T) {
+	t.Parallel()
+
+	exec := &ruleListingExecutor{chains: map[string]string{
+		"OUTPUT": "-P OUTPUT ACCEPT\n" +
+			"-A OUTPUT -j CANARY_DNAT\n" +
+			"-A OUTPUT -j ISTIO_OUTPUT\n" +
+			"-A OUTPUT -j SECOND_DNAT\n" +
+			"-A OUTPUT -j CANARY_DNAT_REVERSE\n" +
+			"-A OUTPUT -d 127.0.0.1/32 -j RETURN\n",
+		"CANARY_DNAT": "-N CANARY_DNAT\n-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.1:80\n",
+		// A reverse chain left jumped by a watcher that exited in reverse
+		// mode is this instance's own.
+		"CANARY_DNAT_REVERSE": "-N CANARY_DNAT_REVERSE\n-A CANARY_DNAT_REVERSE -d 10.0.1.1/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.0.1:80\n",
+		"ISTIO_OUTPUT":        "-N ISTIO_OUTPUT\n-A ISTIO_OUTPUT -p tcp -j REDIRECT --to-ports 15001\n",
+		"SECOND_DNAT":         "-N SECOND_DNAT\n-A SECOND_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.1.2:80\n",
+	}}
+
+	foreign, err := ForeignChains(context.Background(), exec, "nat", []string{"OUTPUT"}, "CANARY_DNAT")
+	if err != nil {
+		t.Fatalf("ForeignChains returned error: %v", err)
+	}
+	if !equalSlices(foreign, []string{"SECOND_DNAT"}) {
+		t.Fatalf("unexpected foreign chains %v", foreign)
+	}
+
+	if _, err := ForeignChains(context.Background(), &recordingExecutor{}, "nat", []string{"OUTPUT"}, "CANARY_DNAT"); err == nil {
+		t.Fatal("expected an error from an executor without listings")
+	}
+}
+
+func TestCommandErrorSentinels(t *testing.T) {
+	t.Parallel()
+
+	status := fakeExitError{code: 1}
+	cases := []struct {
+		name   string
+		err    *CommandError
+		target error
+		want   bool
+	}{
+		{"missing chain listed", NewCommandError(ipv4Binary, []string{"-w", "5", "-t", "nat", "-L", "CANARY_DNAT"}, "", "", status), ErrChainMissing, true},
+		{"missing rule checked", NewCommandError(ipv4Binary, []string{"-w", "5", "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}, "", "", status), ErrRuleMissing, true},
+		{"checked rule is not a chain", NewCommandError(ipv4Binary, []string{"-w", "5", "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}, "", "", status), ErrChainMissing, false},
+		{"chain named by stderr", NewCommandError(ipv4Binary, []string{"-t", "nat", "-A", "CANARY_DNAT"}, "", "iptables: No chain/target/match by that name.\n", fakeExitError{code: 2}), ErrChainMissing, true},
+		{"chain already created", NewCommandError(ipv4Binary, []string{"-t", "nat", "-N", "CANARY_DNAT"}, "", "iptables: Chain already exists.\n", status), ErrRuleExists, true},
+		{"lock held", NewCommandError(ipv4Binary, []string{"-w", "5", "-t", "nat", "-A", "CANARY_DNAT"}, "", "Another app is currently holding the xtables lock. Stopped waiting after 5s.\n", fakeExitError{code: 4}), ErrLockContention, true},
+		{"missing ipset", NewCommandError("ipset", []string{"add", "canary", "10.0.0.1"}, "", "ipset v7.1: The set with the given name does not exist\n", status), ErrSetMissing, true},
+		{"unrelated failure", NewCommandError(ipv4Binary, []string{"-t", "nat", "-A", "CANARY_DNAT"}, "", "iptables v1.8.9: unknown option\n", fakeExitError{code: 2}), ErrRuleMissing, false},
+	}
+	for _, tc := range cases {
+		wrapped := fmt.Errorf("wrapped: %w", tc.err)
+		if got := errors.Is(wrapped, tc.target); got != tc.want {
+			t.Errorf("%s: errors.Is(%v) = %v, want %v", tc.name, tc.target, got, tc.want)
+		}
+	}
+
+	err := NewCommandError(ipv4Binary, []string{"-w", "5", "-A", "CANARY_DNAT", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT"}, "partial\n", "iptables: Bad argument\n", status)
+	if err.Table != "filter" || err.Chain != "CANARY_DNAT" || err.Operation != "append" {
+		t.Fatalf("unexpected context table=%q chain=%q operation=%q", err.Table, err.Chain, err.Operation)
+	}
+	if err.Stdout != "partial\n" || err.Stderr != "iptables: Bad argument\n" || err.Output != "partial\niptables: Bad argument\n" {
+		t.Fatalf("unexpected output split %q / %q / %q", err.Stdout, err.Stderr, err.Output)
+	}
+
+	var cmdErr *CommandError
+	if !errors.As(WithService(err, "orders"), &cmdErr) || cmdErr.Service != "orders" {
+		t.Fatalf("expected the service to be recorded, got %+v", cmdErr)
+	}
+	if WithService(nil, "orders") != nil {
+		t.Fatal("expected a nil error to stay nil")
+	}
+}
+
+func TestOwnershipComments(t *testing.T) {
+	t.Parallel()
+
+	if got := strings.Join(withOwner("-A", "CANARY_DNAT", "-d", "10.0.0.0/8", "-j", "RETURN"), " "); got != "-A CANARY_DNAT -d 10.0.0.0/8 -m comment --comment ghostwire -j RETURN" {
+		t.Fatalf("unexpected tagged rule: %s", got)
+	}
+
+	current := "iptables -w 5 -t nat -C OUTPUT -m comment --comment ghostwire -j CANARY_DNAT"
+	exec := &ruleListingExecutor{
+		recordingExecutor: recordingExecutor{runErrors: map[string]error{
+			current: &CommandError{Command: ipv4Binary, Err: fakeExitError{code: 1}},
+		}},
+		chains: map[string]string{
+			"OUTPUT": "-P OUTPUT ACCEPT\n" +
+				"-A OUTPUT -j CANARY_DNAT\n" +
+				"-A OUTPUT -m comment --comment \"mesh: capture\" -j CANARY_DNAT\n" +
+				"-A OUTPUT -j ISTIO_OUTPUT\n",
+			"CANARY_DNAT": "-N CANARY_DNAT\n" +
+				"-A CANARY_DNAT -d 10.0.0.1/32 -p tcp -m tcp --dport 80 -m comment --comment \"ghostwire\" -j DNAT --to-destination 10.0.1.1:80\n" +
+				"-A CANARY_DNAT -d 10.0.0.2/32 -p tcp -m tcp --dport 80 -m comment --comment other-tool -j DNAT --to-destination 10.0.1.2:80\n",
+		},
+	}
+
+	// A jump from before rules were tagged is ghostwire's to remove; the one
+	// tagged by other tooling is not.
+	if err := RemoveJump(context.Background(), exec, "nat", "OUTPUT", "CANARY_DNAT", false, discardLogger()); err != nil {
+		t.Fatalf("RemoveJump returned error: %v", err)
+	}
+	var deleted []string
+	for _, call := range exec.calls {
+		if call.args[4] == "-D" {
+			deleted = append(deleted, strings.Join(call.args[5:], " "))
+		}
+	}
+	if want := []string{"OUTPUT -j CANARY_DNAT"}; !slices.Equal(deleted, want) {
+		t.Fatalf("expected deletes %q, got %q", want, deleted)
+	}
+
+	// A DNAT rule tagged by other tooling never satisfies a mapping.
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "cart", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+	}
+	discrepancies, err := VerifyDNATRules(context.Background(), exec, "nat", "CANARY_DNAT", mappings, false)
+	if err != nil {
+		t.Fatalf("VerifyDNATRules returned error: %v", err)
+	}
+	if len(discrepancies) != 2 ||
+		discrepancies[0].Kind != DiscrepancyExtra || !strings.Contains(discrepancies[0].Rule, "other-tool") ||
+		discrepancies[1].Kind != DiscrepancyMissing || discrepancies[1].Service != "cart" {
+		t.Fatalf("expected the foreign rule extra and cart missing, got %+v", discrepancies)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 )
 
 // JumpExists determines whether a jump from the provided hook to the target chain exists in the IPv4 table.
@@ -30,6 +31,9 @@ func AddJump(ctx context.Context, executor Executor, table string, hook string,
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if err := validateJumpNames(table, hook, chain); err != nil {
+		return err
+	}
 
 	exists, err := JumpExists(ctx, executor, table, hook, chain)
 	if err != nil {
@@ -52,7 +56,7 @@ func AddJump(ctx context.Context, executor Executor, table string, hook string,
 		slog.String("chain", chain),
 		slog.Bool("ipv6", false),
 	)
-	if err := executor.Run(ctx, ipv4Binary, "-w", iptablesWaitSeconds, "-t", table, "-I", hook, "1", "-j", chain); err != nil {
+	if err := executor.Run(ctx, ipv4Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-I", hook, "1", "-j", chain)...); err != nil {
 		return fmt.Errorf("add ipv4 jump: %w", err)
 	}
 
@@ -85,7 +89,7 @@ func AddJump(ctx context.Context, executor Executor, table string, hook string,
 		slog.String("chain", chain),
 		slog.Bool("ipv6", true),
 	)
-	if err := executor.Run(ctx, ipv6Binary, "-w", iptablesWaitSeconds, "-t", table, "-I", hook, "1", "-j", chain); err != nil {
+	if err := executor.Run(ctx, ipv6Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-I", hook, "1", "-j", chain)...); err != nil {
 		logger.Warn("failed to add ipv6 jump rule",
 			slog.String("table", table),
 			slog.String("hook", hook),
@@ -97,7 +101,9 @@ func AddJump(ctx context.Context, executor Executor, table string, hook string,
 	return nil
 }
 
-// RemoveJump deletes the jump rule from the specified hook, ignoring missing rules.
+// RemoveJump deletes the jump rule from the specified hook, ignoring missing
+// rules. Jumps to chain left by other ghostwire versions, whose ownership
+// comments differ, are removed too when the executor can list rules.
 func RemoveJump(ctx context.Context, executor Executor, table string, hook string, chain string, ipv6 bool, logger *slog.Logger) error {
 	if logger == nil {
 		logger = slog.Default()
@@ -106,6 +112,9 @@ func RemoveJump(ctx context.Context, executor Executor, table string, hook strin
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if err := validateJumpNames(table, hook, chain); err != nil {
+		return err
+	}
 
 	existsV4, err := JumpExists(ctx, executor, table, hook, chain)
 	if err != nil {
@@ -119,7 +128,7 @@ func RemoveJump(ctx context.Context, executor Executor, table string, hook strin
 			slog.String("chain", chain),
 			slog.Bool("ipv6", false),
 		)
-		if err := executor.Run(ctx, ipv4Binary, "-w", iptablesWaitSeconds, "-t", table, "-D", hook, "-j", chain); err != nil {
+		if err := executor.Run(ctx, ipv4Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-D", hook, "-j", chain)...); err != nil {
 			return fmt.Errorf("remove ipv4 jump: %w", err)
 		}
 	} else {
@@ -130,10 +139,21 @@ func RemoveJump(ctx context.Context, executor Executor, table string, hook strin
 			slog.Bool("ipv6", false),
 		)
 	}
+	if err := removeOwnedJumps(ctx, executor, ipv4Binary, table, hook, chain, logger); err != nil {
+		return fmt.Errorf("remove ipv4 jump: %w", err)
+	}
 
 	if !ipv6 {
 		return nil
 	}
+	if err := removeOwnedJumps(ctx, executor, ipv6Binary, table, hook, chain, logger); err != nil {
+		logger.Warn("failed to remove earlier ipv6 jump rules",
+			slog.String("table", table),
+			slog.String("hook", hook),
+			slog.String("chain", chain),
+			slog.Any("error", err),
+		)
+	}
 
 	ipv6Exists, err := jumpExistsWithBinary(ctx, executor, ipv6Binary, table, hook, chain)
 	if err != nil {
@@ -163,7 +183,7 @@ func RemoveJump(ctx context.Context, executor Executor, table string, hook strin
 		slog.String("chain", chain),
 		slog.Bool("ipv6", true),
 	)
-	if err := executor.Run(ctx, ipv6Binary, "-w", iptablesWaitSeconds, "-t", table, "-D", hook, "-j", chain); err != nil {
+	if err := executor.Run(ctx, ipv6Binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-D", hook, "-j", chain)...); err != nil {
 		logger.Warn("failed to remove ipv6 jump rule",
 			slog.String("table", table),
 			slog.String("hook", hook),
@@ -175,17 +195,74 @@ func RemoveJump(ctx context.Context, executor Executor, table string, hook strin
 	return nil
 }
 
+// removeOwnedJumps deletes every jump to chain still in hook that ghostwire
+// owns, such as the untagged ones of releases before rules were tagged. Jumps carrying other tooling's comments are left alone.
+// Executors that cannot list rules skip this.
+func removeOwnedJumps(ctx context.Context, executor Executor, binary string, table string, hook string, chain string, logger *slog.Logger) error {
+	outputExecutor, ok := executor.(OutputExecutor)
+	if !ok {
+		return nil
+	}
+	output, err := outputExecutor.Output(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-S", hook)
+	if err != nil {
+		return fmt.Errorf("list %s rules: %w", hook, err)
+	}
+
+	prefix := "-A " + hook + " "
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) || ruleTarget(line) != chain || !ownedRule(line) {
+			continue
+		}
+		spec := strings.Fields(strings.TrimPrefix(line, prefix))
+		for i := range spec {
+			spec[i] = strings.Trim(spec[i], `"`)
+		}
+		logger.Info("removing earlier jump rule",
+			slog.String("binary", binary),
+			slog.String("table", table),
+			slog.String("hook", hook),
+			slog.String("rule", line),
+		)
+		args := append([]string{"-w", iptablesWaitSeconds, "-t", table, "-D", hook}, spec...)
+		if err := executor.Run(ctx, binary, args...); err != nil && !errors.Is(err, ErrRuleMissing) {
+			return err
+		}
+	}
+	return nil
+}
+
 func jumpExistsWithBinary(ctx context.Context, executor Executor, binary string, table string, hook string, chain string) (bool, error) {
-	if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-C", hook, "-j", chain); err != nil {
-		var cmdErr *CommandError
-		if errors.As(err, &cmdErr) {
-			var exitErr interface{ ExitCode() int }
-			if errors.As(cmdErr.Err, &exitErr) && exitErr.ExitCode() == 1 {
-				return false, nil
-			}
+	if err := executor.Run(ctx, binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-C", hook, "-j", chain)...); err != nil {
+		if errors.Is(err, ErrRuleMissing) {
+			return false, nil
 		}
 		return false, err
 	}
 
 	return true, nil
 }
+
+// AddJumps adds the jump to chain at the top of every hook in hooks, stopping
+// at the first hook that fails.
+func AddJumps(ctx context.Context, executor Executor, table string, hooks []string, chain string, ipv6 bool, logger *slog.Logger) error {
+	for _, hook := range hooks {
+		if err := AddJump(ctx, executor, table, hook, chain, ipv6, logger); err != nil {
+			return fmt.Errorf("hook %s: %w", hook, err)
+		}
+	}
+	return nil
+}
+
+// RemoveJumps removes the jump to chain from every hook in hooks. Every hook
+// is attempted so one failure does not leave the others routing; the errors
+// are returned joined.
+func RemoveJumps(ctx context.Context, executor Executor, table string, hooks []string, chain string, ipv6 bool, logger *slog.Logger) error {
+	var errs []error
+	for _, hook := range hooks {
+		if err := RemoveJump(ctx, executor, table, hook, chain, ipv6, logger); err != nil {
+			errs = append(errs, fmt.Errorf("hook %s: %w", hook, err))
+		}
+	}
+	return errors.Join(errs...)
+}
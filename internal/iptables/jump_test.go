@@ -2,6 +2,9 @@ package iptables
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -50,9 +53,9 @@ func TestAddJumpInsertsRuleWhenMissing(t *testing.T) {
 	ctx := context.Background()
 	exec := &fakeExecutor{
 		responses: map[string]error{
-			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"}): &CommandError{
+			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}): &CommandError{
 				Command: ipv4Binary,
-				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"},
+				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"},
 				Err:     fakeExitError{code: 1},
 			},
 		},
@@ -67,11 +70,99 @@ func TestAddJumpInsertsRuleWhenMissing(t *testing.T) {
 	}
 
 	got := exec.calls[1]
-	if got.command != ipv4Binary || runKey(got.command, got.args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-I", "OUTPUT", "1", "-j", "CANARY_DNAT"}) {
+	if got.command != ipv4Binary || runKey(got.command, got.args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-I", "OUTPUT", "1", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}) {
 		t.Fatalf("unexpected insert command: %#v", got)
 	}
 }
 
+func TestAddJumpsInstallsEveryHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	responses := map[string]error{}
+	for _, hook := range []string{"OUTPUT", "PREROUTING"} {
+		args := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", hook, "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}
+		responses[runKey(ipv4Binary, args)] = &CommandError{Command: ipv4Binary, Args: args, Err: fakeExitError{code: 1}}
+	}
+	exec := &fakeExecutor{responses: responses}
+
+	if err := AddJumps(ctx, exec, "nat", []string{"OUTPUT", "PREROUTING"}, "CANARY_DNAT", false, discardLogger()); err != nil {
+		t.Fatalf("AddJumps returned error: %v", err)
+	}
+
+	var inserted []string
+	for _, call := range exec.calls {
+		if call.args[4] == "-I" {
+			inserted = append(inserted, call.args[5])
+		}
+	}
+	if strings.Join(inserted, ",") != "OUTPUT,PREROUTING" {
+		t.Fatalf("expected jumps inserted into OUTPUT and PREROUTING, got %v", inserted)
+	}
+}
+
+func TestRemoveJumpsAttemptsEveryHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	failing := []string{"-w", iptablesWaitSeconds, "-t", "nat", "-D", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}
+	exec := &fakeExecutor{
+		responses: map[string]error{
+			runKey(ipv4Binary, failing): &CommandError{Command: ipv4Binary, Args: failing, Err: fakeExitError{code: 4}},
+		},
+	}
+
+	err := RemoveJumps(ctx, exec, "nat", []string{"OUTPUT", "PREROUTING"}, "CANARY_DNAT", false, discardLogger())
+	if err == nil || !strings.Contains(err.Error(), "hook OUTPUT") {
+		t.Fatalf("expected OUTPUT removal error, got %v", err)
+	}
+
+	removed := false
+	for _, call := range exec.calls {
+		if call.args[4] == "-D" && call.args[5] == "PREROUTING" {
+			removed = true
+		}
+	}
+	if !removed {
+		t.Fatalf("expected PREROUTING jump removal despite OUTPUT failure, calls: %v", exec.calls)
+	}
+}
+
+func TestAddJumpRejectsUnsafeNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		table string
+		hook  string
+		chain string
+	}{
+		{name: "chain with space", table: "nat", hook: "OUTPUT", chain: "CANARY DNAT"},
+		{name: "chain too long", table: "nat", hook: "OUTPUT", chain: strings.Repeat("C", MaxChainNameLength+1)},
+		{name: "hook with option", table: "nat", hook: "--flush", chain: "CANARY_DNAT"},
+		{name: "empty hook", table: "nat", hook: "", chain: "CANARY_DNAT"},
+		{name: "uppercase table", table: "NAT", hook: "OUTPUT", chain: "CANARY_DNAT"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			exec := &fakeExecutor{}
+			err := AddJump(context.Background(), exec, tc.table, tc.hook, tc.chain, false, discardLogger())
+			if !errors.Is(err, ErrInvalidName) {
+				t.Fatalf("AddJump error = %v, want ErrInvalidName", err)
+			}
+			if err := RemoveJump(context.Background(), exec, tc.table, tc.hook, tc.chain, false, discardLogger()); !errors.Is(err, ErrInvalidName) {
+				t.Fatalf("RemoveJump error = %v, want ErrInvalidName", err)
+			}
+			if len(exec.calls) != 0 {
+				t.Fatalf("expected no commands, got %+v", exec.calls)
+			}
+		})
+	}
+}
+
 func TestAddJumpSkipsWhenPresent(t *testing.T) {
 	t.Parallel()
 
@@ -85,7 +176,7 @@ func TestAddJumpSkipsWhenPresent(t *testing.T) {
 	if len(exec.calls) != 1 {
 		t.Fatalf("expected only the check command, got %d calls", len(exec.calls))
 	}
-	if runKey(exec.calls[0].command, exec.calls[0].args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"}) {
+	if runKey(exec.calls[0].command, exec.calls[0].args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}) {
 		t.Fatalf("unexpected check command: %#v", exec.calls[0])
 	}
 }
@@ -96,14 +187,14 @@ func TestAddJumpAddsIPv6WhenEnabled(t *testing.T) {
 	ctx := context.Background()
 	exec := &fakeExecutor{
 		responses: map[string]error{
-			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"}): &CommandError{
+			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}): &CommandError{
 				Command: ipv4Binary,
-				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"},
+				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"},
 				Err:     fakeExitError{code: 1},
 			},
-			runKey(ipv6Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"}): &CommandError{
+			runKey(ipv6Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}): &CommandError{
 				Command: ipv6Binary,
-				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"},
+				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"},
 				Err:     fakeExitError{code: 1},
 			},
 		},
@@ -116,7 +207,7 @@ func TestAddJumpAddsIPv6WhenEnabled(t *testing.T) {
 	if len(exec.calls) != 4 {
 		t.Fatalf("expected 4 commands with ipv6 enabled, got %d", len(exec.calls))
 	}
-	if runKey(exec.calls[3].command, exec.calls[3].args) != runKey(ipv6Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-I", "OUTPUT", "1", "-j", "CANARY_DNAT"}) {
+	if runKey(exec.calls[3].command, exec.calls[3].args) != runKey(ipv6Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-I", "OUTPUT", "1", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}) {
 		t.Fatalf("unexpected ipv6 insert command: %#v", exec.calls[3])
 	}
 }
@@ -134,7 +225,7 @@ func TestRemoveJumpRemovesRuleWhenPresent(t *testing.T) {
 	if len(exec.calls) != 2 {
 		t.Fatalf("expected check and delete commands, got %d", len(exec.calls))
 	}
-	if runKey(exec.calls[1].command, exec.calls[1].args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-D", "OUTPUT", "-j", "CANARY_DNAT"}) {
+	if runKey(exec.calls[1].command, exec.calls[1].args) != runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-D", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}) {
 		t.Fatalf("unexpected delete command: %#v", exec.calls[1])
 	}
 }
@@ -145,9 +236,9 @@ func TestRemoveJumpNoOpWhenMissing(t *testing.T) {
 	ctx := context.Background()
 	exec := &fakeExecutor{
 		responses: map[string]error{
-			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"}): &CommandError{
+			runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"}): &CommandError{
 				Command: ipv4Binary,
-				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-j", "CANARY_DNAT"},
+				Args:    []string{"-w", iptablesWaitSeconds, "-t", "nat", "-C", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT"},
 				Err:     fakeExitError{code: 1},
 			},
 		},
@@ -161,3 +252,172 @@ func TestRemoveJumpNoOpWhenMissing(t *testing.T) {
 		t.Fatalf("expected only the check command when rule missing, got %d", len(exec.calls))
 	}
 }
+
+// listingExecutor simulates a single hook so positioned inserts can be
+// verified against the resulting rule order.
+type listingExecutor struct {
+	fakeExecutor
+	rules []string
+}
+
+func (l *listingExecutor) Run(ctx context.Context, command string, args ...string) error {
+	if err := l.fakeExecutor.Run(ctx, command, args...); err != nil {
+		return err
+	}
+	for i, arg := range args {
+		switch arg {
+		case "-I":
+			index, _ := strconv.Atoi(args[i+2])
+			target := args[len(args)-1]
+			l.rules = append(l.rules[:index-1], append([]string{target}, l.rules[index-1:]...)...)
+		case "-D":
+			if index, err := strconv.Atoi(args[i+2]); err == nil {
+				l.rules = append(l.rules[:index-1], l.rules[index:]...)
+				break
+			}
+			target := args[len(args)-1]
+			for j, rule := range l.rules {
+				if rule == target {
+					l.rules = append(l.rules[:j], l.rules[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *listingExecutor) Output(_ context.Context, _ string, args ...string) (string, error) {
+	hook := args[len(args)-1]
+	lines := []string{"-P " + hook + " ACCEPT"}
+	for _, target := range l.rules {
+		lines = append(lines, "-A "+hook+" -j "+target)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func TestParseJumpPosition(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		raw     string
+		want    JumpPosition
+		wantErr bool
+	}{
+		{raw: "", want: JumpPosition{Index: 1}},
+		{raw: "3", want: JumpPosition{Index: 3}},
+		{raw: "after:SECURITY", want: JumpPosition{After: "SECURITY"}},
+		{raw: "0", wantErr: true},
+		{raw: "after:", wantErr: true},
+		{raw: "top", wantErr: true},
+		{raw: "after:KUBE;rm", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.raw, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseJumpPosition(tc.raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tc.raw)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("ParseJumpPosition(%q) = %+v, %v; want %+v", tc.raw, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddJumpAtAnchorsAfterChain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	exec := &listingExecutor{rules: []string{"SECURITY", "AUDIT"}}
+	pos := JumpPosition{After: "SECURITY"}
+
+	if err := AddJumpAt(ctx, exec, "nat", "OUTPUT", "CANARY_DNAT", pos, false, discardLogger()); err != nil {
+		t.Fatalf("AddJumpAt returned error: %v", err)
+	}
+	if got := strings.Join(exec.rules, ","); got != "SECURITY,CANARY_DNAT,AUDIT" {
+		t.Fatalf("unexpected rule order: %s", got)
+	}
+
+	// Security tooling re-creates its rule below the jump, which is drift.
+	exec.rules = []string{"CANARY_DNAT", "SECURITY", "AUDIT"}
+	exec.calls = nil
+	holds, err := VerifyJumpPosition(ctx, exec, "nat", "OUTPUT", "CANARY_DNAT", pos)
+	if err != nil || holds {
+		t.Fatalf("expected drift to be detected, holds=%v err=%v", holds, err)
+	}
+
+	if err := AddJumpAt(ctx, exec, "nat", "OUTPUT", "CANARY_DNAT", pos, false, discardLogger()); err != nil {
+		t.Fatalf("AddJumpAt returned error: %v", err)
+	}
+	if got := strings.Join(exec.rules, ","); got != "SECURITY,CANARY_DNAT,AUDIT" {
+		t.Fatalf("expected jump restored below anchor, got %s", got)
+	}
+	// The listing carries no ownership comment, so the misplaced jump has to
+	// be deleted by its rule number.
+	deleteByNumber := runKey(ipv4Binary, []string{"-w", iptablesWaitSeconds, "-t", "nat", "-D", "OUTPUT", "1"})
+	if !slices.ContainsFunc(exec.calls, func(call execCall) bool { return runKey(call.command, call.args) == deleteByNumber }) {
+		t.Fatalf("expected %q, got %+v", deleteByNumber, exec.calls)
+	}
+}
+
+func TestAddJumpAtIndexClampsToHookLength(t *testing.T) {
+	t.Parallel()
+
+	exec := &listingExecutor{rules: []string{"FIRST"}}
+	if err := AddJumpAt(context.Background(), exec, "nat", "OUTPUT", "CANARY_DNAT", JumpPosition{Index: 5}, false, discardLogger()); err != nil {
+		t.Fatalf("AddJumpAt returned error: %v", err)
+	}
+	if got := strings.Join(exec.rules, ","); got != "FIRST,CANARY_DNAT" {
+		t.Fatalf("unexpected rule order: %s", got)
+	}
+}
+
+func TestPromoteChain(t *testing.T) {
+	t.Parallel()
+
+	exec := &recordingExecutor{chainExists: true}
+	if err := PromoteChain(context.Background(), exec, "nat", "CANARY_DNAT", "CANARY_DNAT_NEXT", false, discardLogger()); err != nil {
+		t.Fatalf("PromoteChain returned error: %v", err)
+	}
+
+	want := []string{
+		"-w " + iptablesWaitSeconds + " -t nat -F CANARY_DNAT",
+		"-w " + iptablesWaitSeconds + " -t nat -X CANARY_DNAT",
+		"-w " + iptablesWaitSeconds + " -t nat -E CANARY_DNAT_NEXT CANARY_DNAT",
+	}
+	if len(exec.calls) != len(want) {
+		t.Fatalf("expected %d calls, got %+v", len(want), exec.calls)
+	}
+	for i, call := range exec.calls {
+		if got := strings.Join(call.args, " "); got != want[i] {
+			t.Fatalf("call %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+
+	fresh := &recordingExecutor{}
+	if err := PromoteChain(context.Background(), fresh, "nat", "CANARY_DNAT", "CANARY_DNAT_NEXT", false, discardLogger()); err != nil {
+		t.Fatalf("PromoteChain returned error: %v", err)
+	}
+	if len(fresh.calls) != 1 || !strings.Contains(strings.Join(fresh.calls[0].args, " "), "-E") {
+		t.Fatalf("expected only a rename when the live chain is missing, got %+v", fresh.calls)
+	}
+}
+
+func TestStagingChainName(t *testing.T) {
+	t.Parallel()
+
+	if got := StagingChainName("CANARY_DNAT"); got != "CANARY_DNAT_NEXT" {
+		t.Fatalf("unexpected staging name %q", got)
+	}
+	long := strings.Repeat("X", 28)
+	if got := StagingChainName(long); len(got) != maxChainNameLength || !strings.HasSuffix(got, "_NEXT") {
+		t.Fatalf("expected trimmed staging name, got %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// MirrorTable holds the TEE rules: TEE is only valid in the mangle table.
+const MirrorTable = "mangle"
+
+const mirrorSuffix = "_MIRROR"
+
+// MirrorChainName returns the mangle-table chain holding the TEE rules that
+// copy traffic bound for chain's active Services. Long names are trimmed so
+// the suffix still fits.
+func MirrorChainName(chain string) string {
+	if len(chain)+len(mirrorSuffix) > maxChainNameLength {
+		chain = chain[:maxChainNameLength-len(mirrorSuffix)]
+	}
+	return chain + mirrorSuffix
+}
+
+// SetupMirror rebuilds the mirror chain of chain with one TEE rule per active
+// ClusterIP and port, cloning those packets to gateway, and jumps to it from
+// every hook. The original packets are untouched, so the active Services keep
+// serving the traffic. Mappings of the other address family than gateway are
+// skipped. Returns the number of TEE rules added.
+func SetupMirror(ctx context.Context, executor Executor, chain string, hooks []string, gateway string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
+	gatewayIP := net.ParseIP(gateway)
+	if gatewayIP == nil {
+		return 0, fmt.Errorf("mirror gateway %q is not an IP address", gateway)
+	}
+	bin := ipv4Binary
+	if gatewayIP.To4() == nil {
+		if !ipv6 {
+			return 0, fmt.Errorf("mirror gateway %s is IPv6 but ipv6 is disabled", gateway)
+		}
+		bin = ipv6Binary
+	}
+
+	mirrorChain := MirrorChainName(chain)
+	if err := EnsureChain(ctx, executor, MirrorTable, mirrorChain, ipv6, logger); err != nil {
+		return 0, fmt.Errorf("prepare mirror chain: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	added := 0
+	for _, mapping := range mappings {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+		if mapping.ActiveClusterIP == "" || isIPv6(mapping.ActiveClusterIP) != (bin == ipv6Binary) {
+			continue
+		}
+		protocol := strings.ToLower(string(mapping.Protocol))
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		port := strconv.Itoa(int(mapping.Port))
+		key := mapping.ActiveClusterIP + "/" + protocol + "/" + port
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		logger.Debug("adding mirror rule", slog.String("active_ip", mapping.ActiveClusterIP), slog.String("protocol", protocol), slog.String("port", port), slog.String("gateway", gateway))
+		if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", MirrorTable, "-A", mirrorChain, "-d", mapping.ActiveClusterIP, "-p", protocol, "--dport", port, "-j", "TEE", "--gateway", gateway)...); err != nil {
+			return added, fmt.Errorf("add mirror rule for %s: %w", key, err)
+		}
+		added++
+	}
+
+	if err := AddJumps(ctx, executor, MirrorTable, hooks, mirrorChain, ipv6, logger); err != nil {
+		return added, fmt.Errorf("jump to %s: %w", mirrorChain, err)
+	}
+	logger.Info("traffic mirror installed", slog.String("chain", mirrorChain), slog.String("gateway", gateway), slog.Int("rules", added))
+	return added, nil
+}
+
+// RemoveMirror removes the jumps to the mirror chain of chain from every hook
+// and flushes the chain, stopping the copies.
+func RemoveMirror(ctx context.Context, executor Executor, chain string, hooks []string, ipv6 bool, logger *slog.Logger) error {
+	mirrorChain := MirrorChainName(chain)
+	if err := RemoveJumps(ctx, executor, MirrorTable, hooks, mirrorChain, ipv6, logger); err != nil {
+		return fmt.Errorf("remove jump to %s: %w", mirrorChain, err)
+	}
+	if err := EnsureChain(ctx, executor, MirrorTable, mirrorChain, ipv6, logger); err != nil {
+		return fmt.Errorf("flush mirror chain: %w", err)
+	}
+	logger.Info("traffic mirror removed", slog.String("chain", mirrorChain))
+	return nil
+}
@@ -0,0 +1,132 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// maxMultiportPorts is the number of ports the multiport match accepts in a
+// single rule.
+const maxMultiportPorts = 15
+
+// MultiportGroup collects the ports sharing one active/preview IP pair and
+// protocol that can be redirected by a single multiport rule.
+type MultiportGroup struct {
+	ServiceName      string
+	Protocol         corev1.Protocol
+	ActiveClusterIP  string
+	PreviewClusterIP string
+	Ports            []int32
+}
+
+// GroupMultiport partitions mappings into multiport groups and the mappings
+// that still need per-port rules. Only mappings whose preview port equals the
+// matched port are grouped, and a group needs at least two ports to be worth
+// a multiport rule.
+func GroupMultiport(mappings []discovery.ServiceMapping) ([]MultiportGroup, []discovery.ServiceMapping) {
+	type groupKey struct {
+		protocol corev1.Protocol
+		active   string
+		preview  string
+	}
+
+	var order []groupKey
+	members := make(map[groupKey][]discovery.ServiceMapping)
+	var rest []discovery.ServiceMapping
+	for _, mapping := range mappings {
+		if mapping.ActiveClusterIP == "" || mapping.PreviewClusterIP == "" || mapping.Port == 0 || mapping.TargetPort() != mapping.Port {
+			rest = append(rest, mapping)
+			continue
+		}
+		protocol := mapping.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		key := groupKey{protocol: protocol, active: mapping.ActiveClusterIP, preview: mapping.PreviewClusterIP}
+		if _, seen := members[key]; !seen {
+			order = append(order, key)
+		}
+		members[key] = append(members[key], mapping)
+	}
+
+	var groups []MultiportGroup
+	for _, key := range order {
+		grouped := members[key]
+		if len(grouped) < 2 {
+			rest = append(rest, grouped...)
+			continue
+		}
+		group := MultiportGroup{
+			ServiceName:      grouped[0].ServiceName,
+			Protocol:         key.protocol,
+			ActiveClusterIP:  key.active,
+			PreviewClusterIP: key.preview,
+		}
+		seenPorts := make(map[int32]bool)
+		for _, mapping := range grouped {
+			if !seenPorts[mapping.Port] {
+				seenPorts[mapping.Port] = true
+				group.Ports = append(group.Ports, mapping.Port)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rest
+}
+
+// AddMultiportDNATRules emits one `-m multiport --dports` DNAT rule per group,
+// splitting groups that exceed the multiport port limit.
+func AddMultiportDNATRules(ctx context.Context, executor Executor, table string, chain string, groups []MultiportGroup, ipv6 bool, logger *slog.Logger) (int, error) {
+	added := 0
+	for _, group := range groups {
+		isActiveV6 := isIPv6(group.ActiveClusterIP)
+		if isActiveV6 != isIPv6(group.PreviewClusterIP) {
+			recordSkippedDNATRule(SkipReasonMixedFamily)
+			logger.Warn("skipping dnat rule due to mixed IP families", slog.String("service", group.ServiceName), slog.String("active_ip", group.ActiveClusterIP), slog.String("preview_ip", group.PreviewClusterIP))
+			continue
+		}
+
+		bin := ipv4Binary
+		if isActiveV6 {
+			if !ipv6 {
+				recordSkippedDNATRule(SkipReasonIPv6Disabled)
+				logger.Warn("skipping ipv6 dnat rule without ipv6 support", slog.String("service", group.ServiceName), slog.String("active_ip", group.ActiveClusterIP), slog.String("preview_ip", group.PreviewClusterIP))
+				continue
+			}
+			bin = ipv6Binary
+		}
+
+		protocol := strings.ToLower(string(group.Protocol))
+		for start := 0; start < len(group.Ports); start += maxMultiportPorts {
+			if err := ctx.Err(); err != nil {
+				return added, err
+			}
+
+			end := start + maxMultiportPorts
+			if end > len(group.Ports) {
+				end = len(group.Ports)
+			}
+			ports := make([]string, 0, end-start)
+			for _, port := range group.Ports[start:end] {
+				ports = append(ports, strconv.Itoa(int(port)))
+			}
+			dports := strings.Join(ports, ",")
+
+			logger.Info("adding multiport dnat rule", slog.String("service", group.ServiceName), slog.String("ports", dports), slog.String("protocol", protocol), slog.String("active_ip", group.ActiveClusterIP), slog.String("preview_ip", group.PreviewClusterIP), slog.Bool("ipv6", isActiveV6))
+			if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", group.ActiveClusterIP, "-p", protocol, "-m", "multiport", "--dports", dports, "-j", "DNAT", "--to-destination", group.PreviewClusterIP)...); err != nil {
+				return added, fmt.Errorf("add multiport dnat rule for %s: %w", group.ServiceName, err)
+			}
+			added++
+		}
+	}
+
+	return added, nil
+}
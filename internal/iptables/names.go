@@ -0,0 +1,63 @@
+package iptables
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// MaxChainNameLength is the kernel's limit for chain names
+	// (XT_EXTENSION_MAXNAMELEN minus the terminating NUL). Hooks are chains
+	// too and share it.
+	MaxChainNameLength = 28
+	// MaxTableNameLength is the kernel's limit for table names
+	// (XT_TABLE_MAXNAMELEN minus the terminating NUL).
+	MaxTableNameLength = 31
+)
+
+// ErrInvalidName reports a chain, hook or table name outside the safe
+// charset.
+var ErrInvalidName = errors.New("invalid name")
+
+var (
+	// A leading '-' would be read as an iptables option.
+	chainNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	tableNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// ValidateChainName checks that name is 1-28 letters, digits, '-' or '_',
+// starting with a letter or digit. Chain names reach iptables as argv, so
+// anything else is refused before a command is built.
+func ValidateChainName(name string) error {
+	return validateName("chain", name, chainNamePattern, MaxChainNameLength, "letters, digits, '-' or '_' (not starting with '-')")
+}
+
+// ValidateHookName checks a jump hook like ValidateChainName.
+func ValidateHookName(name string) error {
+	return validateName("hook", name, chainNamePattern, MaxChainNameLength, "letters, digits, '-' or '_' (not starting with '-')")
+}
+
+// ValidateTableName checks that name is 1-31 lowercase letters, digits or
+// '_'.
+func ValidateTableName(name string) error {
+	return validateName("table", name, tableNamePattern, MaxTableNameLength, "lowercase letters, digits or '_'")
+}
+
+func validateName(kind, name string, pattern *regexp.Regexp, maxLength int, charset string) error {
+	if len(name) > maxLength || !pattern.MatchString(name) {
+		return fmt.Errorf("%w: %s %q must be 1-%d %s", ErrInvalidName, kind, name, maxLength, charset)
+	}
+	return nil
+}
+
+// validateJumpNames checks the table, hook and chain of a jump rule.
+func validateJumpNames(table, hook, chain string) error {
+	if err := ValidateTableName(table); err != nil {
+		return err
+	}
+	if err := ValidateHookName(hook); err != nil {
+		return err
+	}
+	return ValidateChainName(chain)
+}
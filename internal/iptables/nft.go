@@ -0,0 +1,421 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// nftTablePrefix names the nftables tables the nft export defines: one per
+// iptables table and family, e.g. "ip ghostwire_nat". Keeping them apart from
+// the iptables-nft tables lets both coexist during a migration.
+const nftTablePrefix = "ghostwire_"
+
+// nftHooks maps the iptables built-in chains to nftables hooks.
+var nftHooks = map[string]string{
+	"PREROUTING":  "prerouting",
+	"INPUT":       "input",
+	"FORWARD":     "forward",
+	"OUTPUT":      "output",
+	"POSTROUTING": "postrouting",
+}
+
+// nftBaseChain returns the type and priority that give a base chain the
+// place of table's built-in chain for hook.
+func nftBaseChain(table, hook string) (string, string) {
+	switch table {
+	case "nat":
+		if hook == "postrouting" || hook == "input" {
+			return "nat", "srcnat"
+		}
+		return "nat", "dstnat"
+	case "raw", "mangle":
+		return "filter", table
+	default:
+		return "filter", "filter"
+	}
+}
+
+type nftSet struct {
+	family   string
+	elements []string
+}
+
+type nftTimeout struct {
+	protocol string
+	policy   []string
+}
+
+type nftTable struct {
+	chains   []string
+	rules    map[string][]string
+	sets     []string
+	timeouts []string
+}
+
+// RenderNFT returns the recorded rules of both families as an nftables
+// ruleset for `nft -f`. Each iptables table becomes an nftables table of its
+// own that is flushed and redefined, so applying the file again replaces the
+// previous ruleset. Built-in chains become base chains at the priority of
+// the iptables table they stand for. ipsets and nfct timeout policies the
+// rules reference are declared in the tables that use them. Rules without an
+// nftables equivalent are an error rather than being dropped. It returns nil
+// when nothing was recorded.
+func (r *RestoreRecorder) RenderNFT() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sets := map[string]*nftSet{}
+	timeouts := map[string]*nftTimeout{}
+	for _, cmd := range r.commands {
+		switch {
+		case cmd.binary == ipsetBinary && len(cmd.args) >= 5 && cmd.args[0] == "create":
+			sets[cmd.args[1]] = &nftSet{family: cmd.args[4]}
+		case cmd.binary == ipsetBinary && len(cmd.args) >= 3 && cmd.args[0] == "add":
+			if set, ok := sets[cmd.args[1]]; ok {
+				set.elements = append(set.elements, cmd.args[2])
+			}
+		case cmd.binary == ipsetBinary && len(cmd.args) >= 3 && cmd.args[0] == "swap":
+			sets[cmd.args[1]], sets[cmd.args[2]] = sets[cmd.args[2]], sets[cmd.args[1]]
+		case cmd.binary == ipsetBinary && len(cmd.args) >= 2 && cmd.args[0] == "destroy":
+			delete(sets, cmd.args[1])
+		case cmd.binary == nfctBinary && len(cmd.args) >= 5 && cmd.args[0] == "add" && cmd.args[1] == "timeout":
+			timeout := &nftTimeout{protocol: cmd.args[4]}
+			for i := 5; i+1 < len(cmd.args); i += 2 {
+				timeout.policy = append(timeout.policy, fmt.Sprintf("%s: %ss", cmd.args[i], cmd.args[i+1]))
+			}
+			timeouts[cmd.args[2]] = timeout
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, family := range []struct{ name, binary string }{{"ip", ipv4Binary}, {"ip6", ipv6Binary}} {
+		tables := map[string]*nftTable{}
+		table := func(name string) *nftTable {
+			if tables[name] == nil {
+				tables[name] = &nftTable{rules: map[string][]string{}}
+			}
+			return tables[name]
+		}
+		addChain := func(t *nftTable, chain string) {
+			for _, existing := range t.chains {
+				if existing == chain {
+					return
+				}
+			}
+			t.chains = append(t.chains, chain)
+		}
+
+		for _, cmd := range r.commands {
+			if cmd.binary != family.binary {
+				continue
+			}
+			tableName, op, rest := splitRecordedArgs(cmd.args)
+			if len(rest) == 0 {
+				continue
+			}
+			switch op {
+			case "-N":
+				addChain(table(tableName), rest[0])
+			case "-A", "-I":
+				t := table(tableName)
+				chain, matches := rest[0], rest[1:]
+				insertAt := -1
+				if op == "-I" {
+					insertAt = 0
+					if len(matches) > 0 && isRuleNumber(matches[0]) {
+						fmt.Sscanf(matches[0], "%d", &insertAt)
+						insertAt--
+						matches = matches[1:]
+					}
+				}
+				rule, err := nftRule(family.name, matches, t)
+				if err != nil {
+					return nil, fmt.Errorf("%s -t %s %s %s: %w", cmd.binary, tableName, op, strings.Join(rest, " "), err)
+				}
+				addChain(t, chain)
+				rules := t.rules[chain]
+				if insertAt < 0 || insertAt > len(rules) {
+					t.rules[chain] = append(rules, rule)
+				} else {
+					t.rules[chain] = append(rules[:insertAt], append([]string{rule}, rules[insertAt:]...)...)
+				}
+			}
+		}
+
+		for _, tableName := range restoreTables {
+			t := tables[tableName]
+			if t == nil {
+				continue
+			}
+			if buf.Len() == 0 {
+				buf.WriteString("#!/usr/sbin/nft -f\n# Generated by ghostwire; apply with nft -f\n")
+			}
+			qualified := family.name + " " + nftTablePrefix + tableName
+			fmt.Fprintf(&buf, "\ntable %s\nflush table %s\n\ntable %s {\n", qualified, qualified, qualified)
+			for _, name := range t.sets {
+				set, ok := sets[name]
+				if !ok {
+					return nil, fmt.Errorf("ipset %s is referenced but never created", name)
+				}
+				elementType := "ipv4_addr"
+				if set.family == "inet6" {
+					elementType = "ipv6_addr"
+				}
+				fmt.Fprintf(&buf, "\tset %s {\n\t\ttype %s\n\t\tflags interval\n", name, elementType)
+				if len(set.elements) > 0 {
+					fmt.Fprintf(&buf, "\t\telements = { %s }\n", strings.Join(set.elements, ", "))
+				}
+				buf.WriteString("\t}\n\n")
+			}
+			for _, name := range t.timeouts {
+				timeout, ok := timeouts[name]
+				if !ok {
+					return nil, fmt.Errorf("conntrack timeout policy %s is not created by ghostwire (set ct-udp-timeout); nftables needs it declared in the table", name)
+				}
+				fmt.Fprintf(&buf, "\tct timeout %s {\n\t\tprotocol %s\n\t\tl3proto %s\n\t\tpolicy = { %s }\n\t}\n\n", name, timeout.protocol, family.name, strings.Join(timeout.policy, ", "))
+			}
+			for _, chain := range t.chains {
+				fmt.Fprintf(&buf, "\tchain %s {\n", nftChainName(chain))
+				if hook, ok := nftHooks[chain]; ok {
+					chainType, priority := nftBaseChain(tableName, hook)
+					fmt.Fprintf(&buf, "\t\ttype %s hook %s priority %s; policy accept;\n", chainType, hook, priority)
+				}
+				for _, rule := range t.rules[chain] {
+					fmt.Fprintf(&buf, "\t\t%s\n", rule)
+				}
+				buf.WriteString("\t}\n")
+			}
+			buf.WriteString("}\n")
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteNFTFile renders both families in nft syntax to path.
+func (r *RestoreRecorder) WriteNFTFile(path string) error {
+	if err := validateDNATMapPath(path); err != nil {
+		return err
+	}
+	data, err := r.RenderNFT()
+	if err != nil {
+		return err
+	}
+	// #nosec G306 -- rule files live on an operator-configured shared volume for the applier to read.
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write nft file %s: %w", path, err)
+	}
+	return nil
+}
+
+// nftChainName keeps user chain names and lowercases built-in ones, the
+// usual spelling of nftables base chains.
+func nftChainName(chain string) string {
+	if hook, ok := nftHooks[chain]; ok {
+		return hook
+	}
+	return chain
+}
+
+// nftRule translates the match and target arguments of one iptables rule.
+// Sets and timeout policies it references are recorded on t.
+func nftRule(family string, args []string, t *nftTable) (string, error) {
+	var exprs []string
+	protoIdx := -1
+	proto := ""
+	target := ""
+	every := ""
+	options := map[string]string{}
+
+	next := func(i int) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("%s needs a value", args[i])
+		}
+		return args[i+1], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-m":
+			// Matches are translated through their options.
+			i++
+			continue
+		case "--match-set":
+			if i+2 >= len(args) {
+				return "", fmt.Errorf("--match-set needs a set and a direction")
+			}
+			name, direction := args[i+1], args[i+2]
+			field := "daddr"
+			if direction == "src" {
+				field = "saddr"
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s @%s", family, field, name))
+			appendUnique(&t.sets, name)
+			i += 2
+			continue
+		}
+
+		value, err := next(i)
+		if err != nil {
+			return "", err
+		}
+		i++
+		switch arg {
+		case "-d":
+			exprs = append(exprs, family+" daddr "+value)
+		case "-s":
+			exprs = append(exprs, family+" saddr "+value)
+		case "-p":
+			proto = strings.ToLower(value)
+			protoIdx = len(exprs)
+			exprs = append(exprs, "meta l4proto "+proto)
+		case "--dport", "--dports":
+			if protoIdx < 0 {
+				return "", fmt.Errorf("%s without -p", arg)
+			}
+			ports := strings.ReplaceAll(value, ":", "-")
+			if strings.Contains(ports, ",") {
+				ports = "{ " + strings.Join(strings.Split(ports, ","), ", ") + " }"
+			}
+			exprs[protoIdx] = proto + " dport " + ports
+		case "--path":
+			path := strings.Trim(value, "/")
+			exprs = append(exprs, fmt.Sprintf("socket cgroupv2 level %d %q", strings.Count(path, "/")+1, path))
+		case "--limit":
+			exprs = append(exprs, "limit rate "+value)
+		case "--mark":
+			mark, mask, ok := strings.Cut(value, "/")
+			if !ok {
+				exprs = append(exprs, "ct mark "+mark)
+			} else {
+				exprs = append(exprs, fmt.Sprintf("ct mark and %s == %s", mask, mark))
+			}
+		case "--mode":
+			if value != "random" && value != "nth" {
+				return "", fmt.Errorf("no nftables translation for statistic mode %s", value)
+			}
+		case "--probability":
+			probability, err := strconv.ParseFloat(value, 64)
+			if err != nil || probability <= 0 {
+				return "", fmt.Errorf("invalid probability %s", value)
+			}
+			modulus := math.Round(1 / probability)
+			if math.Abs(1/modulus-probability) > 1e-4 {
+				return "", fmt.Errorf("no nftables translation for probability %s", value)
+			}
+			exprs = append(exprs, fmt.Sprintf("numgen random mod %d == 0", int(modulus)))
+		case "--every":
+			every = value
+		case "--packet":
+			exprs = append(exprs, fmt.Sprintf("numgen inc mod %s == %s", every, value))
+		case "--comment":
+			options[arg] = value
+		case "-j":
+			target = value
+		default:
+			if !strings.HasPrefix(arg, "--") || target == "" {
+				return "", fmt.Errorf("no nftables translation for %s", arg)
+			}
+			options[arg] = value
+		}
+	}
+
+	verdict, err := nftVerdict(family, target, options, t)
+	if err != nil {
+		return "", err
+	}
+	exprs = append(exprs, verdict)
+	if comment, ok := options["--comment"]; ok {
+		exprs = append(exprs, fmt.Sprintf("comment %q", comment))
+	}
+	return strings.Join(exprs, " "), nil
+}
+
+// nftVerdict translates an iptables target and its options.
+func nftVerdict(family, target string, options map[string]string, t *nftTable) (string, error) {
+	switch target {
+	case "":
+		return "", fmt.Errorf("rule has no target")
+	case "RETURN":
+		return "return", nil
+	case "ACCEPT", "DROP":
+		return strings.ToLower(target), nil
+	case "MASQUERADE":
+		return "masquerade", nil
+	case "NOTRACK":
+		return "notrack", nil
+	case "DNAT":
+		return "dnat to " + nftAddress(family, options["--to-destination"]), nil
+	case "TEE":
+		return "dup to " + options["--gateway"], nil
+	case "CONNMARK":
+		value, mask, ok := strings.Cut(options["--set-xmark"], "/")
+		if !ok || value != mask {
+			return "", fmt.Errorf("no nftables translation for CONNMARK --set-xmark %s", options["--set-xmark"])
+		}
+		return "ct mark set ct mark or " + value, nil
+	case "CT":
+		policy, ok := options["--timeout"]
+		if !ok {
+			return "", fmt.Errorf("no nftables translation for CT without --timeout")
+		}
+		appendUnique(&t.timeouts, policy)
+		return fmt.Sprintf("ct timeout set %q", policy), nil
+	case "LOG":
+		return fmt.Sprintf("log prefix %q", options["--log-prefix"]), nil
+	case "NFLOG":
+		return fmt.Sprintf("log prefix %q group %s", options["--nflog-prefix"], options["--nflog-group"]), nil
+	}
+	// Anything else is a jump to a user chain, which takes no options.
+	for option := range options {
+		if option != "--comment" {
+			return "", fmt.Errorf("no nftables translation for target %s %s", target, option)
+		}
+	}
+	return "jump " + target, nil
+}
+
+// nftAddress brackets the IPv6 address of an address:port destination.
+func nftAddress(family, destination string) string {
+	if family != "ip6" {
+		return destination
+	}
+	idx := strings.LastIndex(destination, ":")
+	if idx < 0 || strings.HasPrefix(destination, "[") {
+		return destination
+	}
+	host, port := destination[:idx], destination[idx+1:]
+	if !strings.Contains(host, ":") || !isRuleNumber(port) {
+		return destination
+	}
+	return "[" + host + "]:" + port
+}
+
+func isRuleNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(list *[]string, value string) {
+	for _, existing := range *list {
+		if existing == value {
+			return
+		}
+	}
+	*list = append(*list, value)
+}
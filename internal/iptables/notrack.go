@@ -0,0 +1,117 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// NotrackChainNames returns the raw-table chains holding NOTRACK rules for
+// traffic leaving the pod and for the replies coming back.
+func NotrackChainNames(chain string) (string, string) {
+	return chain + "_NT_OUT", chain + "_NT_IN"
+}
+
+// ValidateNotrackCIDRs ensures every NOTRACK CIDR lies within one of the
+// exclusion CIDRs. Untracked packets never reach the nat table, so NOTRACK on
+// a destination that is not excluded would silently break Service DNAT.
+func ValidateNotrackCIDRs(notrack []string, excluded []string) error {
+	var nets []*net.IPNet
+	for _, raw := range excluded {
+		cidr := strings.TrimSpace(raw)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("parse exclusion cidr %q: %w", cidr, err)
+		}
+		nets = append(nets, network)
+	}
+
+	for _, raw := range notrack {
+		cidr := strings.TrimSpace(raw)
+		if cidr == "" {
+			continue
+		}
+		_, candidate, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("parse notrack cidr %q: %w", cidr, err)
+		}
+		if !cidrCovered(candidate, nets) {
+			return fmt.Errorf("notrack cidr %s is not covered by any exclusion cidr", cidr)
+		}
+	}
+
+	return nil
+}
+
+func cidrCovered(candidate *net.IPNet, nets []*net.IPNet) bool {
+	candidateOnes, candidateBits := candidate.Mask.Size()
+	for _, network := range nets {
+		ones, bits := network.Mask.Size()
+		if bits == candidateBits && ones <= candidateOnes && network.Contains(candidate.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupNotrack installs raw-table NOTRACK rules so flows to and from cidrs skip
+// conntrack entirely. Outbound packets are matched by destination from OUTPUT
+// and replies by source from PREROUTING. Unlike the nat chain, these jumps are
+// permanent: the destinations are excluded from preview routing regardless of
+// role. Returns the number of NOTRACK rules added.
+func SetupNotrack(ctx context.Context, executor Executor, chain string, cidrs []string, ipv6 bool, logger *slog.Logger) (int, error) {
+	outChain, inChain := NotrackChainNames(chain)
+	for _, c := range []string{outChain, inChain} {
+		if err := EnsureChain(ctx, executor, conntrackTable, c, ipv6, logger); err != nil {
+			return 0, fmt.Errorf("prepare notrack chain %s: %w", c, err)
+		}
+	}
+
+	added := 0
+	for _, raw := range cidrs {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+
+		cidr := strings.TrimSpace(raw)
+		if cidr == "" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return added, fmt.Errorf("parse notrack cidr %q: %w", cidr, err)
+		}
+
+		bin := ipv4Binary
+		if ip.To4() == nil {
+			if !ipv6 {
+				logger.Warn("skipping ipv6 notrack rule without ipv6 support", slog.String("cidr", cidr))
+				continue
+			}
+			bin = ipv6Binary
+		}
+
+		logger.Info("adding notrack rules", slog.String("cidr", cidr), slog.Bool("ipv6", bin == ipv6Binary))
+		if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", conntrackTable, "-A", outChain, "-d", cidr, "-j", "NOTRACK")...); err != nil {
+			return added, fmt.Errorf("add outbound notrack rule for %s: %w", cidr, err)
+		}
+		if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", conntrackTable, "-A", inChain, "-s", cidr, "-j", "NOTRACK")...); err != nil {
+			return added, fmt.Errorf("add inbound notrack rule for %s: %w", cidr, err)
+		}
+		added += 2
+	}
+
+	if err := AddJump(ctx, executor, conntrackTable, "OUTPUT", outChain, ipv6, logger); err != nil {
+		return added, fmt.Errorf("jump to %s: %w", outChain, err)
+	}
+	if err := AddJump(ctx, executor, conntrackTable, "PREROUTING", inChain, ipv6, logger); err != nil {
+		return added, fmt.Errorf("jump to %s: %w", inChain, err)
+	}
+
+	return added, nil
+}
@@ -0,0 +1,44 @@
+package iptables
+
+import (
+	"slices"
+	"strings"
+)
+
+// ownerComment is the comment ghostwire tags its rules with, so they can be
+// told apart from rules other tooling adds to the same hooks and chains. It
+// carries no version, unlike the "ghostwire:<version>" first proposed:
+// `iptables -C` and `-D` match comments exactly, so a newer binary could not
+// find the rules an older one wrote, and the binary has no version to embed.
+const ownerComment = "ghostwire"
+
+// withOwner adds the ownership comment match to args, ahead of the target so
+// that checks and deletes built the same way match the rule that was added.
+func withOwner(args ...string) []string {
+	comment := []string{"-m", "comment", "--comment", ownerComment}
+	at := slices.Index(args, "-j")
+	if at < 0 {
+		return append(slices.Clone(args), comment...)
+	}
+	return slices.Concat(args[:at], comment, args[at:])
+}
+
+// ruleComment returns the comment of a rule listed by `iptables -S`, without
+// the quotes iptables may print around it, and whether it has one.
+func ruleComment(rule string) (string, bool) {
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "--comment" {
+			return strings.Trim(fields[i+1], `"`), true
+		}
+	}
+	return "", false
+}
+
+// ownedRule reports whether a rule listed by `iptables -S` belongs to
+// ghostwire: it carries the ownership comment, or no comment at all, like the
+// rules of releases that predate ownership comments.
+func ownedRule(rule string) bool {
+	comment, ok := ruleComment(rule)
+	return !ok || comment == ownerComment
+}
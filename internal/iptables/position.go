@@ -0,0 +1,289 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const jumpAfterPrefix = "after:"
+
+// OutputExecutor is implemented by executors that can return command output.
+// Positioned jumps need it to read the hook's current rule order.
+type OutputExecutor interface {
+	Output(ctx context.Context, command string, args ...string) (string, error)
+}
+
+// Output executes the command and returns its standard output.
+func (r *RealExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	if err := r.validate(command, args); err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr = string(exitErr.Stderr)
+		}
+		return "", NewCommandError(command, args, "", stderr, err)
+	}
+	return string(output), nil
+}
+
+// JumpPosition controls where the jump to the DNAT chain is inserted within a
+// hook. Index is a 1-based rule number; After anchors the jump directly below
+// the last rule jumping to the named chain. The zero value behaves like
+// Index 1.
+type JumpPosition struct {
+	Index int
+	After string
+}
+
+// ParseJumpPosition accepts "", a positive rule number, or "after:CHAIN".
+func ParseJumpPosition(raw string) (JumpPosition, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return JumpPosition{Index: 1}, nil
+	}
+	if strings.HasPrefix(value, jumpAfterPrefix) {
+		anchor := strings.TrimSpace(strings.TrimPrefix(value, jumpAfterPrefix))
+		if anchor == "" {
+			return JumpPosition{}, fmt.Errorf("jump position %q is missing a chain name", raw)
+		}
+		if err := ValidateChainName(anchor); err != nil {
+			return JumpPosition{}, fmt.Errorf("jump position %q: %w", raw, err)
+		}
+		return JumpPosition{After: anchor}, nil
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 1 {
+		return JumpPosition{}, fmt.Errorf("jump position %q must be a positive rule number or %sCHAIN", raw, jumpAfterPrefix)
+	}
+	return JumpPosition{Index: index}, nil
+}
+
+// IsDefault reports whether the position is the top of the hook.
+func (p JumpPosition) IsDefault() bool {
+	return p.After == "" && p.Index <= 1
+}
+
+// String renders the position in the form accepted by ParseJumpPosition.
+func (p JumpPosition) String() string {
+	if p.After != "" {
+		return jumpAfterPrefix + p.After
+	}
+	if p.Index < 1 {
+		return "1"
+	}
+	return strconv.Itoa(p.Index)
+}
+
+// ListHookRules returns the targets of the rules in hook, in evaluation order.
+func ListHookRules(ctx context.Context, executor Executor, binary string, table string, hook string) ([]string, error) {
+	outputExecutor, ok := executor.(OutputExecutor)
+	if !ok {
+		return nil, errors.New("executor cannot read rule listings")
+	}
+
+	output, err := outputExecutor.Output(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-S", hook)
+	if err != nil {
+		return nil, fmt.Errorf("list %s rules: %w", hook, err)
+	}
+
+	prefix := "-A " + hook + " "
+	var targets []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		targets = append(targets, ruleTarget(line))
+	}
+	return targets, nil
+}
+
+func ruleTarget(rule string) string {
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "-j" || fields[i] == "-g" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// resolveJumpIndex turns a position into the rule number to pass to -I.
+func resolveJumpIndex(targets []string, pos JumpPosition) int {
+	if pos.After != "" {
+		anchor := 0
+		for i, target := range targets {
+			if target == pos.After {
+				anchor = i + 1
+			}
+		}
+		return anchor + 1
+	}
+	if pos.Index > len(targets)+1 {
+		return len(targets) + 1
+	}
+	if pos.Index < 1 {
+		return 1
+	}
+	return pos.Index
+}
+
+// jumpPositionHolds reports whether the jump to chain satisfies pos within
+// targets. A missing jump never holds.
+func jumpPositionHolds(targets []string, chain string, pos JumpPosition) bool {
+	jumpAt := -1
+	for i, target := range targets {
+		if target == chain {
+			jumpAt = i
+			break
+		}
+	}
+	if jumpAt < 0 {
+		return false
+	}
+	if pos.After != "" {
+		for _, target := range targets[jumpAt+1:] {
+			if target == pos.After {
+				return false
+			}
+		}
+		return true
+	}
+	index := pos.Index
+	if index < 1 {
+		index = 1
+	}
+	return jumpAt+1 <= index
+}
+
+// VerifyJumpPosition reports whether the IPv4 jump to chain is still where pos
+// requires. Other tools inserting rules at the top of the hook push the jump
+// down, which shows up here as drift.
+func VerifyJumpPosition(ctx context.Context, executor Executor, table string, hook string, chain string, pos JumpPosition) (bool, error) {
+	targets, err := ListHookRules(ctx, executor, ipv4Binary, table, hook)
+	if err != nil {
+		return false, err
+	}
+	return jumpPositionHolds(targets, chain, pos), nil
+}
+
+// AddJumpAt inserts the jump at pos and verifies the resulting order. The
+// default position delegates to AddJump. As with AddJump, IPv6 failures are
+// logged rather than returned.
+func AddJumpAt(ctx context.Context, executor Executor, table string, hook string, chain string, pos JumpPosition, ipv6 bool, logger *slog.Logger) error {
+	if pos.IsDefault() {
+		return AddJump(ctx, executor, table, hook, chain, ipv6, logger)
+	}
+	if err := validateJumpNames(table, hook, chain); err != nil {
+		return err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if err := insertJumpAt(ctx, executor, ipv4Binary, table, hook, chain, pos, logger); err != nil {
+		return err
+	}
+
+	if !ipv6 {
+		return nil
+	}
+
+	if err := insertJumpAt(ctx, executor, ipv6Binary, table, hook, chain, pos, logger); err != nil {
+		logger.Warn("failed to add positioned ipv6 jump rule",
+			slog.String("table", table),
+			slog.String("hook", hook),
+			slog.String("chain", chain),
+			slog.String("position", pos.String()),
+			slog.Any("error", err),
+		)
+	}
+
+	return nil
+}
+
+// AddJumpsAt adds the jump to chain at pos in every hook in hooks, stopping at
+// the first hook that fails.
+func AddJumpsAt(ctx context.Context, executor Executor, table string, hooks []string, chain string, pos JumpPosition, ipv6 bool, logger *slog.Logger) error {
+	for _, hook := range hooks {
+		if err := AddJumpAt(ctx, executor, table, hook, chain, pos, ipv6, logger); err != nil {
+			return fmt.Errorf("hook %s: %w", hook, err)
+		}
+	}
+	return nil
+}
+
+func insertJumpAt(ctx context.Context, executor Executor, binary string, table string, hook string, chain string, pos JumpPosition, logger *slog.Logger) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	targets, err := ListHookRules(ctx, executor, binary, table, hook)
+	if err != nil {
+		return err
+	}
+
+	if jumpPositionHolds(targets, chain, pos) {
+		logger.Debug("positioned jump rule already present",
+			slog.String("binary", binary),
+			slog.String("table", table),
+			slog.String("hook", hook),
+			slog.String("chain", chain),
+			slog.String("position", pos.String()),
+		)
+		return nil
+	}
+
+	for i, target := range targets {
+		if target != chain {
+			continue
+		}
+		// Present but out of place: drop it so the insert below restores order.
+		// It goes by rule number because a jump added before the ownership
+		// comment, or by hand, would not match a delete by rule spec.
+		if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-D", hook, strconv.Itoa(i+1)); err != nil {
+			return fmt.Errorf("remove misplaced jump via %s: %w", binary, err)
+		}
+		return insertJumpAt(ctx, executor, binary, table, hook, chain, pos, logger)
+	}
+
+	if pos.After != "" && resolveJumpIndex(targets, pos) == 1 {
+		logger.Warn("jump anchor chain not referenced in hook; inserting at top",
+			slog.String("binary", binary),
+			slog.String("hook", hook),
+			slog.String("anchor", pos.After),
+		)
+	}
+
+	index := resolveJumpIndex(targets, pos)
+	logger.Info("adding positioned jump rule",
+		slog.String("binary", binary),
+		slog.String("table", table),
+		slog.String("hook", hook),
+		slog.String("chain", chain),
+		slog.String("position", pos.String()),
+		slog.Int("rule_number", index),
+	)
+	if err := executor.Run(ctx, binary, withOwner("-w", iptablesWaitSeconds, "-t", table, "-I", hook, strconv.Itoa(index), "-j", chain)...); err != nil {
+		return fmt.Errorf("add jump via %s: %w", binary, err)
+	}
+
+	after, err := ListHookRules(ctx, executor, binary, table, hook)
+	if err != nil {
+		return fmt.Errorf("verify jump position: %w", err)
+	}
+	if !jumpPositionHolds(after, chain, pos) {
+		return fmt.Errorf("jump to %s not at position %s in %s after insertion", chain, pos.String(), hook)
+	}
+
+	return nil
+}
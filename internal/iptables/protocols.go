@@ -0,0 +1,95 @@
+package iptables
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// ParseProtocols normalises a list of protocol names into their Kubernetes
+// spelling. An empty list means every protocol is allowed.
+func ParseProtocols(values []string) ([]corev1.Protocol, error) {
+	var protocols []corev1.Protocol
+	seen := make(map[corev1.Protocol]bool)
+	for _, value := range values {
+		trimmed := strings.ToUpper(strings.TrimSpace(value))
+		if trimmed == "" {
+			continue
+		}
+		protocol := corev1.Protocol(trimmed)
+		switch protocol {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q (expected TCP, UDP, or SCTP)", value)
+		}
+		if !seen[protocol] {
+			seen[protocol] = true
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols, nil
+}
+
+// FilterProtocols drops mappings whose protocol is not in allowed and returns
+// the remaining mappings with per-protocol counts of what was skipped. Services
+// that lose any port also lose whole-service eligibility, since an
+// address-only DNAT rule would reroute the filtered protocols too.
+func FilterProtocols(mappings []discovery.ServiceMapping, allowed []corev1.Protocol, logger *slog.Logger) ([]discovery.ServiceMapping, map[corev1.Protocol]int) {
+	if len(allowed) == 0 {
+		return mappings, nil
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	permitted := make(map[corev1.Protocol]bool, len(allowed))
+	for _, protocol := range allowed {
+		permitted[protocol] = true
+	}
+
+	skipped := make(map[corev1.Protocol]int)
+	partial := make(map[string]bool)
+	kept := make([]discovery.ServiceMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		protocol := mapping.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		if permitted[protocol] {
+			kept = append(kept, mapping)
+			continue
+		}
+		skipped[protocol]++
+		partial[mapping.ServiceName+"/"+mapping.ActiveClusterIP] = true
+		logger.Info("skipping dnat rule for disabled protocol",
+			slog.String("service", mapping.ServiceName),
+			slog.Int("port", int(mapping.Port)),
+			slog.String("protocol", string(protocol)))
+	}
+
+	for i := range kept {
+		if partial[kept[i].ServiceName+"/"+kept[i].ActiveClusterIP] {
+			kept[i].IdenticalPorts = false
+		}
+	}
+
+	if len(skipped) > 0 {
+		names := make([]string, 0, len(skipped))
+		for protocol := range skipped {
+			names = append(names, string(protocol))
+		}
+		sort.Strings(names)
+		attrs := make([]any, 0, len(names))
+		for _, name := range names {
+			attrs = append(attrs, slog.Int(strings.ToLower(name), skipped[corev1.Protocol(name)]))
+		}
+		logger.Info("skipped mappings by protocol", attrs...)
+	}
+
+	return kept, skipped
+}
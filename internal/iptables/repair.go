@@ -0,0 +1,78 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// RepairDNATRule replaces the rule installed for recorded with one targeting
+// current, keeping per-port and whole-service rules in their original form.
+// The corrected rule is appended before the stale one is deleted so the
+// destination is never left unrouted. Multiport rules cover several mappings
+// at once and are not repaired; an error reports that no rule matched.
+func RepairDNATRule(ctx context.Context, executor Executor, table string, chain string, recorded discovery.ServiceMapping, current discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) error {
+	bin := ipv4Binary
+	if isIPv6(recorded.ActiveClusterIP) {
+		if !ipv6 {
+			return fmt.Errorf("repair dnat rule for %s: ipv6 support disabled", recorded.ServiceName)
+		}
+		bin = ipv6Binary
+	}
+
+	candidates := [][2][]string{
+		{perPortRuleSpec(recorded), perPortRuleSpec(current)},
+		{wholeServiceRuleSpec(recorded), wholeServiceRuleSpec(current)},
+	}
+	for _, candidate := range candidates {
+		stale, fixed := candidate[0], candidate[1]
+		exists, err := ruleExists(ctx, executor, bin, table, chain, stale)
+		if err != nil {
+			return fmt.Errorf("check dnat rule for %s: %w", recorded.ServiceName, err)
+		}
+		if !exists {
+			continue
+		}
+
+		logger.Info("repairing dnat rule",
+			slog.String("service", recorded.ServiceName),
+			slog.Int("port", int(recorded.Port)),
+			slog.String("active_ip", current.ActiveClusterIP),
+			slog.String("stale_preview_ip", recorded.PreviewClusterIP),
+			slog.String("preview_ip", current.PreviewClusterIP),
+		)
+		if err := executor.Run(ctx, bin, append([]string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain}, fixed...)...); err != nil {
+			return fmt.Errorf("add repaired dnat rule for %s: %w", recorded.ServiceName, err)
+		}
+		if err := executor.Run(ctx, bin, append([]string{"-w", iptablesWaitSeconds, "-t", table, "-D", chain}, stale...)...); err != nil {
+			return fmt.Errorf("delete stale dnat rule for %s: %w", recorded.ServiceName, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("repair dnat rule for %s: no per-port or whole-service rule matches the recorded mapping", recorded.ServiceName)
+}
+
+func perPortRuleSpec(mapping discovery.ServiceMapping) []string {
+	return withOwner("-d", mapping.ActiveClusterIP, "-p", strings.ToLower(string(mapping.Protocol)), "--dport", fmt.Sprintf("%d", mapping.Port), "-j", "DNAT", "--to-destination", previewDestination(mapping))
+}
+
+func wholeServiceRuleSpec(mapping discovery.ServiceMapping) []string {
+	return withOwner("-d", mapping.ActiveClusterIP, "-j", "DNAT", "--to-destination", mapping.PreviewClusterIP)
+}
+
+func ruleExists(ctx context.Context, executor Executor, binary string, table string, chain string, spec []string) (bool, error) {
+	args := append([]string{"-w", iptablesWaitSeconds, "-t", table, "-C", chain}, spec...)
+	if err := executor.Run(ctx, binary, args...); err != nil {
+		if errors.Is(err, ErrRuleMissing) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
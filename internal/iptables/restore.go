@@ -0,0 +1,213 @@
+package iptables
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// restoreTables lists tables in the order they are rendered.
+var restoreTables = []string{"raw", "mangle", "nat", "filter"}
+
+// RestoreRecorder is an Executor that records the commands Setup would run
+// instead of running them, so a separate privileged component can apply the
+// rules with iptables-restore. It reports every chain and rule as absent.
+type RestoreRecorder struct {
+	mu       sync.Mutex
+	commands []recordedCommand
+}
+
+type recordedCommand struct {
+	binary string
+	args   []string
+}
+
+// recordedAbsent is returned for -C checks; exit code 1 is how iptables
+// reports a missing rule.
+type recordedAbsent struct{}
+
+func (recordedAbsent) Error() string { return "rule not present (recorded)" }
+
+func (recordedAbsent) ExitCode() int { return 1 }
+
+// NewRestoreRecorder constructs an empty RestoreRecorder.
+func NewRestoreRecorder() *RestoreRecorder {
+	return &RestoreRecorder{}
+}
+
+// Run records the command. Existence checks fail as if nothing is installed.
+func (r *RestoreRecorder) Run(_ context.Context, command string, args ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, recordedCommand{binary: command, args: append([]string(nil), args...)})
+	for _, arg := range args {
+		if arg == "-C" {
+			return NewCommandError(command, args, "", "", recordedAbsent{})
+		}
+	}
+	return nil
+}
+
+// ChainExists always reports false so chains are recorded as created.
+func (r *RestoreRecorder) ChainExists(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
+// ChainExists6 always reports false so chains are recorded as created.
+func (r *RestoreRecorder) ChainExists6(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
+// Render returns the recorded rules for binary ("iptables" or "ip6tables")
+// in iptables-restore format, meant for `iptables-restore --noflush`.
+// Commands for other tools (ipset, nfct) are listed as leading comments for
+// the applier to run first. It returns nil when nothing was recorded for
+// binary.
+func (r *RestoreRecorder) Render(binary string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chains := make(map[string][]string)
+	rules := make(map[string][]string)
+	var external []string
+	for _, cmd := range r.commands {
+		if cmd.binary != ipv4Binary && cmd.binary != ipv6Binary {
+			if binary == ipv4Binary {
+				external = append(external, cmd.binary+" "+strings.Join(cmd.args, " "))
+			}
+			continue
+		}
+		if cmd.binary != binary {
+			continue
+		}
+
+		table, op, rest := splitRecordedArgs(cmd.args)
+		switch op {
+		case "-N":
+			if len(rest) > 0 {
+				chains[table] = append(chains[table], rest[0])
+			}
+		case "-A", "-I":
+			rules[table] = append(rules[table], op+" "+strings.Join(rest, " "))
+		}
+	}
+
+	if len(chains) == 0 && len(rules) == 0 && len(external) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by ghostwire; apply with %s-restore --noflush\n", binary)
+	for _, line := range external {
+		fmt.Fprintf(&buf, "# run first: %s\n", line)
+	}
+	for _, table := range restoreTables {
+		if len(chains[table]) == 0 && len(rules[table]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "*%s\n", table)
+		for _, chain := range chains[table] {
+			fmt.Fprintf(&buf, ":%s - [0:0]\n", chain)
+		}
+		for _, rule := range rules[table] {
+			buf.WriteString(rule + "\n")
+		}
+		buf.WriteString("COMMIT\n")
+	}
+	return buf.Bytes()
+}
+
+// ExportRules records the complete ruleset ghostwire manages for mappings:
+// the chain, exclusions and DNAT rules Setup installs plus the jumps from
+// hooks the watcher adds on promotion, and the raw-table jumps when a
+// conntrack timeout policy is configured. Nothing is executed and no DNAT map
+// is written. Positioned jumps depend on the live hook contents, so each jump
+// is always rendered at the top of its hook.
+func ExportRules(ctx context.Context, cfg Config, mappings []discovery.ServiceMapping, hooks []string, logger *slog.Logger) (*RestoreRecorder, error) {
+	recorder := NewRestoreRecorder()
+	cfg.DnatMapPath = ""
+	if err := SetupWithExecutor(ctx, recorder, cfg, mappings, logger); err != nil {
+		return nil, err
+	}
+	if err := AddJumps(ctx, recorder, "nat", hooks, cfg.ChainName, cfg.IPv6, logger); err != nil {
+		return nil, err
+	}
+	if cfg.CTTimeoutPolicy != "" {
+		if err := AddJumps(ctx, recorder, conntrackTable, hooks, cfg.ChainName, cfg.IPv6, logger); err != nil {
+			return nil, err
+		}
+	}
+	return recorder, nil
+}
+
+// Rules returns the rules recorded for binary in table, each as the
+// "-A chain ..." or "-I chain ..." line Render would print.
+func (r *RestoreRecorder) Rules(binary, table string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rules []string
+	for _, cmd := range r.commands {
+		if cmd.binary != binary {
+			continue
+		}
+		recordedTable, op, rest := splitRecordedArgs(cmd.args)
+		if recordedTable == table && (op == "-A" || op == "-I") {
+			rules = append(rules, op+" "+strings.Join(rest, " "))
+		}
+	}
+	return rules
+}
+
+// WriteFiles renders the IPv4 rules to path and, when any were recorded, the
+// IPv6 rules to path + ".v6".
+func (r *RestoreRecorder) WriteFiles(path string) error {
+	if err := validateDNATMapPath(path); err != nil {
+		return err
+	}
+	for _, out := range []struct {
+		binary string
+		path   string
+	}{
+		{ipv4Binary, path},
+		{ipv6Binary, path + ".v6"},
+	} {
+		data := r.Render(out.binary)
+		if data == nil {
+			continue
+		}
+		// #nosec G306 -- restore files live on an operator-configured shared volume for the applier to read.
+		if err := os.WriteFile(out.path, data, 0o644); err != nil {
+			return fmt.Errorf("write restore file %s: %w", out.path, err)
+		}
+	}
+	return nil
+}
+
+// splitRecordedArgs extracts the table, the operation flag and the arguments
+// after it from an iptables invocation, skipping -w and its value.
+func splitRecordedArgs(args []string) (string, string, []string) {
+	table := "filter"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-w":
+			i++
+		case "-t":
+			if i+1 < len(args) {
+				table = args[i+1]
+			}
+			i++
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return table, args[i], args[i+1:]
+			}
+		}
+	}
+	return table, "", nil
+}
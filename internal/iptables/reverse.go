@@ -0,0 +1,93 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+const reverseSuffix = "_REVERSE"
+
+// ReverseChainName returns the chain holding the reverse DNAT rules of chain,
+// which send preview ClusterIPs back to the active Services. Long names are
+// trimmed so the suffix still fits.
+func ReverseChainName(chain string) string {
+	if len(chain)+len(reverseSuffix) > maxChainNameLength {
+		chain = chain[:maxChainNameLength-len(reverseSuffix)]
+	}
+	return chain + reverseSuffix
+}
+
+// ReverseMappings swaps the active and preview side of every mapping, so the
+// DNAT rules built from them match the preview ClusterIP and port and rewrite
+// to the active ones. When several active services share one preview
+// destination the first mapping wins.
+func ReverseMappings(mappings []discovery.ServiceMapping) []discovery.ServiceMapping {
+	seen := make(map[string]bool)
+	reversed := make([]discovery.ServiceMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		port := mapping.TargetPort()
+		key := fmt.Sprintf("%s/%s/%d", mapping.PreviewClusterIP, strings.ToLower(string(mapping.Protocol)), port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		reverse := discovery.ServiceMapping{
+			Namespace:         mapping.Namespace,
+			ServiceName:       mapping.ServiceName,
+			Port:              port,
+			Protocol:          mapping.Protocol,
+			ActiveClusterIP:   mapping.PreviewClusterIP,
+			PreviewClusterIP:  mapping.ActiveClusterIP,
+			PortName:          mapping.PortName,
+			IdenticalPorts:    mapping.IdenticalPorts,
+			ActiveTargetPort:  mapping.PreviewTargetPort,
+			PreviewTargetPort: mapping.ActiveTargetPort,
+		}
+		if port != mapping.Port {
+			reverse.PreviewPort = mapping.Port
+		}
+		reversed = append(reversed, reverse)
+	}
+	return reversed
+}
+
+// SetupReverse rebuilds the reverse chain of chain in table from the reversed
+// mappings and jumps to it from every hook, so a pod bypassing the preview
+// Services cannot reach them even by a hardcoded preview address. Returns the
+// number of DNAT rules added.
+func SetupReverse(ctx context.Context, executor Executor, table string, chain string, hooks []string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
+	reverseChain := ReverseChainName(chain)
+	if err := EnsureChain(ctx, executor, table, reverseChain, ipv6, logger); err != nil {
+		return 0, fmt.Errorf("prepare reverse chain: %w", err)
+	}
+
+	added, err := AddDNATRules(ctx, executor, table, reverseChain, ReverseMappings(mappings), ipv6, logger)
+	if err != nil {
+		return added, fmt.Errorf("add reverse dnat rules: %w", err)
+	}
+
+	if err := AddJumps(ctx, executor, table, hooks, reverseChain, ipv6, logger); err != nil {
+		return added, fmt.Errorf("jump to %s: %w", reverseChain, err)
+	}
+	logger.Info("reverse dnat installed", slog.String("chain", reverseChain), slog.Int("rules", added))
+	return added, nil
+}
+
+// RemoveReverse removes the jumps to the reverse chain of chain from every
+// hook and flushes the chain, so preview ClusterIPs are reachable again.
+func RemoveReverse(ctx context.Context, executor Executor, table string, chain string, hooks []string, ipv6 bool, logger *slog.Logger) error {
+	reverseChain := ReverseChainName(chain)
+	if err := RemoveJumps(ctx, executor, table, hooks, reverseChain, ipv6, logger); err != nil {
+		return fmt.Errorf("remove jump to %s: %w", reverseChain, err)
+	}
+	if err := EnsureChain(ctx, executor, table, reverseChain, ipv6, logger); err != nil {
+		return fmt.Errorf("flush reverse chain: %w", err)
+	}
+	logger.Info("reverse dnat removed", slog.String("chain", reverseChain))
+	return nil
+}
@@ -15,6 +15,19 @@ func isIPv6(ip string) bool {
 	return parsed != nil && parsed.To4() == nil
 }
 
+// previewDestination formats the DNAT target using the preview port, which
+// differs from the matched port only when an override remaps it.
+func previewDestination(mapping discovery.ServiceMapping) string {
+	return fmt.Sprintf("%s:%d", mapping.PreviewClusterIP, mapping.TargetPort())
+}
+
+// dnatSkipMessages are the warnings logged for each skip reason.
+var dnatSkipMessages = map[string]string{
+	SkipReasonMissingFields: "skipping dnat rule due to missing IP/port",
+	SkipReasonMixedFamily:   "skipping dnat rule due to mixed IP families",
+	SkipReasonIPv6Disabled:  "skipping ipv6 dnat rule without ipv6 support",
+}
+
 // AddDNATRules builds DNAT rules for each discovered service mapping.
 func AddDNATRules(ctx context.Context, executor Executor, table string, chain string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
 	added := 0
@@ -23,8 +36,9 @@ func AddDNATRules(ctx context.Context, executor Executor, table string, chain st
 			return added, err
 		}
 
-		if mapping.ActiveClusterIP == "" || mapping.PreviewClusterIP == "" || mapping.Port == 0 {
-			logger.Warn("skipping dnat rule due to missing IP/port",
+		if reason := dnatSkipReason(mapping, ipv6); reason != "" {
+			recordSkippedDNATRule(reason)
+			logger.Warn(dnatSkipMessages[reason],
 				slog.String("service", mapping.ServiceName),
 				slog.String("active_ip", mapping.ActiveClusterIP),
 				slog.String("preview_ip", mapping.PreviewClusterIP),
@@ -33,29 +47,84 @@ func AddDNATRules(ctx context.Context, executor Executor, table string, chain st
 		}
 
 		protocol := strings.ToLower(string(mapping.Protocol))
-		ruleArgs := []string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", mapping.ActiveClusterIP, "-p", protocol, "--dport", fmt.Sprintf("%d", mapping.Port), "-j", "DNAT", "--to-destination", fmt.Sprintf("%s:%d", mapping.PreviewClusterIP, mapping.Port)}
+		ruleArgs := withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", mapping.ActiveClusterIP, "-p", protocol, "--dport", fmt.Sprintf("%d", mapping.Port), "-j", "DNAT", "--to-destination", previewDestination(mapping))
 
-		isActiveV6 := isIPv6(mapping.ActiveClusterIP)
-		isPreviewV6 := isIPv6(mapping.PreviewClusterIP)
+		useIPv6 := isIPv6(mapping.ActiveClusterIP)
+		bin := ipv4Binary
+		if useIPv6 {
+			bin = ipv6Binary
+		}
+
+		logger.Info("adding dnat rule", slog.String("service", mapping.ServiceName), slog.Int("port", int(mapping.Port)), slog.String("protocol", protocol), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP), slog.Bool("ipv6", useIPv6))
+		if err := executor.Run(ctx, bin, ruleArgs...); err != nil {
+			return added, fmt.Errorf("add dnat rule for %s: %w", mapping.ServiceName, WithService(err, mapping.ServiceName))
+		}
+		added++
+	}
+
+	return added, nil
+}
+
+// SplitWholeService separates mappings whose services expose identical port
+// sets from the rest. The first result holds one representative mapping per
+// such service; the second holds every mapping still needing per-port rules.
+func SplitWholeService(mappings []discovery.ServiceMapping) ([]discovery.ServiceMapping, []discovery.ServiceMapping) {
+	eligible := make(map[string]bool)
+	for _, mapping := range mappings {
+		key := mapping.ServiceName + "/" + mapping.ActiveClusterIP
+		if current, seen := eligible[key]; seen {
+			eligible[key] = current && mapping.IdenticalPorts
+		} else {
+			eligible[key] = mapping.IdenticalPorts
+		}
+	}
+
+	var whole, perPort []discovery.ServiceMapping
+	emitted := make(map[string]bool)
+	for _, mapping := range mappings {
+		key := mapping.ServiceName + "/" + mapping.ActiveClusterIP
+		if !eligible[key] {
+			perPort = append(perPort, mapping)
+			continue
+		}
+		if !emitted[key] {
+			emitted[key] = true
+			whole = append(whole, mapping)
+		}
+	}
+
+	return whole, perPort
+}
 
-		if isActiveV6 != isPreviewV6 {
+// AddWholeServiceDNATRules emits a single protocol- and port-agnostic DNAT rule
+// per mapping, rewriting only the destination address.
+func AddWholeServiceDNATRules(ctx context.Context, executor Executor, table string, chain string, mappings []discovery.ServiceMapping, ipv6 bool, logger *slog.Logger) (int, error) {
+	added := 0
+	for _, mapping := range mappings {
+		if err := ctx.Err(); err != nil {
+			return added, err
+		}
+
+		isActiveV6 := isIPv6(mapping.ActiveClusterIP)
+		if isActiveV6 != isIPv6(mapping.PreviewClusterIP) {
+			recordSkippedDNATRule(SkipReasonMixedFamily)
 			logger.Warn("skipping dnat rule due to mixed IP families", slog.String("service", mapping.ServiceName), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP))
 			continue
 		}
 
-		useIPv6 := isActiveV6
 		bin := ipv4Binary
-		if useIPv6 {
+		if isActiveV6 {
 			if !ipv6 {
+				recordSkippedDNATRule(SkipReasonIPv6Disabled)
 				logger.Warn("skipping ipv6 dnat rule without ipv6 support", slog.String("service", mapping.ServiceName), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP))
 				continue
 			}
 			bin = ipv6Binary
 		}
 
-		logger.Info("adding dnat rule", slog.String("service", mapping.ServiceName), slog.Int("port", int(mapping.Port)), slog.String("protocol", protocol), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP), slog.Bool("ipv6", useIPv6))
-		if err := executor.Run(ctx, bin, ruleArgs...); err != nil {
-			return added, fmt.Errorf("add dnat rule for %s: %w", mapping.ServiceName, err)
+		logger.Info("adding whole-service dnat rule", slog.String("service", mapping.ServiceName), slog.String("active_ip", mapping.ActiveClusterIP), slog.String("preview_ip", mapping.PreviewClusterIP), slog.Bool("ipv6", isActiveV6))
+		if err := executor.Run(ctx, bin, withOwner("-w", iptablesWaitSeconds, "-t", table, "-A", chain, "-d", mapping.ActiveClusterIP, "-j", "DNAT", "--to-destination", mapping.PreviewClusterIP)...); err != nil {
+			return added, fmt.Errorf("add whole-service dnat rule for %s: %w", mapping.ServiceName, WithService(err, mapping.ServiceName))
 		}
 		added++
 	}
@@ -0,0 +1,88 @@
+package iptables
+
+import (
+	"sync"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// Reasons a mapping produced no DNAT rule, as counted by SkippedDNATRules.
+const (
+	SkipReasonMissingFields = "missing_fields"
+	SkipReasonMixedFamily   = "mixed_family"
+	SkipReasonIPv6Disabled  = "ipv6_disabled"
+)
+
+// dnatSkipReason returns why AddDNATRules writes no rule for mapping, or ""
+// when it writes one. VerifyDNATRules applies the same test, so a skipped
+// mapping is not reported missing from the chain.
+func dnatSkipReason(mapping discovery.ServiceMapping, ipv6 bool) string {
+	switch {
+	case mapping.ActiveClusterIP == "" || mapping.PreviewClusterIP == "" || mapping.Port == 0:
+		return SkipReasonMissingFields
+	case isIPv6(mapping.ActiveClusterIP) != isIPv6(mapping.PreviewClusterIP):
+		return SkipReasonMixedFamily
+	case isIPv6(mapping.ActiveClusterIP) && !ipv6:
+		return SkipReasonIPv6Disabled
+	}
+	return ""
+}
+
+// endpointSkipReason is dnatSkipReason for AddEndpointDNATRules, which needs
+// preview endpoints in the active address family rather than a preview IP.
+func endpointSkipReason(mapping discovery.ServiceMapping, ipv6 bool) string {
+	activeV6 := isIPv6(mapping.ActiveClusterIP)
+	if activeV6 && !ipv6 {
+		return SkipReasonIPv6Disabled
+	}
+	for _, endpoint := range mapping.PreviewEndpoints {
+		if isIPv6(endpoint.IP) == activeV6 {
+			return ""
+		}
+	}
+	return SkipReasonMixedFamily
+}
+
+var (
+	skippedMu    sync.Mutex
+	skippedCount = map[string]uint64{}
+)
+
+func recordSkippedDNATRule(reason string) {
+	skippedMu.Lock()
+	skippedCount[reason]++
+	skippedMu.Unlock()
+}
+
+// SkippedDNATRules returns, by reason, how many mappings have been dropped
+// while building DNAT rules since process start.
+func SkippedDNATRules() map[string]uint64 {
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+
+	counts := make(map[string]uint64, len(skippedCount))
+	for reason, count := range skippedCount {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// ResetSkippedDNATRulesForTest clears the skipped rule counters.
+// This is exported solely for white-box testing.
+func ResetSkippedDNATRulesForTest() {
+	skippedMu.Lock()
+	skippedCount = map[string]uint64{}
+	skippedMu.Unlock()
+}
+
+// skippedSince returns the per-reason increase over an earlier snapshot,
+// omitting reasons that did not grow.
+func skippedSince(before map[string]uint64) map[string]uint64 {
+	delta := map[string]uint64{}
+	for reason, count := range SkippedDNATRules() {
+		if count > before[reason] {
+			delta[reason] = count - before[reason]
+		}
+	}
+	return delta
+}
@@ -0,0 +1,160 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recent-list suffixes remembering which side a source was sent to.
+const (
+	splitActiveListSuffix  = "_ACTIVE"
+	splitPreviewListSuffix = "_PREVIEW"
+)
+
+// SplitConfig sends only part of the new connections entering the chain to
+// the preview Services; the rest return and reach the active ones.
+type SplitConfig struct {
+	// BypassPercent is the share of new connections, from 0 to 100, that
+	// return before the DNAT rules. Zero routes everything to preview.
+	BypassPercent int
+	// StickyWindow, when positive, keeps every source on the side its last
+	// connection went to for this long, using two `-m recent` lists, so a
+	// client does not flip between active and preview from one connection to
+	// the next. Zero decides each connection on its own.
+	StickyWindow time.Duration
+	// Adjustable installs the rules even when BypassPercent is zero, so
+	// UpdateSplit can change the share later.
+	Adjustable bool
+}
+
+// Enabled reports whether the chain holds split rules.
+func (s SplitConfig) Enabled() bool {
+	return s.BypassPercent > 0 || s.Adjustable
+}
+
+// Validate checks the percentage and window.
+func (s SplitConfig) Validate() error {
+	if s.BypassPercent < 0 || s.BypassPercent > 100 {
+		return fmt.Errorf("bypass percent %d is not between 0 and 100", s.BypassPercent)
+	}
+	if s.StickyWindow < 0 {
+		return fmt.Errorf("sticky window %s is negative", s.StickyWindow)
+	}
+	if s.StickyWindow > 0 && s.StickyWindow < time.Second {
+		return fmt.Errorf("sticky window %s is shorter than the one-second resolution of -m recent", s.StickyWindow)
+	}
+	return nil
+}
+
+// SplitListNames returns the recent lists holding the sources sent to the
+// active and the preview side of chain.
+func SplitListNames(chain string) (string, string) {
+	return chain + splitActiveListSuffix, chain + splitPreviewListSuffix
+}
+
+// AddSplitRules appends the rules deciding, per new connection, whether it
+// continues to the DNAT rules below them. Without a sticky window a single
+// statistic rule returns the active share. With one, a source still within
+// its window on the active list returns, a source not on the preview list
+// returns (and joins the active list) with the active share's probability,
+// and every connection reaching the DNAT rules refreshes its source on the
+// preview list. Returns the number of rules added per family.
+func AddSplitRules(ctx context.Context, executor Executor, table string, chain string, split SplitConfig, ipv6 bool, logger *slog.Logger) (int, error) {
+	if !split.Enabled() {
+		return 0, nil
+	}
+	if err := split.Validate(); err != nil {
+		return 0, err
+	}
+
+	rules := splitRules(chain, split)
+
+	binaries := []string{ipv4Binary}
+	if ipv6 {
+		binaries = append(binaries, ipv6Binary)
+	}
+	for _, bin := range binaries {
+		for _, rule := range rules {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			args := withOwner(append([]string{"-w", iptablesWaitSeconds, "-t", table, "-A", chain}, rule...)...)
+			if err := executor.Run(ctx, bin, args...); err != nil {
+				return 0, fmt.Errorf("add split rule via %s: %w", bin, err)
+			}
+		}
+	}
+
+	logger.Info("preview traffic split configured",
+		slog.String("chain", chain),
+		slog.Int("preview_percent", 100-split.BypassPercent),
+		slog.Duration("sticky_window", split.StickyWindow),
+	)
+	return len(rules), nil
+}
+
+// splitRules builds the split rules of chain. Exactly one of them carries the
+// random statistic match deciding the share; see AddSplitRules.
+func splitRules(chain string, split SplitConfig) [][]string {
+	activeShare := strconv.FormatFloat(float64(split.BypassPercent)/100, 'f', 5, 64)
+	if split.StickyWindow <= 0 {
+		return [][]string{{"-m", "statistic", "--mode", "random", "--probability", activeShare, "-j", "RETURN"}}
+	}
+	activeList, previewList := SplitListNames(chain)
+	seconds := strconv.Itoa(int(split.StickyWindow / time.Second))
+	return [][]string{
+		{"-m", "recent", "--name", activeList, "--update", "--seconds", seconds, "-j", "RETURN"},
+		{"-m", "recent", "!", "--rcheck", "--name", previewList, "--seconds", seconds, "-m", "statistic", "--mode", "random", "--probability", activeShare, "-m", "recent", "--name", activeList, "--set", "-j", "RETURN"},
+		{"-m", "recent", "--name", previewList, "--set"},
+	}
+}
+
+// UpdateSplit changes the share of an installed split in place: the rule
+// holding the statistic match is replaced with `iptables -R`, which swaps it
+// atomically, so connections never see the chain without it. The chain must
+// have been built with the split enabled.
+func UpdateSplit(ctx context.Context, executor Executor, table string, chain string, split SplitConfig, ipv6 bool, logger *slog.Logger) error {
+	if err := split.Validate(); err != nil {
+		return err
+	}
+	var decision []string
+	for _, rule := range splitRules(chain, split) {
+		if slices.Contains(rule, "statistic") {
+			decision = rule
+		}
+	}
+
+	binaries := []string{ipv4Binary}
+	if ipv6 {
+		binaries = append(binaries, ipv6Binary)
+	}
+	for _, bin := range binaries {
+		rules, err := ListChainRules(ctx, executor, bin, table, chain)
+		if err != nil {
+			return err
+		}
+		number := 0
+		for i, rule := range rules {
+			if strings.Contains(rule, " --mode random ") && strings.Contains(rule, " --probability ") && strings.HasSuffix(rule, "-j RETURN") {
+				number = i + 1
+				break
+			}
+		}
+		if number == 0 {
+			return fmt.Errorf("no split rule in %s %s; init must build the chain with an adjustable split", bin, chain)
+		}
+
+		args := withOwner(append([]string{"-w", iptablesWaitSeconds, "-t", table, "-R", chain, strconv.Itoa(number)}, decision...)...)
+		if err := executor.Run(ctx, bin, args...); err != nil {
+			return fmt.Errorf("replace split rule via %s: %w", bin, err)
+		}
+	}
+
+	logger.Info("preview traffic split updated", slog.String("chain", chain), slog.Int("preview_percent", 100-split.BypassPercent))
+	return nil
+}
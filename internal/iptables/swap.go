@@ -0,0 +1,69 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// maxChainNameLength is the longest user chain name iptables accepts.
+const maxChainNameLength = 28
+
+const stagingSuffix = "_NEXT"
+
+// StagingChainName returns the chain a rebuild is written to before it is
+// promoted over chain. Long names are trimmed so the suffix still fits.
+func StagingChainName(chain string) string {
+	if len(chain)+len(stagingSuffix) > maxChainNameLength {
+		chain = chain[:maxChainNameLength-len(stagingSuffix)]
+	}
+	return chain + stagingSuffix
+}
+
+// PromoteChain deletes chain and renames staging to take its place. Callers
+// move any jump from chain to staging first: iptables refuses to delete a
+// referenced chain, while renaming keeps jumps to staging pointing at it.
+// IPv6 failures are logged, matching EnsureChain.
+func PromoteChain(ctx context.Context, executor Executor, table string, chain string, staging string, ipv6 bool, logger *slog.Logger) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	exists, err := executor.ChainExists(ctx, table, chain)
+	if err != nil {
+		return fmt.Errorf("determine chain existence: %w", err)
+	}
+	if err := promoteChain(ctx, executor, ipv4Binary, table, chain, staging, exists); err != nil {
+		return err
+	}
+	logger.Info("promoted staging chain", slog.String("table", table), slog.String("chain", chain), slog.String("staging_chain", staging), slog.Bool("ipv6", false))
+
+	if !ipv6 {
+		return nil
+	}
+
+	exists, err = executor.ChainExists6(ctx, table, chain)
+	if err == nil {
+		err = promoteChain(ctx, executor, ipv6Binary, table, chain, staging, exists)
+	}
+	if err != nil {
+		ipv6ChainFailureCount.Add(1)
+		logger.Warn("ip6tables chain promotion failed", slog.String("table", table), slog.String("chain", chain), slog.Any("error", err))
+	}
+	return nil
+}
+
+func promoteChain(ctx context.Context, executor Executor, binary string, table string, chain string, staging string, exists bool) error {
+	if exists {
+		if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-F", chain); err != nil {
+			return fmt.Errorf("flush chain %s: %w", chain, err)
+		}
+		if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-X", chain); err != nil {
+			return fmt.Errorf("delete chain %s: %w", chain, err)
+		}
+	}
+	if err := executor.Run(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-E", staging, chain); err != nil {
+		return fmt.Errorf("rename chain %s to %s: %w", staging, chain, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Trace verdicts.
+const (
+	TraceExcluded = "excluded"
+	TraceDNAT     = "dnat"
+	TraceNoMatch  = "no_match"
+)
+
+// Jump states reported by a trace.
+const (
+	TraceJumpActive   = "active"
+	TraceJumpInactive = "inactive"
+	TraceJumpUnknown  = "unknown"
+)
+
+// TraceStep records how one chain rule was evaluated.
+type TraceStep struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Note    string `json:"note,omitempty"`
+}
+
+// TraceResult is the outcome of simulating a connection through the DNAT
+// chain. Target is the rewritten destination for TraceDNAT verdicts.
+type TraceResult struct {
+	Destination string      `json:"destination"`
+	Protocol    string      `json:"protocol"`
+	Chain       string      `json:"chain"`
+	Source      string      `json:"source"`
+	Jump        string      `json:"jump"`
+	Verdict     string      `json:"verdict"`
+	Target      string      `json:"target,omitempty"`
+	MatchedRule string      `json:"matchedRule,omitempty"`
+	Steps       []TraceStep `json:"steps"`
+}
+
+// Trace lists chain's live rules and the hook's jump for ip's family and
+// evaluates a connection to ip:port over protocol against them.
+func Trace(ctx context.Context, executor Executor, table, hook, chain string, ip net.IP, port int, protocol string) (TraceResult, error) {
+	binary := ipv4Binary
+	if ip.To4() == nil {
+		binary = ipv6Binary
+	}
+
+	rules, err := ListChainRules(ctx, executor, binary, table, chain)
+	if err != nil {
+		return TraceResult{}, err
+	}
+	jump, err := jumpExistsWithBinary(ctx, executor, binary, table, hook, chain)
+	if err != nil {
+		return TraceResult{}, fmt.Errorf("check jump existence: %w", err)
+	}
+
+	result := EvaluateTrace(chain, rules, ip, port, protocol)
+	result.Source = "live"
+	result.Jump = TraceJumpInactive
+	if jump {
+		result.Jump = TraceJumpActive
+	}
+	return result, nil
+}
+
+// EvaluateTrace walks chain's "-A chain ..." rules in order the way the
+// kernel would for a connection to ip:port over protocol. RETURN ends the
+// walk as an exclusion and DNAT as a redirect; other targets (logging, marks,
+// conntrack) continue. Rules whose matches depend on more than the
+// destination, such as ipset or cgroup matches, are assumed not to match and
+// carry a note saying so.
+func EvaluateTrace(chain string, rules []string, ip net.IP, port int, protocol string) TraceResult {
+	protocol = strings.ToLower(protocol)
+	result := TraceResult{
+		Destination: net.JoinHostPort(ip.String(), strconv.Itoa(port)),
+		Protocol:    protocol,
+		Chain:       chain,
+		Jump:        TraceJumpUnknown,
+		Verdict:     TraceNoMatch,
+		Steps:       make([]TraceStep, 0, len(rules)),
+	}
+
+	for _, rule := range rules {
+		matched, target, note := evaluateTraceRule(rule, ip, port, protocol)
+		result.Steps = append(result.Steps, TraceStep{Rule: rule, Matched: matched, Note: note})
+		if !matched {
+			continue
+		}
+		switch target {
+		case "RETURN":
+			result.Verdict = TraceExcluded
+			result.MatchedRule = rule
+			return result
+		case "DNAT":
+			destination, destPort := splitDestination(ruleValue(rule, "--to-destination"))
+			if destPort == 0 {
+				destPort = port
+			}
+			result.Verdict = TraceDNAT
+			result.Target = net.JoinHostPort(destination, strconv.Itoa(destPort))
+			result.MatchedRule = rule
+			return result
+		}
+	}
+	return result
+}
+
+// evaluateTraceRule reports whether rule matches the connection, the rule's
+// target and, when the rule could not be fully evaluated, why.
+func evaluateTraceRule(rule string, ip net.IP, port int, protocol string) (bool, string, string) {
+	fields := strings.Fields(rule)
+	target := ""
+	matched := true
+	for i := 0; i < len(fields); i++ {
+		var value string
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		switch fields[i] {
+		case "-d":
+			if !destinationMatches(value, ip) {
+				matched = false
+			}
+			i++
+		case "-p":
+			if strings.ToLower(value) != protocol {
+				matched = false
+			}
+			i++
+		case "--dport", "--dports":
+			if !portMatches(value, port) {
+				matched = false
+			}
+			i++
+		case "-j":
+			target = value
+			i++
+		case "--match-set":
+			return false, target, "ipset membership cannot be simulated; assumed not to match"
+		case "--path":
+			return false, target, "cgroup match depends on the sending process; assumed not to match"
+		case "!":
+			return false, target, "negated matches are not simulated; assumed not to match"
+		}
+	}
+	return matched, target, ""
+}
+
+func destinationMatches(value string, ip net.IP) bool {
+	if !strings.Contains(value, "/") {
+		return sameIP(value, ip.String())
+	}
+	_, network, err := net.ParseCIDR(value)
+	return err == nil && network.Contains(ip)
+}
+
+func portMatches(value string, port int) bool {
+	for _, part := range strings.Split(value, ",") {
+		r, err := parsePortRange(part)
+		if err == nil && port >= r.from && port <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleValue returns the argument following flag in rule.
+func ruleValue(rule, flag string) string {
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == flag {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
@@ -1,9 +1,51 @@
 package iptables
 
+import corev1 "k8s.io/api/core/v1"
+
 // Config represents iptables/ip6tables configuration options used during setup.
 type Config struct {
 	ChainName    string
 	ExcludeCIDRs []string
-	IPv6         bool
-	DnatMapPath  string
+	// NotrackCIDRs lists excluded destinations that additionally skip
+	// conntrack via raw-table NOTRACK rules. Each must lie within ExcludeCIDRs.
+	NotrackCIDRs []string
+	// ExclusionIPSet, when set, loads ExcludeCIDRs into ipsets with this base
+	// name and matches them with a single rule per family.
+	ExclusionIPSet string
+	// ExcludeCgroupPaths lists cgroup v2 paths whose traffic bypasses DNAT.
+	ExcludeCgroupPaths []string
+	IPv6               bool
+	// Protocols restricts rule generation to these protocols; empty allows all.
+	Protocols   []corev1.Protocol
+	DnatMapPath string
+	// DnatMapKey, when set, signs the dnat.map with HMAC-SHA256 so readers
+	// holding the same key can detect tampering.
+	DnatMapKey []byte
+	// WholeServiceDNAT emits one address-only DNAT rule for services whose
+	// active and preview port sets match exactly.
+	WholeServiceDNAT bool
+	// Multiport collapses ports sharing an active/preview IP pair into
+	// `-m multiport` rules when no port remapping is needed.
+	Multiport bool
+	// EndpointDNAT sends mappings with resolved preview endpoints straight to
+	// the preview pods instead of the preview ClusterIP.
+	EndpointDNAT bool
+	// EndpointBalance is EndpointBalanceRandom (the default) or
+	// EndpointBalanceNth.
+	EndpointBalance string
+	// Split routes only a share of new connections to preview.
+	Split SplitConfig
+	// HairpinMasquerade marks redirected connections and masquerades them in
+	// POSTROUTING so hairpin flows back into the pod are not dropped.
+	HairpinMasquerade bool
+	// HairpinMark overrides DefaultHairpinMark.
+	HairpinMark string
+	// DebugLog optionally logs packets entering or falling through the chain.
+	DebugLog DebugLogConfig
+	// CTTimeoutPolicy names a conntrack timeout policy attached to UDP flows
+	// towards active services. Empty disables conntrack rules.
+	CTTimeoutPolicy string
+	// CTUDPTimeoutSeconds, when positive, creates CTTimeoutPolicy with nfct
+	// using this timeout instead of expecting it to exist.
+	CTUDPTimeoutSeconds int
 }
@@ -0,0 +1,336 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// Discrepancy kinds reported by VerifyDNATRules.
+const (
+	DiscrepancyMissing     = "missing"
+	DiscrepancyExtra       = "extra"
+	DiscrepancyWrongTarget = "wrong_target"
+)
+
+// Discrepancy describes one difference between the DNAT map and the live
+// chain. Service, Port and Protocol are empty for extra rules, which only
+// carry the live Rule.
+type Discrepancy struct {
+	Kind     string `json:"kind"`
+	Service  string `json:"service,omitempty"`
+	Port     int32  `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	ActiveIP string `json:"activeIP"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// String describes the discrepancy on one line: the mapping and where it
+// should lead, followed by where its live rule leads instead, or the live rule
+// itself for extra rules.
+func (d Discrepancy) String() string {
+	if d.Kind == DiscrepancyExtra {
+		return d.Rule
+	}
+	desc := fmt.Sprintf("%s:%d/%s %s -> %s", d.Service, d.Port, d.Protocol, d.ActiveIP, d.Expected)
+	if d.Actual != "" {
+		desc += ", live " + d.Actual
+	}
+	return desc
+}
+
+// liveDNATRule is a DNAT rule parsed from `iptables -S` output.
+type liveDNATRule struct {
+	raw         string
+	activeIP    string
+	protocol    string
+	ports       []portRange
+	destination string
+	destPort    int
+	used        bool
+	foreign     bool
+}
+
+type portRange struct {
+	from, to int
+}
+
+// ListChainRules returns the `-A chain ...` lines of chain as printed by
+// `iptables -S`.
+func ListChainRules(ctx context.Context, executor Executor, binary string, table string, chain string) ([]string, error) {
+	outputExecutor, ok := executor.(OutputExecutor)
+	if !ok {
+		return nil, errors.New("executor cannot read rule listings")
+	}
+
+	output, err := outputExecutor.Output(ctx, binary, "-w", iptablesWaitSeconds, "-t", table, "-S", chain)
+	if err != nil {
+		return nil, fmt.Errorf("list %s rules: %w", chain, err)
+	}
+
+	prefix := "-A " + chain + " "
+	var rules []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			rules = append(rules, strings.TrimSpace(line))
+		}
+	}
+	return rules, nil
+}
+
+// VerifyDNATRules compares mappings, typically loaded from dnat.map, with the
+// DNAT rules live in chain. Per-port, multiport and whole-service rules are
+// all recognised. A mapping without a covering rule is missing, a covering
+// rule pointing elsewhere is a wrong target, and DNAT rules no mapping uses
+// are extra. Mappings the rule writers skip, such as those mixing address
+// families or missing an IP or port, are not expected. Only ghostwire's own
+// rules can cover a mapping: DNAT rules with another tool's comment are always
+// extra. Non-DNAT rules (exclusions, marks, logging) are ignored.
+func VerifyDNATRules(ctx context.Context, executor Executor, table string, chain string, mappings []discovery.ServiceMapping, ipv6 bool) ([]Discrepancy, error) {
+	binaries := []string{ipv4Binary}
+	if ipv6 {
+		binaries = append(binaries, ipv6Binary)
+	}
+
+	var live []*liveDNATRule
+	for _, bin := range binaries {
+		rules, err := ListChainRules(ctx, executor, bin, table, chain)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if parsed, ok := parseLiveDNATRule(rule); ok {
+				parsed.foreign = !ownedRule(rule)
+				live = append(live, parsed)
+			}
+		}
+	}
+
+	var discrepancies []Discrepancy
+	for _, mapping := range mappings {
+		protocol := strings.ToLower(string(mapping.Protocol))
+		if len(mapping.PreviewEndpoints) > 0 {
+			if endpointSkipReason(mapping, ipv6) != "" {
+				continue
+			}
+			discrepancies = append(discrepancies, verifyEndpointRules(live, mapping, protocol)...)
+			continue
+		}
+		if dnatSkipReason(mapping, ipv6) != "" {
+			continue
+		}
+		rule := findCoveringRule(live, mapping.ActiveClusterIP, protocol, int(mapping.Port))
+		base := Discrepancy{
+			Service:  mapping.ServiceName,
+			Port:     mapping.Port,
+			Protocol: string(mapping.Protocol),
+			ActiveIP: mapping.ActiveClusterIP,
+			Expected: net.JoinHostPort(mapping.PreviewClusterIP, strconv.Itoa(int(mapping.TargetPort()))),
+		}
+		if rule == nil {
+			base.Kind = DiscrepancyMissing
+			discrepancies = append(discrepancies, base)
+			continue
+		}
+		rule.used = true
+		if !rule.targets(mapping) {
+			base.Kind = DiscrepancyWrongTarget
+			base.Actual = rule.destinationString()
+			base.Rule = rule.raw
+			discrepancies = append(discrepancies, base)
+		}
+	}
+
+	for _, rule := range live {
+		if rule.used {
+			continue
+		}
+		discrepancies = append(discrepancies, Discrepancy{
+			Kind:     DiscrepancyExtra,
+			ActiveIP: rule.activeIP,
+			Actual:   rule.destinationString(),
+			Rule:     rule.raw,
+		})
+	}
+
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		if discrepancies[i].Kind != discrepancies[j].Kind {
+			return discrepancies[i].Kind < discrepancies[j].Kind
+		}
+		return discrepancies[i].Service < discrepancies[j].Service
+	})
+	return discrepancies, nil
+}
+
+// verifyEndpointRules checks the balanced rules of an endpoint-level mapping:
+// every preview endpoint needs a rule, and every per-port rule for the
+// mapping's address and port must target one of them.
+func verifyEndpointRules(live []*liveDNATRule, mapping discovery.ServiceMapping, protocol string) []Discrepancy {
+	base := Discrepancy{
+		Service:  mapping.ServiceName,
+		Port:     mapping.Port,
+		Protocol: string(mapping.Protocol),
+		ActiveIP: mapping.ActiveClusterIP,
+	}
+
+	expected := make(map[string]bool, len(mapping.PreviewEndpoints))
+	for _, endpoint := range mapping.PreviewEndpoints {
+		if isIPv6(endpoint.IP) == isIPv6(mapping.ActiveClusterIP) {
+			expected[net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))] = true
+		}
+	}
+
+	var discrepancies []Discrepancy
+	found := make(map[string]bool)
+	for _, rule := range live {
+		if rule.foreign || !sameIP(rule.activeIP, mapping.ActiveClusterIP) || rule.protocol != protocol || !rule.coversPort(int(mapping.Port)) {
+			continue
+		}
+		rule.used = true
+		destination := rule.destinationString()
+		if expected[destination] {
+			found[destination] = true
+			continue
+		}
+		wrong := base
+		wrong.Kind = DiscrepancyWrongTarget
+		wrong.Actual = destination
+		wrong.Rule = rule.raw
+		discrepancies = append(discrepancies, wrong)
+	}
+
+	for _, endpoint := range mapping.PreviewEndpoints {
+		destination := net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))
+		if !expected[destination] || found[destination] {
+			continue
+		}
+		missing := base
+		missing.Kind = DiscrepancyMissing
+		missing.Expected = destination
+		discrepancies = append(discrepancies, missing)
+	}
+	return discrepancies
+}
+
+func (r *liveDNATRule) coversPort(port int) bool {
+	for _, ports := range r.ports {
+		if port >= ports.from && port <= ports.to {
+			return true
+		}
+	}
+	return false
+}
+
+// findCoveringRule prefers per-port and multiport rules over whole-service
+// ones, matching the order Setup installs them in.
+func findCoveringRule(live []*liveDNATRule, activeIP, protocol string, port int) *liveDNATRule {
+	var whole *liveDNATRule
+	for _, rule := range live {
+		if rule.foreign || !sameIP(rule.activeIP, activeIP) {
+			continue
+		}
+		if rule.protocol == "" && len(rule.ports) == 0 {
+			if whole == nil {
+				whole = rule
+			}
+			continue
+		}
+		if rule.protocol == protocol && rule.coversPort(port) {
+			return rule
+		}
+	}
+	return whole
+}
+
+// targets reports whether the rule sends mapping's traffic to its preview.
+// Rules without a destination port keep the original port, which is only
+// right when the mapping doesn't remap it.
+func (r *liveDNATRule) targets(mapping discovery.ServiceMapping) bool {
+	if !sameIP(r.destination, mapping.PreviewClusterIP) {
+		return false
+	}
+	if r.destPort == 0 {
+		return mapping.TargetPort() == mapping.Port
+	}
+	return r.destPort == int(mapping.TargetPort())
+}
+
+func (r *liveDNATRule) destinationString() string {
+	if r.destPort == 0 {
+		return r.destination
+	}
+	return net.JoinHostPort(r.destination, strconv.Itoa(r.destPort))
+}
+
+func sameIP(a, b string) bool {
+	pa, pb := net.ParseIP(a), net.ParseIP(b)
+	if pa == nil || pb == nil {
+		return a == b
+	}
+	return pa.Equal(pb)
+}
+
+// parseLiveDNATRule extracts the match and target of a DNAT rule.
+func parseLiveDNATRule(rule string) (*liveDNATRule, bool) {
+	fields := strings.Fields(rule)
+	parsed := &liveDNATRule{raw: rule}
+	isDNAT := false
+	for i := 0; i < len(fields)-1; i++ {
+		value := fields[i+1]
+		switch fields[i] {
+		case "-d":
+			parsed.activeIP = strings.SplitN(value, "/", 2)[0]
+		case "-p":
+			parsed.protocol = strings.ToLower(value)
+		case "--dport", "--dports":
+			for _, part := range strings.Split(value, ",") {
+				r, err := parsePortRange(part)
+				if err != nil {
+					return nil, false
+				}
+				parsed.ports = append(parsed.ports, r)
+			}
+		case "-j":
+			isDNAT = value == "DNAT"
+		case "--to-destination":
+			parsed.destination, parsed.destPort = splitDestination(value)
+		}
+	}
+	if !isDNAT || parsed.activeIP == "" || parsed.destination == "" {
+		return nil, false
+	}
+	return parsed, true
+}
+
+func parsePortRange(raw string) (portRange, error) {
+	from, to, isRange := strings.Cut(raw, ":")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return portRange{}, err
+	}
+	if !isRange {
+		return portRange{from: start, to: start}, nil
+	}
+	end, err := strconv.Atoi(to)
+	if err != nil {
+		return portRange{}, err
+	}
+	return portRange{from: start, to: end}, nil
+}
+
+// splitDestination accepts "ip", "ip:port" and "[ipv6]:port".
+func splitDestination(value string) (string, int) {
+	if host, port, err := net.SplitHostPort(value); err == nil {
+		p, _ := strconv.Atoi(port)
+		return host, p
+	}
+	return strings.Trim(value, "[]"), 0
+}
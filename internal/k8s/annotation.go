@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AnnotationPreviewPercent holds the share of new connections, from 0 to 100,
+// routed to preview. The watcher reads it from the pod or a ConfigMap.
+const AnnotationPreviewPercent = "ghostwire.dev/preview-percent"
+
+// AnnotationReader reads annotations from one Pod or ConfigMap.
+type AnnotationReader struct {
+	client    kubernetes.Interface
+	namespace string
+	kind      string
+	name      string
+}
+
+// NewPodAnnotationReader reads the annotations of a Pod.
+func NewPodAnnotationReader(client kubernetes.Interface, namespace, podName string) *AnnotationReader {
+	return &AnnotationReader{client: client, namespace: namespace, kind: "pod", name: podName}
+}
+
+// NewConfigMapAnnotationReader reads the annotations of a ConfigMap, so one
+// object can steer every pod that points at it.
+func NewConfigMapAnnotationReader(client kubernetes.Interface, namespace, name string) *AnnotationReader {
+	return &AnnotationReader{client: client, namespace: namespace, kind: "configmap", name: name}
+}
+
+// GetAnnotation returns the value of key and whether the object carries it.
+func (r *AnnotationReader) GetAnnotation(ctx context.Context, key string) (string, bool, error) {
+	var annotations map[string]string
+	switch r.kind {
+	case "configmap":
+		cm, err := r.client.CoreV1().ConfigMaps(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("get configmap %s/%s for annotation %q: %w", r.namespace, r.name, key, err)
+		}
+		annotations = cm.Annotations
+	default:
+		pod, err := r.client.CoreV1().Pods(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("get pod %s/%s for annotation %q: %w", r.namespace, r.name, key, err)
+		}
+		annotations = pod.Annotations
+	}
+
+	value, ok := annotations[key]
+	return value, ok, nil
+}
+
+// String names the object read, as kind/name.
+func (r *AnnotationReader) String() string {
+	return r.kind + "/" + r.name
+}
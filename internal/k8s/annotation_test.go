@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnnotationReader(t *testing.T) {
+	t.Parallel()
+
+	pod := newTestPod(nil)
+	pod.Annotations = map[string]string{AnnotationPreviewPercent: "25"}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "ghostwire",
+		Name:        "rollout",
+		Annotations: map[string]string{AnnotationPreviewPercent: "5"},
+	}}
+	client := fake.NewSimpleClientset(pod, cm)
+	ctx := context.Background()
+
+	value, ok, err := NewPodAnnotationReader(client, "ghostwire", "ghostwire-watcher").GetAnnotation(ctx, AnnotationPreviewPercent)
+	if err != nil || !ok || value != "25" {
+		t.Fatalf("pod annotation = %q, %t, %v; want 25", value, ok, err)
+	}
+
+	reader := NewConfigMapAnnotationReader(client, "ghostwire", "rollout")
+	value, ok, err = reader.GetAnnotation(ctx, AnnotationPreviewPercent)
+	if err != nil || !ok || value != "5" {
+		t.Fatalf("configmap annotation = %q, %t, %v; want 5", value, ok, err)
+	}
+	if _, ok, err := reader.GetAnnotation(ctx, "missing"); err != nil || ok {
+		t.Fatalf("missing annotation = %t, %v; want absent", ok, err)
+	}
+	if reader.String() != "configmap/rollout" {
+		t.Fatalf("String() = %q", reader.String())
+	}
+
+	_, _, err = NewConfigMapAnnotationReader(client, "ghostwire", "gone").GetAnnotation(ctx, AnnotationPreviewPercent)
+	if err == nil || !containsString(err.Error(), "get configmap ghostwire/gone") {
+		t.Fatalf("expected configmap error, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Routing status annotations the watcher maintains on its own Pod.
+const (
+	AnnotationRole           = "ghostwire.dev/role"
+	AnnotationJumpActive     = "ghostwire.dev/jump-active"
+	AnnotationLastTransition = "ghostwire.dev/last-transition"
+	AnnotationRuleCount      = "ghostwire.dev/rule-count"
+	AnnotationLastError      = "ghostwire.dev/last-error"
+)
+
+// StatusAnnotations lists every annotation written by PodAnnotator callers so
+// admission validation can recognise them.
+var StatusAnnotations = []string{
+	AnnotationRole,
+	AnnotationJumpActive,
+	AnnotationLastTransition,
+	AnnotationRuleCount,
+	AnnotationLastError,
+}
+
+// PodAnnotator merges annotations into a Pod. The Pod's ServiceAccount needs
+// patch permission on pods.
+type PodAnnotator struct {
+	client    kubernetes.Interface
+	namespace string
+	podName   string
+}
+
+// NewPodAnnotator constructs a PodAnnotator for the given pod reference.
+func NewPodAnnotator(client kubernetes.Interface, namespace, podName string) *PodAnnotator {
+	return &PodAnnotator{
+		client:    client,
+		namespace: namespace,
+		podName:   podName,
+	}
+}
+
+// Annotate applies annotations with a JSON merge patch. A nil value removes
+// the annotation; other annotations on the Pod are left untouched.
+func (a *PodAnnotator) Annotate(ctx context.Context, annotations map[string]*string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return fmt.Errorf("encode annotation patch: %w", err)
+	}
+
+	if _, err := a.client.CoreV1().Pods(a.namespace).Patch(ctx, a.podName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("patch pod %s/%s annotations: %w", a.namespace, a.podName, err)
+	}
+	return nil
+}
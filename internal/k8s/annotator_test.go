@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodAnnotatorAnnotate(t *testing.T) {
+	t.Parallel()
+
+	pod := newTestPod(map[string]string{"role": "preview"})
+	pod.Annotations = map[string]string{"keep": "me", AnnotationLastError: "old failure"}
+	client := fake.NewSimpleClientset(pod)
+	annotator := NewPodAnnotator(client, pod.Namespace, pod.Name)
+
+	active := "true"
+	err := annotator.Annotate(context.Background(), map[string]*string{
+		AnnotationJumpActive: &active,
+		AnnotationLastError:  nil,
+	})
+	if err != nil {
+		t.Fatalf("Annotate returned error: %v", err)
+	}
+
+	updated, err := client.CoreV1().Pods(pod.Namespace).Get(context.Background(), pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	if updated.Annotations[AnnotationJumpActive] != "true" {
+		t.Fatalf("expected jump-active annotation, got %v", updated.Annotations)
+	}
+	if _, ok := updated.Annotations[AnnotationLastError]; ok {
+		t.Fatalf("expected last-error annotation to be removed, got %v", updated.Annotations)
+	}
+	if updated.Annotations["keep"] != "me" {
+		t.Fatalf("expected unrelated annotations to survive, got %v", updated.Annotations)
+	}
+}
+
+func TestPodAnnotatorMissingPod(t *testing.T) {
+	t.Parallel()
+
+	annotator := NewPodAnnotator(fake.NewSimpleClientset(), "ghostwire", "absent")
+	if err := annotator.Annotate(context.Background(), map[string]*string{}); err == nil {
+		t.Fatalf("expected error for missing pod")
+	}
+}
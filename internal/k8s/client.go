@@ -3,18 +3,25 @@ package k8s
 import (
 	"fmt"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+
+	"github.com/denniswebb/ghostwire/internal/client/clientset/versioned"
 )
 
 // NewInClusterClient creates a Kubernetes clientset using the Pod's service account.
 // The Pod must run with a ServiceAccount that has RBAC permissions to access the
-// resources it needs (for the watcher, read its own Pod object).
-func NewInClusterClient() (*kubernetes.Clientset, error) {
+// resources it needs (for the watcher, read its own Pod object). Each observer
+// is told about every request the clientset makes.
+func NewInClusterClient(observers ...RequestObserver) (*kubernetes.Clientset, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, fmt.Errorf("build in-cluster config: %w", err)
 	}
+	for _, observer := range observers {
+		observeRequests(config, observer)
+	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -23,3 +30,35 @@ func NewInClusterClient() (*kubernetes.Clientset, error) {
 
 	return clientset, nil
 }
+
+// NewInClusterDynamicClient creates a dynamic client for reading ghostwire custom
+// resources. The ServiceAccount needs get/list/watch on the relevant resources.
+func NewInClusterDynamicClient() (dynamic.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("build in-cluster config: %w", err)
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+
+	return client, nil
+}
+
+// NewInClusterGhostwireClient creates a typed client for the ghostwire.dev
+// resources using the Pod's service account.
+func NewInClusterGhostwireClient() (versioned.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("build in-cluster config: %w", err)
+	}
+
+	client, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create ghostwire client: %w", err)
+	}
+
+	return client, nil
+}
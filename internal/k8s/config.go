@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/client/clientset/versioned"
+)
+
+const defaultConfigRetryInterval = 5 * time.Second
+
+// ConfigSource reads and watches a single GhostwireConfig object.
+type ConfigSource struct {
+	client        versioned.Interface
+	namespace     string
+	name          string
+	retryInterval time.Duration
+	logger        *slog.Logger
+}
+
+// NewConfigSource constructs a ConfigSource for the named GhostwireConfig.
+func NewConfigSource(client versioned.Interface, namespace, name string, logger *slog.Logger) *ConfigSource {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ConfigSource{
+		client:        client,
+		namespace:     namespace,
+		name:          name,
+		retryInterval: defaultConfigRetryInterval,
+		logger:        logger,
+	}
+}
+
+// Get fetches and validates the GhostwireConfig.
+func (s *ConfigSource) Get(ctx context.Context) (*v1alpha1.GhostwireConfig, error) {
+	cfg, err := s.client.GhostwireV1alpha1().GhostwireConfigs(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get ghostwireconfig %s/%s: %w", s.namespace, s.name, err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Watch streams changes to the GhostwireConfig until ctx is cancelled, invoking
+// onChange for every valid added or modified object. Invalid objects are logged
+// and skipped so a bad edit never tears down working routing. The watch is
+// re-established after errors or server-side timeouts.
+func (s *ConfigSource) Watch(ctx context.Context, onChange func(*v1alpha1.GhostwireConfig)) {
+	selector := fields.OneTermEqualSelector("metadata.name", s.name).String()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		w, err := s.client.GhostwireV1alpha1().GhostwireConfigs(s.namespace).Watch(ctx, metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			s.logger.Warn("failed to watch ghostwireconfig",
+				slog.String("namespace", s.namespace),
+				slog.String("name", s.name),
+				slog.Any("error", err),
+			)
+		} else {
+			s.consume(ctx, w, onChange)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
+func (s *ConfigSource) consume(ctx context.Context, w watch.Interface, onChange func(*v1alpha1.GhostwireConfig)) {
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				cfg, ok := event.Object.(*v1alpha1.GhostwireConfig)
+				if !ok {
+					continue
+				}
+				if err := validateConfig(cfg); err != nil {
+					s.logger.Warn("ignoring invalid ghostwireconfig",
+						slog.String("namespace", s.namespace),
+						slog.String("name", s.name),
+						slog.Any("error", err),
+					)
+					continue
+				}
+				onChange(cfg)
+			case watch.Deleted:
+				s.logger.Warn("ghostwireconfig deleted; keeping last applied settings",
+					slog.String("namespace", s.namespace),
+					slog.String("name", s.name),
+				)
+			case watch.Error:
+				s.logger.Warn("ghostwireconfig watch error",
+					slog.String("namespace", s.namespace),
+					slog.String("name", s.name),
+				)
+				return
+			}
+		}
+	}
+}
+
+func validateConfig(cfg *v1alpha1.GhostwireConfig) error {
+	if err := cfg.Spec.Validate(); err != nil {
+		return fmt.Errorf("validate ghostwireconfig %s/%s: %w", cfg.Namespace, cfg.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+	"github.com/denniswebb/ghostwire/internal/client/clientset/versioned/fake"
+)
+
+func newGhostwireConfig(name string, spec v1alpha1.GhostwireConfigSpec) *v1alpha1.GhostwireConfig {
+	return &v1alpha1.GhostwireConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec:       spec,
+	}
+}
+
+func TestConfigSourceGet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		wantChain   string
+		wantCIDRs   []string
+		expectError string
+	}{
+		{
+			name: "valid config",
+			objects: []runtime.Object{newGhostwireConfig("ghostwire", v1alpha1.GhostwireConfigSpec{
+				NATChain:     "PREVIEW_DNAT",
+				JumpHook:     "PREROUTING",
+				ExcludeCIDRs: []string{"10.0.0.0/8"},
+			})},
+			wantChain: "PREVIEW_DNAT",
+			wantCIDRs: []string{"10.0.0.0/8"},
+		},
+		{
+			name: "invalid hook rejected",
+			objects: []runtime.Object{newGhostwireConfig("ghostwire", v1alpha1.GhostwireConfigSpec{
+				JumpHook: "INPUT",
+			})},
+			expectError: "jumpHook",
+		},
+		{
+			name:        "missing object",
+			expectError: "not found",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := NewConfigSource(fake.NewSimpleClientset(tc.objects...), "shop", "ghostwire", nil)
+			cfg, err := source.Get(context.Background())
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Spec.NATChain != tc.wantChain {
+				t.Fatalf("unexpected chain: got %q want %q", cfg.Spec.NATChain, tc.wantChain)
+			}
+			if strings.Join(cfg.Spec.ExcludeCIDRs, ",") != strings.Join(tc.wantCIDRs, ",") {
+				t.Fatalf("unexpected cidrs: got %v want %v", cfg.Spec.ExcludeCIDRs, tc.wantCIDRs)
+			}
+		})
+	}
+}
+
+func TestConfigSourceWatchDeliversUpdates(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	source := NewConfigSource(client, "shop", "ghostwire", nil)
+	source.retryInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var hooks []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		source.Watch(ctx, func(cfg *v1alpha1.GhostwireConfig) {
+			mu.Lock()
+			hooks = append(hooks, cfg.Spec.JumpHook)
+			mu.Unlock()
+		})
+	}()
+
+	configs := client.GhostwireV1alpha1().GhostwireConfigs("shop")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		// The fake only delivers events to watches established before the
+		// mutation, so keep retrying the create until one is observed.
+		_, _ = configs.Create(ctx, newGhostwireConfig("ghostwire", v1alpha1.GhostwireConfigSpec{JumpHook: "OUTPUT"}), metav1.CreateOptions{})
+		_, _ = configs.Update(ctx, newGhostwireConfig("ghostwire", v1alpha1.GhostwireConfigSpec{JumpHook: "PREROUTING"}), metav1.UpdateOptions{})
+
+		mu.Lock()
+		count := len(hooks)
+		last := ""
+		if count > 0 {
+			last = hooks[count-1]
+		}
+		mu.Unlock()
+		if last == "PREROUTING" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for watch events, got %v", hooks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watch did not stop after cancel")
+	}
+}
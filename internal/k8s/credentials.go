@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceAccountTokenFile is where the kubelet projects the Pod's service
+// account token. Clients built by NewInClusterClient re-read it as the kubelet
+// rotates it, so bound tokens keep working without a restart.
+const ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// ErrCredentialsRejected means the API server no longer accepts the service
+// account token.
+var ErrCredentialsRejected = errors.New("service account token rejected by the API server")
+
+// TokenExpiry returns the exp claim of the JWT in path. Legacy tokens without
+// an expiry return the zero time.
+func TokenExpiry(path string) (time.Time, error) {
+	// #nosec G304 -- path is the projected token location.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read service account token: %w", err)
+	}
+
+	parts := strings.Split(strings.TrimSpace(string(raw)), ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("service account token in %s is not a JWT", path)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode service account token claims: %w", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("parse service account token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// CheckCredentials makes an authenticated request that any service account
+// may make, so a failure points at the token rather than at RBAC. A 401 is
+// reported as ErrCredentialsRejected.
+func CheckCredentials(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  "pods",
+			},
+		},
+	}
+	_, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if apierrors.IsUnauthorized(err) {
+		return fmt.Errorf("%w: %v", ErrCredentialsRejected, err)
+	}
+	if err != nil {
+		return fmt.Errorf("check credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func writeToken(t *testing.T, claims string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "token")
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	return path
+}
+
+func TestTokenExpiry(t *testing.T) {
+	t.Parallel()
+
+	expiry, err := TokenExpiry(writeToken(t, `{"exp":1700000000,"sub":"system:serviceaccount:shop:web"}`))
+	if err != nil {
+		t.Fatalf("TokenExpiry returned error: %v", err)
+	}
+	if !expiry.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("expiry = %v, want %v", expiry, time.Unix(1700000000, 0))
+	}
+
+	expiry, err = TokenExpiry(writeToken(t, `{"sub":"legacy"}`))
+	if err != nil || !expiry.IsZero() {
+		t.Fatalf("legacy token: expiry %v, err %v; want zero time", expiry, err)
+	}
+
+	if _, err := TokenExpiry(writeToken(t, `not json`)); err == nil {
+		t.Fatal("expected error for malformed claims")
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	if err := CheckCredentials(context.Background(), client, "shop"); err != nil {
+		t.Fatalf("CheckCredentials returned error: %v", err)
+	}
+
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewUnauthorized("token expired")
+	})
+	err := CheckCredentials(context.Background(), client, "shop")
+	if !errors.Is(err, ErrCredentialsRejected) {
+		t.Fatalf("expected ErrCredentialsRejected, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// EventReasonPreviewPairing is the reason on Events recorded for each active
+// Service init pairs with a preview Service.
+const EventReasonPreviewPairing = "PreviewPairing"
+
+const eventComponent = "ghostwire-init"
+
+// ServicePairing summarises the mappings for one active Service.
+type ServicePairing struct {
+	Service        string
+	PreviewService string
+	Ports          []string
+}
+
+// GroupPairings collapses per-port mappings into one pairing per active
+// Service, sorted by Service name. Ports are rendered as "port/PROTOCOL".
+func GroupPairings(mappings []discovery.ServiceMapping) []ServicePairing {
+	index := make(map[string]int)
+	var pairings []ServicePairing
+	for _, m := range mappings {
+		i, ok := index[m.ServiceName]
+		if !ok {
+			i = len(pairings)
+			index[m.ServiceName] = i
+			pairings = append(pairings, ServicePairing{Service: m.ServiceName, PreviewService: m.PreviewServiceName})
+		}
+		pairings[i].Ports = append(pairings[i].Ports, fmt.Sprintf("%d/%s", m.Port, m.Protocol))
+	}
+
+	sort.Slice(pairings, func(a, b int) bool { return pairings[a].Service < pairings[b].Service })
+	return pairings
+}
+
+// ServiceEventRecorder records Events on active Services so their owners can
+// see that traffic from preview pods is rerouted. The ServiceAccount needs get
+// on services and create on events.
+type ServiceEventRecorder struct {
+	client    kubernetes.Interface
+	namespace string
+	podName   string
+	now       func() time.Time
+}
+
+// NewServiceEventRecorder constructs a ServiceEventRecorder. podName, when
+// set, is named in the Event message and used as the reporting instance.
+func NewServiceEventRecorder(client kubernetes.Interface, namespace, podName string) *ServiceEventRecorder {
+	return &ServiceEventRecorder{
+		client:    client,
+		namespace: namespace,
+		podName:   podName,
+		now:       time.Now,
+	}
+}
+
+// RecordPairing creates a Normal Event on the active Service describing the
+// pairing and the ports it covers.
+func (r *ServiceEventRecorder) RecordPairing(ctx context.Context, pairing ServicePairing) error {
+	svc, err := r.client.CoreV1().Services(r.namespace).Get(ctx, pairing.Service, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get service %s/%s: %w", r.namespace, pairing.Service, err)
+	}
+
+	preview := pairing.PreviewService
+	if preview == "" {
+		preview = "its preview service"
+	}
+	message := fmt.Sprintf("Traffic to ports %s may be rerouted to %s", strings.Join(pairing.Ports, ", "), preview)
+	if r.podName != "" {
+		message = fmt.Sprintf("Traffic from preview pod %s to ports %s is rerouted to %s", r.podName, strings.Join(pairing.Ports, ", "), preview)
+	}
+
+	now := metav1.NewTime(r.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", svc.Name, now.UnixNano()),
+			Namespace: r.namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "Service",
+			Name:            svc.Name,
+			Namespace:       svc.Namespace,
+			UID:             svc.UID,
+			ResourceVersion: svc.ResourceVersion,
+		},
+		Reason:              EventReasonPreviewPairing,
+		Message:             message,
+		Type:                corev1.EventTypeNormal,
+		Source:              corev1.EventSource{Component: eventComponent},
+		ReportingController: "ghostwire.dev/init",
+		ReportingInstance:   r.podName,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+
+	if _, err := r.client.CoreV1().Events(r.namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("create event for service %s/%s: %w", r.namespace, pairing.Service, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+func TestGroupPairings(t *testing.T) {
+	t.Parallel()
+
+	got := GroupPairings([]discovery.ServiceMapping{
+		{ServiceName: "orders", PreviewServiceName: "orders-preview", Port: 80, Protocol: corev1.ProtocolTCP},
+		{ServiceName: "cart", PreviewServiceName: "cart-preview", Port: 53, Protocol: corev1.ProtocolUDP},
+		{ServiceName: "orders", PreviewServiceName: "orders-preview", Port: 443, Protocol: corev1.ProtocolTCP},
+	})
+
+	if len(got) != 2 || got[0].Service != "cart" || got[1].Service != "orders" {
+		t.Fatalf("unexpected pairings: %+v", got)
+	}
+	if strings.Join(got[1].Ports, ",") != "80/TCP,443/TCP" {
+		t.Fatalf("unexpected ports: %v", got[1].Ports)
+	}
+}
+
+func TestServiceEventRecorderRecordPairing(t *testing.T) {
+	t.Parallel()
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", UID: "svc-uid"}}
+	client := fake.NewSimpleClientset(svc)
+	recorder := NewServiceEventRecorder(client, "shop", "orders-preview-7d9")
+	recorder.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	err := recorder.RecordPairing(context.Background(), ServicePairing{
+		Service:        "orders",
+		PreviewService: "orders-preview",
+		Ports:          []string{"80/TCP", "443/TCP"},
+	})
+	if err != nil {
+		t.Fatalf("RecordPairing returned error: %v", err)
+	}
+
+	events, err := client.CoreV1().Events("shop").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expected one event, got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "Service" || event.InvolvedObject.UID != "svc-uid" {
+		t.Fatalf("unexpected involved object: %+v", event.InvolvedObject)
+	}
+	if event.Reason != EventReasonPreviewPairing || event.Type != corev1.EventTypeNormal {
+		t.Fatalf("unexpected reason/type: %s/%s", event.Reason, event.Type)
+	}
+	want := "Traffic from preview pod orders-preview-7d9 to ports 80/TCP, 443/TCP is rerouted to orders-preview"
+	if event.Message != want {
+		t.Fatalf("unexpected message %q", event.Message)
+	}
+}
+
+func TestServiceEventRecorderMissingService(t *testing.T) {
+	t.Parallel()
+
+	recorder := NewServiceEventRecorder(fake.NewSimpleClientset(), "shop", "")
+	if err := recorder.RecordPairing(context.Background(), ServicePairing{Service: "absent"}); err == nil {
+		t.Fatalf("expected error for missing service")
+	}
+}
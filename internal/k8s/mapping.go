@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+// MappingSource lists GhostwireMapping overrides in a namespace and records the
+// outcome of applying them in each object's status.
+type MappingSource struct {
+	client    dynamic.Interface
+	namespace string
+}
+
+// NewMappingSource constructs a MappingSource for the namespace.
+func NewMappingSource(client dynamic.Interface, namespace string) *MappingSource {
+	return &MappingSource{client: client, namespace: namespace}
+}
+
+// List returns every GhostwireMapping in the namespace. Specs are not validated
+// here so callers can report validation failures through the status.
+func (s *MappingSource) List(ctx context.Context) ([]v1alpha1.GhostwireMapping, error) {
+	list, err := s.client.Resource(v1alpha1.GhostwireMappingResource).Namespace(s.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list ghostwiremappings in namespace %q: %w", s.namespace, err)
+	}
+
+	mappings := make([]v1alpha1.GhostwireMapping, 0, len(list.Items))
+	for i := range list.Items {
+		var mapping v1alpha1.GhostwireMapping
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), &mapping); err != nil {
+			return nil, fmt.Errorf("decode ghostwiremapping %s/%s: %w", s.namespace, list.Items[i].GetName(), err)
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+// UpdateStatus writes the conflicts observed for the mapping. The write is
+// skipped when the status already reflects the current generation and
+// conflicts, so many pods running init do not hammer the API server.
+func (s *MappingSource) UpdateStatus(ctx context.Context, mapping *v1alpha1.GhostwireMapping, conflicts []string) error {
+	if mapping.Status.ObservedGeneration == mapping.Generation && slices.Equal(mapping.Status.Conflicts, conflicts) {
+		return nil
+	}
+
+	updated := mapping.DeepCopy()
+	updated.APIVersion = v1alpha1.SchemeGroupVersion.String()
+	updated.Kind = "GhostwireMapping"
+	updated.Status = v1alpha1.GhostwireMappingStatus{
+		ObservedGeneration: mapping.Generation,
+		Conflicts:          conflicts,
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updated)
+	if err != nil {
+		return fmt.Errorf("encode ghostwiremapping %s/%s: %w", s.namespace, mapping.Name, err)
+	}
+
+	_, err = s.client.Resource(v1alpha1.GhostwireMappingResource).Namespace(s.namespace).UpdateStatus(ctx, &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("update ghostwiremapping %s/%s status: %w", s.namespace, mapping.Name, err)
+	}
+
+	return nil
+}
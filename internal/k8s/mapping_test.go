@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+func newGhostwireMapping(name string, generation int64, spec map[string]interface{}, conflicts []interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1alpha1.SchemeGroupVersion.String(),
+		"kind":       "GhostwireMapping",
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  "shop",
+			"generation": generation,
+		},
+		"spec": spec,
+	}}
+	if conflicts != nil {
+		obj.Object["status"] = map[string]interface{}{
+			"observedGeneration": generation,
+			"conflicts":          conflicts,
+		}
+	}
+	return obj
+}
+
+func TestMappingSourceListAndUpdateStatus(t *testing.T) {
+	t.Parallel()
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.GhostwireMappingResource: "GhostwireMappingList"},
+		newGhostwireMapping("orders", 2, map[string]interface{}{
+			"service":        "orders",
+			"previewService": "orders-canary",
+			"ports":          []interface{}{map[string]interface{}{"port": int64(80), "previewPort": int64(8080)}},
+		}, nil),
+		newGhostwireMapping("stable", 1, map[string]interface{}{"service": "billing", "exclude": true}, []interface{}{}),
+	)
+	source := NewMappingSource(client, "shop")
+
+	ctx := context.Background()
+	mappings, err := source.List(ctx)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("expected 2 mappings, got %d", len(mappings))
+	}
+
+	byName := map[string]*v1alpha1.GhostwireMapping{}
+	for i := range mappings {
+		byName[mappings[i].Name] = &mappings[i]
+	}
+	orders := byName["orders"]
+	if orders == nil || orders.Spec.PreviewService != "orders-canary" || len(orders.Spec.Ports) != 1 || orders.Spec.Ports[0].PreviewPort != 8080 {
+		t.Fatalf("unexpected decoded mapping: %+v", orders)
+	}
+
+	client.ClearActions()
+	if err := source.UpdateStatus(ctx, orders, []string{"preview service \"orders-canary\" not found"}); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+	if err := source.UpdateStatus(ctx, byName["stable"], nil); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+
+	var updates []k8stesting.UpdateAction
+	for _, action := range client.Actions() {
+		if update, ok := action.(k8stesting.UpdateAction); ok && action.GetSubresource() == "status" {
+			updates = append(updates, update)
+		}
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected exactly one status update (unchanged status skipped), got %d", len(updates))
+	}
+
+	stored, err := client.Resource(v1alpha1.GhostwireMappingResource).Namespace("shop").Get(ctx, "orders", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get updated mapping: %v", err)
+	}
+	conflicts, _, _ := unstructured.NestedStringSlice(stored.Object, "status", "conflicts")
+	if len(conflicts) != 1 {
+		t.Fatalf("expected stored conflicts, got %v", conflicts)
+	}
+	observed, _, _ := unstructured.NestedInt64(stored.Object, "status", "observedGeneration")
+	if observed != 2 {
+		t.Fatalf("expected observedGeneration 2, got %d", observed)
+	}
+}
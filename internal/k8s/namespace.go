@@ -0,0 +1,35 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NamespaceLabelProduction marks a namespace as production. Tooling that
+// deliberately disrupts routing, such as chaos flapping, refuses to run there.
+const NamespaceLabelProduction = "ghostwire.dev/production"
+
+// IsProductionNamespace reports whether namespace carries
+// NamespaceLabelProduction with a true value. The caller's ServiceAccount
+// needs get permission on namespaces.
+func IsProductionNamespace(ctx context.Context, client kubernetes.Interface, namespace string) (bool, error) {
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get namespace %s: %w", namespace, err)
+	}
+
+	raw, ok := ns.Labels[NamespaceLabelProduction]
+	if !ok {
+		return false, nil
+	}
+	production, err := strconv.ParseBool(raw)
+	if err != nil {
+		// Treat a malformed marker as production so chaos stays off.
+		return true, nil
+	}
+	return production, nil
+}
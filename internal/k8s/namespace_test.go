@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsProductionNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{name: "unlabeled", want: false},
+		{name: "explicitly false", labels: map[string]string{NamespaceLabelProduction: "false"}, want: false},
+		{name: "true", labels: map[string]string{NamespaceLabelProduction: "true"}, want: true},
+		{name: "malformed", labels: map[string]string{NamespaceLabelProduction: "prod"}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: tc.labels},
+			})
+			got, err := IsProductionNamespace(context.Background(), client, "apps")
+			if err != nil {
+				t.Fatalf("IsProductionNamespace returned error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("IsProductionNamespace = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := IsProductionNamespace(context.Background(), fake.NewSimpleClientset(), "absent"); err == nil {
+		t.Fatalf("expected error for missing namespace")
+	}
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// RequestObserver receives the outcome of each API request made by a client
+// built with an observer. code is the HTTP status, or 0 when the request
+// failed before a response arrived; resource is the plural resource name
+// from the request path ("pods", "services", ...).
+type RequestObserver func(verb, resource string, code int, duration time.Duration)
+
+// observeRequests wraps config's transport so every request is reported to
+// observer.
+func observeRequests(config *rest.Config, observer RequestObserver) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &observedTransport{next: rt, observer: observer}
+	})
+}
+
+type observedTransport struct {
+	next     http.RoundTripper
+	observer RequestObserver
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := 0
+	if err == nil {
+		code = resp.StatusCode
+	}
+	t.observer(req.Method, apiResource(req.URL.Path), code, time.Since(start))
+	return resp, err
+}
+
+// apiResource extracts the resource from a core (/api/v1/...) or group
+// (/apis/<group>/<version>/...) request path, ignoring namespaces, object
+// names and subresources so the result is safe as a metric label.
+func apiResource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var rest []string
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		rest = parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		rest = parts[3:]
+	default:
+		return "other"
+	}
+
+	if rest[0] == "namespaces" && len(rest) > 2 {
+		return rest[2]
+	}
+	return rest[0]
+}
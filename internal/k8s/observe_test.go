@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestAPIResource(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]string{
+		"/api/v1/namespaces/shop/pods/web-0": "pods",
+		"/api/v1/namespaces/shop/services":   "services",
+		"/api/v1/namespaces/shop":            "namespaces",
+		"/api/v1/nodes":                      "nodes",
+		"/apis/ghostwire.dev/v1alpha1/namespaces/shop/ghostwirestatuses/web-0": "ghostwirestatuses",
+		"/apis/authorization.k8s.io/v1/selfsubjectaccessreviews":               "selfsubjectaccessreviews",
+		"/api/v1/namespaces/shop/pods/web-0/status":                            "pods",
+		"/version": "other",
+		"/apis":    "other",
+	} {
+		if got := apiResource(path); got != want {
+			t.Errorf("apiResource(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestObserveRequests(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+	}))
+	defer server.Close()
+
+	type observation struct {
+		verb, resource string
+		code           int
+	}
+	var seen []observation
+	config := &rest.Config{Host: server.URL}
+	observeRequests(config, func(verb, resource string, code int, _ time.Duration) {
+		seen = append(seen, observation{verb, resource, code})
+	})
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("create clientset: %v", err)
+	}
+
+	if _, err := clientset.CoreV1().Pods("shop").Get(context.Background(), "web-0", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected not found error")
+	}
+	if len(seen) != 1 || seen[0] != (observation{http.MethodGet, "pods", http.StatusNotFound}) {
+		t.Fatalf("unexpected observations %+v", seen)
+	}
+}
@@ -3,6 +3,7 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -11,9 +12,10 @@ import (
 
 // PodLabelReader fetches labels from a Pod in the cluster.
 type PodLabelReader struct {
-	client    kubernetes.Interface
-	namespace string
-	podName   string
+	client      kubernetes.Interface
+	namespace   string
+	podName     string
+	terminating atomic.Bool
 }
 
 // NewPodLabelReader constructs a PodLabelReader for the given pod reference.
@@ -36,6 +38,8 @@ func (r *PodLabelReader) GetLabel(ctx context.Context, labelKey string) (string,
 		return "", fmt.Errorf("get pod %s/%s for label %q: %w", r.namespace, r.podName, labelKey, err)
 	}
 
+	r.terminating.Store(pod.DeletionTimestamp != nil)
+
 	if pod.Labels == nil {
 		return "", nil
 	}
@@ -47,3 +51,9 @@ func (r *PodLabelReader) GetLabel(ctx context.Context, labelKey string) (string,
 
 	return value, nil
 }
+
+// Terminating reports whether the Pod had a deletionTimestamp at the last
+// GetLabel call.
+func (r *PodLabelReader) Terminating() bool {
+	return r.terminating.Load()
+}
@@ -116,6 +116,35 @@ func TestPodLabelReader_GetLabel(t *testing.T) {
 	}
 }
 
+func TestPodLabelReaderTerminating(t *testing.T) {
+	t.Parallel()
+
+	pod := newTestPod(map[string]string{"role": "preview"})
+	client := fake.NewSimpleClientset(pod)
+	reader := NewPodLabelReader(client, "ghostwire", "ghostwire-watcher")
+
+	ctx := context.Background()
+	if _, err := reader.GetLabel(ctx, "role"); err != nil {
+		t.Fatalf("GetLabel returned error: %v", err)
+	}
+	if reader.Terminating() {
+		t.Fatalf("expected running pod not to be terminating")
+	}
+
+	deleting := pod.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	if _, err := client.CoreV1().Pods("ghostwire").Update(ctx, deleting, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update pod: %v", err)
+	}
+	if _, err := reader.GetLabel(ctx, "role"); err != nil {
+		t.Fatalf("GetLabel returned error: %v", err)
+	}
+	if !reader.Terminating() {
+		t.Fatalf("expected pod with deletionTimestamp to be terminating")
+	}
+}
+
 func newTestPod(labels map[string]string) *corev1.Pod {
 	return &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,6 +14,12 @@ type LabelReader interface {
 	GetLabel(ctx context.Context, labelKey string) (string, error)
 }
 
+// TerminationReader is implemented by label readers that also know whether
+// the pod is terminating. The poller suppresses transitions once it is.
+type TerminationReader interface {
+	Terminating() bool
+}
+
 // TransitionHandler reacts to recognized role transitions detected by the poller.
 type TransitionHandler interface {
 	OnTransition(ctx context.Context, previous string, current string) error
@@ -20,10 +27,13 @@ type TransitionHandler interface {
 
 // PollerConfig holds the dependencies and settings for the Poller.
 type PollerConfig struct {
-	LabelReader       LabelReader
-	LabelKey          string
-	ActiveValue       string
-	PreviewValue      string
+	LabelReader  LabelReader
+	LabelKey     string
+	ActiveValue  string
+	PreviewValue string
+	// ExtraRoles are further label values, beyond ActiveValue and
+	// PreviewValue, whose transitions reach the TransitionHandler.
+	ExtraRoles        []string
 	PollInterval      time.Duration
 	Logger            *slog.Logger
 	TransitionHandler TransitionHandler
@@ -36,6 +46,10 @@ type Poller struct {
 	mu           sync.RWMutex
 	lastRole     string
 	observedRole bool
+	terminating  bool
+	// suppressedRole is the label value last reported as suppressed, so a
+	// label that stays changed is logged once rather than on every poll.
+	suppressedRole string
 }
 
 // NewPoller validates the configuration and returns a Poller ready to run.
@@ -55,6 +69,9 @@ func NewPoller(cfg PollerConfig) (*Poller, error) {
 	if cfg.ActiveValue == cfg.PreviewValue {
 		return nil, fmt.Errorf("active and preview values must differ")
 	}
+	if err := validateExtraRoles(cfg.ActiveValue, cfg.PreviewValue, cfg.ExtraRoles); err != nil {
+		return nil, err
+	}
 	if cfg.PollInterval <= 0 {
 		return nil, fmt.Errorf("poll interval must be positive")
 	}
@@ -73,7 +90,7 @@ func NewPoller(cfg PollerConfig) (*Poller, error) {
 // Run executes the polling loop until the context is canceled.
 func (p *Poller) Run(ctx context.Context) {
 	p.logger.Info("starting label poller",
-		slog.String("label_key", p.cfg.LabelKey),
+		slog.String("label_key", p.currentLabelKey()),
 		slog.String("poll_interval", p.cfg.PollInterval.String()),
 	)
 
@@ -81,7 +98,7 @@ func (p *Poller) Run(ctx context.Context) {
 	defer func() {
 		ticker.Stop()
 		p.logger.Info("stopping label poller",
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", p.currentLabelKey()),
 		)
 	}()
 
@@ -98,6 +115,49 @@ func (p *Poller) Run(ctx context.Context) {
 	}
 }
 
+// SetRoles swaps the label key and recognized role values at runtime, e.g. when
+// a GhostwireConfig update arrives. The last observed role is kept so the next
+// poll compares against it using the new values. The extra roles are replaced
+// too, so callers pass the ones they still want recognized.
+func (p *Poller) SetRoles(labelKey, activeValue, previewValue string, extraRoles ...string) error {
+	if labelKey == "" {
+		return fmt.Errorf("label key is required")
+	}
+	if activeValue == "" || previewValue == "" {
+		return fmt.Errorf("active and preview values are required")
+	}
+	if activeValue == previewValue {
+		return fmt.Errorf("active and preview values must differ")
+	}
+	if err := validateExtraRoles(activeValue, previewValue, extraRoles); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.cfg.LabelKey = labelKey
+	p.cfg.ActiveValue = activeValue
+	p.cfg.PreviewValue = previewValue
+	p.cfg.ExtraRoles = append([]string(nil), extraRoles...)
+	p.mu.Unlock()
+	return nil
+}
+
+// validateExtraRoles rejects empty extra roles and ones that repeat another
+// recognized value.
+func validateExtraRoles(activeValue, previewValue string, extraRoles []string) error {
+	seen := map[string]bool{activeValue: true, previewValue: true}
+	for _, role := range extraRoles {
+		if role == "" {
+			return fmt.Errorf("extra role values must not be empty")
+		}
+		if seen[role] {
+			return fmt.Errorf("role value %q is recognized more than once", role)
+		}
+		seen[role] = true
+	}
+	return nil
+}
+
 // GetCurrentRole returns the last role value observed by the poller.
 func (p *Poller) GetCurrentRole() string {
 	p.mu.RLock()
@@ -106,15 +166,22 @@ func (p *Poller) GetCurrentRole() string {
 }
 
 func (p *Poller) pollOnce(ctx context.Context) {
-	labelValue, err := p.cfg.LabelReader.GetLabel(ctx, p.cfg.LabelKey)
+	labelKey := p.currentLabelKey()
+
+	labelValue, err := p.cfg.LabelReader.GetLabel(ctx, labelKey)
 	if err != nil {
 		p.logger.Warn("failed to read pod label",
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", labelKey),
 			slog.Any("error", err),
 		)
 		return
 	}
 
+	if reader, ok := p.cfg.LabelReader.(TerminationReader); ok && reader.Terminating() {
+		p.suppressTransition(labelKey, labelValue)
+		return
+	}
+
 	p.mu.Lock()
 	previousValue := p.lastRole
 	previousRecognized := p.isRecognizedRole(previousValue)
@@ -137,7 +204,7 @@ func (p *Poller) pollOnce(ctx context.Context) {
 	if firstObservation {
 		p.logger.Debug("initialized role state",
 			slog.String("current_role", labelValue),
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", labelKey),
 			slog.Bool("recognized_role", currentRecognized),
 		)
 		if currentRecognized && p.cfg.TransitionHandler != nil {
@@ -155,13 +222,13 @@ func (p *Poller) pollOnce(ctx context.Context) {
 	case stateUnchanged:
 		p.logger.Debug("role state unchanged",
 			slog.String("current_role", labelValue),
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", labelKey),
 		)
 	case recognizedTransition:
 		p.logger.Info("role transition detected",
 			slog.String("previous_role", previousValue),
 			slog.String("current_role", labelValue),
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", labelKey),
 		)
 		if handler := p.cfg.TransitionHandler; handler != nil {
 			if err := handler.OnTransition(ctx, previousValue, labelValue); err != nil {
@@ -178,11 +245,48 @@ func (p *Poller) pollOnce(ctx context.Context) {
 			slog.Bool("previous_recognized", previousRecognized),
 			slog.String("current_role", labelValue),
 			slog.Bool("current_recognized", currentRecognized),
-			slog.String("label_key", p.cfg.LabelKey),
+			slog.String("label_key", labelKey),
 		)
 	}
 }
 
+// suppressTransition skips a poll while the pod is terminating: label churn
+// during rollouts would otherwise toggle the jump on a pod that is going away.
+// Each label value that differs from the applied role is logged once.
+func (p *Poller) suppressTransition(labelKey, labelValue string) {
+	p.mu.Lock()
+	first := !p.terminating
+	p.terminating = true
+	previousValue := p.lastRole
+	report := labelValue != previousValue && labelValue != p.suppressedRole
+	if labelValue == previousValue {
+		p.suppressedRole = ""
+	} else {
+		p.suppressedRole = labelValue
+	}
+	p.mu.Unlock()
+
+	if first {
+		p.logger.Info("pod is terminating; suppressing role transitions",
+			slog.String("current_role", previousValue),
+			slog.String("label_key", labelKey),
+		)
+	}
+	if report {
+		p.logger.Info("role transition suppressed while terminating",
+			slog.String("previous_role", previousValue),
+			slog.String("current_role", labelValue),
+			slog.String("label_key", labelKey),
+		)
+	}
+}
+
+func (p *Poller) currentLabelKey() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg.LabelKey
+}
+
 func (p *Poller) isRecognizedRole(role string) bool {
-	return role == p.cfg.ActiveValue || role == p.cfg.PreviewValue
+	return role == p.cfg.ActiveValue || role == p.cfg.PreviewValue || slices.Contains(p.cfg.ExtraRoles, role)
 }
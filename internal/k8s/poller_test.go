@@ -62,6 +62,20 @@ func TestNewPollerValidation(t *testing.T) {
 			},
 			expectError: "active and preview values must differ",
 		},
+		{
+			name: "extra role repeats active",
+			mutate: func(cfg *PollerConfig) {
+				cfg.ExtraRoles = []string{"shadow", cfg.ActiveValue}
+			},
+			expectError: "recognized more than once",
+		},
+		{
+			name: "empty extra role",
+			mutate: func(cfg *PollerConfig) {
+				cfg.ExtraRoles = []string{""}
+			},
+			expectError: "must not be empty",
+		},
 		{
 			name: "non positive poll interval",
 			mutate: func(cfg *PollerConfig) {
@@ -180,6 +194,21 @@ func TestPollerRunScenarios(t *testing.T) {
 			},
 			polls: 2,
 		},
+		{
+			name: "extra role transition",
+			responses: []labelResponse{
+				{value: "active"},
+				{value: "shadow"},
+			},
+			expect: expectation{
+				transitions: []transitionCall{
+					{Previous: "", Current: "active"},
+					{Previous: "active", Current: "shadow"},
+				},
+				logContains: []string{"role transition detected", "level=INFO"},
+			},
+			polls: 2,
+		},
 		{
 			name: "unrecognized role transition ignored",
 			responses: []labelResponse{
@@ -252,6 +281,7 @@ func TestPollerRunScenarios(t *testing.T) {
 				LabelKey:          "role",
 				ActiveValue:       "active",
 				PreviewValue:      "preview",
+				ExtraRoles:        []string{"shadow"},
 				PollInterval:      5 * time.Millisecond,
 				Logger:            logger,
 				TransitionHandler: handler,
@@ -366,6 +396,107 @@ func TestPollerGetCurrentRole(t *testing.T) {
 	<-done
 }
 
+func TestPollerSetRoles(t *testing.T) {
+	t.Parallel()
+
+	reader := newMockLabelReader(labelResponse{value: "blue"}, labelResponse{value: "green"})
+	handler := &recordingTransitionHandler{}
+	logger, _ := newBufferLogger()
+
+	poller, err := NewPoller(PollerConfig{
+		LabelReader:       reader,
+		LabelKey:          "role",
+		ActiveValue:       "active",
+		PreviewValue:      "preview",
+		PollInterval:      time.Hour,
+		Logger:            logger,
+		TransitionHandler: handler,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating poller: %v", err)
+	}
+
+	for _, bad := range [][3]string{{"", "a", "b"}, {"role", "", "b"}, {"role", "same", "same"}} {
+		if err := poller.SetRoles(bad[0], bad[1], bad[2]); err == nil {
+			t.Fatalf("expected SetRoles(%q, %q, %q) to fail", bad[0], bad[1], bad[2])
+		}
+	}
+
+	if err := poller.SetRoles("track", "blue", "green"); err != nil {
+		t.Fatalf("SetRoles returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	poller.pollOnce(ctx)
+	poller.pollOnce(ctx)
+
+	want := []transitionCall{{Previous: "", Current: "blue"}, {Previous: "blue", Current: "green"}}
+	if got := handler.Transitions(); !equalTransitions(got, want) {
+		t.Fatalf("unexpected transitions: got %v want %v", got, want)
+	}
+}
+
+func TestPollerSuppressesTransitionsWhileTerminating(t *testing.T) {
+	t.Parallel()
+
+	reader := &terminatingLabelReader{mockLabelReader: newMockLabelReader(
+		labelResponse{value: "active"},
+		labelResponse{value: "preview"},
+		labelResponse{value: "preview"},
+		labelResponse{value: "preview"},
+		labelResponse{value: "active"},
+		labelResponse{value: "preview"},
+	)}
+	handler := &recordingTransitionHandler{}
+	logger, buf := newBufferLogger()
+
+	poller, err := NewPoller(PollerConfig{
+		LabelReader:       reader,
+		LabelKey:          "role",
+		ActiveValue:       "active",
+		PreviewValue:      "preview",
+		PollInterval:      time.Hour,
+		Logger:            logger,
+		TransitionHandler: handler,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating poller: %v", err)
+	}
+
+	ctx := context.Background()
+	poller.pollOnce(ctx)
+	reader.terminating = true
+	for range 5 {
+		poller.pollOnce(ctx)
+	}
+
+	want := []transitionCall{{Previous: "", Current: "active"}}
+	if got := handler.Transitions(); !equalTransitions(got, want) {
+		t.Fatalf("unexpected transitions: got %v want %v", got, want)
+	}
+	if got := poller.GetCurrentRole(); got != "active" {
+		t.Fatalf("expected last applied role to be kept, got %q", got)
+	}
+	logs := buf.String()
+	if strings.Count(logs, "pod is terminating; suppressing role transitions") != 1 {
+		t.Fatalf("expected one termination notice, got logs:\n%s", logs)
+	}
+	// The label staying at preview is reported once, not on every poll; it
+	// is reported again after flipping back and changing anew.
+	if count := strings.Count(logs, "role transition suppressed while terminating"); count != 2 {
+		t.Fatalf("expected two suppressed transition notices, got %d in logs:\n%s", count, logs)
+	}
+}
+
+type terminatingLabelReader struct {
+	*mockLabelReader
+	terminating bool
+}
+
+func (r *terminatingLabelReader) Terminating() bool {
+	return r.terminating
+}
+
 type labelResponse struct {
 	value string
 	err   error
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is one verb on a resource that a ghostwire component needs.
+type Permission struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+	// Name restricts the check to a single object, matching RBAC rules
+	// scoped with resourceNames.
+	Name string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Name != "" {
+		resource += "/" + p.Name
+	}
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s (cluster-scoped)", p.Verb, resource)
+	}
+	return fmt.Sprintf("%s %s in namespace %q", p.Verb, resource, p.Namespace)
+}
+
+// MissingRBACError lists the permissions the ServiceAccount lacks.
+type MissingRBACError struct {
+	Missing []Permission
+}
+
+func (e *MissingRBACError) Error() string {
+	parts := make([]string, len(e.Missing))
+	for i, p := range e.Missing {
+		parts[i] = p.String()
+	}
+	return "missing RBAC: " + strings.Join(parts, "; ")
+}
+
+// CheckPermissions asks the API server, via SelfSubjectAccessReview, whether
+// the caller holds every permission. It returns a *MissingRBACError naming
+// each denied one, or the API error when a review could not be made.
+func CheckPermissions(ctx context.Context, client kubernetes.Interface, permissions []Permission) error {
+	var missing []Permission
+	for _, p := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: p.Namespace,
+					Verb:      p.Verb,
+					Group:     p.Group,
+					Resource:  p.Resource,
+					Name:      p.Name,
+				},
+			},
+		}
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("review access to %s: %w", p, err)
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingRBACError{Missing: missing}
+	}
+	return nil
+}
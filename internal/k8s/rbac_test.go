@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attrs.Verb == "patch" && attrs.Resource == "pods")
+		return true, review, nil
+	})
+
+	permissions := []Permission{
+		{Verb: "list", Resource: "services", Namespace: "shop"},
+		{Verb: "get", Resource: "pods", Namespace: "shop", Name: "web-0"},
+		{Verb: "patch", Resource: "pods", Namespace: "shop", Name: "web-0"},
+	}
+	err := CheckPermissions(context.Background(), client, permissions)
+
+	var missing *MissingRBACError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingRBACError, got %v", err)
+	}
+	if len(missing.Missing) != 1 || missing.Missing[0].Verb != "patch" {
+		t.Fatalf("unexpected missing permissions: %+v", missing.Missing)
+	}
+	if want := `missing RBAC: patch pods/web-0 in namespace "shop"`; err.Error() != want {
+		t.Fatalf("unexpected message %q, want %q", err.Error(), want)
+	}
+
+	if err := CheckPermissions(context.Background(), client, permissions[:2]); err != nil {
+		t.Fatalf("expected granted permissions to pass, got %v", err)
+	}
+}
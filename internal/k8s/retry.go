@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Backoff bounds the retries RetryAPI performs. The delay starts at Initial
+// and doubles after every failed attempt, capped at Max.
+type Backoff struct {
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+}
+
+// Budget returns the longest RetryAPI can spend sleeping between attempts,
+// so callers can size their deadline to outlast every retry.
+func (b Backoff) Budget() time.Duration {
+	var total time.Duration
+	delay := b.Initial
+	for attempt := 1; attempt < b.Attempts; attempt++ {
+		total += delay
+		delay *= 2
+		if b.Max > 0 && delay > b.Max {
+			delay = b.Max
+		}
+	}
+	return total
+}
+
+// IsRetryable reports whether err is a transient API server failure worth
+// retrying: server-side timeouts, throttling, 5xx responses and transport
+// errors such as refused or reset connections. Authorization failures,
+// missing resources and local errors are fatal.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) {
+			return true
+		}
+		return status.Status().Code >= 500
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetryAPI runs fn until it succeeds, returns a non-retryable error, the
+// attempts are exhausted or ctx is done. op names the operation in logs and
+// in the returned error.
+func RetryAPI(ctx context.Context, backoff Backoff, logger *slog.Logger, op string, fn func(context.Context) error) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attempts := backoff.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := backoff.Initial
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("%s failed after %d attempts: %w", op, attempt, err)
+		}
+
+		logger.Warn("retrying kubernetes api call",
+			slog.String("operation", op),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", delay),
+			slog.String("error", err.Error()),
+		)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w (last error: %v)", op, ctx.Err(), err)
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if backoff.Max > 0 && delay > backoff.Max {
+			delay = backoff.Max
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	services := schema.GroupResource{Resource: "services"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "service unavailable", err: apierrors.NewServiceUnavailable("rolling"), want: true},
+		{name: "internal error", err: apierrors.NewInternalError(errors.New("etcd")), want: true},
+		{name: "server timeout", err: apierrors.NewServerTimeout(services, "list", 1), want: true},
+		{name: "throttled", err: apierrors.NewTooManyRequests("slow down", 1), want: true},
+		{name: "wrapped connection refused", err: fmt.Errorf("list services: %w", syscall.ECONNREFUSED), want: true},
+		{name: "forbidden", err: apierrors.NewForbidden(services, "", errors.New("rbac")), want: false},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("token"), want: false},
+		{name: "not found", err: apierrors.NewNotFound(services, "orders"), want: false},
+		{name: "local error", err: errors.New("parse selector"), want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := IsRetryable(tc.err); got != tc.want {
+				t.Fatalf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackoffBudget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		backoff Backoff
+		want    time.Duration
+	}{
+		{"capped", Backoff{Attempts: 5, Initial: 500 * time.Millisecond, Max: 2 * time.Second}, 5500 * time.Millisecond},
+		{"uncapped", Backoff{Attempts: 4, Initial: time.Second}, 7 * time.Second},
+		{"single attempt", Backoff{Attempts: 1, Initial: time.Second}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.backoff.Budget(); got != tt.want {
+			t.Errorf("%s: Budget() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAPI(t *testing.T) {
+	t.Parallel()
+
+	backoff := Backoff{Attempts: 3, Initial: time.Millisecond, Max: 2 * time.Millisecond}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		t.Parallel()
+		calls := 0
+		err := RetryAPI(context.Background(), backoff, nil, "list services", func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return apierrors.NewServiceUnavailable("rolling")
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Fatalf("expected success on third call, got err=%v calls=%d", err, calls)
+		}
+	})
+
+	t.Run("stops on fatal error", func(t *testing.T) {
+		t.Parallel()
+		calls := 0
+		forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "services"}, "", errors.New("rbac"))
+		err := RetryAPI(context.Background(), backoff, nil, "list services", func(context.Context) error {
+			calls++
+			return forbidden
+		})
+		if !apierrors.IsForbidden(err) || calls != 1 {
+			t.Fatalf("expected single forbidden attempt, got err=%v calls=%d", err, calls)
+		}
+	})
+
+	t.Run("gives up after attempts", func(t *testing.T) {
+		t.Parallel()
+		calls := 0
+		err := RetryAPI(context.Background(), backoff, nil, "list services", func(context.Context) error {
+			calls++
+			return apierrors.NewServiceUnavailable("rolling")
+		})
+		if err == nil || calls != 3 || !apierrors.IsServiceUnavailable(err) {
+			t.Fatalf("expected wrapped unavailable error after 3 calls, got err=%v calls=%d", err, calls)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+// StatusWriter maintains the GhostwireStatus object for a single pod. The
+// ServiceAccount needs get, create and update on ghostwirestatuses.
+type StatusWriter struct {
+	client    dynamic.Interface
+	namespace string
+	podName   string
+	podUID    string
+}
+
+// NewStatusWriter constructs a StatusWriter. When podUID is set the created
+// object is owned by the pod so it is garbage collected alongside it.
+func NewStatusWriter(client dynamic.Interface, namespace, podName, podUID string) *StatusWriter {
+	return &StatusWriter{
+		client:    client,
+		namespace: namespace,
+		podName:   podName,
+		podUID:    podUID,
+	}
+}
+
+// Write creates or replaces the pod's GhostwireStatus with state.
+func (w *StatusWriter) Write(ctx context.Context, state v1alpha1.RoutingState) error {
+	state.PodName = w.podName
+	resource := w.client.Resource(v1alpha1.GhostwireStatusResource).Namespace(w.namespace)
+
+	existing, err := resource.Get(ctx, w.podName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("get ghostwirestatus %s/%s: %w", w.namespace, w.podName, err)
+	}
+
+	obj := &v1alpha1.GhostwireStatus{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "GhostwireStatus"},
+		Status:   state,
+	}
+	found := err == nil
+	if found {
+		obj.ObjectMeta = metav1.ObjectMeta{
+			Name:            existing.GetName(),
+			Namespace:       existing.GetNamespace(),
+			ResourceVersion: existing.GetResourceVersion(),
+			Labels:          existing.GetLabels(),
+			OwnerReferences: existing.GetOwnerReferences(),
+		}
+	} else {
+		obj.ObjectMeta = metav1.ObjectMeta{Name: w.podName, Namespace: w.namespace}
+		if w.podUID != "" {
+			obj.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       w.podName,
+				UID:        types.UID(w.podUID),
+			}}
+		}
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return fmt.Errorf("encode ghostwirestatus %s/%s: %w", w.namespace, w.podName, err)
+	}
+	desired := &unstructured.Unstructured{Object: content}
+
+	if !found {
+		if _, err := resource.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create ghostwirestatus %s/%s: %w", w.namespace, w.podName, err)
+		}
+		return nil
+	}
+
+	if _, err := resource.Update(ctx, desired, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update ghostwirestatus %s/%s: %w", w.namespace, w.podName, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+func TestStatusWriterCreatesThenUpdates(t *testing.T) {
+	t.Parallel()
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.GhostwireStatusResource: "GhostwireStatusList"},
+	)
+	writer := NewStatusWriter(client, "shop", "orders-abc", "uid-123")
+	ctx := context.Background()
+
+	transition := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err := writer.Write(ctx, v1alpha1.RoutingState{Role: "preview", JumpActive: true, RuleCount: 4, LastTransitionTime: transition}); err != nil {
+		t.Fatalf("first Write returned error: %v", err)
+	}
+
+	obj, err := client.Resource(v1alpha1.GhostwireStatusResource).Namespace("shop").Get(ctx, "orders-abc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get status: %v", err)
+	}
+	owners := obj.GetOwnerReferences()
+	if len(owners) != 1 || owners[0].Kind != "Pod" || string(owners[0].UID) != "uid-123" {
+		t.Fatalf("expected pod owner reference, got %+v", owners)
+	}
+
+	if err := writer.Write(ctx, v1alpha1.RoutingState{Role: "active", LastError: "remove jump: boom"}); err != nil {
+		t.Fatalf("second Write returned error: %v", err)
+	}
+
+	obj, err = client.Resource(v1alpha1.GhostwireStatusResource).Namespace("shop").Get(ctx, "orders-abc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get status: %v", err)
+	}
+	var status v1alpha1.GhostwireStatus
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Status.PodName != "orders-abc" || status.Status.Role != "active" || status.Status.JumpActive || status.Status.LastError != "remove jump: boom" {
+		t.Fatalf("unexpected status after update: %+v", status.Status)
+	}
+	if len(status.OwnerReferences) != 1 {
+		t.Fatalf("expected owner reference to survive update, got %+v", status.OwnerReferences)
+	}
+}
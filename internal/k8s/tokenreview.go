@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrTokenNotAuthenticated means the API server did not accept a token
+// submitted for review.
+var ErrTokenNotAuthenticated = errors.New("token not authenticated")
+
+// ReviewToken asks the API server, via TokenReview, who token belongs to. An
+// empty audiences list accepts tokens for the API server's default audience.
+// The caller's ServiceAccount needs create on tokenreviews
+// (system:auth-delegator grants it).
+func ReviewToken(ctx context.Context, client kubernetes.Interface, token string, audiences []string) (string, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}
+	result, err := client.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("review token: %w", err)
+	}
+	if !result.Status.Authenticated {
+		if result.Status.Error != "" {
+			return "", fmt.Errorf("%w: %s", ErrTokenNotAuthenticated, result.Status.Error)
+		}
+		return "", ErrTokenNotAuthenticated
+	}
+	return result.Status.User.Username, nil
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReviewToken(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "good" {
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:monitoring:prometheus"
+		} else {
+			review.Status.Error = "invalid bearer token"
+		}
+		return true, review, nil
+	})
+
+	user, err := ReviewToken(context.Background(), client, "good", nil)
+	if err != nil || user != "system:serviceaccount:monitoring:prometheus" {
+		t.Fatalf("ReviewToken = %q, %v; want prometheus service account", user, err)
+	}
+
+	if _, err := ReviewToken(context.Background(), client, "bad", nil); !errors.Is(err, ErrTokenNotAuthenticated) {
+		t.Fatalf("expected ErrTokenNotAuthenticated, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// NamedHandler is a TransitionHandler with the name it is logged and timed
+// under.
+type NamedHandler struct {
+	Name    string
+	Handler TransitionHandler
+}
+
+// HandlerObserver receives the outcome of each handler call made by a
+// CompositeHandler; err is nil when the handler succeeded.
+type HandlerObserver func(name string, duration time.Duration, err error)
+
+// CompositeHandler runs several TransitionHandlers in order for each
+// transition. A handler that fails or panics does not stop the ones after it;
+// their errors are joined into the result.
+type CompositeHandler struct {
+	handlers []NamedHandler
+	observer HandlerObserver
+	logger   *slog.Logger
+}
+
+// NewCompositeHandler returns a handler calling handlers in the given order.
+// observer may be nil.
+func NewCompositeHandler(logger *slog.Logger, observer HandlerObserver, handlers ...NamedHandler) *CompositeHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &CompositeHandler{
+		handlers: append([]NamedHandler(nil), handlers...),
+		observer: observer,
+		logger:   logger,
+	}
+}
+
+// OnTransition implements TransitionHandler.
+func (c *CompositeHandler) OnTransition(ctx context.Context, previous string, current string) error {
+	var errs []error
+	for _, h := range c.handlers {
+		start := time.Now()
+		err := callHandler(ctx, h.Handler, previous, current)
+		duration := time.Since(start)
+
+		if c.observer != nil {
+			c.observer(h.Name, duration, err)
+		}
+		if err != nil {
+			c.logger.Warn("transition handler failed",
+				slog.String("handler", h.Name),
+				slog.String("previous_role", previous),
+				slog.String("current_role", current),
+				slog.Duration("duration", duration),
+				slog.Any("error", err),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", h.Name, err))
+			continue
+		}
+		c.logger.Debug("transition handler finished",
+			slog.String("handler", h.Name),
+			slog.String("current_role", current),
+			slog.Duration("duration", duration),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// callHandler runs handler, turning a panic into an error so one broken
+// handler cannot take the watcher down with it.
+func callHandler(ctx context.Context, handler TransitionHandler, previous, current string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler.OnTransition(ctx, previous, current)
+}
@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type panickingHandler struct{}
+
+func (panickingHandler) OnTransition(context.Context, string, string) error {
+	panic("boom")
+}
+
+func TestCompositeHandler(t *testing.T) {
+	t.Parallel()
+
+	first := &recordingTransitionHandler{responses: []error{errors.New("first failed")}}
+	last := &recordingTransitionHandler{}
+	var observed []string
+	logger, buf := newBufferLogger()
+
+	composite := NewCompositeHandler(logger, func(name string, duration time.Duration, err error) {
+		if duration < 0 {
+			t.Errorf("negative duration for %s", name)
+		}
+		observed = append(observed, name+"="+strconv.FormatBool(err == nil))
+	},
+		NamedHandler{Name: "first", Handler: first},
+		NamedHandler{Name: "panics", Handler: panickingHandler{}},
+		NamedHandler{Name: "last", Handler: last},
+	)
+
+	err := composite.OnTransition(context.Background(), "active", "preview")
+	if err == nil {
+		t.Fatalf("expected joined handler errors")
+	}
+	for _, want := range []string{"first: first failed", "panics: panic: boom"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to contain %q, got %v", want, err)
+		}
+	}
+
+	want := []transitionCall{{Previous: "active", Current: "preview"}}
+	if !equalTransitions(first.Transitions(), want) || !equalTransitions(last.Transitions(), want) {
+		t.Fatalf("expected every handler to run, got first %v last %v", first.Transitions(), last.Transitions())
+	}
+	if strings.Join(observed, ",") != "first=false,panics=false,last=true" {
+		t.Fatalf("unexpected observations %v", observed)
+	}
+	if !strings.Contains(buf.String(), "handler=panics") {
+		t.Fatalf("expected failure log naming the handler, got %q", buf.String())
+	}
+
+	if err := composite.OnTransition(context.Background(), "preview", "active"); err == nil || strings.Contains(err.Error(), "first") {
+		t.Fatalf("expected only the panicking handler to fail, got %v", err)
+	}
+}
@@ -31,7 +31,7 @@ func TestCountDNATMappings(t *testing.T) {
 		{
 			name: "valid mappings",
 			setup: func(t *testing.T) string {
-				content := "# ghostwire dnat map\nsvc-a 10.0.0.1 10.0.0.2\nsvc-b 10.0.0.3 10.0.0.4\n# trailing comment\nsvc-c 10.0.0.5 10.0.0.6\n"
+				content := "# ghostwire dnat map\nsvc-a:80/TCP 10.0.0.1 -> 10.0.0.2\nsvc-b:80/TCP 10.0.0.3 -> 10.0.0.4\n# trailing comment\nsvc-c:80/TCP 10.0.0.5 -> 10.0.0.6\n"
 				return write(t, "valid.map", content, 0o600)
 			},
 			wantCount: 3,
@@ -54,7 +54,7 @@ func TestCountDNATMappings(t *testing.T) {
 		{
 			name: "blank lines ignored",
 			setup: func(t *testing.T) string {
-				content := "\nsvc-a 10.0.0.1 10.0.0.2\n\nsvc-b 10.0.0.3 10.0.0.4\n\n"
+				content := "\nsvc-a:80/TCP 10.0.0.1 -> 10.0.0.2\n\nsvc-b:80/TCP 10.0.0.3 -> 10.0.0.4\n\n"
 				return write(t, "blank.map", content, 0o600)
 			},
 			wantCount: 2,
@@ -75,7 +75,7 @@ func TestCountDNATMappings(t *testing.T) {
 				if os.Geteuid() == 0 {
 					t.Skip("skipping permission denied scenario when running as root")
 				}
-				path := write(t, "restricted.map", "svc 10.0.0.1 10.0.0.2\n", 0o600)
+				path := write(t, "restricted.map", "svc:80/TCP 10.0.0.1 -> 10.0.0.2\n", 0o600)
 				if err := os.Chmod(path, 0o000); err != nil {
 					t.Fatalf("chmod failed: %v", err)
 				}
@@ -89,7 +89,7 @@ func TestCountDNATMappings(t *testing.T) {
 		{
 			name: "mixed content",
 			setup: func(t *testing.T) string {
-				content := "# header\n\nsvc-a 10.0.0.1 10.0.0.2\n# comment\nsvc-b 10.0.0.3 10.0.0.4\n   \nsvc-c 10.0.0.5 10.0.0.6\n"
+				content := "# header\n\nsvc-a:80/TCP 10.0.0.1 -> 10.0.0.2\n# comment\nsvc-b:80/TCP 10.0.0.3 -> 10.0.0.4\n   \nsvc-c:80/TCP 10.0.0.5 -> 10.0.0.6\n"
 				return write(t, "mixed.map", content, 0o600)
 			},
 			wantCount: 3,
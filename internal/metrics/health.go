@@ -1,21 +1,64 @@
 package metrics
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/denniswebb/ghostwire/internal/logging"
 )
 
+// Signal names a readiness condition the HealthChecker can require.
+type Signal string
+
+const (
+	// SignalChain is satisfied once the DNAT chain has been seen.
+	SignalChain Signal = "chain"
+	// SignalLabels is satisfied once the pod labels have been read.
+	SignalLabels Signal = "labels"
+	// SignalJump is satisfied while the jump rule matches the pod's role,
+	// i.e. the latest transition completed without error.
+	SignalJump Signal = "jump"
+)
+
+// DefaultSignals are required when no policy is configured.
+var DefaultSignals = []Signal{SignalChain, SignalLabels}
+
+// ParseSignals parses a comma-separated readiness policy such as
+// "chain,labels,jump". An empty list requires nothing, so /healthz only
+// reports that the process is serving.
+func ParseSignals(csv string) ([]Signal, error) {
+	signals := []Signal{}
+	for _, part := range strings.Split(csv, ",") {
+		switch signal := Signal(strings.TrimSpace(part)); signal {
+		case "":
+		case SignalChain, SignalLabels, SignalJump:
+			signals = append(signals, signal)
+		default:
+			return nil, fmt.Errorf("unknown readiness signal %q (expected %s, %s or %s)", signal, SignalChain, SignalLabels, SignalJump)
+		}
+	}
+	return signals, nil
+}
+
 // HealthChecker tracks readiness signals for the watcher sidecar.
 type HealthChecker struct {
-	mu            sync.RWMutex
-	chainVerified bool
-	labelsRead    bool
-	logger        *slog.Logger
+	mu              sync.RWMutex
+	chainVerified   bool
+	labelsRead      bool
+	jumpMatchesRole bool
+	required        []Signal
+	verifiers       map[string]Verifier
+	logger          *slog.Logger
 }
 
+// Verifier performs a fresh check on demand, for example re-reading the DNAT
+// chain, and returns an error describing what is wrong.
+type Verifier func(ctx context.Context) error
+
 // NewHealthChecker returns a HealthChecker with a logger derived from the shared logging package.
 func NewHealthChecker() *HealthChecker {
 	logger := logging.GetLogger()
@@ -23,7 +66,22 @@ func NewHealthChecker() *HealthChecker {
 		logger = slog.Default()
 	}
 
-	return &HealthChecker{logger: logger}
+	return &HealthChecker{required: DefaultSignals, logger: logger}
+}
+
+// SetRequired replaces the signals IsHealthy and /healthz wait for.
+func (h *HealthChecker) SetRequired(signals []Signal) {
+	h.mu.Lock()
+	h.required = append([]Signal(nil), signals...)
+	h.mu.Unlock()
+}
+
+// SetJumpMatchesRole records whether the jump rule currently matches the
+// pod's role.
+func (h *HealthChecker) SetJumpMatchesRole(matches bool) {
+	h.mu.Lock()
+	h.jumpMatchesRole = matches
+	h.mu.Unlock()
 }
 
 // SetChainVerified records that the DNAT chain existence has been confirmed.
@@ -40,34 +98,101 @@ func (h *HealthChecker) SetLabelsRead() {
 	h.mu.Unlock()
 }
 
-// IsHealthy reports whether both readiness signals have been satisfied.
+// SetVerifier registers fn under name so that `GET /healthz?verify=<name>`
+// runs it before answering. Several names may be requested comma-separated.
+func (h *HealthChecker) SetVerifier(name string, fn Verifier) {
+	h.mu.Lock()
+	if h.verifiers == nil {
+		h.verifiers = make(map[string]Verifier)
+	}
+	h.verifiers[name] = fn
+	h.mu.Unlock()
+}
+
+// IsHealthy reports whether every required readiness signal is satisfied.
 func (h *HealthChecker) IsHealthy() bool {
+	return len(h.missing()) == 0
+}
+
+// missing returns the required signals that are not yet satisfied.
+func (h *HealthChecker) missing() []string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.chainVerified && h.labelsRead
+
+	var missing []string
+	for _, signal := range h.required {
+		var ok bool
+		switch signal {
+		case SignalChain:
+			ok = h.chainVerified
+		case SignalLabels:
+			ok = h.labelsRead
+		case SignalJump:
+			ok = h.jumpMatchesRole
+		}
+		if !ok {
+			missing = append(missing, string(signal))
+		}
+	}
+	return missing
 }
 
 // Handler produces an HTTP handler for the /healthz endpoint.
 func (h *HealthChecker) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h.mu.RLock()
-		chainVerified := h.chainVerified
-		labelsRead := h.labelsRead
-		h.mu.RUnlock()
-
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
-		if chainVerified && labelsRead {
+		if status, msg := h.runVerifiers(r); status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(msg + "\n"))
+			return
+		}
+
+		missing := h.missing()
+		if len(missing) == 0 {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("OK\n"))
 			return
 		}
 
+		h.mu.RLock()
+		chainVerified := h.chainVerified
+		labelsRead := h.labelsRead
+		jumpMatchesRole := h.jumpMatchesRole
+		h.mu.RUnlock()
+
 		h.logger.Warn("health check not yet passing",
 			slog.Bool("chain_verified", chainVerified),
 			slog.Bool("labels_read", labelsRead),
+			slog.Bool("jump_matches_role", jumpMatchesRole),
+			slog.String("missing", strings.Join(missing, ",")),
 		)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte("Service Unavailable\n"))
 	})
 }
+
+// runVerifiers runs the verifiers named in the request's verify parameter.
+// Verifiers run before the sticky signals are read so a passing verifier can
+// update them.
+func (h *HealthChecker) runVerifiers(r *http.Request) (int, string) {
+	raw := r.URL.Query().Get("verify")
+	if raw == "" {
+		return http.StatusOK, ""
+	}
+
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		h.mu.RLock()
+		fn, ok := h.verifiers[name]
+		h.mu.RUnlock()
+		if !ok {
+			return http.StatusBadRequest, fmt.Sprintf("unknown verify check %q", name)
+		}
+		if err := fn(r.Context()); err != nil {
+			h.logger.Warn("health verification failed", slog.String("check", name), slog.Any("error", err))
+			return http.StatusServiceUnavailable, fmt.Sprintf("%s verification failed: %v", name, err)
+		}
+	}
+	return http.StatusOK, ""
+}
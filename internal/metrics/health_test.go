@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -149,6 +151,82 @@ func TestHealthCheckerHandlerStates(t *testing.T) {
 	}
 }
 
+func TestHealthCheckerRequiredSignals(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ParseSignals("chain,routes"); err == nil {
+		t.Fatal("expected unknown signal to be rejected")
+	}
+
+	signals, err := ParseSignals(" labels , jump ")
+	if err != nil {
+		t.Fatalf("ParseSignals returned error: %v", err)
+	}
+	h, _ := newHealthCheckerForTest()
+	h.SetRequired(signals)
+	h.SetLabelsRead()
+	if h.IsHealthy() {
+		t.Fatal("expected jump signal to be required")
+	}
+	h.SetJumpMatchesRole(true)
+	if !h.IsHealthy() {
+		t.Fatal("expected healthy without chain verification once labels and jump are satisfied")
+	}
+	h.SetJumpMatchesRole(false)
+	if h.IsHealthy() {
+		t.Fatal("expected jump mismatch to make the checker unhealthy again")
+	}
+
+	none, err := ParseSignals("")
+	if err != nil {
+		t.Fatalf("ParseSignals returned error: %v", err)
+	}
+	h.SetRequired(none)
+	if !h.IsHealthy() {
+		t.Fatal("expected empty policy to always be healthy")
+	}
+}
+
+func TestHealthCheckerVerifiers(t *testing.T) {
+	t.Parallel()
+
+	h, buf := newHealthCheckerForTest()
+	h.SetLabelsRead()
+	chainPresent := false
+	h.SetVerifier("chain", func(context.Context) error {
+		if !chainPresent {
+			return errors.New("chain CANARY_DNAT missing")
+		}
+		h.SetChainVerified()
+		return nil
+	})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve("/healthz?verify=chain")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "chain verification failed: chain CANARY_DNAT missing\n" {
+		t.Fatalf("unexpected failing verify response: %d %q", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(buf.String(), "health verification failed") {
+		t.Fatalf("expected warning log, got %q", buf.String())
+	}
+
+	chainPresent = true
+	if rec := serve("/healthz?verify=chain"); rec.Code != http.StatusOK {
+		t.Fatalf("expected passing verify to report healthy, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve("/healthz"); rec.Code != http.StatusOK {
+		t.Fatalf("expected passing verify to set chainVerified, got %d", rec.Code)
+	}
+	if rec := serve("/healthz?verify=chain,nope"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown check to be rejected, got %d", rec.Code)
+	}
+}
+
 func TestHealthCheckerConcurrentAccess(t *testing.T) {
 	t.Parallel()
 
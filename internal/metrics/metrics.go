@@ -2,17 +2,56 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
 )
 
 // Metrics bundles Prometheus instruments for the watcher.
 type Metrics struct {
-	registry    *prometheus.Registry
-	jumpState   prometheus.Gauge
-	errorsTotal *prometheus.CounterVec
-	dnatRules   prometheus.Gauge
+	registry         *prometheus.Registry
+	jumpState        prometheus.Gauge
+	errorsTotal      *prometheus.CounterVec
+	dnatRules        prometheus.Gauge
+	stale            prometheus.Gauge
+	lastPoll         prometheus.Gauge
+	skipped          *prometheus.CounterVec
+	mappingInfo      *prometheus.GaugeVec
+	omitted          prometheus.Gauge
+	apiLatency       *prometheus.HistogramVec
+	apiRequests      *prometheus.CounterVec
+	authFailures     *prometheus.CounterVec
+	credentialsValid prometheus.Gauge
+	tokenExpiry      prometheus.Gauge
+	handlerLatency   *prometheus.HistogramVec
+	handlerFailures  *prometheus.CounterVec
+	previewPercent   prometheus.Gauge
+	scheduleOverride prometheus.Gauge
+	expirations      prometheus.Counter
+	circuitOpen      prometheus.Gauge
+	conntrackCount   prometheus.Gauge
+	conntrackMax     prometheus.Gauge
+	conntrackDNAT    prometheus.Gauge
+	certReloads      *prometheus.CounterVec
+	namespaceSyncs   *prometheus.HistogramVec
+	syncDuration     prometheus.Histogram
+	syncNamespaces   prometheus.Gauge
+	syncFailed       prometheus.Gauge
+	repairs          *prometheus.CounterVec
+	ruleDrift        *prometheus.GaugeVec
+	handlerOpts      promhttp.HandlerOpts
+
+	mu      sync.Mutex
+	started time.Time
+	lastOK  time.Time
+	skips   map[string]uint64
+	now     func() time.Time
 }
 
 // NewMetrics constructs a Metrics instance with an isolated registry.
@@ -37,14 +76,215 @@ func NewMetrics() *Metrics {
 		Help:      "Number of DNAT rules discovered from the audit map.",
 	})
 
-	registry.MustRegister(jumpState, errorsTotal, dnatRules)
+	stale := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "stale_mappings",
+		Help:      "Number of DNAT mappings whose recorded Service IPs no longer match the cluster.",
+	})
+
+	lastPoll := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "last_poll_success_timestamp_seconds",
+		Help:      "Unix time of the last successful pod label read.",
+	})
+
+	skipped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "dnat_rules_skipped_total",
+		Help:      "Mappings dropped while building DNAT rules, by reason.",
+	}, []string{"reason"})
+
+	mappingInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "mapping_info",
+		Help:      "Always 1 for each DNAT mapping the pod routes, up to the configured cap.",
+	}, []string{"service", "port", "protocol", "active_ip", "preview_ip"})
+
+	omitted := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "mapping_info_omitted",
+		Help:      "Number of DNAT mappings left out of ghostwire_mapping_info by the cardinality cap.",
+	})
+
+	apiLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ghostwire",
+		Name:      "kube_api_request_duration_seconds",
+		Help:      "Duration of Kubernetes API requests by verb and resource.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"verb", "resource"})
+
+	apiRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "kube_api_requests_total",
+		Help:      "Kubernetes API requests by verb, resource and HTTP status code (\"error\" when no response arrived).",
+	}, []string{"verb", "resource", "code"})
 
-	return &Metrics{
-		registry:    registry,
-		jumpState:   jumpState,
-		errorsTotal: errorsTotal,
-		dnatRules:   dnatRules,
+	credentialsValid := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "credentials_valid",
+		Help:      "Whether the API server accepted the service account token at the last credential check (1) or rejected it (0).",
+	})
+
+	tokenExpiry := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "serviceaccount_token_expiry_timestamp_seconds",
+		Help:      "Unix time at which the mounted service account token expires (0 for tokens without an expiry).",
+	})
+
+	authFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "admin_auth_failures_total",
+		Help:      "Rejected requests to the watcher's admin endpoints by reason.",
+	}, []string{"reason"})
+
+	handlerLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ghostwire",
+		Name:      "transition_handler_duration_seconds",
+		Help:      "Time each transition handler took to react to a role change.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"handler"})
+
+	handlerFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "transition_handler_failures_total",
+		Help:      "Role changes a transition handler failed to apply.",
+	}, []string{"handler"})
+
+	previewPercent := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "preview_percent",
+		Help:      "Percentage of new connections the DNAT chain routes to preview while the jump is active.",
+	})
+
+	scheduleOverride := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "schedule_override",
+		Help:      "Whether the routing schedule is holding the pod on the active role against its label (1) or not (0).",
+	})
+
+	expirations := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "preview_expirations_total",
+		Help:      "Times the jump was removed for outliving the maximum preview duration.",
+	})
+
+	circuitOpen := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "preview_circuit_open",
+		Help:      "Whether the jump is held off because a preview Service has no ready endpoints (1) or not (0).",
+	})
+
+	conntrackCount := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "conntrack_entries",
+		Help:      "Entries in the conntrack table seen from the pod's network namespace.",
+	})
+
+	conntrackMax := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "conntrack_max",
+		Help:      "Size limit of the conntrack table (nf_conntrack_max).",
+	})
+
+	conntrackDNAT := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "conntrack_dnat_entries",
+		Help:      "Conntrack entries of connections redirected from an active ClusterIP to its preview side.",
+	})
+
+	certReloads := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "tls_certificate_reloads_total",
+		Help:      "Attempts to load a replaced TLS certificate, by server and result (success or failure).",
+	}, []string{"server", "result"})
+
+	namespaceSyncs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ghostwire",
+		Name:      "controller_namespace_sync_duration_seconds",
+		Help:      "Time the controller took to discover and publish one namespace, by result (success or failure).",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"result"})
+
+	syncDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ghostwire",
+		Name:      "controller_sync_duration_seconds",
+		Help:      "Time the controller took for a full pass over its namespaces.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	syncNamespaces := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "controller_namespaces",
+		Help:      "Namespaces covered by the controller's last pass.",
+	})
+
+	syncFailed := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "controller_namespaces_failed",
+		Help:      "Namespaces whose sync failed in the controller's last pass.",
+	})
+
+	repairs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ghostwire",
+		Name:      "reconcile_repairs_total",
+		Help:      "Rule drift repaired by the watcher's reconcile loop, by kind (chain, rules, jump).",
+	}, []string{"kind"})
+
+	ruleDrift := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "rule_drift",
+		Help:      "Differences between the DNAT chain and the DNAT map at the last rule drift check, by kind (missing, extra, wrong_target).",
+	}, []string{"kind"})
+
+	m := &Metrics{
+		registry:         registry,
+		jumpState:        jumpState,
+		errorsTotal:      errorsTotal,
+		dnatRules:        dnatRules,
+		stale:            stale,
+		lastPoll:         lastPoll,
+		skipped:          skipped,
+		mappingInfo:      mappingInfo,
+		omitted:          omitted,
+		apiLatency:       apiLatency,
+		apiRequests:      apiRequests,
+		authFailures:     authFailures,
+		credentialsValid: credentialsValid,
+		tokenExpiry:      tokenExpiry,
+		handlerLatency:   handlerLatency,
+		handlerFailures:  handlerFailures,
+		previewPercent:   previewPercent,
+		scheduleOverride: scheduleOverride,
+		expirations:      expirations,
+		circuitOpen:      circuitOpen,
+		conntrackCount:   conntrackCount,
+		conntrackMax:     conntrackMax,
+		conntrackDNAT:    conntrackDNAT,
+		certReloads:      certReloads,
+		namespaceSyncs:   namespaceSyncs,
+		syncDuration:     syncDuration,
+		syncNamespaces:   syncNamespaces,
+		syncFailed:       syncFailed,
+		repairs:          repairs,
+		ruleDrift:        ruleDrift,
+		skips:            map[string]uint64{},
+		now:              time.Now,
+		handlerOpts: promhttp.HandlerOpts{
+			EnableOpenMetrics:   true,
+			OfferedCompressions: []promhttp.Compression{promhttp.Identity, promhttp.Gzip},
+		},
 	}
+	m.started = m.now()
+
+	staleness := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "ghostwire",
+		Name:      "poll_staleness_seconds",
+		Help:      "Seconds since the last successful pod label read, or since startup if none succeeded yet.",
+	}, m.pollStaleness)
+
+	registry.MustRegister(jumpState, errorsTotal, dnatRules, stale, lastPoll, staleness, skipped, mappingInfo, omitted, apiLatency, apiRequests, authFailures, credentialsValid, tokenExpiry, handlerLatency, handlerFailures, previewPercent, scheduleOverride, expirations, circuitOpen, conntrackCount, conntrackMax, conntrackDNAT, certReloads, namespaceSyncs, syncDuration, syncNamespaces, syncFailed, repairs, ruleDrift)
+
+	return m
 }
 
 // SetJumpActive updates the jump activation gauge.
@@ -66,7 +306,217 @@ func (m *Metrics) SetDNATRuleCount(count int) {
 	m.dnatRules.Set(float64(count))
 }
 
+// SetPreviewPercent records the share of new connections routed to preview.
+func (m *Metrics) SetPreviewPercent(percent int) {
+	m.previewPercent.Set(float64(percent))
+}
+
+// SetScheduleOverride records whether the routing schedule overrides the
+// role label.
+func (m *Metrics) SetScheduleOverride(active bool) {
+	if active {
+		m.scheduleOverride.Set(1)
+		return
+	}
+	m.scheduleOverride.Set(0)
+}
+
+// IncrementPreviewExpirations counts a jump removed for outliving the
+// maximum preview duration.
+func (m *Metrics) IncrementPreviewExpirations() {
+	m.expirations.Inc()
+}
+
+// SetPreviewCircuitOpen records whether the preview health circuit breaker
+// holds the jump off.
+func (m *Metrics) SetPreviewCircuitOpen(open bool) {
+	if open {
+		m.circuitOpen.Set(1)
+		return
+	}
+	m.circuitOpen.Set(0)
+}
+
+// SetConntrackUsage records the conntrack table's entry count and limit.
+func (m *Metrics) SetConntrackUsage(count, limit int) {
+	m.conntrackCount.Set(float64(count))
+	m.conntrackMax.Set(float64(limit))
+}
+
+// SetConntrackDNATEntries records how many conntrack entries belong to
+// redirected connections.
+func (m *Metrics) SetConntrackDNATEntries(count int) {
+	m.conntrackDNAT.Set(float64(count))
+}
+
+// SetStaleMappings records how many mappings the latest drift check found
+// stale.
+func (m *Metrics) SetStaleMappings(count int) {
+	m.stale.Set(float64(count))
+}
+
+// SetMappingInfo replaces the ghostwire_mapping_info series with the first
+// limit mappings and returns how many were left out. A limit of zero or less
+// exports no series.
+func (m *Metrics) SetMappingInfo(mappings []discovery.ServiceMapping, limit int) int {
+	m.mappingInfo.Reset()
+
+	omitted := 0
+	for i, mapping := range mappings {
+		if i >= limit {
+			omitted = len(mappings) - max(limit, 0)
+			break
+		}
+		m.mappingInfo.WithLabelValues(
+			mapping.ServiceName,
+			strconv.Itoa(int(mapping.Port)),
+			string(mapping.Protocol),
+			mapping.ActiveClusterIP,
+			mapping.PreviewClusterIP,
+		).Set(1)
+	}
+	m.omitted.Set(float64(omitted))
+	return omitted
+}
+
+// RecordPollSuccess marks a successful pod label read at t.
+func (m *Metrics) RecordPollSuccess(t time.Time) {
+	m.mu.Lock()
+	m.lastOK = t
+	m.mu.Unlock()
+	m.lastPoll.Set(float64(t.UnixNano()) / 1e9)
+}
+
+// pollStaleness is evaluated on every scrape so the gauge keeps growing while
+// reads fail, even though nothing updates it.
+func (m *Metrics) pollStaleness() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := m.lastOK
+	if since.IsZero() {
+		since = m.started
+	}
+	return m.now().Sub(since).Seconds()
+}
+
+// ObserveSkippedDNATRules advances the skipped rule counter to the
+// process-wide totals in counts, as returned by iptables.SkippedDNATRules.
+func (m *Metrics) ObserveSkippedDNATRules(counts map[string]uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for reason, count := range counts {
+		if count > m.skips[reason] {
+			m.skipped.WithLabelValues(reason).Add(float64(count - m.skips[reason]))
+			m.skips[reason] = count
+		}
+	}
+}
+
+// ObserveAPIRequest records one Kubernetes API request. Its signature matches
+// k8s.RequestObserver so it can be passed to k8s.NewInClusterClient.
+func (m *Metrics) ObserveAPIRequest(verb, resource string, code int, duration time.Duration) {
+	status := "error"
+	if code > 0 {
+		status = strconv.Itoa(code)
+	}
+	m.apiLatency.WithLabelValues(verb, resource).Observe(duration.Seconds())
+	m.apiRequests.WithLabelValues(verb, resource, status).Inc()
+}
+
+// ObserveTransitionHandler records one transition handler call. Its signature
+// matches k8s.HandlerObserver.
+func (m *Metrics) ObserveTransitionHandler(name string, duration time.Duration, err error) {
+	m.handlerLatency.WithLabelValues(name).Observe(duration.Seconds())
+	if err != nil {
+		m.handlerFailures.WithLabelValues(name).Inc()
+	}
+}
+
+// IncrementAdminAuthFailure counts a request to an admin endpoint rejected
+// for reason.
+func (m *Metrics) IncrementAdminAuthFailure(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+// ObserveCertificateReload counts a certificate reload of server, failed when
+// err is set.
+func (m *Metrics) ObserveCertificateReload(server string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.certReloads.WithLabelValues(server, result).Inc()
+}
+
+// ObserveNamespaceSync records one namespace synced by the controller. Its
+// signature matches controller.Observer.
+func (m *Metrics) ObserveNamespaceSync(duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.namespaceSyncs.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+// ObserveSync records a full controller pass.
+func (m *Metrics) ObserveSync(duration time.Duration, namespaces, failed int) {
+	m.syncDuration.Observe(duration.Seconds())
+	m.syncNamespaces.Set(float64(namespaces))
+	m.syncFailed.Set(float64(failed))
+}
+
+// IncrementReconcileRepair counts a drift of kind repaired by the reconcile
+// loop.
+func (m *Metrics) IncrementReconcileRepair(kind string) {
+	m.repairs.WithLabelValues(kind).Inc()
+}
+
+// SetRuleDrift records the differences found by the latest rule drift check.
+func (m *Metrics) SetRuleDrift(missing, extra, wrongTarget int) {
+	m.ruleDrift.WithLabelValues("missing").Set(float64(missing))
+	m.ruleDrift.WithLabelValues("extra").Set(float64(extra))
+	m.ruleDrift.WithLabelValues("wrong_target").Set(float64(wrongTarget))
+}
+
+// SetCredentialsValid records the outcome of a credential check.
+func (m *Metrics) SetCredentialsValid(valid bool) {
+	if valid {
+		m.credentialsValid.Set(1)
+		return
+	}
+	m.credentialsValid.Set(0)
+}
+
+// SetTokenExpiry records when the mounted service account token expires. The
+// zero time records 0.
+func (m *Metrics) SetTokenExpiry(expiry time.Time) {
+	if expiry.IsZero() {
+		m.tokenExpiry.Set(0)
+		return
+	}
+	m.tokenExpiry.Set(float64(expiry.Unix()))
+}
+
+// RegisterRuntimeCollectors adds the Go runtime and process collectors to the
+// registry. They are left out by default to keep scrapes small.
+func (m *Metrics) RegisterRuntimeCollectors() {
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// SetHandlerOptions chooses whether the scrape handler serves OpenMetrics to
+// scrapers that ask for it (needed for exemplars) and gzip-compresses
+// responses for scrapers that accept it. Both are on by default.
+func (m *Metrics) SetHandlerOptions(openMetrics, compression bool) {
+	m.handlerOpts.EnableOpenMetrics = openMetrics
+	m.handlerOpts.DisableCompression = !compression
+}
+
 // Handler exposes the Prometheus scrape handler bound to the registry.
 func (m *Metrics) Handler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return promhttp.HandlerFor(m.registry, m.handlerOpts)
 }
@@ -1,12 +1,19 @@
 package metrics
 
 import (
+	"compress/gzip"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
 )
 
 func TestNewMetricsRegistersInstruments(t *testing.T) {
@@ -62,6 +69,61 @@ func TestMetricsSetJumpActive(t *testing.T) {
 	}
 }
 
+func TestMetricsSetPreviewPercent(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.SetPreviewPercent(25)
+	if got := testutil.ToFloat64(m.previewPercent); got != 25 {
+		t.Fatalf("expected gauge to be 25, got %v", got)
+	}
+}
+
+func TestMetricsSetScheduleOverride(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.SetScheduleOverride(true)
+	if got := testutil.ToFloat64(m.scheduleOverride); got != 1 {
+		t.Fatalf("expected gauge to be 1, got %v", got)
+	}
+	m.SetScheduleOverride(false)
+	if got := testutil.ToFloat64(m.scheduleOverride); got != 0 {
+		t.Fatalf("expected gauge to be 0, got %v", got)
+	}
+}
+
+func TestMetricsIncrementPreviewExpirations(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.IncrementPreviewExpirations()
+	if got := testutil.ToFloat64(m.expirations); got != 1 {
+		t.Fatalf("expected counter to be 1, got %v", got)
+	}
+}
+
+func TestMetricsSetPreviewCircuitOpen(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.SetPreviewCircuitOpen(true)
+	if got := testutil.ToFloat64(m.circuitOpen); got != 1 {
+		t.Fatalf("expected gauge to be 1, got %v", got)
+	}
+}
+
+func TestMetricsSetConntrackUsage(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.SetConntrackUsage(870, 1000)
+	m.SetConntrackDNATEntries(12)
+	if count, max, dnat := testutil.ToFloat64(m.conntrackCount), testutil.ToFloat64(m.conntrackMax), testutil.ToFloat64(m.conntrackDNAT); count != 870 || max != 1000 || dnat != 12 {
+		t.Fatalf("unexpected conntrack gauges: %v %v %v", count, max, dnat)
+	}
+}
+
 func TestMetricsIncrementError(t *testing.T) {
 	t.Parallel()
 
@@ -109,6 +171,185 @@ func TestMetricsSetDNATRuleCount(t *testing.T) {
 	}
 }
 
+func TestMetricsPollStaleness(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	start := time.Unix(1_700_000_000, 0)
+	now := start
+	m.now = func() time.Time { return now }
+	m.started = start
+
+	now = start.Add(30 * time.Second)
+	if got := m.pollStaleness(); got != 30 {
+		t.Fatalf("expected staleness since startup, got %v", got)
+	}
+
+	m.RecordPollSuccess(start.Add(20 * time.Second))
+	if got := testutil.ToFloat64(m.lastPoll); got != 1_700_000_020 {
+		t.Fatalf("expected last poll timestamp, got %v", got)
+	}
+	now = start.Add(95 * time.Second)
+	if got := m.pollStaleness(); got != 75 {
+		t.Fatalf("expected staleness since last success, got %v", got)
+	}
+}
+
+func TestMetricsObserveSkippedDNATRules(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.ObserveSkippedDNATRules(map[string]uint64{"mixed_family": 2})
+	m.ObserveSkippedDNATRules(map[string]uint64{"mixed_family": 3, "ipv6_disabled": 1})
+	m.ObserveSkippedDNATRules(map[string]uint64{"mixed_family": 3})
+
+	if got := testutil.ToFloat64(m.skipped.WithLabelValues("mixed_family")); got != 3 {
+		t.Fatalf("expected mixed_family counter to follow the totals, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.skipped.WithLabelValues("ipv6_disabled")); got != 1 {
+		t.Fatalf("expected ipv6_disabled counter to be 1, got %v", got)
+	}
+}
+
+func TestMetricsSetMappingInfo(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		{ServiceName: "cart", Port: 443, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.2", PreviewClusterIP: "10.0.1.2"},
+		{ServiceName: "dns", Port: 53, Protocol: corev1.ProtocolUDP, ActiveClusterIP: "10.0.0.3", PreviewClusterIP: "10.0.1.3"},
+	}
+
+	if omitted := m.SetMappingInfo(mappings, 2); omitted != 1 {
+		t.Fatalf("expected 1 mapping omitted, got %d", omitted)
+	}
+	if got := testutil.CollectAndCount(m.mappingInfo); got != 2 {
+		t.Fatalf("expected 2 mapping_info series, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.mappingInfo.WithLabelValues("orders", "80", "TCP", "10.0.0.1", "10.0.1.1")); got != 1 {
+		t.Fatalf("expected orders series to be 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.omitted); got != 1 {
+		t.Fatalf("expected omitted gauge to be 1, got %v", got)
+	}
+
+	if omitted := m.SetMappingInfo(mappings[:1], 2); omitted != 0 {
+		t.Fatalf("expected nothing omitted, got %d", omitted)
+	}
+	if got := testutil.CollectAndCount(m.mappingInfo); got != 1 {
+		t.Fatalf("expected stale series to be removed, got %d", got)
+	}
+
+	if omitted := m.SetMappingInfo(mappings, 0); omitted != 3 || testutil.CollectAndCount(m.mappingInfo) != 0 {
+		t.Fatalf("expected a zero limit to export nothing, omitted %d", omitted)
+	}
+}
+
+func TestMetricsObserveAPIRequest(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.ObserveAPIRequest(http.MethodGet, "pods", http.StatusOK, 20*time.Millisecond)
+	m.ObserveAPIRequest(http.MethodGet, "pods", http.StatusOK, 3*time.Second)
+	m.ObserveAPIRequest(http.MethodGet, "pods", 0, time.Second)
+
+	if got := testutil.ToFloat64(m.apiRequests.WithLabelValues(http.MethodGet, "pods", "200")); got != 2 {
+		t.Fatalf("expected 2 successful requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.apiRequests.WithLabelValues(http.MethodGet, "pods", "error")); got != 1 {
+		t.Fatalf("expected 1 failed request, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.apiLatency); got != 1 {
+		t.Fatalf("expected one latency series, got %d", got)
+	}
+}
+
+func TestMetricsObserveTransitionHandler(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.ObserveTransitionHandler("routing", 10*time.Millisecond, nil)
+	m.ObserveTransitionHandler("webhook", time.Second, errors.New("timeout"))
+
+	if got := testutil.ToFloat64(m.handlerFailures.WithLabelValues("webhook")); got != 1 {
+		t.Fatalf("expected 1 webhook failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.handlerFailures.WithLabelValues("routing")); got != 0 {
+		t.Fatalf("expected no routing failures, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.handlerLatency); got != 2 {
+		t.Fatalf("expected two latency series, got %d", got)
+	}
+}
+
+func TestMetricsHandlerNegotiation(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	m.SetDNATRuleCount(3)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("expected OpenMetrics content type, got %q", ct)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", enc)
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("open gzip body: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read gzip body: %v", err)
+	}
+	if !strings.Contains(string(body), "ghostwire_dnat_rules 3") || !strings.HasSuffix(string(body), "# EOF\n") {
+		t.Fatalf("unexpected OpenMetrics body %q", body)
+	}
+
+	m.SetHandlerOptions(false, false)
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("expected text format with OpenMetrics disabled, got %q", ct)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected no compression, got %q", enc)
+	}
+}
+
+func TestMetricsRegisterRuntimeCollectors(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	hasGoroutines := func() bool {
+		families, err := m.registry.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+		for _, family := range families {
+			if family.GetName() == "go_goroutines" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasGoroutines() {
+		t.Fatal("expected runtime metrics to be off by default")
+	}
+	m.RegisterRuntimeCollectors()
+	if !hasGoroutines() {
+		t.Fatal("expected go_goroutines after registering runtime collectors")
+	}
+}
+
 func TestMetricsHandler(t *testing.T) {
 	t.Parallel()
 
@@ -118,6 +359,7 @@ func TestMetricsHandler(t *testing.T) {
 	m.IncrementError("label_read")
 	m.IncrementError("chain_verify")
 	m.SetDNATRuleCount(5)
+	m.RecordPollSuccess(time.Unix(1_700_000_000, 0))
 
 	handler := m.Handler()
 	if handler == nil {
@@ -146,6 +388,8 @@ func TestMetricsHandler(t *testing.T) {
 		"ghostwire_errors_total{type=\"label_read\"} 2",
 		"ghostwire_errors_total{type=\"chain_verify\"} 1",
 		"ghostwire_dnat_rules 5",
+		"ghostwire_last_poll_success_timestamp_seconds 1.7e+09",
+		"# TYPE ghostwire_poll_staleness_seconds gauge",
 	} {
 		if !strings.Contains(body, snippet) {
 			t.Fatalf("expected metrics output to contain %q, got %q", snippet, body)
@@ -0,0 +1,161 @@
+// Package netpol builds NetworkPolicies that confine preview pods to the
+// preview services ghostwire routes them to, so a preview environment cannot
+// reach active dependencies directly.
+package netpol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// namespaceNameLabel is set on every namespace by the API server.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// Target is one preview service's pods, which preview pods may reach on
+// Ports.
+type Target struct {
+	Service   string
+	Namespace string
+	Selector  map[string]string
+	Ports     []networkingv1.NetworkPolicyPort
+
+	servicePorts []corev1.ServicePort
+}
+
+// Options describe the pods the policy applies to and where DNS runs.
+type Options struct {
+	Name      string
+	Namespace string
+	// LabelKey and PreviewValue select the preview pods.
+	LabelKey     string
+	PreviewValue string
+	// DNSNamespace and DNSSelector locate the cluster DNS pods.
+	DNSNamespace string
+	DNSSelector  map[string]string
+}
+
+// ResolveTargets looks up each mapping's preview Service and turns its
+// selector and target ports into a Target. NetworkPolicy applies after
+// kube-proxy's DNAT, so the policy must name the backing pods rather than the
+// ClusterIP. Mappings without a recorded preview service, and services
+// without a selector, are returned in skipped.
+func ResolveTargets(ctx context.Context, client kubernetes.Interface, mappings []discovery.ServiceMapping) (targets []Target, skipped []string, err error) {
+	byService := map[string]*Target{}
+	var order []string
+
+	for _, mapping := range mappings {
+		namespace, name := previewService(mapping)
+		if name == "" {
+			skipped = append(skipped, fmt.Sprintf("%s:%d/%s (no preview service recorded)", mapping.ServiceName, mapping.Port, mapping.Protocol))
+			continue
+		}
+		key := namespace + "/" + name
+
+		if _, seen := byService[key]; !seen {
+			svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, nil, fmt.Errorf("get preview service %s: %w", key, err)
+			}
+			if len(svc.Spec.Selector) == 0 {
+				skipped = append(skipped, key+" (service has no selector)")
+				byService[key] = nil
+				continue
+			}
+			byService[key] = &Target{Service: name, Namespace: namespace, Selector: svc.Spec.Selector, servicePorts: svc.Spec.Ports}
+			order = append(order, key)
+		}
+
+		target := byService[key]
+		if target == nil {
+			continue
+		}
+		for _, port := range target.servicePorts {
+			if port.Port == mapping.TargetPort() && port.Protocol == mapping.Protocol {
+				target.Ports = append(target.Ports, policyPort(port))
+			}
+		}
+	}
+
+	for _, key := range order {
+		targets = append(targets, *byService[key])
+	}
+	return targets, skipped, nil
+}
+
+// previewService splits PreviewServiceName, which is "namespace/name" for
+// services outside the mapping's namespace.
+func previewService(mapping discovery.ServiceMapping) (string, string) {
+	if namespace, name, ok := strings.Cut(mapping.PreviewServiceName, "/"); ok {
+		return namespace, name
+	}
+	return mapping.Namespace, mapping.PreviewServiceName
+}
+
+func policyPort(port corev1.ServicePort) networkingv1.NetworkPolicyPort {
+	protocol := port.Protocol
+	target := port.TargetPort
+	if target.Type == intstr.Int && target.IntVal == 0 || target.Type == intstr.String && target.StrVal == "" {
+		target = intstr.FromInt32(port.Port)
+	}
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &target}
+}
+
+// Build returns an egress-only policy for the preview pods allowing DNS and
+// the targets, and nothing else.
+func Build(opts Options, targets []Target) *networkingv1.NetworkPolicy {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt32(53)
+
+	egress := []networkingv1.NetworkPolicyEgressRule{{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: opts.DNSNamespace}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: opts.DNSSelector},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &dnsPort},
+			{Protocol: &tcp, Port: &dnsPort},
+		},
+	}}
+
+	sorted := append([]Target(nil), targets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Service < sorted[j].Service
+	})
+	for _, target := range sorted {
+		peer := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: target.Selector}}
+		if target.Namespace != opts.Namespace {
+			peer.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: target.Namespace}}
+		}
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To:    []networkingv1.NetworkPolicyPeer{peer},
+			Ports: target.Ports,
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "ghostwire"},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{opts.LabelKey: opts.PreviewValue}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+}
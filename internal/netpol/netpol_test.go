@@ -0,0 +1,82 @@
+package netpol
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+func previewSvc(namespace, name string, selector map[string]string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.ServiceSpec{Selector: selector, Ports: ports},
+	}
+}
+
+func TestResolveTargetsAndBuild(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		previewSvc("shop", "orders-preview", map[string]string{"app": "orders", "track": "preview"},
+			corev1.ServicePort{Port: 80, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromString("http")},
+			corev1.ServicePort{Port: 9090, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromInt32(9090)},
+		),
+		previewSvc("payments", "billing-preview", map[string]string{"app": "billing"},
+			corev1.ServicePort{Port: 8443, Protocol: corev1.ProtocolTCP},
+		),
+		previewSvc("shop", "external-preview", nil, corev1.ServicePort{Port: 443, Protocol: corev1.ProtocolTCP}),
+	)
+	mappings := []discovery.ServiceMapping{
+		{Namespace: "shop", ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, PreviewServiceName: "orders-preview"},
+		{Namespace: "shop", ServiceName: "billing", Port: 443, PreviewPort: 8443, Protocol: corev1.ProtocolTCP, PreviewServiceName: "payments/billing-preview"},
+		{Namespace: "shop", ServiceName: "external", Port: 443, Protocol: corev1.ProtocolTCP, PreviewServiceName: "external-preview"},
+		{Namespace: "shop", ServiceName: "legacy", Port: 80, Protocol: corev1.ProtocolTCP},
+	}
+
+	targets, skipped, err := ResolveTargets(context.Background(), client, mappings)
+	if err != nil {
+		t.Fatalf("ResolveTargets returned error: %v", err)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("expected selectorless and unrecorded services to be skipped, got %v", skipped)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %+v", targets)
+	}
+	if len(targets[0].Ports) != 1 || targets[0].Ports[0].Port.String() != "http" {
+		t.Fatalf("orders target should allow only the named target port, got %+v", targets[0].Ports)
+	}
+	if targets[1].Ports[0].Port.IntValue() != 8443 {
+		t.Fatalf("billing target should default the target port to the service port, got %+v", targets[1].Ports)
+	}
+
+	policy := Build(Options{
+		Name:         "ghostwire-preview-egress",
+		Namespace:    "shop",
+		LabelKey:     "role",
+		PreviewValue: "preview",
+		DNSNamespace: "kube-system",
+		DNSSelector:  map[string]string{"k8s-app": "kube-dns"},
+	}, targets)
+
+	if got := policy.Spec.PodSelector.MatchLabels["role"]; got != "preview" {
+		t.Fatalf("policy should select preview pods, got %v", policy.Spec.PodSelector.MatchLabels)
+	}
+	if len(policy.Spec.Egress) != 3 {
+		t.Fatalf("expected DNS plus two targets, got %d egress rules", len(policy.Spec.Egress))
+	}
+	billing := policy.Spec.Egress[1].To[0]
+	if billing.NamespaceSelector == nil || billing.NamespaceSelector.MatchLabels[namespaceNameLabel] != "payments" {
+		t.Fatalf("cross-namespace target needs a namespace selector, got %+v", billing)
+	}
+	orders := policy.Spec.Egress[2].To[0]
+	if orders.NamespaceSelector != nil || orders.PodSelector.MatchLabels["track"] != "preview" {
+		t.Fatalf("same-namespace target should use only the pod selector, got %+v", orders)
+	}
+}
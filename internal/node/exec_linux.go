@@ -0,0 +1,113 @@
+//go:build linux
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// NetnsExecutor runs the commands of Delegate in the network namespace at
+// Path. Each call happens on an OS thread switched into the namespace, and
+// the iptables processes it starts inherit that namespace.
+type NetnsExecutor struct {
+	Path     string
+	Delegate iptables.Executor
+}
+
+// NewNetnsExecutor returns an ExecutorFunc wrapping delegate.
+func NewNetnsExecutor(delegate iptables.Executor) ExecutorFunc {
+	return func(path string) iptables.Executor {
+		return &NetnsExecutor{Path: path, Delegate: delegate}
+	}
+}
+
+// Run executes the command inside the namespace.
+func (n *NetnsExecutor) Run(ctx context.Context, command string, args ...string) error {
+	return n.enter(func() error {
+		return n.Delegate.Run(ctx, command, args...)
+	})
+}
+
+// ChainExists checks for an IPv4 chain inside the namespace.
+func (n *NetnsExecutor) ChainExists(ctx context.Context, table string, chain string) (bool, error) {
+	var exists bool
+	err := n.enter(func() error {
+		var err error
+		exists, err = n.Delegate.ChainExists(ctx, table, chain)
+		return err
+	})
+	return exists, err
+}
+
+// ChainExists6 checks for an IPv6 chain inside the namespace.
+func (n *NetnsExecutor) ChainExists6(ctx context.Context, table string, chain string) (bool, error) {
+	var exists bool
+	err := n.enter(func() error {
+		var err error
+		exists, err = n.Delegate.ChainExists6(ctx, table, chain)
+		return err
+	})
+	return exists, err
+}
+
+// Output executes the command inside the namespace and returns its standard
+// output, when the delegate can.
+func (n *NetnsExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	outputExecutor, ok := n.Delegate.(iptables.OutputExecutor)
+	if !ok {
+		return "", errors.New("executor cannot read rule listings")
+	}
+	var output string
+	err := n.enter(func() error {
+		var err error
+		output, err = outputExecutor.Output(ctx, command, args...)
+		return err
+	})
+	return output, err
+}
+
+// RunInput executes the command inside the namespace with input as its
+// standard input, when the delegate can.
+func (n *NetnsExecutor) RunInput(ctx context.Context, input string, command string, args ...string) error {
+	inputExecutor, ok := n.Delegate.(iptables.InputExecutor)
+	if !ok {
+		return errors.New("executor cannot pass standard input")
+	}
+	return n.enter(func() error {
+		return inputExecutor.RunInput(ctx, input, command, args...)
+	})
+}
+
+// enter runs fn on a thread switched into the namespace. The thread is
+// discarded afterwards rather than returned to the scheduler with a foreign
+// namespace.
+func (n *NetnsExecutor) enter(fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		// Deliberately no UnlockOSThread: the goroutine exiting while locked
+		// terminates the thread.
+
+		// #nosec G304 -- the path comes from the netns resolver.
+		f, err := os.Open(n.Path)
+		if err != nil {
+			errCh <- fmt.Errorf("open network namespace %s: %w", n.Path, err)
+			return
+		}
+		defer f.Close()
+		if err := unix.Setns(int(f.Fd()), unix.CLONE_NEWNET); err != nil {
+			errCh <- fmt.Errorf("enter network namespace %s: %w", n.Path, err)
+			return
+		}
+		errCh <- fn()
+	}()
+	return <-errCh
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package node
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+var errUnsupported = errors.New("node mode is not supported on this platform")
+
+// NewNetnsExecutor is not supported outside Linux; the executors it returns
+// fail every command.
+func NewNetnsExecutor(iptables.Executor) ExecutorFunc {
+	return func(string) iptables.Executor {
+		return unsupportedExecutor{}
+	}
+}
+
+type unsupportedExecutor struct{}
+
+func (unsupportedExecutor) Run(context.Context, string, ...string) error {
+	return errUnsupported
+}
+
+func (unsupportedExecutor) ChainExists(context.Context, string, string) (bool, error) {
+	return false, errUnsupported
+}
+
+func (unsupportedExecutor) ChainExists6(context.Context, string, string) (bool, error) {
+	return false, errUnsupported
+}
@@ -0,0 +1,52 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProcResolver finds a pod's network namespace through the host's /proc: the
+// kubelet places every container of the pod in a cgroup named after the pod
+// UID, and any of their processes leads to the shared namespace. It needs the
+// host PID namespace (hostPID: true), with Root pointing at its /proc, and
+// works with every CRI runtime and cgroup driver.
+type ProcResolver struct {
+	Root string
+}
+
+// Netns returns the ns/net path of a process of pod.
+func (r ProcResolver) Netns(pod *corev1.Pod) (string, error) {
+	uid := string(pod.UID)
+	if uid == "" {
+		return "", fmt.Errorf("pod %s/%s has no uid", pod.Namespace, pod.Name)
+	}
+	// cgroupfs writes pod<uid>, the systemd driver pod<uid with '_' for '-'>.
+	markers := []string{"pod" + uid, "pod" + strings.ReplaceAll(uid, "-", "_")}
+
+	entries, err := os.ReadDir(r.Root)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", r.Root, err)
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		// #nosec G304 -- Root is the host's /proc outside tests.
+		cgroup, err := os.ReadFile(filepath.Join(r.Root, entry.Name(), "cgroup"))
+		if err != nil {
+			// The process exited while the directory was being read.
+			continue
+		}
+		for _, marker := range markers {
+			if strings.Contains(string(cgroup), marker) {
+				return filepath.Join(r.Root, entry.Name(), "ns", "net"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no process of pod %s/%s (uid %s) under %s", pod.Namespace, pod.Name, uid, r.Root)
+}
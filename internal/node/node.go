@@ -0,0 +1,271 @@
+// Package node implements ghostwire's node mode. One privileged DaemonSet pod
+// per node enters the network namespaces of the annotated pods scheduled on
+// its node and manages their DNAT chains and jumps itself, so those pods need
+// neither an init container nor a watcher sidecar with NET_ADMIN.
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/denniswebb/ghostwire/internal/backend"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+const (
+	// ManagedAnnotation opts a pod into node mode when set to "true".
+	ManagedAnnotation = "ghostwire.dev/node-managed"
+
+	defaultInterval = 5 * time.Second
+)
+
+// DiscoverFunc returns the mappings for a single namespace.
+type DiscoverFunc func(ctx context.Context, namespace string) ([]discovery.ServiceMapping, error)
+
+// NetnsResolver finds the network namespace of a running pod.
+type NetnsResolver interface {
+	Netns(pod *corev1.Pod) (string, error)
+}
+
+// ExecutorFunc returns an executor whose commands run in the network
+// namespace at path.
+type ExecutorFunc func(path string) iptables.Executor
+
+// Config describes the node and the rules built in each managed pod.
+type Config struct {
+	Client kubernetes.Interface
+	// NodeName is the node whose pods are managed.
+	NodeName string
+	// Discover performs discovery for the namespace of a managed pod.
+	Discover DiscoverFunc
+	Netns    NetnsResolver
+	Executor ExecutorFunc
+	// Rules shape the chain built in every managed pod. DnatMapPath is ignored;
+	// pods have no shared volume in node mode.
+	Rules iptables.Config
+	Hooks []string
+	// Backend names the rule backend, backend.DefaultName when empty.
+	Backend string
+	// RoleLabelKey and PreviewValue decide when a pod's jump is installed.
+	RoleLabelKey string
+	PreviewValue string
+	Interval     time.Duration
+	Logger       *slog.Logger
+}
+
+// Manager periodically reconciles the chains and jumps of the managed pods on
+// one node.
+type Manager struct {
+	cfg    Config
+	logger *slog.Logger
+	pods   map[types.UID]*podState
+}
+
+// podState is what the manager last did in one pod's network namespace.
+type podState struct {
+	name      string
+	namespace string
+	netns     string
+	// hash identifies the mappings last applied to the chain.
+	hash string
+	jump bool
+}
+
+// New validates cfg and constructs a Manager.
+func New(cfg Config) (*Manager, error) {
+	if cfg.Client == nil {
+		return nil, errors.New("kubernetes client must be provided")
+	}
+	if cfg.NodeName == "" {
+		return nil, errors.New("node name must be provided")
+	}
+	if cfg.Discover == nil {
+		return nil, errors.New("discover function must be provided")
+	}
+	if cfg.Netns == nil || cfg.Executor == nil {
+		return nil, errors.New("netns resolver and executor must be provided")
+	}
+	if cfg.RoleLabelKey == "" || cfg.PreviewValue == "" {
+		return nil, errors.New("role label key and preview value must be provided")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	cfg.Rules.DnatMapPath = ""
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Manager{cfg: cfg, logger: logger, pods: make(map[types.UID]*podState)}, nil
+}
+
+// Run syncs immediately and then on every interval until ctx is cancelled.
+// Sync errors are logged; they never stop the loop.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.SyncOnce(ctx); err != nil {
+			m.logger.Warn("node sync finished with errors", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce discovers the mappings of every namespace with managed pods,
+// rebuilds a pod's chain when it is newly seen, its network namespace changed
+// or its mappings did, installs or removes each pod's jump to follow its role
+// label, and forgets pods that are gone. A failure in one pod does not prevent the others from being
+// processed; all failures are joined, and the failed pods are retried on the
+// next sync.
+func (m *Manager) SyncOnce(ctx context.Context) error {
+	list, err := m.cfg.Client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", m.cfg.NodeName).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("list pods on node %s: %w", m.cfg.NodeName, err)
+	}
+
+	seen := make(map[types.UID]bool)
+	mappings := make(map[string][]discovery.ServiceMapping)
+	var errs []error
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if !managed(pod) {
+			continue
+		}
+		seen[pod.UID] = true
+		if err := m.syncPod(ctx, pod, mappings); err != nil {
+			m.logger.Warn("pod sync failed", slog.String("namespace", pod.Namespace), slog.String("pod", pod.Name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("pod %s/%s: %w", pod.Namespace, pod.Name, err))
+		}
+	}
+
+	for uid, state := range m.pods {
+		if !seen[uid] {
+			m.logger.Info("pod no longer managed", slog.String("namespace", state.namespace), slog.String("pod", state.name))
+			delete(m.pods, uid)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Pods returns the number of pods whose chain is in place.
+func (m *Manager) Pods() int {
+	return len(m.pods)
+}
+
+// managed reports whether pod opted into node mode and has a network
+// namespace of its own to manage. Host-network pods are never touched, since
+// their rules would land in the node's namespace.
+func managed(pod *corev1.Pod) bool {
+	return pod.Annotations[ManagedAnnotation] == "true" &&
+		pod.Status.Phase == corev1.PodRunning &&
+		pod.DeletionTimestamp == nil &&
+		!pod.Spec.HostNetwork
+}
+
+func (m *Manager) syncPod(ctx context.Context, pod *corev1.Pod, mappings map[string][]discovery.ServiceMapping) error {
+	// A restarted container gets a new process, so the path is resolved on
+	// every sync; a changed path means the rules must be built again.
+	netns, err := m.cfg.Netns.Netns(pod)
+	if err != nil {
+		return err
+	}
+	found, ok := mappings[pod.Namespace]
+	if !ok {
+		found, err = m.cfg.Discover(ctx, pod.Namespace)
+		if err != nil {
+			return fmt.Errorf("discover services: %w", err)
+		}
+		mappings[pod.Namespace] = found
+	}
+	hash, err := mappingHash(found)
+	if err != nil {
+		return err
+	}
+
+	logger := m.logger.With(slog.String("namespace", pod.Namespace), slog.String("pod", pod.Name))
+	state := m.pods[pod.UID]
+	fresh := state == nil || state.netns != netns
+	if fresh || state.hash != hash {
+		rules, err := m.rules(netns, logger)
+		if err != nil {
+			return err
+		}
+		if err := rules.ApplyMappings(ctx, found); err != nil {
+			delete(m.pods, pod.UID)
+			return fmt.Errorf("set up rules: %w", err)
+		}
+		if fresh {
+			state = &podState{name: pod.Name, namespace: pod.Namespace, netns: netns}
+			m.pods[pod.UID] = state
+		}
+		state.hash = hash
+		logger.Info("pod chain prepared", slog.String("netns", netns), slog.Int("mappings", len(found)))
+	}
+
+	want := pod.Labels[m.cfg.RoleLabelKey] == m.cfg.PreviewValue
+	if !fresh && want == state.jump {
+		return nil
+	}
+
+	rules, err := m.rules(state.netns, logger)
+	if err != nil {
+		return err
+	}
+	if want {
+		err = rules.AddJump(ctx)
+	} else {
+		err = rules.RemoveJump(ctx)
+	}
+	if err != nil {
+		delete(m.pods, pod.UID)
+		return fmt.Errorf("update jump: %w", err)
+	}
+	logger.Info("pod jump updated", slog.Bool("jump", want))
+	state.jump = want
+	return nil
+}
+
+// mappingHash identifies a set of mappings, so a pod's chain is rebuilt only
+// when discovery returns something new.
+func mappingHash(mappings []discovery.ServiceMapping) (string, error) {
+	encoded, err := json.Marshal(mappings)
+	if err != nil {
+		return "", fmt.Errorf("encode mappings: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// rules returns the rule backend for the network namespace at netns.
+func (m *Manager) rules(netns string, logger *slog.Logger) (backend.Backend, error) {
+	return backend.New(m.cfg.Backend, backend.Options{
+		Executor: m.cfg.Executor(netns),
+		Rules:    m.cfg.Rules,
+		Hooks:    m.cfg.Hooks,
+		Logger:   logger,
+	})
+}
@@ -0,0 +1,203 @@
+package node
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/pkg/iptablestest"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type staticResolver map[types.UID]string
+
+func (s staticResolver) Netns(pod *corev1.Pod) (string, error) {
+	return s[pod.UID], nil
+}
+
+func testPod(name string, uid types.UID, role string, annotated bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: uid, Labels: map[string]string{"role": role}},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if annotated {
+		pod.Annotations = map[string]string{ManagedAnnotation: "true"}
+	}
+	return pod
+}
+
+func TestSyncOnceManagesAnnotatedPods(t *testing.T) {
+	t.Parallel()
+
+	hostNetwork := testPod("agent", "uid-agent", "preview", true)
+	hostNetwork.Spec.HostNetwork = true
+	client := fake.NewSimpleClientset(
+		testPod("web", "uid-web", "preview", true),
+		testPod("db", "uid-db", "preview", false),
+		hostNetwork,
+	)
+
+	executors := map[string]*iptablestest.Fake{"/proc/10/ns/net": iptablestest.New()}
+	executors["/proc/10/ns/net"].RulesMissing()
+	var discovered []string
+	manager, err := New(Config{
+		Client:   client,
+		NodeName: "node-a",
+		Discover: func(_ context.Context, namespace string) ([]discovery.ServiceMapping, error) {
+			discovered = append(discovered, namespace)
+			return []discovery.ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.0.2"},
+			}, nil
+		},
+		Netns:        staticResolver{"uid-web": "/proc/10/ns/net", "uid-agent": "/proc/1/ns/net"},
+		Executor:     func(path string) iptables.Executor { return executors[path] },
+		Rules:        iptables.Config{ChainName: "CANARY_DNAT", DnatMapPath: filepath.Join(t.TempDir(), "dnat.map")},
+		Hooks:        []string{"OUTPUT"},
+		RoleLabelKey: "role",
+		PreviewValue: "preview",
+		Logger:       testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	if manager.Pods() != 1 || len(discovered) != 1 {
+		t.Fatalf("expected one managed pod and one discovery, got %d pods and %v", manager.Pods(), discovered)
+	}
+	web := executors["/proc/10/ns/net"]
+	web.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-N", "CANARY_DNAT")
+	web.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-I", "OUTPUT", "1", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+
+	// A second sync with no changes leaves the namespace alone.
+	web.Reset()
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	web.AssertNoCalls(t)
+
+	pod, err := client.CoreV1().Pods("shop").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	pod.Labels["role"] = "active"
+	if _, err := client.CoreV1().Pods("shop").Update(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update pod: %v", err)
+	}
+	installed := iptablestest.New()
+	executors["/proc/10/ns/net"] = installed
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	installed.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-D", "OUTPUT", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+
+	if err := client.CoreV1().Pods("shop").Delete(context.Background(), "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete pod: %v", err)
+	}
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	if manager.Pods() != 0 {
+		t.Fatalf("expected the deleted pod to be forgotten, got %d pods", manager.Pods())
+	}
+}
+
+func TestProcResolverFindsPodCgroup(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	write := func(pid, cgroup string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(root, pid), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, pid, "cgroup"), []byte(cgroup), 0o600); err != nil {
+			t.Fatalf("write cgroup: %v", err)
+		}
+	}
+	write("1", "0::/init.scope\n")
+	write("42", "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234_abcd.slice/cri-containerd-0f.scope\n")
+
+	resolver := ProcResolver{Root: root}
+	path, err := resolver.Netns(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "1234-abcd"}})
+	if err != nil {
+		t.Fatalf("Netns returned error: %v", err)
+	}
+	if path != filepath.Join(root, "42", "ns", "net") {
+		t.Fatalf("unexpected netns path %s", path)
+	}
+
+	if _, err := resolver.Netns(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gone", Namespace: "shop", UID: "9999"}}); err == nil {
+		t.Fatal("expected an error for a pod without processes")
+	}
+}
+
+func TestSyncOnceReappliesOnChange(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(testPod("web", "uid-web", "preview", true))
+	executors := map[string]*iptablestest.Fake{"/proc/10/ns/net": iptablestest.New(), "/proc/11/ns/net": iptablestest.New()}
+	for _, executor := range executors {
+		executor.RulesMissing()
+	}
+	resolver := staticResolver{"uid-web": "/proc/10/ns/net"}
+	preview := "10.0.0.2"
+	manager, err := New(Config{
+		Client:   client,
+		NodeName: "node-a",
+		Discover: func(context.Context, string) ([]discovery.ServiceMapping, error) {
+			return []discovery.ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: preview},
+			}, nil
+		},
+		Netns:        resolver,
+		Executor:     func(path string) iptables.Executor { return executors[path] },
+		Rules:        iptables.Config{ChainName: "CANARY_DNAT"},
+		Hooks:        []string{"OUTPUT"},
+		RoleLabelKey: "role",
+		PreviewValue: "preview",
+		Logger:       testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+
+	// New mappings rebuild the chain; the jump is already in place.
+	old := executors["/proc/10/ns/net"]
+	old.Reset()
+	preview = "10.0.0.3"
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	old.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-A", "CANARY_DNAT", "-d", "10.0.0.1", "-p", "tcp", "--dport", "80", "-m", "comment", "--comment", "ghostwire", "-j", "DNAT", "--to-destination", "10.0.0.3:80")
+	old.AssertNotCalled(t, "-I", "OUTPUT")
+
+	// A restarted container has a new namespace, which gets the chain and the
+	// jump again.
+	resolver["uid-web"] = "/proc/11/ns/net"
+	if err := manager.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("SyncOnce returned error: %v", err)
+	}
+	restarted := executors["/proc/11/ns/net"]
+	restarted.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-N", "CANARY_DNAT")
+	restarted.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-I", "OUTPUT", "1", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+}
@@ -0,0 +1,99 @@
+// Package output renders command results as a table, JSON or YAML so every
+// ghostwire subcommand honours the same --output flag.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Format selects how a result is rendered.
+type Format string
+
+// Supported formats.
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+)
+
+// ParseFormat validates a --output value. An empty value and "text", the
+// name verify used before the flag was shared, both select FormatTable.
+func ParseFormat(raw string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "text", string(FormatTable):
+		return FormatTable, nil
+	case string(FormatJSON):
+		return FormatJSON, nil
+	case string(FormatYAML), "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q (want table, json or yaml)", raw)
+	}
+}
+
+// Tabular is implemented by results with a human-readable form. Results that
+// do not implement it are rendered as YAML in table mode.
+type Tabular interface {
+	WriteTable(w io.Writer) error
+}
+
+// Write renders v to w in format. JSON and YAML use v's json struct tags.
+func Write(w io.Writer, format Format, v any) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case FormatYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode yaml: %w", err)
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatTable:
+		if t, ok := v.(Tabular); ok {
+			return t.WriteTable(w)
+		}
+		return Write(w, FormatYAML, v)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// Table writes tab-aligned rows under an upper-case header.
+type Table struct {
+	tw *tabwriter.Writer
+}
+
+// NewTable starts a table on w with the given column headers.
+func NewTable(w io.Writer, headers ...string) *Table {
+	t := &Table{tw: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+	upper := make([]string, len(headers))
+	for i, h := range headers {
+		upper[i] = strings.ToUpper(h)
+	}
+	t.Row(upper...)
+	return t
+}
+
+// Row appends one row. Empty cells are shown as "-" so columns stay aligned.
+func (t *Table) Row(cells ...string) {
+	for i, cell := range cells {
+		if cell == "" {
+			cells[i] = "-"
+		}
+	}
+	fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+}
+
+// Flush writes the aligned table.
+func (t *Table) Flush() error {
+	return t.tw.Flush()
+}
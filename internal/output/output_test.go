@@ -0,0 +1,74 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type result struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type tabularResult struct {
+	result
+}
+
+func (r tabularResult) WriteTable(w io.Writer) error {
+	t := NewTable(w, "name", "count", "note")
+	t.Row(r.Name, "3", "")
+	return t.Flush()
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Format{
+		"":      FormatTable,
+		"text":  FormatTable,
+		"TABLE": FormatTable,
+		"json":  FormatJSON,
+		"yaml":  FormatYAML,
+		"yml":   FormatYAML,
+	}
+	for raw, want := range tests {
+		got, err := ParseFormat(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseFormat(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatalf("expected xml to be rejected")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format Format
+		value  any
+		want   string
+	}{
+		{name: "json", format: FormatJSON, value: result{Name: "orders", Count: 3}, want: "{\n  \"name\": \"orders\",\n  \"count\": 3\n}\n"},
+		{name: "yaml", format: FormatYAML, value: result{Name: "orders", Count: 3}, want: "count: 3\nname: orders\n"},
+		{name: "table", format: FormatTable, value: tabularResult{result{Name: "orders"}}, want: "NAME    COUNT  NOTE\norders  3      -\n"},
+		{name: "table falls back to yaml", format: FormatTable, value: result{Name: "orders", Count: 3}, want: "count: 3\nname: orders\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := Write(&buf, tc.format, tc.value); err != nil {
+				t.Fatalf("Write returned error: %v", err)
+			}
+			if buf.String() != tc.want {
+				t.Fatalf("unexpected output:\n%q\nwant\n%q", buf.String(), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+// Package privsep lets a long-running process give up its capabilities once
+// setup is done while the commands it executes keep the ones they need.
+package privsep
+
+import "errors"
+
+// ErrUnsupported is returned on platforms without Linux capabilities, and on
+// Linux when the binary was built with cgo, which prevents changing the
+// capabilities of every thread at once.
+var ErrUnsupported = errors.New("privilege separation is not supported on this platform")
@@ -0,0 +1,76 @@
+//go:build linux
+
+package privsep
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// KeepForExec lists the capabilities re-executed iptables helpers need.
+var KeepForExec = []uintptr{unix.CAP_NET_ADMIN, unix.CAP_NET_RAW}
+
+// maxCapability bounds the scan of the bounding set; the kernel reports
+// capabilities it does not know as invalid well before this.
+const maxCapability = 63
+
+// DropEffective clears the effective, permitted, inheritable and ambient
+// capability sets of every thread in the process, so it cannot raise any
+// capability again and passes none on through its ambient set. When the
+// process holds CAP_SETPCAP it first shrinks the bounding set to keep: a
+// helper re-executed as root then regains only those capabilities at exec.
+// Without CAP_SETPCAP the bounding set is left alone, and a helper running as
+// another user needs file capabilities on the binary instead.
+func DropEffective(keep []uintptr) error {
+	if err := shrinkBoundingSet(keep); err != nil {
+		return err
+	}
+
+	if _, _, errno := syscall.AllThreadsSyscall6(unix.SYS_PRCTL, unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0, 0); errno != 0 {
+		if errors.Is(errno, syscall.ENOTSUP) {
+			return ErrUnsupported
+		}
+		return fmt.Errorf("clear ambient capabilities: %w", errno)
+	}
+
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var next [2]unix.CapUserData
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&next[0])), 0); errno != 0 {
+		return fmt.Errorf("set capabilities: %w", errno)
+	}
+	return nil
+}
+
+// shrinkBoundingSet drops every capability outside keep from the bounding
+// set. It is a no-op when the process lacks CAP_SETPCAP.
+func shrinkBoundingSet(keep []uintptr) error {
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return fmt.Errorf("read capabilities: %w", err)
+	}
+	if data[unix.CAP_SETPCAP/32].Effective&(1<<(unix.CAP_SETPCAP%32)) == 0 {
+		return nil
+	}
+
+	for capability := uintptr(0); capability <= maxCapability; capability++ {
+		if slices.Contains(keep, capability) {
+			continue
+		}
+		if _, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, capability, 0, 0, 0); errors.Is(err, unix.EINVAL) {
+			break
+		}
+		if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_CAPBSET_DROP, capability, 0); errno != 0 {
+			if errors.Is(errno, syscall.ENOTSUP) {
+				return ErrUnsupported
+			}
+			return fmt.Errorf("drop bounding capability %d: %w", capability, errno)
+		}
+	}
+	return nil
+}
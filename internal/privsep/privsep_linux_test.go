@@ -0,0 +1,72 @@
+//go:build linux
+
+package privsep
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func readCapabilitySets(t *testing.T) map[string]uint64 {
+	t.Helper()
+
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		t.Fatalf("open status: %v", err)
+	}
+	defer f.Close()
+
+	sets := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !strings.HasPrefix(name, "Cap") {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			t.Fatalf("parse %s: %v", name, err)
+		}
+		sets[name] = mask
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read status: %v", err)
+	}
+	return sets
+}
+
+// TestDropEffective drops the capabilities of the test process itself, so it
+// must stay the only test in this package that touches them.
+func TestDropEffective(t *testing.T) {
+	before := readCapabilitySets(t)
+
+	if err := DropEffective(KeepForExec); err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			t.Skip("capabilities cannot be changed for every thread in this build")
+		}
+		t.Fatalf("DropEffective returned error: %v", err)
+	}
+
+	after := readCapabilitySets(t)
+	for _, set := range []string{"CapEff", "CapPrm", "CapInh", "CapAmb"} {
+		if after[set] != 0 {
+			t.Errorf("expected %s to be empty, got %016x", set, after[set])
+		}
+	}
+
+	if before["CapEff"]&(1<<unix.CAP_SETPCAP) != 0 {
+		var keep uint64
+		for _, capability := range KeepForExec {
+			keep |= 1 << capability
+		}
+		if after["CapBnd"]&^keep != 0 {
+			t.Errorf("expected the bounding set to hold only %016x, got %016x", keep, after["CapBnd"])
+		}
+	}
+}
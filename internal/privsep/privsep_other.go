@@ -0,0 +1,11 @@
+//go:build !linux
+
+package privsep
+
+// KeepForExec lists the capabilities re-executed iptables helpers need.
+var KeepForExec []uintptr
+
+// DropEffective is not supported outside Linux.
+func DropEffective([]uintptr) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,92 @@
+package rulehelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/denniswebb/ghostwire/internal/backend"
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// BackendName is the rule backend that hands every change to the helper.
+const BackendName = "rule-helper"
+
+func init() {
+	backend.Register(BackendName, newBackend)
+}
+
+// helperBackend maps the Backend methods onto the helper's operations. It
+// needs a *Client as its executor.
+type helperBackend struct {
+	client *Client
+	opts   backend.Options
+}
+
+func newBackend(opts backend.Options) (backend.Backend, error) {
+	client, ok := opts.Executor.(*Client)
+	if !ok {
+		return nil, errors.New("rule-helper backend needs a rule helper client as its executor")
+	}
+	return &helperBackend{client: client, opts: opts}, nil
+}
+
+func (b *helperBackend) jumpRequest() JumpRequest {
+	return JumpRequest{
+		Chain:    b.opts.Rules.ChainName,
+		Hooks:    b.opts.Hooks,
+		Position: b.opts.Position.String(),
+		IPv6:     b.opts.Rules.IPv6,
+	}
+}
+
+func (b *helperBackend) chainRequest(mappings []discovery.ServiceMapping) ChainRequest {
+	return ChainRequest{
+		Chain:        b.opts.Rules.ChainName,
+		Hooks:        b.opts.Hooks,
+		Position:     b.opts.Position.String(),
+		IPv6:         b.opts.Rules.IPv6,
+		ExcludeCIDRs: b.opts.Rules.ExcludeCIDRs,
+		Mappings:     mappings,
+	}
+}
+
+// EnsureChain applies the chain with no mappings, leaving only exclusions.
+func (b *helperBackend) EnsureChain(ctx context.Context) error {
+	return b.client.ApplyChain(ctx, b.chainRequest(nil))
+}
+
+// ApplyMappings has the helper rebuild the chain. Unlike the iptables
+// backend, jumps already on the chain stay in place throughout.
+func (b *helperBackend) ApplyMappings(ctx context.Context, mappings []discovery.ServiceMapping) error {
+	return b.client.ApplyChain(ctx, b.chainRequest(mappings))
+}
+
+func (b *helperBackend) AddJump(ctx context.Context) error {
+	return b.client.AddJump(ctx, b.jumpRequest())
+}
+
+func (b *helperBackend) RemoveJump(ctx context.Context) error {
+	return b.client.RemoveJump(ctx, b.jumpRequest())
+}
+
+func (b *helperBackend) Verify(ctx context.Context, mappings []discovery.ServiceMapping) ([]iptables.Discrepancy, error) {
+	status, err := b.client.VerifyChain(ctx, b.chainRequest(mappings))
+	if err != nil {
+		return nil, err
+	}
+	if !status.Exists {
+		return nil, fmt.Errorf("chain %s: %w", b.opts.Rules.ChainName, iptables.ErrChainMissing)
+	}
+	return status.Discrepancies, nil
+}
+
+// Teardown removes the jumps, then reports ErrNoOperation: deleting the chain
+// is not an operation the helper offers.
+func (b *helperBackend) Teardown(ctx context.Context) error {
+	if err := b.RemoveJump(ctx); err != nil {
+		return fmt.Errorf("remove jumps: %w", err)
+	}
+	return fmt.Errorf("delete chain %s: %w", b.opts.Rules.ChainName, ErrNoOperation)
+}
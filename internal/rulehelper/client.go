@@ -0,0 +1,124 @@
+package rulehelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// Client calls the named operations of a helper listening on a unix socket.
+// It is also an iptables.Executor, so the watcher can hold it in place of a
+// real one: chain checks go through the verify operation and every raw
+// command is refused with ErrNoOperation. Rule changes reach the helper
+// through the backend registered as BackendName.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a Client for the helper socket at path.
+func NewClient(path string) *Client {
+	dialer := &net.Dialer{}
+	return &Client{
+		socket: path,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		}},
+	}
+}
+
+// AddJump installs the jumps described by req.
+func (c *Client) AddJump(ctx context.Context, req JumpRequest) error {
+	return c.call(ctx, addJumpPath, req, nil)
+}
+
+// RemoveJump removes the jumps described by req.
+func (c *Client) RemoveJump(ctx context.Context, req JumpRequest) error {
+	return c.call(ctx, removeJumpPath, req, nil)
+}
+
+// VerifyChain reports whether req.Chain exists and how it differs from
+// req.Mappings.
+func (c *Client) VerifyChain(ctx context.Context, req ChainRequest) (ChainStatus, error) {
+	var status ChainStatus
+	err := c.call(ctx, verifyChainPath, req, &status)
+	return status, err
+}
+
+// ApplyChain rebuilds req.Chain from req.Mappings, keeping any jump to it
+// from req.Hooks.
+func (c *Client) ApplyChain(ctx context.Context, req ChainRequest) error {
+	return c.call(ctx, applyChainPath, req, nil)
+}
+
+func (c *Client) call(ctx context.Context, path string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	// The host is ignored; requests always go to the socket.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://rule-helper"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("call rule helper at %s: %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxRequestBytes))
+		var failure errorResponse
+		if json.Unmarshal(msg, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("rule helper returned %s: %s", resp.Status, failure.Error)
+		}
+		return fmt.Errorf("rule helper returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode rule helper response: %w", err)
+	}
+	return nil
+}
+
+// Run refuses command: the helper does not run raw commands.
+func (c *Client) Run(_ context.Context, command string, args ...string) error {
+	return iptables.NewCommandError(command, args, "", "", ErrNoOperation)
+}
+
+// Output refuses command: the helper does not run raw commands.
+func (c *Client) Output(_ context.Context, command string, args ...string) (string, error) {
+	return "", iptables.NewCommandError(command, args, "", "", ErrNoOperation)
+}
+
+// ChainExists determines whether the IPv4 nat chain is present. Only the nat
+// table is managed through the helper.
+func (c *Client) ChainExists(ctx context.Context, table string, chain string) (bool, error) {
+	if table != natTable {
+		return false, fmt.Errorf("check %s chain %s: %w", table, chain, ErrNoOperation)
+	}
+	status, err := c.VerifyChain(ctx, ChainRequest{Chain: chain})
+	return status.Exists, err
+}
+
+// ChainExists6 determines whether the IPv6 nat chain is present.
+func (c *Client) ChainExists6(ctx context.Context, table string, chain string) (bool, error) {
+	if table != natTable {
+		return false, fmt.Errorf("check %s chain %s: %w", table, chain, ErrNoOperation)
+	}
+	status, err := c.VerifyChain(ctx, ChainRequest{Chain: chain, IPv6: true})
+	return status.Exists6, err
+}
@@ -0,0 +1,62 @@
+// Package rulehelper splits rule changes out of the watcher into a small
+// privileged daemon. The daemon serves a fixed set of named operations to
+// clients connecting over a unix socket and builds every command itself, so
+// the watcher can run with no capabilities and a client can never choose
+// what the daemon executes.
+package rulehelper
+
+import (
+	"errors"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// The operations the helper serves, one endpoint each.
+const (
+	addJumpPath     = "/v1/jump/add"
+	removeJumpPath  = "/v1/jump/remove"
+	verifyChainPath = "/v1/chain/verify"
+	applyChainPath  = "/v1/chain/apply"
+)
+
+// ErrNoOperation is returned, wrapped in a *iptables.CommandError, when a
+// caller asks the Client to run a raw command. The helper only performs the
+// named operations.
+var ErrNoOperation = errors.New("the rule helper only performs named operations")
+
+// JumpRequest names the jumps an add or remove operation manages: the jump to
+// Chain from each of Hooks in the nat table.
+type JumpRequest struct {
+	Chain string   `json:"chain"`
+	Hooks []string `json:"hooks"`
+	// Position places added jumps, in the form iptables.ParseJumpPosition
+	// accepts. Empty means the top of each hook.
+	Position string `json:"position,omitempty"`
+	IPv6     bool   `json:"ipv6,omitempty"`
+}
+
+// ChainRequest describes the nat chain a verify or apply operation works on.
+type ChainRequest struct {
+	Chain string `json:"chain"`
+	// Hooks and Position are only used by apply, to move jumps in Hooks onto
+	// the rebuilt chain.
+	Hooks        []string                   `json:"hooks,omitempty"`
+	Position     string                     `json:"position,omitempty"`
+	IPv6         bool                       `json:"ipv6,omitempty"`
+	ExcludeCIDRs []string                   `json:"excludeCIDRs,omitempty"`
+	Mappings     []discovery.ServiceMapping `json:"mappings,omitempty"`
+}
+
+// ChainStatus is the result of a verify operation. Discrepancies compare the
+// IPv4 chain with the request's mappings and are only set when it exists.
+type ChainStatus struct {
+	Exists        bool                   `json:"exists"`
+	Exists6       bool                   `json:"exists6,omitempty"`
+	Discrepancies []iptables.Discrepancy `json:"discrepancies,omitempty"`
+}
+
+// errorResponse reports why an operation failed.
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,256 @@
+package rulehelper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+type exitCode int
+
+func (e exitCode) Error() string { return "exit status 1" }
+
+func (e exitCode) ExitCode() int { return int(e) }
+
+// fakeExecutor records the commands the server builds and tracks the jumps,
+// keyed "HOOK CHAIN", so jump checks answer consistently.
+type fakeExecutor struct {
+	mu      sync.Mutex
+	calls   []string
+	jumps   map[string]bool
+	chains  map[string]bool
+	listing string
+}
+
+func (f *fakeExecutor) Run(_ context.Context, command string, args ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, command+" "+strings.Join(args, " "))
+	for i, arg := range args[:len(args)-1] {
+		jump := args[i+1] + " " + args[len(args)-1]
+		switch arg {
+		case "-C":
+			if !f.jumps[jump] {
+				return &iptables.CommandError{Command: command, Args: args, Err: exitCode(1)}
+			}
+		case "-I":
+			f.jumps[jump] = true
+		case "-D":
+			delete(f.jumps, jump)
+		}
+	}
+	return nil
+}
+
+func (f *fakeExecutor) Output(context.Context, string, ...string) (string, error) {
+	return f.listing, nil
+}
+
+func (f *fakeExecutor) ChainExists(_ context.Context, _ string, chain string) (bool, error) {
+	return f.chains[chain], nil
+}
+
+func (f *fakeExecutor) ChainExists6(_ context.Context, _ string, chain string) (bool, error) {
+	return f.chains[chain], nil
+}
+
+func (f *fakeExecutor) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func startServer(t *testing.T, executor iptables.Executor) *Client {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "helper.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &Server{executor: executor, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ListenAndServe(ctx, socket)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("ListenAndServe returned error: %v", err)
+		}
+	})
+
+	client := NewClient(socket)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := client.VerifyChain(context.Background(), ChainRequest{Chain: "PROBE"}); err == nil || time.Now().After(deadline) {
+			return client
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJumpOperations(t *testing.T) {
+	exec := &fakeExecutor{jumps: map[string]bool{}}
+	client := startServer(t, exec)
+	ctx := context.Background()
+
+	req := JumpRequest{Chain: "CANARY_DNAT", Hooks: []string{"OUTPUT", "PREROUTING"}}
+	if err := client.AddJump(ctx, req); err != nil {
+		t.Fatalf("AddJump returned error: %v", err)
+	}
+	if err := client.RemoveJump(ctx, req); err != nil {
+		t.Fatalf("RemoveJump returned error: %v", err)
+	}
+
+	calls := exec.recorded()
+	for _, want := range []string{
+		"iptables -w 5 -t nat -I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT",
+		"iptables -w 5 -t nat -I PREROUTING 1 -m comment --comment ghostwire -j CANARY_DNAT",
+		"iptables -w 5 -t nat -D OUTPUT -m comment --comment ghostwire -j CANARY_DNAT",
+	} {
+		if !slices.Contains(calls, want) {
+			t.Errorf("expected %q among %q", want, calls)
+		}
+	}
+}
+
+func TestApplyChainStagesAndKeepsJumps(t *testing.T) {
+	exec := &fakeExecutor{jumps: map[string]bool{"OUTPUT CANARY_DNAT": true}}
+	client := startServer(t, exec)
+
+	err := client.ApplyChain(context.Background(), ChainRequest{
+		Chain:        "CANARY_DNAT",
+		Hooks:        []string{"OUTPUT"},
+		ExcludeCIDRs: []string{"10.96.0.0/12"},
+		Mappings: []discovery.ServiceMapping{
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChain returned error: %v", err)
+	}
+
+	calls := exec.recorded()
+	index := func(fragment string) int {
+		return slices.IndexFunc(calls, func(call string) bool { return strings.Contains(call, fragment) })
+	}
+	dnat := index("-A CANARY_DNAT_NEXT -d 10.0.0.1")
+	stagingJump := index("-I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT_NEXT")
+	liveJump := index("-D OUTPUT -m comment --comment ghostwire -j CANARY_DNAT")
+	promote := index("-E CANARY_DNAT_NEXT CANARY_DNAT")
+	if dnat < 0 || stagingJump < 0 || liveJump < 0 || promote < 0 {
+		t.Fatalf("expected staging rules, a moved jump and a promotion, got %q", calls)
+	}
+	if dnat > stagingJump || stagingJump > liveJump || liveJump > promote {
+		t.Fatalf("expected the staging chain built and jumped to before promotion, got %q", calls)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	exec := &fakeExecutor{
+		chains:  map[string]bool{"CANARY_DNAT": true},
+		listing: "-N CANARY_DNAT\n",
+	}
+	client := startServer(t, exec)
+	ctx := context.Background()
+
+	exists, err := client.ChainExists(ctx, "nat", "CANARY_DNAT")
+	if err != nil || !exists {
+		t.Fatalf("ChainExists = %v, %v; want true", exists, err)
+	}
+	status, err := client.VerifyChain(ctx, ChainRequest{
+		Chain: "CANARY_DNAT",
+		Mappings: []discovery.ServiceMapping{
+			{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("VerifyChain returned error: %v", err)
+	}
+	if len(status.Discrepancies) != 1 || status.Discrepancies[0].Kind != iptables.DiscrepancyMissing {
+		t.Fatalf("expected the mapping reported missing, got %+v", status.Discrepancies)
+	}
+}
+
+func TestServerRefusesInvalidRequests(t *testing.T) {
+	exec := &fakeExecutor{jumps: map[string]bool{}}
+	client := startServer(t, exec)
+	ctx := context.Background()
+	before := len(exec.recorded())
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"chain with shell metacharacters", func() error {
+			return client.AddJump(ctx, JumpRequest{Chain: "X;reboot", Hooks: []string{"OUTPUT"}})
+		}},
+		{"no hooks", func() error {
+			return client.RemoveJump(ctx, JumpRequest{Chain: "CANARY_DNAT"})
+		}},
+		{"hook that is an option", func() error {
+			return client.AddJump(ctx, JumpRequest{Chain: "CANARY_DNAT", Hooks: []string{"--flush"}})
+		}},
+		{"bad position", func() error {
+			return client.AddJump(ctx, JumpRequest{Chain: "CANARY_DNAT", Hooks: []string{"OUTPUT"}, Position: "after:$(id)"})
+		}},
+		{"bad exclusion", func() error {
+			return client.ApplyChain(ctx, ChainRequest{Chain: "CANARY_DNAT", ExcludeCIDRs: []string{"0/0 -j ACCEPT"}})
+		}},
+		{"bad mapping address", func() error {
+			return client.ApplyChain(ctx, ChainRequest{Chain: "CANARY_DNAT", Mappings: []discovery.ServiceMapping{
+				{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.1", PreviewClusterIP: "10.0.1.1 --to-destination 1.2.3.4"},
+			}})
+		}},
+	}
+	for _, tc := range tests {
+		if err := tc.call(); err == nil || !strings.Contains(err.Error(), "400") {
+			t.Errorf("%s: error = %v, want 400 Bad Request", tc.name, err)
+		}
+	}
+	if calls := exec.recorded(); len(calls) != before {
+		t.Fatalf("refused requests ran commands: %q", calls[before:])
+	}
+}
+
+func TestClientRefusesRawCommands(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	err := client.Run(context.Background(), "iptables", "-t", "nat", "-F")
+	if !errors.Is(err, ErrNoOperation) {
+		t.Fatalf("Run error = %v, want ErrNoOperation", err)
+	}
+	if _, err := client.Output(context.Background(), "iptables", "-S"); !errors.Is(err, ErrNoOperation) {
+		t.Fatalf("Output error = %v, want ErrNoOperation", err)
+	}
+}
+
+func TestServerExecutorRejectsBadArgv(t *testing.T) {
+	t.Parallel()
+
+	// Named operations never build such commands; this checks that the
+	// server's executor would refuse them anyway.
+	executor := NewServer(nil).executor
+	ctx := context.Background()
+	for _, argv := range [][]string{
+		{"iptables", "-t", "nat", "-N", "X;reboot"},
+		{"ipset", "add", "gw-exclude", "10.0.0.0/8`id`"},
+		{"sh", "-c", "id"},
+	} {
+		if err := executor.Run(ctx, argv[0], argv[1:]...); !errors.Is(err, iptables.ErrCommandRejected) {
+			t.Errorf("Run(%q) = %v, want ErrCommandRejected", argv, err)
+		}
+	}
+}
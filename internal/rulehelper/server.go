@@ -0,0 +1,297 @@
+package rulehelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/denniswebb/ghostwire/internal/backend"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+const (
+	maxRequestBytes = 1 << 20
+	natTable        = "nat"
+)
+
+// errInvalidRequest marks requests refused before anything runs.
+var errInvalidRequest = errors.New("invalid request")
+
+// Server performs the named rule operations for clients on a unix socket.
+// Every command it runs is built here and goes through an iptables executor,
+// which checks it against the binary allowlist and argument rules.
+type Server struct {
+	executor iptables.Executor
+	logger   *slog.Logger
+}
+
+// NewServer returns a Server running commands through iptables.NewExecutor.
+func NewServer(logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{executor: iptables.NewExecutor(), logger: logger}
+}
+
+// ListenAndServe serves on a unix socket at path until ctx is cancelled. A
+// stale socket left by a previous run is replaced. The socket is created with
+// mode 0660 so only the owner and group can connect.
+func (s *Server) ListenAndServe(ctx context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("restrict socket %s: %w", path, err)
+	}
+
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(listener)
+	}()
+	s.logger.Info("rule helper listening", slog.String("socket", path))
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("shutdown rule helper: %w", err)
+		}
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+// Handler routes each operation's endpoint. Every other path is not found.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(addJumpPath, s.operation("add jump", func(ctx context.Context, body []byte) (any, error) {
+		var req JumpRequest
+		if err := decode(body, &req, func() error { return validateJumpRequest(req) }); err != nil {
+			return nil, err
+		}
+		rules, err := s.rules(req.Chain, req.Hooks, req.Position, iptables.Config{IPv6: req.IPv6})
+		if err != nil {
+			return nil, err
+		}
+		return nil, rules.AddJump(ctx)
+	}))
+	mux.Handle(removeJumpPath, s.operation("remove jump", func(ctx context.Context, body []byte) (any, error) {
+		var req JumpRequest
+		if err := decode(body, &req, func() error { return validateJumpRequest(req) }); err != nil {
+			return nil, err
+		}
+		rules, err := s.rules(req.Chain, req.Hooks, req.Position, iptables.Config{IPv6: req.IPv6})
+		if err != nil {
+			return nil, err
+		}
+		return nil, rules.RemoveJump(ctx)
+	}))
+	mux.Handle(verifyChainPath, s.operation("verify chain", func(ctx context.Context, body []byte) (any, error) {
+		var req ChainRequest
+		if err := decode(body, &req, func() error { return validateChainRequest(req) }); err != nil {
+			return nil, err
+		}
+		return s.verifyChain(ctx, req)
+	}))
+	mux.Handle(applyChainPath, s.operation("apply chain", func(ctx context.Context, body []byte) (any, error) {
+		var req ChainRequest
+		if err := decode(body, &req, func() error { return validateChainRequest(req) }); err != nil {
+			return nil, err
+		}
+		return nil, s.applyChain(ctx, req)
+	}))
+	return mux
+}
+
+// operation wraps one named operation: it only accepts POST, limits the
+// body, and reports the result as JSON. Refused requests get 400 and failed
+// operations 500, each with an errorResponse.
+func (s *Server) operation(name string, run func(ctx context.Context, body []byte) (any, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+		if err != nil {
+			http.Error(w, "read request body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := run(r.Context(), body)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errInvalidRequest) {
+				status = http.StatusBadRequest
+				s.logger.Warn("rule helper refused request", slog.String("operation", name), slog.Any("error", err))
+			} else {
+				s.logger.Error("rule helper operation failed", slog.String("operation", name), slog.Any("error", err))
+			}
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		}
+		s.logger.Debug("rule helper operation done", slog.String("operation", name))
+		if result == nil {
+			result = struct{}{}
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// decode unmarshals body into req and runs validate on the result. Either
+// failure refuses the request.
+func decode(body []byte, req any, validate func() error) error {
+	if err := json.Unmarshal(body, req); err != nil {
+		return fmt.Errorf("%w: decode body: %v", errInvalidRequest, err)
+	}
+	if err := validate(); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	return nil
+}
+
+// rules returns the iptables backend for chain, jumped to from hooks.
+func (s *Server) rules(chain string, hooks []string, position string, cfg iptables.Config) (backend.Backend, error) {
+	pos, err := iptables.ParseJumpPosition(position)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ChainName = chain
+	return backend.New(backend.DefaultName, backend.Options{
+		Executor: s.executor,
+		Rules:    cfg,
+		Hooks:    hooks,
+		Position: pos,
+		Logger:   s.logger,
+	})
+}
+
+func (s *Server) verifyChain(ctx context.Context, req ChainRequest) (ChainStatus, error) {
+	var status ChainStatus
+	exists, err := s.executor.ChainExists(ctx, natTable, req.Chain)
+	if err != nil {
+		return status, fmt.Errorf("check chain %s: %w", req.Chain, err)
+	}
+	status.Exists = exists
+	if req.IPv6 {
+		exists6, err := s.executor.ChainExists6(ctx, natTable, req.Chain)
+		if err != nil {
+			return status, fmt.Errorf("check ipv6 chain %s: %w", req.Chain, err)
+		}
+		status.Exists6 = exists6
+	}
+	if !exists {
+		return status, nil
+	}
+
+	rules, err := s.rules(req.Chain, nil, "", iptables.Config{IPv6: req.IPv6})
+	if err != nil {
+		return status, err
+	}
+	status.Discrepancies, err = rules.Verify(ctx, req.Mappings)
+	if err != nil {
+		return status, fmt.Errorf("verify chain %s: %w", req.Chain, err)
+	}
+	return status, nil
+}
+
+// applyChain rebuilds req.Chain from the request's mappings. The rules go
+// into a staging chain first; jumps from req.Hooks to the live chain are moved
+// onto it, and it is then promoted, so traffic never meets a half-built
+// chain.
+func (s *Server) applyChain(ctx context.Context, req ChainRequest) error {
+	staging := iptables.StagingChainName(req.Chain)
+	cfg := iptables.Config{IPv6: req.IPv6, ExcludeCIDRs: req.ExcludeCIDRs}
+	stagingRules, err := s.rules(staging, req.Hooks, req.Position, cfg)
+	if err != nil {
+		return err
+	}
+	if err := stagingRules.ApplyMappings(ctx, req.Mappings); err != nil {
+		return fmt.Errorf("build staging chain %s: %w", staging, err)
+	}
+
+	var jumped []string
+	for _, hook := range req.Hooks {
+		present, err := iptables.JumpExists(ctx, s.executor, natTable, hook, req.Chain)
+		if err != nil {
+			return err
+		}
+		if present {
+			jumped = append(jumped, hook)
+		}
+	}
+	if len(jumped) > 0 {
+		staged, err := s.rules(staging, jumped, req.Position, cfg)
+		if err != nil {
+			return err
+		}
+		if err := staged.AddJump(ctx); err != nil {
+			return fmt.Errorf("add staging jump: %w", err)
+		}
+		live, err := s.rules(req.Chain, jumped, req.Position, cfg)
+		if err != nil {
+			return err
+		}
+		if err := live.RemoveJump(ctx); err != nil {
+			return fmt.Errorf("remove previous jump: %w", err)
+		}
+	}
+	return iptables.PromoteChain(ctx, s.executor, natTable, req.Chain, staging, req.IPv6, s.logger)
+}
+
+func validateJumpRequest(req JumpRequest) error {
+	if len(req.Hooks) == 0 {
+		return errors.New("no hooks given")
+	}
+	return validateNames(req.Chain, req.Hooks, req.Position)
+}
+
+func validateChainRequest(req ChainRequest) error {
+	if err := validateNames(req.Chain, req.Hooks, req.Position); err != nil {
+		return err
+	}
+	for _, cidr := range req.ExcludeCIDRs {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+			return fmt.Errorf("exclusion %q: %w", cidr, err)
+		}
+	}
+	for _, mapping := range req.Mappings {
+		if net.ParseIP(mapping.ActiveClusterIP) == nil || net.ParseIP(mapping.PreviewClusterIP) == nil {
+			return fmt.Errorf("mapping for %s has an invalid address", mapping.ServiceName)
+		}
+	}
+	return nil
+}
+
+func validateNames(chain string, hooks []string, position string) error {
+	if err := iptables.ValidateChainName(chain); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := iptables.ValidateHookName(hook); err != nil {
+			return err
+		}
+	}
+	_, err := iptables.ParseJumpPosition(position)
+	return err
+}
@@ -0,0 +1,196 @@
+// Package schedule parses cron-style windows and reports whether a moment
+// falls inside one.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded zone data, since minimal images ship no /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// field is one cron field: its bounds, optional names, and the values set.
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField    = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// expression is one parsed cron expression. Each set holds a bit per value.
+type expression struct {
+	minutes, hours, doms, months, dows uint64
+	domAny, dowAny                     bool
+}
+
+// Schedule is a set of cron expressions evaluated in one time zone. A minute
+// is inside the window when any expression matches it, so
+// "* 9-16 * * mon-fri" opens the window for business hours on weekdays.
+type Schedule struct {
+	expressions []expression
+	location    *time.Location
+	source      string
+}
+
+// Parse reads semicolon-separated five-field cron expressions (minute, hour,
+// day of month, month, day of week) evaluated in the named IANA time zone;
+// an empty zone means UTC. Fields accept *, values, names (jan, mon), ranges,
+// lists and /steps. As in cron, when both day fields are restricted a day
+// matching either is inside the window.
+func Parse(raw string, zone string) (*Schedule, error) {
+	location := time.UTC
+	if zone = strings.TrimSpace(zone); zone != "" {
+		var err error
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("load time zone %q: %w", zone, err)
+		}
+	}
+
+	schedule := &Schedule{location: location, source: strings.TrimSpace(raw)}
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		expr, err := parseExpression(part)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", part, err)
+		}
+		schedule.expressions = append(schedule.expressions, expr)
+	}
+	if len(schedule.expressions) == 0 {
+		return nil, fmt.Errorf("schedule %q holds no cron expression", raw)
+	}
+	return schedule, nil
+}
+
+func parseExpression(raw string) (expression, error) {
+	fields := strings.Fields(raw)
+	if len(fields) != 5 {
+		return expression{}, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+	var expr expression
+	var err error
+	if expr.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return expression{}, err
+	}
+	if expr.hours, err = hourField.parse(fields[1]); err != nil {
+		return expression{}, err
+	}
+	if expr.doms, err = domField.parse(fields[2]); err != nil {
+		return expression{}, err
+	}
+	if expr.months, err = monthField.parse(fields[3]); err != nil {
+		return expression{}, err
+	}
+	if expr.dows, err = dowField.parse(fields[4]); err != nil {
+		return expression{}, err
+	}
+	// 7 is another name for Sunday.
+	if expr.dows&(1<<7) != 0 {
+		expr.dows |= 1
+	}
+	expr.domAny = fields[2] == "*"
+	expr.dowAny = fields[4] == "*"
+	return expr, nil
+}
+
+// parse returns the bit set of the values a field selects.
+func (f field) parse(raw string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(raw, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepPart)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowPart); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highPart); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s: range %q runs backwards", f.name, rangePart)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value reads one number or name of the field.
+func (f field) value(raw string) (int, error) {
+	lower := strings.ToLower(raw)
+	for i, name := range f.names {
+		if lower == name {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %q is not between %d and %d", f.name, raw, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Active reports whether t falls inside the window.
+func (s *Schedule) Active(t time.Time) bool {
+	t = t.In(s.location)
+	for _, expr := range s.expressions {
+		if expr.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e expression) matches(t time.Time) bool {
+	if e.minutes&(1<<t.Minute()) == 0 || e.hours&(1<<t.Hour()) == 0 || e.months&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domMatch := e.doms&(1<<t.Day()) != 0
+	dowMatch := e.dows&(1<<int(t.Weekday())) != 0
+	if e.domAny || e.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// String returns the expressions as given.
+func (s *Schedule) String() string {
+	return s.source
+}
+
+// Location returns the time zone the expressions are evaluated in.
+func (s *Schedule) Location() *time.Location {
+	return s.location
+}
@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		expr   string
+		zone   string
+		at     string
+		active bool
+	}{
+		{name: "business hours inside", expr: "* 9-16 * * mon-fri", at: "2026-10-14T09:00:00Z", active: true},
+		{name: "business hours last minute", expr: "* 9-16 * * mon-fri", at: "2026-10-14T16:59:00Z", active: true},
+		{name: "business hours after close", expr: "* 9-16 * * mon-fri", at: "2026-10-14T17:00:00Z"},
+		{name: "business hours weekend", expr: "* 9-16 * * mon-fri", at: "2026-10-17T10:00:00Z"},
+		{name: "time zone shifts the window", expr: "* 9-16 * * 1-5", zone: "America/New_York", at: "2026-10-14T14:00:00Z", active: true},
+		{name: "time zone before opening", expr: "* 9-16 * * 1-5", zone: "America/New_York", at: "2026-10-14T12:59:00Z"},
+		{name: "sunday as seven", expr: "* * * * 7", at: "2026-10-18T03:00:00Z", active: true},
+		{name: "steps", expr: "*/15 * * * *", at: "2026-10-14T10:30:00Z", active: true},
+		{name: "steps miss", expr: "*/15 * * * *", at: "2026-10-14T10:31:00Z"},
+		{name: "either day field", expr: "* * 1 * mon", at: "2026-10-19T10:00:00Z", active: true},
+		{name: "second expression", expr: "* 9 * * *; * 22-23 * dec *", at: "2026-12-01T23:10:00Z", active: true},
+		{name: "month names", expr: "* * * jan-mar *", at: "2026-10-14T10:00:00Z"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := Parse(tc.expr, tc.zone)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			at, err := time.Parse(time.RFC3339, tc.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Active(at); got != tc.active {
+				t.Fatalf("Active(%s) = %t, want %t", tc.at, got, tc.active)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidSchedules(t *testing.T) {
+	t.Parallel()
+
+	for expr, want := range map[string]string{
+		"":                 "holds no cron expression",
+		"* 9-17 * *":       "expected 5 fields",
+		"* 25 * * *":       `hour: "25" is not between 0 and 23`,
+		"* 17-9 * * *":     "runs backwards",
+		"*/0 * * * *":      "invalid step",
+		"* * * * funday":   "day of week",
+		"* * * smarch * *": "expected 5 fields",
+	} {
+		if _, err := Parse(expr, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Parse(%q) = %v, want error containing %q", expr, err, want)
+		}
+	}
+	if _, err := Parse("* * * * *", "Mars/Olympus"); err == nil {
+		t.Fatal("expected an unknown time zone to be rejected")
+	}
+}
@@ -0,0 +1,46 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// NetnsExecutor runs commands inside a named network namespace through
+// `ip netns exec`.
+type NetnsExecutor struct {
+	inner     iptables.Executor
+	namespace string
+}
+
+// NewNetnsExecutor wraps inner so every command runs in namespace.
+func NewNetnsExecutor(inner iptables.Executor, namespace string) *NetnsExecutor {
+	return &NetnsExecutor{inner: inner, namespace: namespace}
+}
+
+// Run executes command inside the namespace.
+func (e *NetnsExecutor) Run(ctx context.Context, command string, args ...string) error {
+	return e.inner.Run(ctx, "ip", append([]string{"netns", "exec", e.namespace, command}, args...)...)
+}
+
+// ChainExists lists the IPv4 chain inside the namespace.
+func (e *NetnsExecutor) ChainExists(ctx context.Context, table string, chain string) (bool, error) {
+	return e.chainExists(ctx, "iptables", table, chain)
+}
+
+// ChainExists6 lists the IPv6 chain inside the namespace.
+func (e *NetnsExecutor) ChainExists6(ctx context.Context, table string, chain string) (bool, error) {
+	return e.chainExists(ctx, "ip6tables", table, chain)
+}
+
+func (e *NetnsExecutor) chainExists(ctx context.Context, binary, table, chain string) (bool, error) {
+	err := e.Run(ctx, binary, "-w", "5", "-t", table, "-L", chain)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, iptables.ErrChainMissing) {
+		return false, nil
+	}
+	return false, err
+}
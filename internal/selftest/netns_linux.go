@@ -0,0 +1,36 @@
+//go:build linux
+
+package selftest
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// inNetns runs fn on an OS thread switched into the named namespace. Sockets
+// created by fn stay in that namespace. The thread is discarded afterwards
+// rather than returned to the scheduler with a foreign namespace.
+func inNetns(name string, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		// Deliberately no UnlockOSThread: the goroutine exiting while locked
+		// terminates the thread.
+
+		f, err := os.Open("/var/run/netns/" + name)
+		if err != nil {
+			errCh <- fmt.Errorf("open namespace %s: %w", name, err)
+			return
+		}
+		defer f.Close()
+		if err := unix.Setns(int(f.Fd()), unix.CLONE_NEWNET); err != nil {
+			errCh <- fmt.Errorf("enter namespace %s: %w", name, err)
+			return
+		}
+		errCh <- fn()
+	}()
+	return <-errCh
+}
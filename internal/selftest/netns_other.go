@@ -0,0 +1,7 @@
+//go:build !linux
+
+package selftest
+
+func inNetns(string, func() error) error {
+	return errUnsupported
+}
@@ -0,0 +1,56 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/pkg/iptablestest"
+)
+
+func TestNetnsExecutor(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := iptablestest.New()
+	exec := NewNetnsExecutor(fake, "gw-selftest-1")
+
+	if err := exec.Run(ctx, "iptables", "-t", "nat", "-N", "GW_SELFTEST"); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	fake.AssertCalled(t, "ip", "netns", "exec", "gw-selftest-1", "iptables", "-t", "nat", "-N", "GW_SELFTEST")
+
+	exists, err := exec.ChainExists(ctx, "nat", "GW_SELFTEST")
+	if err != nil || !exists {
+		t.Fatalf("expected chain to exist, got %v, %v", exists, err)
+	}
+
+	fake.FailOn(iptablestest.Exit(iptablestest.ExitRuleMissing), "ip", "netns", "exec", "gw-selftest-1", "ip6tables", "-w", "5", "-t", "nat", "-L", "GW_SELFTEST")
+	exists, err = exec.ChainExists6(ctx, "nat", "GW_SELFTEST")
+	if err != nil || exists {
+		t.Fatalf("expected missing ipv6 chain, got %v, %v", exists, err)
+	}
+
+	boom := errors.New("boom")
+	fake.FailOn(boom, "ip", "netns", "exec", "gw-selftest-1", "iptables", "-w", "5", "-t", "raw", "-L", "GW_SELFTEST")
+	if _, err := exec.ChainExists(ctx, "raw", "GW_SELFTEST"); !errors.Is(err, boom) {
+		t.Fatalf("expected other failures to surface, got %v", err)
+	}
+}
+
+func TestReportPassed(t *testing.T) {
+	t.Parallel()
+
+	var empty Report
+	if empty.Passed() {
+		t.Fatalf("expected empty report not to pass")
+	}
+	report := Report{Checks: []Check{{Name: "a", OK: true}}}
+	if !report.Passed() {
+		t.Fatalf("expected all-ok report to pass")
+	}
+	report.add("b", errors.New("broken"), "")
+	if report.Passed() || report.Checks[1].Detail != "broken" {
+		t.Fatalf("expected failure recorded, got %+v", report.Checks)
+	}
+}
@@ -0,0 +1,211 @@
+// Package selftest verifies on a node that ghostwire's rules redirect traffic,
+// by installing a representative rule set in a scratch network namespace and
+// sending real connections through it.
+package selftest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// Addresses used inside the scratch namespace, from TEST-NET-1.
+const (
+	activeIP    = "192.0.2.1"
+	previewIP   = "192.0.2.2"
+	excludedIP  = "192.0.2.3"
+	testPort    = 18080
+	testChain   = "GW_SELFTEST"
+	dialTimeout = 2 * time.Second
+)
+
+// Check is the outcome of one step.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report lists the checks in the order they ran.
+type Report struct {
+	Namespace string  `json:"namespace"`
+	Checks    []Check `json:"checks"`
+}
+
+// Passed reports whether every check succeeded.
+func (r Report) Passed() bool {
+	if len(r.Checks) == 0 {
+		return false
+	}
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) add(name string, err error, detail string) bool {
+	check := Check{Name: name, OK: err == nil, Detail: detail}
+	if err != nil {
+		check.Detail = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	return err == nil
+}
+
+// Run creates a scratch network namespace, installs a chain with an
+// exclusion, two DNAT rules and the jump, and checks that connections are
+// redirected, that excluded destinations are not, and that removing the jump
+// restores direct routing. The namespace, and every rule in it, is deleted
+// before Run returns. It needs root (NET_ADMIN and SYS_ADMIN) and the ip and
+// iptables binaries.
+func Run(ctx context.Context, executor iptables.Executor, ipv6 bool, logger *slog.Logger) (Report, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return Report{}, fmt.Errorf("generate namespace name: %w", err)
+	}
+	ns := "gw-selftest-" + hex.EncodeToString(suffix)
+	report := Report{Namespace: ns}
+
+	if !report.add("create network namespace", executor.Run(ctx, "ip", "netns", "add", ns), ns) {
+		return report, nil
+	}
+	defer func() {
+		if err := executor.Run(context.Background(), "ip", "netns", "del", ns); err != nil {
+			logger.Warn("failed to delete selftest namespace", slog.String("namespace", ns), slog.Any("error", err))
+		}
+	}()
+
+	nsExec := NewNetnsExecutor(executor, ns)
+	if !report.add("configure interfaces", configureInterfaces(ctx, nsExec), fmt.Sprintf("%s, %s, %s on lo", activeIP, previewIP, excludedIP)) {
+		return report, nil
+	}
+
+	listeners, err := listen(ns, []string{activeIP, previewIP, excludedIP})
+	if !report.add("start listeners", err, fmt.Sprintf("port %d", testPort)) {
+		return report, nil
+	}
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+
+	mappings := []discovery.ServiceMapping{
+		{ServiceName: "selftest", Port: testPort, Protocol: corev1.ProtocolTCP, ActiveClusterIP: activeIP, PreviewClusterIP: previewIP},
+		{ServiceName: "selftest-excluded", Port: testPort, Protocol: corev1.ProtocolTCP, ActiveClusterIP: excludedIP, PreviewClusterIP: previewIP},
+	}
+	cfg := iptables.Config{
+		ChainName:    testChain,
+		ExcludeCIDRs: []string{excludedIP + "/32"},
+		IPv6:         ipv6,
+	}
+	if !report.add("install chain, exclusion and dnat rules", iptables.SetupWithExecutor(ctx, nsExec, cfg, mappings, logger), testChain) {
+		return report, nil
+	}
+	if !report.add("install jump", iptables.AddJump(ctx, nsExec, "nat", "OUTPUT", testChain, ipv6, logger), "OUTPUT -> "+testChain) {
+		return report, nil
+	}
+
+	report.add("redirect active to preview", expect(ns, activeIP, previewIP), fmt.Sprintf("%s:%d answered by %s", activeIP, testPort, previewIP))
+	report.add("excluded destination bypasses dnat", expect(ns, excludedIP, excludedIP), fmt.Sprintf("%s:%d answered by itself", excludedIP, testPort))
+
+	if !report.add("remove jump", iptables.RemoveJump(ctx, nsExec, "nat", "OUTPUT", testChain, ipv6, logger), "") {
+		return report, nil
+	}
+	report.add("direct routing after jump removal", expect(ns, activeIP, activeIP), fmt.Sprintf("%s:%d answered by itself", activeIP, testPort))
+
+	return report, nil
+}
+
+func configureInterfaces(ctx context.Context, executor iptables.Executor) error {
+	steps := [][]string{
+		{"link", "set", "lo", "up"},
+		{"addr", "add", activeIP + "/32", "dev", "lo"},
+		{"addr", "add", previewIP + "/32", "dev", "lo"},
+		{"addr", "add", excludedIP + "/32", "dev", "lo"},
+	}
+	for _, step := range steps {
+		if err := executor.Run(ctx, "ip", step...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen starts a server on each address inside ns that answers with the
+// address it was reached on.
+func listen(ns string, ips []string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, ip := range ips {
+		var l net.Listener
+		err := inNetns(ns, func() error {
+			var err error
+			l, err = net.Listen("tcp", net.JoinHostPort(ip, fmt.Sprint(testPort)))
+			return err
+		})
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("listen on %s: %w", ip, err)
+		}
+		listeners = append(listeners, l)
+		go serve(l, ip)
+	}
+	return listeners, nil
+}
+
+func serve(l net.Listener, ip string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.WriteString(conn, ip)
+		_ = conn.Close()
+	}
+}
+
+// expect dials target inside ns and checks which listener answered.
+func expect(ns, target, want string) error {
+	var got string
+	err := inNetns(ns, func() error {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(target, fmt.Sprint(testPort)), dialTimeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(dialTimeout))
+		body, err := io.ReadAll(conn)
+		if err != nil {
+			return err
+		}
+		got = strings.TrimSpace(string(body))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", target, err)
+	}
+	if got != want {
+		return fmt.Errorf("connection to %s answered by %s, want %s", target, got, want)
+	}
+	return nil
+}
+
+// errUnsupported is returned by inNetns on platforms without network
+// namespaces.
+var errUnsupported = errors.New("network namespaces are only supported on linux")
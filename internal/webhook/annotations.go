@@ -0,0 +1,211 @@
+// Package webhook implements ghostwire's admission endpoints. The validating
+// handler rejects workloads and ghostwire resources whose settings would
+// otherwise only fail once the init container runs.
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/k8s"
+)
+
+// AnnotationPrefix namespaces every ghostwire annotation.
+const AnnotationPrefix = "ghostwire.dev/"
+
+// Workload annotations understood by the injector.
+const (
+	AnnotationEnabled           = AnnotationPrefix + "enabled"
+	AnnotationRoleLabelKey      = AnnotationPrefix + "roleLabelKey"
+	AnnotationRoleActive        = AnnotationPrefix + "roleActive"
+	AnnotationRolePreview       = AnnotationPrefix + "rolePreview"
+	AnnotationSvcPreviewPattern = AnnotationPrefix + "svcPreviewPattern"
+	AnnotationNamespace         = AnnotationPrefix + "namespace"
+	AnnotationDNSSuffix         = AnnotationPrefix + "dnsSuffix"
+	AnnotationJumpHook          = AnnotationPrefix + "jumpHook"
+	AnnotationExcludeCIDRs      = AnnotationPrefix + "excludeCidrs"
+	AnnotationExcludeCgroups    = AnnotationPrefix + "excludeCgroups"
+	AnnotationPollInterval      = AnnotationPrefix + "pollInterval"
+	AnnotationRefreshInterval   = AnnotationPrefix + "refreshInterval"
+	AnnotationIPv6              = AnnotationPrefix + "ipv6"
+)
+
+type annotationCheck func(value string) error
+
+var annotationChecks = map[string]annotationCheck{
+	AnnotationEnabled:           checkBool,
+	AnnotationRoleLabelKey:      checkLabelKey,
+	AnnotationRoleActive:        checkLabelValue,
+	AnnotationRolePreview:       checkLabelValue,
+	AnnotationSvcPreviewPattern: checkPreviewPattern,
+	AnnotationNamespace:         checkNamespace,
+	AnnotationDNSSuffix:         checkDNSSuffix,
+	AnnotationJumpHook:          checkJumpHook,
+	AnnotationExcludeCIDRs:      checkCIDRList,
+	AnnotationExcludeCgroups:    checkCgroupList,
+	AnnotationPollInterval:      checkDuration,
+	AnnotationRefreshInterval:   checkDuration,
+	AnnotationIPv6:              checkBool,
+}
+
+func init() {
+	// Status annotations are written by the watcher, never by users; accept
+	// them unconditionally so watcher patches are not flagged as typos.
+	for _, key := range k8s.StatusAnnotations {
+		annotationChecks[key] = func(string) error { return nil }
+	}
+}
+
+// ValidateAnnotations checks every ghostwire annotation in the map. Errors are
+// returned for values that would break init or the watcher; warnings flag
+// settings that are accepted but almost certainly unintended, such as unknown
+// keys or tuning applied to a workload that opted out.
+func ValidateAnnotations(annotations map[string]string) (warnings []string, errs []string) {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		if strings.HasPrefix(key, AnnotationPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		check, known := annotationChecks[key]
+		if !known {
+			warnings = append(warnings, fmt.Sprintf("unknown annotation %s is ignored by ghostwire", key))
+			continue
+		}
+		if err := check(annotations[key]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	active, hasActive := annotations[AnnotationRoleActive]
+	preview, hasPreview := annotations[AnnotationRolePreview]
+	if hasActive && hasPreview && active == preview {
+		errs = append(errs, fmt.Sprintf("%s and %s must differ", AnnotationRoleActive, AnnotationRolePreview))
+	}
+
+	if enabled, ok := annotations[AnnotationEnabled]; ok {
+		if on, err := strconv.ParseBool(enabled); err == nil && !on && len(keys) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%s is false; other ghostwire annotations have no effect", AnnotationEnabled))
+		}
+	}
+
+	return warnings, errs
+}
+
+func checkBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("%q is not a boolean", value)
+	}
+	return nil
+}
+
+func checkLabelKey(value string) error {
+	if problems := validation.IsQualifiedName(value); len(problems) > 0 {
+		return fmt.Errorf("%q is not a valid label key: %s", value, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkLabelValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if problems := validation.IsValidLabelValue(value); len(problems) > 0 {
+		return fmt.Errorf("%q is not a valid label value: %s", value, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkPreviewPattern(value string) error {
+	rendered, err := discovery.ApplyPattern(value, "svc")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(rendered, "svc") {
+		return fmt.Errorf("pattern %q must reference {{name}}", value)
+	}
+	if rendered == "svc" {
+		return fmt.Errorf("pattern %q maps every service to itself", value)
+	}
+	return nil
+}
+
+func checkNamespace(value string) error {
+	if problems := validation.IsDNS1123Label(value); len(problems) > 0 {
+		return fmt.Errorf("%q is not a valid namespace: %s", value, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkDNSSuffix(value string) error {
+	if !strings.HasPrefix(value, ".") {
+		return fmt.Errorf("%q must start with a dot", value)
+	}
+	if problems := validation.IsDNS1123Subdomain(strings.TrimPrefix(value, ".")); len(problems) > 0 {
+		return fmt.Errorf("%q is not a valid DNS suffix: %s", value, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkJumpHook(value string) error {
+	for _, hook := range strings.Split(value, ",") {
+		switch strings.TrimSpace(hook) {
+		case "OUTPUT", "PREROUTING":
+		default:
+			return fmt.Errorf("%q must be OUTPUT or PREROUTING, or a comma-separated list of both", value)
+		}
+	}
+	return nil
+}
+
+func checkCIDRList(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		trimmed := strings.TrimSpace(part)
+		if trimmed == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(trimmed); err != nil {
+			return fmt.Errorf("invalid cidr %q", trimmed)
+		}
+	}
+	return nil
+}
+
+func checkCgroupList(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		trimmed := strings.TrimSpace(part)
+		if trimmed == "" {
+			continue
+		}
+		if strings.ContainsAny(trimmed, " \t\n") {
+			return fmt.Errorf("cgroup path %q must not contain whitespace", trimmed)
+		}
+		for _, segment := range strings.Split(trimmed, "/") {
+			if segment == ".." {
+				return fmt.Errorf("cgroup path %q must not contain ..", trimmed)
+			}
+		}
+	}
+	return nil
+}
+
+func checkDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", value)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%q must be positive", value)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/denniswebb/ghostwire/internal/apis/v1alpha1"
+)
+
+const maxReviewBytes = 4 << 20
+
+// annotatedObject captures the metadata of any object that may carry ghostwire
+// annotations, including the pod template of workload controllers.
+type annotatedObject struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Template struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		} `json:"template"`
+	} `json:"spec"`
+}
+
+// Validator serves AdmissionReview requests for the validating webhook.
+type Validator struct {
+	logger *slog.Logger
+}
+
+// NewValidator constructs a Validator.
+func NewValidator(logger *slog.Logger) *Validator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Validator{logger: logger}
+}
+
+// ServeHTTP decodes an AdmissionReview, validates the submitted object, and
+// writes the review back with the verdict.
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReviewBytes))
+	if err != nil {
+		http.Error(w, "read request body", http.StatusBadRequest)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "malformed AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	response := v.Review(review.Request)
+	review.Response = response
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&review); err != nil {
+		v.logger.Warn("failed to write admission response", slog.Any("error", err))
+	}
+}
+
+// Review validates a single admission request.
+func (v *Validator) Review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation == admissionv1.Delete {
+		return response
+	}
+
+	warnings, errs, err := validateObject(req.Kind, req.Object.Raw)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	response.Warnings = warnings
+
+	if len(errs) > 0 {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: "ghostwire: " + strings.Join(errs, "; "),
+		}
+		v.logger.Info("rejected object",
+			slog.String("kind", req.Kind.Kind),
+			slog.String("namespace", req.Namespace),
+			slog.String("name", req.Name),
+			slog.String("reason", response.Result.Message),
+		)
+	}
+
+	return response
+}
+
+func validateObject(kind metav1.GroupVersionKind, raw []byte) (warnings []string, errs []string, err error) {
+	if kind.Group == v1alpha1.GroupName {
+		switch kind.Kind {
+		case "GhostwireConfig":
+			var obj v1alpha1.GhostwireConfig
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, nil, fmt.Errorf("decode GhostwireConfig: %w", err)
+			}
+			return nil, splitErrors(obj.Spec.Validate()), nil
+		case "GhostwireMapping":
+			var obj v1alpha1.GhostwireMapping
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, nil, fmt.Errorf("decode GhostwireMapping: %w", err)
+			}
+			return nil, splitErrors(obj.Spec.Validate()), nil
+		}
+	}
+
+	var obj annotatedObject
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, nil, fmt.Errorf("decode %s: %w", kind.Kind, err)
+	}
+
+	warnings, errs = ValidateAnnotations(obj.Annotations)
+	templateWarnings, templateErrs := ValidateAnnotations(obj.Spec.Template.Metadata.Annotations)
+	for _, w := range templateWarnings {
+		warnings = append(warnings, "pod template: "+w)
+	}
+	for _, e := range templateErrs {
+		errs = append(errs, "pod template: "+e)
+	}
+
+	return warnings, errs, nil
+}
+
+func splitErrors(err error) []string {
+	if err == nil {
+		return nil
+	}
+	return strings.Split(err.Error(), "\n")
+}
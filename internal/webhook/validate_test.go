@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     string
+		wantWarning string
+	}{
+		{
+			name: "valid settings",
+			annotations: map[string]string{
+				AnnotationEnabled:           "true",
+				AnnotationRoleLabelKey:      "rollouts-pod-template-hash",
+				AnnotationSvcPreviewPattern: "{{name}}-canary",
+				AnnotationJumpHook:          "PREROUTING",
+				AnnotationExcludeCIDRs:      "169.254.169.254/32, 10.96.0.10/32",
+				AnnotationPollInterval:      "2s",
+				AnnotationDNSSuffix:         ".svc.cluster.local",
+			},
+		},
+		{name: "bad boolean", annotations: map[string]string{AnnotationIPv6: "yes please"}, wantErr: "not a boolean"},
+		{name: "pattern without name", annotations: map[string]string{AnnotationSvcPreviewPattern: "preview"}, wantErr: "must reference {{name}}"},
+		{name: "unparseable pattern", annotations: map[string]string{AnnotationSvcPreviewPattern: "{{name}-preview"}, wantErr: "parse preview pattern"},
+		{name: "bad hook", annotations: map[string]string{AnnotationJumpHook: "INPUT"}, wantErr: "OUTPUT or PREROUTING"},
+		{name: "bad hook in list", annotations: map[string]string{AnnotationJumpHook: "OUTPUT,FORWARD"}, wantErr: "OUTPUT or PREROUTING"},
+		{name: "bad cidr", annotations: map[string]string{AnnotationExcludeCIDRs: "10.0.0.0/33"}, wantErr: "invalid cidr"},
+		{name: "negative duration", annotations: map[string]string{AnnotationPollInterval: "-1s"}, wantErr: "must be positive"},
+		{name: "dns suffix without dot", annotations: map[string]string{AnnotationDNSSuffix: "svc.cluster.local"}, wantErr: "must start with a dot"},
+		{
+			name:        "identical roles",
+			annotations: map[string]string{AnnotationRoleActive: "blue", AnnotationRolePreview: "blue"},
+			wantErr:     "must differ",
+		},
+		{name: "unknown key", annotations: map[string]string{AnnotationPrefix + "enable": "true"}, wantWarning: "unknown annotation"},
+		{
+			name:        "tuning on opted-out workload",
+			annotations: map[string]string{AnnotationEnabled: "false", AnnotationJumpHook: "OUTPUT"},
+			wantWarning: "no effect",
+		},
+		{name: "foreign annotations ignored", annotations: map[string]string{"example.com/anything": "??"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			warnings, errs := ValidateAnnotations(tc.annotations)
+			assertContains(t, "errors", errs, tc.wantErr)
+			assertContains(t, "warnings", warnings, tc.wantWarning)
+		})
+	}
+}
+
+func TestValidatorServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		kind        metav1.GroupVersionKind
+		object      string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "pod with valid annotations",
+			kind:        metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object:      `{"metadata":{"name":"p","annotations":{"ghostwire.dev/enabled":"true"}}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "deployment template with bad hook",
+			kind:        metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			object:      `{"metadata":{"name":"d"},"spec":{"template":{"metadata":{"annotations":{"ghostwire.dev/jumpHook":"FORWARD"}}}}}`,
+			wantMessage: "pod template: ghostwire.dev/jumpHook",
+		},
+		{
+			name:        "invalid ghostwireconfig",
+			kind:        metav1.GroupVersionKind{Group: "ghostwire.dev", Version: "v1alpha1", Kind: "GhostwireConfig"},
+			object:      `{"metadata":{"name":"c"},"spec":{"jumpHook":"INPUT"}}`,
+			wantMessage: "jumpHook",
+		},
+		{
+			name:        "invalid ghostwiremapping",
+			kind:        metav1.GroupVersionKind{Group: "ghostwire.dev", Version: "v1alpha1", Kind: "GhostwireMapping"},
+			object:      `{"metadata":{"name":"m"},"spec":{"service":"a","exclude":true,"previewService":"b"}}`,
+			wantMessage: "exclude cannot be combined",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			review := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       "uid-1",
+					Kind:      tc.kind,
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: []byte(tc.object)},
+				},
+			}
+			body, err := json.Marshal(review)
+			if err != nil {
+				t.Fatalf("marshal review: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			NewValidator(slog.New(slog.NewTextHandler(io.Discard, nil))).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var got admissionv1.AdmissionReview
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Response == nil || got.Response.UID != "uid-1" {
+				t.Fatalf("expected response for uid-1, got %+v", got.Response)
+			}
+			if got.Response.Allowed != tc.wantAllowed {
+				t.Fatalf("expected allowed=%v, got %+v", tc.wantAllowed, got.Response)
+			}
+			if tc.wantMessage != "" && !strings.Contains(got.Response.Result.Message, tc.wantMessage) {
+				t.Fatalf("expected message containing %q, got %q", tc.wantMessage, got.Response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsMalformedReview(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	NewValidator(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func assertContains(t *testing.T, label string, values []string, want string) {
+	t.Helper()
+
+	if want == "" {
+		if label == "errors" && len(values) > 0 {
+			t.Fatalf("unexpected %s: %v", label, values)
+		}
+		return
+	}
+	for _, v := range values {
+		if strings.Contains(v, want) {
+			return
+		}
+	}
+	t.Fatalf("expected %s to contain %q, got %v", label, want, values)
+}
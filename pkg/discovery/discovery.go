@@ -0,0 +1,160 @@
+// Package discovery is the public entry point to ghostwire's Service pairing,
+// for platform teams building their own controllers on top of it. It pairs
+// the Services of a namespace with their preview counterparts exactly like
+// ghostwire init does.
+//
+// Compatibility: the identifiers exported here follow semantic versioning
+// with the ghostwire module. Within a major version, fields may be added to
+// Options and ServiceMapping, but nothing is removed, renamed or changes
+// meaning. Everything under internal/ carries no such promise.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	internal "github.com/denniswebb/ghostwire/internal/discovery"
+)
+
+// ServiceMapping is one port of an active Service and where its traffic goes
+// while preview routing is on. Mappings are the input of the rules package.
+type ServiceMapping struct {
+	// Namespace is the namespace the Services were discovered in.
+	Namespace        string          `json:"namespace,omitempty"`
+	ServiceName      string          `json:"serviceName"`
+	Port             int32           `json:"port"`
+	Protocol         corev1.Protocol `json:"protocol"`
+	ActiveClusterIP  string          `json:"activeClusterIP"`
+	PreviewClusterIP string          `json:"previewClusterIP"`
+	// PreviewServiceName is the Service the mapping redirects to, as
+	// "namespace/name" when it lives outside Namespace.
+	PreviewServiceName string `json:"previewServiceName,omitempty"`
+	// PortName is the active Service's port name, if any.
+	PortName string `json:"portName,omitempty"`
+	// PreviewPort is the destination port on the preview Service. Zero means
+	// the preview Service listens on the same port as the active one.
+	PreviewPort int32 `json:"previewPort,omitempty"`
+	// IdenticalPorts reports that both Services expose exactly the same ports,
+	// so one DNAT rule per destination can stand in for the per-port rules.
+	IdenticalPorts bool `json:"identicalPorts,omitempty"`
+	// ActiveTargetPort and PreviewTargetPort record each Service's targetPort,
+	// set when Options.ResolveEndpoints is on.
+	ActiveTargetPort  string `json:"activeTargetPort,omitempty"`
+	PreviewTargetPort string `json:"previewTargetPort,omitempty"`
+	// PreviewEndpoints lists the ready preview pods behind the port, set when
+	// Options.ResolveEndpoints is on.
+	PreviewEndpoints []Endpoint `json:"previewEndpoints,omitempty"`
+}
+
+// TargetPort returns the preview port DNAT rewrites to.
+func (m ServiceMapping) TargetPort() int32 {
+	if m.PreviewPort != 0 {
+		return m.PreviewPort
+	}
+	return m.Port
+}
+
+func (m ServiceMapping) String() string {
+	preview := m.PreviewClusterIP
+	if m.PreviewPort != 0 && m.PreviewPort != m.Port {
+		preview = fmt.Sprintf("%s:%d", m.PreviewClusterIP, m.PreviewPort)
+	}
+	return fmt.Sprintf("%s:%d/%s -> active=%s preview=%s", m.ServiceName, m.Port, string(m.Protocol), m.ActiveClusterIP, preview)
+}
+
+// Endpoint is a ready preview pod behind a mapping, set when
+// Options.ResolveEndpoints is on.
+type Endpoint struct {
+	IP   string `json:"ip"`
+	Port int32  `json:"port"`
+}
+
+const (
+	// DefaultPreviewPattern derives "orders-preview" from "orders".
+	DefaultPreviewPattern = "{{name}}-preview"
+	// PairByName pairs Services through PreviewPattern.
+	PairByName = "name"
+	// PairByRelease pairs the Services of an active Helm release with those
+	// of its preview release.
+	PairByRelease = "release"
+)
+
+// Options select and pair the Services of one namespace. The zero value,
+// apart from Namespace, pairs by name with DefaultPreviewPattern.
+type Options struct {
+	Namespace string
+	// PreviewPattern names the preview Service of an active one; {{name}} is
+	// replaced by the active name. It may name another namespace as
+	// "namespace/name".
+	PreviewPattern string
+	// PairBy is PairByName (the default) or PairByRelease.
+	PairBy string
+	// ReleaseLabel and ReleasePattern drive PairByRelease: the label holding
+	// a Service's release, and the pattern deriving the preview release.
+	ReleaseLabel   string
+	ReleasePattern string
+	// ServiceSelector restricts active Services to those matching it.
+	ServiceSelector string
+	// ExcludeSelector fences out matching Services entirely.
+	ExcludeSelector string
+	// StatefulOrdinals pairs per-pod StatefulSet Services by ordinal.
+	StatefulOrdinals bool
+	// ResolveEndpoints fills ServiceMapping.PreviewEndpoints from the preview
+	// Services' EndpointSlices.
+	ResolveEndpoints bool
+}
+
+// Discover lists the Services in opts.Namespace through client and returns
+// their mappings. client needs list on services, and on endpointslices with
+// StatefulOrdinals or ResolveEndpoints. A nil logger logs to slog.Default.
+func Discover(ctx context.Context, client kubernetes.Interface, opts Options, logger *slog.Logger) ([]ServiceMapping, error) {
+	previewPattern := opts.PreviewPattern
+	if previewPattern == "" {
+		previewPattern = DefaultPreviewPattern
+	}
+	mappings, err := internal.Discover(ctx, internal.Config{
+		Clientset:        client,
+		Namespace:        opts.Namespace,
+		PreviewPattern:   previewPattern,
+		ActiveSuffix:     "-active",
+		PreviewSuffix:    "-preview",
+		PairBy:           opts.PairBy,
+		ReleaseLabel:     opts.ReleaseLabel,
+		ReleasePattern:   opts.ReleasePattern,
+		ServiceSelector:  opts.ServiceSelector,
+		ExcludeSelector:  opts.ExcludeSelector,
+		StatefulOrdinals: opts.StatefulOrdinals,
+		ResolveEndpoints: opts.ResolveEndpoints,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	public := make([]ServiceMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		var endpoints []Endpoint
+		for _, endpoint := range mapping.PreviewEndpoints {
+			endpoints = append(endpoints, Endpoint{IP: endpoint.IP, Port: endpoint.Port})
+		}
+		public = append(public, ServiceMapping{
+			Namespace:          mapping.Namespace,
+			ServiceName:        mapping.ServiceName,
+			Port:               mapping.Port,
+			Protocol:           mapping.Protocol,
+			ActiveClusterIP:    mapping.ActiveClusterIP,
+			PreviewClusterIP:   mapping.PreviewClusterIP,
+			PreviewServiceName: mapping.PreviewServiceName,
+			PortName:           mapping.PortName,
+			PreviewPort:        mapping.PreviewPort,
+			IdenticalPorts:     mapping.IdenticalPorts,
+			ActiveTargetPort:   mapping.ActiveTargetPort,
+			PreviewTargetPort:  mapping.PreviewTargetPort,
+			PreviewEndpoints:   endpoints,
+		})
+	}
+	return public, nil
+}
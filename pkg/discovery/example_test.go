@@ -0,0 +1,43 @@
+package discovery_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/denniswebb/ghostwire/pkg/discovery"
+)
+
+func service(name, clusterIP string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: clusterIP,
+			Ports:     []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}},
+		},
+	}
+}
+
+func ExampleDiscover() {
+	client := fake.NewSimpleClientset(
+		service("orders", "10.0.0.10"),
+		service("orders-preview", "10.0.0.11"),
+		service("billing", "10.0.0.20"),
+	)
+
+	mappings, err := discovery.Discover(context.Background(), client, discovery.Options{Namespace: "shop"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		fmt.Println("discover:", err)
+		return
+	}
+	for _, mapping := range mappings {
+		fmt.Println(mapping)
+	}
+	// Output:
+	// orders:80/TCP -> active=10.0.0.10 preview=10.0.0.11
+}
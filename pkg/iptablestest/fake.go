@@ -0,0 +1,248 @@
+// Package iptablestest provides a scriptable fake of ghostwire's iptables
+// Executor for tests that build on ghostwire's rule management. The fake
+// records every command instead of running it, supports error injection, and
+// tracks chain existence per table and address family.
+package iptablestest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+)
+
+// ExitRuleMissing is the exit code iptables uses when a checked rule or
+// listed chain does not exist.
+const ExitRuleMissing = 1
+
+var _ iptables.Executor = (*Fake)(nil)
+
+// Call is one command recorded by the fake.
+type Call struct {
+	Command string
+	Args    []string
+}
+
+// String renders the call as a command line.
+func (c Call) String() string {
+	return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+}
+
+// Contains reports whether any argument equals arg.
+func (c Call) Contains(arg string) bool {
+	for _, a := range c.Args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitError is an error carrying a process exit code, as returned by a
+// failing iptables invocation.
+type ExitError struct {
+	Code int
+}
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit code.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Exit returns an ExitError with code. Run wraps it the way the real executor
+// wraps command failures, so ghostwire's existence checks recognise it.
+func Exit(code int) error {
+	return &ExitError{Code: code}
+}
+
+type rule struct {
+	match func(Call) bool
+	err   error
+}
+
+// Fake is a scriptable iptables Executor. The zero value is ready to use:
+// every command succeeds and no chain exists. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	calls   []Call
+	rules   []rule
+	chains  map[string]bool
+	chains6 map[string]bool
+}
+
+// New returns an empty Fake.
+func New() *Fake {
+	return &Fake{}
+}
+
+// Run records the command and returns the error of the first matching
+// injection, or nil.
+func (f *Fake) Run(_ context.Context, command string, args ...string) error {
+	call := Call{Command: command, Args: append([]string(nil), args...)}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	var err error
+	for _, r := range f.rules {
+		if r.match(call) {
+			err = r.err
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return iptables.NewCommandError(command, args, "", "", err)
+	}
+	return err
+}
+
+// ChainExists reports whether the IPv4 chain was marked present.
+func (f *Fake) ChainExists(_ context.Context, table string, chain string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.chains[table+"/"+chain], nil
+}
+
+// ChainExists6 reports whether the IPv6 chain was marked present.
+func (f *Fake) ChainExists6(_ context.Context, table string, chain string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.chains6[table+"/"+chain], nil
+}
+
+// SetChain marks an IPv4 chain as present or absent.
+func (f *Fake) SetChain(table string, chain string, exists bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.chains == nil {
+		f.chains = make(map[string]bool)
+	}
+	f.chains[table+"/"+chain] = exists
+}
+
+// SetChain6 marks an IPv6 chain as present or absent.
+func (f *Fake) SetChain6(table string, chain string, exists bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.chains6 == nil {
+		f.chains6 = make(map[string]bool)
+	}
+	f.chains6[table+"/"+chain] = exists
+}
+
+// FailOn makes the exact command fail with err. Use Exit(ExitRuleMissing) to
+// make a -C check report a missing rule.
+func (f *Fake) FailOn(err error, command string, args ...string) {
+	want := Call{Command: command, Args: args}.String()
+	f.FailWhen(func(c Call) bool { return c.String() == want }, err)
+}
+
+// FailWhen makes every command accepted by match fail with err. Injections
+// are consulted in the order they were added.
+func (f *Fake) FailWhen(match func(Call) bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule{match: match, err: err})
+}
+
+// RulesMissing makes every -C check fail with ExitRuleMissing, so code under
+// test treats all rules as absent.
+func (f *Fake) RulesMissing() {
+	f.FailWhen(func(c Call) bool { return c.Contains("-C") }, Exit(ExitRuleMissing))
+}
+
+// Calls returns a copy of the recorded calls.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Call, len(f.calls))
+	for i, c := range f.calls {
+		out[i] = Call{Command: c.Command, Args: append([]string(nil), c.Args...)}
+	}
+	return out
+}
+
+// Reset forgets recorded calls. Injections and chain state are kept.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// AssertCalled fails the test unless the exact command was recorded.
+func (f *Fake) AssertCalled(t testing.TB, command string, args ...string) {
+	t.Helper()
+	want := Call{Command: command, Args: args}.String()
+	for _, c := range f.Calls() {
+		if c.String() == want {
+			return
+		}
+	}
+	t.Fatalf("expected call %q, got:\n%s", want, f.describe())
+}
+
+// AssertNotCalled fails the test if any recorded call contains all args.
+func (f *Fake) AssertNotCalled(t testing.TB, args ...string) {
+	t.Helper()
+	for _, c := range f.Calls() {
+		matched := true
+		for _, arg := range args {
+			if !c.Contains(arg) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			t.Fatalf("unexpected call %q", c.String())
+		}
+	}
+}
+
+// AssertCallsContain fails the test unless call i contains markers[i] as an
+// argument, for each marker. Extra calls are allowed.
+func (f *Fake) AssertCallsContain(t testing.TB, markers ...string) {
+	t.Helper()
+	calls := f.Calls()
+	if len(calls) < len(markers) {
+		t.Fatalf("expected at least %d calls, got:\n%s", len(markers), f.describe())
+	}
+	for i, marker := range markers {
+		if !calls[i].Contains(marker) {
+			t.Fatalf("expected call %d to contain %q, got %q", i, marker, calls[i].String())
+		}
+	}
+}
+
+// AssertNoCalls fails the test if any command was recorded.
+func (f *Fake) AssertNoCalls(t testing.TB) {
+	t.Helper()
+	if calls := f.Calls(); len(calls) != 0 {
+		t.Fatalf("expected no calls, got:\n%s", f.describe())
+	}
+}
+
+func (f *Fake) describe() string {
+	calls := f.Calls()
+	if len(calls) == 0 {
+		return "  (none)"
+	}
+	lines := make([]string, len(calls))
+	for i, c := range calls {
+		lines[i] = fmt.Sprintf("  %d: %s", i, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package iptablestest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/pkg/iptablestest"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestFakeDrivesJumpManagement(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := iptablestest.New()
+	fake.RulesMissing()
+
+	if err := iptables.AddJump(ctx, fake, "nat", "OUTPUT", "CANARY_DNAT", false, discardLogger()); err != nil {
+		t.Fatalf("AddJump returned error: %v", err)
+	}
+	fake.AssertCallsContain(t, "-C", "-I")
+	fake.AssertCalled(t, "iptables", "-w", "5", "-t", "nat", "-I", "OUTPUT", "1", "-m", "comment", "--comment", "ghostwire", "-j", "CANARY_DNAT")
+
+	fake.Reset()
+	if err := iptables.RemoveJump(ctx, fake, "nat", "OUTPUT", "CANARY_DNAT", false, discardLogger()); err != nil {
+		t.Fatalf("RemoveJump returned error: %v", err)
+	}
+	fake.AssertNotCalled(t, "-D")
+}
+
+func TestFakeErrorInjection(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	boom := errors.New("boom")
+	fake := iptablestest.New()
+	fake.FailOn(boom, "iptables", "-w", "5", "-t", "nat", "-N", "CANARY_DNAT")
+
+	err := iptables.EnsureChain(ctx, fake, "nat", "CANARY_DNAT", false, discardLogger())
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+
+	fake.SetChain("nat", "CANARY_DNAT", true)
+	fake.Reset()
+	if err := iptables.EnsureChain(ctx, fake, "nat", "CANARY_DNAT", false, discardLogger()); err != nil {
+		t.Fatalf("EnsureChain returned error: %v", err)
+	}
+	fake.AssertNotCalled(t, "-F")
+
+	var cmdErr *iptables.CommandError
+	fake.FailWhen(func(c iptablestest.Call) bool { return c.Contains("-X") }, iptablestest.Exit(4))
+	err = fake.Run(ctx, "iptables", "-t", "nat", "-X", "CANARY_DNAT")
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected exit errors wrapped in CommandError, got %T", err)
+	}
+	var exit *iptablestest.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 4 {
+		t.Fatalf("expected exit code 4, got %v", err)
+	}
+}
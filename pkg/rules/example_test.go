@@ -0,0 +1,46 @@
+package rules_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/denniswebb/ghostwire/pkg/discovery"
+	"github.com/denniswebb/ghostwire/pkg/iptablestest"
+	"github.com/denniswebb/ghostwire/pkg/rules"
+)
+
+var mappings = []discovery.ServiceMapping{
+	{ServiceName: "orders", Port: 80, Protocol: corev1.ProtocolTCP, ActiveClusterIP: "10.0.0.10", PreviewClusterIP: "10.0.0.11"},
+}
+
+func ExampleNewPlan() {
+	plan, err := rules.NewPlan(context.Background(), mappings, rules.Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		fmt.Println("plan:", err)
+		return
+	}
+	for _, rule := range plan.Rules("nat", false) {
+		fmt.Println(rule)
+	}
+	// Output:
+	// -A CANARY_DNAT -d 10.0.0.10 -p tcp --dport 80 -m comment --comment ghostwire -j DNAT --to-destination 10.0.0.11:80
+	// -I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT
+}
+
+func ExampleActivate() {
+	executor := iptablestest.New()
+	executor.RulesMissing()
+
+	if err := rules.Activate(context.Background(), executor, rules.Options{Hooks: []string{"OUTPUT"}}, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		fmt.Println("activate:", err)
+		return
+	}
+	calls := executor.Calls()
+	fmt.Println(calls[len(calls)-1])
+	// Output:
+	// iptables -w 5 -t nat -I OUTPUT 1 -m comment --comment ghostwire -j CANARY_DNAT
+}
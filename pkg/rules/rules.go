@@ -0,0 +1,173 @@
+// Package rules is the public entry point to ghostwire's rule planning and
+// application: it turns mappings from the discovery package into the DNAT
+// chain ghostwire init builds and the jump the watcher toggles.
+//
+// Compatibility: the identifiers exported here follow semantic versioning
+// with the ghostwire module. Within a major version, fields may be added to
+// Options, but nothing is removed, renamed or changes meaning. The exact
+// rules rendered for a set of options may change between minor versions when
+// ghostwire's rule layout does; Plan output is meant for review and
+// iptables-restore, not for parsing.
+package rules
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	internal "github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/internal/iptables"
+	"github.com/denniswebb/ghostwire/pkg/discovery"
+)
+
+// Executor runs iptables commands. NewExecutor returns the one that executes
+// them on the host; pkg/iptablestest has a fake for tests.
+type Executor interface {
+	Run(ctx context.Context, command string, args ...string) error
+	ChainExists(ctx context.Context, table string, chain string) (bool, error)
+	ChainExists6(ctx context.Context, table string, chain string) (bool, error)
+}
+
+// OutputExecutor is implemented by executors that can also return a
+// command's output. Positioned jumps and rule listings need it.
+type OutputExecutor interface {
+	Output(ctx context.Context, command string, args ...string) (string, error)
+}
+
+// NewExecutor returns an Executor that runs iptables, ip6tables and the
+// helper tools ghostwire needs, refusing anything else.
+func NewExecutor() Executor {
+	return iptables.NewExecutor()
+}
+
+const (
+	// DefaultChain is the chain holding the DNAT rules.
+	DefaultChain = "CANARY_DNAT"
+	// DefaultHook is the built-in chain that jumps to it.
+	DefaultHook = "OUTPUT"
+)
+
+// Options shape the chain. The zero value builds DefaultChain, jumped to from
+// DefaultHook, with IPv4 rules for every protocol.
+type Options struct {
+	Chain string
+	Hooks []string
+	// ExcludeCIDRs are destinations never redirected.
+	ExcludeCIDRs []string
+	IPv6         bool
+	// Protocols restricts the DNAT rules to these protocols.
+	Protocols []corev1.Protocol
+	// WholeServiceDNAT and Multiport collapse rules where ports allow it.
+	WholeServiceDNAT bool
+	Multiport        bool
+	// EndpointDNAT sends mappings with resolved endpoints straight to the
+	// preview pods.
+	EndpointDNAT bool
+}
+
+func (o Options) chain() string {
+	if chain := strings.TrimSpace(o.Chain); chain != "" {
+		return chain
+	}
+	return DefaultChain
+}
+
+func (o Options) hooks() []string {
+	if len(o.Hooks) == 0 {
+		return []string{DefaultHook}
+	}
+	return o.Hooks
+}
+
+func (o Options) config() iptables.Config {
+	return iptables.Config{
+		ChainName:        o.chain(),
+		ExcludeCIDRs:     o.ExcludeCIDRs,
+		IPv6:             o.IPv6,
+		Protocols:        o.Protocols,
+		WholeServiceDNAT: o.WholeServiceDNAT,
+		Multiport:        o.Multiport,
+		EndpointDNAT:     o.EndpointDNAT,
+	}
+}
+
+// Plan is the complete ruleset for a set of mappings, with the jump in place.
+type Plan struct {
+	recorder *iptables.RestoreRecorder
+}
+
+// NewPlan builds the ruleset for mappings without executing anything.
+func NewPlan(ctx context.Context, mappings []discovery.ServiceMapping, opts Options, logger *slog.Logger) (*Plan, error) {
+	recorder, err := iptables.ExportRules(ctx, opts.config(), internalMappings(mappings), opts.hooks(), logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{recorder: recorder}, nil
+}
+
+// Rules returns the rules of table ("nat", "raw", ...) as "-A chain ..." or
+// "-I hook ..." lines, IPv6 ones when ipv6 is set.
+func (p *Plan) Rules(table string, ipv6 bool) []string {
+	return p.recorder.Rules(binary(ipv6), table)
+}
+
+// Render returns the ruleset in iptables-restore format, for
+// `iptables-restore --noflush` (or ip6tables-restore when ipv6 is set). It is
+// nil when the plan holds no rules of that family.
+func (p *Plan) Render(ipv6 bool) []byte {
+	return p.recorder.Render(binary(ipv6))
+}
+
+func binary(ipv6 bool) string {
+	if ipv6 {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// Apply builds the chain through executor, flushing it first when it exists.
+// Like ghostwire init it leaves the jump alone, so traffic is unaffected
+// until Activate.
+func Apply(ctx context.Context, executor Executor, mappings []discovery.ServiceMapping, opts Options, logger *slog.Logger) error {
+	return iptables.SetupWithExecutor(ctx, executor, opts.config(), internalMappings(mappings), logger)
+}
+
+// Activate adds the jump from every hook to the chain, turning preview
+// routing on. It does nothing for jumps already in place.
+func Activate(ctx context.Context, executor Executor, opts Options, logger *slog.Logger) error {
+	return iptables.AddJumps(ctx, executor, "nat", opts.hooks(), opts.chain(), opts.IPv6, logger)
+}
+
+// Deactivate removes the jumps, turning preview routing off.
+func Deactivate(ctx context.Context, executor Executor, opts Options, logger *slog.Logger) error {
+	return iptables.RemoveJumps(ctx, executor, "nat", opts.hooks(), opts.chain(), opts.IPv6, logger)
+}
+
+// internalMappings converts mappings to the type the rule builders take.
+func internalMappings(mappings []discovery.ServiceMapping) []internal.ServiceMapping {
+	converted := make([]internal.ServiceMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		var endpoints []internal.Endpoint
+		for _, endpoint := range mapping.PreviewEndpoints {
+			endpoints = append(endpoints, internal.Endpoint{IP: endpoint.IP, Port: endpoint.Port})
+		}
+		converted = append(converted, internal.ServiceMapping{
+			Namespace:          mapping.Namespace,
+			ServiceName:        mapping.ServiceName,
+			Port:               mapping.Port,
+			Protocol:           mapping.Protocol,
+			ActiveClusterIP:    mapping.ActiveClusterIP,
+			PreviewClusterIP:   mapping.PreviewClusterIP,
+			PreviewServiceName: mapping.PreviewServiceName,
+			PortName:           mapping.PortName,
+			PreviewPort:        mapping.PreviewPort,
+			IdenticalPorts:     mapping.IdenticalPorts,
+			ActiveTargetPort:   mapping.ActiveTargetPort,
+			PreviewTargetPort:  mapping.PreviewTargetPort,
+			PreviewEndpoints:   endpoints,
+		})
+	}
+	return converted
+}
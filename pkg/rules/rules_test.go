@@ -0,0 +1,59 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	internal "github.com/denniswebb/ghostwire/internal/discovery"
+	"github.com/denniswebb/ghostwire/pkg/discovery"
+)
+
+func TestInternalMappings(t *testing.T) {
+	t.Parallel()
+
+	// Every field the rule builders read must have a public counterpart, or
+	// it silently drops out at the boundary.
+	public := reflect.TypeOf(discovery.ServiceMapping{})
+	fields := reflect.TypeOf(internal.ServiceMapping{})
+	for i := 0; i < fields.NumField(); i++ {
+		if _, ok := public.FieldByName(fields.Field(i).Name); !ok {
+			t.Errorf("discovery.ServiceMapping lacks field %s", fields.Field(i).Name)
+		}
+	}
+
+	mapping := discovery.ServiceMapping{
+		Namespace:          "shop",
+		ServiceName:        "orders",
+		Port:               80,
+		Protocol:           corev1.ProtocolTCP,
+		ActiveClusterIP:    "10.0.0.10",
+		PreviewClusterIP:   "10.0.0.11",
+		PreviewServiceName: "orders-preview",
+		PortName:           "http",
+		PreviewPort:        8080,
+		IdenticalPorts:     true,
+		ActiveTargetPort:   "http",
+		PreviewTargetPort:  "8080",
+		PreviewEndpoints:   []discovery.Endpoint{{IP: "10.1.0.5", Port: 8080}},
+	}
+	want := internal.ServiceMapping{
+		Namespace:          "shop",
+		ServiceName:        "orders",
+		Port:               80,
+		Protocol:           corev1.ProtocolTCP,
+		ActiveClusterIP:    "10.0.0.10",
+		PreviewClusterIP:   "10.0.0.11",
+		PreviewServiceName: "orders-preview",
+		PortName:           "http",
+		PreviewPort:        8080,
+		IdenticalPorts:     true,
+		ActiveTargetPort:   "http",
+		PreviewTargetPort:  "8080",
+		PreviewEndpoints:   []internal.Endpoint{{IP: "10.1.0.5", Port: 8080}},
+	}
+	if got := internalMappings([]discovery.ServiceMapping{mapping}); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Fatalf("unexpected conversion: %+v", got)
+	}
+}
@@ -75,7 +75,7 @@ for entry in "${services[@]}"; do
 		echo "error: missing cluster IPs for ${active}/${preview}" >&2
 		exit 1
 	fi
-	rule="-A ${CHAIN_NAME} -d ${active_ip} -p tcp --dport ${port} -j DNAT --to-destination ${preview_ip}:${port}"
+	rule="-A ${CHAIN_NAME} -d ${active_ip} -p tcp --dport ${port} -m comment --comment \"\?ghostwire\"\? -j DNAT --to-destination ${preview_ip}:${port}"
 	expect_rule "${rule}"
 done
 